@@ -0,0 +1,9 @@
+// Package integration holds end-to-end tests of the kubectl and git modules
+// against a real cluster. They are built with the integration tag:
+//
+//	go test -tags integration ./integration/...
+//
+// A kind cluster is created for the run and deleted afterwards. Set
+// INTEGRATION_KUBECONFIG to run against an existing cluster instead, or
+// KEEP_CLUSTER=1 to leave the kind cluster running for inspection.
+package integration
@@ -0,0 +1,439 @@
+//go:build integration
+
+package integration
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/git"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// gitRun runs git in dir and fails the test if it fails
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
+
+// newRemote creates a bare repository with an initial commit on main,
+// standing in for the remote GitOps repository
+func newRemote(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	seed := filepath.Join(dir, "seed")
+	remote := filepath.Join(dir, "remote.git")
+
+	gitRun(t, dir, "init", "--initial-branch=main", seed)
+	if err := os.WriteFile(filepath.Join(seed, "README.md"), []byte("# Cluster configs\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, seed, "add", ".")
+	gitRun(t, seed, "commit", "-m", "Initial commit")
+	gitRun(t, dir, "clone", "--bare", seed, remote)
+	return remote
+}
+
+// newGitCluster returns cluster info whose GitOps repository is a new bare
+// repository, cloned into a temp directory
+func newGitCluster(t *testing.T) *config.ClusterInfo {
+	t.Helper()
+	cluster := newCluster(t)
+	cluster.GitRepo = newRemote(t)
+	cluster.GitRepoPath = filepath.Join(t.TempDir(), "clone")
+	return cluster
+}
+
+// remoteFile returns a file as committed on main of the remote repository
+func remoteFile(t *testing.T, remote, path string) string {
+	t.Helper()
+	return gitRun(t, remote, "show", "main:"+path)
+}
+
+// TestGitSync exports the cluster into the repository and pushes it, then
+// syncs a single namespace after a change
+func TestGitSync(t *testing.T) {
+	cluster := newGitCluster(t)
+	executor := kubectl.NewExecutor(cluster)
+	ns := newNamespace(t, executor)
+	mustExecute(t, executor, "create", "deployment", "api", "-n", ns, "--image=registry.k8s.io/pause:3.9")
+
+	manager, err := git.NewManager(cluster, executor)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if err := manager.ValidateRepository(); err != nil {
+		t.Fatalf("ValidateRepository failed: %v", err)
+	}
+
+	// Work in progress in the user's checkout is left alone by syncs
+	readme := filepath.Join(cluster.GitRepoPath, "README.md")
+	if err := os.WriteFile(readme, []byte("# Work in progress\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	head := gitRun(t, cluster.GitRepoPath, "rev-parse", "HEAD")
+
+	if err := manager.SyncChanges("Export cluster"); err != nil {
+		t.Fatalf("SyncChanges failed: %v", err)
+	}
+	if after := gitRun(t, cluster.GitRepoPath, "rev-parse", "HEAD"); after != head {
+		t.Errorf("SyncChanges moved the user's checkout from %s to %s", head, after)
+	}
+	if changes := gitRun(t, cluster.GitRepoPath, "status", "--porcelain"); changes != " M README.md\n" {
+		t.Errorf("user's checkout status = %q, want only the README edit", changes)
+	}
+	if content := remoteFile(t, cluster.GitRepo, "README.md"); content != "# Cluster configs\n" {
+		t.Errorf("SyncChanges pushed the user's README edit: %q", content)
+	}
+	deployments := remoteFile(t, cluster.GitRepo, cluster.Name+"/deployments.yaml")
+	if !strings.Contains(deployments, "name: api") || !strings.Contains(deployments, "namespace: "+ns) {
+		t.Errorf("pushed deployments.yaml doesn't contain api in %s:\n%s", ns, deployments)
+	}
+	if log := gitRun(t, cluster.GitRepo, "log", "--format=%s", "main"); !strings.HasPrefix(log, "Export cluster\n") {
+		t.Errorf("remote log = %q, want the export commit on top", log)
+	}
+
+	status, err := manager.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Branch != "main" || len(status.Changes) != 0 || status.LastSyncErr != nil || status.LastSync.IsZero() {
+		t.Errorf("Status = %+v, want a clean main after a successful sync", status)
+	}
+	if !strings.Contains(status.LastCommit, "Export cluster") {
+		t.Errorf("Status.LastCommit = %q, want the export commit", status.LastCommit)
+	}
+
+	// Only the configmaps of the namespace are exported, merged into the
+	// file alongside those of other namespaces
+	mustExecute(t, executor, "create", "configmap", "flags", "-n", ns, "--from-literal=beta=on")
+	scope, ok := kubectl.AffectedResources("create configmap flags -n " + ns + " --from-literal=beta=on")
+	if !ok || len(scope.Resources) != 1 || scope.Resources[0] != "configmaps" || scope.Namespace != ns {
+		t.Fatalf("AffectedResources = %+v, %v; want configmaps in %s", scope, ok, ns)
+	}
+	if err := manager.SyncResources(scope, "Add flags"); err != nil {
+		t.Fatalf("SyncResources failed: %v", err)
+	}
+	configmaps := remoteFile(t, cluster.GitRepo, cluster.Name+"/configmaps.yaml")
+	if !strings.Contains(configmaps, "name: flags") || !strings.Contains(configmaps, "beta: \"on\"") {
+		t.Errorf("pushed configmaps.yaml doesn't contain flags:\n%s", configmaps)
+	}
+	if !strings.Contains(configmaps, "namespace: kube-system") {
+		t.Errorf("SyncResources dropped the configmaps of other namespaces:\n%s", configmaps)
+	}
+
+	// A sync without changes doesn't commit
+	before := gitRun(t, cluster.GitRepo, "rev-parse", "main")
+	if err := manager.SyncResources(kubectl.ResourceScope{Resources: []string{"deployments"}, Namespace: ns}, "No-op"); err != nil {
+		t.Fatalf("SyncResources failed: %v", err)
+	}
+	if after := gitRun(t, cluster.GitRepo, "rev-parse", "main"); after != before {
+		t.Errorf("SyncResources without changes committed")
+	}
+}
+
+// TestApplyFromGit applies a manifest from the repository, detects drift in
+// the live object with kubectl diff and reconciles it by applying again
+func TestApplyFromGit(t *testing.T) {
+	cluster := newGitCluster(t)
+	executor := kubectl.NewExecutor(cluster)
+	ns := newNamespace(t, executor)
+
+	// Someone else commits the app's manifest
+	work := filepath.Join(t.TempDir(), "work")
+	gitRun(t, filepath.Dir(work), "clone", cluster.GitRepo, work)
+	appDir := filepath.Join(work, "apps")
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(appDir, "web.yaml"), []byte(deploymentManifest("web", ns, 2)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, work, "add", ".")
+	gitRun(t, work, "commit", "-m", "Add web")
+	gitRun(t, work, "push", "origin", "main")
+
+	manager, err := git.NewManager(cluster, executor)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	manifest := filepath.Join(cluster.GitRepoPath, "apps", "web.yaml")
+	if !kubectl.IsManifestFile(manifest) {
+		t.Fatalf("IsManifestFile(%q) = false", manifest)
+	}
+
+	diff, changed, err := executor.Diff("-f", manifest)
+	if err != nil || !changed || !strings.Contains(diff, "web") {
+		t.Fatalf("Diff before apply = %q, %v, %v; want the new deployment", diff, changed, err)
+	}
+	if output, err := executor.Apply("-f", manifest); err != nil {
+		t.Fatalf("Apply failed: %v\n%s", err, output)
+	}
+	if diff, changed, err := executor.Diff("-f", manifest); err != nil || changed {
+		t.Fatalf("Diff after apply = %q, %v, %v; want no drift", diff, changed, err)
+	}
+
+	// Drift: the live deployment is scaled by hand
+	mustExecute(t, executor, "scale", "deployment", "web", "-n", ns, "--replicas=5")
+	diff, changed, err = executor.Diff("-f", manifest)
+	if err != nil || !changed {
+		t.Fatalf("Diff after scaling = %q, %v, %v; want drift", diff, changed, err)
+	}
+	if !strings.Contains(diff, "-  replicas: 5") || !strings.Contains(diff, "+  replicas: 2") {
+		t.Errorf("Diff after scaling doesn't show the replica change:\n%s", diff)
+	}
+
+	// Reconcile from git
+	if output, err := executor.Apply("-f", manifest); err != nil {
+		t.Fatalf("Apply failed: %v\n%s", err, output)
+	}
+	if diff, changed, err := executor.Diff("-f", manifest); err != nil || changed {
+		t.Errorf("Diff after reconciling = %q, %v, %v; want no drift", diff, changed, err)
+	}
+	if replicas := mustExecute(t, executor, "get", "deployment", "web", "-n", ns, "-o", "jsonpath={.spec.replicas}"); replicas != "2" {
+		t.Errorf("replicas after reconciling = %q, want 2", replicas)
+	}
+}
+
+// TestGitBootstrap lays out an empty repository for two clusters sharing it
+func TestGitBootstrap(t *testing.T) {
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	gitRun(t, filepath.Dir(remote), "init", "--bare", remote)
+
+	cluster := newCluster(t)
+	cluster.GitRepo = remote
+	cluster.GitRepoPath = filepath.Join(t.TempDir(), "clone")
+	executor := kubectl.NewExecutor(cluster)
+	manager, err := git.NewManager(cluster, executor)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Initialize of an empty repository failed: %v", err)
+	}
+	if status, err := manager.Status(); err != nil || !status.NeedsBootstrap {
+		t.Fatalf("Status = %+v, %v; want an empty repository needing a bootstrap", status, err)
+	}
+
+	if err := manager.Bootstrap(git.BootstrapOptions{ArgoCD: true}); err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	files := gitRun(t, remote, "ls-tree", "-r", "--name-only", "main")
+	for _, want := range []string{"README.md", "argocd/integration.yaml", "integration/namespaces.yaml", "integration/deployments.yaml"} {
+		if !strings.Contains(files, want+"\n") {
+			t.Errorf("Expected %s in the bootstrap commit, got:\n%s", want, files)
+		}
+	}
+	if count := gitRun(t, remote, "rev-list", "--count", "main"); count != "1\n" {
+		t.Errorf("Expected a single bootstrap commit, got %s", count)
+	}
+	application := remoteFile(t, remote, "argocd/integration.yaml")
+	if !strings.Contains(application, "repoURL: "+remote) || !strings.Contains(application, "path: integration") {
+		t.Errorf("Unexpected ArgoCD Application:\n%s", application)
+	}
+	if err := manager.Bootstrap(git.BootstrapOptions{}); err == nil {
+		t.Errorf("Expected a second bootstrap of the cluster to be refused")
+	}
+
+	// Another cluster cloned the repository while it was still empty
+	other := newCluster(t)
+	other.Name = "staging"
+	other.GitRepo = remote
+	other.GitRepoPath = filepath.Join(t.TempDir(), "clone")
+	otherManager, err := git.NewManager(other, kubectl.NewExecutor(other))
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if err := otherManager.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if err := otherManager.Bootstrap(git.BootstrapOptions{}); err != nil {
+		t.Fatalf("Bootstrap of a second cluster failed: %v", err)
+	}
+	files = gitRun(t, remote, "ls-tree", "-r", "--name-only", "main")
+	if !strings.Contains(files, "staging/deployments.yaml\n") || strings.Contains(files, "argocd/staging.yaml") {
+		t.Errorf("Expected staging's exports without an Application, got:\n%s", files)
+	}
+	if err := manager.SyncChanges("Sync after staging"); err != nil {
+		t.Errorf("SyncChanges after another cluster's bootstrap failed: %v", err)
+	}
+}
+
+// TestGitPruneStale checks that the export of a kind whose objects were all
+// deleted is removed from the repository, unless the cluster keeps it
+func TestGitPruneStale(t *testing.T) {
+	cluster := newGitCluster(t)
+	executor := kubectl.NewExecutor(cluster)
+	ns := newNamespace(t, executor)
+	mustExecute(t, executor, "create", "cronjob", "report", "-n", ns, "--image=registry.k8s.io/pause:3.9", "--schedule=0 0 * * *")
+
+	manager, err := git.NewManager(cluster, executor)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if err := manager.SyncChanges("Export cluster"); err != nil {
+		t.Fatalf("SyncChanges failed: %v", err)
+	}
+	cronjobs := cluster.Name + "/cronjobs.yaml"
+	if !strings.Contains(remoteFile(t, cluster.GitRepo, cronjobs), "name: report") {
+		t.Fatalf("pushed cronjobs.yaml doesn't contain report")
+	}
+
+	mustExecute(t, executor, "delete", "cronjob", "report", "-n", ns)
+	scope := kubectl.ResourceScope{Resources: []string{"cronjobs"}, Namespace: ns}
+	if err := manager.SyncResources(scope, "Delete report"); err != nil {
+		t.Fatalf("SyncResources failed: %v", err)
+	}
+	if files := gitRun(t, cluster.GitRepo, "ls-tree", "-r", "--name-only", "main"); strings.Contains(files, cronjobs) {
+		t.Errorf("Expected %s to be removed, got:\n%s", cronjobs, files)
+	}
+	if changed := gitRun(t, cluster.GitRepo, "show", "--name-status", "--format=", "main"); !strings.Contains(changed, "D\t"+cronjobs) {
+		t.Errorf("Expected the removal in the sync commit, got:\n%s", changed)
+	}
+
+	// With stale exports kept, the emptied file stays
+	cluster.GitKeepStale = true
+	mustExecute(t, executor, "create", "cronjob", "report", "-n", ns, "--image=registry.k8s.io/pause:3.9", "--schedule=0 0 * * *")
+	if err := manager.SyncResources(scope, "Add report"); err != nil {
+		t.Fatalf("SyncResources failed: %v", err)
+	}
+	mustExecute(t, executor, "delete", "cronjob", "report", "-n", ns)
+	if err := manager.SyncResources(scope, "Delete report"); err != nil {
+		t.Fatalf("SyncResources failed: %v", err)
+	}
+	if content := remoteFile(t, cluster.GitRepo, cronjobs); strings.Contains(content, "name: report") {
+		t.Errorf("Expected the kept cronjobs.yaml to drop report:\n%s", content)
+	}
+}
+
+// TestGitOfflinePushQueue commits syncs locally while the remote is
+// unreachable and pushes them in the background once it is back
+func TestGitOfflinePushQueue(t *testing.T) {
+	cluster := newGitCluster(t)
+	executor := kubectl.NewExecutor(cluster)
+	ns := newNamespace(t, executor)
+
+	manager, err := git.NewManager(cluster, executor)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if err := manager.SyncChanges("Export cluster"); err != nil {
+		t.Fatalf("SyncChanges failed: %v", err)
+	}
+
+	// Take the remote away
+	offline := cluster.GitRepo + ".offline"
+	if err := os.Rename(cluster.GitRepo, offline); err != nil {
+		t.Fatal(err)
+	}
+	scope := kubectl.ResourceScope{Resources: []string{"configmaps"}, Namespace: ns}
+	for i, name := range []string{"first", "second"} {
+		mustExecute(t, executor, "create", "configmap", name, "-n", ns, "--from-literal=key=value")
+		err := manager.SyncResources(scope, "Add "+name)
+		var pushErr *git.PushError
+		if !errors.As(err, &pushErr) || pushErr.Pending != i+1 {
+			t.Fatalf("SyncResources offline = %v, want a PushError with %d commit(s) pending", err, i+1)
+		}
+	}
+	if pending := manager.PendingPushes(); pending != 2 {
+		t.Errorf("PendingPushes = %d, want 2", pending)
+	}
+
+	if err := os.Rename(offline, cluster.GitRepo); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Minute)
+	for manager.PendingPushes() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("queued commits weren't pushed: %d pending", manager.PendingPushes())
+		}
+		time.Sleep(time.Second)
+	}
+	if log := gitRun(t, cluster.GitRepo, "log", "--format=%s", "main"); !strings.HasPrefix(log, "Add second\nAdd first\n") {
+		t.Errorf("remote log = %q, want both queued commits on top", log)
+	}
+	if configmaps := remoteFile(t, cluster.GitRepo, cluster.Name+"/configmaps.yaml"); !strings.Contains(configmaps, "name: second") {
+		t.Errorf("pushed configmaps.yaml doesn't contain second:\n%s", configmaps)
+	}
+}
+
+// TestGitRBACDiff grants a service account cluster-admin and checks that the
+// sync committing it is reported as an RBAC change with a warning
+func TestGitRBACDiff(t *testing.T) {
+	cluster := newGitCluster(t)
+	executor := kubectl.NewExecutor(cluster)
+	ns := newNamespace(t, executor)
+	mustExecute(t, executor, "create", "serviceaccount", "deployer", "-n", ns)
+
+	manager, err := git.NewManager(cluster, executor)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if err := manager.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if err := manager.SyncChanges("Export cluster"); err != nil {
+		t.Fatalf("SyncChanges failed: %v", err)
+	}
+
+	binding := ns + "-deployer-admin"
+	mustExecute(t, executor, "create", "clusterrolebinding", binding, "--clusterrole=cluster-admin", "--serviceaccount="+ns+":deployer")
+	t.Cleanup(func() { executor.Execute("delete", "clusterrolebinding", binding) })
+
+	grants, err := executor.ListRBAC()
+	if err != nil {
+		t.Fatalf("ListRBAC failed: %v", err)
+	}
+	found := false
+	for _, grant := range grants {
+		if grant.Binding == "ClusterRoleBinding/"+binding {
+			found = grant.ClusterAdmin() && grant.Subject.Name == "deployer" && grant.Subject.Namespace == ns
+		}
+	}
+	if !found {
+		t.Errorf("ListRBAC doesn't hold a cluster-admin grant to %s/deployer", ns)
+	}
+
+	head := manager.Head()
+	if err := manager.SyncChanges("Grant deployer cluster-admin"); err != nil {
+		t.Fatalf("SyncChanges failed: %v", err)
+	}
+	change, err := manager.LastRBACChange()
+	if err != nil {
+		t.Fatalf("LastRBACChange failed: %v", err)
+	}
+	if change == nil || change.Hash == head || change.Hash != manager.Head() {
+		t.Fatalf("LastRBACChange = %+v, want the grant commit", change)
+	}
+	if len(change.Diff.Added) != 1 || change.Diff.Added[0].Binding != "ClusterRoleBinding/"+binding {
+		t.Errorf("Diff.Added = %+v, want only the new binding", change.Diff.Added)
+	}
+	warnings := change.Diff.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "cluster-admin") {
+		t.Errorf("Warnings = %q, want one cluster-admin warning", warnings)
+	}
+}
@@ -0,0 +1,156 @@
+//go:build integration
+
+package integration
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// TestExecutorQueries checks the read-only helpers against a fresh cluster
+func TestExecutorQueries(t *testing.T) {
+	executor := kubectl.NewExecutor(newCluster(t))
+
+	if err := executor.TestConnection(); err != nil {
+		t.Fatalf("TestConnection failed: %v", err)
+	}
+	if version, err := executor.GetVersion(); err != nil || !strings.Contains(version, "Server Version") {
+		t.Errorf("GetVersion = %q, %v; want the server version", version, err)
+	}
+	if nodes, err := executor.GetNodes(); err != nil || !strings.Contains(nodes, "Ready") {
+		t.Errorf("GetNodes = %q, %v; want a ready node", nodes, err)
+	}
+
+	namespaces, err := executor.ListNamespaces()
+	if err != nil {
+		t.Fatalf("ListNamespaces failed: %v", err)
+	}
+	found := false
+	for _, ns := range namespaces {
+		if ns.Name == "kube-system" {
+			found = true
+			if ns.Status != "Active" {
+				t.Errorf("kube-system status = %q, want Active", ns.Status)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("ListNamespaces didn't return kube-system: %+v", namespaces)
+	}
+
+	capabilities, err := executor.DetectCapabilities()
+	if err != nil {
+		t.Fatalf("DetectCapabilities failed: %v", err)
+	}
+	if capabilities.ArgoCD || capabilities.Prometheus {
+		t.Errorf("DetectCapabilities = %+v on a bare cluster, want none", capabilities)
+	}
+}
+
+// TestExecutorHeredocApply applies a manifest given as a heredoc, the way it
+// is typed in the terminal, and reads the objects back
+func TestExecutorHeredocApply(t *testing.T) {
+	executor := kubectl.NewExecutor(newCluster(t))
+	ns := newNamespace(t, executor)
+
+	command := "apply -n " + ns + " -f - <<EOF\n" + deploymentManifest("web", ns, 1) + "EOF"
+	if !kubectl.CommandComplete(command) {
+		t.Fatalf("CommandComplete(%q) = false", command)
+	}
+	if output, err := executor.ExecuteCommand(command); err != nil || !strings.Contains(output, "deployment.apps/web created") {
+		t.Fatalf("ExecuteCommand = %q, %v; want the deployment created", output, err)
+	}
+
+	deployments, err := executor.ListDeployments("-n", ns)
+	if err != nil {
+		t.Fatalf("ListDeployments failed: %v", err)
+	}
+	if len(deployments) != 1 || deployments[0].Name != "web" || deployments[0].Desired != 1 {
+		t.Errorf("ListDeployments = %+v, want web with 1 replica", deployments)
+	}
+
+	if output, err := executor.Scale("web", 2); err == nil {
+		t.Errorf("Scale without the namespace = %q, want an error from the default namespace", output)
+	}
+	executor.SetNamespace(ns)
+	if output, err := executor.Scale("web", 2); err != nil {
+		t.Fatalf("Scale failed: %v\n%s", err, output)
+	}
+	replicas := mustExecute(t, executor, "get", "deployment", "web", "-o", "jsonpath={.spec.replicas}")
+	if replicas != "2" {
+		t.Errorf("replicas after Scale = %q, want 2", replicas)
+	}
+
+	pods, err := executor.ListPods()
+	if err != nil {
+		t.Fatalf("ListPods failed: %v", err)
+	}
+	for _, pod := range pods {
+		if pod.Namespace != ns || len(pod.Containers) != 1 || pod.Containers[0] != "pause" {
+			t.Errorf("ListPods returned %+v, want pods of web in %s", pod, ns)
+		}
+	}
+}
+
+// TestExecutorPolicy checks that denied and read-only commands never reach
+// the cluster
+func TestExecutorPolicy(t *testing.T) {
+	executor := kubectl.NewExecutor(newCluster(t))
+	ns := newNamespace(t, executor)
+
+	executor.SetPolicy(config.Policy{Deny: []string{"delete namespace"}, Confirm: []string{"delete configmap"}})
+	_, err := executor.Execute("delete", "ns", ns)
+	var policyErr *kubectl.PolicyError
+	if !errors.As(err, &policyErr) || policyErr.NeedsConfirmation {
+		t.Fatalf("delete ns = %v, want a policy denial", err)
+	}
+	mustExecute(t, executor, "get", "namespace", ns)
+
+	mustExecute(t, executor, "create", "configmap", "settings", "-n", ns, "--from-literal=mode=test")
+	_, err = executor.Execute("delete", "configmap", "settings", "-n", ns)
+	if !errors.As(err, &policyErr) || !policyErr.NeedsConfirmation {
+		t.Fatalf("delete configmap = %v, want a confirmation request", err)
+	}
+	if output, err := executor.ExecuteConfirmed("delete", "configmap", "settings", "-n", ns); err != nil {
+		t.Fatalf("confirmed delete failed: %v\n%s", err, output)
+	}
+
+	executor.SetPolicy(config.Policy{ReadOnly: true})
+	if _, err := executor.ExecuteCommand("create configmap other -n " + ns); !errors.As(err, &policyErr) {
+		t.Fatalf("create in read-only mode = %v, want a policy denial", err)
+	}
+	if output := mustExecute(t, executor, "get", "configmaps", "-n", ns, "-o", "name"); strings.Contains(output, "configmap/other") {
+		t.Errorf("read-only mode created configmap/other")
+	}
+}
+
+// TestMaskSecrets checks that secret values read from the cluster are masked
+func TestMaskSecrets(t *testing.T) {
+	executor := kubectl.NewExecutor(newCluster(t))
+	ns := newNamespace(t, executor)
+
+	mustExecute(t, executor, "create", "secret", "generic", "db", "-n", ns, "--from-literal=password=hunter2")
+	mustExecute(t, executor, "create", "configmap", "db", "-n", ns, "--from-literal=host=db.internal")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("hunter2"))
+	output := mustExecute(t, executor, "get", "secret,configmap", "db", "-n", ns, "-o", "yaml")
+	if !strings.Contains(output, encoded) {
+		t.Fatalf("kubectl output doesn't contain the secret value:\n%s", output)
+	}
+
+	masked := kubectl.MaskSecrets(output)
+	if strings.Contains(masked, encoded) {
+		t.Errorf("MaskSecrets left the secret value:\n%s", masked)
+	}
+	if !strings.Contains(masked, kubectl.MaskPlaceholder) {
+		t.Errorf("MaskSecrets didn't mask anything:\n%s", masked)
+	}
+	if !strings.Contains(masked, "db.internal") {
+		t.Errorf("MaskSecrets masked configmap data:\n%s", masked)
+	}
+}
@@ -0,0 +1,130 @@
+//go:build integration
+
+package integration
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// kubeconfig is the kubeconfig of the cluster every test runs against
+var kubeconfig string
+
+// namespaces counts the namespaces created, to name them uniquely
+var namespaces int32
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+// run sets up the cluster, runs the tests and tears the cluster down
+func run(m *testing.M) int {
+	tools := []string{"kubectl", "git"}
+	kubeconfig = os.Getenv("INTEGRATION_KUBECONFIG")
+	if kubeconfig == "" {
+		tools = append(tools, "kind", "docker")
+	}
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			fmt.Printf("Skipping integration tests: %s not found\n", tool)
+			return 0
+		}
+	}
+
+	// Commits made by the git manager need an identity
+	for _, name := range []string{"GIT_AUTHOR", "GIT_COMMITTER"} {
+		os.Setenv(name+"_NAME", "Integration Tests")
+		os.Setenv(name+"_EMAIL", "integration@example.com")
+	}
+
+	if kubeconfig == "" {
+		dir, err := os.MkdirTemp("", "kube-orchestrator-it")
+		if err != nil {
+			fmt.Printf("Failed to create temp directory: %v\n", err)
+			return 1
+		}
+		defer os.RemoveAll(dir)
+
+		name := fmt.Sprintf("kube-orchestrator-it-%d", os.Getpid())
+		kubeconfig = filepath.Join(dir, "kubeconfig")
+		fmt.Printf("Creating kind cluster %s...\n", name)
+		create := exec.Command("kind", "create", "cluster", "--name", name, "--kubeconfig", kubeconfig, "--wait", "2m")
+		if output, err := create.CombinedOutput(); err != nil {
+			fmt.Printf("Failed to create kind cluster: %v\nOutput: %s\n", err, output)
+			return 1
+		}
+		if os.Getenv("KEEP_CLUSTER") == "" {
+			defer exec.Command("kind", "delete", "cluster", "--name", name).Run()
+		} else {
+			defer fmt.Printf("Keeping kind cluster %s, kubeconfig %s\n", name, kubeconfig)
+		}
+	}
+
+	return m.Run()
+}
+
+// newCluster returns cluster info for the test cluster, without a git repository
+func newCluster(t *testing.T) *config.ClusterInfo {
+	t.Helper()
+	return &config.ClusterInfo{
+		Name:       "integration",
+		ConfigPath: kubeconfig,
+	}
+}
+
+// newNamespace creates a namespace for one test and deletes it afterwards
+func newNamespace(t *testing.T, executor *kubectl.Executor) string {
+	t.Helper()
+	name := fmt.Sprintf("it-%d-%d", os.Getpid(), atomic.AddInt32(&namespaces, 1))
+	if output, err := executor.Execute("create", "namespace", name); err != nil {
+		t.Fatalf("Failed to create namespace %s: %v\n%s", name, err, output)
+	}
+	t.Cleanup(func() {
+		executor.Execute("delete", "namespace", name, "--wait=false")
+	})
+	return name
+}
+
+// mustExecute runs a kubectl command and fails the test if it fails
+func mustExecute(t *testing.T, executor *kubectl.Executor, args ...string) string {
+	t.Helper()
+	output, err := executor.Execute(args...)
+	if err != nil {
+		t.Fatalf("kubectl %s failed: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return output
+}
+
+// deploymentManifest is a deployment of the pause image, which needs no
+// port, probe or network access once pulled
+func deploymentManifest(name, namespace string, replicas int) string {
+	return fmt.Sprintf(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+  labels:
+    app: %[1]s
+spec:
+  replicas: %[3]d
+  selector:
+    matchLabels:
+      app: %[1]s
+  template:
+    metadata:
+      labels:
+        app: %[1]s
+    spec:
+      containers:
+      - name: pause
+        image: registry.k8s.io/pause:3.9
+`, name, namespace, replicas)
+}
@@ -0,0 +1,236 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RaymondAkachi/custom-kub-cli/k8s/clustersetup"
+)
+
+// Sources of audited operations
+const (
+	SourceKubectl      = "kubectl"
+	SourceGit          = "git"
+	SourcePlugin       = "plugin"
+	SourceShell        = "shell"
+	SourceClusterSetup = "clustersetup"
+)
+
+// Statuses of audited operations
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// Entry is a single audit log record
+type Entry struct {
+	Time     time.Time     `json:"time"`
+	User     string        `json:"user"`
+	Cluster  string        `json:"cluster"`
+	Source   string        `json:"source"`
+	Command  string        `json:"command"`
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns,omitempty"`
+}
+
+// Log is an append-only audit log stored as JSON lines
+type Log struct {
+	path string
+	user string
+	mu   sync.Mutex
+}
+
+// NewLog creates an audit log backed by the file at path
+func NewLog(path string) *Log {
+	return &Log{path: path, user: currentUser()}
+}
+
+// currentUser returns the name of the user running the application
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// Path returns the location of the audit log file
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Record appends an entry to the audit log
+func (l *Log) Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.User == "" {
+		entry.User = l.user
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %v", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %v", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %v", err)
+	}
+
+	return nil
+}
+
+// RecordResult appends an entry whose status is derived from err
+func (l *Log) RecordResult(cluster, source, command string, started time.Time, err error) error {
+	entry := Entry{
+		Time:     started,
+		Cluster:  cluster,
+		Source:   source,
+		Command:  command,
+		Status:   StatusSuccess,
+		Duration: time.Since(started),
+	}
+	if err != nil {
+		entry.Status = StatusFailure
+		entry.Error = err.Error()
+	}
+	return l.Record(entry)
+}
+
+// Read returns the last limit entries of the audit log, oldest first.
+// A limit of zero or less returns every entry.
+func (l *Log) Read(limit int) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse audit entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// Export writes every audit entry to dest as JSON or CSV, chosen by the
+// file extension (.csv for CSV, anything else for JSON)
+func (l *Log) Export(dest string) error {
+	entries, err := l.Read(0)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %v", err)
+	}
+	defer file.Close()
+
+	if strings.ToLower(filepath.Ext(dest)) != ".csv" {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			return fmt.Errorf("failed to write JSON export: %v", err)
+		}
+		return nil
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"time", "user", "cluster", "source", "command", "status", "error", "duration"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %v", err)
+	}
+	for _, entry := range entries {
+		record := []string{
+			entry.Time.Format(time.RFC3339),
+			entry.User,
+			entry.Cluster,
+			entry.Source,
+			entry.Command,
+			entry.Status,
+			entry.Error,
+			entry.Duration.String(),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record: %v", err)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ClusterSetupHandler returns a clustersetup event handler that records
+// setup results and failed remote commands in the audit log
+func (l *Log) ClusterSetupHandler() clustersetup.EventHandler {
+	return func(event clustersetup.Event) {
+		entry := Entry{
+			Time:    event.Time,
+			Cluster: event.Cluster,
+			Source:  SourceClusterSetup,
+		}
+		switch event.Type {
+		case clustersetup.EventSetupCompleted:
+			entry.Command = "setup"
+			entry.Status = StatusSuccess
+		case clustersetup.EventSetupFailed:
+			entry.Command = "setup"
+			entry.Status = StatusFailure
+		case clustersetup.EventCommandFailed:
+			entry.Command = fmt.Sprintf("%s: %s", event.Node, event.Command)
+			entry.Status = StatusFailure
+		default:
+			return
+		}
+		if event.Err != nil {
+			entry.Error = event.Err.Error()
+		}
+		l.Record(entry)
+	}
+}
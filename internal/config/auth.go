@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Kubeconfig authentication types
+const (
+	AuthCertificate = "certificate"
+	AuthToken       = "token"
+	AuthBasic       = "basic"
+	AuthExec        = "exec"
+	AuthOIDC        = "oidc"
+	AuthUnknown     = "unknown"
+)
+
+// AuthInfo holds the credentials of a kubeconfig user entry
+type AuthInfo struct {
+	ClientCertificate     string              `yaml:"client-certificate"`
+	ClientCertificateData string              `yaml:"client-certificate-data"`
+	Token                 string              `yaml:"token"`
+	TokenFile             string              `yaml:"tokenFile"`
+	Username              string              `yaml:"username"`
+	Exec                  *ExecConfig         `yaml:"exec"`
+	AuthProvider          *AuthProviderConfig `yaml:"auth-provider"`
+}
+
+// ExecConfig describes a client-go credential exec plugin
+type ExecConfig struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Command    string   `yaml:"command"`
+	Args       []string `yaml:"args"`
+	Env        []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"env"`
+}
+
+// AuthProviderConfig describes a legacy auth provider such as oidc
+type AuthProviderConfig struct {
+	Name   string            `yaml:"name"`
+	Config map[string]string `yaml:"config"`
+}
+
+// KubeAuth describes how the current context of a kubeconfig authenticates
+type KubeAuth struct {
+	Type string
+	User string
+	Info AuthInfo
+}
+
+// CurrentAuth returns the authentication used by the kubeconfig's current context
+func (kc *KubeConfig) CurrentAuth() (*KubeAuth, error) {
+	userName := ""
+	for _, ctx := range kc.Contexts {
+		if ctx.Name == kc.CurrentContext {
+			userName = ctx.Context.User
+			break
+		}
+	}
+	if userName == "" && kc.CurrentContext == "" && len(kc.Contexts) > 0 {
+		userName = kc.Contexts[0].Context.User
+	}
+	if userName == "" && len(kc.Users) == 1 {
+		userName = kc.Users[0].Name
+	}
+
+	for _, user := range kc.Users {
+		if user.Name == userName {
+			return &KubeAuth{Type: detectAuthType(user.User), User: user.Name, Info: user.User}, nil
+		}
+	}
+
+	if userName == "" {
+		return &KubeAuth{Type: AuthUnknown}, nil
+	}
+	return nil, fmt.Errorf("user '%s' referenced by the current context not found", userName)
+}
+
+// detectAuthType classifies a kubeconfig user entry
+func detectAuthType(info AuthInfo) string {
+	switch {
+	case info.Exec != nil:
+		return AuthExec
+	case info.AuthProvider != nil && info.AuthProvider.Name == "oidc":
+		return AuthOIDC
+	case info.ClientCertificate != "" || info.ClientCertificateData != "":
+		return AuthCertificate
+	case info.Token != "" || info.TokenFile != "":
+		return AuthToken
+	case info.Username != "":
+		return AuthBasic
+	}
+	return AuthUnknown
+}
+
+// Binary returns the executable the auth method depends on, if any
+func (a *KubeAuth) Binary() string {
+	if a.Type != AuthExec {
+		return ""
+	}
+	// kubelogin is usually installed as a kubectl plugin: kubectl oidc-login ...
+	if a.Info.Exec.Command == "kubectl" && len(a.Info.Exec.Args) > 0 && !strings.HasPrefix(a.Info.Exec.Args[0], "-") {
+		return "kubectl-" + strings.ReplaceAll(a.Info.Exec.Args[0], "-", "_")
+	}
+	return a.Info.Exec.Command
+}
+
+// Description returns a short human readable description of the auth method
+func (a *KubeAuth) Description() string {
+	switch a.Type {
+	case AuthExec:
+		return fmt.Sprintf("exec plugin (%s)", a.Binary())
+	case AuthOIDC:
+		return fmt.Sprintf("OIDC (%s)", a.Info.AuthProvider.Config["idp-issuer-url"])
+	}
+	return a.Type
+}
+
+// Validate checks that everything the auth method needs is available on this machine
+func (a *KubeAuth) Validate() error {
+	if a.Type != AuthExec {
+		return nil
+	}
+	binary := a.Binary()
+	if binary == "" {
+		return fmt.Errorf("kubeconfig exec auth plugin has no command")
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		return fmt.Errorf("kubeconfig uses the exec auth plugin '%s', which was not found in PATH. Install it before adding this cluster", binary)
+	}
+	return nil
+}
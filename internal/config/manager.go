@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -13,16 +14,44 @@ import (
 
 // ClusterInfo holds cluster information
 type ClusterInfo struct {
-	Name         string    `json:"name"`
-	ConfigPath   string    `json:"config_path"`
-	Server       string    `json:"server"`
-	PublicIP     string    `json:"public_ip"`
-	DNS          string    `json:"dns"`
-	CreatedAt    time.Time `json:"created_at"`
-	HasPrometheus bool     `json:"has_prometheus"`
-	HasArgoCD    bool     `json:"has_argocd"`
-	GitRepo      string   `json:"git_repo"`
-	GitRepoPath  string   `json:"git_repo_path"`
+	Name           string       `json:"name"`
+	ConfigPath     string       `json:"config_path"`
+	SourcePath     string       `json:"source_path,omitempty"` // kubeconfig the managed copy was made from
+	Server         string       `json:"server"`
+	PublicIP       string       `json:"public_ip"`
+	DNS            string       `json:"dns"`
+	CreatedAt      time.Time    `json:"created_at"`
+	HasPrometheus  bool         `json:"has_prometheus"`
+	HasArgoCD      bool         `json:"has_argocd"`
+	GitRepo        string       `json:"git_repo"`
+	GitRepoPath    string       `json:"git_repo_path"`
+	AuthType       string       `json:"auth_type,omitempty"`
+	Tags           []string     `json:"tags,omitempty"`
+	Namespace      string       `json:"namespace,omitempty"`       // default namespace for terminal commands
+	RequestTimeout string       `json:"request_timeout,omitempty"` // kubectl --request-timeout, e.g. "20s"
+	QPS            float32      `json:"qps,omitempty"`             // API request rate limit for the client-go backend
+	Burst          int          `json:"burst,omitempty"`           // API request burst for the client-go backend
+	ReadOnly       bool         `json:"read_only,omitempty"`       // block every modifying command on this cluster
+	GitTokenVault  *VaultSecret `json:"git_token_vault,omitempty"`
+	GitKeepStale   bool         `json:"git_keep_stale,omitempty"` // keep the exports of resource types the cluster no longer has
+}
+
+// Timeout returns the per-request timeout of the cluster, or 0 for kubectl's default
+func (c *ClusterInfo) Timeout() (time.Duration, error) {
+	if c.RequestTimeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(c.RequestTimeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid request timeout '%s': use a duration such as 20s", c.RequestTimeout)
+	}
+	return timeout, nil
+}
+
+// VaultSecret points at a field of a HashiCorp Vault secret
+type VaultSecret struct {
+	Path  string `json:"path"`
+	Field string `json:"field"`
 }
 
 // ClusterRegistry manages cluster configurations
@@ -42,41 +71,101 @@ type KubeConfig struct {
 		Name    string `yaml:"name"`
 		Context struct {
 			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
 		} `yaml:"context"`
 	} `yaml:"contexts"`
+	Users []struct {
+		Name string   `yaml:"name"`
+		User AuthInfo `yaml:"user"`
+	} `yaml:"users"`
 	CurrentContext string `yaml:"current-context"`
 }
 
 // Manager handles configuration management
 type Manager struct {
-	ConfigDir    string
-	RegistryPath string
-	Registry     *ClusterRegistry
+	Workspace       string
+	ConfigDir       string
+	SetupDir        string
+	SetupWorkDir    string
+	RegistryPath    string
+	PluginDir       string
+	TemplateDir     string
+	AuditLogPath    string
+	LogPath         string
+	PolicyPath      string
+	StatsPath       string
+	PreferencesPath string
+	CrashDir        string
+	LocaleDir       string
+	Registry        *ClusterRegistry
 }
 
-// Initialize creates and initializes the configuration manager
-func Initialize() (*Manager, error) {
-	homeDir, err := os.UserHomeDir()
+// Initialize creates and initializes the configuration manager for a
+// workspace. Plugins, templates, locales, preferences and the application log
+// are shared by all workspaces; clusters, setup configs, policies, the audit log
+// and usage statistics are not.
+func Initialize(workspace string) (*Manager, error) {
+	if err := ValidateWorkspaceName(workspace); err != nil {
+		return nil, err
+	}
+	baseDir, err := baseDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user home directory: %v", err)
+		return nil, err
 	}
-
-	configDir := filepath.Join(homeDir, ".kube-orchestrator", "configs")
-	registryPath := filepath.Join(homeDir, ".kube-orchestrator", "registry.json")
+	workspaceDir := WorkspaceDir(baseDir, workspace)
+
+	configDir := filepath.Join(workspaceDir, "configs")
+	setupDir := filepath.Join(workspaceDir, "cluster-configs")
+	// Certificates and state of the clusters set up from the configs
+	setupWorkDir := filepath.Join(workspaceDir, "cluster-work")
+	registryPath := filepath.Join(workspaceDir, "registry.json")
+	pluginDir := filepath.Join(baseDir, "plugins")
+	templateDir := filepath.Join(baseDir, "templates")
+	auditLogPath := filepath.Join(workspaceDir, "audit.log")
+	logPath := filepath.Join(baseDir, "orchestrator.log")
+	policyPath := filepath.Join(workspaceDir, "policies.json")
+	statsPath := filepath.Join(workspaceDir, "stats.jsonl")
+	preferencesPath := filepath.Join(baseDir, "preferences.json")
+	crashDir := filepath.Join(baseDir, "crashes")
+	localeDir := filepath.Join(baseDir, "locales")
 
 	// Create directories
 	if err := os.MkdirAll(configDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create config directory: %v", err)
 	}
 
+	if err := os.MkdirAll(setupDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cluster config directory: %v", err)
+	}
+
 	if err := os.MkdirAll(filepath.Dir(registryPath), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create registry directory: %v", err)
 	}
 
+	if err := os.MkdirAll(pluginDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create plugin directory: %v", err)
+	}
+
+	if err := os.MkdirAll(templateDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create template directory: %v", err)
+	}
+
 	manager := &Manager{
-		ConfigDir:    configDir,
-		RegistryPath: registryPath,
-		Registry:     &ClusterRegistry{},
+		Workspace:       workspace,
+		ConfigDir:       configDir,
+		SetupDir:        setupDir,
+		SetupWorkDir:    setupWorkDir,
+		RegistryPath:    registryPath,
+		PluginDir:       pluginDir,
+		TemplateDir:     templateDir,
+		AuditLogPath:    auditLogPath,
+		LogPath:         logPath,
+		PolicyPath:      policyPath,
+		StatsPath:       statsPath,
+		PreferencesPath: preferencesPath,
+		CrashDir:        crashDir,
+		LocaleDir:       localeDir,
+		Registry:        &ClusterRegistry{},
 	}
 
 	if err := manager.LoadRegistry(); err != nil {
@@ -86,6 +175,18 @@ func Initialize() (*Manager, error) {
 	return manager, nil
 }
 
+// ExpandPath resolves a user supplied local path: surrounding quotes added by
+// Windows "Copy as path" are removed and a leading ~ is expanded to the home directory
+func ExpandPath(path string) string {
+	path = strings.Trim(strings.TrimSpace(path), `"'`)
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, path[1:])
+		}
+	}
+	return filepath.Clean(path)
+}
+
 // LoadRegistry loads the cluster registry from disk
 func (m *Manager) LoadRegistry() error {
 	if _, err := os.Stat(m.RegistryPath); os.IsNotExist(err) {
@@ -177,6 +278,11 @@ func (m *Manager) GetAllClusters() []ClusterInfo {
 
 // ParseKubeConfig parses a kubeconfig file and extracts cluster information
 func (m *Manager) ParseKubeConfig(configPath string) (*KubeConfig, error) {
+	return LoadKubeConfig(configPath)
+}
+
+// LoadKubeConfig reads and parses a kubeconfig file
+func LoadKubeConfig(configPath string) (*KubeConfig, error) {
 	data, err := ioutil.ReadFile(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read kubeconfig file: %v", err)
@@ -190,10 +296,18 @@ func (m *Manager) ParseKubeConfig(configPath string) (*KubeConfig, error) {
 	return &config, nil
 }
 
+// PrimaryServer returns the API server of the first cluster in the kubeconfig
+func (kc *KubeConfig) PrimaryServer() string {
+	if len(kc.Clusters) == 0 {
+		return ""
+	}
+	return kc.Clusters[0].Cluster.Server
+}
+
 // CopyKubeConfig copies a kubeconfig file to the managed directory
 func (m *Manager) CopyKubeConfig(srcPath, clusterName string) (string, error) {
 	destPath := filepath.Join(m.ConfigDir, fmt.Sprintf("%s.yaml", clusterName))
-	
+
 	data, err := ioutil.ReadFile(srcPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to read source kubeconfig: %v", err)
@@ -221,10 +335,19 @@ func (m *Manager) ValidateClusterConfig(cluster *ClusterInfo) error {
 	}
 
 	// Validate kubeconfig format
-	_, err := m.ParseKubeConfig(cluster.ConfigPath)
+	kubeConfig, err := m.ParseKubeConfig(cluster.ConfigPath)
 	if err != nil {
 		return fmt.Errorf("invalid kubeconfig file: %v", err)
 	}
 
+	// Make sure exec auth plugins can be found before the first command fails
+	auth, err := kubeConfig.CurrentAuth()
+	if err != nil {
+		return fmt.Errorf("invalid kubeconfig file: %v", err)
+	}
+	if err := auth.Validate(); err != nil {
+		return err
+	}
+
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Policy describes the command restrictions applied to a cluster
+type Policy struct {
+	Deny     []string `json:"deny,omitempty"`      // Command patterns that are always rejected
+	Confirm  []string `json:"confirm,omitempty"`   // Command patterns that need explicit confirmation
+	ReadOnly bool     `json:"read_only,omitempty"` // Reject every modifying command
+}
+
+// PolicySet maps cluster tags to policy profiles
+type PolicySet struct {
+	Profiles map[string]Policy `json:"profiles"`
+}
+
+// LoadPolicies loads policy profiles from disk, returning an empty set if the file doesn't exist
+func LoadPolicies(path string) (*PolicySet, error) {
+	set := &PolicySet{Profiles: map[string]Policy{}}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return set, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %v", err)
+	}
+
+	if err := json.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %v", err)
+	}
+	if set.Profiles == nil {
+		set.Profiles = map[string]Policy{}
+	}
+
+	return set, nil
+}
+
+// ForTags merges the profiles matching any of the given tags into a single policy
+func (ps *PolicySet) ForTags(tags []string) Policy {
+	var merged Policy
+	for _, tag := range tags {
+		profile, ok := ps.Profiles[tag]
+		if !ok {
+			continue
+		}
+		merged.Deny = append(merged.Deny, profile.Deny...)
+		merged.Confirm = append(merged.Confirm, profile.Confirm...)
+		merged.ReadOnly = merged.ReadOnly || profile.ReadOnly
+	}
+	return merged
+}
+
+// PolicyForCluster returns the effective policy for a registered cluster
+func (m *Manager) PolicyForCluster(cluster *ClusterInfo) (Policy, error) {
+	set, err := LoadPolicies(m.PolicyPath)
+	if err != nil {
+		return Policy{}, err
+	}
+	return set.ForTags(cluster.Tags), nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Preferences are user choices shared by all workspaces
+type Preferences struct {
+	Stats  bool   `json:"stats"`            // record local usage statistics
+	Locale string `json:"locale,omitempty"` // language of the UI, empty for the system locale
+}
+
+// LoadPreferences reads the preferences, returning the defaults if none are saved
+func (m *Manager) LoadPreferences() (Preferences, error) {
+	var preferences Preferences
+	data, err := os.ReadFile(m.PreferencesPath)
+	if os.IsNotExist(err) {
+		return preferences, nil
+	}
+	if err != nil {
+		return preferences, fmt.Errorf("failed to read preferences: %v", err)
+	}
+	if err := json.Unmarshal(data, &preferences); err != nil {
+		return preferences, fmt.Errorf("failed to parse preferences: %v", err)
+	}
+	return preferences, nil
+}
+
+// SavePreferences writes the preferences to disk
+func (m *Manager) SavePreferences(preferences Preferences) error {
+	data, err := json.MarshalIndent(preferences, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal preferences: %v", err)
+	}
+	if err := os.WriteFile(m.PreferencesPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write preferences: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// fileState is what the kubeconfig watcher remembers about a file
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// KubeConfigChange reports a cluster whose kubeconfig changed on disk
+type KubeConfigChange struct {
+	Cluster    string
+	FromSource bool   // the original kubeconfig changed and was copied in again
+	Server     string // server after the change
+	AuthType   string // auth type after the change, if it could be detected
+	OldServer  string
+	Err        error
+}
+
+// KubeConfigWatcher detects kubeconfigs rotated outside the CLI, such as by
+// aws eks update-kubeconfig. Files are polled by modification time and size.
+type KubeConfigWatcher struct {
+	manager *Manager
+	seen    map[string]fileState
+}
+
+// NewKubeConfigWatcher creates a watcher for the kubeconfigs of registered clusters
+func NewKubeConfigWatcher(manager *Manager) *KubeConfigWatcher {
+	return &KubeConfigWatcher{manager: manager, seen: make(map[string]fileState)}
+}
+
+// changed reports whether a file differs from the last time it was checked.
+// Files seen for the first time are remembered without being reported.
+func (w *KubeConfigWatcher) changed(path string) bool {
+	var state fileState
+	if info, err := os.Stat(path); err == nil {
+		state = fileState{exists: true, modTime: info.ModTime(), size: info.Size()}
+	}
+	previous, known := w.seen[path]
+	w.seen[path] = state
+	return known && previous != state
+}
+
+// Check looks for changed kubeconfigs among clusters. When the kubeconfig a
+// cluster was added from changes, it is copied into the managed directory
+// again. Changed clusters get their server and auth type re-read into the
+// change, for Save to record. Check only touches files, so it can run in the
+// background on a copy of the registered clusters, one call at a time.
+func (w *KubeConfigWatcher) Check(clusters []ClusterInfo) []KubeConfigChange {
+	var changes []KubeConfigChange
+	for _, cluster := range clusters {
+		fromSource := cluster.SourcePath != "" && w.changed(cluster.SourcePath)
+		if !w.changed(cluster.ConfigPath) && !fromSource {
+			continue
+		}
+
+		change := KubeConfigChange{Cluster: cluster.Name, FromSource: fromSource, OldServer: cluster.Server}
+		if fromSource {
+			if _, err := os.Stat(cluster.SourcePath); err != nil {
+				// Keep using the managed copy when the original is removed
+				change.Err = fmt.Errorf("original kubeconfig %s is gone: %v", cluster.SourcePath, err)
+				changes = append(changes, change)
+				continue
+			}
+			if _, err := w.manager.CopyKubeConfig(cluster.SourcePath, cluster.Name); err != nil {
+				change.Err = err
+				changes = append(changes, change)
+				continue
+			}
+			// Don't report the copy as a second change
+			w.changed(cluster.ConfigPath)
+		}
+
+		kubeConfig, err := LoadKubeConfig(cluster.ConfigPath)
+		if err != nil {
+			change.Err = err
+			changes = append(changes, change)
+			continue
+		}
+		change.Server = kubeConfig.PrimaryServer()
+		if auth, err := kubeConfig.CurrentAuth(); err == nil {
+			change.AuthType = auth.Type
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// Save records the server and auth type a change re-read in the registry
+func (w *KubeConfigWatcher) Save(change KubeConfigChange) error {
+	cluster, err := w.manager.GetCluster(change.Cluster)
+	if err != nil {
+		return err
+	}
+	cluster.Server = change.Server
+	cluster.AuthType = change.AuthType // detected again on the next auth failure if empty
+	return w.manager.UpdateCluster(*cluster)
+}
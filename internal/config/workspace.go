@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// DefaultWorkspace is the workspace used when none is selected. It keeps its
+// files directly in ~/.kube-orchestrator, where they were before workspaces.
+const DefaultWorkspace = "default"
+
+// WorkspaceEnv selects the workspace at startup when no --workspace flag is given
+const WorkspaceEnv = "KUB_CLI_WORKSPACE"
+
+// workspaceNamePattern keeps workspace names usable as directory names
+var workspaceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// baseDir returns the directory holding all CLI state
+func baseDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %v", err)
+	}
+	return filepath.Join(homeDir, ".kube-orchestrator"), nil
+}
+
+// WorkspaceDir returns the directory of a workspace's clusters and settings
+func WorkspaceDir(baseDir, workspace string) string {
+	if workspace == DefaultWorkspace {
+		return baseDir
+	}
+	return filepath.Join(baseDir, "workspaces", workspace)
+}
+
+// ValidateWorkspaceName checks that a workspace name can be used as a directory name
+func ValidateWorkspaceName(name string) error {
+	if !workspaceNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid workspace name '%s': use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// ListWorkspaces returns the default workspace followed by the others in order
+func ListWorkspaces() ([]string, error) {
+	baseDir, err := baseDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(baseDir, "workspaces"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read workspaces: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != DefaultWorkspace && ValidateWorkspaceName(entry.Name()) == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultWorkspace}, names...), nil
+}
@@ -0,0 +1,73 @@
+// Package crash writes reports of panics to disk so they can be attached to
+// bug reports after the terminal has been restored.
+package crash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Report describes a panic and what the application was doing at the time
+type Report struct {
+	Time     time.Time
+	Value    interface{} // the value passed to panic
+	Stack    []byte      // stack of the panicking goroutine
+	Versions []string    // tool versions, one per line
+	Commands []string    // recently run commands, oldest first
+}
+
+// Write saves the report as a text file in dir and returns its path
+func Write(dir string, report Report) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create crash report directory: %v", err)
+	}
+	path := filepath.Join(dir, "crash-"+report.Time.Format("20060102-150405")+".txt")
+	if err := os.WriteFile(path, []byte(report.String()), 0600); err != nil {
+		return "", fmt.Errorf("failed to write crash report: %v", err)
+	}
+	return path, nil
+}
+
+// String formats the report as plain text
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "kub-cli crash report\n\n")
+	fmt.Fprintf(&b, "Time:  %s\n", r.Time.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Panic: %v\n", r.Value)
+
+	b.WriteString("\nVersions:\n")
+	for _, version := range append(BuildVersions(), r.Versions...) {
+		fmt.Fprintf(&b, "  %s\n", version)
+	}
+
+	b.WriteString("\nRecent commands:\n")
+	if len(r.Commands) == 0 {
+		b.WriteString("  (none)\n")
+	}
+	for _, command := range r.Commands {
+		fmt.Fprintf(&b, "  %s\n", command)
+	}
+
+	fmt.Fprintf(&b, "\nStack:\n%s", r.Stack)
+	return b.String()
+}
+
+// BuildVersions describes the running binary and the Go runtime
+func BuildVersions() []string {
+	versions := []string{fmt.Sprintf("go: %s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH)}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version := info.Main.Version
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				version += " (" + setting.Value + ")"
+			}
+		}
+		versions = append(versions, fmt.Sprintf("%s: %s", info.Main.Path, version))
+	}
+	return versions
+}
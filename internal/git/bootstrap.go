@@ -0,0 +1,165 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// BootstrapOptions selects the optional parts of a repository bootstrap
+type BootstrapOptions struct {
+	ArgoCD bool // add an ArgoCD Application syncing the cluster's directory
+}
+
+// argoCDDir holds the ArgoCD Applications of the clusters, outside their
+// directories so an Application doesn't manage itself
+const argoCDDir = "argocd"
+
+// repositoryReadme describes the layout bootstrap creates
+const repositoryReadme = `# Cluster Configurations
+
+This repository holds the resources of Kubernetes clusters managed with
+kube-orchestrator, one directory per cluster:
+
+    <cluster>/<resource>.yaml   resources exported from the cluster, one file
+                                per kind, e.g. deployments.yaml
+    argocd/<cluster>.yaml       ArgoCD Application syncing <cluster>/, when
+                                bootstrapped with ArgoCD
+
+kube-orchestrator re-exports the resources a command changes after every
+modifying command, then commits and pushes them. Exports are the output of
+kubectl get -o yaml as is, including status, server-set fields and the data
+of Secrets, so keep this repository private.
+
+The ArgoCD Applications sync manually. Apply one to the cluster's argocd
+namespace to let ArgoCD track the cluster's directory.
+`
+
+// argoCDApplication is the Application of a cluster's exports
+const argoCDApplication = `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: %[1]s
+  namespace: argocd
+spec:
+  project: default
+  source:
+    repoURL: %[2]s
+    targetRevision: %[3]s
+    path: %[1]s
+  destination:
+    server: https://kubernetes.default.svc
+`
+
+// NeedsBootstrap reports whether the repository holds nothing for the
+// cluster yet: it has no commits, or none with a directory for the cluster
+func (gm *Manager) NeedsBootstrap() bool {
+	cmd := exec.Command("git", "-C", gm.syncPath, "ls-tree", "--name-only", "HEAD", gm.cluster.Name)
+	output, err := cmd.CombinedOutput()
+	return err != nil || strings.TrimSpace(string(output)) == ""
+}
+
+// Bootstrap lays out the repository for the cluster in a single commit: the
+// cluster's directory with its first export, a README describing the layout
+// unless the repository has one, and optionally an ArgoCD Application. It
+// refuses repositories that already hold the cluster.
+func (gm *Manager) Bootstrap(opts BootstrapOptions) error {
+	unlock := gm.lockClone()
+	defer unlock()
+	err := gm.bootstrap(opts)
+	gm.recordSync(err)
+	return err
+}
+
+// bootstrap runs the steps of Bootstrap
+func (gm *Manager) bootstrap(opts BootstrapOptions) error {
+	if err := gm.pullLatest(); err != nil {
+		return fmt.Errorf("failed to pull latest changes: %v", err)
+	}
+	empty := !gm.hasCommits()
+	if !gm.NeedsBootstrap() {
+		return fmt.Errorf("repository already holds %s/; sync it instead", gm.cluster.Name)
+	}
+
+	exportErr := gm.ExportClusterResources()
+	var partial *ExportError
+	if exportErr != nil && !errors.As(exportErr, &partial) {
+		return fmt.Errorf("failed to export cluster resources: %v", exportErr)
+	}
+
+	readme := filepath.Join(gm.syncPath, "README.md")
+	if _, err := os.Stat(readme); os.IsNotExist(err) {
+		if err := ioutil.WriteFile(readme, []byte(repositoryReadme), 0644); err != nil {
+			return fmt.Errorf("failed to write README.md: %v", err)
+		}
+	}
+
+	// A repository without commits has no branch yet
+	branch := "main"
+	if empty {
+		if err := gm.runGitCommand("symbolic-ref", "HEAD", "refs/heads/"+branch); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if branch, err = gm.currentBranch(); err != nil {
+			return err
+		}
+	}
+
+	if opts.ArgoCD {
+		if err := os.MkdirAll(filepath.Join(gm.syncPath, argoCDDir), 0755); err != nil {
+			return fmt.Errorf("failed to create %s directory: %v", argoCDDir, err)
+		}
+		application := fmt.Sprintf(argoCDApplication, gm.cluster.Name, gm.cluster.GitRepo, branch)
+		path := filepath.Join(gm.syncPath, argoCDDir, gm.cluster.Name+".yaml")
+		if err := ioutil.WriteFile(path, []byte(application), 0644); err != nil {
+			return fmt.Errorf("failed to write the ArgoCD Application: %v", err)
+		}
+	}
+
+	if err := gm.runGitCommand("add", "."); err != nil {
+		return fmt.Errorf("failed to add changes: %v", err)
+	}
+	if err := gm.runGitCommand("commit", "-m", fmt.Sprintf("Bootstrap GitOps repository for %s", gm.cluster.Name)); err != nil {
+		return fmt.Errorf("failed to commit changes: %v", err)
+	}
+	// Set the upstream, which a new branch doesn't have, for later pushes
+	if err := gm.runGitCommand("push", "-u", "origin", branch); err != nil {
+		return gm.queuePush(fmt.Errorf("failed to push changes: %v", err))
+	}
+	gm.pushed()
+	if partial != nil {
+		return partial
+	}
+	return nil
+}
+
+// hasCommits reports whether the checked out branch has any commits
+func (gm *Manager) hasCommits() bool {
+	return exec.Command("git", "-C", gm.syncPath, "rev-parse", "--verify", "--quiet", "HEAD").Run() == nil
+}
+
+// remoteEmpty reports whether the remote repository has no branches yet
+func (gm *Manager) remoteEmpty() bool {
+	output, err := gm.gitCommand("-C", gm.syncPath, "ls-remote", "--heads", "origin").Output()
+	return err == nil && strings.TrimSpace(string(output)) == ""
+}
+
+// checkoutRemote checks out main, or else master, of the remote in a clone
+// made while the remote was still empty
+func (gm *Manager) checkoutRemote() error {
+	if err := gm.runGitCommand("fetch", "origin"); err != nil {
+		return fmt.Errorf("failed to fetch: %v", err)
+	}
+	for _, branch := range []string{"main", "master"} {
+		if gm.runGitCommand("checkout", "-B", branch, "origin/"+branch) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("remote has neither a main nor a master branch")
+}
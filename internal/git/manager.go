@@ -1,24 +1,49 @@
 package git
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
 	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+	"github.com/RaymondAkachi/custom-kub-cli/k8s/clustersetup"
 )
 
-// Manager handles Git operations for GitOps workflow
+// syncCheckoutDir is the separate clone syncs run in, kept inside the .git
+// directory of the repository path so it never shows up in the user's checkout
+const syncCheckoutDir = "kube-orchestrator-sync"
+
+// Manager handles Git operations for GitOps workflow. The repository path is
+// the user's checkout; exports, commits and pushes happen in a separate clone
+// so they never touch work in progress there.
 type Manager struct {
 	cluster     *config.ClusterInfo
 	repoPath    string
+	syncPath    string
 	clusterPath string
 	executor    *kubectl.Executor
+	token       string
+
+	mu          sync.Mutex
+	lastSync    time.Time
+	lastSyncErr error
+	pending     int
+	lastPushErr error
+	retrying    bool
+
+	// ctx ends the background pushes once the manager is closed
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewManager creates a new Git manager for a cluster
@@ -33,46 +58,106 @@ func NewManager(cluster *config.ClusterInfo, executor *kubectl.Executor) (*Manag
 		repoPath = filepath.Join(os.TempDir(), fmt.Sprintf("k8s-configs-%s", cluster.Name))
 	}
 
-	clusterPath := filepath.Join(repoPath, cluster.Name)
+	syncPath := filepath.Join(repoPath, ".git", syncCheckoutDir)
+	clusterPath := filepath.Join(syncPath, cluster.Name)
 
 	manager := &Manager{
 		cluster:     cluster,
 		repoPath:    repoPath,
+		syncPath:    syncPath,
 		clusterPath: clusterPath,
 		executor:    executor,
 	}
+	manager.ctx, manager.cancel = context.WithCancel(context.Background())
+
+	if cluster.GitTokenVault != nil {
+		token, err := readVaultToken(cluster.GitTokenVault)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read git token from vault: %v", err)
+		}
+		manager.token = token
+	}
 
 	return manager, nil
 }
 
-// Initialize sets up the Git repository (clone if needed)
+// Close stops retrying queued pushes in the background. The commits stay in
+// the sync clone and the next manager of the cluster pushes them.
+func (gm *Manager) Close() {
+	gm.cancel()
+}
+
+// readVaultToken reads a git access token from Vault using VAULT_ADDR and VAULT_TOKEN
+func readVaultToken(secret *config.VaultSecret) (string, error) {
+	vault, err := clustersetup.NewVaultClient(clustersetup.VaultConfig{})
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return vault.ReadField(ctx, clustersetup.VaultSecretRef{Path: secret.Path, Field: secret.Field})
+}
+
+// gitCommand creates a git command that authenticates with the Vault token if one is set.
+// The token is passed through the environment so it never appears in arguments or errors.
+func (gm *Manager) gitCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	if gm.token != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + gm.token))
+		cmd.Env = append(os.Environ(),
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+credentials,
+		)
+	}
+	return cmd
+}
+
+// Initialize sets up the Git repository (clone if needed) and the clone syncs
+// run in. An existing checkout at the repository path is left as it is, since
+// the user may be working in it.
 func (gm *Manager) Initialize() error {
-	// Check if repository already exists
-	if _, err := os.Stat(filepath.Join(gm.repoPath, ".git")); err == nil {
-		// Repository exists, just pull latest changes
-		return gm.pullLatest()
+	if _, err := os.Stat(filepath.Join(gm.repoPath, ".git")); err != nil {
+		if err := gm.cloneRepository(gm.repoPath); err != nil {
+			return err
+		}
 	}
 
-	// Clone the repository
-	return gm.cloneRepository()
+	// Sync clone exists, just pull latest changes and push what an earlier
+	// session left queued
+	if _, err := os.Stat(filepath.Join(gm.syncPath, ".git")); err == nil {
+		unlock := gm.lockClone()
+		defer unlock()
+		if err := gm.refresh(); err != nil {
+			return err
+		}
+		if pending := gm.countPending(); pending > 0 {
+			gm.mu.Lock()
+			gm.pending = pending
+			gm.mu.Unlock()
+			gm.retryPushes()
+		}
+		return nil
+	}
+	return gm.cloneRepository(gm.syncPath)
 }
 
-// cloneRepository clones the Git repository
-func (gm *Manager) cloneRepository() error {
+// cloneRepository clones the Git repository into path
+func (gm *Manager) cloneRepository(path string) error {
 	// Remove existing directory if it exists but is not a git repo
-	if _, err := os.Stat(gm.repoPath); err == nil {
-		if err := os.RemoveAll(gm.repoPath); err != nil {
+	if _, err := os.Stat(path); err == nil {
+		if err := os.RemoveAll(path); err != nil {
 			return fmt.Errorf("failed to remove existing directory: %v", err)
 		}
 	}
 
 	// Create parent directory
-	if err := os.MkdirAll(filepath.Dir(gm.repoPath), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return fmt.Errorf("failed to create parent directory: %v", err)
 	}
 
 	// Clone repository
-	cmd := exec.Command("git", "clone", gm.cluster.GitRepo, gm.repoPath)
+	cmd := gm.gitCommand("clone", gm.cluster.GitRepo, path)
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("failed to clone repository: %v\nOutput: %s", err, string(output))
 	}
@@ -82,11 +167,24 @@ func (gm *Manager) cloneRepository() error {
 
 // pullLatest pulls the latest changes from the remote repository
 func (gm *Manager) pullLatest() error {
-	cmd := exec.Command("git", "-C", gm.repoPath, "pull", "origin", "main")
+	// A clone of an empty repository has no branch to pull into
+	if !gm.hasCommits() {
+		if gm.remoteEmpty() {
+			return nil
+		}
+		return gm.checkoutRemote()
+	}
+
+	// A pull that conflicts is abandoned rather than left half merged, so
+	// queued commits are never committed over or pushed with the conflicts.
+	// Merging even where pull.rebase is set keeps that to one abort.
+	cmd := gm.gitCommand("-C", gm.syncPath, "pull", "--no-rebase", "origin", "main")
 	if output, err := cmd.CombinedOutput(); err != nil {
+		gm.abortMerge()
 		// Try master branch if main fails
-		cmd = exec.Command("git", "-C", gm.repoPath, "pull", "origin", "master")
+		cmd = gm.gitCommand("-C", gm.syncPath, "pull", "--no-rebase", "origin", "master")
 		if output2, err2 := cmd.CombinedOutput(); err2 != nil {
+			gm.abortMerge()
 			return fmt.Errorf("failed to pull from both main and master branches:\nMain: %v (%s)\nMaster: %v (%s)", 
 				err, string(output), err2, string(output2))
 		}
@@ -95,66 +193,180 @@ func (gm *Manager) pullLatest() error {
 	return nil
 }
 
-// ExportClusterResources exports current cluster resources to the Git repository
+// exportWorkers bounds how many resource types are exported concurrently
+const exportWorkers = 4
+
+// exportQPS limits export requests to the API server per second
+const exportQPS = 5
+
+// ExportError reports resource types that failed to export. The others were
+// exported, and the failed ones keep their last export.
+type ExportError struct {
+	Failed map[string]error
+}
+
+func (e *ExportError) Error() string {
+	resources := make([]string, 0, len(e.Failed))
+	for resource := range e.Failed {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	failures := make([]string, len(resources))
+	for i, resource := range resources {
+		failures[i] = fmt.Sprintf("%s: %v", resource, e.Failed[resource])
+	}
+	return fmt.Sprintf("%d resource type(s) failed to export: %s", len(resources), strings.Join(failures, "; "))
+}
+
+// ExportClusterResources exports current cluster resources to the Git repository.
+// When only some resource types fail the rest are still exported and an
+// *ExportError lists the failures.
 func (gm *Manager) ExportClusterResources() error {
 	// Ensure cluster directory exists
 	if err := os.MkdirAll(gm.clusterPath, 0755); err != nil {
 		return fmt.Errorf("failed to create cluster directory: %v", err)
 	}
 
-	// Export each resource type
-	resources := kubectl.GetResourcesForExport()
+	// Export each resource type the cluster serves
+	resources := gm.availableResources(kubectl.GetResourcesForExport())
+
+	limiter := time.NewTicker(time.Second / exportQPS)
+	defer limiter.Stop()
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
 	exportedCount := 0
+	failed := make(map[string]error)
+
+	for i := 0; i < exportWorkers && i < len(resources); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for resource := range jobs {
+				<-limiter.C
+				err := gm.exportResource(resource)
+				// Record the failure but continue with other resources
+				mu.Lock()
+				if err != nil {
+					failed[resource] = err
+				} else {
+					exportedCount++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
 
 	for _, resource := range resources {
-		if err := gm.exportResource(resource); err != nil {
-			// Log warning but continue with other resources
-			fmt.Printf("Warning: Failed to export %s: %v\n", resource, err)
-			continue
-		}
-		exportedCount++
+		jobs <- resource
 	}
+	close(jobs)
+	wg.Wait()
 
 	if exportedCount == 0 {
+		if len(failed) > 0 {
+			return fmt.Errorf("no resources were successfully exported: %v", &ExportError{Failed: failed})
+		}
 		return fmt.Errorf("no resources were successfully exported")
 	}
 
+	if err := gm.pruneUnserved(resources); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return &ExportError{Failed: failed}
+	}
 	return nil
 }
 
-// exportResource exports a specific resource type
+// availableResources filters resource types down to those the cluster serves, using
+// API discovery. If discovery fails every resource type is returned.
+func (gm *Manager) availableResources(resources []string) []string {
+	output, err := gm.executor.Execute("api-resources", "-o", "name")
+	if err != nil {
+		return resources
+	}
+
+	served := make(map[string]bool)
+	for _, name := range strings.Fields(output) {
+		// Names are plural.group, e.g. deployments.apps
+		served[strings.ToLower(strings.SplitN(name, ".", 2)[0])] = true
+	}
+
+	var available []string
+	for _, resource := range resources {
+		if served[strings.ToLower(resource)] {
+			available = append(available, resource)
+		}
+	}
+	return available
+}
+
+// emptyListSize bounds the size of an export that may be an empty List; only
+// exports this small are read back to check
+const emptyListSize = 4096
+
+// exportResource exports a specific resource type. The output is streamed into
+// a temporary file that replaces the export once complete, so memory use stays
+// flat however many objects there are.
 func (gm *Manager) exportResource(resourceType string) error {
-	output, err := gm.executor.Execute("get", resourceType, "--all-namespaces", "-o", "yaml")
+	// Inside .git the temporary file is never picked up by git add
+	tmp, err := ioutil.TempFile(filepath.Join(gm.syncPath, ".git"), "export-"+resourceType+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary %s file: %v", resourceType, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	stderr, err := gm.executor.ExecuteTo(tmp, "get", resourceType, "--all-namespaces", "-o", "yaml")
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
 	if err != nil {
 		return fmt.Errorf("failed to get %s: %v", resourceType, err)
 	}
 
+	// Objects that were all deleted leave no export behind
+	noResources := strings.Contains(stderr, "No resources found")
+	if noResources || emptyExport(tmp.Name()) {
+		if pruned, err := gm.pruneExport(resourceType); pruned || err != nil {
+			return err
+		}
+	}
+
 	// Skip if no resources found
-	if strings.Contains(output, "No resources found") {
+	if noResources {
 		return nil
 	}
 
-	// Write to file
 	resourceFile := filepath.Join(gm.clusterPath, fmt.Sprintf("%s.yaml", resourceType))
-	if err := ioutil.WriteFile(resourceFile, []byte(output), 0644); err != nil {
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s file: %v", resourceType, err)
+	}
+	if err := os.Rename(tmp.Name(), resourceFile); err != nil {
 		return fmt.Errorf("failed to write %s file: %v", resourceType, err)
 	}
 
 	return nil
 }
 
-// CommitAndPush commits changes and pushes to the remote repository
-func (gm *Manager) CommitAndPush(message string) error {
-	// Change to repository directory
-	oldDir, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %v", err)
+// emptyExport reports whether an exported file is a List without items
+func emptyExport(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > emptyListSize {
+		return false
 	}
-	defer os.Chdir(oldDir)
+	data, err := ioutil.ReadFile(path)
+	return err == nil && emptyList(string(data))
+}
 
-	if err := os.Chdir(gm.repoPath); err != nil {
-		return fmt.Errorf("failed to change to repository directory: %v", err)
-	}
+// CommitAndPush commits changes and pushes to the remote repository. When
+// the push fails the commit stays queued and a *PushError is returned.
+// Git runs with its directory set to the sync clone, since the process's
+// working directory is shared with syncs of other clusters running in parallel.
+func (gm *Manager) CommitAndPush(message string) error {
+	// A pull interrupted mid-merge leaves conflicts that must not be committed
+	gm.abortMerge()
 
 	// Add all changes
 	if err := gm.runGitCommand("add", "."); err != nil {
@@ -163,6 +375,7 @@ func (gm *Manager) CommitAndPush(message string) error {
 
 	// Check if there are changes to commit
 	cmd := exec.Command("git", "diff", "--cached", "--quiet")
+	cmd.Dir = gm.syncPath
 	if err := cmd.Run(); err == nil {
 		// No changes to commit
 		return nil
@@ -178,18 +391,19 @@ func (gm *Manager) CommitAndPush(message string) error {
 		return fmt.Errorf("failed to commit changes: %v", err)
 	}
 
-	// Push changes
+	// Push changes, queueing them if the remote can't be reached
 	if err := gm.runGitCommand("push"); err != nil {
-		return fmt.Errorf("failed to push changes: %v", err)
+		return gm.queuePush(fmt.Errorf("failed to push changes: %v", err))
 	}
+	gm.pushed()
 
 	return nil
 }
 
 // runGitCommand runs a git command in the repository directory
 func (gm *Manager) runGitCommand(args ...string) error {
-	cmd := exec.Command("git", args...)
-	cmd.Dir = gm.repoPath
+	cmd := gm.gitCommand(args...)
+	cmd.Dir = gm.syncPath
 	
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("git %s failed: %v\nOutput: %s", 
@@ -201,19 +415,73 @@ func (gm *Manager) runGitCommand(args ...string) error {
 
 // SyncChanges performs a complete sync operation (export + commit + push)
 func (gm *Manager) SyncChanges(commitMessage string) error {
-	// Pull latest changes first
-	if err := gm.pullLatest(); err != nil {
+	unlock := gm.lockClone()
+	defer unlock()
+	err := gm.syncChanges(commitMessage)
+	gm.recordSync(err)
+	return err
+}
+
+// syncChanges runs the sync steps for SyncChanges
+func (gm *Manager) syncChanges(commitMessage string) error {
+	// Pull latest changes first; offline, the commit is queued locally
+	if err := gm.refresh(); err != nil {
 		return fmt.Errorf("failed to pull latest changes: %v", err)
 	}
 
-	// Export current cluster resources
-	if err := gm.ExportClusterResources(); err != nil {
-		return fmt.Errorf("failed to export cluster resources: %v", err)
+	// Export current cluster resources. What exported is committed even when
+	// some resource types failed, which are reported after.
+	exportErr := gm.ExportClusterResources()
+	var partial *ExportError
+	if exportErr != nil && !errors.As(exportErr, &partial) {
+		return fmt.Errorf("failed to export cluster resources: %v", exportErr)
 	}
 
 	// Commit and push changes
 	if err := gm.CommitAndPush(commitMessage); err != nil {
-		return fmt.Errorf("failed to commit and push changes: %v", err)
+		return fmt.Errorf("failed to commit and push changes: %w", err)
+	}
+
+	if partial != nil {
+		return partial
+	}
+	return nil
+}
+
+// SyncResources exports only the resources in scope, then commits and pushes.
+// It is much faster than SyncChanges after commands that touch a single kind.
+func (gm *Manager) SyncResources(scope kubectl.ResourceScope, commitMessage string) error {
+	unlock := gm.lockClone()
+	defer unlock()
+	err := gm.syncResources(scope, commitMessage)
+	gm.recordSync(err)
+	return err
+}
+
+// syncResources runs the sync steps for SyncResources
+func (gm *Manager) syncResources(scope kubectl.ResourceScope, commitMessage string) error {
+	if err := gm.refresh(); err != nil {
+		return fmt.Errorf("failed to pull latest changes: %v", err)
+	}
+
+	if err := os.MkdirAll(gm.clusterPath, 0755); err != nil {
+		return fmt.Errorf("failed to create cluster directory: %v", err)
+	}
+
+	for _, resource := range scope.Resources {
+		var err error
+		if scope.Namespace != "" && !kubectl.IsClusterScoped(resource) {
+			err = gm.exportNamespacedResource(resource, scope.Namespace)
+		} else {
+			err = gm.exportResource(resource)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %v", resource, err)
+		}
+	}
+
+	if err := gm.CommitAndPush(commitMessage); err != nil {
+		return fmt.Errorf("failed to commit and push changes: %w", err)
 	}
 
 	return nil
@@ -221,7 +489,7 @@ func (gm *Manager) SyncChanges(commitMessage string) error {
 
 // GetRepositoryStatus returns the current Git repository status
 func (gm *Manager) GetRepositoryStatus() (string, error) {
-	cmd := exec.Command("git", "-C", gm.repoPath, "status", "--porcelain")
+	cmd := exec.Command("git", "-C", gm.syncPath, "status", "--porcelain")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("failed to get repository status: %v", err)
@@ -232,7 +500,7 @@ func (gm *Manager) GetRepositoryStatus() (string, error) {
 
 // GetLastCommit returns information about the last commit
 func (gm *Manager) GetLastCommit() (string, error) {
-	cmd := exec.Command("git", "-C", gm.repoPath, "log", "-1", "--pretty=format:%h - %s (%cr) <%an>")
+	cmd := exec.Command("git", "-C", gm.syncPath, "log", "-1", "--pretty=format:%h - %s (%cr) <%an>")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf("failed to get last commit: %v", err)
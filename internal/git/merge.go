@@ -0,0 +1,270 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exportNamespacedResource re-exports one namespace of a resource type and merges it
+// into the existing all-namespaces export, leaving other namespaces untouched. Both
+// exports are streamed through temporary files, an item at a time, so neither has to
+// fit in memory.
+func (gm *Manager) exportNamespacedResource(resourceType, namespace string) error {
+	resourceFile := filepath.Join(gm.clusterPath, fmt.Sprintf("%s.yaml", resourceType))
+	existing, err := os.Open(resourceFile)
+	if os.IsNotExist(err) {
+		// Nothing to merge into yet, export every namespace
+		return gm.exportResource(resourceType)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s file: %v", resourceType, err)
+	}
+	defer existing.Close()
+
+	// Inside .git the temporary files are never picked up by git add
+	update, err := ioutil.TempFile(filepath.Join(gm.syncPath, ".git"), "export-"+resourceType+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary %s file: %v", resourceType, err)
+	}
+	defer os.Remove(update.Name())
+	defer update.Close()
+
+	if _, err := gm.executor.ExecuteTo(update, "get", resourceType, "-n", namespace, "-o", "yaml"); err != nil {
+		return fmt.Errorf("failed to get %s in %s: %v", resourceType, namespace, err)
+	}
+	if _, err := update.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read %s in %s: %v", resourceType, namespace, err)
+	}
+
+	merged, err := ioutil.TempFile(filepath.Join(gm.syncPath, ".git"), "merge-"+resourceType+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary %s file: %v", resourceType, err)
+	}
+	defer os.Remove(merged.Name())
+
+	items, ok, err := mergeNamespaceItems(merged, existing, update, namespace)
+	if closeErr := merged.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to merge %s in %s: %v", resourceType, namespace, err)
+	}
+	if !ok {
+		return gm.exportResource(resourceType)
+	}
+	if items == 0 {
+		if pruned, err := gm.pruneExport(resourceType); pruned || err != nil {
+			return err
+		}
+	}
+
+	if err := os.Chmod(merged.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s file: %v", resourceType, err)
+	}
+	if err := os.Rename(merged.Name(), resourceFile); err != nil {
+		return fmt.Errorf("failed to write %s file: %v", resourceType, err)
+	}
+	return nil
+}
+
+// listScanner reads kubectl -o yaml List output an item at a time: the lines
+// before the items with Head, each item with Next, and the lines after with Tail
+type listScanner struct {
+	reader  *bufio.Reader
+	line    string // a line read ahead, when pending is set
+	pending bool
+	empty   bool // the List was written as "items: []"
+	err     error
+}
+
+func newListScanner(r io.Reader) *listScanner {
+	return &listScanner{reader: bufio.NewReader(r)}
+}
+
+// readLine returns the next line without its newline, or false at the end
+// of the input or on a read error, which is kept in s.err
+func (s *listScanner) readLine() (string, bool) {
+	if s.pending {
+		s.pending = false
+		return s.line, true
+	}
+	line, err := s.reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		s.err = err
+		return "", false
+	}
+	if err == io.EOF && line == "" {
+		return "", false
+	}
+	return strings.TrimSuffix(line, "\n"), true
+}
+
+// unread makes line the next one readLine returns
+func (s *listScanner) unread(line string) {
+	s.line, s.pending = line, true
+}
+
+// Head returns the lines before the items. It returns false if the
+// document isn't a List.
+func (s *listScanner) Head() ([]string, bool, error) {
+	var head []string
+	for {
+		line, ok := s.readLine()
+		if !ok {
+			return head, false, s.err
+		}
+		switch line {
+		case "items: []":
+			s.empty = true
+			return head, true, nil
+		case "items:":
+			return head, true, nil
+		}
+		head = append(head, line)
+	}
+}
+
+// Next returns the next item, or false after the last one
+func (s *listScanner) Next() (string, bool, error) {
+	if s.empty {
+		return "", false, nil
+	}
+	line, ok := s.readLine()
+	if !ok || !strings.HasPrefix(line, "- ") {
+		if ok {
+			s.unread(line)
+		}
+		return "", false, s.err
+	}
+	item := []string{line}
+	for {
+		line, ok := s.readLine()
+		if !ok {
+			break
+		}
+		if !strings.HasPrefix(line, "  ") && line != "" {
+			s.unread(line)
+			break
+		}
+		item = append(item, line)
+	}
+	if s.err != nil {
+		return "", false, s.err
+	}
+	// Blank lines at the end of the document aren't part of the item
+	for len(item) > 1 && item[len(item)-1] == "" {
+		item = item[:len(item)-1]
+	}
+	return strings.Join(item, "\n"), true, nil
+}
+
+// Tail returns the lines after the items
+func (s *listScanner) Tail() ([]string, error) {
+	for {
+		if _, ok, err := s.Next(); !ok || err != nil {
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	var tail []string
+	for {
+		line, ok := s.readLine()
+		if !ok {
+			break
+		}
+		tail = append(tail, line)
+	}
+	for len(tail) > 0 && tail[len(tail)-1] == "" {
+		tail = tail[:len(tail)-1]
+	}
+	return tail, s.err
+}
+
+// itemNamespace returns metadata.namespace of a List item
+func itemNamespace(item string) string {
+	inMetadata := false
+	for _, line := range strings.Split(item, "\n") {
+		if strings.HasPrefix(line, "- ") {
+			line = "  " + line[2:]
+		}
+		if strings.HasPrefix(line, "  ") && !strings.HasPrefix(line, "   ") {
+			inMetadata = strings.TrimSpace(line) == "metadata:"
+			continue
+		}
+		if inMetadata && strings.HasPrefix(line, "    namespace: ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "    namespace: ")), `"'`)
+		}
+	}
+	return ""
+}
+
+// mergeNamespaceItems writes the existing List to w with the items of one
+// namespace replaced by the items of a namespaced export, in kubectl's YAML
+// layout. It returns how many items were written, and false if either
+// document isn't a List.
+func mergeNamespaceItems(w io.Writer, existing, update io.Reader, namespace string) (int, bool, error) {
+	current, fresh := newListScanner(existing), newListScanner(update)
+	head, ok, err := current.Head()
+	if !ok || err != nil {
+		return 0, false, err
+	}
+	// The export of one namespace only contributes its items
+	if _, ok, err := fresh.Head(); !ok || err != nil {
+		return 0, false, err
+	}
+
+	out := bufio.NewWriter(w)
+	for _, line := range head {
+		fmt.Fprintln(out, line)
+	}
+	items := 0
+	write := func(item string) {
+		if items == 0 {
+			fmt.Fprintln(out, "items:")
+		}
+		fmt.Fprintln(out, item)
+		items++
+	}
+
+	for {
+		item, ok, err := current.Next()
+		if err != nil {
+			return 0, false, err
+		}
+		if !ok {
+			break
+		}
+		if itemNamespace(item) != namespace {
+			write(item)
+		}
+	}
+	tail, err := current.Tail()
+	if err != nil {
+		return 0, false, err
+	}
+	for {
+		item, ok, err := fresh.Next()
+		if err != nil {
+			return 0, false, err
+		}
+		if !ok {
+			break
+		}
+		write(item)
+	}
+
+	if items == 0 {
+		fmt.Fprintln(out, "items: []")
+	}
+	for _, line := range tail {
+		fmt.Fprintln(out, line)
+	}
+	return items, true, out.Flush()
+}
@@ -0,0 +1,160 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backoff between attempts to push queued commits
+const (
+	pushRetryMin = 5 * time.Second
+	pushRetryMax = 5 * time.Minute
+)
+
+// cloneLocks serializes git operations on a sync clone, by path, across the
+// managers of a cluster and their background pushes
+var cloneLocks sync.Map
+
+// PushError reports a sync whose commit was made but couldn't be pushed. The
+// commits stay queued in the sync clone and are pushed in the background.
+type PushError struct {
+	Pending int
+	Err     error
+}
+
+func (e *PushError) Error() string {
+	return fmt.Sprintf("%d commit(s) pending push: %v", e.Pending, e.Err)
+}
+
+func (e *PushError) Unwrap() error {
+	return e.Err
+}
+
+// lockClone locks the sync clone and returns the function that unlocks it
+func (gm *Manager) lockClone() func() {
+	lock, _ := cloneLocks.LoadOrStore(gm.syncPath, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// refresh pulls the latest changes into the sync clone. An unreachable remote
+// isn't an error once the clone has commits: the sync commits locally and
+// the push is queued until the remote is back.
+func (gm *Manager) refresh() error {
+	if gm.hasCommits() && gm.runGitCommand("fetch", "origin") != nil {
+		return nil
+	}
+	return gm.pullLatest()
+}
+
+// abortMerge abandons a merge a failed pull left in progress in the sync
+// clone, restoring the tree of its last commit
+func (gm *Manager) abortMerge() {
+	cmd := exec.Command("git", "-C", gm.syncPath, "rev-parse", "-q", "--verify", "MERGE_HEAD")
+	if cmd.Run() != nil {
+		return
+	}
+	gm.runGitCommand("merge", "--abort")
+}
+
+// PendingPushes returns how many commits of the sync clone wait to be pushed
+func (gm *Manager) PendingPushes() int {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	return gm.pending
+}
+
+// countPending counts the commits of the sync clone on no remote branch
+func (gm *Manager) countPending() int {
+	cmd := exec.Command("git", "-C", gm.syncPath, "rev-list", "--count", "HEAD", "--not", "--remotes=origin")
+	output, err := cmd.Output()
+	if err != nil {
+		return 0
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(string(output)))
+	return count
+}
+
+// queuePush records the commits a failed push left behind and retries them
+// in the background
+func (gm *Manager) queuePush(err error) error {
+	pending := gm.countPending()
+	gm.mu.Lock()
+	gm.pending = pending
+	gm.lastPushErr = err
+	gm.mu.Unlock()
+	gm.retryPushes()
+	return &PushError{Pending: pending, Err: err}
+}
+
+// pushed records that the sync clone has nothing left to push
+func (gm *Manager) pushed() {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.pending = 0
+	gm.lastPushErr = nil
+}
+
+// retryPushes pushes the queued commits in the background until none are
+// left or the manager is closed, waiting twice as long after each failed
+// attempt
+func (gm *Manager) retryPushes() {
+	gm.mu.Lock()
+	if gm.retrying {
+		gm.mu.Unlock()
+		return
+	}
+	gm.retrying = true
+	gm.mu.Unlock()
+
+	go func() {
+		delay := pushRetryMin
+		defer func() {
+			gm.mu.Lock()
+			gm.retrying = false
+			gm.mu.Unlock()
+		}()
+		for {
+			select {
+			case <-gm.ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			if gm.pushPending() {
+				return
+			}
+			delay *= 2
+			if delay > pushRetryMax {
+				delay = pushRetryMax
+			}
+		}
+	}()
+}
+
+// pushPending pulls and pushes the queued commits. It reports whether none
+// are left.
+func (gm *Manager) pushPending() bool {
+	unlock := gm.lockClone()
+	defer unlock()
+
+	if gm.countPending() == 0 {
+		gm.pushed()
+		return true
+	}
+	err := gm.pullLatest()
+	if err == nil {
+		// Set the upstream too, which a bootstrapped branch may lack
+		err = gm.runGitCommand("push", "-u", "origin", "HEAD")
+	}
+	pending := gm.countPending()
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.pending = pending
+	gm.lastPushErr = err
+	return err == nil && pending == 0
+}
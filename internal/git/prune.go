@@ -0,0 +1,53 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// pruneExport removes the export of a resource type the cluster has no
+// objects of anymore, unless the cluster keeps stale exports. It reports
+// whether the file is gone; the removal is committed by the next git add.
+func (gm *Manager) pruneExport(resourceType string) (bool, error) {
+	if gm.cluster.GitKeepStale {
+		return false, nil
+	}
+	resourceFile := filepath.Join(gm.clusterPath, fmt.Sprintf("%s.yaml", resourceType))
+	if err := os.Remove(resourceFile); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to remove stale %s file: %v", resourceType, err)
+	}
+	return true, nil
+}
+
+// pruneUnserved removes the exports of resource types the cluster no longer
+// serves, such as those of a deleted CRD. Only the types the manager exports
+// are considered, so other files in the cluster's directory are left alone.
+func (gm *Manager) pruneUnserved(served []string) error {
+	exported := make(map[string]bool)
+	for _, resource := range served {
+		exported[resource] = true
+	}
+	for _, resource := range kubectl.GetResourcesForExport() {
+		if exported[resource] {
+			continue
+		}
+		if _, err := gm.pruneExport(resource); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emptyList reports whether kubectl -o yaml output is a List without items
+func emptyList(output string) bool {
+	list := newListScanner(strings.NewReader(output))
+	if _, ok, err := list.Head(); !ok || err != nil {
+		return false
+	}
+	_, ok, err := list.Next()
+	return !ok && err == nil
+}
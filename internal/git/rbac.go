@@ -0,0 +1,80 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// RBACChange is how the last commit that touched a cluster's RBAC exports
+// changed its grants
+type RBACChange struct {
+	Hash   string
+	Commit string // short hash and subject
+	Diff   kubectl.RBACDiff
+}
+
+// rbacFiles returns the paths of the RBAC exports within the repository
+func (gm *Manager) rbacFiles() []string {
+	var files []string
+	for _, resource := range kubectl.RBACResources {
+		files = append(files, path.Join(gm.cluster.Name, resource+".yaml"))
+	}
+	return files
+}
+
+// LastRBACChange diffs the RBAC exports of the last commit that touched
+// them against their parent. It returns nil if no commit has.
+func (gm *Manager) LastRBACChange() (*RBACChange, error) {
+	args := append([]string{"-C", gm.syncPath, "log", "-1", "--pretty=format:%H %h - %s", "--"}, gm.rbacFiles()...)
+	output, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find RBAC changes: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	hash, commit, found := strings.Cut(strings.TrimSpace(string(output)), " ")
+	if !found {
+		return nil, nil
+	}
+
+	before, err := gm.rbacAt(hash + "^")
+	if err != nil {
+		return nil, err
+	}
+	after, err := gm.rbacAt(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &RBACChange{Hash: hash, Commit: commit, Diff: kubectl.DiffRBAC(before, after)}, nil
+}
+
+// Head returns the hash of the last commit of the sync clone, or "" before
+// the first commit
+func (gm *Manager) Head() string {
+	output, err := exec.Command("git", "-C", gm.syncPath, "rev-parse", "--verify", "-q", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// rbacAt parses the grants of the RBAC exports at a revision. Exports
+// missing at the revision, or a revision that doesn't exist such as the
+// parent of the first commit, count as empty.
+func (gm *Manager) rbacAt(rev string) ([]kubectl.RBACGrant, error) {
+	var exports []string
+	for _, file := range gm.rbacFiles() {
+		output, err := exec.Command("git", "-C", gm.syncPath, "show", rev+":"+file).Output()
+		if err != nil {
+			continue
+		}
+		exports = append(exports, string(output))
+	}
+	grants, err := kubectl.ParseRBAC(exports...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RBAC at %s: %v", rev, err)
+	}
+	return grants, nil
+}
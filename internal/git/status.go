@@ -0,0 +1,150 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// FileChange is an uncommitted change reported by git status
+type FileChange struct {
+	Status string
+	Path   string
+}
+
+// SyncStatus describes the state of a cluster's GitOps repository
+type SyncStatus struct {
+	RepoPath    string
+	SyncPath    string // the clone syncs run in
+	RemoteURL   string
+	WebURL      string
+	Branch      string
+	Changes     []FileChange
+	LastCommit  string
+	LastSync    time.Time
+	LastSyncErr error
+	// PendingPushes counts the commits queued for a push that failed, and
+	// LastPushErr is the error of the last attempt
+	PendingPushes int
+	LastPushErr   error
+	// NeedsBootstrap is set while the repository holds nothing for the cluster
+	NeedsBootstrap bool
+}
+
+// ParseStatus parses the output of git status --porcelain
+func ParseStatus(porcelain string) []FileChange {
+	var changes []FileChange
+	for _, line := range strings.Split(porcelain, "\n") {
+		if len(strings.TrimSpace(line)) == 0 || len(line) < 4 {
+			continue
+		}
+		changes = append(changes, FileChange{
+			Status: describeStatus(line[:2]),
+			Path:   strings.TrimSpace(line[3:]),
+		})
+	}
+	return changes
+}
+
+// describeStatus turns a two-letter porcelain status code into a word
+func describeStatus(code string) string {
+	switch {
+	case code == "??":
+		return "untracked"
+	case strings.Contains(code, "U"):
+		return "conflict"
+	case strings.Contains(code, "A"):
+		return "added"
+	case strings.Contains(code, "D"):
+		return "deleted"
+	case strings.Contains(code, "R"):
+		return "renamed"
+	default:
+		return "modified"
+	}
+}
+
+// Status collects the repository path, and the branch, uncommitted drift and last commit of the
+// sync clone, with the last sync result
+func (gm *Manager) Status() (*SyncStatus, error) {
+	gm.mu.Lock()
+	status := &SyncStatus{
+		RepoPath:    gm.repoPath,
+		SyncPath:    gm.syncPath,
+		RemoteURL:   gm.cluster.GitRepo,
+		WebURL:      WebURL(gm.cluster.GitRepo),
+		LastSync:    gm.lastSync,
+		LastSyncErr: gm.lastSyncErr,
+		LastPushErr: gm.lastPushErr,
+	}
+	gm.mu.Unlock()
+
+	branch, err := gm.currentBranch()
+	if err != nil {
+		return status, err
+	}
+	status.Branch = branch
+
+	porcelain, err := gm.GetRepositoryStatus()
+	if err != nil {
+		return status, err
+	}
+	status.Changes = ParseStatus(porcelain)
+
+	if commit, err := gm.GetLastCommit(); err == nil {
+		status.LastCommit = commit
+	}
+	status.NeedsBootstrap = gm.NeedsBootstrap()
+	// Counted from the clone, which may hold commits queued by an earlier session
+	status.PendingPushes = gm.countPending()
+
+	return status, nil
+}
+
+// currentBranch returns the checked out branch of the repository, which
+// may have no commits yet
+func (gm *Manager) currentBranch() (string, error) {
+	if output, err := exec.Command("git", "-C", gm.syncPath, "symbolic-ref", "--short", "HEAD").Output(); err == nil {
+		return strings.TrimSpace(string(output)), nil
+	}
+	cmd := exec.Command("git", "-C", gm.syncPath, "rev-parse", "--abbrev-ref", "HEAD")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// recordSync remembers the time and result of the last sync
+func (gm *Manager) recordSync(err error) {
+	gm.mu.Lock()
+	defer gm.mu.Unlock()
+	gm.lastSync = time.Now()
+	gm.lastSyncErr = err
+}
+
+// WebURL converts a git remote URL into a browsable https URL
+func WebURL(remote string) string {
+	url := strings.TrimSuffix(strings.TrimSpace(remote), ".git")
+	switch {
+	case strings.HasPrefix(url, "git@"):
+		// git@github.com:org/repo -> https://github.com/org/repo
+		url = "https://" + strings.Replace(strings.TrimPrefix(url, "git@"), ":", "/", 1)
+	case strings.HasPrefix(url, "ssh://"):
+		url = strings.TrimPrefix(url, "ssh://")
+		if at := strings.Index(url, "@"); at >= 0 {
+			url = url[at+1:]
+		}
+		url = "https://" + url
+	case strings.HasPrefix(url, "http://"), strings.HasPrefix(url, "https://"):
+		// Drop credentials embedded in the URL
+		scheme := url[:strings.Index(url, "://")+3]
+		rest := strings.TrimPrefix(url, scheme)
+		if at := strings.Index(rest, "@"); at >= 0 && at < strings.Index(rest+"/", "/") {
+			rest = rest[at+1:]
+		}
+		url = scheme + rest
+	}
+	return url
+}
@@ -0,0 +1,132 @@
+// Package i18n translates user-facing strings. Messages are looked up by ID
+// in the catalog of the selected locale and fall back to English, so a
+// partial translation still leaves every screen readable.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is the locale of the built-in messages
+const DefaultLocale = "en"
+
+// Catalog holds the messages of one locale
+type Catalog struct {
+	locale   string
+	messages map[string]string
+}
+
+// English returns the catalog of the built-in messages
+func English() *Catalog {
+	return &Catalog{locale: DefaultLocale, messages: english}
+}
+
+// Load reads the catalog of a locale from <dir>/<locale>.json, a JSON object
+// mapping message IDs to translations
+func Load(dir, locale string) (*Catalog, error) {
+	if locale == "" || locale == DefaultLocale {
+		return English(), nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, locale+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog for locale %s: %v", locale, err)
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog for locale %s: %v", locale, err)
+	}
+	return &Catalog{locale: locale, messages: messages}, nil
+}
+
+// Locale returns the locale of the catalog
+func (c *Catalog) Locale() string {
+	return c.locale
+}
+
+// T returns the message with the given ID, formatted with args like
+// fmt.Sprintf. Unknown IDs are returned as they are.
+func (c *Catalog) T(id string, args ...interface{}) string {
+	message, ok := c.messages[id]
+	if !ok {
+		if message, ok = english[id]; !ok {
+			return id
+		}
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Missing returns the IDs of built-in messages the catalog doesn't translate
+func (c *Catalog) Missing() []string {
+	var missing []string
+	for id := range english {
+		if _, ok := c.messages[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Available lists the built-in locale and those with a catalog in dir
+func Available(dir string) ([]string, error) {
+	locales := []string{DefaultLocale}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return locales, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read locale directory: %v", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		if locale := strings.TrimSuffix(name, ".json"); locale != DefaultLocale {
+			locales = append(locales, locale)
+		}
+	}
+	return locales, nil
+}
+
+// WriteTemplate writes the built-in messages to path as a starting point for
+// a translation
+func WriteTemplate(path string) error {
+	data, err := json.MarshalIndent(english, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal messages: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write message template: %v", err)
+	}
+	return nil
+}
+
+// SystemLocale returns the language of LC_ALL, LC_MESSAGES or LANG, such as
+// "de" for de_DE.UTF-8, or an empty string if none is set
+func SystemLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		value = strings.SplitN(value, ".", 2)[0]
+		value = strings.SplitN(value, "_", 2)[0]
+		if value == "C" || value == "POSIX" {
+			return ""
+		}
+		return strings.ToLower(value)
+	}
+	return ""
+}
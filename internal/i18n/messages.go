@@ -0,0 +1,558 @@
+package i18n
+
+// english holds the built-in messages, keyed by ID. IDs are grouped by the
+// screen or command they belong to.
+var english = map[string]string{
+	"add.endpoint":               "Cluster: %s\nEnter public IP or DNS:",
+	"add.endpoint_placeholder":   "Enter public IP or DNS...",
+	"add.footer":                 "enter: next • esc: cancel",
+	"add.kubeconfig":             "Cluster: %s\nEndpoint: %s\nEnter path to kubeconfig file:",
+	"add.kubeconfig_placeholder": "Enter path to kubeconfig file...",
+	"add.loading":                "Adding cluster and verifying configuration...",
+	"add.name":                   "Enter the cluster name:",
+	"add.name_placeholder":       "Enter cluster name...",
+	"add.title":                  "📝 Add New Cluster - Step %d/3",
+
+	"app.crashed":      "💥 Crashed, exiting...",
+	"app.initializing": "Initializing...",
+	"app.title":        "🚀 Kubernetes Orchestrator",
+
+	"apply.applying":          "Applying %s...",
+	"apply.back_files":        "esc: back to files",
+	"apply.back_form":         "esc: back to the form",
+	"apply.back_templates":    "esc: back to templates",
+	"apply.checking":          "Checking %s...",
+	"apply.checks_failed":     "❌ Fix the failed checks before applying",
+	"apply.client_validation": "Client-side validation",
+	"apply.diff":              "Diff",
+	"apply.diff_check":        "Diff against the cluster",
+	"apply.failed_footer":     "↑/↓: scroll • r: check again • %s",
+	"apply.footer":            "↑/↓: select • enter: open/check • c: check whole directory • backspace: parent • esc: back",
+	"apply.manifest":          "Manifest",
+	"apply.no_changes":        "No changes: the cluster already matches the manifest",
+	"apply.no_manifests":      "No manifests here (.yaml, .yml or .json)",
+	"apply.read_failed":       "❌ Failed to read %s: %v",
+	"apply.review_footer":     "↑/↓: scroll • a: apply • r: check again • %s",
+	"apply.server_dry_run":    "Server dry run",
+	"apply.spaces":            "❌ Paths with spaces can't be applied from here; use kubectl directly",
+	"apply.title":             "📦 Apply manifests",
+	"apply.title_create":      "📦 Create %s",
+	"apply.title_file":        "📦 Apply %s",
+	"apply.title_template":    "📦 Apply template %s",
+
+	"audit.empty":         "📜 Audit log is empty",
+	"audit.export_failed": "❌ Failed to export audit log: %v",
+	"audit.export_usage":  "❌ Usage: audit export <file.json|file.csv>",
+	"audit.exported":      "✅ Audit log exported to %s",
+	"audit.read_failed":   "❌ Failed to read audit log: %v",
+	"audit.title":         "📜 Audit Log (%s):",
+	"audit.usage":         "❌ Usage: audit [n] | audit export <file>",
+
+	"browser.crumb_logs":      "logs %s",
+	"browser.crumb_related":   "related %s",
+	"browser.delete_canceled": "Delete canceled",
+	"browser.delete_confirm":  "Delete %s? (y/n)",
+	"browser.deleted":         "✅ Deleted %s",
+	"browser.deleting":        "Deleting %s...",
+	"browser.describe_footer": "tab: next section • g: related • f/F: port-forward • l: logs • e: edit • d: delete • r: refresh • esc: back",
+	"browser.describing":      "Describing %s...",
+	"browser.edit_failed":     "❌ Edit failed: %v",
+	"browser.fetching_logs":   "Fetching logs of %s...",
+	"browser.finding_related": "Finding objects related to %s...",
+	"browser.forwarding":      "⏳ Starting port-forward to %s...",
+	"browser.graph_footer":    "↑/↓: select • enter: describe • g: follow • r: refresh • esc: back",
+	"browser.logs_footer":     "↑/↓: scroll • r: refresh • esc: back",
+	"browser.no_related":      "No related objects",
+	"browser.no_table":        "No resource table yet - run a get command first",
+	"browser.refreshing":      "Refreshing...",
+	"browser.related":         "Related: %s",
+	"browser.table_footer":    "↑/↓: select • enter: describe • g: related • f/F: port-forward (F opens it) • r: refresh • esc: back",
+
+	"capabilities.detected":    "%s detected",
+	"capabilities.removed":     "%s no longer found",
+	"capabilities.reselect":    "select the cluster again to enable git sync",
+	"capabilities.save_failed": "❌ Failed to save the add-ons of %s: %v",
+
+	"clusters.add":             "➕ Add New Cluster",
+	"clusters.add_description": "Add a new Kubernetes cluster",
+	"clusters.footer":          "↑/↓: navigate • enter: select • q: quit",
+	"clusters.read_only":       "🔒 read-only",
+
+	"configs.description":     "Create, edit and run cluster setup configs",
+	"configs.item":            "%s • %s • controller %s • %d worker(s) • %s",
+	"configs.item_ha":         "%s • %s • %d controllers • %d worker(s) • %s",
+	"configs.new":             "➕ New Config",
+	"configs.new_description": "Create a config from the default template",
+	"configs.title":           "🛠️  Managed Cluster Configs",
+
+	"contexts.already_on":        "Already on %s",
+	"contexts.default_namespace": "(kubeconfig default)",
+	"contexts.hint":              "switch <name> changes cluster • switch - goes back to the previous one",
+	"contexts.no_previous":       "No previous cluster to switch back to",
+	"contexts.running":           "(%d running)",
+	"contexts.switch_usage":      "Usage: switch <cluster> | switch -",
+	"contexts.title":             "Clusters:",
+	"contexts.unknown":           "%v - 'context' lists the clusters",
+
+	"dashboard.addons":          "Addons",
+	"dashboard.certificates":    "Certificates",
+	"dashboard.checked":         "Last checked %s • refreshes every %s",
+	"dashboard.checking":        "Checking cluster health...",
+	"dashboard.drift":           "Node file drift",
+	"dashboard.etcd":            "etcd members",
+	"dashboard.expired":         "expired %s",
+	"dashboard.expires":         "expires %s (%d days)",
+	"dashboard.footer":          "r: refresh • esc: back • ctrl+c: quit",
+	"dashboard.no_drift":        "✅ Node files match the applied config",
+	"dashboard.nodes":           "Nodes",
+	"dashboard.services":        "Services",
+	"dashboard.system_pods":     "System pods",
+	"dashboard.test_deployment": "Test deployment",
+	"dashboard.title":           "🩺 Cluster Health: %s",
+
+	"deps.available":       "✅ %s: Available",
+	"deps.checked":         "Checked at %s • f5 or 'deps refresh' to refresh",
+	"deps.checking":        "⏳ Checking dependencies in the background... run 'deps' again shortly",
+	"deps.git_connected":   "✅ Repository: Connected",
+	"deps.git_title":       "📁 Git Repository Status:",
+	"deps.git_unavailable": "❌ Unable to get repository status",
+	"deps.loading":         "Refreshing dependency information...",
+	"deps.not_available":   "❌ %s: Not available - %s",
+	"deps.not_installed":   "➖ %s: Not installed (%s)",
+	"deps.optional":        "🧰 Optional Tools:",
+	"deps.refreshing":      "refreshing...",
+	"deps.title":           "🔧 System Dependencies:",
+	"deps.version":         "Version: %s",
+
+	"document.folded":     " … %d lines",
+	"document.help":       "↑/↓: move • enter: fold • c/e: fold/unfold all • m/s: metadata/status • /: jump to path • n: next • esc: back",
+	"document.jump":       "Jump to path: %s",
+	"document.no_match":   "❌ No path matches %s",
+	"document.no_section": "No %s section",
+	"document.none":       "❌ No document to view - run a command with -o yaml or -o json first",
+	"document.opened":     "📄 %d lines opened in the document viewer - 'view' shows them again",
+	"document.position":   "Line %d/%d • %s",
+
+	"explain.try": "Try:",
+
+	"git.bootstrap_canceled": "🚫 Bootstrap canceled",
+	"git.bootstrap_failed":   "❌ Bootstrap failed: %v",
+	"git.bootstrap_hint":     "📦 The repository holds nothing for this cluster yet - run git-bootstrap [argocd] to lay it out",
+	"git.bootstrap_partial":  "⚠️  Repository bootstrapped with the first export of %s, but %v",
+	"git.bootstrap_usage":    "❌ Usage: git-bootstrap [argocd]",
+	"git.bootstrapped":       "✅ Repository bootstrapped with the first export of %s",
+	"git.drift":              "⚠️  %d uncommitted change(s):",
+	"git.footer":             "s: force sync • o: open repo • r: refresh • esc: back",
+	"git.hint":               "Press ctrl+g to sync or open the repository",
+	"git.last_commit":        "Last commit: %s",
+	"git.last_sync":          "Last sync: %s",
+	"git.loading":            "Loading repository status...",
+	"git.never_synced":       "never this session",
+	"git.no_drift":           "✅ No uncommitted drift",
+	"git.not_configured":     "Git sync is not configured for this cluster",
+	"git.opened":             "🌐 Opened %s",
+	"git.pending_push":       "⏳ %d commit(s) pending push",
+	"git.push_queued":        "⏳ Committed locally, but the push failed; retrying in the background (%d commit(s) pending push)",
+	"git.read_only":          "❌ Syncing to Git is disabled in read-only mode",
+	"git.refreshing":         "Refreshing...",
+	"git.repository":         "  Repository: %s\n  Local path: %s\n  Sync clone: %s\n  Branch: %s",
+	"git.sync_canceled":      "🚫 Sync canceled",
+	"git.sync_failed":        "❌ Sync failed: %v",
+	"git.sync_failed_ago":    "❌ failed %s ago: %v",
+	"git.sync_skipped":       "⏭️  Git sync skipped - run sync to export the cluster later",
+	"git.sync_usage":         "❌ Usage: sync [--message \"<commit message>\"]",
+	"git.sync_warning":       "Git sync warning: %v",
+	"git.synced":             "✅ Changes synced to Git repository",
+	"git.synced_ago":         "✅ %s (%s ago)",
+	"git.synced_partial":     "⚠️  Changes synced to Git repository, but %v",
+	"git.syncing":            "Syncing cluster resources to Git...",
+	"git.title":              "🔄 Git Sync Status - %s",
+
+	"help.text": `🎯 Kubernetes Orchestrator Terminal
+
+Built-in Commands:
+  help              - Show this help
+  clear             - Clear terminal
+  cluster-info      - Show cluster information
+  deps              - Show dependency information
+  deps refresh      - Re-check dependency versions
+  plugins           - List installed terminal plugins
+  audit [n]         - Show the last n audit log entries (default 20)
+  audit export <f>  - Export the audit log to a .json or .csv file
+  policy            - Show the command policy for this cluster
+  settings [timeout|qps|burst|read-only|keep-stale <value>]
+                    - Show or change request timeout, rate limits, read-only mode and whether
+                      Git keeps the exports of deleted resources
+  confirm           - Run a command that the policy asked to confirm
+  relogin           - Sign in again when exec/OIDC credentials expire
+  git-status        - Show the Git sync status of this cluster
+  git-bootstrap [argocd]
+                    - Lay out an empty Git repository for this cluster, with an ArgoCD Application if asked
+  sync [--message "<msg>"]
+                    - Export this cluster to Git now, with an optional commit message
+  rbac [all|diff]   - Show who can do what, or how the last synced RBAC change differs
+  setup-logs [n]    - Show the last n cluster setup log lines (default 50)
+  setups [cluster]  - List the managed cluster configs or show one cluster's setup
+  queue             - Show running and queued commands for this cluster
+  cancel <id>       - Cancel a queued command
+  rollout status|restart|undo <deploy|ds>/<name>
+                    - Follow a rollout with live progress and revision history
+  scale [-n <ns>|-A] - Pick a deployment and scale it interactively
+  jobs [-n <ns>|-A]  - Manage Jobs and CronJobs
+  tail [-n <ns>|-A] [pod[/container] ...]
+                    - Follow the logs of several pods side by side
+  forwards          - List, open and stop port-forwards started from the resource browser
+  context           - List the registered clusters and their terminal namespaces
+  switch <name>|-   - Switch to another cluster, or back to the previous one
+  namespaces        - Browse, create and delete namespaces and set the default one
+  apply             - Pick a manifest, review its dry run and diff, then apply it
+  templates         - Fill in a manifest template and apply it
+  create [kind]     - Create a Deployment, Service, ConfigMap or Ingress from a guided form
+  stats             - Show local command and setup timing statistics
+  view              - Browse the last -o yaml/json output, with folding and path search
+  locale [<name>]   - Show or switch the UI language
+  !<command>        - Run a local shell command, e.g. !dig example.com
+  esc               - Switch clusters
+
+Kubectl Commands:
+  get pods          - List pods
+  get nodes         - List nodes  
+  get namespaces    - List namespaces
+  describe <resource> <n>  - Describe resource
+  logs <pod-name>   - Get pod logs
+  apply -f <file>   - Apply resource from file
+  create <resource> - Create resource
+  delete <resource> <n> - Delete resource
+
+💡 Any kubectl command will work and be executed on the selected cluster.
+🧩 Executables named kub-cli-<name> on PATH or in ~/.kube-orchestrator/plugins run as <name>.
+📤 Resource modifications are automatically synced to Git (if ArgoCD is configured).
+   End a command with :nosync to skip the sync, e.g. scale deploy/web --replicas=3 :nosync
+
+Keyboard Shortcuts:
+  Enter   - Run the command, or continue it after a trailing \, an open quote or a heredoc
+  Ctrl+J  - Start a new line (also Alt+Enter)
+  ←/→, Home/End, Ctrl+A/E, Alt+B/F - Move the cursor
+  Ctrl+W, Ctrl+U, Ctrl+K - Delete a word, to the start or to the end of the line
+  Ctrl+V  - Paste from the clipboard
+  PgUp/PgDn - Scroll the output (also ↑/↓ on single-line commands)
+  Ctrl+L  - Clear terminal
+  Ctrl+R  - Reveal/mask secret values in output
+  Ctrl+G  - Open the Git sync status view
+  Ctrl+T  - Browse and describe the rows of the last get output
+  F5      - Refresh dependency information
+  Esc     - Switch clusters
+  Ctrl+C  - Quit application
+
+🔧 System Requirements:
+  • kubectl - Kubernetes command-line tool
+  • git - Version control system
+
+All dependencies are verified on startup.`,
+
+	"info.auth":              "Auth: %s",
+	"info.connection_active": "✅ Connection: Active",
+	"info.connection_failed": "❌ Connection: Failed",
+	"info.details":           "  Config: %s\n  Added: %s\n  Prometheus: %v\n  ArgoCD: %v",
+	"info.dns":               "DNS: %s",
+	"info.git_repo":          "Git Repo: %s",
+	"info.header":            "🔍 Cluster Information:\n  Name: %s\n  Server: %s",
+	"info.public_ip":         "Public IP: %s",
+
+	"input.placeholder": "Enter value...",
+
+	"jobs.active":         "active",
+	"jobs.age":            "%s ago",
+	"jobs.cronjob_footer": "↑/↓: select • t: trigger now • s: suspend/resume • l: last run logs • r: refresh • esc: back",
+	"jobs.cronjobs":       "CronJobs",
+	"jobs.fetching_logs":  "Fetching logs of %s...",
+	"jobs.footer":         "↑/↓: select • l: logs • r: refresh • esc: back",
+	"jobs.jobs":           "Jobs",
+	"jobs.last_never":     "never run",
+	"jobs.last_run":       "%s %s ago",
+	"jobs.last_scheduled": "scheduled %s ago",
+	"jobs.loading":        "Loading jobs...",
+	"jobs.logs_footer":    "↑/↓: scroll • r: refresh • esc: back",
+	"jobs.logs_title":     "📜 Logs of %s",
+	"jobs.never_run":      "This CronJob hasn't run yet",
+	"jobs.no_cronjobs":    "No CronJobs",
+	"jobs.no_jobs":        "No Jobs",
+	"jobs.resuming":       "Resuming %s...",
+	"jobs.running":        "%d running",
+	"jobs.suspended":      "suspended",
+	"jobs.suspending":     "Suspending %s...",
+	"jobs.title":          "⏱️  Jobs and CronJobs",
+	"jobs.triggering":     "Triggering %s...",
+
+	"kubeconfig.copied":   "🔄 The original kubeconfig of %s changed and was copied in again",
+	"kubeconfig.reloaded": "🔄 kubeconfig of %s changed on disk and was reloaded",
+	"kubeconfig.server":   "server is now %s",
+	"kubeconfig.unusable": "⚠️  kubeconfig of %s changed on disk, but it can't be used: %v",
+
+	"loading.footer": "esc: cancel",
+
+	"locale.exported": "✅ Messages to translate written to %s",
+	"locale.failed":   "❌ %v",
+	"locale.hint":     "'locale <name>' switches, 'locale default' follows the system locale • catalogs are read from %s",
+	"locale.missing":  "%d message(s) are not translated and are shown in English",
+	"locale.switched": "✅ Switched to locale %s",
+	"locale.title":    "🌐 Locales:",
+	"locale.usage":    "❌ Usage: locale [<name>|default|export <file.json>]",
+
+	"multilog.ended":       "ended",
+	"multilog.following":   "following",
+	"multilog.lines_back":  "%d lines back",
+	"multilog.loading":     "Loading pods...",
+	"multilog.no_pods":     "No pods",
+	"multilog.select_help": "↑/↓: select • space: mark (up to %d) • enter: tail • r: refresh • esc: back",
+	"multilog.starting":    "starting",
+	"multilog.tail_help":   "↑/↓: scroll • pgup/pgdn: page • g: oldest • G: follow • esc: back",
+	"multilog.tailing":     "📜 Tailing %d logs (%s)",
+	"multilog.title":       "📜 Tail logs",
+	"multilog.too_many":    "At most %d pods can be tailed at once",
+	"multilog.truncated":   "Tailing the first %d of %d targets",
+
+	"namespaces.cleared":               "✅ Terminal commands use the kubeconfig's namespace again",
+	"namespaces.create_footer":         "enter: create • esc: cancel",
+	"namespaces.creating":              "Creating %s...",
+	"namespaces.delete_confirm":        "Deleting %s removes everything in it. Type the name to confirm:",
+	"namespaces.delete_footer":         "enter: delete • esc: cancel",
+	"namespaces.deleting":              "Deleting %s...",
+	"namespaces.footer":                "↑/↓: select • enter: use in terminal • x: clear default • n: new • d: delete • r: refresh • esc: back",
+	"namespaces.limit_default":         "default",
+	"namespaces.limit_default_request": "default request",
+	"namespaces.limit_max":             "max",
+	"namespaces.limit_min":             "min",
+	"namespaces.limits":                "limits %s (%s)",
+	"namespaces.loading":               "Loading namespaces...",
+	"namespaces.name_mismatch":         "❌ Name doesn't match; nothing was deleted",
+	"namespaces.name_placeholder":      "Enter namespace name...",
+	"namespaces.new_name":              "New namespace name:",
+	"namespaces.no_policies":           "No ResourceQuotas or LimitRanges",
+	"namespaces.policies":              "Quotas and limits: %s",
+	"namespaces.protected":             "❌ %s is a system namespace and can't be deleted here",
+	"namespaces.quota":                 "quota %s",
+	"namespaces.save_failed":           "❌ Namespace set for this session, but not saved: %v",
+	"namespaces.set":                   "✅ Terminal commands now run in %s",
+	"namespaces.title":                 "🗂️  Namespaces",
+	"namespaces.title_default":         "🗂️  Namespaces - terminal default: %s",
+
+	"plugins.discover_failed": "❌ Failed to discover plugins: %v",
+	"plugins.none":            "🧩 No plugins installed. Add executables named kub-cli-<name> to PATH or %s",
+	"plugins.title":           "🧩 Terminal Plugins:",
+
+	"policy.confirm":           "Requires confirmation: %s",
+	"policy.confirm_hint":      "Type 'confirm' to run it, or any other command to cancel.",
+	"policy.confirm_terminal":  "⚠️  %v - type 'confirm' in the terminal to run it",
+	"policy.denied":            "Denied: %s",
+	"policy.load_failed":       "❌ Failed to load policy: %v",
+	"policy.no_tags":           "none",
+	"policy.profiles":          "Profiles are defined in %s",
+	"policy.read_only":         "Read-only: %s",
+	"policy.read_only_flag":    "true (started with --read-only)",
+	"policy.read_only_setting": "true (cluster setting)",
+	"policy.tags":              "Tags: %s",
+	"policy.title":             "🛡️  Command Policy:",
+
+	"portforward.empty":      "No port-forwards - press f on a service or pod in the resource browser (Ctrl+T)",
+	"portforward.ended":      "ended",
+	"portforward.failed":     "❌ Port-forward to %s failed: %v",
+	"portforward.forwarding": "forwarding",
+	"portforward.help":       "↑/↓: select • o: open in browser • x: stop • esc: back",
+	"portforward.started":    "🔌 Forwarding %s to %s (see 'forwards')",
+	"portforward.starting":   "starting",
+	"portforward.stopped":    "✅ Stopped forwarding %s",
+	"portforward.title":      "🔌 Port-forwards",
+
+	"queue.cancel_hint":      "Use 'cancel <id>' to remove a queued command",
+	"queue.cancel_usage":     "❌ Usage: cancel <id>",
+	"queue.canceled":         "✅ Canceled queued command %d",
+	"queue.command_canceled": "🚫 Canceled: %s",
+	"queue.empty":            "📭 No queued commands",
+	"queue.queued":           "queued ",
+	"queue.running":          "running",
+	"queue.title":            "📋 Command Queue:",
+
+	"rbac.diff_empty":    "The commit didn't change who can do what",
+	"rbac.diff_title":    "🔐 RBAC changed by %s:",
+	"rbac.empty":         "No grants to show",
+	"rbac.failed":        "❌ Failed to read RBAC: %v",
+	"rbac.hint":          "⚠️ marks cluster-admin and wildcard grants • 'rbac diff' shows the last synced RBAC change",
+	"rbac.no_changes":    "🔐 No synced commit has changed RBAC yet",
+	"rbac.system_hidden": "%d system grant(s) hidden - 'rbac all' shows them",
+	"rbac.title":         "🔐 RBAC of %s (%d grant(s)):",
+	"rbac.usage":         "❌ Usage: rbac [all|diff]",
+	"rbac.warnings":      "⚠️  %d RBAC security warning(s):",
+
+	"relogin.failed": "❌ Re-login failed: %v",
+
+	"rollout.checking":      "⏳ Checking rollout status...",
+	"rollout.complete":      "✅ Rollout complete after %s",
+	"rollout.confirm":       "%v - type 'confirm' in the terminal to run it",
+	"rollout.done_footer":   "u: roll back • h: refresh history • esc: back",
+	"rollout.failed":        "❌ Rollout failed after %s: %s",
+	"rollout.footer":        "h: refresh history • esc: back",
+	"rollout.history":       "Revision history",
+	"rollout.in_progress":   "⏳ In progress for %s",
+	"rollout.old_replicas":  "%d old replica(s) terminating",
+	"rollout.replicas":      "updated %d/%d • ready %d/%d • available %d/%d",
+	"rollout.running":       "Running rollout %s...",
+	"rollout.scale_footer":  "esc: back",
+	"rollout.scale_stalled": "❌ Scaling stalled after %s: %s",
+	"rollout.scale_title":   "📏 Scale %s",
+	"rollout.scaled":        "✅ %d replica(s) ready after %s",
+	"rollout.title":         "🚀 Rollout %s %s",
+	"rollout.undo_hint":     "Press u to roll back to the previous revision",
+
+	"scale.adjust_footer": "+/-: adjust • 0-9: type a count • enter: scale • esc: back",
+	"scale.confirm":       "Scale %s from %d to %d replicas? (y/n)",
+	"scale.empty":         "No deployments found",
+	"scale.footer":        "↑/↓: select • enter: pick • r: refresh • esc: back",
+	"scale.loading":       "⏳ Loading deployments...",
+	"scale.ready":         "%d/%d ready",
+	"scale.replicas":      "Current: %d replicas (%d ready)\nDesired: %s",
+	"scale.title":         "📏 Scale Deployment",
+
+	"secrets.mask":   "ctrl+r: mask secrets",
+	"secrets.reveal": "ctrl+r: reveal secrets",
+
+	"settings.invalid_burst":     "❌ Burst must be a positive whole number",
+	"settings.invalid_qps":       "❌ QPS must be a positive number",
+	"settings.kubectl_default":   "kubectl default",
+	"settings.rate_limit_note":   "QPS and burst apply to the client-go backend; kubectl doesn't rate limit requests",
+	"settings.save_failed":       "❌ Failed to save settings: %v",
+	"settings.saved":             "✅ Saved %s for %s",
+	"settings.started_read_only": "❌ The application was started with --read-only",
+	"settings.title":             "⚙️  Cluster Settings:",
+	"settings.usage":             "❌ Usage: settings [timeout <duration>|qps <n>|burst <n>|<setting> default|read-only on|off|keep-stale on|off]",
+	"settings.values":            "  Request timeout: %s\n  QPS: %g\n  Burst: %d\n  Read-only: %v\n  Keep stale Git exports: %v",
+
+	"setup_logs.empty": "📄 No setup logs yet (full log: %s)",
+	"setup_logs.title": "📄 Setup Logs (%s):",
+	"setup_logs.usage": "❌ Usage: setup-logs [n]",
+
+	"setups.action_bake":            "Bake",
+	"setups.action_destroy":         "Destroy",
+	"setups.action_resume":          "Resume",
+	"setups.action_runbook":         "Runbook",
+	"setups.action_setup":           "Setup",
+	"setups.action_status":          "Status",
+	"setups.bake_footer":            "enter: bake • esc: cancel",
+	"setups.bake_info":              "Only the downloads and installs are run, so the node can be snapshotted into a machine image.",
+	"setups.bake_placeholder":       "node name or role=ip",
+	"setups.bake_prompt":            "Enter a node of the config, or controller=IP, etcd=IP or worker=IP for a template machine:",
+	"setups.bake_title":             "📦 Bake Node Image for %s",
+	"setups.baked":                  "%s (%s) now has the %s binaries installed. Shut it down and snapshot it into an image;\nsetup skips the downloads on %s nodes created from that image while the versions in the config match.",
+	"setups.canceled":               "%s canceled",
+	"setups.canceling":              "Canceling...",
+	"setups.config":                 "  Config: %s\n  Cluster: %s\n  Kubernetes: %s\n  Work directory: %s\n  Status: %s",
+	"setups.connecting":             "Connecting to nodes...",
+	"setups.destroy_confirm":        "The cluster and its kubeconfig are also removed from the registry. Type the cluster name to confirm:",
+	"setups.destroy_footer":         "enter: destroy • esc: cancel",
+	"setups.destroy_title":          "💥 Destroy Cluster %s",
+	"setups.destroy_warning":        "This stops and removes Kubernetes from %s and deletes %s.",
+	"setups.done_footer":            "esc: back • ctrl+c: quit",
+	"setups.edit_canceled":          "Edit canceled, unsaved changes discarded",
+	"setups.empty":                  "📄 No cluster configs in %s",
+	"setups.error":                  "❌ %v",
+	"setups.estimating":             "estimating",
+	"setups.footer":                 "enter/e: edit • n: new • v: validate • s: setup • r: resume setup • t: status • x: runbook • h: health • b: bake • d: destroy • esc: back",
+	"setups.forget_failed":          "❌ Failed to remove %s from the registry: %v",
+	"setups.forgotten":              "✅ Removed %s and its kubeconfig from the cluster registry",
+	"setups.form_footer":            "enter: next • shift+tab: previous • esc: cancel",
+	"setups.form_save_footer":       "enter: save • shift+tab: previous • esc: cancel",
+	"setups.form_title":             "📝 %s - Field %d/%d",
+	"setups.import_footer":          "i: import into registry • esc: back • ctrl+c: quit",
+	"setups.local":                  "local",
+	"setups.name_mismatch":          "the name doesn't match",
+	"setups.name_placeholder":       "Enter config name...",
+	"setups.new_footer":             "enter: create • esc: cancel",
+	"setups.new_name":               "Config name (saved under %s):",
+	"setups.new_title":              "📝 New Cluster Config",
+	"setups.node_done":              "done",
+	"setups.nodes":                  "Nodes",
+	"setups.operation_failed":       "❌ %s failed after %s: %v",
+	"setups.operation_finished":     "✅ %s finished in %s",
+	"setups.operation_footer":       "o: last operation • enter/e: edit • n: new • v: validate • s: setup • r: resume setup • t: status • x: runbook • h: health • b: bake • d: destroy • esc: back",
+	"setups.operation_title":        "⚙️  %s %s",
+	"setups.parse_failed":           "❌ %v; fix %s in an editor",
+	"setups.passphrase_placeholder": "passphrase",
+	"setups.progress":               "%.0f%% overall • elapsed %s • ETA %s",
+	"setups.quitting_footer":        "ctrl+c: quit now",
+	"setups.read_only":              "❌ %s is read-only; only status checks are allowed",
+	"setups.recent_logs":            "Recent logs",
+	"setups.remote_kubeconfig":      "Remote admin kubeconfig: %s",
+	"setups.runbook_written":        "Runbook written to %s. Uploaded files and certificates are next to it.",
+	"setups.running_footer":         "esc: back (keeps running) • c: cancel • ctrl+c: stop after this step and quit",
+	"setups.saved":                  "✅ Saved %s",
+	"setups.saved_invalid":          "⚠️  Saved %s, but it is not valid yet: %v",
+	"setups.shutting_down":          "Shutting down, stopping the running step...",
+	"setups.status":                 "Nodes:\n%s\nSystem pods:\n%s\nTest deployment:\n%s",
+	"setups.step":                   "Step %d/%d: %s",
+	"setups.still_running":          "❌ %s of %s is still running",
+	"setups.title":                  "🏗️  Cluster Configs (%s):",
+	"setups.unlock_footer":          "enter: unlock and %s • esc: cancel",
+	"setups.unlock_info":            "%s is protected by a passphrase. It is kept in memory for this session only;\n\nset ssh_key_passphrase in the config or load the key into an SSH agent to skip this.",
+	"setups.unlock_title":           "🔑 Unlock SSH Key for %s",
+	"setups.usage":                  "❌ Usage: setups [cluster]",
+	"setups.valid":                  "✅ %s is valid",
+
+	"shell.read_only": "Error: shell commands are disabled in read-only mode",
+	"shell.usage":     "Usage: !<command>, e.g. !dig example.com",
+
+	"stats.all_time":          "all time",
+	"stats.clusters":          "Clusters",
+	"stats.commands":          "Commands",
+	"stats.count":             "count",
+	"stats.day":               "last 24 hours",
+	"stats.empty":             "Nothing recorded",
+	"stats.failed":            "failed",
+	"stats.footer":            "tab: time range • e: start/stop recording • r: refresh • esc: back",
+	"stats.month":             "last 30 days",
+	"stats.off":               "Recording is off - press e to record timings to %s",
+	"stats.on":                "Recording to %s",
+	"stats.recording_started": "✅ Recording command and setup timings locally",
+	"stats.recording_stopped": "✅ Recording stopped; recorded timings are kept",
+	"stats.setup_phases":      "Setup phases",
+	"stats.title":             "📊 Usage Statistics - %s",
+	"stats.week":              "last 7 days",
+
+	"status.argocd":     "🚀 ArgoCD",
+	"status.line":       "Status: %s",
+	"status.prometheus": "🔍 Prometheus",
+	"status.read_only":  "🔒 Read-only",
+
+	"templates.built_in":      "built-in",
+	"templates.custom":        "custom",
+	"templates.dir":           "Custom templates: %s",
+	"templates.field_footer":  "enter: next • shift+tab: previous • esc: cancel",
+	"templates.field_title":   "🧱 %s - Field %d/%d",
+	"templates.footer":        "↑/↓: select • enter: fill in • r: reload • esc: back",
+	"templates.review_footer": "enter: review • shift+tab: previous • esc: cancel",
+	"templates.title":         "🧱 Templates",
+
+	"terminal.connected": "🎯 Connected to cluster: %s",
+	"terminal.error":     "Error: %v",
+	"terminal.footer":    "esc: switch clusters • ctrl+l: clear • %s • ctrl+g: git status • ctrl+t: browse table • f5: refresh deps • ctrl+c: quit",
+	"terminal.in_flight": "%s %d command(s) running or queued",
+	"terminal.ready":     "Terminal Ready - Type 'help' for commands, 'esc' to switch clusters",
+
+	"wizard.example":     "e.g. %s",
+	"wizard.field_title": "🪄 New %s - Field %d/%d",
+	"wizard.help":        "↑/↓: select • enter: fill in • esc: back",
+	"wizard.next_help":   "enter: next • shift+tab: previous • esc: cancel",
+	"wizard.optional":    "optional",
+	"wizard.review_help": "enter: review • shift+tab: previous • esc: cancel",
+	"wizard.title":       "🪄 Create a resource",
+	"wizard.unknown":     "❌ No form for %s - pick one of these",
+
+	"workspaces.busy":             "❌ Wait for running commands and setups to finish before switching",
+	"workspaces.description":      "Switch to another set of clusters",
+	"workspaces.footer":           "↑/↓: select • enter: switch • n: new • esc: back",
+	"workspaces.item":             "🗂️  Workspace: %s",
+	"workspaces.name":             "Workspace name:",
+	"workspaces.name_placeholder": "Enter workspace name...",
+	"workspaces.new_footer":       "enter: create and switch • esc: cancel",
+	"workspaces.new_title":        "🗂️  New Workspace",
+	"workspaces.title":            "🗂️  Workspaces",
+}
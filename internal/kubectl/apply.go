@@ -0,0 +1,35 @@
+package kubectl
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// manifestExtensions are the file types offered by the apply flow
+var manifestExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// IsManifestFile reports whether a file name looks like a Kubernetes manifest
+func IsManifestFile(name string) bool {
+	return manifestExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// Diff runs kubectl diff and reports whether the live objects differ. kubectl
+// diff exits with 1 when there are differences, which isn't an error here.
+func (e *Executor) Diff(args ...string) (string, bool, error) {
+	output, err := e.Execute(append([]string{"diff"}, args...)...)
+	if err == nil {
+		return output, false, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return output, true, nil
+	}
+	return output, false, err
+}
@@ -0,0 +1,124 @@
+package kubectl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+)
+
+// reloginTimeout bounds interactive logins such as browser based OIDC flows
+const reloginTimeout = 5 * time.Minute
+
+// authFailurePatterns are kubectl and auth plugin messages for missing or expired credentials
+var authFailurePatterns = []string{
+	"unauthorized",
+	"you must be logged in to the server",
+	"the server has asked for the client to provide credentials",
+	"token is expired",
+	"token has expired",
+	"id-token expired",
+	"refresh token",
+	"invalid_grant",
+	"getting credentials: exec",
+}
+
+// AuthError reports that the cluster rejected or couldn't obtain credentials
+type AuthError struct {
+	Cluster  string
+	AuthType string
+	Output   string
+}
+
+func (e *AuthError) Error() string {
+	msg := fmt.Sprintf("authentication to cluster '%s' failed: credentials are missing or expired", e.Cluster)
+	if e.AuthType == config.AuthExec || e.AuthType == config.AuthOIDC {
+		msg += ". Type 'relogin' to sign in again"
+	}
+	if output := strings.TrimSpace(e.Output); output != "" {
+		msg += "\n" + output
+	}
+	return msg
+}
+
+// isAuthFailure checks whether kubectl output indicates an authentication failure
+func isAuthFailure(output string) bool {
+	lower := strings.ToLower(output)
+	for _, pattern := range authFailurePatterns {
+		if strings.Contains(lower, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// authType returns the cluster's auth type, detecting it for clusters registered before it was recorded
+func (e *Executor) authType() string {
+	if e.cluster.AuthType != "" {
+		return e.cluster.AuthType
+	}
+	kubeConfig, err := config.LoadKubeConfig(e.cluster.ConfigPath)
+	if err != nil {
+		return config.AuthUnknown
+	}
+	auth, err := kubeConfig.CurrentAuth()
+	if err != nil {
+		return config.AuthUnknown
+	}
+	return auth.Type
+}
+
+// Relogin refreshes the cluster credentials by running its exec auth plugin,
+// which triggers the plugin's own login flow, then tests the connection
+func (e *Executor) Relogin() (string, error) {
+	if e.cluster == nil {
+		return "", fmt.Errorf("no cluster configured")
+	}
+
+	kubeConfig, err := config.LoadKubeConfig(e.cluster.ConfigPath)
+	if err != nil {
+		return "", err
+	}
+	auth, err := kubeConfig.CurrentAuth()
+	if err != nil {
+		return "", err
+	}
+
+	switch auth.Type {
+	case config.AuthExec:
+		if err := auth.Validate(); err != nil {
+			return "", err
+		}
+	case config.AuthOIDC:
+		return "", fmt.Errorf("cluster uses the legacy OIDC auth provider; log in with your identity provider and update the id-token and refresh-token in %s", e.cluster.ConfigPath)
+	default:
+		return "", fmt.Errorf("cluster uses %s credentials, which can't be refreshed; replace the kubeconfig at %s", auth.Type, e.cluster.ConfigPath)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reloginTimeout)
+	defer cancel()
+
+	plugin := auth.Info.Exec
+	cmd := exec.CommandContext(ctx, plugin.Command, plugin.Args...)
+	cmd.Env = os.Environ()
+	for _, env := range plugin.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf(`KUBERNETES_EXEC_INFO={"apiVersion":"%s","kind":"ExecCredential","spec":{"interactive":false}}`, plugin.APIVersion))
+
+	// The plugin prints the credential on stdout; only its stderr is useful to show
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("login with %s failed: %v\n%s", auth.Binary(), err, stderr.String())
+	}
+
+	if err := e.TestConnection(); err != nil {
+		return "", fmt.Errorf("login succeeded but the cluster is still unreachable: %v", err)
+	}
+	return fmt.Sprintf("✅ Logged in to %s using %s", e.cluster.Name, auth.Description()), nil
+}
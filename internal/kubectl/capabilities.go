@@ -0,0 +1,79 @@
+package kubectl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Capabilities are the add-ons found installed on a cluster
+type Capabilities struct {
+	ArgoCD     bool
+	Prometheus bool
+}
+
+// capabilityProbe is how an add-on is recognised: by one of its CRDs being
+// served, or by its usual namespace existing
+type capabilityProbe struct {
+	resources  []string
+	namespaces []string
+}
+
+var (
+	argoCDProbe = capabilityProbe{
+		resources:  []string{"applications.argoproj.io"},
+		namespaces: []string{"argocd"},
+	}
+	prometheusProbe = capabilityProbe{
+		resources:  []string{"prometheuses.monitoring.coreos.com", "servicemonitors.monitoring.coreos.com"},
+		namespaces: []string{"prometheus", "monitoring"},
+	}
+)
+
+// found reports whether any of the probe's resources or namespaces exist
+func (p capabilityProbe) found(resources, namespaces map[string]bool) bool {
+	for _, resource := range p.resources {
+		if resources[resource] {
+			return true
+		}
+	}
+	for _, namespace := range p.namespaces {
+		if namespaces[namespace] {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectCapabilities probes the cluster for ArgoCD and Prometheus through
+// the API resources it serves and its namespaces. Either lookup may be
+// denied to the user, but not both.
+func (e *Executor) DetectCapabilities() (Capabilities, error) {
+	resources := make(map[string]bool)
+	output, resourcesErr := e.Execute("api-resources", "-o", "name")
+	if resourcesErr == nil {
+		for _, line := range strings.Fields(output) {
+			resources[line] = true
+		}
+	}
+
+	namespaces := make(map[string]bool)
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		} `json:"items"`
+	}
+	namespacesErr := e.getJSON(&list, "get", "namespaces")
+	for _, item := range list.Items {
+		namespaces[item.Metadata.Name] = true
+	}
+
+	if resourcesErr != nil && namespacesErr != nil {
+		return Capabilities{}, fmt.Errorf("failed to probe the cluster: %v", namespacesErr)
+	}
+	return Capabilities{
+		ArgoCD:     argoCDProbe.found(resources, namespaces),
+		Prometheus: prometheusProbe.found(resources, namespaces),
+	}, nil
+}
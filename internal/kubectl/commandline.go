@@ -0,0 +1,166 @@
+package kubectl
+
+import (
+	"strings"
+	"unicode"
+)
+
+// heredoc is a << redirection and the body lines read so far
+type heredoc struct {
+	delimiter string
+	stripTabs bool // <<- strips leading tabs from the body and delimiter
+	body      []string
+	closed    bool // the delimiter line was read
+}
+
+// commandScan is the result of reading a multi-line terminal command the way
+// a shell would
+type commandScan struct {
+	line      strings.Builder // the command without heredocs and line continuations
+	heredocs  []*heredoc
+	quote     rune // the quote left open, if any
+	continued bool // the last line ends in a backslash
+}
+
+// scanCommand reads a command line by line, joining backslash continuations,
+// tracking quotes and collecting heredoc bodies
+func scanCommand(command string) *commandScan {
+	s := &commandScan{}
+	for _, text := range strings.Split(command, "\n") {
+		if doc := s.openHeredoc(); doc != nil {
+			if doc.stripTabs {
+				text = strings.TrimLeft(text, "\t")
+			}
+			if text == doc.delimiter {
+				doc.closed = true
+			} else {
+				doc.body = append(doc.body, text)
+			}
+			continue
+		}
+
+		if s.line.Len() > 0 && !s.continued {
+			s.line.WriteRune('\n')
+		}
+		s.continued = false
+		s.scanLine([]rune(text))
+	}
+	return s
+}
+
+// openHeredoc returns the first heredoc still waiting for its delimiter
+func (s *commandScan) openHeredoc() *heredoc {
+	for _, doc := range s.heredocs {
+		if !doc.closed {
+			return doc
+		}
+	}
+	return nil
+}
+
+// scanLine reads one line outside of heredoc bodies
+func (s *commandScan) scanLine(runes []rune) {
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case s.quote != 0:
+			if r == s.quote {
+				s.quote = 0
+			} else if r == '\\' && s.quote == '"' && i+1 < len(runes) {
+				s.line.WriteRune(r)
+				i++
+				r = runes[i]
+			}
+		case r == '\'' || r == '"':
+			s.quote = r
+		case r == '\\':
+			if i == len(runes)-1 {
+				s.continued = true
+				return
+			}
+			s.line.WriteRune(r)
+			i++
+			r = runes[i]
+		case r == '#' && (i == 0 || unicode.IsSpace(runes[i-1])):
+			// A comment, whose quotes don't count
+			s.line.WriteString(string(runes[i:]))
+			return
+		case r == '<' && strings.HasPrefix(string(runes[i:]), "<<"):
+			if strings.HasPrefix(string(runes[i:]), "<<<") {
+				// A here-string
+				s.line.WriteString("<<<")
+				i += 2
+				continue
+			}
+			if end, doc := parseHeredoc(runes, i); doc != nil {
+				s.heredocs = append(s.heredocs, doc)
+				i = end - 1
+				continue
+			}
+			s.line.WriteString("<<")
+			i++
+			continue
+		}
+		s.line.WriteRune(r)
+	}
+}
+
+// parseHeredoc parses the heredoc redirection starting at runes[start],
+// returning the index after its delimiter, or nil if it has none
+func parseHeredoc(runes []rune, start int) (int, *heredoc) {
+	i := start + 2
+	doc := &heredoc{}
+	if i < len(runes) && runes[i] == '-' {
+		doc.stripTabs = true
+		i++
+	}
+	for i < len(runes) && (runes[i] == ' ' || runes[i] == '\t') {
+		i++
+	}
+
+	var delimiter strings.Builder
+	var quote rune
+	for ; i < len(runes); i++ {
+		r := runes[i]
+		if quote != 0 {
+			if r == quote {
+				quote = 0
+			} else {
+				delimiter.WriteRune(r)
+			}
+			continue
+		}
+		if r == '\'' || r == '"' {
+			quote = r
+			continue
+		}
+		if unicode.IsSpace(r) || strings.ContainsRune(";|&<>()", r) {
+			break
+		}
+		delimiter.WriteRune(r)
+	}
+	if delimiter.Len() == 0 || quote != 0 {
+		return start, nil
+	}
+	doc.delimiter = delimiter.String()
+	return i, doc
+}
+
+// CommandComplete reports whether a command typed in the terminal is ready
+// to run. It isn't while its last line ends in a backslash, a quote is left
+// open or a heredoc hasn't reached its delimiter.
+func CommandComplete(command string) bool {
+	s := scanCommand(command)
+	return s.openHeredoc() == nil && s.quote == 0 && !s.continued
+}
+
+// SplitInput splits a heredoc off a kubectl command, as in
+// "apply -f - <<EOF", returning the command without it and the heredoc
+// body to pass on stdin. Backslash continuations are joined.
+func SplitInput(command string) (line, input string) {
+	s := scanCommand(command)
+	if len(s.heredocs) > 0 {
+		input = strings.Join(s.heredocs[0].body, "\n") + "\n"
+	}
+	return s.line.String(), input
+}
@@ -0,0 +1,169 @@
+package kubectl
+
+import (
+	"strings"
+)
+
+// ObjectRef identifies a single Kubernetes object
+type ObjectRef struct {
+	Kind      string // resource type as kubectl accepts it, e.g. "pod" or "ReplicaSet"
+	Name      string
+	Namespace string // empty for cluster-scoped objects or the current namespace
+}
+
+// String returns the kind/name form of the object
+func (r ObjectRef) String() string {
+	return r.Kind + "/" + r.Name
+}
+
+// Args returns the kubectl arguments that select the object
+func (r ObjectRef) Args() []string {
+	args := []string{r.String()}
+	if r.Namespace != "" {
+		args = append(args, "-n", r.Namespace)
+	}
+	return args
+}
+
+// TableRow is an object listed in the output of kubectl get
+type TableRow struct {
+	ObjectRef
+	Line int // index of the row in Table.Lines
+}
+
+// Table is the parsed output of a kubectl get command
+type Table struct {
+	Command string
+	Lines   []string
+	Rows    []TableRow
+}
+
+// ParseTable parses the table printed by a kubectl get command. It returns
+// false for other commands, structured output formats and empty results.
+func ParseTable(command, output string) (*Table, bool) {
+	parts := strings.Fields(command)
+	if len(parts) < 2 || parts[0] != "get" {
+		return nil, false
+	}
+	if format := outputFormat(parts); format != "" && format != "wide" {
+		return nil, false
+	}
+
+	args, namespace, ok := parseArgs(parts[1:])
+	if !ok || len(args) == 0 {
+		return nil, false
+	}
+	// Without a kind/ prefix on names, rows are of the requested type
+	kind := strings.Split(args[0], ",")[0]
+	if slash := strings.Index(kind, "/"); slash >= 0 {
+		kind = kind[:slash]
+	}
+
+	table := &Table{Command: command}
+	nameColumn := -1
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		index := len(table.Lines)
+		table.Lines = append(table.Lines, line)
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			// Each kind gets its own block with a header
+			nameColumn = -1
+			continue
+		}
+		if nameColumn < 0 {
+			if fields[0] == "NAMESPACE" && len(fields) > 1 && fields[1] == "NAME" {
+				nameColumn = 1
+			} else if fields[0] == "NAME" {
+				nameColumn = 0
+			}
+			continue
+		}
+		if len(fields) <= nameColumn {
+			continue
+		}
+
+		row := TableRow{ObjectRef: ObjectRef{Kind: kind, Name: fields[nameColumn], Namespace: namespace}, Line: index}
+		if nameColumn == 1 {
+			row.Namespace = fields[0]
+		}
+		if slash := strings.Index(row.Name, "/"); slash >= 0 {
+			row.Kind, row.Name = row.Name[:slash], row.Name[slash+1:]
+		}
+		table.Rows = append(table.Rows, row)
+	}
+
+	if len(table.Rows) == 0 {
+		return nil, false
+	}
+	return table, true
+}
+
+// DescribeSection is a top-level section of kubectl describe output, such as Events
+type DescribeSection struct {
+	Title string
+	Line  int
+}
+
+// ParseDescribe returns the top-level sections of kubectl describe output that
+// hold nested content, in order
+func ParseDescribe(output string) []DescribeSection {
+	var sections []DescribeSection
+	for i, line := range strings.Split(output, "\n") {
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		// "Events:" or "Events:  <none>" start a section, "Name:  nginx" doesn't
+		title, value, found := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		if found && (value == "" || value == "<none>") {
+			sections = append(sections, DescribeSection{Title: title, Line: i})
+		}
+	}
+	return sections
+}
+
+// RelatedObjects returns the objects a described object points to, like the
+// owner of a pod or the current ReplicaSet of a deployment
+func RelatedObjects(output string) []ObjectRef {
+	var namespace string
+	var related []ObjectRef
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found || strings.HasPrefix(key, " ") {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch key {
+		case "Namespace":
+			namespace = fields[0]
+		case "Controlled By":
+			// Controlled By:  ReplicaSet/nginx-7c5ddbdf54
+			if kind, name, ok := strings.Cut(fields[0], "/"); ok {
+				related = append(related, ObjectRef{Kind: kind, Name: name})
+			}
+		case "NewReplicaSet", "OldReplicaSets":
+			// NewReplicaSet:   nginx-7c5ddbdf54 (3/3 replicas created)
+			if fields[0] != "<none>" {
+				related = append(related, ObjectRef{Kind: "ReplicaSet", Name: strings.TrimSuffix(fields[0], ",")})
+			}
+		case "Node":
+			// Node:  worker-0/10.240.0.20
+			if name, _, _ := strings.Cut(fields[0], "/"); name != "<none>" {
+				related = append(related, ObjectRef{Kind: "node", Name: name})
+			}
+		}
+	}
+
+	// Owners and ReplicaSets live in the namespace of the described object
+	for i := range related {
+		if related[i].Kind != "node" {
+			related[i].Namespace = namespace
+		}
+	}
+	return related
+}
@@ -0,0 +1,125 @@
+package kubectl
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DocumentEntry is a key or list item of a document and the lines it spans
+type DocumentEntry struct {
+	Path  string // e.g. spec.template.spec.containers[0].image
+	Depth int
+	Line  int // index of its first line in Document.Lines
+	End   int // index of its last line, Line for single-line values
+}
+
+// Document is the parsed output of a kubectl command run with -o yaml or
+// -o json
+type Document struct {
+	Command string
+	Format  string // "yaml" or "json"
+	Lines   []string
+	Entries []DocumentEntry // in the order they appear
+}
+
+// outputFormat returns the value of the last -o/--output flag of a command
+func outputFormat(parts []string) string {
+	format := ""
+	for i, part := range parts {
+		if (part == "-o" || part == "--output") && i+1 < len(parts) {
+			format = parts[i+1]
+		} else if strings.HasPrefix(part, "-o=") || strings.HasPrefix(part, "--output=") {
+			format = part[strings.Index(part, "=")+1:]
+		} else if strings.HasPrefix(part, "-o") && len(part) > 2 {
+			format = part[2:]
+		}
+	}
+	return format
+}
+
+// ParseDocument parses the output of a command run with -o yaml or -o json.
+// It returns false for other output formats and output that doesn't parse.
+func ParseDocument(command, output string) (*Document, bool) {
+	format := outputFormat(strings.Fields(command))
+	if format != "yaml" && format != "json" {
+		return nil, false
+	}
+	output = strings.TrimRight(output, "\n")
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(output), &root); err != nil || len(root.Content) == 0 {
+		return nil, false
+	}
+
+	doc := &Document{Command: command, Format: format, Lines: strings.Split(output, "\n")}
+	doc.addEntries(root.Content[0], "", 0)
+	for i := range doc.Entries {
+		doc.Entries[i].End = doc.entryEnd(i)
+	}
+	return doc, true
+}
+
+// addEntries adds the keys and list items under a node, in document order
+func (d *Document) addEntries(node *yaml.Node, path string, depth int) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			child := joinPath(path, key.Value)
+			d.Entries = append(d.Entries, DocumentEntry{Path: child, Depth: depth, Line: key.Line - 1})
+			d.addEntries(value, child, depth+1)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			d.Entries = append(d.Entries, DocumentEntry{Path: child, Depth: depth, Line: item.Line - 1})
+			d.addEntries(item, child, depth+1)
+		}
+	}
+}
+
+// entryEnd finds the last line of entry i. JSON entries opening an object or
+// array end at the matching bracket; YAML entries end before the next entry
+// that isn't nested in them, which takes in multi-line strings.
+func (d *Document) entryEnd(i int) int {
+	entry := d.Entries[i]
+	if d.Format == "json" {
+		line := strings.TrimSpace(d.Lines[entry.Line])
+		if !strings.HasSuffix(line, "{") && !strings.HasSuffix(line, "[") {
+			return entry.Line
+		}
+		indent := indentOf(d.Lines[entry.Line])
+		for j := entry.Line + 1; j < len(d.Lines); j++ {
+			closing := strings.TrimSpace(d.Lines[j])
+			if indentOf(d.Lines[j]) == indent && (strings.HasPrefix(closing, "}") || strings.HasPrefix(closing, "]")) {
+				return j
+			}
+		}
+		return entry.Line
+	}
+
+	end := len(d.Lines) - 1
+	for _, next := range d.Entries[i+1:] {
+		if next.Depth <= entry.Depth && next.Line > entry.Line {
+			end = next.Line - 1
+			break
+		}
+	}
+	for end > entry.Line && strings.TrimSpace(d.Lines[end]) == "" {
+		end--
+	}
+	return end
+}
+
+// joinPath adds a key to a path, bracketing keys that contain dots, such as
+// annotation names
+func joinPath(path, key string) string {
+	if strings.ContainsAny(key, ".[] ") || key == "" {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
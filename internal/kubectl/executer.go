@@ -1,7 +1,9 @@
 package kubectl
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
 	"time"
@@ -9,10 +11,24 @@ import (
 	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
 )
 
+// Default client-side rate limits, matching client-go's
+const (
+	DefaultQPS   = 5
+	DefaultBurst = 10
+)
+
+// requestTimeoutGrace is how much longer than the request timeout a command may run
+const requestTimeoutGrace = 5 * time.Second
+
 // Executor handles kubectl command execution
 type Executor struct {
-	cluster *config.ClusterInfo
-	timeout time.Duration
+	cluster        *config.ClusterInfo
+	timeout        time.Duration
+	requestTimeout time.Duration
+	qps            float32
+	burst          int
+	policy         config.Policy
+	namespace      string
 }
 
 // NewExecutor creates a new kubectl executor for a cluster
@@ -20,6 +36,8 @@ func NewExecutor(cluster *config.ClusterInfo) *Executor {
 	return &Executor{
 		cluster: cluster,
 		timeout: 30 * time.Second, // Default timeout
+		qps:     DefaultQPS,
+		burst:   DefaultBurst,
 	}
 }
 
@@ -28,17 +46,131 @@ func (e *Executor) SetTimeout(timeout time.Duration) {
 	e.timeout = timeout
 }
 
+// SetRequestTimeout sets how long kubectl waits for a single API request. Zero
+// uses kubectl's default. The command timeout is raised to fit it if needed.
+func (e *Executor) SetRequestTimeout(timeout time.Duration) {
+	e.requestTimeout = timeout
+	if timeout > 0 && timeout+requestTimeoutGrace > e.timeout {
+		e.timeout = timeout + requestTimeoutGrace
+	}
+}
+
+// RequestTimeout returns the timeout set with SetRequestTimeout
+func (e *Executor) RequestTimeout() time.Duration {
+	return e.requestTimeout
+}
+
+// SetRateLimits sets the API request rate limits. kubectl doesn't take them as
+// flags, so they only apply once commands run through client-go.
+func (e *Executor) SetRateLimits(qps float32, burst int) {
+	if qps <= 0 {
+		qps = DefaultQPS
+	}
+	if burst <= 0 {
+		burst = DefaultBurst
+	}
+	e.qps, e.burst = qps, burst
+}
+
+// RateLimits returns the API request rate limits set with SetRateLimits
+func (e *Executor) RateLimits() (float32, int) {
+	return e.qps, e.burst
+}
+
+// SetPolicy sets the command policy enforced before running commands
+func (e *Executor) SetPolicy(policy config.Policy) {
+	e.policy = policy
+}
+
+// SetNamespace sets the namespace commands run in when they don't name one.
+// An empty namespace uses the kubeconfig's default.
+func (e *Executor) SetNamespace(namespace string) {
+	e.namespace = namespace
+}
+
+// Namespace returns the default namespace set with SetNamespace
+func (e *Executor) Namespace() string {
+	return e.namespace
+}
+
+// baseArgs returns the kubeconfig, default namespace and request timeout flags.
+// They come first so the same flags in the command itself still take precedence.
+func (e *Executor) baseArgs() []string {
+	args := []string{"--kubeconfig", e.cluster.ConfigPath}
+	if e.namespace != "" {
+		args = append(args, "--namespace", e.namespace)
+	}
+	if e.requestTimeout > 0 {
+		args = append(args, "--request-timeout", e.requestTimeout.String())
+	}
+	return args
+}
+
 // Execute runs a kubectl command and returns the output
 func (e *Executor) Execute(args ...string) (string, error) {
+	return e.execute(false, "", args...)
+}
+
+// ExecuteConfirmed runs a kubectl command whose policy confirmation was given by the user
+func (e *Executor) ExecuteConfirmed(args ...string) (string, error) {
+	return e.execute(true, "", args...)
+}
+
+// ExecuteTo runs a kubectl command and writes its standard output to w as it
+// arrives, so large output such as an export never has to fit in memory.
+// Standard error is returned, for messages like "No resources found".
+func (e *Executor) ExecuteTo(w io.Writer, args ...string) (string, error) {
 	if e.cluster == nil {
 		return "", fmt.Errorf("no cluster configured")
 	}
 
+	if err := CheckPolicy(e.policy, strings.Join(args, " "), false); err != nil {
+		return "", err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("kubectl", append(e.baseArgs(), args...)...)
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if e.timeout > 0 {
+		go func() {
+			time.Sleep(e.timeout)
+			if cmd.Process != nil {
+				cmd.Process.Kill()
+			}
+		}()
+	}
+
+	if err := cmd.Run(); err != nil {
+		if isAuthFailure(stderr.String()) {
+			return stderr.String(), &AuthError{Cluster: e.cluster.Name, AuthType: e.authType(), Output: stderr.String()}
+		}
+		return stderr.String(), fmt.Errorf("kubectl command failed: %w\n%s", err, stderr.String())
+	}
+
+	return stderr.String(), nil
+}
+
+// execute enforces the cluster policy and runs a kubectl command, passing
+// it input on stdin unless input is empty
+func (e *Executor) execute(confirmed bool, input string, args ...string) (string, error) {
+	if e.cluster == nil {
+		return "", fmt.Errorf("no cluster configured")
+	}
+
+	if err := CheckPolicy(e.policy, strings.Join(args, " "), confirmed); err != nil {
+		return "", err
+	}
+
 	// Prepare kubectl command with kubeconfig
-	cmdArgs := []string{"--kubeconfig", e.cluster.ConfigPath}
+	cmdArgs := e.baseArgs()
 	cmdArgs = append(cmdArgs, args...)
 
 	cmd := exec.Command("kubectl", cmdArgs...)
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
 	
 	// Set timeout
 	if e.timeout > 0 {
@@ -52,20 +184,49 @@ func (e *Executor) Execute(args ...string) (string, error) {
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return string(output), fmt.Errorf("kubectl command failed: %v", err)
+		if isAuthFailure(string(output)) {
+			return string(output), &AuthError{Cluster: e.cluster.Name, AuthType: e.authType(), Output: string(output)}
+		}
+		return string(output), fmt.Errorf("kubectl command failed: %w", err)
 	}
 
 	return string(output), nil
 }
 
-// ExecuteCommand parses a command string and executes it
+// ExecuteCommand parses a command string and executes it. A heredoc in the
+// command is passed to kubectl on stdin.
 func (e *Executor) ExecuteCommand(command string) (string, error) {
-	parts := strings.Fields(command)
+	line, input := SplitInput(command)
+	parts := strings.Fields(line)
+	if len(parts) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+
+	return e.execute(false, input, parts...)
+}
+
+// ExecuteCommandConfirmed parses and executes a command the user has confirmed
+func (e *Executor) ExecuteCommandConfirmed(command string) (string, error) {
+	line, input := SplitInput(command)
+	parts := strings.Fields(line)
 	if len(parts) == 0 {
 		return "", fmt.Errorf("empty command")
 	}
 
-	return e.Execute(parts...)
+	return e.execute(true, input, parts...)
+}
+
+// InteractiveCommand prepares a kubectl command that takes over the terminal,
+// such as edit. The policy is checked, but the command isn't started.
+func (e *Executor) InteractiveCommand(args ...string) (*exec.Cmd, error) {
+	if e.cluster == nil {
+		return nil, fmt.Errorf("no cluster configured")
+	}
+	if err := CheckPolicy(e.policy, strings.Join(args, " "), false); err != nil {
+		return nil, err
+	}
+
+	return exec.Command("kubectl", append(e.baseArgs(), args...)...), nil
 }
 
 // TestConnection tests connectivity to the cluster
@@ -143,34 +304,47 @@ func (e *Executor) Describe(resourceType, resourceName string) (string, error) {
 	return e.Execute("describe", resourceType, resourceName)
 }
 
-// IsModifyingCommand checks if a command modifies cluster state
+// readOnlyCommands are the kubectl verbs that only read cluster state.
+// Every other verb, including plugins and verbs this list doesn't know yet,
+// counts as modifying.
+var readOnlyCommands = map[string]bool{
+	"get":           true,
+	"describe":      true,
+	"logs":          true,
+	"explain":       true,
+	"top":           true,
+	"diff":          true,
+	"events":        true,
+	"wait":          true,
+	"api-resources": true,
+	"api-versions":  true,
+	"cluster-info":  true,
+	"version":       true,
+	"kustomize":     true,
+	"completion":    true,
+	"options":       true,
+}
+
+// IsModifyingCommand checks if a command modifies cluster state. Global
+// flags such as -n or --context may come before the verb. Commands whose
+// verb isn't known to be read-only, or can't be told apart from an unknown
+// flag before it, count as modifying.
 func IsModifyingCommand(command string) bool {
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		return false
+	verb, args, ok := commandVerb(strings.Fields(command))
+	if !ok {
+		return true
 	}
 
-	modifyingCommands := map[string]bool{
-		"create":    true,
-		"apply":     true,
-		"patch":     true,
-		"replace":   true,
-		"delete":    true,
-		"scale":     true,
-		"annotate":  true,
-		"label":     true,
-		"expose":    true,
-		"rollout":   true,
-		"set":       true,
-		"edit":      true,
-		"cp":        true,
-		"drain":     true,
-		"cordon":    true,
-		"uncordon":  true,
-		"taint":     true,
-	}
-
-	return modifyingCommands[parts[0]]
+	switch verb {
+	case "":
+		return false
+	case "auth":
+		return len(args) == 0 || (args[0] != "can-i" && args[0] != "whoami")
+	case "config":
+		return len(args) == 0 || (args[0] != "view" && args[0] != "current-context" &&
+			args[0] != "get-contexts" && args[0] != "get-clusters" && args[0] != "get-users")
+	}
+	return !readOnlyCommands[verb]
 }
 
 // GetResourcesForExport returns a list of resource types suitable for GitOps export
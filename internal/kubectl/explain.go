@@ -0,0 +1,85 @@
+package kubectl
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Explanation describes a known kubectl failure and the command to run next
+type Explanation struct {
+	Problem    string
+	Suggestion string // a terminal command
+}
+
+// explainer matches a kubectl failure and explains it from the submatches
+type explainer struct {
+	pattern *regexp.Regexp
+	explain func(match []string) Explanation
+}
+
+// explainers are checked in order; the first match wins
+var explainers = []explainer{
+	{
+		pattern: regexp.MustCompile(`User "([^"]+)" cannot (\w+) resource "([^"]+)"(?: in API group "[^"]*")?(?: in the namespace "([^"]+)")?`),
+		explain: func(match []string) Explanation {
+			user, verb, resource, namespace := match[1], match[2], match[3], match[4]
+			scope := "at cluster scope"
+			flags := ""
+			if namespace != "" {
+				scope = "in namespace " + namespace
+				flags = " -n " + namespace
+			}
+			return Explanation{
+				Problem:    fmt.Sprintf("RBAC denies %s permission to %s %s %s. A Role or ClusterRole binding is missing.", user, verb, resource, scope),
+				Suggestion: "auth can-i --list" + flags,
+			}
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`Failed to pull image "([^"]+)"`),
+		explain: func(match []string) Explanation {
+			return Explanation{
+				Problem:    fmt.Sprintf("The node can't pull %s. Check the image name and tag, and that the pod has imagePullSecrets for a private registry.", match[1]),
+				Suggestion: "get events --field-selector reason=Failed",
+			}
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`ImagePullBackOff|ErrImagePull`),
+		explain: func(match []string) Explanation {
+			return Explanation{
+				Problem:    "A container image can't be pulled. Check the image name and tag, and that the pod has imagePullSecrets for a private registry.",
+				Suggestion: "get events --field-selector reason=Failed",
+			}
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`connect: connection refused|The connection to the server (\S+) was refused`),
+		explain: func(match []string) Explanation {
+			return Explanation{
+				Problem:    "Nothing is accepting connections at the API server address. The API server may be down, or the kubeconfig points at the wrong host or port.",
+				Suggestion: "cluster-info",
+			}
+		},
+	},
+	{
+		pattern: regexp.MustCompile(`x509: certificate has expired or is not yet valid`),
+		explain: func(match []string) Explanation {
+			return Explanation{
+				Problem:    "A certificate has expired or is not valid yet. Either the cluster's certificates need renewing, or this machine's clock is wrong.",
+				Suggestion: "!date -u",
+			}
+		},
+	},
+}
+
+// ExplainFailure explains a known failure in kubectl output, such as RBAC
+// denials, image pull errors, refused connections and expired certificates
+func ExplainFailure(output string) (Explanation, bool) {
+	for _, e := range explainers {
+		if match := e.pattern.FindStringSubmatch(output); match != nil {
+			return e.explain(match), true
+		}
+	}
+	return Explanation{}, false
+}
@@ -0,0 +1,185 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Job states
+const (
+	JobComplete  = "Complete"
+	JobFailed    = "Failed"
+	JobRunning   = "Running"
+	JobSuspended = "Suspended"
+	JobPending   = "Pending"
+)
+
+// JobInfo is the state of a Job
+type JobInfo struct {
+	ObjectRef
+	CronJob     string // owning CronJob, if any
+	Status      string
+	Succeeded   int
+	Completions int
+	Created     time.Time
+}
+
+// CronJobInfo is the state of a CronJob and its most recent run
+type CronJobInfo struct {
+	ObjectRef
+	Schedule     string
+	Suspended    bool
+	Active       int
+	LastSchedule time.Time
+	LastJob      *JobInfo
+}
+
+// jobObject holds the fields of Jobs and CronJobs shown in the jobs view
+type jobObject struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name              string    `json:"name"`
+		Namespace         string    `json:"namespace"`
+		CreationTimestamp time.Time `json:"creationTimestamp"`
+		OwnerReferences   []struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec struct {
+		Schedule    string `json:"schedule"`
+		Suspend     *bool  `json:"suspend"`
+		Completions *int   `json:"completions"`
+	} `json:"spec"`
+	Status struct {
+		Active           json.RawMessage `json:"active"` // a count on Jobs, a list on CronJobs
+		Succeeded        int             `json:"succeeded"`
+		LastScheduleTime *time.Time      `json:"lastScheduleTime"`
+		Conditions       []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// ParseJobList reads CronJobs and Jobs from the JSON of kubectl get cronjobs,jobs.
+// Each CronJob gets its most recent Job, and Jobs are sorted newest first.
+func ParseJobList(data string) ([]CronJobInfo, []JobInfo, error) {
+	var list struct {
+		Items []jobObject `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse jobs: %v", err)
+	}
+
+	var cronJobs []CronJobInfo
+	var jobs []JobInfo
+	for _, item := range list.Items {
+		ref := ObjectRef{Name: item.Metadata.Name, Namespace: item.Metadata.Namespace}
+		switch item.Kind {
+		case "CronJob":
+			ref.Kind = "cronjob"
+			cronJob := CronJobInfo{
+				ObjectRef: ref,
+				Schedule:  item.Spec.Schedule,
+				Suspended: item.Spec.Suspend != nil && *item.Spec.Suspend,
+			}
+			var active []json.RawMessage
+			if json.Unmarshal(item.Status.Active, &active) == nil {
+				cronJob.Active = len(active)
+			}
+			if item.Status.LastScheduleTime != nil {
+				cronJob.LastSchedule = *item.Status.LastScheduleTime
+			}
+			cronJobs = append(cronJobs, cronJob)
+		case "Job":
+			ref.Kind = "job"
+			job := JobInfo{
+				ObjectRef:   ref,
+				Status:      jobStatus(item),
+				Succeeded:   item.Status.Succeeded,
+				Completions: 1,
+				Created:     item.Metadata.CreationTimestamp,
+			}
+			if item.Spec.Completions != nil {
+				job.Completions = *item.Spec.Completions
+			}
+			for _, owner := range item.Metadata.OwnerReferences {
+				if owner.Kind == "CronJob" {
+					job.CronJob = owner.Name
+				}
+			}
+			jobs = append(jobs, job)
+		}
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Created.After(jobs[j].Created)
+	})
+	for i := range cronJobs {
+		for j := range jobs {
+			if jobs[j].CronJob == cronJobs[i].Name && jobs[j].Namespace == cronJobs[i].Namespace {
+				cronJobs[i].LastJob = &jobs[j]
+				break
+			}
+		}
+	}
+	return cronJobs, jobs, nil
+}
+
+// jobStatus derives a Job's state from its conditions
+func jobStatus(item jobObject) string {
+	for _, condition := range item.Status.Conditions {
+		if condition.Status != "True" {
+			continue
+		}
+		switch condition.Type {
+		case "Complete":
+			return JobComplete
+		case "Failed":
+			return JobFailed
+		case "Suspended":
+			return JobSuspended
+		}
+	}
+	var active int
+	if json.Unmarshal(item.Status.Active, &active) == nil && active > 0 {
+		return JobRunning
+	}
+	return JobPending
+}
+
+// ListJobs returns the CronJobs and Jobs selected by namespace flags such as -n or -A
+func (e *Executor) ListJobs(flags ...string) ([]CronJobInfo, []JobInfo, error) {
+	args := append([]string{"get", "cronjobs,jobs", "-o", "json"}, flags...)
+	output, err := e.Execute(args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(output))
+	}
+	return ParseJobList(output)
+}
+
+// TriggerCronJobCommand returns the command that runs a CronJob now by creating
+// a Job from its template
+func TriggerCronJobCommand(cronJob ObjectRef, now time.Time) string {
+	suffix := fmt.Sprintf("-manual-%d", now.Unix())
+	name := cronJob.Name
+	// Job names are limited to 63 characters
+	if len(name)+len(suffix) > 63 {
+		name = strings.TrimRight(name[:63-len(suffix)], "-")
+	}
+	name += suffix
+	command := fmt.Sprintf("create job %s --from=cronjob/%s", name, cronJob.Name)
+	if cronJob.Namespace != "" {
+		command += " -n " + cronJob.Namespace
+	}
+	return command
+}
+
+// SuspendCronJobCommand returns the command that suspends or resumes a CronJob
+func SuspendCronJobCommand(cronJob ObjectRef, suspend bool) string {
+	return fmt.Sprintf(`patch %s --type=merge -p {"spec":{"suspend":%t}}`, strings.Join(cronJob.Args(), " "), suspend)
+}
@@ -0,0 +1,73 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PodContainers is a pod and the names of its containers
+type PodContainers struct {
+	ObjectRef
+	Phase      string
+	Containers []string
+}
+
+// ParsePodList reads pods and their containers from the JSON of kubectl get pods
+func ParsePodList(data string) ([]PodContainers, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Spec struct {
+				Containers []struct {
+					Name string `json:"name"`
+				} `json:"containers"`
+			} `json:"spec"`
+			Status struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse pods: %v", err)
+	}
+
+	var pods []PodContainers
+	for _, item := range list.Items {
+		pod := PodContainers{
+			ObjectRef: ObjectRef{Kind: "pod", Name: item.Metadata.Name, Namespace: item.Metadata.Namespace},
+			Phase:     item.Status.Phase,
+		}
+		for _, container := range item.Spec.Containers {
+			pod.Containers = append(pod.Containers, container.Name)
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// ListPods returns the pods selected by namespace flags such as -n or -A
+func (e *Executor) ListPods(flags ...string) ([]PodContainers, error) {
+	args := append([]string{"get", "pods", "-o", "json"}, flags...)
+	output, err := e.Execute(args...)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(output))
+	}
+	return ParsePodList(output)
+}
+
+// FollowLogsArgs returns the arguments of kubectl logs -f for a pod, starting
+// with its last tail lines. An empty container follows every container.
+func FollowLogsArgs(pod ObjectRef, container string, tail int) []string {
+	args := append([]string{"logs", "-f"}, pod.Args()...)
+	if container == "" {
+		args = append(args, "--all-containers")
+	} else {
+		args = append(args, "-c", container)
+	}
+	return append(args, "--tail="+strconv.Itoa(tail))
+}
@@ -0,0 +1,158 @@
+package kubectl
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaskPlaceholder replaces sensitive values in masked output
+const MaskPlaceholder = "********"
+
+var (
+	// Values of keys that always hold credentials, in YAML or JSON form
+	sensitiveKeyPattern = regexp.MustCompile(`(?i)^(\s*-?\s*"?(?:token|password|passwd|secret|client-key-data|access-token|refresh-token|id-token|api-key|apikey)"?\s*:\s*)("?)([^"\s,][^",]*)("?)(,?)\s*$`)
+
+	// Bearer tokens and JWTs embedded anywhere in a line
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]{8,}`)
+
+	// Key/value lines inside data blocks
+	yamlEntryPattern = regexp.MustCompile(`^(\s*"?[^":]+"?\s*:\s*)(.+?)(,?)\s*$`)
+)
+
+// MaskSecrets hides Secret data, tokens and private keys in kubectl output.
+// Secret data blocks are only masked when the enclosing object is a Secret,
+// so ConfigMap data stays readable.
+func MaskSecrets(output string) string {
+	lines := strings.Split(output, "\n")
+	masked := make([]bool, len(lines))
+
+	maskSecretDataBlocks(lines, masked)
+
+	inPrivateKey := false
+	for i, line := range lines {
+		if strings.Contains(line, "-----BEGIN") && strings.Contains(line, "PRIVATE KEY-----") {
+			inPrivateKey = true
+			continue
+		}
+		if inPrivateKey {
+			if strings.Contains(line, "-----END") {
+				inPrivateKey = false
+				continue
+			}
+			lines[i] = leadingWhitespace(line) + MaskPlaceholder
+			continue
+		}
+		if masked[i] {
+			continue
+		}
+
+		if m := sensitiveKeyPattern.FindStringSubmatch(line); m != nil {
+			lines[i] = m[1] + m[2] + MaskPlaceholder + m[4] + m[5]
+			continue
+		}
+		line = jwtPattern.ReplaceAllString(line, MaskPlaceholder)
+		lines[i] = bearerPattern.ReplaceAllString(line, "${1}"+MaskPlaceholder)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// maskSecretDataBlocks masks the entries of data/stringData blocks that
+// belong to Secret objects, in both YAML and JSON output
+func maskSecretDataBlocks(lines []string, masked []bool) {
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "- "))
+		isYAML := trimmed == "data:" || trimmed == "stringData:"
+		isJSON := trimmed == `"data": {` || trimmed == `"stringData": {`
+		if !isYAML && !isJSON {
+			continue
+		}
+
+		indent := indentOf(line)
+		if strings.HasPrefix(strings.TrimSpace(line), "- ") {
+			indent += 2
+		}
+		if !isSecretObject(lines, i, indent) {
+			continue
+		}
+
+		for j := i + 1; j < len(lines); j++ {
+			entry := lines[j]
+			if strings.TrimSpace(entry) == "" {
+				continue
+			}
+			if indentOf(entry) <= indent {
+				break
+			}
+			if m := yamlEntryPattern.FindStringSubmatch(entry); m != nil {
+				value := strings.TrimSpace(m[2])
+				if value == "|" || value == ">" || value == "|-" || value == ">-" {
+					// Multi-line values are masked line by line below
+					lines[j] = m[1] + value
+				} else {
+					quote := ""
+					if strings.HasPrefix(value, `"`) {
+						quote = `"`
+					}
+					lines[j] = m[1] + quote + MaskPlaceholder + quote + m[3]
+				}
+			} else {
+				lines[j] = leadingWhitespace(entry) + MaskPlaceholder
+			}
+			masked[j] = true
+		}
+	}
+}
+
+// isSecretObject reports whether the object containing line i, whose keys
+// sit at the given indentation, declares kind Secret
+func isSecretObject(lines []string, i, indent int) bool {
+	matches := func(line string) bool {
+		trimmed := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "- "))
+		return trimmed == "kind: Secret" || strings.HasPrefix(trimmed, `"kind": "Secret"`)
+	}
+
+	for _, step := range []int{-1, 1} {
+		for j := i + step; j >= 0 && j < len(lines); j += step {
+			line := lines[j]
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if strings.TrimSpace(line) == "---" {
+				break
+			}
+			lineIndent := indentOf(line)
+			if strings.HasPrefix(strings.TrimSpace(line), "- ") {
+				lineIndent += 2
+				if lineIndent == indent && matches(line) {
+					return true
+				}
+				if step == 1 && lineIndent <= indent {
+					break
+				}
+				if step == -1 && lineIndent == indent {
+					// The list item marker starts this object
+					break
+				}
+			}
+			if lineIndent < indent {
+				break
+			}
+			if lineIndent == indent && matches(line) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// indentOf returns the number of leading spaces of a line
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// leadingWhitespace returns the leading whitespace of a line
+func leadingWhitespace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
@@ -0,0 +1,183 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// protectedNamespaces are system namespaces the namespace manager won't delete
+var protectedNamespaces = map[string]bool{
+	"default":         true,
+	"kube-system":     true,
+	"kube-public":     true,
+	"kube-node-lease": true,
+}
+
+// countedKinds are the resource types counted per namespace
+var countedKinds = []string{"pods", "deployments", "services", "configmaps", "secrets"}
+
+// IsProtectedNamespace reports whether a namespace is a system namespace
+func IsProtectedNamespace(name string) bool {
+	return protectedNamespaces[name]
+}
+
+// QuotaInfo is a ResourceQuota with its usage, keyed by resource name
+type QuotaInfo struct {
+	Name string
+	Hard map[string]string
+	Used map[string]string
+}
+
+// LimitInfo is a LimitRange limit for one type of object
+type LimitInfo struct {
+	Name           string
+	Type           string // Container, Pod or PersistentVolumeClaim
+	Default        map[string]string
+	DefaultRequest map[string]string
+	Max            map[string]string
+	Min            map[string]string
+}
+
+// NamespaceInfo is a namespace with its resource counts, quotas and limits
+type NamespaceInfo struct {
+	Name    string
+	Status  string
+	Created time.Time
+	Counts  map[string]int // keyed by countedKinds
+	Quotas  []QuotaInfo
+	Limits  []LimitInfo
+}
+
+// CountedKinds returns the resource types counted in NamespaceInfo.Counts, in display order
+func CountedKinds() []string {
+	return countedKinds
+}
+
+// ListNamespaces returns every namespace with resource counts, quotas and
+// limits. Counts, quotas and limits are best effort, since the user may not be
+// allowed to list them across namespaces.
+func (e *Executor) ListNamespaces() ([]NamespaceInfo, error) {
+	output, err := e.Execute("get", "namespaces", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(output))
+	}
+	namespaces, err := parseNamespaces(output)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]*NamespaceInfo)
+	for i := range namespaces {
+		index[namespaces[i].Name] = &namespaces[i]
+	}
+
+	// One line per object: "<namespace> <kind>"
+	output, err = e.Execute("get", strings.Join(countedKinds, ","), "--all-namespaces", "--no-headers",
+		"-o", "custom-columns=NAMESPACE:.metadata.namespace,KIND:.kind")
+	if err == nil {
+		for _, line := range strings.Split(output, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 || index[fields[0]] == nil {
+				continue
+			}
+			kind := strings.ToLower(fields[1]) + "s"
+			index[fields[0]].Counts[kind]++
+		}
+	}
+
+	output, err = e.Execute("get", "resourcequotas,limitranges", "--all-namespaces", "-o", "json")
+	if err == nil {
+		parsePolicies(output, index)
+	}
+	return namespaces, nil
+}
+
+// parseNamespaces reads namespaces from the JSON of kubectl get namespaces
+func parseNamespaces(data string) ([]NamespaceInfo, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name              string    `json:"name"`
+				CreationTimestamp time.Time `json:"creationTimestamp"`
+			} `json:"metadata"`
+			Status struct {
+				Phase string `json:"phase"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse namespaces: %v", err)
+	}
+
+	var namespaces []NamespaceInfo
+	for _, item := range list.Items {
+		namespaces = append(namespaces, NamespaceInfo{
+			Name:    item.Metadata.Name,
+			Status:  item.Status.Phase,
+			Created: item.Metadata.CreationTimestamp,
+			Counts:  make(map[string]int),
+		})
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+	return namespaces, nil
+}
+
+// parsePolicies adds ResourceQuotas and LimitRanges to their namespaces
+func parsePolicies(data string, index map[string]*NamespaceInfo) {
+	var list struct {
+		Items []struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Spec struct {
+				Limits []struct {
+					Type           string            `json:"type"`
+					Default        map[string]string `json:"default"`
+					DefaultRequest map[string]string `json:"defaultRequest"`
+					Max            map[string]string `json:"max"`
+					Min            map[string]string `json:"min"`
+				} `json:"limits"`
+			} `json:"spec"`
+			Status struct {
+				Hard map[string]string `json:"hard"`
+				Used map[string]string `json:"used"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if json.Unmarshal([]byte(data), &list) != nil {
+		return
+	}
+
+	for _, item := range list.Items {
+		namespace := index[item.Metadata.Namespace]
+		if namespace == nil {
+			continue
+		}
+		switch item.Kind {
+		case "ResourceQuota":
+			namespace.Quotas = append(namespace.Quotas, QuotaInfo{
+				Name: item.Metadata.Name,
+				Hard: item.Status.Hard,
+				Used: item.Status.Used,
+			})
+		case "LimitRange":
+			for _, limit := range item.Spec.Limits {
+				namespace.Limits = append(namespace.Limits, LimitInfo{
+					Name:           item.Metadata.Name,
+					Type:           limit.Type,
+					Default:        limit.Default,
+					DefaultRequest: limit.DefaultRequest,
+					Max:            limit.Max,
+					Min:            limit.Min,
+				})
+			}
+		}
+	}
+}
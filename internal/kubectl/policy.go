@@ -0,0 +1,144 @@
+package kubectl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+)
+
+// PolicyError is returned when a command is rejected by the cluster policy
+type PolicyError struct {
+	Command           string
+	Rule              string
+	NeedsConfirmation bool
+}
+
+func (e *PolicyError) Error() string {
+	if e.NeedsConfirmation {
+		return fmt.Sprintf("command '%s' requires confirmation (policy rule '%s')", e.Command, e.Rule)
+	}
+	return fmt.Sprintf("command '%s' is denied by policy rule '%s'", e.Command, e.Rule)
+}
+
+// resourceAliases normalizes resource short names so policy rules and
+// selective git syncs match any spelling
+var resourceAliases = map[string]string{
+	"ns":          "namespaces",
+	"namespace":   "namespaces",
+	"po":          "pods",
+	"pod":         "pods",
+	"deploy":      "deployments",
+	"deployment":  "deployments",
+	"svc":         "services",
+	"service":     "services",
+	"no":          "nodes",
+	"node":        "nodes",
+	"sts":         "statefulsets",
+	"statefulset": "statefulsets",
+	"ds":          "daemonsets",
+	"daemonset":   "daemonsets",
+	"pv":          "persistentvolumes",
+	"pvc":         "persistentvolumeclaims",
+	"cm":          "configmaps",
+	"configmap":   "configmaps",
+	"secret":      "secrets",
+	"crd":         "customresourcedefinitions",
+
+	"ing":                     "ingresses",
+	"ingress":                 "ingresses",
+	"persistentvolume":        "persistentvolumes",
+	"persistentvolumeclaim":   "persistentvolumeclaims",
+	"sa":                      "serviceaccounts",
+	"serviceaccount":          "serviceaccounts",
+	"role":                    "roles",
+	"rolebinding":             "rolebindings",
+	"clusterrole":             "clusterroles",
+	"clusterrolebinding":      "clusterrolebindings",
+	"netpol":                  "networkpolicies",
+	"networkpolicy":           "networkpolicies",
+	"pdb":                     "poddisruptionbudgets",
+	"poddisruptionbudget":     "poddisruptionbudgets",
+	"hpa":                     "horizontalpodautoscalers",
+	"horizontalpodautoscaler": "horizontalpodautoscalers",
+	"vpa":                     "verticalpodautoscalers",
+	"verticalpodautoscaler":   "verticalpodautoscalers",
+	"job":                     "jobs",
+	"cj":                      "cronjobs",
+	"cronjob":                 "cronjobs",
+	"rs":                      "replicasets",
+	"replicaset":              "replicasets",
+}
+
+// unknownFlagRule is the rule reported for commands whose verb can't be
+// determined because of an unknown flag before it
+const unknownFlagRule = "unknown flag before the verb"
+
+// policyTokens splits a command into normalized, non-flag tokens, starting
+// with the verb. Flags are dropped along with their values, wherever they
+// appear, so "-n prod delete ns foo" matches "delete ns". It returns false
+// when the verb can't be determined.
+func policyTokens(command string) ([]string, bool) {
+	verb, args, ok := commandVerb(strings.Fields(command))
+	if !ok {
+		return nil, false
+	}
+	var tokens []string
+	if verb != "" {
+		args = append([]string{verb}, args...)
+	}
+	for _, field := range args {
+		// Split kind/name so "delete ns/foo" matches "delete ns"
+		for _, part := range strings.SplitN(field, "/", 2) {
+			part = strings.ToLower(part)
+			if alias, ok := resourceAliases[part]; ok {
+				part = alias
+			}
+			tokens = append(tokens, part)
+		}
+	}
+	return tokens, true
+}
+
+// matchesRule reports whether a rule's tokens are a prefix of the command's tokens
+func matchesRule(commandTokens []string, rule string) bool {
+	ruleTokens, _ := policyTokens(rule)
+	if len(ruleTokens) == 0 || len(ruleTokens) > len(commandTokens) {
+		return false
+	}
+	for i, token := range ruleTokens {
+		if token != "*" && token != commandTokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckPolicy evaluates a command against a policy. Confirmation rules are
+// skipped when confirmed is true; deny rules and read-only mode always apply.
+// Commands whose verb is hidden behind an unknown flag are denied whenever
+// the policy has rules, since the rules can't be matched against them.
+func CheckPolicy(policy config.Policy, command string, confirmed bool) error {
+	tokens, ok := policyTokens(command)
+
+	if policy.ReadOnly && IsModifyingCommand(command) {
+		return &PolicyError{Command: command, Rule: "read-only"}
+	}
+	if !ok && (len(policy.Deny) > 0 || len(policy.Confirm) > 0) {
+		return &PolicyError{Command: command, Rule: unknownFlagRule}
+	}
+	for _, rule := range policy.Deny {
+		if matchesRule(tokens, rule) {
+			return &PolicyError{Command: command, Rule: rule}
+		}
+	}
+	if confirmed {
+		return nil
+	}
+	for _, rule := range policy.Confirm {
+		if matchesRule(tokens, rule) {
+			return &PolicyError{Command: command, Rule: rule, NeedsConfirmation: true}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package kubectl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+)
+
+func TestIsModifyingCommand(t *testing.T) {
+	tests := map[string]bool{
+		"get pods":                                false,
+		"-n prod get pods":                        false,
+		"describe deploy web":                     false,
+		"logs -f web-0":                           false,
+		"rollout status deploy/web":               true,
+		"certificate approve csr-1":               true,
+		"certificate deny csr-1":                  true,
+		"auth can-i delete pods":                  false,
+		"auth reconcile -f rbac.yaml":             true,
+		"delete pod x":                            true,
+		"-n prod delete pod x":                    true,
+		"--namespace=prod delete pod x":           true,
+		"--context c apply -f y":                  true,
+		"--kubeconfig /tmp/k -n a scale deploy x": true,
+		"-v 6 get pods":                           false,
+		"--request-timeout 5s --context c get ns": false,
+		"run nginx --image=nginx":                 true,
+		"exec -it web-0 -- sh":                    true,
+		"-n prod exec web-0 -- ls":                true,
+		"debug node/worker-0 -it --image=busybox": true,
+		"autoscale deploy web --max=5":            true,
+		"attach web-0":                            true,
+		"port-forward svc/web 8080:80":            true,
+		"":                                        false,
+		"--profile none delete ns prod":           true,
+		"--username bob delete ns prod":           true,
+		"--chunk-size 5 delete ns prod":           true,
+		"--chunk-size=5 delete ns prod":           true,
+		"--profile none get pods":                 false,
+		"--insecure-skip-tls-verify get pods":     false,
+		"-nprod get pods":                         false,
+		"proxy --port 8001":                       true,
+		"krew install ctx":                        true,
+		"config view":                             false,
+		"config use-context other":                true,
+		"auth whoami":                             false,
+		"top pods -A":                             false,
+	}
+	for command, want := range tests {
+		if got := IsModifyingCommand(command); got != want {
+			t.Errorf("IsModifyingCommand(%q) = %v, want %v", command, got, want)
+		}
+	}
+}
+
+func TestReadOnlyPolicy(t *testing.T) {
+	policy := config.Policy{ReadOnly: true}
+	tests := map[string]bool{
+		"get pods -A":                     false,
+		"-n prod get pods":                false,
+		"-n prod delete pod x":            true,
+		"--context c apply -f y":          true,
+		"-n prod exec web-0 -- ls":        true,
+		"run debug --image=busybox":       true,
+		"certificate approve csr-1":       true,
+		"port-forward deploy/web 8080:80": true,
+		"--profile none delete ns prod":   true,
+		"--username bob delete ns prod":   true,
+		"--chunk-size 5 delete ns prod":   true,
+		"proxy --port 8001":               true,
+		"krew install ctx":                true,
+	}
+	for command, denied := range tests {
+		err := CheckPolicy(policy, command, true)
+		if (err != nil) != denied {
+			t.Errorf("CheckPolicy(%q) = %v, want denied %v", command, err, denied)
+		}
+	}
+}
+
+func TestPolicyRulesWithFlags(t *testing.T) {
+	policy := config.Policy{
+		Deny:    []string{"delete ns", "delete namespace kube-system"},
+		Confirm: []string{"scale", "rollout restart"},
+	}
+	tests := map[string]struct {
+		denied, confirm bool
+	}{
+		"delete ns foo":                                {denied: true},
+		"delete -n default ns foo":                     {denied: true},
+		"--context x delete ns foo":                    {denied: true},
+		"delete --grace-period 0 namespace/foo":        {denied: true},
+		"-n kube-system --kubeconfig /tmp/k delete ns": {denied: true},
+		"delete pod foo -n default":                    {},
+		"-n a get ns":                                  {},
+		"scale deploy x --replicas=3":                  {confirm: true},
+		"-n a scale deploy x":                          {confirm: true},
+		"--context c -n a scale --replicas 2 deploy x": {confirm: true},
+		"rollout -n a restart deploy/web":              {confirm: true},
+		"-n a rollout status deploy/web":               {},
+		"--profile none delete ns prod":                {denied: true},
+		"--username bob delete namespace/prod":         {denied: true},
+		"--chunk-size 5 delete ns prod":                {denied: true},
+		"--chunk-size 5 get pods":                      {denied: true},
+		"--profile none scale deploy x":                {confirm: true},
+		"--insecure-skip-tls-verify -n a get ns":       {},
+	}
+	for command, tc := range tests {
+		err := CheckPolicy(policy, command, false)
+		var policyErr *PolicyError
+		switch {
+		case tc.denied && (!errors.As(err, &policyErr) || policyErr.NeedsConfirmation):
+			t.Errorf("CheckPolicy(%q) = %v, want it denied", command, err)
+		case tc.confirm && (!errors.As(err, &policyErr) || !policyErr.NeedsConfirmation):
+			t.Errorf("CheckPolicy(%q) = %v, want a confirmation", command, err)
+		case !tc.denied && !tc.confirm && err != nil:
+			t.Errorf("CheckPolicy(%q) = %v, want it allowed", command, err)
+		}
+	}
+}
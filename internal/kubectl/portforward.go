@@ -0,0 +1,84 @@
+package kubectl
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// forwardedContainers holds the container ports of a pod spec
+type forwardedContainers []struct {
+	Ports []struct {
+		ContainerPort int `json:"containerPort"`
+	} `json:"ports"`
+}
+
+// firstPort returns the first declared container port
+func (c forwardedContainers) firstPort() int {
+	for _, container := range c {
+		for _, port := range container.Ports {
+			return port.ContainerPort
+		}
+	}
+	return 0
+}
+
+// DefaultPort returns the port a port-forward to the object targets: the
+// first port of a service, or the first container port of a pod or of the
+// pods of a workload
+func (e *Executor) DefaultPort(object ObjectRef) (int, error) {
+	var target struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			Ports []struct {
+				Port int `json:"port"`
+			} `json:"ports"`
+			Containers forwardedContainers `json:"containers"`
+			Template   struct {
+				Spec struct {
+					Containers forwardedContainers `json:"containers"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	if err := e.getJSON(&target, append([]string{"get"}, object.Args()...)...); err != nil {
+		return 0, err
+	}
+
+	port := 0
+	switch {
+	case target.Kind == "Service" && len(target.Spec.Ports) > 0:
+		port = target.Spec.Ports[0].Port
+	case target.Kind == "Pod":
+		port = target.Spec.Containers.firstPort()
+	default:
+		port = target.Spec.Template.Spec.Containers.firstPort()
+	}
+	if port == 0 {
+		return 0, fmt.Errorf("%s declares no port to forward", object)
+	}
+	return port, nil
+}
+
+// FreeLocalPort returns a local TCP port nothing listens on
+func FreeLocalPort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free local port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// PortForwardArgs returns the arguments of kubectl port-forward from a local
+// port to a port of the object
+func PortForwardArgs(object ObjectRef, local, remote int) []string {
+	args := append([]string{"port-forward"}, object.Args()...)
+	return append(args, strconv.Itoa(local)+":"+strconv.Itoa(remote))
+}
+
+// IsForwarding reports whether a port-forward output line says the forward is up
+func IsForwarding(line string) bool {
+	return strings.HasPrefix(line, "Forwarding from")
+}
@@ -0,0 +1,146 @@
+package kubectl
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCanceled is returned by Queue.Run when a queued command was canceled before it started
+var ErrCanceled = errors.New("command canceled")
+
+// QueuedCommand describes a command waiting in or running from a cluster queue
+type QueuedCommand struct {
+	ID       int
+	Cluster  string
+	Command  string
+	QueuedAt time.Time
+	Running  bool
+}
+
+// queueEntry is a command in a cluster queue; ready is closed when it may start
+type queueEntry struct {
+	QueuedCommand
+	ready    chan struct{}
+	started  bool
+	canceled bool
+}
+
+// Queue runs commands one at a time per cluster, in the order they were submitted
+type Queue struct {
+	mu      sync.Mutex
+	nextID  int
+	entries map[string][]*queueEntry
+}
+
+// NewQueue creates an empty command queue
+func NewQueue() *Queue {
+	return &Queue{entries: make(map[string][]*queueEntry)}
+}
+
+// Run queues a command for a cluster and blocks until it has run. Commands for
+// the same cluster run sequentially; commands for different clusters run in parallel.
+func (q *Queue) Run(cluster, command string, run func()) error {
+	entry := q.enqueue(cluster, command)
+	<-entry.ready
+
+	q.mu.Lock()
+	if entry.canceled {
+		q.mu.Unlock()
+		return ErrCanceled
+	}
+	entry.Running = true
+	q.mu.Unlock()
+
+	defer q.finish(cluster, entry)
+	run()
+	return nil
+}
+
+// enqueue appends a command to a cluster's queue, starting it immediately if the queue was empty
+func (q *Queue) enqueue(cluster, command string) *queueEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	entry := &queueEntry{
+		QueuedCommand: QueuedCommand{
+			ID:       q.nextID,
+			Cluster:  cluster,
+			Command:  command,
+			QueuedAt: time.Now(),
+		},
+		ready: make(chan struct{}),
+	}
+	q.entries[cluster] = append(q.entries[cluster], entry)
+	q.startNext(cluster)
+	return entry
+}
+
+// startNext lets the command at the head of a cluster queue start; the caller must hold the lock
+func (q *Queue) startNext(cluster string) {
+	if entries := q.entries[cluster]; len(entries) > 0 && !entries[0].started {
+		entries[0].started = true
+		close(entries[0].ready)
+	}
+}
+
+// finish removes a completed entry and starts the next command for the cluster
+func (q *Queue) finish(cluster string, entry *queueEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.remove(cluster, entry)
+	q.startNext(cluster)
+}
+
+// remove deletes an entry from a cluster queue; the caller must hold the lock
+func (q *Queue) remove(cluster string, entry *queueEntry) {
+	entries := q.entries[cluster]
+	for i, e := range entries {
+		if e == entry {
+			q.entries[cluster] = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+	if len(q.entries[cluster]) == 0 {
+		delete(q.entries, cluster)
+	}
+}
+
+// Pending returns the running and queued commands for a cluster in execution order
+func (q *Queue) Pending(cluster string) []QueuedCommand {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var pending []QueuedCommand
+	for _, entry := range q.entries[cluster] {
+		pending = append(pending, entry.QueuedCommand)
+	}
+	return pending
+}
+
+// Cancel removes a queued command before it starts. Running commands can't be canceled.
+func (q *Queue) Cancel(cluster string, id int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, entry := range q.entries[cluster] {
+		if entry.ID != id {
+			continue
+		}
+		if entry.Running {
+			return fmt.Errorf("command %d is already running", id)
+		}
+		entry.canceled = true
+		q.remove(cluster, entry)
+		if !entry.started {
+			// Wake the waiting Run call so it can return ErrCanceled
+			close(entry.ready)
+		}
+		q.startNext(cluster)
+		return nil
+	}
+	return fmt.Errorf("no queued command with id %d", id)
+}
@@ -0,0 +1,290 @@
+package kubectl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RBACResources are the kinds that make up the RBAC of a cluster
+var RBACResources = []string{"roles", "clusterroles", "rolebindings", "clusterrolebindings"}
+
+// RBACSubject is a user, group or service account a binding grants a role to
+type RBACSubject struct {
+	Kind      string `yaml:"kind"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+// String returns the kind and name of the subject, with the namespace of
+// service accounts
+func (s RBACSubject) String() string {
+	if s.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", s.Kind, s.Namespace, s.Name)
+	}
+	return s.Kind + " " + s.Name
+}
+
+// RBACRule is a policy rule of a role
+type RBACRule struct {
+	APIGroups       []string `yaml:"apiGroups"`
+	Resources       []string `yaml:"resources"`
+	ResourceNames   []string `yaml:"resourceNames"`
+	NonResourceURLs []string `yaml:"nonResourceURLs"`
+	Verbs           []string `yaml:"verbs"`
+}
+
+// String returns the verbs and the resources, or URLs, the rule allows
+func (r RBACRule) String() string {
+	targets := r.NonResourceURLs
+	if len(targets) == 0 {
+		for _, resource := range r.Resources {
+			for _, group := range r.APIGroups {
+				if group != "" {
+					resource += "." + group
+				}
+				targets = append(targets, resource)
+			}
+			if len(r.APIGroups) == 0 {
+				targets = append(targets, resource)
+			}
+		}
+	}
+	rule := strings.Join(r.Verbs, ",") + " " + strings.Join(targets, ",")
+	if len(r.ResourceNames) > 0 {
+		rule += " (" + strings.Join(r.ResourceNames, ",") + ")"
+	}
+	return rule
+}
+
+// Wildcard reports whether the rule allows every verb, resource or API group
+func (r RBACRule) Wildcard() bool {
+	for _, values := range [][]string{r.Verbs, r.Resources, r.APIGroups, r.NonResourceURLs} {
+		for _, value := range values {
+			if value == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// everything reports whether the rule allows every verb on every resource
+func (r RBACRule) everything() bool {
+	return contains(r.Verbs, "*") && contains(r.Resources, "*") && contains(r.APIGroups, "*")
+}
+
+// RBACGrant is a role granted to a subject by a binding, in a namespace or,
+// for ClusterRoleBindings, cluster-wide
+type RBACGrant struct {
+	Subject   RBACSubject
+	Namespace string // empty for cluster-wide grants
+	Binding   string // kind/name of the binding
+	Role      string // kind/name of the role
+	Rules     []RBACRule
+}
+
+// Scope returns the namespace the grant applies in, or "cluster"
+func (g RBACGrant) Scope() string {
+	if g.Namespace == "" {
+		return "cluster"
+	}
+	return g.Namespace
+}
+
+// key identifies a grant across snapshots
+func (g RBACGrant) key() string {
+	return strings.Join([]string{g.Subject.String(), g.Namespace, g.Binding, g.Role}, "|")
+}
+
+// ClusterAdmin reports whether the grant allows everything cluster-wide
+func (g RBACGrant) ClusterAdmin() bool {
+	if g.Namespace != "" {
+		return false
+	}
+	if g.Role == "ClusterRole/cluster-admin" {
+		return true
+	}
+	for _, rule := range g.Rules {
+		if rule.everything() {
+			return true
+		}
+	}
+	return false
+}
+
+// Wildcard reports whether any rule of the grant uses a wildcard
+func (g RBACGrant) Wildcard() bool {
+	for _, rule := range g.Rules {
+		if rule.Wildcard() {
+			return true
+		}
+	}
+	return false
+}
+
+// System reports whether the grant is one Kubernetes sets up itself, by its
+// system: subject or role
+func (g RBACGrant) System() bool {
+	role := g.Role[strings.Index(g.Role, "/")+1:]
+	return strings.HasPrefix(g.Subject.Name, "system:") || strings.HasPrefix(role, "system:")
+}
+
+// rbacObject holds the fields of roles and bindings
+type rbacObject struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Rules    []RBACRule    `yaml:"rules"`
+	Subjects []RBACSubject `yaml:"subjects"`
+	RoleRef  struct {
+		Kind string `yaml:"kind"`
+		Name string `yaml:"name"`
+	} `yaml:"roleRef"`
+}
+
+// ParseRBAC reads the grants from kubectl get -o yaml or -o json output of
+// roles, cluster roles and their bindings, which may be spread over several
+// outputs such as the files of a Git export. Grants are sorted by subject.
+func ParseRBAC(outputs ...string) ([]RBACGrant, error) {
+	var objects []rbacObject
+	for _, output := range outputs {
+		if strings.TrimSpace(output) == "" {
+			continue
+		}
+		var list struct {
+			Kind  string       `yaml:"kind"`
+			Items []rbacObject `yaml:"items"`
+		}
+		if err := yaml.Unmarshal([]byte(output), &list); err != nil {
+			return nil, fmt.Errorf("failed to parse RBAC: %v", err)
+		}
+		objects = append(objects, list.Items...)
+	}
+
+	roles := map[string][]RBACRule{}
+	for _, object := range objects {
+		switch object.Kind {
+		case "Role":
+			roles[object.Metadata.Namespace+"/Role/"+object.Metadata.Name] = object.Rules
+		case "ClusterRole":
+			roles["ClusterRole/"+object.Metadata.Name] = object.Rules
+		}
+	}
+
+	var grants []RBACGrant
+	for _, object := range objects {
+		if object.Kind != "RoleBinding" && object.Kind != "ClusterRoleBinding" {
+			continue
+		}
+		role := object.RoleRef.Kind + "/" + object.RoleRef.Name
+		rules := roles[role]
+		if object.RoleRef.Kind == "Role" {
+			rules = roles[object.Metadata.Namespace+"/"+role]
+		}
+		for _, subject := range object.Subjects {
+			grants = append(grants, RBACGrant{
+				Subject:   subject,
+				Namespace: object.Metadata.Namespace,
+				Binding:   object.Kind + "/" + object.Metadata.Name,
+				Role:      role,
+				Rules:     rules,
+			})
+		}
+	}
+	sort.SliceStable(grants, func(i, j int) bool {
+		return grants[i].key() < grants[j].key()
+	})
+	return grants, nil
+}
+
+// ListRBAC returns the grants of every role and binding in the cluster
+func (e *Executor) ListRBAC() ([]RBACGrant, error) {
+	output, err := e.Execute("get", strings.Join(RBACResources, ","), "-A", "-o", "json")
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(output))
+	}
+	return ParseRBAC(output)
+}
+
+// RBACDiff is how the grants of a cluster changed between two snapshots.
+// Changed holds the new version of grants whose role's rules changed.
+type RBACDiff struct {
+	Added   []RBACGrant
+	Removed []RBACGrant
+	Changed []RBACGrant
+	before  map[string]RBACGrant
+}
+
+// Empty reports whether the snapshots grant the same permissions
+func (d RBACDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffRBAC compares two snapshots of the grants of a cluster
+func DiffRBAC(before, after []RBACGrant) RBACDiff {
+	diff := RBACDiff{before: map[string]RBACGrant{}}
+	for _, grant := range before {
+		diff.before[grant.key()] = grant
+	}
+	current := map[string]bool{}
+	for _, grant := range after {
+		current[grant.key()] = true
+		old, ok := diff.before[grant.key()]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, grant)
+		case !rulesEqual(old.Rules, grant.Rules):
+			diff.Changed = append(diff.Changed, grant)
+		}
+	}
+	for _, grant := range before {
+		if !current[grant.key()] {
+			diff.Removed = append(diff.Removed, grant)
+		}
+	}
+	return diff
+}
+
+// Warnings describes the added and changed grants that newly allow
+// everything cluster-wide or use wildcards
+func (d RBACDiff) Warnings() []string {
+	var warnings []string
+	for _, grant := range append(append([]RBACGrant{}, d.Added...), d.Changed...) {
+		old, existed := d.before[grant.key()]
+		switch {
+		case grant.ClusterAdmin() && !(existed && old.ClusterAdmin()):
+			warnings = append(warnings, fmt.Sprintf("%s was granted cluster-admin permissions by %s", grant.Subject, grant.Binding))
+		case grant.Wildcard() && !(existed && old.Wildcard()):
+			warnings = append(warnings, fmt.Sprintf("%s was granted wildcard permissions in %s by %s (%s)", grant.Subject, grant.Scope(), grant.Binding, grant.Role))
+		}
+	}
+	return warnings
+}
+
+// rulesEqual reports whether two roles have the same rules
+func rulesEqual(a, b []RBACRule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Relations of an object to the objects around it
+const (
+	RelationOwner     = "Owned by"
+	RelationOwns      = "Owns"
+	RelationEndpoints = "Endpoints"
+	RelationSelects   = "Selects"
+	RelationEvents    = "Events"
+)
+
+// RelationOrder is the order relations are listed in
+var RelationOrder = []string{RelationOwner, RelationOwns, RelationEndpoints, RelationSelects, RelationEvents}
+
+// ownedKinds maps a kind to the resource type of the objects it owns
+var ownedKinds = map[string]string{
+	"deployment":  "replicasets",
+	"replicaset":  "pods",
+	"statefulset": "pods",
+	"daemonset":   "pods",
+	"job":         "pods",
+	"cronjob":     "jobs",
+}
+
+// RelatedObject is an object related to another one, with a short status
+type RelatedObject struct {
+	ObjectRef
+	Relation string
+	Detail   string
+}
+
+// graphObject holds the fields of an object that relate it to others
+type graphObject struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		UID             string `json:"uid"`
+		OwnerReferences []struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+			UID  string `json:"uid"`
+		} `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec struct {
+		// A label map for services, a LabelSelector for workloads
+		Selector json.RawMessage `json:"selector"`
+	} `json:"spec"`
+	Status struct {
+		Phase    string `json:"phase"`
+		Replicas *int   `json:"replicas"`
+		Ready    *int   `json:"readyReplicas"`
+	} `json:"status"`
+}
+
+// detail returns a short status of the object
+func (o graphObject) detail() string {
+	switch {
+	case o.Status.Phase != "":
+		return o.Status.Phase
+	case o.Status.Replicas != nil:
+		ready := 0
+		if o.Status.Ready != nil {
+			ready = *o.Status.Ready
+		}
+		return fmt.Sprintf("%d/%d ready", ready, *o.Status.Replicas)
+	}
+	return ""
+}
+
+// labelSelector returns the kubectl -l form of a service's selector, or
+// false for selectors that aren't a plain label map
+func (o graphObject) labelSelector() (string, bool) {
+	var labels map[string]string
+	if len(o.Spec.Selector) == 0 || json.Unmarshal(o.Spec.Selector, &labels) != nil || len(labels) == 0 {
+		return "", false
+	}
+	var pairs []string
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ","), true
+}
+
+// Related returns the objects around an object: its owners, the objects it
+// owns, the endpoints and pods a service selects, and its events
+func (e *Executor) Related(object ObjectRef) ([]RelatedObject, error) {
+	var target graphObject
+	if err := e.getJSON(&target, append([]string{"get"}, object.Args()...)...); err != nil {
+		return nil, err
+	}
+	namespace := target.Metadata.Namespace
+	inNamespace := func(kind, name string) ObjectRef {
+		return ObjectRef{Kind: kind, Name: name, Namespace: namespace}
+	}
+
+	var related []RelatedObject
+	for _, owner := range target.Metadata.OwnerReferences {
+		related = append(related, RelatedObject{ObjectRef: inNamespace(owner.Kind, owner.Name), Relation: RelationOwner})
+	}
+
+	kind := strings.ToLower(target.Kind)
+	if resource, ok := ownedKinds[kind]; ok {
+		var list struct{ Items []graphObject }
+		if err := e.getJSON(&list, "get", resource, "-n", namespace); err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			for _, owner := range item.Metadata.OwnerReferences {
+				if owner.UID == target.Metadata.UID {
+					related = append(related, RelatedObject{
+						ObjectRef: inNamespace(item.Kind, item.Metadata.Name),
+						Relation:  RelationOwns,
+						Detail:    item.detail(),
+					})
+				}
+			}
+		}
+	}
+
+	if kind == "service" {
+		endpoints, err := e.endpointsOf(target.Metadata.Name, namespace)
+		if err != nil {
+			return nil, err
+		}
+		related = append(related, RelatedObject{ObjectRef: inNamespace("endpoints", target.Metadata.Name), Relation: RelationEndpoints, Detail: endpoints})
+
+		if selector, ok := target.labelSelector(); ok {
+			var pods struct{ Items []graphObject }
+			if err := e.getJSON(&pods, "get", "pods", "-n", namespace, "-l", selector); err != nil {
+				return nil, err
+			}
+			for _, pod := range pods.Items {
+				related = append(related, RelatedObject{ObjectRef: inNamespace("Pod", pod.Metadata.Name), Relation: RelationSelects, Detail: pod.detail()})
+			}
+		}
+	}
+
+	events, err := e.eventsOf(target.Metadata.UID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return append(related, events...), nil
+}
+
+// endpointsOf summarizes the addresses of a service's endpoints
+func (e *Executor) endpointsOf(name, namespace string) (string, error) {
+	var endpoints struct {
+		Subsets []struct {
+			Addresses []struct {
+				IP string `json:"ip"`
+			} `json:"addresses"`
+			NotReadyAddresses []json.RawMessage `json:"notReadyAddresses"`
+			Ports             []struct {
+				Port int `json:"port"`
+			} `json:"ports"`
+		} `json:"subsets"`
+	}
+	if err := e.getJSON(&endpoints, "get", "endpoints", name, "-n", namespace); err != nil {
+		return "", err
+	}
+
+	var addresses []string
+	notReady := 0
+	for _, subset := range endpoints.Subsets {
+		notReady += len(subset.NotReadyAddresses)
+		for _, address := range subset.Addresses {
+			for _, port := range subset.Ports {
+				addresses = append(addresses, fmt.Sprintf("%s:%d", address.IP, port.Port))
+			}
+		}
+	}
+	detail := "no ready addresses"
+	if len(addresses) > 0 {
+		detail = strings.Join(addresses, ", ")
+	}
+	if notReady > 0 {
+		detail += fmt.Sprintf(" (%d not ready)", notReady)
+	}
+	return detail, nil
+}
+
+// eventsOf returns the events about the object with the UID, oldest first.
+// Events of cluster-scoped objects are looked up in every namespace.
+func (e *Executor) eventsOf(uid, namespace string) ([]RelatedObject, error) {
+	args := []string{"get", "events", "--field-selector", "involvedObject.uid=" + uid}
+	if namespace == "" {
+		args = append(args, "-A")
+	} else {
+		args = append(args, "-n", namespace)
+	}
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Type          string `json:"type"`
+			Reason        string `json:"reason"`
+			Message       string `json:"message"`
+			LastTimestamp string `json:"lastTimestamp"`
+		} `json:"items"`
+	}
+	if err := e.getJSON(&list, args...); err != nil {
+		return nil, err
+	}
+	// RFC 3339 timestamps sort by time
+	sort.SliceStable(list.Items, func(i, j int) bool {
+		return list.Items[i].LastTimestamp < list.Items[j].LastTimestamp
+	})
+
+	var events []RelatedObject
+	for _, item := range list.Items {
+		events = append(events, RelatedObject{
+			ObjectRef: ObjectRef{Kind: "event", Name: item.Metadata.Name, Namespace: item.Metadata.Namespace},
+			Relation:  RelationEvents,
+			Detail:    fmt.Sprintf("%s %s: %s", item.Type, item.Reason, strings.TrimSpace(item.Message)),
+		})
+	}
+	return events, nil
+}
+
+// getJSON runs a kubectl get with JSON output and decodes it into v
+func (e *Executor) getJSON(v interface{}, args ...string) error {
+	output, err := e.Execute(append(args, "-o", "json")...)
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(output))
+	}
+	if err := json.Unmarshal([]byte(output), v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", strings.Join(args, " "), err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// RolloutStatus is the progress of a deployment or daemonset rollout
+type RolloutStatus struct {
+	Desired   int
+	Updated   int
+	Ready     int
+	Available int
+	// Old is the number of replicas of previous revisions still running
+	Old int
+	// Observed is false until the controller has seen the latest spec
+	Observed bool
+	// Failed is set when the rollout exceeded its progress deadline
+	Failed  bool
+	Message string
+}
+
+// Complete reports whether every replica runs the new revision and is available
+func (s RolloutStatus) Complete() bool {
+	return s.Observed && !s.Failed && s.Updated == s.Desired && s.Available == s.Desired && s.Old == 0
+}
+
+// Progress returns the fraction of desired replicas that are updated and available
+func (s RolloutStatus) Progress() float64 {
+	if s.Desired == 0 {
+		if s.Observed {
+			return 1
+		}
+		return 0
+	}
+	done := s.Updated
+	if s.Available < done {
+		done = s.Available
+	}
+	return float64(done) / float64(s.Desired)
+}
+
+// rolloutObject holds the fields of deployments and daemonsets needed to follow a rollout
+type rolloutObject struct {
+	Metadata struct {
+		Generation int64 `json:"generation"`
+	} `json:"metadata"`
+	Spec struct {
+		Replicas *int `json:"replicas"`
+	} `json:"spec"`
+	Status struct {
+		ObservedGeneration int64 `json:"observedGeneration"`
+		// Deployments
+		Replicas          int `json:"replicas"`
+		UpdatedReplicas   int `json:"updatedReplicas"`
+		ReadyReplicas     int `json:"readyReplicas"`
+		AvailableReplicas int `json:"availableReplicas"`
+		// DaemonSets
+		DesiredNumberScheduled int `json:"desiredNumberScheduled"`
+		UpdatedNumberScheduled int `json:"updatedNumberScheduled"`
+		NumberReady            int `json:"numberReady"`
+		NumberAvailable        int `json:"numberAvailable"`
+		Conditions             []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// IsRolloutKind reports whether kubectl rollout supports a resource type
+func IsRolloutKind(kind string) bool {
+	switch strings.ToLower(kind) {
+	case "deploy", "deployment", "deployments", "ds", "daemonset", "daemonsets":
+		return true
+	}
+	return false
+}
+
+// ParseObjectRef reads the object a command operates on from its arguments,
+// either as kind/name or as kind name
+func ParseObjectRef(parts []string) (ObjectRef, bool) {
+	args, namespace, ok := parseArgs(parts)
+	if !ok || len(args) == 0 {
+		return ObjectRef{}, false
+	}
+	if kind, name, found := strings.Cut(args[0], "/"); found {
+		return ObjectRef{Kind: kind, Name: name, Namespace: namespace}, name != ""
+	}
+	if len(args) < 2 {
+		return ObjectRef{}, false
+	}
+	return ObjectRef{Kind: args[0], Name: args[1], Namespace: namespace}, true
+}
+
+// ParseRolloutStatus reads the rollout progress from the JSON of a deployment or daemonset
+func ParseRolloutStatus(data string) (RolloutStatus, error) {
+	var object rolloutObject
+	if err := json.Unmarshal([]byte(data), &object); err != nil {
+		return RolloutStatus{}, fmt.Errorf("failed to parse rollout status: %v", err)
+	}
+
+	s := object.Status
+	status := RolloutStatus{Observed: s.ObservedGeneration >= object.Metadata.Generation}
+	if s.DesiredNumberScheduled > 0 || s.UpdatedNumberScheduled > 0 {
+		status.Desired = s.DesiredNumberScheduled
+		status.Updated = s.UpdatedNumberScheduled
+		status.Ready = s.NumberReady
+		status.Available = s.NumberAvailable
+	} else {
+		status.Desired = 1
+		if object.Spec.Replicas != nil {
+			status.Desired = *object.Spec.Replicas
+		}
+		status.Updated = s.UpdatedReplicas
+		status.Ready = s.ReadyReplicas
+		status.Available = s.AvailableReplicas
+		if s.Replicas > s.UpdatedReplicas {
+			status.Old = s.Replicas - s.UpdatedReplicas
+		}
+	}
+
+	for _, condition := range s.Conditions {
+		if condition.Type == "Progressing" && condition.Reason == "ProgressDeadlineExceeded" {
+			status.Failed = true
+			status.Message = condition.Message
+		}
+	}
+	return status, nil
+}
+
+// RolloutStatus returns the rollout progress of a deployment or daemonset
+func (e *Executor) RolloutStatus(object ObjectRef) (RolloutStatus, error) {
+	args := append([]string{"get"}, object.Args()...)
+	output, err := e.Execute(append(args, "-o", "json")...)
+	if err != nil {
+		return RolloutStatus{}, fmt.Errorf("%v: %s", err, strings.TrimSpace(output))
+	}
+	return ParseRolloutStatus(output)
+}
+
+// RolloutHistory returns the revision history of an object
+func (e *Executor) RolloutHistory(object ObjectRef) (string, error) {
+	args := append([]string{"rollout", "history"}, object.Args()...)
+	output, err := e.Execute(args...)
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(output))
+	}
+	return output, nil
+}
@@ -0,0 +1,59 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DeploymentReplicas is the replica count of a deployment
+type DeploymentReplicas struct {
+	ObjectRef
+	Desired int
+	Ready   int
+}
+
+// ParseDeploymentList reads replica counts from the JSON of kubectl get deployments
+func ParseDeploymentList(data string) ([]DeploymentReplicas, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+			Spec struct {
+				Replicas *int `json:"replicas"`
+			} `json:"spec"`
+			Status struct {
+				ReadyReplicas int `json:"readyReplicas"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse deployments: %v", err)
+	}
+
+	var deployments []DeploymentReplicas
+	for _, item := range list.Items {
+		deployment := DeploymentReplicas{
+			ObjectRef: ObjectRef{Kind: "deployment", Name: item.Metadata.Name, Namespace: item.Metadata.Namespace},
+			Desired:   1,
+			Ready:     item.Status.ReadyReplicas,
+		}
+		if item.Spec.Replicas != nil {
+			deployment.Desired = *item.Spec.Replicas
+		}
+		deployments = append(deployments, deployment)
+	}
+	return deployments, nil
+}
+
+// ListDeployments returns the deployments selected by namespace flags such as -n or -A
+func (e *Executor) ListDeployments(flags ...string) ([]DeploymentReplicas, error) {
+	args := append([]string{"get", "deployments", "-o", "json"}, flags...)
+	output, err := e.Execute(args...)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(output))
+	}
+	return ParseDeploymentList(output)
+}
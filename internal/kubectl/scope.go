@@ -0,0 +1,238 @@
+package kubectl
+
+import (
+	"strings"
+)
+
+// ResourceScope is the subset of exported resources a command affects
+type ResourceScope struct {
+	Resources []string // export names, e.g. "deployments"
+	Namespace string   // empty means all namespaces
+}
+
+// clusterScopedResources are exported resources that don't live in a namespace
+var clusterScopedResources = map[string]bool{
+	"namespaces":          true,
+	"persistentvolumes":   true,
+	"clusterroles":        true,
+	"clusterrolebindings": true,
+}
+
+// valueFlags are flags whose value may follow as a separate argument
+var valueFlags = map[string]bool{
+	"-l": true, "--selector": true,
+	"-o": true, "--output": true,
+	"-c": true, "--container": true,
+	"-p": true, "--patch": true,
+	"--type": true, "--field-selector": true,
+	"--port": true, "--target-port": true,
+	"--name": true, "--image": true,
+	"--replicas": true, "--timeout": true,
+	"--grace-period": true, "--from-literal": true,
+	"--from-file": true, "--from-env-file": true,
+	"--context": true, "--kubeconfig": true,
+	"--cluster": true, "--user": true,
+	"-s": true, "--server": true,
+	"--token": true, "--as": true,
+	"--as-group": true, "--as-uid": true,
+	"--request-timeout": true, "--cache-dir": true,
+	"--certificate-authority": true, "--client-certificate": true,
+	"--client-key": true, "--tls-server-name": true,
+	"-v": true, "--v": true,
+	"--username": true, "--password": true,
+	"--profile": true, "--profile-output": true,
+	"--vmodule": true, "--log-dir": true,
+	"--log-file": true, "--log-file-max-size": true,
+	"--log-flush-frequency": true, "--stderrthreshold": true,
+	"--chunk-size": true, "--raw": true,
+	"--sort-by": true, "--template": true,
+	"--since": true, "--since-time": true,
+	"--tail": true, "-L": true, "--label-columns": true,
+	"--subresource": true,
+}
+
+// globalFlags are the kubectl flags that may come before the verb, mapped to
+// whether they take a value that may follow as a separate argument
+var globalFlags = map[string]bool{
+	"-n": true, "--namespace": true,
+	"--context": true, "--kubeconfig": true,
+	"--cluster": true, "--user": true,
+	"-s": true, "--server": true,
+	"--token": true, "--username": true, "--password": true,
+	"--as": true, "--as-group": true, "--as-uid": true,
+	"--request-timeout": true, "--cache-dir": true,
+	"--certificate-authority": true, "--client-certificate": true,
+	"--client-key": true, "--tls-server-name": true,
+	"--profile": true, "--profile-output": true,
+	"-v": true, "--v": true, "--vmodule": true,
+	"--log-dir": true, "--log-file": true, "--log-file-max-size": true,
+	"--log-flush-frequency": true, "--stderrthreshold": true,
+
+	"--insecure-skip-tls-verify": false, "--match-server-version": false,
+	"--warnings-as-errors": false, "--disable-compression": false,
+	"--alsologtostderr": false, "--logtostderr": false,
+	"--add-dir-header": false, "--one-output": false,
+	"--skip-headers": false, "--skip-log-headers": false,
+	"-h": false, "--help": false,
+}
+
+// IsClusterScoped reports whether an exported resource type is cluster-scoped
+func IsClusterScoped(resource string) bool {
+	return clusterScopedResources[resource]
+}
+
+// AffectedResources determines which exported resources a modifying command
+// touches. It returns false when the scope can't be determined from the command
+// line, for example with apply -f, and everything should be re-exported.
+func AffectedResources(command string) (ResourceScope, bool) {
+	args, namespace, ok := parseArgs(strings.Fields(command))
+	if !ok {
+		// Manifests may contain any kind
+		return ResourceScope{}, false
+	}
+	if len(args) == 0 {
+		return ResourceScope{}, false
+	}
+	verb := args[0]
+	args = args[1:]
+	scope := ResourceScope{Namespace: namespace}
+
+	switch verb {
+	case "cp", "drain", "cordon", "uncordon", "taint", "run", "exec", "debug",
+		"attach", "port-forward", "certificate":
+		// These change pods, nodes and certificate requests, which aren't exported
+		return scope, true
+	case "expose":
+		scope.Resources = []string{"services"}
+		return scope, true
+	case "autoscale":
+		scope.Resources = []string{"horizontalpodautoscalers"}
+		return scope, true
+	case "rollout", "set":
+		// Skip the subcommand, e.g. rollout restart or set image
+		if len(args) > 0 {
+			args = args[1:]
+		}
+	}
+
+	if len(args) == 0 {
+		return ResourceScope{}, false
+	}
+
+	seen := make(map[string]bool)
+	for _, kind := range strings.Split(args[0], ",") {
+		// type/name form
+		if slash := strings.Index(kind, "/"); slash >= 0 {
+			kind = kind[:slash]
+		}
+		// kind.group form, e.g. deployments.apps
+		if dot := strings.Index(kind, "."); dot >= 0 {
+			kind = kind[:dot]
+		}
+		kind = strings.ToLower(kind)
+
+		if kind == "all" {
+			return ResourceScope{}, false
+		}
+		if verb == "delete" && (kind == "ns" || kind == "namespace" || kind == "namespaces") {
+			// Deleting a namespace removes everything in it
+			return ResourceScope{}, false
+		}
+
+		if alias, ok := resourceAliases[kind]; ok {
+			kind = alias
+		}
+		resource := exportName(kind)
+		if resource == "" || seen[resource] {
+			continue
+		}
+		seen[resource] = true
+		scope.Resources = append(scope.Resources, resource)
+	}
+
+	return scope, true
+}
+
+// parseArgs splits command arguments into positional arguments and the
+// namespace. It returns false when the command reads manifests with -f or -k.
+func parseArgs(parts []string) (args []string, namespace string, ok bool) {
+	args, namespace, manifests := splitArgs(parts)
+	if manifests {
+		return nil, "", false
+	}
+	return args, namespace, true
+}
+
+// commandVerb returns the verb of a command and the positional arguments
+// after it, skipping the global flags before the verb. It returns false when
+// a flag before the verb isn't a known global flag, since its value can't be
+// told apart from the verb.
+func commandVerb(parts []string) (verb string, args []string, ok bool) {
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		if !strings.HasPrefix(part, "-") {
+			args, _, _ := splitArgs(parts[i:])
+			return args[0], args[1:], true
+		}
+		name, _, attached := strings.Cut(part, "=")
+		if !attached && !strings.HasPrefix(part, "--") && len(part) > 2 {
+			// Short flag with its value attached, e.g. -nprod or -v6
+			name, attached = part[:2], true
+		}
+		takesValue, known := globalFlags[name]
+		switch {
+		case attached && strings.HasPrefix(name, "--"):
+			// --flag=value can't swallow the verb, known or not
+		case !known || (attached && !takesValue):
+			return "", nil, false
+		case takesValue && !attached:
+			i++
+		}
+	}
+	return "", nil, true
+}
+
+// splitArgs splits command arguments into positional arguments and the
+// namespace, skipping flags along with the values of those that take one.
+// It also reports whether the command reads manifests with -f or -k.
+func splitArgs(parts []string) (args []string, namespace string, manifests bool) {
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		switch {
+		case part == "-f" || part == "-k" || part == "--filename" || part == "--kustomize":
+			manifests = true
+			i++
+		case strings.HasPrefix(part, "--filename=") || strings.HasPrefix(part, "--kustomize=") ||
+			strings.HasPrefix(part, "-f=") || strings.HasPrefix(part, "-k="):
+			manifests = true
+		case part == "-A" || part == "--all-namespaces":
+			namespace = ""
+		case part == "-n" || part == "--namespace":
+			if i+1 < len(parts) {
+				namespace = parts[i+1]
+				i++
+			}
+		case strings.HasPrefix(part, "--namespace="):
+			namespace = strings.TrimPrefix(part, "--namespace=")
+		case strings.HasPrefix(part, "-n") && len(part) > 2 && !strings.HasPrefix(part, "--"):
+			namespace = strings.TrimPrefix(strings.TrimPrefix(part, "-n"), "=")
+		case valueFlags[part]:
+			i++
+		case strings.HasPrefix(part, "-"):
+			// Boolean flag or --flag=value
+		default:
+			args = append(args, part)
+		}
+	}
+	return args, namespace, manifests
+}
+
+// exportName returns the export name for a plural kind, or "" if it isn't exported
+func exportName(kind string) string {
+	for _, resource := range GetResourcesForExport() {
+		if strings.EqualFold(resource, kind) {
+			return resource
+		}
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package kubectl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// maxStreamLine is the longest output line a stream reads; a longer one ends it with an error
+const maxStreamLine = 1024 * 1024
+
+// Stream is a long-running kubectl command, such as logs -f, whose output is
+// read line by line as it arrives
+type Stream struct {
+	cmd      *exec.Cmd
+	lines    chan string
+	err      error
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// Stream starts a kubectl command that keeps running until it ends on its own
+// or is stopped. The policy is checked like for Execute, but there is no
+// timeout. Standard output and error are merged into Lines.
+func (e *Executor) Stream(args ...string) (*Stream, error) {
+	if e.cluster == nil {
+		return nil, fmt.Errorf("no cluster configured")
+	}
+	if err := CheckPolicy(e.policy, strings.Join(args, " "), false); err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	cmd := exec.Command("kubectl", append(e.baseArgs(), args...)...)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start kubectl: %w", err)
+	}
+
+	s := &Stream{cmd: cmd, lines: make(chan string, 64), stopped: make(chan struct{})}
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			err = fmt.Errorf("kubectl command failed: %w", err)
+		}
+		writer.CloseWithError(err)
+	}()
+	go func() {
+		defer close(s.lines)
+		defer reader.Close()
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+		for scanner.Scan() {
+			select {
+			case s.lines <- scanner.Text():
+			case <-s.stopped:
+				// Nobody reads the rest of a stopped stream
+				return
+			}
+		}
+		s.err = scanner.Err()
+	}()
+	return s, nil
+}
+
+// Lines returns the output lines of the command. The channel is closed once
+// the command has ended.
+func (s *Stream) Lines() <-chan string {
+	return s.lines
+}
+
+// Err returns why the command ended, once Lines is closed. It is nil when the
+// command exited successfully.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Stop ends the command and discards the output not read yet. Lines is
+// closed soon after.
+func (s *Stream) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+		s.cmd.Process.Kill()
+	})
+}
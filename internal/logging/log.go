@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/RaymondAkachi/custom-kub-cli/k8s/clustersetup"
+)
+
+// Entry is a single structured log record
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Source  string    `json:"source"`
+	Cluster string    `json:"cluster,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Log keeps recent entries in memory for the TUI log pane and appends every
+// entry to a JSON lines file
+type Log struct {
+	path    string
+	limit   int
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewLog creates a log backed by the file at path that keeps the last limit entries in memory
+func NewLog(path string, limit int) *Log {
+	return &Log{path: path, limit: limit}
+}
+
+// Path returns the location of the log file
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Record stores an entry in memory and appends it to the log file
+func (l *Log) Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > l.limit {
+		l.entries = l.entries[len(l.entries)-l.limit:]
+	}
+	return l.write(entry)
+}
+
+// write appends an entry to the log file; the caller must hold the lock
+func (l *Log) write(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal log entry: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %v", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write log entry: %v", err)
+	}
+	return nil
+}
+
+// Recent returns up to n of the most recent entries, oldest first
+func (l *Log) Recent(n int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if n <= 0 || n > len(l.entries) {
+		n = len(l.entries)
+	}
+	recent := make([]Entry, n)
+	copy(recent, l.entries[len(l.entries)-n:])
+	return recent
+}
+
+// ClusterSetupLogger returns a clustersetup.Logger that records setup logs for a
+// cluster here instead of printing them over the TUI
+func (l *Log) ClusterSetupLogger(cluster string) clustersetup.Logger {
+	return clustersetup.NewFuncLogger(func(level clustersetup.LogLevel, msg string) {
+		l.Record(Entry{
+			Level:   string(level),
+			Source:  "clustersetup",
+			Cluster: cluster,
+			Message: msg,
+		})
+	})
+}
@@ -0,0 +1,159 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+)
+
+// Prefix is the executable name prefix that marks a terminal plugin
+const Prefix = "kub-cli-"
+
+// Plugin represents an executable terminal plugin
+type Plugin struct {
+	Name string // Command name used in the terminal
+	Path string // Absolute path to the executable
+}
+
+// Manager discovers and runs exec-based terminal plugins. Discover may run
+// in the background while plugins are looked up.
+type Manager struct {
+	pluginDir string
+
+	mu      sync.RWMutex
+	plugins map[string]Plugin
+}
+
+// NewManager creates a new plugin manager that searches pluginDir before PATH
+func NewManager(pluginDir string) *Manager {
+	return &Manager{
+		pluginDir: pluginDir,
+		plugins:   make(map[string]Plugin),
+	}
+}
+
+// Discover scans the plugin directory and PATH for plugin executables.
+// Plugins found in the plugin directory take precedence over PATH.
+func (m *Manager) Discover() error {
+	found := make(map[string]Plugin)
+
+	dirs := []string{}
+	if m.pluginDir != "" {
+		dirs = append(dirs, m.pluginDir)
+	}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok || entry.IsDir() {
+				continue
+			}
+			if _, exists := found[name]; exists {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			found[name] = Plugin{Name: name, Path: path}
+		}
+	}
+
+	m.mu.Lock()
+	m.plugins = found
+	m.mu.Unlock()
+	return nil
+}
+
+// pluginName extracts the command name from a plugin file name
+func pluginName(fileName string) (string, bool) {
+	if !strings.HasPrefix(fileName, Prefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(fileName, Prefix)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name, name != ""
+}
+
+// isExecutable checks whether a file can be executed
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(path))
+		return ext == ".exe" || ext == ".bat" || ext == ".cmd"
+	}
+	return info.Mode()&0111 != 0
+}
+
+// Get returns the plugin registered under name
+func (m *Manager) Get(name string) (*Plugin, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	plugin, ok := m.plugins[name]
+	if !ok {
+		return nil, false
+	}
+	return &plugin, true
+}
+
+// List returns all discovered plugins sorted by name
+func (m *Manager) List() []Plugin {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	plugins := make([]Plugin, 0, len(m.plugins))
+	for _, plugin := range m.plugins {
+		plugins = append(plugins, plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// Run executes a plugin against the given cluster and returns its combined output.
+// The cluster's kubeconfig and the namespace are passed via environment variables.
+func (m *Manager) Run(name string, args []string, cluster *config.ClusterInfo, namespace string) (string, error) {
+	plugin, ok := m.Get(name)
+	if !ok {
+		return "", fmt.Errorf("plugin '%s' not found", name)
+	}
+
+	cmd := exec.Command(plugin.Path, args...)
+	cmd.Env = append(os.Environ(), Environment(cluster, namespace)...)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("plugin '%s' failed: %v", name, err)
+	}
+
+	return string(output), nil
+}
+
+// Environment returns the environment variables describing the selected cluster
+func Environment(cluster *config.ClusterInfo, namespace string) []string {
+	if namespace == "" {
+		namespace = "default"
+	}
+	return []string{
+		"KUBECONFIG=" + cluster.ConfigPath,
+		"KUB_CLI_KUBECONFIG=" + cluster.ConfigPath,
+		"KUB_CLI_CLUSTER=" + cluster.Name,
+		"KUB_CLI_SERVER=" + cluster.Server,
+		"KUB_CLI_NAMESPACE=" + namespace,
+	}
+}
@@ -0,0 +1,252 @@
+package setup
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/RaymondAkachi/custom-kub-cli/k8s/clustersetup"
+)
+
+// Field is a config value that can be edited in the TUI form
+type Field struct {
+	Label string
+	Get   func(c *clustersetup.ClusterConfig) string
+	Set   func(c *clustersetup.ClusterConfig, value string) error
+}
+
+// stringField creates a field backed by a string in the config
+func stringField(label string, ptr func(c *clustersetup.ClusterConfig) *string) Field {
+	return Field{
+		Label: label,
+		Get:   func(c *clustersetup.ClusterConfig) string { return *ptr(c) },
+		Set: func(c *clustersetup.ClusterConfig, value string) error {
+			*ptr(c) = value
+			return nil
+		},
+	}
+}
+
+// Fields are the form fields for editing a config, in display order.
+// Settings without a field (hooks, notifications, vault) are edited in the YAML file.
+var Fields = []Field{
+	stringField("Cluster name", func(c *clustersetup.ClusterConfig) *string { return &c.ClusterName }),
+	stringField("Kubernetes version", func(c *clustersetup.ClusterConfig) *string { return &c.KubernetesVersion }),
+	stringField("etcd version", func(c *clustersetup.ClusterConfig) *string { return &c.EtcdVersion }),
+	stringField("containerd version", func(c *clustersetup.ClusterConfig) *string { return &c.ContainerdVersion }),
+	stringField("CNI version", func(c *clustersetup.ClusterConfig) *string { return &c.CNIVersion }),
+	stringField("CNI provider (bridge, calico, flannel or cilium)", func(c *clustersetup.ClusterConfig) *string { return &c.CNIProvider }),
+	stringField("CoreDNS version", func(c *clustersetup.ClusterConfig) *string { return &c.CoreDNSVersion }),
+	stringField("Sandbox (pause) image (empty uses containerd's default)", func(c *clustersetup.ClusterConfig) *string { return &c.SandboxImage }),
+	{
+		Label: "Air-gapped (true pushes locally downloaded binaries and images to the nodes)",
+		Get: func(c *clustersetup.ClusterConfig) string {
+			return strconv.FormatBool(c.AirGapped)
+		},
+		Set: func(c *clustersetup.ClusterConfig, value string) error {
+			airGapped, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("air-gapped must be true or false")
+			}
+			c.AirGapped = airGapped
+			return nil
+		},
+	},
+	stringField("HTTP proxy (URL nodes download and pull through, optional)", func(c *clustersetup.ClusterConfig) *string { return &c.Proxy.HTTPProxy }),
+	stringField("HTTPS proxy (URL, optional)", func(c *clustersetup.ClusterConfig) *string { return &c.Proxy.HTTPSProxy }),
+	stringField("No proxy (extra hosts, domains and CIDRs to reach directly, optional)", func(c *clustersetup.ClusterConfig) *string { return &c.Proxy.NoProxy }),
+	stringField("Pod CIDR", func(c *clustersetup.ClusterConfig) *string { return &c.PodCIDR }),
+	stringField("Service CIDR", func(c *clustersetup.ClusterConfig) *string { return &c.ServiceCIDR }),
+	stringField("Cluster DNS", func(c *clustersetup.ClusterConfig) *string { return &c.ClusterDNS }),
+	{
+		Label: "Addons (" + strings.Join(clustersetup.AddonCatalog(), ", ") + "; comma-separated, optional)",
+		Get: func(c *clustersetup.ClusterConfig) string {
+			return strings.Join(c.Addons, ", ")
+		},
+		Set: func(c *clustersetup.ClusterConfig, value string) error {
+			var addons []string
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name == "" {
+					continue
+				}
+				if _, ok := clustersetup.LookupAddon(name); !ok {
+					return fmt.Errorf("unknown addon %q", name)
+				}
+				addons = append(addons, name)
+			}
+			c.Addons = addons
+			return nil
+		},
+	},
+	stringField("Work directory", func(c *clustersetup.ClusterConfig) *string { return &c.WorkDir }),
+	stringField("Setup log level (debug, info, warn or error; empty is info)", func(c *clustersetup.ClusterConfig) *string { return &c.Logging.Level }),
+	stringField("Setup log format (text or json; empty is text)", func(c *clustersetup.ClusterConfig) *string { return &c.Logging.Format }),
+	stringField("SSH key (empty uses the SSH agent's keys)", func(c *clustersetup.ClusterConfig) *string { return &c.SSHKey }),
+	stringField("SSH user", func(c *clustersetup.ClusterConfig) *string { return &c.SSHUser }),
+	stringField("Bastion host (host or host:port nodes are reached through, optional)", func(c *clustersetup.ClusterConfig) *string { return &c.BastionHost }),
+	stringField("Bastion user (empty uses the SSH user)", func(c *clustersetup.ClusterConfig) *string { return &c.BastionUser }),
+	stringField("Bastion SSH key (empty uses the SSH key)", func(c *clustersetup.ClusterConfig) *string { return &c.BastionKey }),
+	stringField("OS family (debian or rhel, empty detects it)", func(c *clustersetup.ClusterConfig) *string { return &c.OSFamily }),
+	{
+		Label: "Controller (name=ip)",
+		Get: func(c *clustersetup.ClusterConfig) string {
+			return c.Controller.Name + "=" + c.Controller.IPAddress
+		},
+		Set: func(c *clustersetup.ClusterConfig, value string) error {
+			node, err := parseNode(value, false)
+			if err != nil {
+				return err
+			}
+			node.PublicAddress = c.Controller.PublicAddress
+			c.Controller = keepHostname(node, []clustersetup.Node{c.Controller})
+			return nil
+		},
+	},
+	stringField("Controller public address (IP or DNS name for remote kubectl, optional)", func(c *clustersetup.ClusterConfig) *string { return &c.Controller.PublicAddress }),
+	{
+		Label: "HA controllers (name=ip, ... starting with the controller; empty for a single controller)",
+		Get: func(c *clustersetup.ClusterConfig) string {
+			var controllers []string
+			for _, controller := range c.Controllers {
+				controllers = append(controllers, controller.Name+"="+controller.IPAddress)
+			}
+			return strings.Join(controllers, ", ")
+		},
+		Set: func(c *clustersetup.ClusterConfig, value string) error {
+			var controllers []clustersetup.Node
+			for _, entry := range strings.Split(value, ",") {
+				if strings.TrimSpace(entry) == "" {
+					continue
+				}
+				node, err := parseNode(entry, false)
+				if err != nil {
+					return err
+				}
+				if node.Name == c.Controller.Name {
+					node.PublicAddress = c.Controller.PublicAddress
+				}
+				controllers = append(controllers, keepHostname(node, c.Controllers))
+			}
+			c.Controllers = controllers
+			return nil
+		},
+	},
+	stringField("Control plane endpoint (load balancer or kube-vip virtual IP for HA controllers, optional)", func(c *clustersetup.ClusterConfig) *string { return &c.ControlPlaneEndpoint }),
+	{
+		Label: "kube-vip (true holds the control plane endpoint as a virtual IP on the controllers)",
+		Get: func(c *clustersetup.ClusterConfig) string {
+			return strconv.FormatBool(c.KubeVIP.Enabled)
+		},
+		Set: func(c *clustersetup.ClusterConfig, value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("kube-vip must be true or false")
+			}
+			c.KubeVIP.Enabled = enabled
+			return nil
+		},
+	},
+	{
+		Label: "Workers (name=ip[=pod_cidr], ...; pod_cidr defaults to a free subnet)",
+		Get: func(c *clustersetup.ClusterConfig) string {
+			var workers []string
+			for _, worker := range c.Workers {
+				entry := worker.Name + "=" + worker.IPAddress
+				if worker.PodCIDR != "" {
+					entry += "=" + worker.PodCIDR
+				}
+				workers = append(workers, entry)
+			}
+			return strings.Join(workers, ", ")
+		},
+		Set: func(c *clustersetup.ClusterConfig, value string) error {
+			var workers []clustersetup.Node
+			for _, entry := range strings.Split(value, ",") {
+				if strings.TrimSpace(entry) == "" {
+					continue
+				}
+				node, err := parseNode(entry, true)
+				if err != nil {
+					return err
+				}
+				workers = append(workers, keepHostname(node, c.Workers))
+			}
+			c.Workers = workers
+			return nil
+		},
+	},
+	{
+		Label: "Dedicated etcd nodes (name=ip, ...; empty runs etcd on the controller)",
+		Get: func(c *clustersetup.ClusterConfig) string {
+			var members []string
+			for _, member := range c.Etcd.Members {
+				members = append(members, member.Name+"="+member.IPAddress)
+			}
+			return strings.Join(members, ", ")
+		},
+		Set: func(c *clustersetup.ClusterConfig, value string) error {
+			var members []clustersetup.Node
+			for _, entry := range strings.Split(value, ",") {
+				if strings.TrimSpace(entry) == "" {
+					continue
+				}
+				node, err := parseNode(entry, false)
+				if err != nil {
+					return err
+				}
+				members = append(members, keepHostname(node, c.Etcd.Members))
+			}
+			c.Etcd.Members = members
+			return nil
+		},
+	},
+	stringField("Certificate country", func(c *clustersetup.ClusterConfig) *string { return &c.Certificates.Country }),
+	stringField("Certificate organization", func(c *clustersetup.ClusterConfig) *string { return &c.Certificates.Organization }),
+	{
+		Label: "Certificate validity (days)",
+		Get: func(c *clustersetup.ClusterConfig) string {
+			return strconv.Itoa(c.Certificates.ValidityDays)
+		},
+		Set: func(c *clustersetup.ClusterConfig, value string) error {
+			days, err := strconv.Atoi(value)
+			if err != nil || days <= 0 {
+				return fmt.Errorf("validity must be a positive number of days")
+			}
+			c.Certificates.ValidityDays = days
+			return nil
+		},
+	},
+}
+
+// parseNode parses "name=ip" or, for workers, "name=ip=pod_cidr" with an
+// optional pod_cidr. The hostname defaults to the node name.
+func parseNode(value string, withPodCIDR bool) (clustersetup.Node, error) {
+	parts := strings.Split(strings.TrimSpace(value), "=")
+	valid, format := len(parts) == 2, "name=ip"
+	if withPodCIDR {
+		valid, format = valid || len(parts) == 3, "name=ip[=pod_cidr]"
+	}
+	if !valid {
+		return clustersetup.Node{}, fmt.Errorf("invalid node %q, expected %s", value, format)
+	}
+
+	node := clustersetup.Node{
+		Name:      strings.TrimSpace(parts[0]),
+		IPAddress: strings.TrimSpace(parts[1]),
+		Hostname:  strings.TrimSpace(parts[0]),
+	}
+	if len(parts) == 3 {
+		node.PodCIDR = strings.TrimSpace(parts[2])
+	}
+	return node, nil
+}
+
+// keepHostname preserves the hostname of an existing node with the same name
+func keepHostname(node clustersetup.Node, existing []clustersetup.Node) clustersetup.Node {
+	for _, old := range existing {
+		if old.Name == node.Name && old.Hostname != "" {
+			node.Hostname = old.Hostname
+		}
+	}
+	return node
+}
@@ -0,0 +1,298 @@
+package setup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/RaymondAkachi/custom-kub-cli/k8s/clustersetup"
+)
+
+// ManagedConfig is a clustersetup configuration file kept in the config directory
+type ManagedConfig struct {
+	Name   string
+	Path   string
+	Config clustersetup.ClusterConfig
+	// ParseErr is set when the file couldn't be read as YAML
+	ParseErr error
+	// Passphrase decrypts the SSH key when the config has none. It's typed
+	// in for the session and never saved.
+	Passphrase string
+}
+
+// Store manages clustersetup YAML configs in a directory. Each cluster set
+// up from them gets its own work directory under workRoot by default.
+type Store struct {
+	dir      string
+	workRoot string
+}
+
+// NewStore creates a store for configs in dir whose clusters keep their
+// certificates and setup state under workRoot
+func NewStore(dir, workRoot string) *Store {
+	return &Store{dir: dir, workRoot: workRoot}
+}
+
+// Dir returns the config directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// WorkDir returns the default work directory of a cluster
+func (s *Store) WorkDir(clusterName string) string {
+	return filepath.Join(s.workRoot, clusterName)
+}
+
+// List returns the configs in the directory sorted by name. Files that fail to
+// parse are still listed with ParseErr set so they can be fixed.
+func (s *Store) List() ([]ManagedConfig, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config directory: %v", err)
+	}
+
+	var configs []ManagedConfig
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		configs = append(configs, s.load(filepath.Join(s.dir, entry.Name())))
+	}
+
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].Name < configs[j].Name
+	})
+	return configs, nil
+}
+
+// load reads a config file without validating it
+func (s *Store) load(path string) ManagedConfig {
+	managed := ManagedConfig{
+		Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Path: path,
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		managed.ParseErr = fmt.Errorf("failed to read config: %v", err)
+		return managed
+	}
+	if err := yaml.Unmarshal(data, &managed.Config); err != nil {
+		managed.ParseErr = fmt.Errorf("failed to parse config: %v", err)
+	}
+	return managed
+}
+
+// Create writes a new config for name from the default template
+func (s *Store) Create(name string) (ManagedConfig, error) {
+	if name == "" || strings.ContainsAny(name, `/\ `) {
+		return ManagedConfig{}, fmt.Errorf("invalid config name %q", name)
+	}
+
+	path := filepath.Join(s.dir, name+".yaml")
+	if _, err := os.Stat(path); err == nil {
+		return ManagedConfig{}, fmt.Errorf("config %s already exists", name)
+	}
+
+	config := clustersetup.GenerateDefaultConfig()
+	config.ClusterName = name
+	// Keep generated certificates of different clusters apart
+	config.WorkDir = s.WorkDir(name)
+
+	managed := ManagedConfig{Name: name, Path: path, Config: config}
+	if err := s.Save(managed); err != nil {
+		return ManagedConfig{}, err
+	}
+	return managed, nil
+}
+
+// Find returns the config of the named cluster, matching the cluster name in
+// the config or else the config's file name
+func (s *Store) Find(clusterName string) (ManagedConfig, error) {
+	configs, err := s.List()
+	if err != nil {
+		return ManagedConfig{}, err
+	}
+	for _, managed := range configs {
+		if managed.ParseErr == nil && managed.Config.ClusterName == clusterName {
+			return managed, nil
+		}
+	}
+	for _, managed := range configs {
+		if managed.Name == clusterName {
+			return managed, nil
+		}
+	}
+	return ManagedConfig{}, fmt.Errorf("no cluster config for %s in %s", clusterName, s.dir)
+}
+
+// CheckUnique reports another config with the same cluster name or work
+// directory, since setting up either would overwrite the other's certificates
+// and state
+func (s *Store) CheckUnique(managed ManagedConfig) error {
+	configs, err := s.List()
+	if err != nil {
+		return err
+	}
+	dir := workDir(managed)
+	for _, other := range configs {
+		if other.Path == managed.Path || other.ParseErr != nil {
+			continue
+		}
+		if other.Config.ClusterName == managed.Config.ClusterName {
+			return fmt.Errorf("%s and %s both set up cluster %s", managed.Name, other.Name, managed.Config.ClusterName)
+		}
+		if managed.Config.WorkDir != "" && workDir(other) == dir {
+			return fmt.Errorf("%s and %s share the work directory %s", managed.Name, other.Name, dir)
+		}
+	}
+	return nil
+}
+
+// workDir returns the work directory of a config with a leading ~ expanded
+func workDir(managed ManagedConfig) string {
+	dir := managed.Config.WorkDir
+	if strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[2:])
+		}
+	}
+	return filepath.Clean(dir)
+}
+
+// SetupStatus summarizes how far the cluster of a config was set up, from
+// the setup state in its work directory
+func SetupStatus(managed ManagedConfig) string {
+	if managed.Config.WorkDir == "" {
+		return "not set up"
+	}
+	state, err := clustersetup.LoadSetupState(workDir(managed))
+	if err != nil {
+		return "not set up"
+	}
+	if state.Done("validation") {
+		return "set up"
+	}
+	return fmt.Sprintf("setup incomplete (%d steps done)", len(state.Completed))
+}
+
+// Save writes a config back to its file
+func (s *Store) Save(managed ManagedConfig) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	if err := clustersetup.SaveConfig(managed.Config, managed.Path); err != nil {
+		return fmt.Errorf("failed to save config %s: %v", managed.Name, err)
+	}
+	return nil
+}
+
+// Validate loads a config file through clustersetup, which checks every
+// required field, and returns the resolved configuration
+func Validate(managed ManagedConfig) (clustersetup.ClusterConfig, error) {
+	if managed.ParseErr != nil {
+		return clustersetup.ClusterConfig{}, managed.ParseErr
+	}
+	return clustersetup.LoadClusterConfig(managed.Path)
+}
+
+// NewClusterManager validates a config and creates a cluster manager for it,
+// reading credentials and certificates from Vault when the config asks for it
+func NewClusterManager(managed ManagedConfig, logger clustersetup.Logger, progress clustersetup.ProgressReporter) (*clustersetup.ClusterManager, error) {
+	config, err := Validate(managed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", managed.Name, err)
+	}
+
+	var sshClient *clustersetup.RealSSHClient
+	var certManager clustersetup.CertificateManager = clustersetup.NewCertificateManager()
+
+	if config.Vault.Enabled() {
+		vault, err := clustersetup.NewVaultClient(config.Vault)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vault client: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if config.Vault.SSHKey.IsSet() {
+			if sshClient, err = clustersetup.NewSSHClientFromVault(ctx, vault, config.SSHUser, config.Vault); err != nil {
+				return nil, err
+			}
+		} else {
+			if sshClient, err = clustersetup.NewSSHClient(config.SSHUser, config.SSHKey); err != nil {
+				return nil, fmt.Errorf("failed to create SSH client: %v", err)
+			}
+			if config.Vault.SudoPassword.IsSet() {
+				password, err := vault.ReadField(ctx, config.Vault.SudoPassword)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read sudo password from vault: %v", err)
+				}
+				sshClient.SetSudoPassword(password)
+			}
+			if config.Vault.SSHKeyPassphrase.IsSet() {
+				passphrase, err := vault.ReadField(ctx, config.Vault.SSHKeyPassphrase)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read SSH key passphrase from vault: %v", err)
+				}
+				sshClient.SetKeyPassphrase(passphrase)
+			}
+		}
+		if config.Vault.PKI != nil {
+			certManager = clustersetup.NewVaultCertificateManager(vault, *config.Vault.PKI)
+		}
+	} else {
+		if sshClient, err = clustersetup.NewSSHClient(config.SSHUser, config.SSHKey); err != nil {
+			return nil, fmt.Errorf("failed to create SSH client: %v", err)
+		}
+	}
+	// A passphrase typed in this session stands in for one in the config
+	if !config.Vault.SSHKeyPassphrase.IsSet() {
+		passphrase := config.SSHKeyPassphrase
+		if passphrase == "" {
+			passphrase = managed.Passphrase
+		}
+		sshClient.SetKeyPassphrase(passphrase)
+	}
+	if config.BastionHost != "" {
+		if err := sshClient.SetBastion(config.BastionHost, config.BastionUser, config.BastionKey); err != nil {
+			return nil, fmt.Errorf("failed to configure the bastion: %v", err)
+		}
+	}
+
+	cm := clustersetup.NewClusterManager(config, logger, sshClient, certManager, progress)
+	// Setups keep going without a log file; the logs still reach the TUI
+	if err := cm.LogToFile(); err != nil {
+		logger.Warn(fmt.Sprintf("Setup log disabled: %v", err))
+	}
+	return cm, nil
+}
+
+// ExportRunbook validates a config and renders its setup into a runbook in
+// the work directory. The runbook never connects to the nodes, so no SSH key
+// is needed unless Vault is configured, which may issue the certificates.
+func ExportRunbook(ctx context.Context, managed ManagedConfig, logger clustersetup.Logger, progress clustersetup.ProgressReporter) (string, error) {
+	config, err := Validate(managed)
+	if err != nil {
+		return "", fmt.Errorf("invalid config %s: %v", managed.Name, err)
+	}
+	if config.Vault.Enabled() {
+		cm, err := NewClusterManager(managed, logger, progress)
+		if err != nil {
+			return "", err
+		}
+		defer cm.Close()
+		return cm.ExportRunbook(ctx)
+	}
+	cm := clustersetup.NewClusterManager(config, logger, nil, clustersetup.NewCertificateManager(), progress)
+	return cm.ExportRunbook(ctx)
+}
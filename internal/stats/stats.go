@@ -0,0 +1,233 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/audit"
+	"github.com/RaymondAkachi/custom-kub-cli/k8s/clustersetup"
+)
+
+// Kinds of samples
+const (
+	KindCommand    = "command"
+	KindSetupPhase = "setup-phase"
+)
+
+// Sample is one timed operation. Commands are recorded by their verb only, so
+// resource names and arguments never end up in the file.
+type Sample struct {
+	Time     time.Time     `json:"time"`
+	Kind     string        `json:"kind"`
+	Cluster  string        `json:"cluster"`
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration_ns"`
+	Failed   bool          `json:"failed,omitempty"`
+}
+
+// Recorder appends samples to a local JSON lines file while enabled. Nothing
+// is ever sent anywhere.
+type Recorder struct {
+	path    string
+	mu      sync.Mutex
+	enabled bool
+}
+
+// NewRecorder creates a recorder backed by the file at path
+func NewRecorder(path string, enabled bool) *Recorder {
+	return &Recorder{path: path, enabled: enabled}
+}
+
+// Path returns the location of the statistics file
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Enabled reports whether samples are being recorded
+func (r *Recorder) Enabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enabled
+}
+
+// SetEnabled starts or stops recording
+func (r *Recorder) SetEnabled(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enabled = enabled
+}
+
+// Record appends a sample if recording is enabled
+func (r *Recorder) Record(sample Sample) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.enabled {
+		return nil
+	}
+
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sample: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create statistics directory: %v", err)
+	}
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open statistics file: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write sample: %v", err)
+	}
+	return nil
+}
+
+// RecordCommand records the latency of a terminal or view command, named by
+// its audit source and verb. Shell commands are only recorded as "shell".
+func (r *Recorder) RecordCommand(cluster, source, command string, duration time.Duration, err error) error {
+	name := source
+	if fields := strings.Fields(command); len(fields) > 0 && source != audit.SourceShell {
+		name = source + " " + fields[0]
+	}
+	return r.Record(Sample{
+		Time:     time.Now(),
+		Kind:     KindCommand,
+		Cluster:  cluster,
+		Name:     name,
+		Duration: duration,
+		Failed:   err != nil,
+	})
+}
+
+// ClusterSetupHandler returns a clustersetup event handler that records how
+// long each phase of a run takes. Use a new handler for every run.
+func (r *Recorder) ClusterSetupHandler() clustersetup.EventHandler {
+	var mu sync.Mutex
+	var cluster, phase string
+	var started time.Time
+
+	finish := func(at time.Time, failed bool) {
+		if phase == "" {
+			return
+		}
+		r.Record(Sample{Time: started, Kind: KindSetupPhase, Cluster: cluster, Name: phase, Duration: at.Sub(started), Failed: failed})
+		phase = ""
+	}
+
+	return func(event clustersetup.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch event.Type {
+		case clustersetup.EventPhaseStarted:
+			finish(event.Time, false)
+			cluster, phase, started = event.Cluster, event.Phase, event.Time
+		case clustersetup.EventSetupCompleted:
+			finish(event.Time, false)
+		case clustersetup.EventSetupFailed:
+			finish(event.Time, true)
+		}
+	}
+}
+
+// Load reads every sample recorded since the given time
+func (r *Recorder) Load(since time.Time) ([]Sample, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := os.Open(r.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open statistics file: %v", err)
+	}
+	defer file.Close()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var sample Sample
+		// Skip lines cut short by a crash rather than losing the whole file
+		if json.Unmarshal(scanner.Bytes(), &sample) != nil || sample.Time.Before(since) {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read statistics file: %v", err)
+	}
+	return samples, nil
+}
+
+// Summary aggregates the samples sharing a key
+type Summary struct {
+	Key      string
+	Count    int
+	Failures int
+	P50      time.Duration
+	P95      time.Duration
+	Max      time.Duration
+}
+
+// FailureRate returns the fraction of failed samples
+func (s Summary) FailureRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Count)
+}
+
+// Summarize groups samples of a kind by key, slowest median first
+func Summarize(samples []Sample, kind string, key func(Sample) string) []Summary {
+	durations := make(map[string][]time.Duration)
+	failures := make(map[string]int)
+	for _, sample := range samples {
+		if sample.Kind != kind {
+			continue
+		}
+		k := key(sample)
+		durations[k] = append(durations[k], sample.Duration)
+		if sample.Failed {
+			failures[k]++
+		}
+	}
+
+	var summaries []Summary
+	for k, values := range durations {
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		summaries = append(summaries, Summary{
+			Key:      k,
+			Count:    len(values),
+			Failures: failures[k],
+			P50:      percentile(values, 0.50),
+			P95:      percentile(values, 0.95),
+			Max:      values[len(values)-1],
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].P50 != summaries[j].P50 {
+			return summaries[i].P50 > summaries[j].P50
+		}
+		return summaries[i].Key < summaries[j].Key
+	})
+	return summaries
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,26 @@
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// OpenURL opens a URL in the user's default browser
+func OpenURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to open %s: %v", url, err)
+	}
+	// Reap the launcher so it doesn't linger as a zombie
+	go cmd.Wait()
+	return nil
+}
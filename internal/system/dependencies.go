@@ -13,6 +13,7 @@ type Dependency struct {
 	Description string   // Brief description of the dependency
 	InstallURL  string   // URL for installation instructions
 	VersionCmd  []string // Command to check the version
+	Optional    bool     // Optional dependencies are reported but never required
 }
 
 // DependencyChecker manages system dependency verification
@@ -38,6 +39,30 @@ func NewDependencyChecker() *DependencyChecker {
 				InstallURL:  "https://git-scm.com/downloads",
 				VersionCmd:  []string{"git", "--version"},
 			},
+			{
+				Name:        "helm",
+				Command:     "helm",
+				Description: "Kubernetes package manager",
+				InstallURL:  "https://helm.sh/docs/intro/install/",
+				VersionCmd:  []string{"helm", "version", "--short"},
+				Optional:    true,
+			},
+			{
+				Name:        "cfssl",
+				Command:     "cfssl",
+				Description: "Cloudflare PKI toolkit",
+				InstallURL:  "https://github.com/cloudflare/cfssl#installation",
+				VersionCmd:  []string{"cfssl", "version"},
+				Optional:    true,
+			},
+			{
+				Name:        "terraform",
+				Command:     "terraform",
+				Description: "Infrastructure as code tool",
+				InstallURL:  "https://developer.hashicorp.com/terraform/install",
+				VersionCmd:  []string{"terraform", "version"},
+				Optional:    true,
+			},
 		},
 	}
 }
@@ -48,6 +73,9 @@ func (dc *DependencyChecker) CheckAll() error {
 	var errors []string
 
 	for _, dep := range dc.dependencies {
+		if dep.Optional {
+			continue
+		}
 		if err := dc.checkDependency(dep); err != nil {
 			missingDeps = append(missingDeps, dep.Name)
 			errors = append(errors, fmt.Sprintf("  • %s: %v", dep.Name, err))
@@ -91,6 +119,11 @@ func (dc *DependencyChecker) checkDependency(dep Dependency) error {
 	return nil
 }
 
+// Dependencies returns all known dependencies, required ones first
+func (dc *DependencyChecker) Dependencies() []Dependency {
+	return dc.dependencies
+}
+
 // GetDependencyInfo returns information about a specific dependency
 func (dc *DependencyChecker) GetDependencyInfo(name string) (*Dependency, error) {
 	for _, dep := range dc.dependencies {
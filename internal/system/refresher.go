@@ -0,0 +1,103 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeTimeout bounds a single version command, some of which check for updates online
+const probeTimeout = 10 * time.Second
+
+// DependencyStatus is the cached result of probing a dependency
+type DependencyStatus struct {
+	Dependency
+	Available bool
+	Version   string
+	Error     string
+}
+
+// Refresher probes dependency versions in the background and caches the results
+type Refresher struct {
+	checker   *DependencyChecker
+	mu        sync.RWMutex
+	statuses  []DependencyStatus
+	checkedAt time.Time
+	running   chan struct{} // closed when the running refresh finishes, nil when idle
+}
+
+// NewRefresher creates a refresher for the checker's dependencies
+func NewRefresher(checker *DependencyChecker) *Refresher {
+	return &Refresher{checker: checker}
+}
+
+// Refresh probes every dependency concurrently and replaces the cached results.
+// Calls while a refresh is running wait for it to finish and share its
+// results instead of starting another.
+func (r *Refresher) Refresh() {
+	r.mu.Lock()
+	if running := r.running; running != nil {
+		r.mu.Unlock()
+		<-running
+		return
+	}
+	running := make(chan struct{})
+	r.running = running
+	r.mu.Unlock()
+
+	deps := r.checker.Dependencies()
+	statuses := make([]DependencyStatus, len(deps))
+
+	var wg sync.WaitGroup
+	for i, dep := range deps {
+		wg.Add(1)
+		go func(i int, dep Dependency) {
+			defer wg.Done()
+			statuses[i] = probe(dep)
+		}(i, dep)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	r.statuses = statuses
+	r.checkedAt = time.Now()
+	r.running = nil
+	r.mu.Unlock()
+	close(running)
+}
+
+// Snapshot returns the cached results, when they were taken, and whether a refresh is running
+func (r *Refresher) Snapshot() ([]DependencyStatus, time.Time, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]DependencyStatus, len(r.statuses))
+	copy(statuses, r.statuses)
+	return statuses, r.checkedAt, r.running != nil
+}
+
+// probe checks a single dependency and reads the first line of its version output
+func probe(dep Dependency) DependencyStatus {
+	status := DependencyStatus{Dependency: dep}
+
+	if _, err := exec.LookPath(dep.Command); err != nil {
+		status.Error = fmt.Sprintf("command '%s' not found in PATH", dep.Command)
+		return status
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, dep.VersionCmd[0], dep.VersionCmd[1:]...).CombinedOutput()
+	if err != nil {
+		status.Error = fmt.Sprintf("version check failed: %v", err)
+		return status
+	}
+
+	status.Available = true
+	status.Version = strings.TrimSpace(strings.Split(strings.TrimSpace(string(output)), "\n")[0])
+	return status
+}
@@ -0,0 +1,42 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// ShellTimeout bounds how long a shell passthrough command may run
+const ShellTimeout = 2 * time.Minute
+
+// RunShell runs a command line with the user's shell and returns its combined
+// output. env is added to the current environment.
+func RunShell(command string, env []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ShellTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		shell := os.Getenv("SHELL")
+		if shell == "" {
+			shell = "/bin/sh"
+		}
+		cmd = exec.CommandContext(ctx, shell, "-c", command)
+	}
+	cmd.Env = append(os.Environ(), env...)
+
+	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return string(output), fmt.Errorf("command timed out after %s", ShellTimeout)
+	}
+	if err != nil {
+		return string(output), fmt.Errorf("command failed: %v", err)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,131 @@
+package templates
+
+// builtins are the templates shipped with the CLI, written in the same format
+// as custom templates
+var builtins = map[string]string{
+	"deployment": `# description: Deployment running one container image
+# param: name - Name of the deployment
+# param: namespace - Namespace (empty for the terminal default)
+# param: image - Container image, e.g. nginx:1.27
+# param: replicas=1 - Number of pods
+# param: port=80 - Container port
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .name }}
+{{- if .namespace }}
+  namespace: {{ .namespace }}
+{{- end }}
+  labels:
+    app: {{ .name }}
+spec:
+  replicas: {{ .replicas }}
+  selector:
+    matchLabels:
+      app: {{ .name }}
+  template:
+    metadata:
+      labels:
+        app: {{ .name }}
+    spec:
+      containers:
+        - name: {{ .name }}
+          image: {{ .image }}
+          ports:
+            - containerPort: {{ .port }}
+`,
+
+	"service": `# description: Service exposing pods labeled app=<selector>
+# param: name - Name of the service
+# param: namespace - Namespace (empty for the terminal default)
+# param: selector - Value of the app label of the pods
+# param: type=ClusterIP - ClusterIP, NodePort or LoadBalancer
+# param: port=80 - Service port
+# param: targetPort=80 - Container port
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .name }}
+{{- if .namespace }}
+  namespace: {{ .namespace }}
+{{- end }}
+spec:
+  type: {{ .type }}
+  selector:
+    app: {{ .selector }}
+  ports:
+    - port: {{ .port }}
+      targetPort: {{ .targetPort }}
+`,
+
+	"ingress": `# description: Ingress routing a host to a service
+# param: name - Name of the ingress
+# param: namespace - Namespace (empty for the terminal default)
+# param: host - Host name, e.g. app.example.com
+# param: service - Backend service name
+# param: port=80 - Backend service port
+# param: className=nginx - Ingress class
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: {{ .name }}
+{{- if .namespace }}
+  namespace: {{ .namespace }}
+{{- end }}
+spec:
+  ingressClassName: {{ .className }}
+  rules:
+    - host: {{ .host }}
+      http:
+        paths:
+          - path: /
+            pathType: Prefix
+            backend:
+              service:
+                name: {{ .service }}
+                port:
+                  number: {{ .port }}
+`,
+
+	"configmap": `# description: ConfigMap with a single key
+# param: name - Name of the configmap
+# param: namespace - Namespace (empty for the terminal default)
+# param: key - Key name
+# param: value - Value of the key
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .name }}
+{{- if .namespace }}
+  namespace: {{ .namespace }}
+{{- end }}
+data:
+  {{ .key }}: {{ quote .value }}
+`,
+
+	"cronjob": `# description: CronJob running a command on a schedule
+# param: name - Name of the cronjob
+# param: namespace - Namespace (empty for the terminal default)
+# param: schedule=0 * * * * - Cron schedule
+# param: image=busybox:1.36 - Container image
+# param: command - Shell command to run
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: {{ .name }}
+{{- if .namespace }}
+  namespace: {{ .namespace }}
+{{- end }}
+spec:
+  schedule: {{ quote .schedule }}
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          restartPolicy: OnFailure
+          containers:
+            - name: {{ .name }}
+              image: {{ .image }}
+              command: ["/bin/sh", "-c", {{ quote .command }}]
+`,
+}
@@ -0,0 +1,164 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// placeholderPattern finds the parameters a template body refers to
+var placeholderPattern = regexp.MustCompile(`\{\{-?\s*(?:[a-z]+\s+)?\.(\w+)`)
+
+// funcs are the functions available to templates
+var funcs = template.FuncMap{
+	"quote": strconv.Quote,
+}
+
+// Param is a value filled in before a template is rendered
+type Param struct {
+	Name        string
+	Default     string
+	Description string
+}
+
+// Template is a parameterized manifest. Parameters and the description are
+// declared in comment lines at the top of the file:
+//
+//	# description: Redis with a persistent volume
+//	# param: name - Name of the release
+//	# param: replicas=1 - Number of pods
+//
+// The rest of the file is a Go text/template referring to parameters as {{ .name }}.
+type Template struct {
+	Name        string
+	Description string
+	Params      []Param
+	Body        string
+	Path        string // empty for built-in templates
+}
+
+// Parse reads a template in the commented header format
+func Parse(name, data string) (Template, error) {
+	t := Template{Name: name}
+	declared := make(map[string]bool)
+
+	// The header is dropped from the rendered manifest
+	lines := strings.Split(data, "\n")
+	header := 0
+	for ; header < len(lines); header++ {
+		line := strings.TrimSpace(lines[header])
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		switch {
+		case strings.HasPrefix(line, "description:"):
+			t.Description = strings.TrimSpace(strings.TrimPrefix(line, "description:"))
+		case strings.HasPrefix(line, "param:"):
+			param := parseParam(strings.TrimSpace(strings.TrimPrefix(line, "param:")))
+			if param.Name == "" || declared[param.Name] {
+				continue
+			}
+			declared[param.Name] = true
+			t.Params = append(t.Params, param)
+		}
+	}
+
+	t.Body = strings.Join(lines[header:], "\n")
+
+	if _, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(t.Body); err != nil {
+		return Template{}, fmt.Errorf("invalid template %s: %v", name, err)
+	}
+
+	// Parameters used without a declaration are still asked for
+	for _, match := range placeholderPattern.FindAllStringSubmatch(t.Body, -1) {
+		if !declared[match[1]] {
+			declared[match[1]] = true
+			t.Params = append(t.Params, Param{Name: match[1]})
+		}
+	}
+	return t, nil
+}
+
+// parseParam reads "name[=default] - description"
+func parseParam(spec string) Param {
+	var param Param
+	if i := strings.Index(spec, " - "); i >= 0 {
+		param.Description = strings.TrimSpace(spec[i+3:])
+		spec = spec[:i]
+	}
+	param.Name, param.Default, _ = strings.Cut(strings.TrimSpace(spec), "=")
+	param.Name = strings.TrimSpace(param.Name)
+	return param
+}
+
+// Render fills in the template with parameter values
+func (t Template) Render(values map[string]string) (string, error) {
+	tmpl, err := template.New(t.Name).Funcs(funcs).Option("missingkey=error").Parse(t.Body)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %s: %v", t.Name, err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, values); err != nil {
+		return "", fmt.Errorf("failed to render %s: %v", t.Name, err)
+	}
+	return out.String(), nil
+}
+
+// Load returns the built-in templates and the custom ones in dir, sorted by
+// name. A custom template replaces a built-in one of the same name. Templates
+// that fail to parse are returned as errors alongside the rest.
+func Load(dir string) ([]Template, []error) {
+	byName := make(map[string]Template)
+	var errs []error
+
+	for name, data := range builtins {
+		t, err := Parse(name, data)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		byName[name] = t
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		errs = append(errs, fmt.Errorf("failed to read template directory: %v", err))
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".tmpl") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read template %s: %v", path, err))
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		t, err := Parse(name, string(data))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		t.Path = path
+		byName[name] = t
+	}
+
+	var templates []Template
+	for _, t := range byName {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, errs
+}
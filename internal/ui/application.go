@@ -1,21 +1,34 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/RaymondAkachi/custom-kub-cli/internal/audit"
 	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
 	"github.com/RaymondAkachi/custom-kub-cli/internal/git"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/i18n"
 	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/logging"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/plugins"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/setup"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/stats"
 	"github.com/RaymondAkachi/custom-kub-cli/internal/system"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/wizard"
+	"github.com/RaymondAkachi/custom-kub-cli/k8s/clustersetup"
 )
 
 // sessionState represents the current UI state
@@ -26,23 +39,94 @@ const (
 	addClusterView
 	terminalView
 	loadingView
+	gitStatusView
+	clusterConfigsView
+	configFormView
+	setupRunView
+	destroyConfirmView
+	bakeNodeView
+	dashboardView
+	resourceBrowserView
+	rolloutView
+	scaleView
+	jobsView
+	multiLogView
+	portForwardsView
+	namespacesView
+	applyView
+	templatesView
+	workspacesView
+	statsView
+	passphraseView
+	documentView
+	wizardView
 )
 
 // Messages for tea.Cmd communication
 type clusterSelectedMsg struct{ cluster *config.ClusterInfo }
 type clusterAddedMsg struct{ cluster *config.ClusterInfo }
-type commandExecutedMsg struct{ output string }
+type commandExecutedMsg struct {
+	output  string
+	cluster string
+	table   *kubectl.Table
+	doc     *kubectl.Document
+}
 type errorMsg struct{ err error }
 type setupCompleteMsg struct{}
+type dependenciesRefreshedMsg struct{}
+type commandFinishedMsg struct {
+	cluster string
+	msg     tea.Msg
+}
+type gitStatusMsg struct {
+	status *git.SyncStatus
+	err    error
+	notice string
+}
+type confirmationRequiredMsg struct {
+	command string
+	err     *kubectl.PolicyError
+}
+
+// clusterSession holds the per-cluster state a command runs against.
+// noSync is set for commands that opted out of the Git sync.
+type clusterSession struct {
+	cluster    *config.ClusterInfo
+	executor   *kubectl.Executor
+	gitManager *git.Manager
+	noSync     bool
+}
+
+// noSyncSuffix ends a modifying command that shouldn't be synced to Git
+const noSyncSuffix = ":nosync"
+
+// cutNoSync removes a trailing :nosync word from a command and reports
+// whether it was there. It must be a word of its own, so image tags and
+// file names ending in :nosync are left alone.
+func cutNoSync(command string) (string, bool) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || fields[len(fields)-1] != noSyncSuffix {
+		return command, false
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(command), noSyncSuffix)), true
+}
 
 // Application represents the main TUI application
 type Application struct {
 	state           sessionState
 	config          *config.Manager
 	dependencyChecker *system.DependencyChecker
+	depsRefresher     *system.Refresher
 	selectedCluster *config.ClusterInfo
 	kubectlExecutor *kubectl.Executor
 	gitManager      *git.Manager
+	pluginManager   *plugins.Manager
+	auditLog        *audit.Log
+	catalog         *i18n.Catalog
+	stats           *stats.Recorder
+	logs            *logging.Log
+	commandQueue    *kubectl.Queue
+	kubeconfigWatcher *config.KubeConfigWatcher
 
 	// UI components
 	list         list.Model
@@ -50,14 +134,51 @@ type Application struct {
 	viewport     viewport.Model
 	spinner      spinner.Model
 
+	// Managed cluster configs
+	setupStore     *setup.Store
+	configList     list.Model
+	configForm     configForm
+	configsNotice  string
+	destroyTarget  setup.ManagedConfig
+	bakeTarget     setup.ManagedConfig
+	passphrase     passphrasePrompt
+	passphrases    map[string]string // SSH key passphrases typed in, by config
+	operation      *setupOperation
+	dashboard      clusterDashboard
+
 	// Add cluster form
 	addClusterStep int
 	newCluster     config.ClusterInfo
 
 	// Terminal
 	commandHistory []string
-	currentCommand string
+	prompt         textarea.Model
+	terminals      map[string]terminalSession // terminals of the clusters switched away from
+	lastCluster    string
 	output         string
+	revealSecrets  bool
+	pendingCommand string
+	inFlight       int
+	running        map[string]int // commands in flight per cluster
+	gitStatus      gitStatusMsg
+	tables         map[string]*kubectl.Table // last get output per cluster
+	documents      map[string]*kubectl.Document
+	document       documentViewer // shows the last -o yaml/json output of a cluster, kept in documents
+	wizard         resourceWizard
+	browser        resourceBrowser
+	rollout        rolloutWatch
+	scale          scalePicker
+	jobs           jobsManager
+	multiLog       multiLog
+	portForwards   portForwardManager
+	namespaces     namespaceManager
+	apply          applyFlow
+	templates      templateLibrary
+	workspaces     workspaceSwitcher
+	statsReport    statsReport
+	readOnly       bool // started with --read-only
+	shuttingDown   bool // waiting for a canceled operation before exiting
+	crashed        *Crash
 	ready          bool
 	width          int
 	height         int
@@ -69,49 +190,116 @@ type Application struct {
 
 // NewApplication creates a new TUI application
 func NewApplication(cfg *config.Manager) (*Application, error) {
+	preferences, err := cfg.LoadPreferences()
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := loadCatalog(cfg.LocaleDir, preferences.Locale)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create list items from clusters
 	var items []list.Item
 	for _, cluster := range cfg.GetAllClusters() {
 		items = append(items, &clusterItem{cluster: cluster})
 	}
-	items = append(items, &addClusterItem{})
+	items = append(items, menuItems(catalog, cfg.Workspace)...)
 
 	l := list.New(items, list.NewDefaultDelegate(), 80, 14)
-	l.Title = "🚀 Kubernetes Orchestrator"
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(false)
 
+	cl := list.New(nil, list.NewDefaultDelegate(), 80, 14)
+	cl.SetShowStatusBar(false)
+	cl.SetFilteringEnabled(false)
+
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
 
 	ti := textinput.New()
-	ti.Placeholder = "Enter value..."
 	ti.Focus()
 
 	vp := viewport.New(80, 20)
 
+	pm := plugins.NewManager(cfg.PluginDir)
+	if err := pm.Discover(); err != nil {
+		return nil, fmt.Errorf("failed to discover plugins: %v", err)
+	}
+
+	dc := system.NewDependencyChecker()
+
 	app := &Application{
 		state:             clusterSelectionView,
 		config:            cfg,
-		dependencyChecker: system.NewDependencyChecker(),
+		catalog:           catalog,
+		dependencyChecker: dc,
+		depsRefresher:     system.NewRefresher(dc),
+		pluginManager:     pm,
+		auditLog:          audit.NewLog(cfg.AuditLogPath),
+		stats:             stats.NewRecorder(cfg.StatsPath, preferences.Stats),
+		logs:              logging.NewLog(cfg.LogPath, 500),
+		commandQueue:      kubectl.NewQueue(),
+		kubeconfigWatcher: config.NewKubeConfigWatcher(cfg),
+		tables:            make(map[string]*kubectl.Table),
+		documents:         make(map[string]*kubectl.Document),
+		running:           make(map[string]int),
+		setupStore:        setup.NewStore(cfg.SetupDir, cfg.SetupWorkDir),
+		configList:        cl,
 		list:              l,
 		textInput:         ti,
+		prompt:            newCommandPrompt(),
 		viewport:          vp,
 		spinner:           s,
 		newCluster:        config.ClusterInfo{CreatedAt: time.Now()},
 	}
+	app.refreshTitles()
+	// Remember the current kubeconfigs so only later changes are reported
+	app.kubeconfigWatcher.Check(cfg.GetAllClusters())
 
 	return app, nil
 }
 
 // Init initializes the application
 func (a *Application) Init() tea.Cmd {
-	return tea.Batch(textinput.Blink, a.spinner.Tick)
+	return guard(tea.Batch(textinput.Blink, a.spinner.Tick, a.refreshDependencies, watchKubeconfigs(), a.probeCapabilities()))
+}
+
+// setupLogger returns the logger for cluster setup runs. Setup logs go to the
+// log pane and log file, since printing to stdout would corrupt the TUI.
+func (a *Application) setupLogger(cluster string) clustersetup.Logger {
+	return a.logs.ClusterSetupLogger(cluster)
+}
+
+// refreshDependencies probes dependency versions in the background
+func (a *Application) refreshDependencies() tea.Msg {
+	a.depsRefresher.Refresh()
+	return dependenciesRefreshedMsg{}
+}
+
+// Update handles messages and updates the application state. A panic is
+// written to a crash report and ends the program, which restores the terminal.
+func (a *Application) Update(msg tea.Msg) (model tea.Model, cmd tea.Cmd) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.recordCrash(r, debug.Stack())
+			model, cmd = a, tea.Quit
+		}
+	}()
+
+	if msg, ok := msg.(panicMsg); ok {
+		a.recordCrash(msg.value, msg.stack)
+	}
+	if a.crashed != nil {
+		return a, tea.Quit
+	}
+	model, cmd = a.update(msg)
+	return model, guard(cmd)
 }
 
-// Update handles messages and updates the application state
-func (a *Application) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+// update handles messages and updates the application state
+func (a *Application) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	var cmds []tea.Cmd
 
@@ -121,11 +309,19 @@ func (a *Application) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.height = msg.Height
 		a.list.SetWidth(msg.Width - 4)
 		a.list.SetHeight(msg.Height - 8)
+		a.configList.SetWidth(msg.Width - 4)
+		a.configList.SetHeight(msg.Height - 10)
 		a.viewport.Width = msg.Width - 4
 		a.viewport.Height = msg.Height - 10
 		a.ready = true
+		if a.state == terminalView {
+			a.updateTerminalOutput()
+		}
 
 	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" && a.operation.running() {
+			return a.handleShutdown()
+		}
 		switch a.state {
 		case clusterSelectionView:
 			return a.updateClusterSelection(msg)
@@ -133,6 +329,48 @@ func (a *Application) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return a.updateAddCluster(msg)
 		case terminalView:
 			return a.updateTerminal(msg)
+		case gitStatusView:
+			return a.updateGitStatus(msg)
+		case clusterConfigsView:
+			return a.updateClusterConfigs(msg)
+		case configFormView:
+			return a.updateConfigForm(msg)
+		case setupRunView:
+			return a.updateSetupRun(msg)
+		case destroyConfirmView:
+			return a.updateDestroyConfirm(msg)
+		case bakeNodeView:
+			return a.updateBakeNode(msg)
+		case passphraseView:
+			return a.updatePassphrase(msg)
+		case dashboardView:
+			return a.updateDashboard(msg)
+		case resourceBrowserView:
+			return a.updateResourceBrowser(msg)
+		case rolloutView:
+			return a.updateRollout(msg)
+		case scaleView:
+			return a.updateScale(msg)
+		case jobsView:
+			return a.updateJobs(msg)
+		case multiLogView:
+			return a.updateMultiLog(msg)
+		case portForwardsView:
+			return a.updatePortForwards(msg)
+		case namespacesView:
+			return a.updateNamespaces(msg)
+		case applyView:
+			return a.updateApply(msg)
+		case templatesView:
+			return a.updateTemplates(msg)
+		case workspacesView:
+			return a.updateWorkspaces(msg)
+		case statsView:
+			return a.updateStats(msg)
+		case documentView:
+			return a.updateDocument(msg)
+		case wizardView:
+			return a.updateWizard(msg)
 		case loadingView:
 			if msg.String() == "esc" {
 				a.state = clusterSelectionView
@@ -146,24 +384,160 @@ func (a *Application) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case clusterAddedMsg:
 		return a.handleClusterAdded(msg.cluster)
 
+	case commandFinishedMsg:
+		a.inFlight--
+		a.running[msg.cluster]--
+		if a.selectedCluster != nil && msg.cluster != a.selectedCluster.Name {
+			return a.updateBackground(msg.cluster, msg.msg)
+		}
+		return a.update(msg.msg)
+
 	case commandExecutedMsg:
-		a.output = msg.output
+		if msg.table != nil {
+			a.tables[msg.cluster] = msg.table
+		}
+		// Documents taller than the terminal open in the viewer instead
+		if msg.doc != nil {
+			a.documents[msg.cluster] = msg.doc
+			if len(msg.doc.Lines) > a.viewport.Height && msg.cluster == a.selectedCluster.Name {
+				a.output += styles.InfoStyle.Render(a.tr("document.opened", len(msg.doc.Lines))) + "\n"
+				a.loading = false
+				a.updateTerminalOutput()
+				return a.openDocument(msg.doc)
+			}
+		}
+		if msg.output != "" {
+			a.output += strings.TrimRight(msg.output, "\n") + "\n"
+		}
 		a.loading = false
 		a.state = terminalView
 		a.updateTerminalOutput()
 		return a, nil
 
+	case confirmationRequiredMsg:
+		a.pendingCommand = msg.command
+		a.output += styles.ErrorStyle.Render(fmt.Sprintf("⚠️  %v", msg.err)) + "\n" +
+			styles.InfoStyle.Render(a.tr("policy.confirm_hint")) + "\n"
+		a.loading = false
+		a.state = terminalView
+		a.updateTerminalOutput()
+		return a, nil
+
+	case dependenciesRefreshedMsg:
+		return a, nil
+
+	case setupEventMsg:
+		return a.handleSetupEvent(msg)
+
+	case setupDoneMsg:
+		return a.handleSetupDone(msg)
+
+	case ShutdownMsg:
+		return a.handleShutdown()
+
+	case dashboardMsg:
+		return a.handleDashboard(msg)
+
+	case kubeconfigTickMsg:
+		return a, a.checkKubeconfigs()
+
+	case kubeconfigChangesMsg:
+		return a.handleKubeconfigChanges(msg)
+
+	case capabilityTickMsg:
+		return a, a.probeCapabilities()
+
+	case capabilitiesMsg:
+		return a.handleCapabilities(msg)
+
+	case settingsChangedMsg:
+		return a.handleSettingsChanged(msg)
+
+	case localeChangedMsg:
+		return a.handleLocaleChanged(msg)
+
+	case dashboardTickMsg:
+		return a.handleDashboardTick(msg)
+
+	case browserFrameMsg:
+		return a.handleBrowserFrame(msg)
+
+	case browserActionMsg:
+		return a.handleBrowserAction(msg)
+
+	case browserEditedMsg:
+		return a.handleBrowserEdited(msg)
+
+	case rolloutActionMsg:
+		return a.handleRolloutAction(msg)
+
+	case rolloutStatusMsg:
+		return a.handleRolloutStatus(msg)
+
+	case rolloutTickMsg:
+		return a.handleRolloutTick(msg)
+
+	case rolloutHistoryMsg:
+		return a.handleRolloutHistory(msg)
+
+	case scaleListMsg:
+		return a.handleScaleList(msg)
+
+	case jobsListMsg:
+		return a.handleJobsList(msg)
+
+	case jobsActionMsg:
+		return a.handleJobsAction(msg)
+
+	case jobsLogsMsg:
+		return a.handleJobsLogs(msg)
+
+	case multiLogPodsMsg:
+		return a.handleMultiLogPods(msg)
+
+	case multiLogStartedMsg:
+		return a.handleMultiLogStarted(msg)
+
+	case multiLogLinesMsg:
+		return a.handleMultiLogLines(msg)
+
+	case portForwardStartedMsg:
+		return a.handlePortForwardStarted(msg)
+
+	case portForwardLineMsg:
+		return a.handlePortForwardLine(msg)
+
+	case namespacesMsg:
+		return a.handleNamespaces(msg)
+
+	case namespaceActionMsg:
+		return a.handleNamespaceAction(msg)
+
+	case applyCheckMsg:
+		return a.handleApplyCheck(msg)
+
+	case applyActionMsg:
+		return a.handleApplyAction(msg)
+
+	case gitStatusMsg:
+		a.gitStatus = msg
+		return a, nil
+
 	case errorMsg:
-		a.output = styles.ErrorStyle.Render(fmt.Sprintf("Error: %v", msg.err))
+		a.output += styles.ErrorStyle.Render(a.tr("terminal.error", msg.err)) + "\n"
+		a.output += a.explainFailure(msg.err.Error())
 		a.loading = false
 		a.state = terminalView
 		a.updateTerminalOutput()
 		return a, nil
 
 	case spinner.TickMsg:
-		if a.loading {
+		if a.loading || a.inFlight > 0 || a.operation.running() || a.dashboard.loading {
 			a.spinner, cmd = a.spinner.Update(msg)
 			cmds = append(cmds, cmd)
+			if a.state == terminalView {
+				a.updateTerminalPrompt()
+			}
 		}
 	}
 
@@ -178,6 +552,12 @@ func (a *Application) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case terminalView:
 		a.viewport, cmd = a.viewport.Update(msg)
 		cmds = append(cmds, cmd)
+		// Clipboard pastes arrive after the key that asked for them
+		command := a.prompt.Value()
+		cmds = append(cmds, a.editPrompt(msg))
+		if a.prompt.Value() != command {
+			a.updateTerminalPrompt()
+		}
 	}
 
 	return a, tea.Batch(cmds...)
@@ -185,12 +565,33 @@ func (a *Application) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // handleClusterSelected handles cluster selection
 func (a *Application) handleClusterSelected(cluster *config.ClusterInfo) (tea.Model, tea.Cmd) {
+	if a.selectedCluster != nil && a.selectedCluster.Name != cluster.Name {
+		a.lastCluster = a.selectedCluster.Name
+	}
+	a.saveTerminal()
+	a.closeGitManager()
 	a.selectedCluster = cluster
 	a.kubectlExecutor = kubectl.NewExecutor(cluster)
+	a.kubectlExecutor.SetNamespace(cluster.Namespace)
+	a.kubectlExecutor.SetRateLimits(cluster.QPS, cluster.Burst)
+	timeout, err := cluster.Timeout()
+	if err != nil {
+		return a, func() tea.Msg {
+			return errorMsg{err: fmt.Errorf("failed to load cluster settings: %v", err)}
+		}
+	}
+	a.kubectlExecutor.SetRequestTimeout(timeout)
+
+	policy, err := a.clusterPolicy(cluster)
+	if err != nil {
+		return a, func() tea.Msg {
+			return errorMsg{err: fmt.Errorf("failed to load cluster policy: %v", err)}
+		}
+	}
+	a.kubectlExecutor.SetPolicy(policy)
 
 	// Initialize git manager if ArgoCD is configured
 	if cluster.HasArgoCD {
-		var err error
 		a.gitManager, err = git.NewManager(cluster, a.kubectlExecutor)
 		if err != nil {
 			return a, func() tea.Msg {
@@ -207,22 +608,67 @@ func (a *Application) handleClusterSelected(cluster *config.ClusterInfo) (tea.Mo
 	}
 
 	a.state = terminalView
-	a.setupTerminalViewport()
+	if !a.restoreTerminal() {
+		a.setupTerminalViewport()
+	}
 	return a, nil
 }
 
+// closeGitManager stops the background pushes of the cluster's git manager
+// and drops it
+func (a *Application) closeGitManager() {
+	if a.gitManager != nil {
+		a.gitManager.Close()
+		a.gitManager = nil
+	}
+}
+
+// Close stops the port-forwards and background pushes of the application,
+// as it exits or its workspace is replaced
+func (a *Application) Close() {
+	a.StopPortForwards()
+	a.closeGitManager()
+}
+
+// SetReadOnly blocks every command that changes clusters, Git repositories or
+// cluster setups, regardless of cluster settings and policies
+func (a *Application) SetReadOnly(readOnly bool) {
+	a.readOnly = readOnly
+	a.refreshTitles()
+}
+
+// clusterReadOnly reports whether modifications of a cluster are blocked
+func (a *Application) clusterReadOnly(cluster *config.ClusterInfo) bool {
+	return a.readOnly || (cluster != nil && cluster.ReadOnly)
+}
+
+// clusterPolicy returns the command policy of a cluster, made read-only by
+// the --read-only flag or the cluster's setting
+func (a *Application) clusterPolicy(cluster *config.ClusterInfo) (config.Policy, error) {
+	policy, err := a.config.PolicyForCluster(cluster)
+	if err != nil {
+		return policy, err
+	}
+	policy.ReadOnly = policy.ReadOnly || a.clusterReadOnly(cluster)
+	return policy, nil
+}
+
 // handleClusterAdded handles new cluster addition
 func (a *Application) handleClusterAdded(cluster *config.ClusterInfo) (tea.Model, tea.Cmd) {
-	// Refresh the cluster list
+	a.refreshClusterList()
+
+	// Select the new cluster
+	return a.handleClusterSelected(cluster)
+}
+
+// refreshClusterList rebuilds the cluster selection list from the registry
+func (a *Application) refreshClusterList() {
 	var items []list.Item
 	for _, c := range a.config.GetAllClusters() {
 		items = append(items, &clusterItem{cluster: c})
 	}
-	items = append(items, &addClusterItem{})
+	items = append(items, menuItems(a.catalog, a.config.Workspace)...)
 	a.list.SetItems(items)
-
-	// Select the new cluster
-	return a.handleClusterSelected(cluster)
 }
 
 // updateClusterSelection handles cluster selection view updates
@@ -243,8 +689,12 @@ func (a *Application) updateClusterSelection(msg tea.KeyMsg) (tea.Model, tea.Cmd
 			a.state = addClusterView
 			a.addClusterStep = 0
 			a.textInput.SetValue("")
-			a.textInput.Placeholder = "Enter cluster name..."
+			a.textInput.Placeholder = a.tr("add.name_placeholder")
 			return a, nil
+		case *managedConfigsItem:
+			return a.openClusterConfigs()
+		case *workspaceItem:
+			return a.openWorkspaces()
 		}
 
 	case "q", "ctrl+c":
@@ -285,7 +735,7 @@ func (a *Application) handleAddClusterStep() (tea.Model, tea.Cmd) {
 	case 0: // Cluster name
 		a.newCluster.Name = value
 		a.textInput.SetValue("")
-		a.textInput.Placeholder = "Enter public IP or DNS..."
+		a.textInput.Placeholder = a.tr("add.endpoint_placeholder")
 		a.addClusterStep++
 
 	case 1: // Public IP or DNS
@@ -295,12 +745,13 @@ func (a *Application) handleAddClusterStep() (tea.Model, tea.Cmd) {
 			a.newCluster.DNS = value
 		}
 		a.textInput.SetValue("")
-		a.textInput.Placeholder = "Enter path to kubeconfig file..."
+		a.textInput.Placeholder = a.tr("add.kubeconfig_placeholder")
 		a.addClusterStep++
 
 	case 2: // Kubeconfig path
+		value = config.ExpandPath(value)
 		a.loading = true
-		a.loadingMsg = "Adding cluster and verifying configuration..."
+		a.loadingMsg = a.tr("add.loading")
 		a.state = loadingView
 
 		return a, func() tea.Msg {
@@ -331,6 +782,10 @@ func (a *Application) addCluster(configPath string) error {
 	}
 
 	a.newCluster.ConfigPath = destPath
+	// Remember the original so external rotations of it are picked up
+	if sourcePath, err := filepath.Abs(configPath); err == nil {
+		a.newCluster.SourcePath = sourcePath
+	}
 
 	// Parse kubeconfig to get server information
 	kubeConfig, err := a.config.ParseKubeConfig(destPath)
@@ -338,8 +793,10 @@ func (a *Application) addCluster(configPath string) error {
 		return fmt.Errorf("failed to parse kubeconfig: %v", err)
 	}
 
-	if len(kubeConfig.Clusters) > 0 {
-		a.newCluster.Server = kubeConfig.Clusters[0].Cluster.Server
+	a.newCluster.Server = kubeConfig.PrimaryServer()
+
+	if auth, err := kubeConfig.CurrentAuth(); err == nil {
+		a.newCluster.AuthType = auth.Type
 	}
 
 	// Test connection using dependency checker
@@ -347,11 +804,13 @@ func (a *Application) addCluster(configPath string) error {
 		return fmt.Errorf("failed to connect to cluster: %v", err)
 	}
 
-	// For demo purposes, automatically set up ArgoCD
-	// In production, you would prompt the user
-	a.newCluster.HasArgoCD = true
+	// Clusters that can't be probed now are probed again in the background
+	if capabilities, err := detectCapabilities(&a.newCluster); err == nil {
+		a.newCluster.HasArgoCD = capabilities.ArgoCD
+		a.newCluster.HasPrometheus = capabilities.Prometheus
+	}
 	a.newCluster.GitRepo = "https://github.com/example/k8s-configs" // Placeholder
-	a.newCluster.GitRepoPath = fmt.Sprintf("/tmp/k8s-configs-%s", a.newCluster.Name)
+	a.newCluster.GitRepoPath = filepath.Join(os.TempDir(), fmt.Sprintf("k8s-configs-%s", a.newCluster.Name))
 
 	// Add cluster to configuration
 	if err := a.config.AddCluster(a.newCluster); err != nil {
@@ -370,24 +829,53 @@ func (a *Application) updateTerminal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "ctrl+c":
 		return a, tea.Quit
 	case "enter":
-		if a.currentCommand != "" {
-			return a.executeCommand()
+		command := a.prompt.Value()
+		if strings.TrimSpace(command) == "" {
+			break
 		}
+		// Like a shell, enter continues an unfinished command, so pasted
+		// lines and heredocs are read up to their end
+		if !kubectl.CommandComplete(command) {
+			a.prompt.SetValue(command + "\n")
+			a.updateTerminalPrompt()
+			return a, nil
+		}
+		return a.executeCommand()
 	case "ctrl+l":
 		a.output = ""
 		a.updateTerminalOutput()
 		return a, nil
+	case "ctrl+r":
+		a.revealSecrets = !a.revealSecrets
+		a.updateTerminalOutput()
+		return a, nil
+	case "ctrl+g":
+		if a.gitManager == nil {
+			a.output += styles.InfoStyle.Render(a.tr("git.not_configured")) + "\n"
+			a.updateTerminalOutput()
+			return a, nil
+		}
+		a.state = gitStatusView
+		a.gitStatus = gitStatusMsg{notice: a.tr("git.loading")}
+		return a, a.loadGitStatus(a.currentSession(), "")
+	case "ctrl+t":
+		return a.openResourceBrowser()
+	case "f5":
+		a.loading = true
+		a.state = loadingView
+		a.loadingMsg = a.tr("deps.loading")
+		return a, func() tea.Msg {
+			a.depsRefresher.Refresh()
+			return commandExecutedMsg{output: a.getDependencyInfo()}
+		}
 	default:
-		// Handle command input
-		switch msg.Type {
-		case tea.KeyBackspace:
-			if len(a.currentCommand) > 0 {
-				a.currentCommand = a.currentCommand[:len(a.currentCommand)-1]
-			}
-		case tea.KeyRunes:
-			a.currentCommand += string(msg.Runes)
+		if a.scrollsOutput(msg) {
+			break
 		}
+		// Edit the command, including with cursor movement and word keys
+		cmd := a.editPrompt(msg)
 		a.updateTerminalPrompt()
+		return a, cmd
 	}
 
 	var cmd tea.Cmd
@@ -397,50 +885,310 @@ func (a *Application) updateTerminal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 // executeCommand executes a kubectl command
 func (a *Application) executeCommand() (tea.Model, tea.Cmd) {
-	if a.currentCommand == "" {
+	if a.prompt.Value() == "" {
 		return a, nil
 	}
 
-	command := strings.TrimSpace(a.currentCommand)
+	command := strings.TrimSpace(a.prompt.Value())
+	a.prompt.Reset()
+	if command == "" {
+		a.updateTerminalPrompt()
+		return a, nil
+	}
 	a.commandHistory = append(a.commandHistory, command)
 
 	// Add command to output
-	a.output += fmt.Sprintf("%s %s\n",
-		styles.PromptStyle.Render(fmt.Sprintf("[%s]$", a.selectedCluster.Name)),
-		command)
+	a.output += a.echoCommand(command)
+	command, noSync := cutNoSync(command)
+	if command == "" {
+		a.updateTerminalOutput()
+		return a, nil
+	}
 
-	a.currentCommand = ""
-	a.loading = true
-	a.state = loadingView
-	a.loadingMsg = "Executing command..."
+	if command == "clear" {
+		a.output = ""
+		a.updateTerminalOutput()
+		return a, nil
+	}
+
+	// !<command> runs a local shell command instead of kubectl
+	if strings.HasPrefix(command, "!") {
+		a.pendingCommand = ""
+		// Shell commands could change anything, so they can't run in read-only mode
+		if a.clusterReadOnly(a.selectedCluster) {
+			a.output += styles.ErrorStyle.Render(a.tr("shell.read_only")) + "\n"
+			a.updateTerminalOutput()
+			return a, nil
+		}
+		return a.runShellCommand(strings.TrimSpace(command[1:]))
+	}
 
-	return a, func() tea.Msg {
+	// Rollouts of deployments and daemonsets are followed in their own view
+	if action, object, ok := parseRolloutCommand(command); ok {
+		a.pendingCommand = ""
+		return a.startRollout(action, object, command)
+	}
+	// scale without a target picks a deployment interactively
+	if parts := strings.Fields(command); parts[0] == "scale" && !hasPositionalArgs(parts[1:]) {
+		a.pendingCommand = ""
+		return a.openScalePicker(parts[1:])
+	}
+	if parts := strings.Fields(command); parts[0] == "jobs" {
+		a.pendingCommand = ""
+		return a.openJobs(parts[1:])
+	}
+	if parts := strings.Fields(command); parts[0] == "tail" {
+		a.pendingCommand = ""
+		return a.openMultiLog(parts[1:])
+	}
+	if command == "context" {
+		a.pendingCommand = ""
+		return a.listContexts()
+	}
+	if parts := strings.Fields(command); parts[0] == "switch" {
+		a.pendingCommand = ""
+		return a.switchCluster(parts[1:])
+	}
+	if command == "forwards" {
+		a.pendingCommand = ""
+		return a.openPortForwards()
+	}
+	if command == "namespaces" {
+		a.pendingCommand = ""
+		return a.openNamespaces()
+	}
+	// apply on its own picks a manifest and reviews it before applying
+	if command == "apply" {
+		a.pendingCommand = ""
+		return a.openApply()
+	}
+	if command == "templates" {
+		a.pendingCommand = ""
+		return a.openTemplates()
+	}
+	// create on its own, or with just a kind, fills in a form
+	if parts := strings.Fields(command); parts[0] == "create" && len(parts) <= 2 {
+		if _, ok := wizard.Find(parts[len(parts)-1]); ok || len(parts) == 1 {
+			a.pendingCommand = ""
+			return a.openWizard(parts[1:])
+		}
+	}
+	if command == "stats" {
+		a.pendingCommand = ""
+		return a.openStats()
+	}
+	if command == "view" {
+		a.pendingCommand = ""
+		return a.openDocument(a.documents[a.selectedCluster.Name])
+	}
+
+	// Commands run in the background so more can be queued while they execute
+	session := a.currentSession()
+	session.noSync = noSync
+	tick := a.startCommand(session)
+	a.updateTerminalOutput()
+
+	// Run or discard a command waiting for policy confirmation
+	pending := a.pendingCommand
+	a.pendingCommand = ""
+	if pending != "" && command == "confirm" {
+		pending, session.noSync = cutNoSync(pending)
+		return a, tea.Batch(tick, a.queueCommand(session, pending, func() tea.Msg {
+			return a.runKubectlCommand(session, pending, true)
+		}))
+	}
+
+	return a, tea.Batch(tick, func() tea.Msg {
 		// Handle built-in commands
-		if output := a.handleBuiltinCommand(command); output != "" {
-			return commandExecutedMsg{output: output}
+		if msg := a.handleSettingsCommand(session, command); msg != nil {
+			return commandFinishedMsg{cluster: session.cluster.Name, msg: msg}
+		}
+		if output := a.handleBuiltinCommand(session, command); output != "" {
+			return commandFinishedMsg{cluster: session.cluster.Name, msg: commandExecutedMsg{output: output}}
 		}
 
-		// Execute kubectl command
-		output, err := a.kubectlExecutor.ExecuteCommand(command)
+		return a.queueCommand(session, command, func() tea.Msg {
+			// Run terminal plugins before falling back to kubectl
+			if parts := strings.Fields(command); len(parts) > 0 {
+				if _, ok := a.pluginManager.Get(parts[0]); ok {
+					if a.clusterReadOnly(session.cluster) {
+						return errorMsg{err: fmt.Errorf("plugins are disabled in read-only mode")}
+					}
+					started := time.Now()
+					output, err := a.pluginManager.Run(parts[0], parts[1:], session.cluster, session.executor.Namespace())
+					a.recordResult(session.cluster.Name, audit.SourcePlugin, command, started, err)
+					if err != nil {
+						return errorMsg{err: fmt.Errorf("%v\n%s", err, output)}
+					}
+					return commandExecutedMsg{output: output}
+				}
+			}
+
+			return a.runKubectlCommand(session, command, false)
+		})()
+	})
+}
+
+// runShellCommand runs a local shell command in the background. Shell commands
+// don't touch the cluster queue, but see the selected cluster through the same
+// environment variables as plugins.
+func (a *Application) runShellCommand(command string) (tea.Model, tea.Cmd) {
+	if command == "" {
+		a.output += styles.InfoStyle.Render(a.tr("shell.usage")) + "\n"
+		a.updateTerminalOutput()
+		return a, nil
+	}
+
+	session := a.currentSession()
+	tick := a.startCommand(session)
+	a.updateTerminalOutput()
+
+	return a, tea.Batch(tick, func() tea.Msg {
+		started := time.Now()
+		output, err := system.RunShell(command, plugins.Environment(session.cluster, session.executor.Namespace()))
+		a.recordResult(session.cluster.Name, audit.SourceShell, command, started, err)
 		if err != nil {
-			return errorMsg{err: err}
+			return commandFinishedMsg{cluster: session.cluster.Name, msg: errorMsg{err: fmt.Errorf("%v\n%s", err, output)}}
 		}
+		return commandFinishedMsg{cluster: session.cluster.Name, msg: commandExecutedMsg{output: output}}
+	})
+}
 
-		// Check if command modifies resources and sync to git
-		if kubectl.IsModifyingCommand(command) && a.gitManager != nil {
-			if syncErr := a.gitManager.SyncChanges(""); syncErr != nil {
-				output += "\n" + styles.ErrorStyle.Render(fmt.Sprintf("Git sync warning: %v", syncErr))
-			} else {
-				output += "\n" + styles.SuccessStyle.Render("✅ Changes synced to Git repository")
-			}
+// currentSession captures the cluster state a command runs against, so
+// queued commands keep their cluster when the user switches clusters
+func (a *Application) currentSession() clusterSession {
+	return clusterSession{
+		cluster:    a.selectedCluster,
+		executor:   a.kubectlExecutor,
+		gitManager: a.gitManager,
+	}
+}
+
+// queueCommand returns a command that waits for its turn in the cluster's queue before running
+func (a *Application) queueCommand(session clusterSession, command string, run func() tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		var msg tea.Msg
+		err := a.commandQueue.Run(session.cluster.Name, command, func() {
+			msg = run()
+		})
+		if errors.Is(err, kubectl.ErrCanceled) {
+			msg = commandExecutedMsg{output: styles.InfoStyle.Render(a.tr("queue.command_canceled", command))}
 		}
+		return commandFinishedMsg{cluster: session.cluster.Name, msg: msg}
+	}
+}
+
+// recordResult audits a command and adds its latency to the usage statistics
+func (a *Application) recordResult(cluster, source, command string, started time.Time, err error) {
+	a.auditLog.RecordResult(cluster, source, command, started, err)
+	a.stats.RecordCommand(cluster, source, command, time.Since(started), err)
+}
 
-		return commandExecutedMsg{output: output}
+// runKubectlCommand executes a kubectl command and syncs modifications to git
+func (a *Application) runKubectlCommand(session clusterSession, command string, confirmed bool) tea.Msg {
+	started := time.Now()
+	var output string
+	var err error
+	if confirmed {
+		output, err = session.executor.ExecuteCommandConfirmed(command)
+	} else {
+		output, err = session.executor.ExecuteCommand(command)
 	}
+
+	var policyErr *kubectl.PolicyError
+	if errors.As(err, &policyErr) && policyErr.NeedsConfirmation {
+		// Keep the opt-out for the confirmed run
+		if session.noSync {
+			command += " " + noSyncSuffix
+		}
+		return confirmationRequiredMsg{command: command, err: policyErr}
+	}
+
+	a.recordResult(session.cluster.Name, audit.SourceKubectl, command, started, err)
+	if err != nil {
+		// Auth errors already carry kubectl's output
+		var authErr *kubectl.AuthError
+		if output = strings.TrimSpace(output); output != "" && !errors.As(err, &authErr) {
+			err = fmt.Errorf("%v\n%s", err, output)
+		}
+		return errorMsg{err: err}
+	}
+
+	// Remember tables so their rows can be described
+	if table, ok := kubectl.ParseTable(command, output); ok {
+		return commandExecutedMsg{output: output, cluster: session.cluster.Name, table: table}
+	}
+
+	// Structured output is browsed in the document viewer
+	if !kubectl.IsModifyingCommand(command) {
+		if document, ok := kubectl.ParseDocument(command, output); ok {
+			return commandExecutedMsg{output: output, cluster: session.cluster.Name, doc: document}
+		}
+	}
+
+	// Point out known problems such as image pull failures in describe output
+	if strings.Fields(command)[0] == "describe" {
+		output += a.explainFailure(output)
+	}
+
+	return commandExecutedMsg{output: output + a.syncChanges(session, command)}
+}
+
+// explainFailure formats the explanation of a known kubectl failure and the
+// command to run next, or returns "" for unknown failures
+func (a *Application) explainFailure(output string) string {
+	explanation, ok := kubectl.ExplainFailure(output)
+	if !ok {
+		return ""
+	}
+	return "\n" + styles.InfoStyle.Render("💡 "+explanation.Problem) + "\n" +
+		styles.InfoStyle.Render("   "+a.tr("explain.try")+" ") + explanation.Suggestion + "\n"
 }
 
-// handleBuiltinCommand handles built-in terminal commands
-func (a *Application) handleBuiltinCommand(command string) string {
+// syncChanges syncs the resources a modifying command touched to git and
+// returns a status line for the terminal
+func (a *Application) syncChanges(session clusterSession, command string) string {
+	if !kubectl.IsModifyingCommand(command) || session.gitManager == nil {
+		return ""
+	}
+	if session.noSync {
+		return "\n" + styles.InfoStyle.Render(a.tr("git.sync_skipped"))
+	}
+
+	// Only re-export the resources the command touched when they can be determined
+	scope, scoped := kubectl.AffectedResources(command)
+	if scoped && len(scope.Resources) == 0 {
+		return ""
+	}
+	started := time.Now()
+	head := session.gitManager.Head()
+	var syncErr error
+	if scoped {
+		syncErr = session.gitManager.SyncResources(scope, "")
+	} else {
+		syncErr = session.gitManager.SyncChanges("")
+	}
+	a.recordResult(session.cluster.Name, audit.SourceGit, "sync", started, syncErr)
+	var pushErr *git.PushError
+	if errors.As(syncErr, &pushErr) {
+		return "\n" + styles.ErrorStyle.Render(a.tr("git.push_queued", pushErr.Pending)) +
+			a.rbacSyncWarnings(session.gitManager, head)
+	}
+	var exportErr *git.ExportError
+	if errors.As(syncErr, &exportErr) {
+		return "\n" + styles.ErrorStyle.Render(a.tr("git.synced_partial", exportErr)) +
+			a.rbacSyncWarnings(session.gitManager, head)
+	}
+	if syncErr != nil {
+		return "\n" + styles.ErrorStyle.Render(a.tr("git.sync_warning", syncErr))
+	}
+	return "\n" + styles.SuccessStyle.Render(a.tr("git.synced")) + a.rbacSyncWarnings(session.gitManager, head)
+}
+
+// handleBuiltinCommand handles built-in terminal commands. It runs in the
+// background, so the built-ins work on the session the command was entered
+// in instead of the selected cluster.
+func (a *Application) handleBuiltinCommand(session clusterSession, command string) string {
 	parts := strings.Fields(command)
 	if len(parts) == 0 {
 		return ""
@@ -454,16 +1202,128 @@ func (a *Application) handleBuiltinCommand(command string) string {
 	case "cluster-info":
 		return a.getClusterInfo()
 	case "deps":
+		if len(parts) > 1 && parts[1] == "refresh" {
+			a.depsRefresher.Refresh()
+		}
 		return a.getDependencyInfo()
+	case "plugins":
+		return a.getPluginInfo()
+	case "audit":
+		return a.getAuditInfo(parts[1:])
+	case "policy":
+		return a.getPolicyInfo()
+	case "relogin":
+		return a.relogin()
+	case "git-status":
+		return a.getGitStatusInfo(session)
+	case "git-bootstrap":
+		return a.bootstrapGit(session, parts[1:])
+	case "sync":
+		return a.syncGit(session, strings.TrimSpace(strings.TrimPrefix(command, "sync")))
+	case "rbac":
+		return a.getRBACInfo(session, parts[1:])
+	case "setup-logs":
+		return a.getSetupLogInfo(parts[1:])
+	case "setups":
+		return a.getSetupsInfo(parts[1:])
+	case "queue":
+		return a.getQueueInfo()
+	case "cancel":
+		return a.cancelQueuedCommand(parts[1:])
 	default:
 		return "" // Not a built-in command
 	}
 }
 
-// View renders the current view
-func (a *Application) View() string {
+// handleSettingsCommand handles the built-in commands that change the
+// application's state. They return messages that apply the change in Update.
+// It returns nil for other commands.
+func (a *Application) handleSettingsCommand(session clusterSession, command string) tea.Msg {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return nil
+	}
+
+	switch parts[0] {
+	case "settings":
+		return a.clusterSettings(session, parts[1:])
+	case "locale":
+		return a.localeSettings(parts[1:])
+	default:
+		return nil
+	}
+}
+
+// updateGitStatus handles git status view updates
+func (a *Application) updateGitStatus(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	session := a.currentSession()
+	switch msg.String() {
+	case "esc", "q":
+		a.state = terminalView
+		a.updateTerminalOutput()
+	case "ctrl+c":
+		return a, tea.Quit
+	case "r":
+		a.gitStatus.notice = a.tr("git.refreshing")
+		return a, a.loadGitStatus(session, "")
+	case "s":
+		if a.clusterReadOnly(session.cluster) {
+			a.gitStatus.notice = a.tr("git.read_only")
+			return a, nil
+		}
+		a.gitStatus.notice = a.tr("git.syncing")
+		return a, func() tea.Msg {
+			// Sync through the command queue so it doesn't race kubectl commands
+			var syncErr error
+			err := a.commandQueue.Run(session.cluster.Name, "git sync", func() {
+				started := time.Now()
+				syncErr = session.gitManager.SyncChanges("")
+				a.recordResult(session.cluster.Name, audit.SourceGit, "sync", started, syncErr)
+			})
+			notice := a.tr("git.synced")
+			var pushErr *git.PushError
+			if errors.Is(err, kubectl.ErrCanceled) {
+				notice = a.tr("git.sync_canceled")
+			} else if errors.As(syncErr, &pushErr) {
+				notice = a.tr("git.push_queued", pushErr.Pending)
+			} else if syncErr != nil {
+				notice = a.tr("git.sync_failed", syncErr)
+			}
+			return a.loadGitStatus(session, notice)()
+		}
+	case "o":
+		if a.gitStatus.status == nil || a.gitStatus.status.WebURL == "" {
+			return a, nil
+		}
+		if err := system.OpenURL(a.gitStatus.status.WebURL); err != nil {
+			a.gitStatus.notice = fmt.Sprintf("❌ %v", err)
+		} else {
+			a.gitStatus.notice = a.tr("git.opened", a.gitStatus.status.WebURL)
+		}
+	}
+	return a, nil
+}
+
+// loadGitStatus reads the repository status in the background
+func (a *Application) loadGitStatus(session clusterSession, notice string) tea.Cmd {
+	return func() tea.Msg {
+		status, err := session.gitManager.Status()
+		return gitStatusMsg{status: status, err: err, notice: notice}
+	}
+}
+
+// View renders the current view. A panic is reported like one in Update, and
+// the next message ends the program.
+func (a *Application) View() (view string) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.recordCrash(r, debug.Stack())
+			view = styles.ErrorStyle.Render("\n  " + a.tr("app.crashed"))
+		}
+	}()
+
 	if !a.ready {
-		return "\n  Initializing..."
+		return "\n  " + a.tr("app.initializing")
 	}
 
 	switch a.state {
@@ -473,6 +1333,48 @@ func (a *Application) View() string {
 		return a.renderAddCluster()
 	case terminalView:
 		return a.renderTerminal()
+	case gitStatusView:
+		return a.renderGitStatus()
+	case clusterConfigsView:
+		return a.renderClusterConfigs()
+	case configFormView:
+		return a.renderConfigForm()
+	case setupRunView:
+		return a.renderSetupRun()
+	case destroyConfirmView:
+		return a.renderDestroyConfirm()
+	case bakeNodeView:
+		return a.renderBakeNode()
+	case passphraseView:
+		return a.renderPassphrase()
+	case dashboardView:
+		return a.renderDashboard()
+	case resourceBrowserView:
+		return a.renderResourceBrowser()
+	case rolloutView:
+		return a.renderRollout()
+	case scaleView:
+		return a.renderScale()
+	case jobsView:
+		return a.renderJobs()
+	case multiLogView:
+		return a.renderMultiLog()
+	case portForwardsView:
+		return a.renderPortForwards()
+	case namespacesView:
+		return a.renderNamespaces()
+	case applyView:
+		return a.renderApply()
+	case templatesView:
+		return a.renderTemplates()
+	case workspacesView:
+		return a.renderWorkspaces()
+	case statsView:
+		return a.renderStats()
+	case documentView:
+		return a.renderDocument()
+	case wizardView:
+		return a.renderWizard()
 	case loadingView:
 		return a.renderLoading()
 	}
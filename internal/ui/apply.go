@@ -0,0 +1,389 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/audit"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// Messages for the apply flow
+type applyCheckMsg struct {
+	path   string
+	checks []applyCheck
+	diff   string
+}
+type applyActionMsg struct{ result tea.Msg }
+
+// applyCheck is the result of one step run before applying
+type applyCheck struct {
+	name   string
+	output string
+	err    error
+}
+
+// manifestEntry is a directory or manifest file in the apply file browser
+type manifestEntry struct {
+	name  string
+	isDir bool
+}
+
+// applyFlow picks local manifests, checks them against the cluster and applies them
+type applyFlow struct {
+	session clusterSession
+	dir     string
+	entries []manifestEntry
+	cursor  int
+	loading string
+	notice  string
+	// Set when reviewing a generated manifest instead of a picked file: what
+	// it was generated from and the view esc returns to
+	template string
+	manifest string
+	source   sessionState
+	// Set once a manifest is picked and checked
+	path     string
+	checks   []applyCheck
+	viewport viewport.Model
+}
+
+// passed reports whether every check succeeded
+func (f *applyFlow) passed() bool {
+	for _, check := range f.checks {
+		if check.err != nil {
+			return false
+		}
+	}
+	return len(f.checks) > 0
+}
+
+// openApply opens the manifest browser in the working directory
+func (a *Application) openApply() (tea.Model, tea.Cmd) {
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "."
+	}
+	a.apply = applyFlow{
+		session:  a.currentSession(),
+		viewport: viewport.New(a.width-4, a.height-10),
+	}
+	a.state = applyView
+	a.readManifestDir(dir)
+	return a, nil
+}
+
+// readManifestDir lists the subdirectories and manifests of a directory
+func (a *Application) readManifestDir(dir string) {
+	f := &a.apply
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		f.notice = styles.ErrorStyle.Render(a.tr("apply.read_failed", dir, err))
+		return
+	}
+
+	var manifests []manifestEntry
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if entry.IsDir() || kubectl.IsManifestFile(entry.Name()) {
+			manifests = append(manifests, manifestEntry{name: entry.Name(), isDir: entry.IsDir()})
+		}
+	}
+	// Directories first, then files
+	sort.SliceStable(manifests, func(i, j int) bool {
+		return manifests[i].isDir && !manifests[j].isDir
+	})
+
+	f.dir = dir
+	f.entries = append([]manifestEntry{{name: "..", isDir: true}}, manifests...)
+	f.cursor = 0
+	f.notice = ""
+}
+
+// displayPath shortens a path to be relative to the working directory when it's inside it
+func displayPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+// checkManifest validates a manifest, dry-runs it on the server and diffs it against the cluster
+func (a *Application) checkManifest(path string) tea.Cmd {
+	f := &a.apply
+	// The apply itself goes through the terminal's command parser, which splits on spaces
+	if strings.ContainsAny(path, " \t") {
+		f.notice = styles.ErrorStyle.Render(a.tr("apply.spaces"))
+		return nil
+	}
+	f.loading = a.tr("apply.checking", displayPath(path))
+	f.notice = ""
+
+	session := f.session
+	return func() tea.Msg {
+		msg := applyCheckMsg{path: path}
+		run := func(name string, args ...string) bool {
+			output, err := a.runBrowserCommand(session, args)
+			msg.checks = append(msg.checks, applyCheck{name: name, output: output, err: err})
+			return err == nil
+		}
+
+		// Without a valid manifest the server steps only repeat the same error
+		if !run(a.tr("apply.client_validation"), "apply", "--dry-run=client", "-f", path) {
+			return msg
+		}
+		if !run(a.tr("apply.server_dry_run"), "apply", "--dry-run=server", "-f", path) {
+			return msg
+		}
+
+		started := time.Now()
+		diff, changed, err := session.executor.Diff("-f", path)
+		a.recordResult(session.cluster.Name, audit.SourceKubectl, "diff -f "+path, started, err)
+		if err != nil {
+			err = fmt.Errorf("%v\n%s", err, diff)
+		}
+		msg.checks = append(msg.checks, applyCheck{name: a.tr("apply.diff_check"), err: err})
+		if err == nil && !changed {
+			diff = a.tr("apply.no_changes")
+		}
+		msg.diff = diff
+		return msg
+	}
+}
+
+// handleApplyCheck shows the check results and the diff
+func (a *Application) handleApplyCheck(msg applyCheckMsg) (tea.Model, tea.Cmd) {
+	f := &a.apply
+	f.loading = ""
+	f.path = msg.path
+	f.checks = msg.checks
+
+	var b strings.Builder
+	if f.manifest != "" {
+		b.WriteString(styles.HeaderStyle.Render(a.tr("apply.manifest")) + "\n")
+		b.WriteString(strings.TrimRight(f.manifest, "\n") + "\n\n")
+	}
+	for _, check := range msg.checks {
+		if check.err != nil {
+			b.WriteString(styles.ErrorStyle.Render("❌ "+check.name) + "\n")
+			b.WriteString(strings.TrimRight(check.err.Error(), "\n") + "\n\n")
+			continue
+		}
+		b.WriteString(styles.SuccessStyle.Render("✅ "+check.name) + "\n")
+		if output := strings.TrimSpace(check.output); output != "" {
+			b.WriteString(output + "\n")
+		}
+		b.WriteString("\n")
+	}
+	if msg.diff != "" {
+		b.WriteString(styles.HeaderStyle.Render(a.tr("apply.diff")) + "\n")
+		b.WriteString(highlightDiff(msg.diff))
+	}
+
+	f.viewport.Width = a.width - 4
+	f.viewport.Height = a.height - 10
+	f.viewport.SetContent(b.String())
+	f.viewport.GotoTop()
+	return a, nil
+}
+
+// highlightDiff colors added and removed lines of a unified diff
+func highlightDiff(diff string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "diff "):
+			line = styles.HeaderStyle.Render(line)
+		case strings.HasPrefix(line, "+"):
+			line = styles.SuccessStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			line = styles.ErrorStyle.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			line = styles.InfoStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// applyManifest applies the checked manifest through the command queue, like a terminal command
+func (a *Application) applyManifest() tea.Cmd {
+	f := &a.apply
+	session := f.session
+	command := "apply -f " + displayPath(f.path)
+	f.loading = a.tr("apply.applying", displayPath(f.path))
+	a.output += fmt.Sprintf("%s %s\n", styles.PromptStyle.Render(fmt.Sprintf("[%s]$", session.cluster.Name)), command)
+
+	return tea.Batch(a.startCommand(session), a.queueCommand(session, command, func() tea.Msg {
+		return applyActionMsg{result: a.runKubectlCommand(session, command, false)}
+	}))
+}
+
+// handleApplyAction returns to the terminal once the manifest is applied
+func (a *Application) handleApplyAction(msg applyActionMsg) (tea.Model, tea.Cmd) {
+	f := &a.apply
+	f.loading = ""
+	switch result := msg.result.(type) {
+	case commandExecutedMsg:
+		a.output += strings.TrimRight(result.output, "\n") + "\n"
+		a.discardRenderedTemplate()
+		if a.state == applyView {
+			a.state = terminalView
+			a.updateTerminalOutput()
+		}
+	case errorMsg:
+		a.output += styles.ErrorStyle.Render(a.tr("terminal.error", result.err)) + "\n"
+		f.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", result.err))
+	case confirmationRequiredMsg:
+		a.pendingCommand = result.command
+		f.notice = styles.ErrorStyle.Render(a.tr("policy.confirm_terminal", result.err))
+	}
+	return a, nil
+}
+
+// discardRenderedTemplate removes the temporary file of a rendered template
+func (a *Application) discardRenderedTemplate() {
+	if a.apply.template != "" && a.apply.path != "" {
+		os.Remove(a.apply.path)
+	}
+}
+
+// updateApply handles apply flow updates
+func (a *Application) updateApply(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	f := &a.apply
+	key := msg.String()
+	if key == "ctrl+c" {
+		return a, tea.Quit
+	}
+
+	if f.path != "" || f.template != "" {
+		switch key {
+		case "esc":
+			if f.template != "" {
+				a.discardRenderedTemplate()
+				a.state = f.source
+				return a, nil
+			}
+			f.path = ""
+			f.checks = nil
+			f.notice = ""
+			return a, nil
+		case "r":
+			return a, a.checkManifest(f.path)
+		case "a", "y":
+			if f.loading != "" {
+				return a, nil
+			}
+			if !f.passed() {
+				f.notice = styles.ErrorStyle.Render(a.tr("apply.checks_failed"))
+				return a, nil
+			}
+			return a, a.applyManifest()
+		}
+		var cmd tea.Cmd
+		f.viewport, cmd = f.viewport.Update(msg)
+		return a, cmd
+	}
+
+	switch key {
+	case "esc":
+		a.state = terminalView
+		a.updateTerminalOutput()
+	case "up", "k":
+		if f.cursor > 0 {
+			f.cursor--
+		}
+	case "down", "j":
+		if f.cursor < len(f.entries)-1 {
+			f.cursor++
+		}
+	case "backspace", "left", "h":
+		a.readManifestDir(filepath.Dir(f.dir))
+	case "enter", "right", "l":
+		if f.cursor >= len(f.entries) {
+			return a, nil
+		}
+		entry := f.entries[f.cursor]
+		path := filepath.Join(f.dir, entry.name)
+		if entry.isDir {
+			a.readManifestDir(path)
+			return a, nil
+		}
+		return a, a.checkManifest(path)
+	case "c":
+		// Check a whole directory of manifests, or the current one on ".."
+		if f.cursor < len(f.entries) && f.entries[f.cursor].isDir {
+			path := f.dir
+			if f.entries[f.cursor].name != ".." {
+				path = filepath.Join(f.dir, f.entries[f.cursor].name)
+			}
+			return a, a.checkManifest(path)
+		}
+	}
+	return a, nil
+}
+
+// renderApply renders the apply flow
+func (a *Application) renderApply() string {
+	f := &a.apply
+
+	status := f.notice
+	if f.loading != "" {
+		status = styles.LoadingStyle.Render("⏳ " + f.loading)
+	}
+
+	if f.path != "" || f.template != "" {
+		back := a.tr("apply.back_files")
+		title := a.tr("apply.title_file", displayPath(f.path))
+		if f.template != "" {
+			back = a.tr("apply.back_templates")
+			title = a.tr("apply.title_template", f.template)
+			if f.source == wizardView {
+				back = a.tr("apply.back_form")
+				title = a.tr("apply.title_create", f.template)
+			}
+		}
+		footer := a.tr("apply.review_footer", back)
+		if !f.passed() {
+			footer = a.tr("apply.failed_footer", back)
+		}
+		return fmt.Sprintf("\n%s\n\n%s\n%s\n%s",
+			styles.TitleStyle.Render(title),
+			f.viewport.View(),
+			status,
+			styles.InfoStyle.Render(footer))
+	}
+
+	var b strings.Builder
+	for i, entry := range f.entries {
+		line := "  📄 " + entry.name
+		if entry.isDir {
+			line = "  📁 " + entry.name + "/"
+		}
+		if i == f.cursor {
+			line = styles.SelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(f.entries) == 1 {
+		b.WriteString(styles.InfoStyle.Render("  "+a.tr("apply.no_manifests")) + "\n")
+	}
+
+	return fmt.Sprintf("\n%s\n%s\n\n%s\n%s\n%s",
+		styles.TitleStyle.Render(a.tr("apply.title")),
+		styles.InfoStyle.Render(f.dir),
+		b.String(),
+		status,
+		styles.InfoStyle.Render(a.tr("apply.footer")))
+}
@@ -0,0 +1,121 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// capabilityCheckInterval is how often clusters are probed for ArgoCD and Prometheus
+const capabilityCheckInterval = 5 * time.Minute
+
+// capabilityTickMsg triggers probing the clusters
+type capabilityTickMsg struct{}
+
+// capabilitiesMsg carries the add-ons found on the clusters that could be probed
+type capabilitiesMsg struct {
+	found map[string]kubectl.Capabilities
+}
+
+// watchCapabilities schedules the next probe
+func watchCapabilities() tea.Cmd {
+	return tea.Tick(capabilityCheckInterval, func(time.Time) tea.Msg {
+		return capabilityTickMsg{}
+	})
+}
+
+// detectCapabilities probes a cluster for the add-ons it runs
+func detectCapabilities(cluster *config.ClusterInfo) (kubectl.Capabilities, error) {
+	executor := kubectl.NewExecutor(cluster)
+	if timeout, err := cluster.Timeout(); err == nil {
+		executor.SetRequestTimeout(timeout)
+	}
+	return executor.DetectCapabilities()
+}
+
+// probeCapabilities probes every registered cluster in parallel. Clusters
+// that can't be reached keep their flags.
+func (a *Application) probeCapabilities() tea.Cmd {
+	clusters := append([]config.ClusterInfo(nil), a.config.GetAllClusters()...)
+	return func() tea.Msg {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		found := make(map[string]kubectl.Capabilities)
+		for i := range clusters {
+			wg.Add(1)
+			go func(cluster *config.ClusterInfo) {
+				defer wg.Done()
+				capabilities, err := detectCapabilities(cluster)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				found[cluster.Name] = capabilities
+				mu.Unlock()
+			}(&clusters[i])
+		}
+		wg.Wait()
+		return capabilitiesMsg{found: found}
+	}
+}
+
+// handleCapabilities saves the add-ons found on clusters whose flags were out
+// of date and tells the user what changed
+func (a *Application) handleCapabilities(msg capabilitiesMsg) (tea.Model, tea.Cmd) {
+	changed := false
+	for name, found := range msg.found {
+		cluster, err := a.config.GetCluster(name)
+		if err != nil {
+			// Removed while it was probed
+			continue
+		}
+		if cluster.HasArgoCD == found.ArgoCD && cluster.HasPrometheus == found.Prometheus {
+			continue
+		}
+
+		var notes []string
+		if cluster.HasArgoCD != found.ArgoCD {
+			notes = append(notes, a.capabilityNote("ArgoCD", found.ArgoCD))
+		}
+		if cluster.HasPrometheus != found.Prometheus {
+			notes = append(notes, a.capabilityNote("Prometheus", found.Prometheus))
+		}
+		cluster.HasArgoCD, cluster.HasPrometheus = found.ArgoCD, found.Prometheus
+		if err := a.config.UpdateCluster(*cluster); err != nil {
+			a.output += styles.ErrorStyle.Render(a.tr("capabilities.save_failed", name, err)) + "\n"
+			continue
+		}
+		changed = true
+
+		notice := fmt.Sprintf("🔄 %s: %s", name, strings.Join(notes, ", "))
+		if a.selectedCluster != nil && a.selectedCluster.Name == name {
+			a.selectedCluster.HasArgoCD, a.selectedCluster.HasPrometheus = found.ArgoCD, found.Prometheus
+			if found.ArgoCD && a.gitManager == nil {
+				notice += " - " + a.tr("capabilities.reselect")
+			}
+		}
+		a.output += styles.InfoStyle.Render(notice) + "\n"
+	}
+
+	if changed {
+		a.refreshClusterList()
+		if a.state == terminalView {
+			a.updateTerminalOutput()
+		}
+	}
+	return a, watchCapabilities()
+}
+
+// capabilityNote describes an add-on that appeared on or left a cluster
+func (a *Application) capabilityNote(addOn string, installed bool) string {
+	if installed {
+		return a.tr("capabilities.detected", addOn)
+	}
+	return a.tr("capabilities.removed", addOn)
+}
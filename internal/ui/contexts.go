@@ -0,0 +1,173 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// terminalSession is the terminal of a cluster the user switched away from:
+// its transcript, command history, namespace, the command being typed and
+// the one waiting for confirmation. It's restored when they come back to it.
+type terminalSession struct {
+	output         string
+	history        []string
+	pendingCommand string
+	namespace      string
+	draft          string
+}
+
+// captureTerminal returns the transcript and commands of the current terminal
+func (a *Application) captureTerminal() terminalSession {
+	return terminalSession{
+		output:         a.output,
+		history:        a.commandHistory,
+		pendingCommand: a.pendingCommand,
+	}
+}
+
+// loadTerminal makes a captured transcript and commands the current ones
+func (a *Application) loadTerminal(session terminalSession) {
+	a.output = session.output
+	a.commandHistory = session.history
+	a.pendingCommand = session.pendingCommand
+}
+
+// saveTerminal keeps the terminal of the current cluster for when the user
+// switches back to it
+func (a *Application) saveTerminal() {
+	if a.selectedCluster == nil || a.kubectlExecutor == nil {
+		return
+	}
+	if a.terminals == nil {
+		a.terminals = make(map[string]terminalSession)
+	}
+	session := a.captureTerminal()
+	session.namespace = a.kubectlExecutor.Namespace()
+	session.draft = a.prompt.Value()
+	a.terminals[a.selectedCluster.Name] = session
+}
+
+// restoreTerminal brings back the terminal of a cluster used earlier in the
+// session, reporting false for clusters that have none, whose terminal
+// starts empty
+func (a *Application) restoreTerminal() bool {
+	session, ok := a.terminals[a.selectedCluster.Name]
+	a.loadTerminal(session)
+	a.prompt.SetValue(session.draft)
+	if !ok {
+		return false
+	}
+	a.output += styles.TitleStyle.Render(a.tr("terminal.connected", a.selectedCluster.Name)) + "\n"
+	a.kubectlExecutor.SetNamespace(session.namespace)
+	a.updateTerminalOutput()
+	return true
+}
+
+// updateBackground handles the result of a command of a cluster the user
+// switched away from against that cluster's terminal, leaving the current
+// one and the open view as they are
+func (a *Application) updateBackground(cluster string, msg tea.Msg) (tea.Model, tea.Cmd) {
+	background, ok := a.terminals[cluster]
+	if !ok {
+		// The cluster was forgotten while the command ran
+		return a, nil
+	}
+	current, state := a.captureTerminal(), a.state
+	a.loadTerminal(background)
+	_, cmd := a.update(msg)
+
+	updated := a.captureTerminal()
+	updated.namespace, updated.draft = background.namespace, background.draft
+	a.terminals[cluster] = updated
+	a.loadTerminal(current)
+	a.state = state
+	a.updateTerminalOutput()
+	return a, cmd
+}
+
+// startCommand counts a command of the session's cluster as running,
+// returning the spinner tick when no other command is
+func (a *Application) startCommand(session clusterSession) tea.Cmd {
+	var tick tea.Cmd
+	if a.inFlight == 0 && !a.loading {
+		tick = a.spinner.Tick
+	}
+	a.inFlight++
+	a.running[session.cluster.Name]++
+	return tick
+}
+
+// listContexts lists the registered clusters with the namespace their
+// terminal runs commands in, marking the current one
+func (a *Application) listContexts() (tea.Model, tea.Cmd) {
+	var b strings.Builder
+	b.WriteString(styles.HeaderStyle.Render(a.tr("contexts.title")) + "\n")
+	for _, cluster := range a.config.GetAllClusters() {
+		namespace := cluster.Namespace
+		if session, ok := a.terminals[cluster.Name]; ok {
+			namespace = session.namespace
+		}
+		if cluster.Name == a.selectedCluster.Name {
+			namespace = a.kubectlExecutor.Namespace()
+		}
+		if namespace == "" {
+			namespace = a.tr("contexts.default_namespace")
+		}
+
+		marker := "  "
+		switch cluster.Name {
+		case a.selectedCluster.Name:
+			marker = "★ "
+		case a.lastCluster:
+			marker = "- "
+		}
+		line := fmt.Sprintf("%s%-20s %-40s %s", marker, cluster.Name, cluster.Server, namespace)
+		if running := a.running[cluster.Name]; running > 0 {
+			line += " " + a.tr("contexts.running", running)
+		}
+		if cluster.Name == a.selectedCluster.Name {
+			line = styles.SuccessStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	b.WriteString(styles.InfoStyle.Render(a.tr("contexts.hint")) + "\n")
+
+	a.output += b.String()
+	a.updateTerminalOutput()
+	return a, nil
+}
+
+// switchCluster makes another registered cluster the active one, keeping the
+// terminal of the current one for when the user switches back. Its running
+// commands carry on and report to that terminal. "-" switches to the previous
+// cluster.
+func (a *Application) switchCluster(args []string) (tea.Model, tea.Cmd) {
+	fail := func(message string) (tea.Model, tea.Cmd) {
+		a.output += styles.ErrorStyle.Render("❌ "+message) + "\n"
+		a.updateTerminalOutput()
+		return a, nil
+	}
+	if len(args) != 1 {
+		return fail(a.tr("contexts.switch_usage"))
+	}
+	name := args[0]
+	if name == "-" {
+		if a.lastCluster == "" {
+			return fail(a.tr("contexts.no_previous"))
+		}
+		name = a.lastCluster
+	}
+	if name == a.selectedCluster.Name {
+		a.output += styles.InfoStyle.Render(a.tr("contexts.already_on", name)) + "\n"
+		a.updateTerminalOutput()
+		return a, nil
+	}
+
+	cluster, err := a.config.GetCluster(name)
+	if err != nil {
+		return fail(a.tr("contexts.unknown", err))
+	}
+	return a.handleClusterSelected(cluster)
+}
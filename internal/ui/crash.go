@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/crash"
+)
+
+// crashCommands is how many recent commands a crash report lists
+const crashCommands = 20
+
+// Crash is a panic the application recovered from before exiting
+type Crash struct {
+	Value      interface{}
+	ReportPath string
+	Err        error // set if the report couldn't be written
+}
+
+// panicMsg carries a panic from a background command to the event loop
+type panicMsg struct {
+	value interface{}
+	stack []byte
+}
+
+// Crash returns the panic that ended the application, if any
+func (a *Application) Crash() *Crash {
+	return a.crashed
+}
+
+// recordCrash writes a crash report for a recovered panic. Only the first
+// panic is reported; later ones are usually fallout from it.
+func (a *Application) recordCrash(value interface{}, stack []byte) {
+	if a.crashed != nil {
+		return
+	}
+	report := crash.Report{Time: time.Now(), Value: value, Stack: stack}
+	statuses, _, _ := a.depsRefresher.Snapshot()
+	for _, status := range statuses {
+		if status.Available {
+			report.Versions = append(report.Versions, fmt.Sprintf("%s: %s", status.Name, status.Version))
+		}
+	}
+	if entries, err := a.auditLog.Read(crashCommands); err == nil {
+		for _, entry := range entries {
+			report.Commands = append(report.Commands, fmt.Sprintf("%s [%s] %s %s (%s)",
+				entry.Time.Format(time.RFC3339), entry.Cluster, entry.Source, entry.Command, entry.Status))
+		}
+	}
+
+	path, err := crash.Write(a.config.CrashDir, report)
+	a.crashed = &Crash{Value: value, ReportPath: path, Err: err}
+}
+
+// guard makes a command hand its panics to the event loop instead of taking
+// the process down with the terminal still in raw mode and the alt screen
+func guard(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() (msg tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				msg = panicMsg{value: r, stack: debug.Stack()}
+			}
+		}()
+		msg = cmd()
+		// Batched commands run on their own goroutines
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			guarded := make(tea.BatchMsg, len(batch))
+			for i, cmd := range batch {
+				guarded[i] = guard(cmd)
+			}
+			msg = guarded
+		}
+		return msg
+	}
+}
+
+// recoverWorker hands a panic in a background goroutine to the event loop.
+// It must be deferred directly by the goroutine.
+func recoverWorker(events chan<- tea.Msg) {
+	if r := recover(); r != nil {
+		events <- panicMsg{value: r, stack: debug.Stack()}
+	}
+}
@@ -0,0 +1,247 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/setup"
+	"github.com/RaymondAkachi/custom-kub-cli/k8s/clustersetup"
+)
+
+// Dashboard refresh and check settings
+const (
+	dashboardRefresh = 30 * time.Second
+	dashboardTimeout = 60 * time.Second
+)
+
+// Messages for the cluster health dashboard
+type dashboardMsg struct {
+	generation int
+	manager    *clustersetup.ClusterManager
+	health     clustersetup.ClusterHealth
+	err        error
+}
+type dashboardTickMsg struct{ generation int }
+
+// clusterDashboard shows the health of a cluster built from a managed config
+type clusterDashboard struct {
+	config  setup.ManagedConfig
+	manager *clustersetup.ClusterManager
+	health  *clustersetup.ClusterHealth
+	err     error
+	loading bool
+	// generation invalidates results and ticks of a dashboard that was closed
+	generation int
+}
+
+// openDashboard shows the health dashboard for a managed config
+func (a *Application) openDashboard(managed setup.ManagedConfig) (tea.Model, tea.Cmd) {
+	managed.Passphrase = a.passphrases[managed.Name]
+	// The previous dashboard's connections to its nodes aren't needed anymore
+	if a.dashboard.manager != nil {
+		a.dashboard.manager.Close()
+	}
+	a.dashboard = clusterDashboard{
+		config:     managed,
+		generation: a.dashboard.generation + 1,
+	}
+	a.configsNotice = ""
+	a.state = dashboardView
+	return a, a.refreshDashboard()
+}
+
+// refreshDashboard runs the health checks in the background
+func (a *Application) refreshDashboard() tea.Cmd {
+	d := &a.dashboard
+	if d.loading {
+		return nil
+	}
+	d.loading = true
+
+	managed, manager, generation := d.config, d.manager, d.generation
+	load := func() tea.Msg {
+		if manager == nil {
+			var err error
+			manager, err = setup.NewClusterManager(managed, a.setupLogger(managed.Config.ClusterName), clustersetup.NewNopProgressReporter())
+			if err != nil {
+				return dashboardMsg{generation: generation, err: err}
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), dashboardTimeout)
+		defer cancel()
+		return dashboardMsg{generation: generation, manager: manager, health: manager.GetClusterHealth(ctx)}
+	}
+
+	cmds := []tea.Cmd{load}
+	if !a.loading && a.inFlight == 0 && !a.operation.running() {
+		cmds = append(cmds, a.spinner.Tick)
+	}
+	return tea.Batch(cmds...)
+}
+
+// handleDashboard records health check results and schedules the next refresh
+func (a *Application) handleDashboard(msg dashboardMsg) (tea.Model, tea.Cmd) {
+	d := &a.dashboard
+	if msg.generation != d.generation {
+		if msg.manager != nil && msg.manager != d.manager {
+			msg.manager.Close()
+		}
+		return a, nil
+	}
+	d.loading = false
+	d.err = msg.err
+	if msg.manager != nil {
+		d.manager = msg.manager
+	}
+	if msg.err == nil {
+		d.health = &msg.health
+	}
+
+	generation := d.generation
+	return a, tea.Tick(dashboardRefresh, func(time.Time) tea.Msg {
+		return dashboardTickMsg{generation: generation}
+	})
+}
+
+// handleDashboardTick refreshes the dashboard while it's open
+func (a *Application) handleDashboardTick(msg dashboardTickMsg) (tea.Model, tea.Cmd) {
+	if msg.generation != a.dashboard.generation || a.state != dashboardView {
+		return a, nil
+	}
+	return a, a.refreshDashboard()
+}
+
+// updateDashboard handles dashboard updates
+func (a *Application) updateDashboard(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		// Stop the refresh timer
+		a.dashboard.generation++
+		return a.openClusterConfigs()
+	case "r":
+		return a, a.refreshDashboard()
+	case "ctrl+c":
+		return a, tea.Quit
+	}
+	return a, nil
+}
+
+// renderDashboard renders the cluster health dashboard
+func (a *Application) renderDashboard() string {
+	d := a.dashboard
+	title := a.tr("dashboard.title", d.config.Config.ClusterName)
+
+	status := ""
+	switch {
+	case d.loading:
+		status = fmt.Sprintf("%s %s", a.spinner.View(), styles.LoadingStyle.Render(a.tr("dashboard.checking")))
+	case d.health != nil:
+		status = styles.InfoStyle.Render(a.tr("dashboard.checked",
+			d.health.CheckedAt.Format("15:04:05"), dashboardRefresh))
+	}
+
+	body := ""
+	if d.err != nil {
+		body += styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", d.err)) + "\n"
+	}
+	if d.health != nil {
+		body += a.formatClusterHealth(*d.health)
+	}
+
+	return fmt.Sprintf("\n%s\n%s\n\n%s\n%s",
+		styles.TitleStyle.Render(title),
+		status,
+		body,
+		styles.InfoStyle.Render(a.tr("dashboard.footer")))
+}
+
+// formatClusterHealth formats the sections of a health report
+func (a *Application) formatClusterHealth(health clustersetup.ClusterHealth) string {
+	var b strings.Builder
+	section := func(title string, err error) {
+		b.WriteString("\n" + styles.HeaderStyle.Render(title) + "\n")
+		if err != nil {
+			b.WriteString(styles.ErrorStyle.Render(fmt.Sprintf("  ❌ %v", err)) + "\n")
+		}
+	}
+	indent := func(text string) {
+		for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+
+	section(a.tr("dashboard.nodes"), health.StatusErr)
+	if health.Status.Nodes != "" {
+		indent(health.Status.Nodes)
+	}
+	section(a.tr("dashboard.system_pods"), nil)
+	if health.Status.PodStatus != "" {
+		indent(health.Status.PodStatus)
+	}
+	section(a.tr("dashboard.test_deployment"), nil)
+	if health.Status.TestStatus != "" {
+		indent(health.Status.TestStatus)
+	}
+
+	section(a.tr("dashboard.services"), health.ServicesErr)
+	node := ""
+	for _, service := range health.Services {
+		if service.Node != node {
+			node = service.Node
+			b.WriteString(fmt.Sprintf("  %s\n", node))
+		}
+		line := fmt.Sprintf("    ✅ %-24s %s", service.Service, service.State)
+		if !service.Healthy() {
+			line = styles.ErrorStyle.Render(fmt.Sprintf("    ❌ %-24s %s", service.Service, service.State))
+		}
+		b.WriteString(line + "\n")
+	}
+
+	section(a.tr("dashboard.etcd"), health.EtcdErr)
+	for _, member := range health.EtcdMembers {
+		line := fmt.Sprintf("  ✅ %-16s %-8s %s", member.Name, member.Status, member.ClientURL)
+		if member.Status != "started" {
+			line = styles.ErrorStyle.Render(fmt.Sprintf("  ❌ %-16s %-8s %s", member.Name, member.Status, member.ClientURL))
+		}
+		b.WriteString(line + "\n")
+	}
+
+	section(a.tr("dashboard.certificates"), health.CertsErr)
+	for _, cert := range health.Certificates {
+		left := time.Until(cert.NotAfter)
+		line := fmt.Sprintf("  %-32s %s", cert.Name,
+			a.tr("dashboard.expires", cert.NotAfter.Format("2006-01-02"), int(left.Hours()/24)))
+		switch {
+		case left <= 0:
+			line = styles.ErrorStyle.Render(fmt.Sprintf("  ❌ %-29s %s", cert.Name, a.tr("dashboard.expired", cert.NotAfter.Format("2006-01-02"))))
+		case left < clustersetup.CertificateWarningPeriod:
+			line = styles.ErrorStyle.Render("⚠️" + line)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	section(a.tr("dashboard.drift"), health.DriftErr)
+	if len(health.Drift) == 0 && health.DriftErr == nil {
+		b.WriteString("  " + a.tr("dashboard.no_drift") + "\n")
+	}
+	for _, drift := range health.Drift {
+		b.WriteString(styles.ErrorStyle.Render(fmt.Sprintf("  ⚠️ %-16s %s", drift.Node, drift.Path)) + "\n")
+	}
+
+	if len(health.Addons) > 0 {
+		section(a.tr("dashboard.addons"), nil)
+		for _, addon := range health.Addons {
+			line := fmt.Sprintf("  ✅ %s", addon.Name)
+			if !addon.Healthy() {
+				line = styles.ErrorStyle.Render(fmt.Sprintf("  ❌ %-16s %s", addon.Name, strings.Split(addon.Err.Error(), "\n")[0]))
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+
+	return b.String()
+}
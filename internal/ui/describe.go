@@ -0,0 +1,572 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/audit"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// logTailLines is how many log lines the logs quick action fetches
+const logTailLines = 200
+
+// Messages for the resource browser
+type browserFrameMsg struct {
+	frame   browserFrame
+	refresh bool // replace the current frame instead of pushing a new one
+	notice  string
+	err     error
+}
+type browserActionMsg struct {
+	object kubectl.ObjectRef
+	result tea.Msg
+}
+type browserEditedMsg struct {
+	object  kubectl.ObjectRef
+	started time.Time
+	err     error
+}
+
+// browserFrame is a table, describe, logs or related objects pane on the browser's back stack
+type browserFrame struct {
+	table    *kubectl.Table // set for table frames
+	cursor   int
+	object   kubectl.ObjectRef
+	logs     bool
+	content  string
+	sections []kubectl.DescribeSection
+	related  []kubectl.ObjectRef
+	graph    []kubectl.RelatedObject // set for related objects frames
+	isGraph  bool
+}
+
+// resourceBrowser drills down from a resource table into describe and logs panes
+type resourceBrowser struct {
+	session       clusterSession
+	stack         []browserFrame
+	viewport      viewport.Model
+	notice        string
+	loading       string
+	confirmDelete bool
+}
+
+// top returns the frame being shown
+func (b *resourceBrowser) top() *browserFrame {
+	return &b.stack[len(b.stack)-1]
+}
+
+// openResourceBrowser opens the last table printed for the selected cluster
+func (a *Application) openResourceBrowser() (tea.Model, tea.Cmd) {
+	table := a.tables[a.selectedCluster.Name]
+	if table == nil {
+		a.output += styles.InfoStyle.Render(a.tr("browser.no_table")) + "\n"
+		a.updateTerminalOutput()
+		return a, nil
+	}
+
+	a.browser = resourceBrowser{
+		session:  a.currentSession(),
+		stack:    []browserFrame{{table: table}},
+		viewport: viewport.New(a.width-4, a.height-10),
+	}
+	a.state = resourceBrowserView
+	return a, nil
+}
+
+// updateResourceBrowser handles resource browser updates
+func (a *Application) updateResourceBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	b := &a.browser
+	frame := b.top()
+	key := msg.String()
+
+	if b.confirmDelete {
+		b.confirmDelete = false
+		if key == "y" {
+			return a, a.deleteObject(frame.object)
+		}
+		b.notice = a.tr("browser.delete_canceled")
+		return a, nil
+	}
+
+	switch key {
+	case "esc":
+		b.notice = ""
+		b.stack = b.stack[:len(b.stack)-1]
+		if len(b.stack) == 0 {
+			a.state = terminalView
+			a.updateTerminalOutput()
+			return a, nil
+		}
+		a.showFrame()
+		return a, nil
+	case "ctrl+c":
+		return a, tea.Quit
+	case "r":
+		if frame.table != nil {
+			return a, a.loadTable(frame.table.Command)
+		}
+		if frame.logs {
+			return a, a.loadLogs(frame.object, true)
+		}
+		if frame.isGraph {
+			return a, a.loadRelated(frame.object, true)
+		}
+		return a, a.describeObject(frame.object, true)
+	}
+
+	if frame.table != nil {
+		switch key {
+		case "up", "k":
+			if frame.cursor > 0 {
+				frame.cursor--
+			}
+		case "down", "j":
+			if frame.cursor < len(frame.table.Rows)-1 {
+				frame.cursor++
+			}
+		case "enter":
+			if len(frame.table.Rows) > 0 {
+				return a, a.describeObject(frame.table.Rows[frame.cursor].ObjectRef, false)
+			}
+		case "g":
+			if len(frame.table.Rows) > 0 {
+				return a, a.loadRelated(frame.table.Rows[frame.cursor].ObjectRef, false)
+			}
+		case "f", "F":
+			if len(frame.table.Rows) > 0 {
+				b.notice = styles.LoadingStyle.Render(a.tr("browser.forwarding", frame.table.Rows[frame.cursor]))
+				return a, a.startPortForward(b.session, frame.table.Rows[frame.cursor].ObjectRef, key == "F")
+			}
+		}
+		return a, nil
+	}
+
+	if frame.isGraph {
+		switch key {
+		case "up", "k":
+			if frame.cursor > 0 {
+				frame.cursor--
+			}
+		case "down", "j":
+			if frame.cursor < len(frame.graph)-1 {
+				frame.cursor++
+			}
+		case "enter":
+			if len(frame.graph) > 0 {
+				return a, a.describeObject(frame.graph[frame.cursor].ObjectRef, false)
+			}
+		case "g":
+			// Follow the graph from the selected object
+			if len(frame.graph) > 0 {
+				return a, a.loadRelated(frame.graph[frame.cursor].ObjectRef, false)
+			}
+		}
+		return a, nil
+	}
+
+	if !frame.logs {
+		switch key {
+		case "tab":
+			// Jump to the next section below the top of the pane
+			for _, section := range frame.sections {
+				if section.Line > b.viewport.YOffset {
+					b.viewport.SetYOffset(section.Line)
+					return a, nil
+				}
+			}
+			b.viewport.GotoTop()
+			return a, nil
+		case "l":
+			return a, a.loadLogs(frame.object, false)
+		case "g":
+			return a, a.loadRelated(frame.object, false)
+		case "f", "F":
+			b.notice = styles.LoadingStyle.Render(a.tr("browser.forwarding", frame.object))
+			return a, a.startPortForward(b.session, frame.object, key == "F")
+		case "e":
+			return a, a.editObject(frame.object)
+		case "d":
+			b.confirmDelete = true
+			return a, nil
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			if i := int(key[0] - '1'); i < len(frame.related) {
+				return a, a.describeObject(frame.related[i], false)
+			}
+			return a, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	b.viewport, cmd = b.viewport.Update(msg)
+	return a, cmd
+}
+
+// describeObject runs kubectl describe in the background
+func (a *Application) describeObject(object kubectl.ObjectRef, refresh bool) tea.Cmd {
+	a.browser.loading = a.tr("browser.describing", object)
+	session := a.browser.session
+	return func() tea.Msg {
+		content, err := a.runBrowserCommand(session, append([]string{"describe"}, object.Args()...))
+		frame := browserFrame{
+			object:   object,
+			content:  content,
+			sections: kubectl.ParseDescribe(content),
+			related:  kubectl.RelatedObjects(content),
+		}
+		return browserFrameMsg{frame: frame, refresh: refresh, err: err}
+	}
+}
+
+// loadLogs fetches recent logs of the object in the background
+func (a *Application) loadLogs(object kubectl.ObjectRef, refresh bool) tea.Cmd {
+	a.browser.loading = a.tr("browser.fetching_logs", object)
+	session := a.browser.session
+	return func() tea.Msg {
+		args := append([]string{"logs"}, object.Args()...)
+		args = append(args, "--all-containers", fmt.Sprintf("--tail=%d", logTailLines))
+		content, err := a.runBrowserCommand(session, args)
+		return browserFrameMsg{frame: browserFrame{object: object, logs: true, content: content}, refresh: refresh, err: err}
+	}
+}
+
+// loadRelated looks up the owners, owned and selected objects and events of
+// the object in the background
+func (a *Application) loadRelated(object kubectl.ObjectRef, refresh bool) tea.Cmd {
+	a.browser.loading = a.tr("browser.finding_related", object)
+	session := a.browser.session
+	return func() tea.Msg {
+		started := time.Now()
+		graph, err := session.executor.Related(object)
+		a.recordResult(session.cluster.Name, audit.SourceKubectl, "get "+strings.Join(object.Args(), " ")+" (related)", started, err)
+		return browserFrameMsg{frame: browserFrame{object: object, isGraph: true, graph: graph}, refresh: refresh, err: err}
+	}
+}
+
+// loadTable re-runs the get command of a table frame in the background
+func (a *Application) loadTable(command string) tea.Cmd {
+	a.browser.loading = a.tr("browser.refreshing")
+	session := a.browser.session
+	return func() tea.Msg {
+		output, err := a.runBrowserCommand(session, strings.Fields(command))
+		table, ok := kubectl.ParseTable(command, output)
+		if !ok {
+			// Keep the frame a table even when nothing is left in it
+			table = &kubectl.Table{Command: command, Lines: strings.Split(strings.TrimRight(output, "\n"), "\n")}
+		}
+		return browserFrameMsg{frame: browserFrame{table: table}, refresh: true, err: err}
+	}
+}
+
+// runBrowserCommand runs a read-only kubectl command for the browser and audits it
+func (a *Application) runBrowserCommand(session clusterSession, args []string) (string, error) {
+	started := time.Now()
+	output, err := session.executor.Execute(args...)
+	a.recordResult(session.cluster.Name, audit.SourceKubectl, strings.Join(args, " "), started, err)
+	if err != nil {
+		return "", fmt.Errorf("%v\n%s", err, output)
+	}
+	return output, nil
+}
+
+// editObject suspends the TUI and runs kubectl edit on the object
+func (a *Application) editObject(object kubectl.ObjectRef) tea.Cmd {
+	cmd, err := a.browser.session.executor.InteractiveCommand(append([]string{"edit"}, object.Args()...)...)
+	if err != nil {
+		a.browser.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+		return nil
+	}
+	started := time.Now()
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return browserEditedMsg{object: object, started: started, err: err}
+	})
+}
+
+// deleteObject deletes the object through the command queue, like a terminal command
+func (a *Application) deleteObject(object kubectl.ObjectRef) tea.Cmd {
+	session := a.browser.session
+	command := "delete " + strings.Join(object.Args(), " ")
+	a.browser.loading = a.tr("browser.deleting", object)
+
+	return tea.Batch(a.startCommand(session), a.queueCommand(session, command, func() tea.Msg {
+		return browserActionMsg{object: object, result: a.runKubectlCommand(session, command, false)}
+	}))
+}
+
+// handleBrowserFrame shows a loaded pane
+func (a *Application) handleBrowserFrame(msg browserFrameMsg) (tea.Model, tea.Cmd) {
+	b := &a.browser
+	if len(b.stack) == 0 {
+		return a, nil
+	}
+	b.loading = ""
+	if msg.err != nil {
+		b.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", msg.err))
+		return a, nil
+	}
+
+	b.notice = msg.notice
+	if msg.refresh {
+		cursor := b.top().cursor
+		*b.top() = msg.frame
+		if msg.frame.table != nil && cursor < len(msg.frame.table.Rows) ||
+			msg.frame.isGraph && cursor < len(msg.frame.graph) {
+			b.top().cursor = cursor
+		}
+	} else {
+		b.stack = append(b.stack, msg.frame)
+	}
+	a.showFrame()
+	return a, nil
+}
+
+// handleBrowserAction reports the result of a delete and returns to the table
+func (a *Application) handleBrowserAction(msg browserActionMsg) (tea.Model, tea.Cmd) {
+	b := &a.browser
+	b.loading = ""
+	switch result := msg.result.(type) {
+	case commandExecutedMsg:
+		a.output += strings.TrimRight(result.output, "\n") + "\n"
+		b.notice = styles.SuccessStyle.Render(a.tr("browser.deleted", msg.object))
+	case errorMsg:
+		b.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", result.err))
+		return a, nil
+	case confirmationRequiredMsg:
+		a.pendingCommand = result.command
+		b.notice = styles.ErrorStyle.Render(a.tr("policy.confirm_terminal", result.err))
+		return a, nil
+	default:
+		return a, nil
+	}
+
+	// Go back to the table the object was picked from and refresh it
+	if len(b.stack) == 0 || b.stack[0].table == nil {
+		return a, nil
+	}
+	b.stack = b.stack[:1]
+	a.showFrame()
+	return a, a.loadTable(b.stack[0].table.Command)
+}
+
+// handleBrowserEdited syncs an edited object to git and shows its new state
+func (a *Application) handleBrowserEdited(msg browserEditedMsg) (tea.Model, tea.Cmd) {
+	session := a.browser.session
+	command := "edit " + strings.Join(msg.object.Args(), " ")
+	a.recordResult(session.cluster.Name, audit.SourceKubectl, command, msg.started, msg.err)
+	if len(a.browser.stack) == 0 {
+		return a, nil
+	}
+	if msg.err != nil {
+		a.browser.notice = styles.ErrorStyle.Render(a.tr("browser.edit_failed", msg.err))
+		return a, nil
+	}
+
+	describe := a.describeObject(msg.object, true)
+	return a, func() tea.Msg {
+		sync := a.syncChanges(session, command)
+		result := describe().(browserFrameMsg)
+		result.notice = strings.TrimPrefix(sync, "\n")
+		return result
+	}
+}
+
+// showFrame loads the top frame into the viewport
+func (a *Application) showFrame() {
+	b := &a.browser
+	frame := b.top()
+	b.viewport.Width = a.width - 4
+	b.viewport.Height = a.height - 10
+	if frame.table != nil || frame.isGraph {
+		return
+	}
+	if frame.logs {
+		b.viewport.SetContent(frame.content)
+		b.viewport.GotoBottom()
+		return
+	}
+	b.viewport.SetContent(highlightDescribe(frame.content))
+	b.viewport.GotoTop()
+}
+
+// renderResourceBrowser renders the top frame of the resource browser
+func (a *Application) renderResourceBrowser() string {
+	b := &a.browser
+	frame := b.top()
+
+	// The back stack doubles as a breadcrumb
+	var crumbs []string
+	for _, f := range b.stack {
+		switch {
+		case f.table != nil:
+			crumbs = append(crumbs, f.table.Command)
+		case f.logs:
+			crumbs = append(crumbs, a.tr("browser.crumb_logs", f.object))
+		case f.isGraph:
+			crumbs = append(crumbs, a.tr("browser.crumb_related", f.object))
+		default:
+			crumbs = append(crumbs, f.object.String())
+		}
+	}
+	title := styles.TitleStyle.Render("🔎 " + strings.Join(crumbs, " › "))
+
+	var body, footer string
+	switch {
+	case frame.table != nil:
+		body = renderTable(frame.table, frame.cursor, a.height-10)
+		footer = a.tr("browser.table_footer")
+	case frame.isGraph:
+		body = a.renderGraph(frame.graph, frame.cursor, a.height-10)
+		footer = a.tr("browser.graph_footer")
+	case frame.logs:
+		body = b.viewport.View()
+		footer = a.tr("browser.logs_footer")
+	default:
+		var related []string
+		for i, object := range frame.related {
+			if i == 9 {
+				break
+			}
+			related = append(related, fmt.Sprintf("%d: %s", i+1, object))
+		}
+		if len(related) > 0 {
+			body = styles.InfoStyle.Render(a.tr("browser.related", strings.Join(related, " • "))) + "\n"
+		}
+		body += b.viewport.View()
+		footer = a.tr("browser.describe_footer")
+	}
+
+	status := b.notice
+	if b.loading != "" {
+		status = styles.LoadingStyle.Render("⏳ " + b.loading)
+	}
+	if b.confirmDelete {
+		status = styles.ErrorStyle.Render(a.tr("browser.delete_confirm", frame.object))
+	}
+
+	return fmt.Sprintf("\n%s\n\n%s\n%s\n%s",
+		title,
+		body,
+		status,
+		styles.InfoStyle.Render(footer))
+}
+
+// renderTable renders a table with the selected row highlighted, scrolled to keep it visible
+func renderTable(table *kubectl.Table, cursor, height int) string {
+	selected := -1
+	if cursor < len(table.Rows) {
+		selected = table.Rows[cursor].Line
+	}
+
+	start := 0
+	if height > 0 && selected >= height {
+		start = selected - height + 1
+	}
+	end := len(table.Lines)
+	if height > 0 && end > start+height {
+		end = start + height
+	}
+
+	var b strings.Builder
+	for i := start; i < end; i++ {
+		line := table.Lines[i]
+		if i == selected {
+			line = styles.SelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// renderGraph renders related objects grouped by relation, with the selected
+// one highlighted and scrolled into view
+func (a *Application) renderGraph(graph []kubectl.RelatedObject, cursor, height int) string {
+	if len(graph) == 0 {
+		return styles.InfoStyle.Render("  "+a.tr("browser.no_related")) + "\n"
+	}
+
+	var lines []string
+	selected := 0
+	for _, relation := range kubectl.RelationOrder {
+		header := false
+		for i, object := range graph {
+			if object.Relation != relation {
+				continue
+			}
+			if !header {
+				lines = append(lines, styles.HeaderStyle.Render(relation))
+				header = true
+			}
+			line := fmt.Sprintf("  %-50s %s", object.Kind+"/"+object.Name, object.Detail)
+			if i == cursor {
+				selected = len(lines)
+				line = styles.SelectedStyle.Render(line)
+			} else if object.Relation == kubectl.RelationEvents && strings.HasPrefix(object.Detail, "Warning") {
+				line = styles.ErrorStyle.Render(line)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	start := 0
+	if height > 0 && selected >= height {
+		start = selected - height + 1
+	}
+	end := len(lines)
+	if height > 0 && end > start+height {
+		end = start + height
+	}
+	return strings.Join(lines[start:end], "\n") + "\n"
+}
+
+// highlightDescribe colors kubectl describe output: section titles and keys,
+// warning events, condition states and volume names
+func highlightDescribe(content string) string {
+	lines := strings.Split(content, "\n")
+	section := ""
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			key, value, found := strings.Cut(line, ":")
+			if !found {
+				continue
+			}
+			if trimmed := strings.TrimSpace(value); trimmed == "" || trimmed == "<none>" {
+				section = key
+				lines[i] = styles.LoadingStyle.Render(key+":") + value
+			} else {
+				section = ""
+				lines[i] = styles.KeyStyle.Render(key+":") + value
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch section {
+		case "Events":
+			switch {
+			case fields[0] == "Type" || strings.HasPrefix(fields[0], "----"):
+				lines[i] = styles.InfoStyle.Render(line)
+			case fields[0] == "Warning":
+				lines[i] = styles.ErrorStyle.Render(line)
+			}
+		case "Conditions":
+			if len(fields) > 1 && fields[1] == "False" {
+				lines[i] = styles.ErrorStyle.Render(line)
+			} else if len(fields) > 1 && fields[1] == "True" {
+				lines[i] = styles.SuccessStyle.Render(line)
+			}
+		case "Volumes":
+			// Volume names are the keys directly below the section title
+			if strings.HasPrefix(line, "  ") && line[2] != ' ' && strings.HasSuffix(line, ":") {
+				lines[i] = styles.KeyStyle.Render(line)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,322 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+var (
+	// A YAML key and its value, after the indentation and any list marker
+	yamlKeyPattern = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s"'#][^:]*?):(\s.*)?$`)
+	// A JSON key and its value
+	jsonKeyPattern = regexp.MustCompile(`^("(?:[^"\\]|\\.)*")(:\s*)(.*)$`)
+	numberPattern  = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+)
+
+// documentViewer shows -o yaml and -o json output with folding and search
+type documentViewer struct {
+	doc    *kubectl.Document
+	folds  map[int]int // last line of the sections that start on a line
+	folded map[int]bool
+	cursor int // line under the cursor
+	offset int // first line shown
+	// Set while typing a path to jump to
+	searching bool
+	query     string
+	notice    string
+}
+
+// openDocument shows a document in the viewer, with managed fields folded
+func (a *Application) openDocument(doc *kubectl.Document) (tea.Model, tea.Cmd) {
+	if doc == nil {
+		a.output += styles.ErrorStyle.Render(a.tr("document.none")) + "\n"
+		a.updateTerminalOutput()
+		return a, nil
+	}
+
+	v := documentViewer{doc: doc, folds: make(map[int]int), folded: make(map[int]bool)}
+	for _, entry := range doc.Entries {
+		// A list item and its first key share a line; the item folds
+		if _, ok := v.folds[entry.Line]; !ok && entry.End > entry.Line {
+			v.folds[entry.Line] = entry.End
+		}
+	}
+	v.foldSections("managedFields", true)
+	a.document = v
+	a.state = documentView
+	return a, nil
+}
+
+// foldSections folds or unfolds the sections with a key of every object in the document
+func (v *documentViewer) foldSections(key string, fold bool) int {
+	count := 0
+	for _, entry := range v.doc.Entries {
+		if entry.Path != key && !strings.HasSuffix(entry.Path, "."+key) {
+			continue
+		}
+		if _, ok := v.folds[entry.Line]; ok {
+			v.folded[entry.Line] = fold
+			count++
+		}
+	}
+	v.clampCursor()
+	return count
+}
+
+// visibleLines returns the lines not hidden in folded sections
+func (v *documentViewer) visibleLines() []int {
+	var lines []int
+	for i := 0; i < len(v.doc.Lines); i++ {
+		lines = append(lines, i)
+		if end, ok := v.folds[i]; ok && v.folded[i] {
+			i = end
+		}
+	}
+	return lines
+}
+
+// reveal unfolds the sections hiding a line
+func (v *documentViewer) reveal(line int) {
+	for start, end := range v.folds {
+		if start < line && line <= end {
+			v.folded[start] = false
+		}
+	}
+}
+
+// clampCursor moves the cursor out of folded sections onto the line that folds them
+func (v *documentViewer) clampCursor() {
+	for start, end := range v.folds {
+		if v.folded[start] && start < v.cursor && v.cursor <= end {
+			v.cursor = start
+		}
+	}
+}
+
+// moveCursor moves the cursor by a number of visible lines
+func (v *documentViewer) moveCursor(delta int) {
+	lines := v.visibleLines()
+	row := 0
+	for i, line := range lines {
+		if line == v.cursor {
+			row = i
+		}
+	}
+	row = min(max(row+delta, 0), len(lines)-1)
+	v.cursor = lines[row]
+}
+
+// jumpDocument moves the document viewer's cursor to the next entry whose
+// path contains the query, searching from after the cursor and wrapping around
+func (a *Application) jumpDocument() {
+	v := &a.document
+	query := strings.ToLower(strings.TrimSpace(v.query))
+	if query == "" {
+		return
+	}
+	entries := v.doc.Entries
+	start := 0
+	for i, entry := range entries {
+		if entry.Line <= v.cursor {
+			start = i + 1
+		}
+	}
+	for i := range entries {
+		entry := entries[(start+i)%len(entries)]
+		if strings.Contains(strings.ToLower(entry.Path), query) {
+			v.reveal(entry.Line)
+			v.cursor = entry.Line
+			v.notice = entry.Path
+			return
+		}
+	}
+	v.notice = styles.ErrorStyle.Render(a.tr("document.no_match", v.query))
+}
+
+// documentRows returns how many lines of the document fit on screen
+func (a *Application) documentRows() int {
+	return max(a.height-8, 5)
+}
+
+// updateDocument handles document viewer updates
+func (a *Application) updateDocument(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	v := &a.document
+	key := msg.String()
+	if key == "ctrl+c" {
+		return a, tea.Quit
+	}
+
+	if v.searching {
+		switch msg.Type {
+		case tea.KeyEsc:
+			v.searching = false
+		case tea.KeyEnter:
+			v.searching = false
+			a.jumpDocument()
+		case tea.KeyBackspace:
+			if v.query != "" {
+				runes := []rune(v.query)
+				v.query = string(runes[:len(runes)-1])
+			}
+		case tea.KeyRunes, tea.KeySpace:
+			v.query += string(msg.Runes)
+		}
+		return a, nil
+	}
+
+	v.notice = ""
+	switch key {
+	case "esc", "q":
+		a.state = terminalView
+		a.updateTerminalOutput()
+	case "up", "k":
+		v.moveCursor(-1)
+	case "down", "j":
+		v.moveCursor(1)
+	case "pgup":
+		v.moveCursor(-a.documentRows())
+	case "pgdown":
+		v.moveCursor(a.documentRows())
+	case "home", "g":
+		v.cursor = 0
+	case "end", "G":
+		v.moveCursor(len(v.doc.Lines))
+	case "enter", " ":
+		if _, ok := v.folds[v.cursor]; ok {
+			v.folded[v.cursor] = !v.folded[v.cursor]
+		}
+	case "c":
+		for start := range v.folds {
+			v.folded[start] = true
+		}
+		v.clampCursor()
+	case "e":
+		v.folded = make(map[int]bool)
+	case "m", "s":
+		section := map[string]string{"m": "metadata", "s": "status"}[key]
+		fold := true
+		for _, entry := range v.doc.Entries {
+			if (entry.Path == section || strings.HasSuffix(entry.Path, "."+section)) && v.folded[entry.Line] {
+				fold = false
+				break
+			}
+		}
+		if v.foldSections(section, fold) == 0 {
+			v.notice = styles.InfoStyle.Render(a.tr("document.no_section", section))
+		}
+	case "/":
+		v.searching = true
+		v.query = ""
+	case "n":
+		a.jumpDocument()
+	case "ctrl+r":
+		a.revealSecrets = !a.revealSecrets
+	}
+	return a, nil
+}
+
+// renderDocument renders the document viewer
+func (a *Application) renderDocument() string {
+	v := &a.document
+	text := v.doc.Lines
+	if !a.revealSecrets {
+		text = strings.Split(kubectl.MaskSecrets(strings.Join(text, "\n")), "\n")
+	}
+
+	lines := v.visibleLines()
+	row := 0
+	for i, line := range lines {
+		if line == v.cursor {
+			row = i
+		}
+	}
+	rows := a.documentRows()
+	if row < v.offset {
+		v.offset = row
+	} else if row >= v.offset+rows {
+		v.offset = row - rows + 1
+	}
+	v.offset = min(v.offset, max(len(lines)-rows, 0))
+
+	var b strings.Builder
+	width := max(a.width-4, 20)
+	for _, line := range lines[v.offset:min(v.offset+rows, len(lines))] {
+		folded := ""
+		if end, ok := v.folds[line]; ok && v.folded[line] {
+			folded = a.tr("document.folded", end-line)
+		}
+		content := truncateLine(text[line], width-len([]rune(folded)))
+		if line == v.cursor {
+			b.WriteString(styles.SelectedStyle.Render(content+folded) + "\n")
+			continue
+		}
+		b.WriteString(highlightDocumentLine(v.doc.Format, content) + styles.InfoStyle.Render(folded) + "\n")
+	}
+
+	status := v.notice
+	if v.searching {
+		status = a.tr("document.jump", v.query) + "█"
+	}
+	return fmt.Sprintf("\n%s\n%s\n\n%s\n%s\n%s",
+		styles.TitleStyle.Render("📄 "+v.doc.Command),
+		styles.InfoStyle.Render(a.tr("document.position", v.cursor+1, len(v.doc.Lines), a.secretsHint())),
+		b.String(),
+		status,
+		styles.InfoStyle.Render(a.tr("document.help")))
+}
+
+// truncateLine shortens a line to a width, marking that it was cut
+func truncateLine(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) <= width {
+		return line
+	}
+	return string(runes[:max(width-1, 0)]) + "…"
+}
+
+// highlightDocumentLine colors the keys and values of a YAML or JSON line
+func highlightDocumentLine(format, line string) string {
+	body := strings.TrimLeft(line, " ")
+	indent := line[:len(line)-len(body)]
+	if format == "json" {
+		if m := jsonKeyPattern.FindStringSubmatch(body); m != nil {
+			return indent + styles.KeyStyle.Render(m[1]) + m[2] + highlightValue(m[3])
+		}
+		return indent + highlightValue(body)
+	}
+
+	if strings.HasPrefix(body, "- ") || body == "-" {
+		indent += body[:min(2, len(body))]
+		body = strings.TrimPrefix(strings.TrimPrefix(body, "-"), " ")
+	}
+	if m := yamlKeyPattern.FindStringSubmatch(body); m != nil {
+		value := strings.TrimPrefix(m[2], " ")
+		if value == "" {
+			return indent + styles.KeyStyle.Render(m[1]) + ":"
+		}
+		return indent + styles.KeyStyle.Render(m[1]) + ": " + highlightValue(value)
+	}
+	return indent + highlightValue(body)
+}
+
+// highlightValue colors a scalar by its type, leaving brackets, block
+// scalar markers and trailing commas as they are
+func highlightValue(value string) string {
+	trimmed := strings.TrimSuffix(value, ",")
+	comma := value[len(trimmed):]
+	switch {
+	case trimmed == "" || strings.ContainsAny(trimmed[:1], "{}[]") ||
+		strings.HasPrefix(trimmed, "|") || strings.HasPrefix(trimmed, ">"):
+		return value
+	case trimmed == "true" || trimmed == "false" || trimmed == "null" || trimmed == "~":
+		return styles.DocBoolStyle.Render(trimmed) + comma
+	case numberPattern.MatchString(trimmed):
+		return styles.DocNumberStyle.Render(trimmed) + comma
+	}
+	return styles.DocStringStyle.Render(trimmed) + comma
+}
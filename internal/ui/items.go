@@ -1,9 +1,11 @@
 package ui
 
 import (
-	"fmt"
+	"github.com/charmbracelet/bubbles/list"
 
 	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/i18n"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/setup"
 )
 
 // clusterItem represents a cluster in the selection list
@@ -21,7 +23,7 @@ func (i *clusterItem) Title() string {
 	if i.cluster.HasArgoCD {
 		status += " 🚀"
 	}
-	return fmt.Sprintf("%s%s", i.cluster.Name, status)
+	return i.cluster.Name + status
 }
 
 func (i *clusterItem) Description() string {
@@ -35,8 +37,68 @@ func (i *clusterItem) Description() string {
 }
 
 // addClusterItem represents the "add new cluster" option
-type addClusterItem struct{}
+type addClusterItem struct {
+	catalog *i18n.Catalog
+}
+
+func (i *addClusterItem) FilterValue() string { return i.Title() }
+func (i *addClusterItem) Title() string       { return i.catalog.T("clusters.add") }
+func (i *addClusterItem) Description() string { return i.catalog.T("clusters.add_description") }
+
+// managedConfigsItem opens the managed cluster config list
+type managedConfigsItem struct {
+	catalog *i18n.Catalog
+}
+
+func (i *managedConfigsItem) FilterValue() string { return i.Title() }
+func (i *managedConfigsItem) Title() string       { return i.catalog.T("configs.title") }
+func (i *managedConfigsItem) Description() string { return i.catalog.T("configs.description") }
+
+// workspaceItem opens the workspace switcher
+type workspaceItem struct {
+	catalog *i18n.Catalog
+	name    string
+}
+
+func (i *workspaceItem) FilterValue() string { return i.Title() }
+func (i *workspaceItem) Title() string       { return i.catalog.T("workspaces.item", i.name) }
+func (i *workspaceItem) Description() string { return i.catalog.T("workspaces.description") }
+
+// menuItems returns the options listed below the clusters
+func menuItems(catalog *i18n.Catalog, workspace string) []list.Item {
+	return []list.Item{
+		&addClusterItem{catalog: catalog},
+		&managedConfigsItem{catalog: catalog},
+		&workspaceItem{catalog: catalog, name: workspace},
+	}
+}
+
+// configItem represents a managed cluster config
+type configItem struct {
+	catalog *i18n.Catalog
+	config  setup.ManagedConfig
+	status  string
+}
+
+func (i *configItem) FilterValue() string { return i.config.Name }
+func (i *configItem) Title() string       { return i.config.Name }
+
+func (i *configItem) Description() string {
+	if i.config.ParseErr != nil {
+		return "❌ " + i.config.ParseErr.Error()
+	}
+	c := i.config.Config
+	if len(c.Controllers) > 1 {
+		return i.catalog.T("configs.item_ha", c.ClusterName, c.KubernetesVersion, len(c.Controllers), len(c.Workers), i.status)
+	}
+	return i.catalog.T("configs.item", c.ClusterName, c.KubernetesVersion, c.Controller.IPAddress, len(c.Workers), i.status)
+}
+
+// newConfigItem represents the "new config" option
+type newConfigItem struct {
+	catalog *i18n.Catalog
+}
 
-func (i *addClusterItem) FilterValue() string { return "add new cluster" }
-func (i *addClusterItem) Title() string       { return "➕ Add New Cluster" }
-func (i *addClusterItem) Description() string { return "Add a new Kubernetes cluster" }
\ No newline at end of file
+func (i *newConfigItem) FilterValue() string { return i.Title() }
+func (i *newConfigItem) Title() string       { return i.catalog.T("configs.new") }
+func (i *newConfigItem) Description() string { return i.catalog.T("configs.new_description") }
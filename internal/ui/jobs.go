@@ -0,0 +1,309 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// Messages for the jobs view
+type jobsListMsg struct {
+	cronJobs []kubectl.CronJobInfo
+	jobs     []kubectl.JobInfo
+	err      error
+}
+type jobsActionMsg struct{ result tea.Msg }
+type jobsLogsMsg struct {
+	job  kubectl.ObjectRef
+	logs string
+	err  error
+}
+
+// jobsManager lists CronJobs and Jobs with actions on the selected one
+type jobsManager struct {
+	session  clusterSession
+	flags    []string
+	cronJobs []kubectl.CronJobInfo
+	jobs     []kubectl.JobInfo
+	cursor   int // CronJobs come first, then Jobs
+	loading  string
+	notice   string
+	// Logs of the latest pod of a job, shown instead of the list
+	logsOf   *kubectl.ObjectRef
+	viewport viewport.Model
+}
+
+// selectedCronJob returns the CronJob under the cursor, if it is one
+func (m *jobsManager) selectedCronJob() (kubectl.CronJobInfo, bool) {
+	if m.cursor < len(m.cronJobs) {
+		return m.cronJobs[m.cursor], true
+	}
+	return kubectl.CronJobInfo{}, false
+}
+
+// selectedJob returns the Job under the cursor, or the last run of the selected CronJob
+func (m *jobsManager) selectedJob() (kubectl.JobInfo, bool) {
+	if cronJob, ok := m.selectedCronJob(); ok {
+		if cronJob.LastJob == nil {
+			return kubectl.JobInfo{}, false
+		}
+		return *cronJob.LastJob, true
+	}
+	if i := m.cursor - len(m.cronJobs); i >= 0 && i < len(m.jobs) {
+		return m.jobs[i], true
+	}
+	return kubectl.JobInfo{}, false
+}
+
+// openJobs lists the CronJobs and Jobs selected by the namespace flags of the jobs built-in
+func (a *Application) openJobs(flags []string) (tea.Model, tea.Cmd) {
+	a.jobs = jobsManager{
+		session:  a.currentSession(),
+		flags:    flags,
+		viewport: viewport.New(a.width-4, a.height-10),
+	}
+	a.state = jobsView
+	return a, a.loadJobs()
+}
+
+// loadJobs fetches CronJobs and Jobs in the background
+func (a *Application) loadJobs() tea.Cmd {
+	a.jobs.loading = a.tr("jobs.loading")
+	session, flags := a.jobs.session, a.jobs.flags
+	return func() tea.Msg {
+		cronJobs, jobs, err := session.executor.ListJobs(flags...)
+		return jobsListMsg{cronJobs: cronJobs, jobs: jobs, err: err}
+	}
+}
+
+// handleJobsList shows the loaded CronJobs and Jobs
+func (a *Application) handleJobsList(msg jobsListMsg) (tea.Model, tea.Cmd) {
+	m := &a.jobs
+	m.loading = ""
+	if msg.err != nil {
+		m.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", msg.err))
+		return a, nil
+	}
+	m.cronJobs, m.jobs = msg.cronJobs, msg.jobs
+	if total := len(m.cronJobs) + len(m.jobs); m.cursor >= total {
+		m.cursor = max(0, total-1)
+	}
+	return a, nil
+}
+
+// runJobsCommand runs a trigger or suspend command through the command queue
+func (a *Application) runJobsCommand(command string) tea.Cmd {
+	session := a.jobs.session
+	a.output += fmt.Sprintf("%s %s\n", styles.PromptStyle.Render(fmt.Sprintf("[%s]$", session.cluster.Name)), command)
+
+	return tea.Batch(a.startCommand(session), a.queueCommand(session, command, func() tea.Msg {
+		return jobsActionMsg{result: a.runKubectlCommand(session, command, false)}
+	}))
+}
+
+// handleJobsAction reports the result of an action and reloads the list
+func (a *Application) handleJobsAction(msg jobsActionMsg) (tea.Model, tea.Cmd) {
+	m := &a.jobs
+	m.loading = ""
+	switch result := msg.result.(type) {
+	case commandExecutedMsg:
+		a.output += strings.TrimRight(result.output, "\n") + "\n"
+		m.notice = styles.SuccessStyle.Render("✅ " + strings.TrimSpace(result.output))
+	case errorMsg:
+		m.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", result.err))
+	case confirmationRequiredMsg:
+		a.pendingCommand = result.command
+		m.notice = styles.ErrorStyle.Render(a.tr("policy.confirm_terminal", result.err))
+	}
+	if a.state != jobsView {
+		return a, nil
+	}
+	return a, a.loadJobs()
+}
+
+// loadJobLogs fetches the logs of the latest pod of a job
+func (a *Application) loadJobLogs(job kubectl.ObjectRef) tea.Cmd {
+	a.jobs.loading = a.tr("jobs.fetching_logs", job.String())
+	session := a.jobs.session
+	return func() tea.Msg {
+		// kubectl logs job/<name> picks the job's most recent pod
+		args := append([]string{"logs"}, job.Args()...)
+		args = append(args, "--all-containers", fmt.Sprintf("--tail=%d", logTailLines))
+		logs, err := a.runBrowserCommand(session, args)
+		return jobsLogsMsg{job: job, logs: logs, err: err}
+	}
+}
+
+// handleJobsLogs shows job logs
+func (a *Application) handleJobsLogs(msg jobsLogsMsg) (tea.Model, tea.Cmd) {
+	m := &a.jobs
+	m.loading = ""
+	if msg.err != nil {
+		m.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", msg.err))
+		return a, nil
+	}
+	m.logsOf = &msg.job
+	m.viewport.Width = a.width - 4
+	m.viewport.Height = a.height - 10
+	m.viewport.SetContent(msg.logs)
+	m.viewport.GotoBottom()
+	return a, nil
+}
+
+// updateJobs handles jobs view updates
+func (a *Application) updateJobs(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m := &a.jobs
+	key := msg.String()
+
+	if m.logsOf != nil {
+		switch key {
+		case "esc":
+			m.logsOf = nil
+			return a, nil
+		case "ctrl+c":
+			return a, tea.Quit
+		case "r":
+			return a, a.loadJobLogs(*m.logsOf)
+		}
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return a, cmd
+	}
+
+	switch key {
+	case "esc":
+		a.state = terminalView
+		a.updateTerminalOutput()
+	case "ctrl+c":
+		return a, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.cronJobs)+len(m.jobs)-1 {
+			m.cursor++
+		}
+	case "r":
+		m.notice = ""
+		return a, a.loadJobs()
+	case "t":
+		if cronJob, ok := m.selectedCronJob(); ok {
+			m.loading = a.tr("jobs.triggering", cronJob.Name)
+			return a, a.runJobsCommand(kubectl.TriggerCronJobCommand(cronJob.ObjectRef, time.Now()))
+		}
+	case "s":
+		if cronJob, ok := m.selectedCronJob(); ok {
+			m.loading = a.tr("jobs.suspending", cronJob.Name)
+			if cronJob.Suspended {
+				m.loading = a.tr("jobs.resuming", cronJob.Name)
+			}
+			return a, a.runJobsCommand(kubectl.SuspendCronJobCommand(cronJob.ObjectRef, !cronJob.Suspended))
+		}
+	case "l":
+		if job, ok := m.selectedJob(); ok {
+			return a, a.loadJobLogs(job.ObjectRef)
+		}
+		m.notice = styles.InfoStyle.Render(a.tr("jobs.never_run"))
+	}
+	return a, nil
+}
+
+// renderJobs renders the jobs view
+func (a *Application) renderJobs() string {
+	m := &a.jobs
+
+	status := m.notice
+	if m.loading != "" {
+		status = styles.LoadingStyle.Render("⏳ " + m.loading)
+	}
+
+	if m.logsOf != nil {
+		return fmt.Sprintf("\n%s\n\n%s\n%s\n%s",
+			styles.TitleStyle.Render(a.tr("jobs.logs_title", m.logsOf.String())),
+			m.viewport.View(),
+			status,
+			styles.InfoStyle.Render(a.tr("jobs.logs_footer")))
+	}
+
+	var b strings.Builder
+	row := func(i int, line string) {
+		if i == m.cursor {
+			line = styles.SelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString(styles.HeaderStyle.Render(a.tr("jobs.cronjobs")) + "\n")
+	if len(m.cronJobs) == 0 {
+		b.WriteString(styles.InfoStyle.Render("  "+a.tr("jobs.no_cronjobs")) + "\n")
+	}
+	for i, cronJob := range m.cronJobs {
+		state := a.tr("jobs.active")
+		if cronJob.Suspended {
+			state = a.tr("jobs.suspended")
+		}
+		last := a.tr("jobs.last_never")
+		if cronJob.LastJob != nil {
+			last = a.tr("jobs.last_run", jobStatusIcon(cronJob.LastJob.Status), formatAge(cronJob.LastJob.Created))
+		} else if !cronJob.LastSchedule.IsZero() {
+			last = a.tr("jobs.last_scheduled", formatAge(cronJob.LastSchedule))
+		}
+		row(i, fmt.Sprintf("  %-40s %-16s %-10s %s  %s",
+			cronJob.Namespace+"/"+cronJob.Name, cronJob.Schedule, state, a.tr("jobs.running", cronJob.Active), last))
+	}
+
+	b.WriteString(styles.HeaderStyle.Render(a.tr("jobs.jobs")) + "\n")
+	if len(m.jobs) == 0 {
+		b.WriteString(styles.InfoStyle.Render("  "+a.tr("jobs.no_jobs")) + "\n")
+	}
+	for i, job := range m.jobs {
+		row(len(m.cronJobs)+i, fmt.Sprintf("  %-40s %s %-10s %d/%d  %s",
+			job.Namespace+"/"+job.Name, jobStatusIcon(job.Status), job.Status,
+			job.Succeeded, job.Completions, a.tr("jobs.age", formatAge(job.Created))))
+	}
+
+	footer := a.tr("jobs.footer")
+	if _, ok := m.selectedCronJob(); ok {
+		footer = a.tr("jobs.cronjob_footer")
+	}
+	return fmt.Sprintf("\n%s\n%s\n%s\n%s",
+		styles.TitleStyle.Render(a.tr("jobs.title")),
+		b.String(),
+		status,
+		styles.InfoStyle.Render(footer))
+}
+
+// jobStatusIcon returns an icon for a job state
+func jobStatusIcon(status string) string {
+	switch status {
+	case kubectl.JobComplete:
+		return "✅"
+	case kubectl.JobFailed:
+		return "❌"
+	case kubectl.JobRunning:
+		return "⏳"
+	case kubectl.JobSuspended:
+		return "⏸️"
+	}
+	return "•"
+}
+
+// formatAge formats the time since t like kubectl's AGE column
+func formatAge(t time.Time) string {
+	age := time.Since(t)
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(age.Hours()/24))
+}
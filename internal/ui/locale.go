@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/i18n"
+)
+
+// tr returns a translated message, formatted with args like fmt.Sprintf
+func (a *Application) tr(id string, args ...interface{}) string {
+	return a.catalog.T(id, args...)
+}
+
+// loadCatalog loads the catalog of the preferred locale. Without a preference
+// the system locale is used if a catalog exists for it.
+func loadCatalog(dir, locale string) (*i18n.Catalog, error) {
+	if locale == "" {
+		locale = i18n.SystemLocale()
+		if _, err := os.Stat(filepath.Join(dir, locale+".json")); err != nil {
+			return i18n.English(), nil
+		}
+	}
+	return i18n.Load(dir, locale)
+}
+
+// refreshTitles sets the titles and placeholders that are kept by widgets
+func (a *Application) refreshTitles() {
+	a.list.Title = a.tr("app.title")
+	if a.config.Workspace != config.DefaultWorkspace {
+		a.list.Title += " - " + a.config.Workspace
+	}
+	if a.readOnly {
+		a.list.Title += " " + a.tr("clusters.read_only")
+	}
+	a.configList.Title = a.tr("configs.title")
+	a.textInput.Placeholder = a.tr("input.placeholder")
+}
+
+// localeChangedMsg carries the catalog of a locale switched to, to be
+// applied in Update
+type localeChangedMsg struct {
+	catalog *i18n.Catalog
+}
+
+// localeSettings shows the locale, switches to another one or exports the
+// built-in messages for translation. The catalog switched to is applied by
+// handleLocaleChanged.
+func (a *Application) localeSettings(args []string) tea.Msg {
+	result := func(output string) tea.Msg {
+		return commandExecutedMsg{output: output}
+	}
+	if len(args) == 0 {
+		locales, err := i18n.Available(a.config.LocaleDir)
+		if err != nil {
+			return result(styles.ErrorStyle.Render(a.tr("locale.failed", err)))
+		}
+		info := a.tr("locale.title") + "\n\n"
+		for _, locale := range locales {
+			marker := "  "
+			if locale == a.catalog.Locale() {
+				marker = "▶ "
+			}
+			info += "  " + marker + locale + "\n"
+		}
+		if missing := len(a.catalog.Missing()); a.catalog.Locale() != i18n.DefaultLocale && missing > 0 {
+			info += "\n" + styles.InfoStyle.Render("  "+a.tr("locale.missing", missing))
+		}
+		info += "\n" + styles.InfoStyle.Render("  "+a.tr("locale.hint", a.config.LocaleDir))
+		return result(info)
+	}
+	if args[0] == "export" {
+		if len(args) != 2 {
+			return result(styles.ErrorStyle.Render(a.tr("locale.usage")))
+		}
+		if err := i18n.WriteTemplate(args[1]); err != nil {
+			return result(styles.ErrorStyle.Render(a.tr("locale.failed", err)))
+		}
+		return result(styles.SuccessStyle.Render(a.tr("locale.exported", args[1])))
+	}
+	if len(args) != 1 {
+		return result(styles.ErrorStyle.Render(a.tr("locale.usage")))
+	}
+
+	locale := args[0]
+	if locale == "default" {
+		locale = ""
+	}
+	catalog, err := loadCatalog(a.config.LocaleDir, locale)
+	if err != nil {
+		return result(styles.ErrorStyle.Render(a.tr("locale.failed", err)))
+	}
+	preferences, err := a.config.LoadPreferences()
+	if err != nil {
+		return result(styles.ErrorStyle.Render(a.tr("locale.failed", err)))
+	}
+	preferences.Locale = locale
+	if err := a.config.SavePreferences(preferences); err != nil {
+		return result(styles.ErrorStyle.Render(a.tr("locale.failed", err)))
+	}
+	return localeChangedMsg{catalog: catalog}
+}
+
+// handleLocaleChanged switches the messages of the application to a catalog
+func (a *Application) handleLocaleChanged(msg localeChangedMsg) (tea.Model, tea.Cmd) {
+	a.catalog = msg.catalog
+	a.refreshTitles()
+	a.refreshClusterList()
+	return a.update(commandExecutedMsg{output: styles.SuccessStyle.Render(a.tr("locale.switched", msg.catalog.Locale()))})
+}
@@ -0,0 +1,444 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/audit"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+const (
+	// maxLogPanes is how many pods or containers the tail view follows at once
+	maxLogPanes = 6
+	// maxPaneLines is how many lines a pane keeps; older lines are dropped
+	maxPaneLines = 1000
+	// logReadBatch is the most lines a pane takes from its stream per update
+	logReadBatch = 100
+)
+
+// paneColors tell the panes of the tail view apart, in pane order
+var paneColors = []lipgloss.Color{"#5FAFFF", "#04B575", "#FFAF00", "#FF5F87", "#AF87FF", "#00D7D7"}
+
+// Messages for the tail view
+type multiLogPodsMsg struct {
+	pods []kubectl.PodContainers
+	err  error
+}
+type multiLogStartedMsg struct {
+	generation int
+	pane       int
+	stream     *kubectl.Stream
+	err        error
+}
+type multiLogLinesMsg struct {
+	generation int
+	pane       int
+	lines      []string
+	done       bool
+	err        error
+}
+
+// logTarget is a container to follow, or every container of a pod when
+// container is empty
+type logTarget struct {
+	pod       kubectl.ObjectRef
+	container string
+}
+
+// String returns the namespace/pod/container form of the target
+func (t logTarget) String() string {
+	name := t.pod.Name
+	if t.pod.Namespace != "" {
+		name = t.pod.Namespace + "/" + name
+	}
+	if t.container != "" {
+		name += "/" + t.container
+	}
+	return name
+}
+
+// logPane holds the lines streamed from one target
+type logPane struct {
+	target logTarget
+	stream *kubectl.Stream
+	lines  []string
+	pin    int // length of lines when scrolling started; the window ends offset lines before it
+	status string
+}
+
+// multiLog picks pods and containers and tails their logs in stacked panes,
+// scrolling them together
+type multiLog struct {
+	session clusterSession
+	flags   []string
+	// Picker
+	targets []logTarget
+	marked  map[int]bool
+	cursor  int
+	loading string
+	notice  string
+	// Panes, set once tailing started
+	panes  []*logPane
+	offset int // lines scrolled up from the newest; 0 follows new lines
+	// generation invalidates the streams of panes that were closed
+	generation int
+}
+
+// openMultiLog handles the tail built-in: pods named in the arguments are
+// tailed right away, otherwise the pods selected by the namespace flags are
+// listed to pick from
+func (a *Application) openMultiLog(args []string) (tea.Model, tea.Cmd) {
+	var flags, names []string
+	namespace := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case (args[i] == "-n" || args[i] == "--namespace") && i+1 < len(args):
+			namespace = args[i+1]
+			flags = append(flags, args[i], args[i+1])
+			i++
+		case strings.HasPrefix(args[i], "-"):
+			flags = append(flags, args[i])
+		default:
+			names = append(names, args[i])
+		}
+	}
+
+	a.multiLog = multiLog{
+		session:    a.currentSession(),
+		flags:      flags,
+		marked:     map[int]bool{},
+		generation: a.multiLog.generation + 1,
+	}
+	a.state = multiLogView
+	if len(names) == 0 {
+		return a, a.loadLogTargets()
+	}
+
+	var targets []logTarget
+	for _, name := range names {
+		pod, container, _ := strings.Cut(name, "/")
+		targets = append(targets, logTarget{
+			pod:       kubectl.ObjectRef{Kind: "pod", Name: pod, Namespace: namespace},
+			container: container,
+		})
+	}
+	if len(targets) > maxLogPanes {
+		a.multiLog.notice = styles.InfoStyle.Render(a.tr("multilog.truncated", maxLogPanes, len(targets)))
+		targets = targets[:maxLogPanes]
+	}
+	return a, a.startLogPanes(targets)
+}
+
+// loadLogTargets lists the pods to pick from in the background
+func (a *Application) loadLogTargets() tea.Cmd {
+	a.multiLog.loading = a.tr("multilog.loading")
+	session, flags := a.multiLog.session, a.multiLog.flags
+	return func() tea.Msg {
+		pods, err := session.executor.ListPods(flags...)
+		return multiLogPodsMsg{pods: pods, err: err}
+	}
+}
+
+// handleMultiLogPods offers every container of the listed pods as a target
+func (a *Application) handleMultiLogPods(msg multiLogPodsMsg) (tea.Model, tea.Cmd) {
+	m := &a.multiLog
+	m.loading = ""
+	if msg.err != nil {
+		m.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", msg.err))
+		return a, nil
+	}
+	m.targets, m.marked = nil, map[int]bool{}
+	for _, pod := range msg.pods {
+		for _, container := range pod.Containers {
+			target := logTarget{pod: pod.ObjectRef, container: container}
+			// A single container is named by its pod alone
+			if len(pod.Containers) == 1 {
+				target.container = ""
+			}
+			m.targets = append(m.targets, target)
+		}
+	}
+	if m.cursor >= len(m.targets) {
+		m.cursor = max(0, len(m.targets)-1)
+	}
+	return a, nil
+}
+
+// startLogPanes opens a pane per target and starts following their logs
+func (a *Application) startLogPanes(targets []logTarget) tea.Cmd {
+	m := &a.multiLog
+	m.panes, m.offset = nil, 0
+	session, generation := m.session, m.generation
+
+	var cmds []tea.Cmd
+	for i, target := range targets {
+		m.panes = append(m.panes, &logPane{target: target, status: a.tr("multilog.starting")})
+		pane, target := i, target
+		cmds = append(cmds, func() tea.Msg {
+			args := kubectl.FollowLogsArgs(target.pod, target.container, logTailLines)
+			started := time.Now()
+			stream, err := session.executor.Stream(args...)
+			a.recordResult(session.cluster.Name, audit.SourceKubectl, strings.Join(args, " "), started, err)
+			return multiLogStartedMsg{generation: generation, pane: pane, stream: stream, err: err}
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+// readLogLines waits for the next lines of a pane's stream, taking the ones
+// already buffered along with the first
+func readLogLines(generation, pane int, stream *kubectl.Stream) tea.Cmd {
+	return func() tea.Msg {
+		msg := multiLogLinesMsg{generation: generation, pane: pane}
+		line, ok := <-stream.Lines()
+		for ok {
+			msg.lines = append(msg.lines, line)
+			if len(msg.lines) == logReadBatch {
+				return msg
+			}
+			select {
+			case line, ok = <-stream.Lines():
+			default:
+				return msg
+			}
+		}
+		msg.done, msg.err = true, stream.Err()
+		return msg
+	}
+}
+
+// handleMultiLogStarted starts reading a pane's stream
+func (a *Application) handleMultiLogStarted(msg multiLogStartedMsg) (tea.Model, tea.Cmd) {
+	m := &a.multiLog
+	if msg.generation != m.generation {
+		// The panes were closed while kubectl started
+		if msg.stream != nil {
+			msg.stream.Stop()
+		}
+		return a, nil
+	}
+	pane := m.panes[msg.pane]
+	if msg.err != nil {
+		pane.status = fmt.Sprintf("❌ %v", msg.err)
+		return a, nil
+	}
+	pane.stream, pane.status = msg.stream, a.tr("multilog.following")
+	return a, readLogLines(m.generation, msg.pane, msg.stream)
+}
+
+// handleMultiLogLines appends streamed lines to a pane and reads on
+func (a *Application) handleMultiLogLines(msg multiLogLinesMsg) (tea.Model, tea.Cmd) {
+	m := &a.multiLog
+	if msg.generation != m.generation {
+		return a, nil
+	}
+	pane := m.panes[msg.pane]
+	pane.lines = append(pane.lines, msg.lines...)
+	if drop := len(pane.lines) - maxPaneLines; drop > 0 {
+		pane.lines = pane.lines[drop:]
+		pane.pin = max(0, pane.pin-drop)
+	}
+	if !msg.done {
+		return a, readLogLines(m.generation, msg.pane, pane.stream)
+	}
+	pane.status = a.tr("multilog.ended")
+	if msg.err != nil {
+		pane.status = fmt.Sprintf("❌ %v", msg.err)
+	}
+	return a, nil
+}
+
+// stopLogPanes stops every stream and invalidates the ones still starting
+func (a *Application) stopLogPanes() {
+	m := &a.multiLog
+	m.generation++
+	for _, pane := range m.panes {
+		if pane.stream != nil {
+			pane.stream.Stop()
+		}
+	}
+	m.panes = nil
+}
+
+// paneHeight returns how many log lines each pane shows
+func (a *Application) paneHeight() int {
+	count := max(1, len(a.multiLog.panes))
+	// Each pane has a header line
+	return max(1, (a.height-8)/count-1)
+}
+
+// scrollLogPanes moves every pane by delta lines, positive being back in time.
+// Panes are pinned to their newest line when scrolling starts, so new lines
+// don't move them.
+func (a *Application) scrollLogPanes(delta int) {
+	m := &a.multiLog
+	if m.offset == 0 && delta > 0 {
+		for _, pane := range m.panes {
+			pane.pin = len(pane.lines)
+		}
+	}
+	limit := 0
+	for _, pane := range m.panes {
+		limit = max(limit, pane.pin-a.paneHeight())
+	}
+	m.offset = max(0, min(m.offset+delta, limit))
+}
+
+// updateMultiLog handles tail view updates
+func (a *Application) updateMultiLog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m := &a.multiLog
+	key := msg.String()
+
+	if m.panes != nil {
+		switch key {
+		case "esc":
+			a.stopLogPanes()
+			if m.targets == nil {
+				a.state = terminalView
+				a.updateTerminalOutput()
+			}
+		case "ctrl+c":
+			a.stopLogPanes()
+			return a, tea.Quit
+		case "up", "k":
+			a.scrollLogPanes(1)
+		case "down", "j":
+			a.scrollLogPanes(-1)
+		case "pgup":
+			a.scrollLogPanes(a.paneHeight())
+		case "pgdown":
+			a.scrollLogPanes(-a.paneHeight())
+		case "g", "home":
+			a.scrollLogPanes(maxPaneLines)
+		case "G", "end":
+			m.offset = 0
+		}
+		return a, nil
+	}
+
+	switch key {
+	case "esc":
+		a.state = terminalView
+		a.updateTerminalOutput()
+	case "ctrl+c":
+		return a, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.targets)-1 {
+			m.cursor++
+		}
+	case " ":
+		if len(m.targets) == 0 {
+			return a, nil
+		}
+		if !m.marked[m.cursor] && len(m.marked) == maxLogPanes {
+			m.notice = styles.InfoStyle.Render(a.tr("multilog.too_many", maxLogPanes))
+			return a, nil
+		}
+		if m.marked[m.cursor] {
+			delete(m.marked, m.cursor)
+		} else {
+			m.marked[m.cursor] = true
+		}
+		m.notice = ""
+	case "r":
+		m.notice = ""
+		return a, a.loadLogTargets()
+	case "enter":
+		var targets []logTarget
+		for i, target := range m.targets {
+			if m.marked[i] {
+				targets = append(targets, target)
+			}
+		}
+		if len(targets) == 0 && len(m.targets) > 0 {
+			targets = []logTarget{m.targets[m.cursor]}
+		}
+		if len(targets) > 0 {
+			m.notice = ""
+			return a, a.startLogPanes(targets)
+		}
+	}
+	return a, nil
+}
+
+// renderMultiLog renders the tail view
+func (a *Application) renderMultiLog() string {
+	m := &a.multiLog
+	if m.panes != nil {
+		return a.renderLogPanes()
+	}
+
+	status := m.notice
+	if m.loading != "" {
+		status = styles.LoadingStyle.Render("⏳ " + m.loading)
+	}
+
+	var b strings.Builder
+	if len(m.targets) == 0 && m.loading == "" {
+		b.WriteString(styles.InfoStyle.Render("  "+a.tr("multilog.no_pods")) + "\n")
+	}
+	for i, target := range m.targets {
+		mark := "[ ]"
+		if m.marked[i] {
+			mark = "[x]"
+		}
+		line := fmt.Sprintf("  %s %s", mark, target)
+		if i == m.cursor {
+			line = styles.SelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	return fmt.Sprintf("\n%s\n\n%s\n%s\n%s",
+		styles.TitleStyle.Render(a.tr("multilog.title")),
+		b.String(),
+		status,
+		styles.InfoStyle.Render(a.tr("multilog.select_help", maxLogPanes)))
+}
+
+// renderLogPanes renders the panes stacked, each showing the same stretch of
+// time relative to its newest line
+func (a *Application) renderLogPanes() string {
+	m := &a.multiLog
+	height := a.paneHeight()
+	width := max(10, a.width-4)
+
+	var b strings.Builder
+	for i, pane := range m.panes {
+		color := lipgloss.NewStyle().Foreground(paneColors[i%len(paneColors)])
+		b.WriteString(color.Bold(true).Render("● "+pane.target.String()) + " " + styles.InfoStyle.Render(pane.status) + "\n")
+
+		end := len(pane.lines)
+		if m.offset > 0 {
+			end = max(min(height, len(pane.lines)), pane.pin-m.offset)
+		}
+		start := max(0, end-height)
+		bar := color.Render("│ ")
+		line := lipgloss.NewStyle().MaxWidth(width - 2)
+		for _, text := range pane.lines[start:end] {
+			b.WriteString(bar + line.Render(text) + "\n")
+		}
+		for j := end - start; j < height; j++ {
+			b.WriteString(bar + "\n")
+		}
+	}
+
+	position := a.tr("multilog.following")
+	if m.offset > 0 {
+		position = a.tr("multilog.lines_back", m.offset)
+	}
+	return fmt.Sprintf("\n%s\n\n%s%s\n%s",
+		styles.TitleStyle.Render(a.tr("multilog.tailing", len(m.panes), position)),
+		b.String(),
+		m.notice,
+		styles.InfoStyle.Render(a.tr("multilog.tail_help")))
+}
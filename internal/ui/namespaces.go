@@ -0,0 +1,313 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// Input modes of the namespace manager
+const (
+	namespaceBrowsing = iota
+	namespaceCreating
+	namespaceDeleting
+)
+
+// Messages for the namespace manager
+type namespacesMsg struct {
+	namespaces []kubectl.NamespaceInfo
+	err        error
+}
+type namespaceActionMsg struct{ result tea.Msg }
+
+// namespaceManager lists namespaces with their resource counts, quotas and limits
+type namespaceManager struct {
+	session    clusterSession
+	namespaces []kubectl.NamespaceInfo
+	cursor     int
+	mode       int
+	loading    string
+	notice     string
+}
+
+// openNamespaces opens the namespace manager
+func (a *Application) openNamespaces() (tea.Model, tea.Cmd) {
+	a.namespaces = namespaceManager{session: a.currentSession()}
+	a.state = namespacesView
+	return a, a.loadNamespaces()
+}
+
+// loadNamespaces fetches namespaces in the background
+func (a *Application) loadNamespaces() tea.Cmd {
+	a.namespaces.loading = a.tr("namespaces.loading")
+	session := a.namespaces.session
+	return func() tea.Msg {
+		namespaces, err := session.executor.ListNamespaces()
+		return namespacesMsg{namespaces: namespaces, err: err}
+	}
+}
+
+// handleNamespaces shows the loaded namespaces, keeping the cursor on the same one
+func (a *Application) handleNamespaces(msg namespacesMsg) (tea.Model, tea.Cmd) {
+	m := &a.namespaces
+	m.loading = ""
+	if msg.err != nil {
+		m.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", msg.err))
+		return a, nil
+	}
+
+	selected := ""
+	if m.cursor < len(m.namespaces) {
+		selected = m.namespaces[m.cursor].Name
+	}
+	m.namespaces = msg.namespaces
+	m.cursor = 0
+	for i, namespace := range m.namespaces {
+		if namespace.Name == selected {
+			m.cursor = i
+		}
+	}
+	return a, nil
+}
+
+// runNamespaceCommand runs a create or delete through the command queue
+func (a *Application) runNamespaceCommand(command string) tea.Cmd {
+	session := a.namespaces.session
+	a.output += fmt.Sprintf("%s %s\n", styles.PromptStyle.Render(fmt.Sprintf("[%s]$", session.cluster.Name)), command)
+
+	return tea.Batch(a.startCommand(session), a.queueCommand(session, command, func() tea.Msg {
+		return namespaceActionMsg{result: a.runKubectlCommand(session, command, false)}
+	}))
+}
+
+// handleNamespaceAction reports the result of a create or delete and reloads the list
+func (a *Application) handleNamespaceAction(msg namespaceActionMsg) (tea.Model, tea.Cmd) {
+	m := &a.namespaces
+	m.loading = ""
+	switch result := msg.result.(type) {
+	case commandExecutedMsg:
+		a.output += strings.TrimRight(result.output, "\n") + "\n"
+		m.notice = styles.SuccessStyle.Render("✅ " + strings.TrimSpace(strings.SplitN(result.output, "\n", 2)[0]))
+	case errorMsg:
+		m.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", result.err))
+	case confirmationRequiredMsg:
+		a.pendingCommand = result.command
+		m.notice = styles.ErrorStyle.Render(a.tr("policy.confirm_terminal", result.err))
+	}
+	if a.state != namespacesView {
+		return a, nil
+	}
+	return a, a.loadNamespaces()
+}
+
+// setDefaultNamespace makes terminal commands run in a namespace and remembers it for the cluster
+func (a *Application) setDefaultNamespace(name string) {
+	m := &a.namespaces
+	m.session.executor.SetNamespace(name)
+
+	cluster := *m.session.cluster
+	cluster.Namespace = name
+	if err := a.config.UpdateCluster(cluster); err != nil {
+		m.notice = styles.ErrorStyle.Render(a.tr("namespaces.save_failed", err))
+		return
+	}
+	m.session.cluster.Namespace = name
+	if name == "" {
+		m.notice = styles.SuccessStyle.Render(a.tr("namespaces.cleared"))
+	} else {
+		m.notice = styles.SuccessStyle.Render(a.tr("namespaces.set", name))
+	}
+}
+
+// updateNamespaces handles namespace manager updates
+func (a *Application) updateNamespaces(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m := &a.namespaces
+	key := msg.String()
+
+	if m.mode != namespaceBrowsing {
+		switch key {
+		case "esc":
+			m.mode = namespaceBrowsing
+			m.notice = ""
+			return a, nil
+		case "ctrl+c":
+			return a, tea.Quit
+		case "enter":
+			value := strings.TrimSpace(a.textInput.Value())
+			mode := m.mode
+			m.mode = namespaceBrowsing
+			if mode == namespaceCreating {
+				if value == "" {
+					return a, nil
+				}
+				m.loading = a.tr("namespaces.creating", value)
+				return a, a.runNamespaceCommand("create namespace " + value)
+			}
+
+			name := m.namespaces[m.cursor].Name
+			if value != name {
+				m.notice = styles.ErrorStyle.Render(a.tr("namespaces.name_mismatch"))
+				return a, nil
+			}
+			m.loading = a.tr("namespaces.deleting", name)
+			return a, a.runNamespaceCommand("delete namespace " + name)
+		}
+		var cmd tea.Cmd
+		a.textInput, cmd = a.textInput.Update(msg)
+		return a, cmd
+	}
+
+	switch key {
+	case "esc":
+		a.state = terminalView
+		a.updateTerminalOutput()
+	case "ctrl+c":
+		return a, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.namespaces)-1 {
+			m.cursor++
+		}
+	case "r":
+		m.notice = ""
+		return a, a.loadNamespaces()
+	case "n":
+		m.mode = namespaceCreating
+		m.notice = ""
+		a.textInput.SetValue("")
+		a.textInput.Placeholder = a.tr("namespaces.name_placeholder")
+	case "d":
+		if m.cursor >= len(m.namespaces) {
+			return a, nil
+		}
+		name := m.namespaces[m.cursor].Name
+		if kubectl.IsProtectedNamespace(name) {
+			m.notice = styles.ErrorStyle.Render(a.tr("namespaces.protected", name))
+			return a, nil
+		}
+		m.mode = namespaceDeleting
+		m.notice = ""
+		a.textInput.SetValue("")
+		a.textInput.Placeholder = name
+	case "enter", "u":
+		if m.cursor < len(m.namespaces) {
+			a.setDefaultNamespace(m.namespaces[m.cursor].Name)
+		}
+	case "x":
+		a.setDefaultNamespace("")
+	}
+	return a, nil
+}
+
+// renderNamespaces renders the namespace manager
+func (a *Application) renderNamespaces() string {
+	m := &a.namespaces
+	current := m.session.executor.Namespace()
+
+	var b strings.Builder
+	for i, namespace := range m.namespaces {
+		var counts []string
+		for _, kind := range kubectl.CountedKinds() {
+			counts = append(counts, fmt.Sprintf("%d %s", namespace.Counts[kind], kind))
+		}
+		marker := "  "
+		if namespace.Name == current {
+			marker = "★ "
+		}
+		line := fmt.Sprintf("%s%-28s %-12s %5s  %s", marker, namespace.Name, namespace.Status,
+			formatAge(namespace.Created), strings.Join(counts, " • "))
+		if i == m.cursor {
+			line = styles.SelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	if m.cursor < len(m.namespaces) {
+		b.WriteString(a.formatNamespacePolicies(m.namespaces[m.cursor]))
+	}
+
+	status := m.notice
+	if m.loading != "" {
+		status = styles.LoadingStyle.Render("⏳ " + m.loading)
+	}
+
+	footer := a.tr("namespaces.footer")
+	switch m.mode {
+	case namespaceCreating:
+		status = a.tr("namespaces.new_name") + "\n" + a.textInput.View()
+		footer = a.tr("namespaces.create_footer")
+	case namespaceDeleting:
+		name := m.namespaces[m.cursor].Name
+		status = styles.ErrorStyle.Render(a.tr("namespaces.delete_confirm", name)) +
+			"\n" + a.textInput.View()
+		footer = a.tr("namespaces.delete_footer")
+	}
+
+	title := a.tr("namespaces.title")
+	if current != "" {
+		title = a.tr("namespaces.title_default", current)
+	}
+	return fmt.Sprintf("\n%s\n\n%s\n%s\n\n%s",
+		styles.TitleStyle.Render(title),
+		b.String(),
+		status,
+		styles.InfoStyle.Render(footer))
+}
+
+// formatNamespacePolicies formats the quotas and limit ranges of a namespace
+func (a *Application) formatNamespacePolicies(namespace kubectl.NamespaceInfo) string {
+	var b strings.Builder
+	b.WriteString("\n" + styles.HeaderStyle.Render(a.tr("namespaces.policies", namespace.Name)) + "\n")
+	if len(namespace.Quotas) == 0 && len(namespace.Limits) == 0 {
+		b.WriteString(styles.InfoStyle.Render("  "+a.tr("namespaces.no_policies")) + "\n")
+	}
+
+	for _, quota := range namespace.Quotas {
+		b.WriteString("  " + a.tr("namespaces.quota", quota.Name) + "\n")
+		for _, resource := range sortedKeys(quota.Hard) {
+			used := quota.Used[resource]
+			if used == "" {
+				used = "0"
+			}
+			b.WriteString(fmt.Sprintf("    %-28s %s / %s\n", resource, used, quota.Hard[resource]))
+		}
+	}
+	for _, limit := range namespace.Limits {
+		b.WriteString("  " + a.tr("namespaces.limits", limit.Name, limit.Type) + "\n")
+		for _, entry := range []struct {
+			label  string
+			values map[string]string
+		}{
+			{a.tr("namespaces.limit_default"), limit.Default},
+			{a.tr("namespaces.limit_default_request"), limit.DefaultRequest},
+			{a.tr("namespaces.limit_max"), limit.Max},
+			{a.tr("namespaces.limit_min"), limit.Min},
+		} {
+			if len(entry.values) == 0 {
+				continue
+			}
+			var values []string
+			for _, resource := range sortedKeys(entry.values) {
+				values = append(values, resource+"="+entry.values[resource])
+			}
+			b.WriteString(fmt.Sprintf("    %-16s %s\n", entry.label, strings.Join(values, ", ")))
+		}
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(values map[string]string) []string {
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,270 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/audit"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/system"
+)
+
+// Messages for port-forwards
+type portForwardStartedMsg struct {
+	forward       *portForward
+	local, remote int
+	stream        *kubectl.Stream
+	err           error
+}
+type portForwardLineMsg struct {
+	forward *portForward
+	line    string
+	done    bool
+	err     error
+}
+
+// portForward is a kubectl port-forward running in the background
+type portForward struct {
+	id       int
+	cluster  string
+	object   kubectl.ObjectRef
+	local    int
+	remote   int
+	stream   *kubectl.Stream
+	started  time.Time
+	status   string
+	openURL  bool // open the URL once the forward is up
+	ready    bool
+	lastLine string
+}
+
+// url returns the local address of the forward
+func (f *portForward) url() string {
+	scheme := "http"
+	if f.remote == 443 || f.remote == 8443 {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://localhost:%d", scheme, f.local)
+}
+
+// portForwardManager keeps the port-forwards of every cluster, which run
+// until they are stopped or the application exits
+type portForwardManager struct {
+	forwards []*portForward
+	nextID   int
+	cursor   int
+	notice   string
+}
+
+// active reports whether the forward is still managed
+func (m *portForwardManager) active(forward *portForward) bool {
+	for _, f := range m.forwards {
+		if f == forward {
+			return true
+		}
+	}
+	return false
+}
+
+// startPortForward forwards a free local port to the default port of a
+// service or pod and registers the forward, optionally opening its URL in
+// the browser once it's up
+func (a *Application) startPortForward(session clusterSession, object kubectl.ObjectRef, openURL bool) tea.Cmd {
+	m := &a.portForwards
+	m.nextID++
+	forward := &portForward{
+		id:      m.nextID,
+		cluster: session.cluster.Name,
+		object:  object,
+		started: time.Now(),
+		status:  a.tr("portforward.starting"),
+		openURL: openURL,
+	}
+	m.forwards = append(m.forwards, forward)
+
+	return func() tea.Msg {
+		remote, err := session.executor.DefaultPort(object)
+		if err != nil {
+			return portForwardStartedMsg{forward: forward, err: err}
+		}
+		local, err := kubectl.FreeLocalPort()
+		if err != nil {
+			return portForwardStartedMsg{forward: forward, err: err}
+		}
+		args := kubectl.PortForwardArgs(object, local, remote)
+		started := time.Now()
+		stream, err := session.executor.Stream(args...)
+		a.recordResult(session.cluster.Name, audit.SourceKubectl, strings.Join(args, " "), started, err)
+		return portForwardStartedMsg{forward: forward, local: local, remote: remote, stream: stream, err: err}
+	}
+}
+
+// readForwardLine waits for the next output line of a forward
+func readForwardLine(forward *portForward) tea.Cmd {
+	stream := forward.stream
+	return func() tea.Msg {
+		line, ok := <-stream.Lines()
+		if !ok {
+			return portForwardLineMsg{forward: forward, done: true, err: stream.Err()}
+		}
+		return portForwardLineMsg{forward: forward, line: line}
+	}
+}
+
+// handlePortForwardStarted starts reading a forward's output
+func (a *Application) handlePortForwardStarted(msg portForwardStartedMsg) (tea.Model, tea.Cmd) {
+	forward := msg.forward
+	if !a.portForwards.active(forward) {
+		// Stopped while kubectl started
+		if msg.stream != nil {
+			msg.stream.Stop()
+		}
+		return a, nil
+	}
+	if msg.err != nil {
+		forward.status = fmt.Sprintf("❌ %v", msg.err)
+		a.browser.notice = styles.ErrorStyle.Render(a.tr("portforward.failed", forward.object, msg.err))
+		return a, nil
+	}
+	forward.local, forward.remote, forward.stream = msg.local, msg.remote, msg.stream
+	return a, readForwardLine(forward)
+}
+
+// handlePortForwardLine tracks whether a forward is up and opens its URL
+// when asked to
+func (a *Application) handlePortForwardLine(msg portForwardLineMsg) (tea.Model, tea.Cmd) {
+	forward := msg.forward
+	if !a.portForwards.active(forward) {
+		return a, nil
+	}
+	if msg.done {
+		forward.ready = false
+		forward.status = a.tr("portforward.ended")
+		if msg.err != nil {
+			forward.status = fmt.Sprintf("❌ %s", forward.lastLine)
+			if forward.lastLine == "" {
+				forward.status = fmt.Sprintf("❌ %v", msg.err)
+			}
+		}
+		return a, nil
+	}
+
+	forward.lastLine = msg.line
+	if kubectl.IsForwarding(msg.line) && !forward.ready {
+		forward.ready = true
+		forward.status = a.tr("portforward.forwarding")
+		a.browser.notice = styles.SuccessStyle.Render(a.tr("portforward.started", forward.object, forward.url()))
+		if forward.openURL {
+			forward.openURL = false
+			if err := system.OpenURL(forward.url()); err != nil {
+				a.browser.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+			}
+		}
+	}
+	return a, readForwardLine(forward)
+}
+
+// stopPortForward ends a forward and drops it from the manager
+func (a *Application) stopPortForward(forward *portForward) {
+	m := &a.portForwards
+	for i, f := range m.forwards {
+		if f == forward {
+			m.forwards = append(m.forwards[:i], m.forwards[i+1:]...)
+			break
+		}
+	}
+	if forward.stream != nil {
+		forward.stream.Stop()
+	}
+	if m.cursor >= len(m.forwards) {
+		m.cursor = max(0, len(m.forwards)-1)
+	}
+}
+
+// StopPortForwards ends every port-forward, as the application exits
+func (a *Application) StopPortForwards() {
+	for len(a.portForwards.forwards) > 0 {
+		a.stopPortForward(a.portForwards.forwards[0])
+	}
+}
+
+// openPortForwards shows the port-forward manager
+func (a *Application) openPortForwards() (tea.Model, tea.Cmd) {
+	a.portForwards.notice = ""
+	a.state = portForwardsView
+	return a, nil
+}
+
+// updatePortForwards handles port-forward manager updates
+func (a *Application) updatePortForwards(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m := &a.portForwards
+	switch msg.String() {
+	case "esc":
+		a.state = terminalView
+		a.updateTerminalOutput()
+	case "ctrl+c":
+		return a, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.forwards)-1 {
+			m.cursor++
+		}
+	case "o":
+		if len(m.forwards) > 0 {
+			forward := m.forwards[m.cursor]
+			if !forward.ready {
+				m.notice = styles.InfoStyle.Render(forward.object.String() + " isn't forwarding")
+				return a, nil
+			}
+			if err := system.OpenURL(forward.url()); err != nil {
+				m.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+			}
+		}
+	case "x":
+		if len(m.forwards) > 0 {
+			forward := m.forwards[m.cursor]
+			a.stopPortForward(forward)
+			m.notice = styles.SuccessStyle.Render(a.tr("portforward.stopped", forward.object))
+		}
+	}
+	return a, nil
+}
+
+// renderPortForwards renders the port-forward manager
+func (a *Application) renderPortForwards() string {
+	m := &a.portForwards
+
+	var b strings.Builder
+	if len(m.forwards) == 0 {
+		b.WriteString(styles.InfoStyle.Render("  "+a.tr("portforward.empty")) + "\n")
+	}
+	for i, forward := range m.forwards {
+		object := forward.object.String()
+		if forward.object.Namespace != "" {
+			object = forward.object.Namespace + "/" + object
+		}
+		local := "-"
+		if forward.local != 0 {
+			local = fmt.Sprintf("%s → %d", forward.url(), forward.remote)
+		}
+		line := fmt.Sprintf("  #%-3d %-16s %-40s %-32s %-12s %s",
+			forward.id, forward.cluster, object, local,
+			formatAge(forward.started), forward.status)
+		if i == m.cursor {
+			line = styles.SelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	return fmt.Sprintf("\n%s\n\n%s\n%s\n%s",
+		styles.TitleStyle.Render(a.tr("portforward.title")),
+		b.String(),
+		m.notice,
+		styles.InfoStyle.Render(a.tr("portforward.help")))
+}
@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxPromptRows bounds the rows of a command being edited
+const maxPromptRows = 1 << 16
+
+// newCommandPrompt creates the terminal's command editor. It grows with the
+// command, so pasted manifests and heredocs can be edited in place.
+func newCommandPrompt() textarea.Model {
+	prompt := textarea.New()
+	prompt.ShowLineNumbers = false
+	prompt.CharLimit = 0
+	prompt.MaxHeight = 0
+	prompt.MaxWidth = 0
+	prompt.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	prompt.FocusedStyle.Prompt = lipgloss.NewStyle()
+	// Enter runs the command once it's complete; these always start a new line
+	prompt.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("ctrl+j", "alt+enter"))
+	// ctrl+t opens the resource browser
+	prompt.KeyMap.TransposeCharacterBackward = key.NewBinding(key.WithDisabled())
+	prompt.Cursor.SetMode(cursor.CursorStatic)
+	prompt.Focus()
+	return prompt
+}
+
+// resizePrompt fits the command editor to the terminal and the lines of the
+// command, labelling the first line with the cluster and namespace and the
+// rest as continuations
+func (a *Application) resizePrompt() {
+	label := a.selectedCluster.Name
+	if namespace := a.kubectlExecutor.Namespace(); namespace != "" {
+		label += ":" + namespace
+	}
+	first := styles.PromptStyle.Render(fmt.Sprintf("[%s]$", label)) + " "
+	width := lipgloss.Width(first)
+	continuation := strings.Repeat(" ", width-2) + styles.PromptStyle.Render(">") + " "
+	a.prompt.SetPromptFunc(width, func(line int) string {
+		if line == 0 {
+			return first
+		}
+		return continuation
+	})
+	a.prompt.SetWidth(max(a.viewport.Width, width+10))
+
+	rows := 0
+	for _, line := range strings.Split(a.prompt.Value(), "\n") {
+		rows += wrappedRows([]rune(line), a.prompt.Width())
+	}
+	a.prompt.SetHeight(rows)
+}
+
+// editPrompt passes a message to the command editor. The editor scrolls when
+// the cursor leaves its rows, so it's given room for any number of lines
+// while editing and then fitted to them, which keeps every line in view.
+func (a *Application) editPrompt(msg tea.Msg) tea.Cmd {
+	a.prompt.SetHeight(maxPromptRows)
+	var cmd tea.Cmd
+	a.prompt, cmd = a.prompt.Update(msg)
+	a.resizePrompt()
+	return cmd
+}
+
+// wrappedRows counts the rows the editor wraps a line into, wrapping at word
+// boundaries the way textarea does
+func wrappedRows(line []rune, width int) int {
+	rows, lineWidth, wordWidth, lastWidth, spaces := 1, 0, 0, 0, 0
+	for _, r := range line {
+		if unicode.IsSpace(r) {
+			spaces++
+		} else {
+			lastWidth = lipgloss.Width(string(r))
+			wordWidth += lastWidth
+		}
+
+		if spaces > 0 {
+			if lineWidth+wordWidth+spaces > width {
+				rows++
+				lineWidth = 0
+			}
+			lineWidth += wordWidth + spaces
+			wordWidth, spaces = 0, 0
+		} else if wordWidth+lastWidth > width {
+			// A word filling a whole row
+			if lineWidth > 0 {
+				rows++
+			}
+			lineWidth, wordWidth = wordWidth, 0
+		}
+	}
+	if lineWidth+wordWidth+spaces >= width {
+		rows++
+	}
+	return rows
+}
+
+// scrollsOutput reports whether a key scrolls the terminal output rather than
+// editing the command. Up and down move between the lines of a multi-line
+// command.
+func (a *Application) scrollsOutput(msg tea.KeyMsg) bool {
+	switch msg.Type {
+	case tea.KeyPgUp, tea.KeyPgDown:
+		return true
+	case tea.KeyUp, tea.KeyDown:
+		return a.prompt.LineCount() == 1
+	}
+	return false
+}
+
+// echoCommand formats a command for the output, marking continuation lines
+// like the prompt does
+func (a *Application) echoCommand(command string) string {
+	return fmt.Sprintf("%s %s\n",
+		styles.PromptStyle.Render(fmt.Sprintf("[%s]$", a.selectedCluster.Name)),
+		strings.ReplaceAll(command, "\n", "\n"+styles.PromptStyle.Render(">")+" "))
+}
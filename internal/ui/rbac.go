@@ -0,0 +1,138 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/git"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// rbacRulesShown is how many rules of a role the RBAC matrix lists before
+// summarizing the rest
+const rbacRulesShown = 3
+
+// getRBACInfo shows who can do what in a session's cluster, or with diff
+// how the last synced RBAC change differs from the export before it
+func (a *Application) getRBACInfo(session clusterSession, args []string) string {
+	switch {
+	case len(args) == 0:
+		return a.rbacMatrix(session, false)
+	case len(args) == 1 && args[0] == "all":
+		return a.rbacMatrix(session, true)
+	case len(args) == 1 && args[0] == "diff":
+		return a.rbacDiff(session)
+	}
+	return styles.ErrorStyle.Render(a.tr("rbac.usage"))
+}
+
+// rbacMatrix lists the grants of a session's cluster by subject. The grants
+// Kubernetes sets up itself are hidden unless all is set.
+func (a *Application) rbacMatrix(session clusterSession, all bool) string {
+	grants, err := session.executor.ListRBAC()
+	if err != nil {
+		return styles.ErrorStyle.Render(a.tr("rbac.failed", err))
+	}
+
+	var shown []kubectl.RBACGrant
+	for _, grant := range grants {
+		if all || !grant.System() {
+			shown = append(shown, grant)
+		}
+	}
+	info := a.tr("rbac.title", session.cluster.Name, len(shown)) + "\n"
+	if hidden := len(grants) - len(shown); hidden > 0 {
+		info += styles.InfoStyle.Render("  "+a.tr("rbac.system_hidden", hidden)) + "\n"
+	}
+	if len(shown) == 0 {
+		return info + "\n" + styles.InfoStyle.Render(a.tr("rbac.empty"))
+	}
+
+	subject := ""
+	for _, grant := range shown {
+		if grant.Subject.String() != subject {
+			subject = grant.Subject.String()
+			info += "\n  " + subject + "\n"
+		}
+		line := fmt.Sprintf("    %-20s %-40s %s", grant.Scope(), grant.Role, formatRules(grant.Rules))
+		if grant.ClusterAdmin() || grant.Wildcard() {
+			line = styles.ErrorStyle.Render("  ⚠️" + line[2:])
+		}
+		info += line + "\n"
+	}
+	return info + "\n" + styles.InfoStyle.Render(a.tr("rbac.hint"))
+}
+
+// rbacDiff shows how the last commit that changed the RBAC exports of a
+// session's cluster changed its grants
+func (a *Application) rbacDiff(session clusterSession) string {
+	if session.gitManager == nil {
+		return styles.InfoStyle.Render(a.tr("git.not_configured"))
+	}
+	change, err := session.gitManager.LastRBACChange()
+	if err != nil {
+		return styles.ErrorStyle.Render(a.tr("rbac.failed", err))
+	}
+	if change == nil {
+		return styles.InfoStyle.Render(a.tr("rbac.no_changes"))
+	}
+
+	info := a.tr("rbac.diff_title", change.Commit) + "\n"
+	if change.Diff.Empty() {
+		return info + "\n" + styles.InfoStyle.Render(a.tr("rbac.diff_empty"))
+	}
+	for _, section := range []struct {
+		marker string
+		grants []kubectl.RBACGrant
+	}{
+		{"+", change.Diff.Added},
+		{"~", change.Diff.Changed},
+		{"-", change.Diff.Removed},
+	} {
+		for _, grant := range section.grants {
+			info += fmt.Sprintf("  %s %-40s %-20s %-40s %s\n",
+				section.marker, grant.Subject, grant.Scope(), grant.Role, formatRules(grant.Rules))
+		}
+	}
+	return info + a.formatRBACWarnings(change.Diff.Warnings())
+}
+
+// rbacSyncWarnings returns the security warnings of the RBAC change a sync
+// committed, given the clone's HEAD before the sync, or "" if the sync
+// didn't change RBAC
+func (a *Application) rbacSyncWarnings(gm *git.Manager, before string) string {
+	head := gm.Head()
+	if head == before {
+		return ""
+	}
+	change, err := gm.LastRBACChange()
+	if err != nil || change == nil || change.Hash != head {
+		return ""
+	}
+	return a.formatRBACWarnings(change.Diff.Warnings())
+}
+
+// formatRBACWarnings renders the warnings of an RBAC change
+func (a *Application) formatRBACWarnings(warnings []string) string {
+	if len(warnings) == 0 {
+		return ""
+	}
+	info := "\n" + styles.ErrorStyle.Render(a.tr("rbac.warnings", len(warnings)))
+	for _, warning := range warnings {
+		info += "\n" + styles.ErrorStyle.Render("  • "+warning)
+	}
+	return info
+}
+
+// formatRules summarizes the rules of a role for a single line
+func formatRules(rules []kubectl.RBACRule) string {
+	var shown []string
+	for i, rule := range rules {
+		if i == rbacRulesShown {
+			shown = append(shown, fmt.Sprintf("+%d more", len(rules)-i))
+			break
+		}
+		shown = append(shown, rule.String())
+	}
+	return strings.Join(shown, "; ")
+}
@@ -0,0 +1,294 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// rolloutPollInterval is how often a watched rollout is checked
+const rolloutPollInterval = 2 * time.Second
+
+// Messages for the rollout view
+type rolloutStatusMsg struct {
+	generation int
+	status     kubectl.RolloutStatus
+	err        error
+}
+type rolloutTickMsg struct{ generation int }
+type rolloutHistoryMsg struct {
+	generation int
+	history    string
+	err        error
+}
+type rolloutActionMsg struct {
+	generation int
+	result     tea.Msg
+}
+
+// rolloutWatch follows a rollout status, restart or undo until it completes or fails
+type rolloutWatch struct {
+	session    clusterSession
+	object     kubectl.ObjectRef
+	action     string
+	status     *kubectl.RolloutStatus
+	history    string
+	notice     string
+	err        error
+	started    time.Time
+	running    bool // the restart or undo command hasn't finished yet
+	done       bool
+	generation int
+}
+
+// parseRolloutCommand recognizes the rollout commands that open the rollout view
+func parseRolloutCommand(command string) (string, kubectl.ObjectRef, bool) {
+	parts := strings.Fields(command)
+	if len(parts) < 3 || parts[0] != "rollout" {
+		return "", kubectl.ObjectRef{}, false
+	}
+	switch parts[1] {
+	case "status", "restart", "undo":
+	default:
+		return "", kubectl.ObjectRef{}, false
+	}
+	object, ok := kubectl.ParseObjectRef(parts[2:])
+	if !ok || !kubectl.IsRolloutKind(object.Kind) {
+		return "", kubectl.ObjectRef{}, false
+	}
+	return parts[1], object, true
+}
+
+// startRollout opens the rollout view, running the restart or undo command first
+func (a *Application) startRollout(action string, object kubectl.ObjectRef, command string) (tea.Model, tea.Cmd) {
+	a.rollout = rolloutWatch{
+		session:    a.currentSession(),
+		object:     object,
+		action:     action,
+		started:    time.Now(),
+		generation: a.rollout.generation + 1,
+	}
+	a.state = rolloutView
+
+	switch action {
+	case "status":
+		return a, tea.Batch(a.pollRollout(), a.loadRolloutHistory())
+	case actionScale:
+		// Scaling doesn't create a revision
+		return a, a.runRolloutCommand(command)
+	}
+	return a, tea.Batch(a.runRolloutCommand(command), a.loadRolloutHistory())
+}
+
+// runRolloutCommand runs restart or undo through the command queue so it's audited and synced
+func (a *Application) runRolloutCommand(command string) tea.Cmd {
+	w := &a.rollout
+	w.running = true
+	session, generation := w.session, w.generation
+
+	return tea.Batch(a.startCommand(session), a.queueCommand(session, command, func() tea.Msg {
+		return rolloutActionMsg{generation: generation, result: a.runKubectlCommand(session, command, false)}
+	}))
+}
+
+// pollRollout checks the rollout progress in the background
+func (a *Application) pollRollout() tea.Cmd {
+	session, object, generation := a.rollout.session, a.rollout.object, a.rollout.generation
+	return func() tea.Msg {
+		status, err := session.executor.RolloutStatus(object)
+		return rolloutStatusMsg{generation: generation, status: status, err: err}
+	}
+}
+
+// loadRolloutHistory fetches the revision history in the background
+func (a *Application) loadRolloutHistory() tea.Cmd {
+	session, object, generation := a.rollout.session, a.rollout.object, a.rollout.generation
+	return func() tea.Msg {
+		history, err := session.executor.RolloutHistory(object)
+		return rolloutHistoryMsg{generation: generation, history: history, err: err}
+	}
+}
+
+// handleRolloutAction starts watching once the restart or undo command has run
+func (a *Application) handleRolloutAction(msg rolloutActionMsg) (tea.Model, tea.Cmd) {
+	w := &a.rollout
+	if msg.generation != w.generation {
+		// The view was closed; the result still belongs in the terminal
+		if result, ok := msg.result.(commandExecutedMsg); ok {
+			a.output += strings.TrimRight(result.output, "\n") + "\n"
+		}
+		return a, nil
+	}
+	w.running = false
+
+	switch result := msg.result.(type) {
+	case commandExecutedMsg:
+		a.output += strings.TrimRight(result.output, "\n") + "\n"
+		w.notice = strings.TrimSpace(result.output)
+		return a, a.pollRollout()
+	case errorMsg:
+		w.err = result.err
+		w.done = true
+	case confirmationRequiredMsg:
+		a.pendingCommand = result.command
+		w.err = errors.New(a.tr("rollout.confirm", result.err))
+		w.done = true
+	}
+	return a, nil
+}
+
+// handleRolloutStatus records progress and keeps polling until the rollout ends
+func (a *Application) handleRolloutStatus(msg rolloutStatusMsg) (tea.Model, tea.Cmd) {
+	w := &a.rollout
+	if msg.generation != w.generation || w.done {
+		return a, nil
+	}
+	if msg.err != nil {
+		w.err = msg.err
+		w.done = true
+		return a, nil
+	}
+
+	w.err = nil
+	w.status = &msg.status
+	switch {
+	case msg.status.Complete():
+		w.done = true
+		if w.action == actionScale {
+			return a, nil
+		}
+		return a, a.loadRolloutHistory()
+	case msg.status.Failed:
+		w.done = true
+		return a, nil
+	}
+
+	generation := w.generation
+	return a, tea.Tick(rolloutPollInterval, func(time.Time) tea.Msg {
+		return rolloutTickMsg{generation: generation}
+	})
+}
+
+// handleRolloutTick polls again while the view is open
+func (a *Application) handleRolloutTick(msg rolloutTickMsg) (tea.Model, tea.Cmd) {
+	if msg.generation != a.rollout.generation || a.state != rolloutView {
+		return a, nil
+	}
+	return a, a.pollRollout()
+}
+
+// handleRolloutHistory shows the revision history
+func (a *Application) handleRolloutHistory(msg rolloutHistoryMsg) (tea.Model, tea.Cmd) {
+	if msg.generation != a.rollout.generation {
+		return a, nil
+	}
+	if msg.err != nil {
+		a.rollout.history = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", msg.err))
+	} else {
+		a.rollout.history = strings.TrimRight(msg.history, "\n")
+	}
+	return a, nil
+}
+
+// updateRollout handles rollout view updates
+func (a *Application) updateRollout(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	w := &a.rollout
+	switch msg.String() {
+	case "esc":
+		// Stop polling
+		w.generation++
+		a.state = terminalView
+		a.updateTerminalOutput()
+	case "ctrl+c":
+		return a, tea.Quit
+	case "h":
+		if w.action == actionScale {
+			return a, nil
+		}
+		return a, a.loadRolloutHistory()
+	case "u":
+		if w.running || !w.done || w.action == actionScale {
+			return a, nil
+		}
+		// Roll back to the previous revision and watch that instead
+		command := "rollout undo " + strings.Join(w.object.Args(), " ")
+		a.output += fmt.Sprintf("%s %s\n", styles.PromptStyle.Render(fmt.Sprintf("[%s]$", w.session.cluster.Name)), command)
+		return a.startRollout("undo", w.object, command)
+	}
+	return a, nil
+}
+
+// renderRollout renders the rollout view
+func (a *Application) renderRollout() string {
+	w := &a.rollout
+	title := a.tr("rollout.title", w.action, w.object)
+	if w.action == actionScale {
+		title = a.tr("rollout.scale_title", w.object)
+	}
+
+	body := ""
+	switch {
+	case w.running:
+		body += fmt.Sprintf("%s %s\n", a.spinner.View(), styles.LoadingStyle.Render(a.tr("rollout.running", w.action)))
+	case w.status == nil && w.err == nil:
+		body += styles.LoadingStyle.Render(a.tr("rollout.checking")) + "\n"
+	}
+
+	if s := w.status; s != nil {
+		body += renderProgressBar(s.Progress(), 40) + "\n"
+		body += a.tr("rollout.replicas", s.Updated, s.Desired, s.Ready, s.Desired, s.Available, s.Desired)
+		if s.Old > 0 {
+			body += " • " + a.tr("rollout.old_replicas", s.Old)
+		}
+		body += "\n"
+
+		elapsed := time.Since(w.started).Round(time.Second)
+		switch {
+		case s.Complete() && w.action == actionScale:
+			body += styles.SuccessStyle.Render(a.tr("rollout.scaled", s.Ready, elapsed)) + "\n"
+		case s.Complete():
+			body += styles.SuccessStyle.Render(a.tr("rollout.complete", elapsed)) + "\n"
+		case s.Failed && w.action == actionScale:
+			body += styles.ErrorStyle.Render(a.tr("rollout.scale_stalled", elapsed, s.Message)) + "\n"
+		case s.Failed:
+			body += styles.ErrorStyle.Render(a.tr("rollout.failed", elapsed, s.Message)) + "\n"
+			body += styles.ErrorStyle.Render(a.tr("rollout.undo_hint")) + "\n"
+		default:
+			body += styles.InfoStyle.Render(a.tr("rollout.in_progress", elapsed)) + "\n"
+		}
+	}
+	if w.err != nil {
+		body += styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", w.err)) + "\n"
+	}
+	if w.notice != "" {
+		body += styles.InfoStyle.Render(w.notice) + "\n"
+	}
+	if w.history != "" {
+		body += "\n" + styles.HeaderStyle.Render(a.tr("rollout.history")) + "\n" + w.history + "\n"
+	}
+
+	footer := a.tr("rollout.footer")
+	if w.action == actionScale {
+		footer = a.tr("rollout.scale_footer")
+	} else if w.done {
+		footer = a.tr("rollout.done_footer")
+	}
+	return fmt.Sprintf("\n%s\n\n%s\n%s",
+		styles.TitleStyle.Render(title),
+		body,
+		styles.InfoStyle.Render(footer))
+}
+
+// renderProgressBar renders a fraction as a bar of the given width with a percentage
+func renderProgressBar(fraction float64, width int) string {
+	fraction = max(0, min(1, fraction))
+	filled := int(fraction * float64(width))
+	return styles.SuccessStyle.Render(strings.Repeat("█", filled)) +
+		styles.InfoStyle.Render(strings.Repeat("░", width-filled)) +
+		fmt.Sprintf(" %3.0f%%", fraction*100)
+}
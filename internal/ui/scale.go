@@ -0,0 +1,197 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+)
+
+// actionScale is the rollout view action for watching a scale operation
+const actionScale = "scale"
+
+// scaleListMsg carries the deployments to pick from
+type scaleListMsg struct {
+	deployments []kubectl.DeploymentReplicas
+	err         error
+}
+
+// scalePicker picks a deployment and its new replica count
+type scalePicker struct {
+	session     clusterSession
+	flags       []string
+	deployments []kubectl.DeploymentReplicas
+	cursor      int
+	loading     bool
+	err         error
+	// Set once a deployment is picked
+	selected bool
+	replicas int
+	input    string // digits typed since the deployment was picked
+	confirm  bool
+}
+
+// hasPositionalArgs reports whether arguments contain anything besides flags
+// and their values
+func hasPositionalArgs(args []string) bool {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-n" || args[i] == "--namespace":
+			i++
+		case !strings.HasPrefix(args[i], "-"):
+			return true
+		}
+	}
+	return false
+}
+
+// openScalePicker lists the deployments selected by the namespace flags of the scale built-in
+func (a *Application) openScalePicker(flags []string) (tea.Model, tea.Cmd) {
+	a.scale = scalePicker{session: a.currentSession(), flags: flags, loading: true}
+	a.state = scaleView
+	return a, a.loadScaleList()
+}
+
+// loadScaleList fetches the deployments in the background
+func (a *Application) loadScaleList() tea.Cmd {
+	session, flags := a.scale.session, a.scale.flags
+	return func() tea.Msg {
+		deployments, err := session.executor.ListDeployments(flags...)
+		return scaleListMsg{deployments: deployments, err: err}
+	}
+}
+
+// handleScaleList shows the loaded deployments
+func (a *Application) handleScaleList(msg scaleListMsg) (tea.Model, tea.Cmd) {
+	p := &a.scale
+	p.loading = false
+	p.err = msg.err
+	p.deployments = msg.deployments
+	if p.cursor >= len(p.deployments) {
+		p.cursor = 0
+	}
+	return a, nil
+}
+
+// updateScale handles scale picker updates
+func (a *Application) updateScale(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := &a.scale
+	key := msg.String()
+
+	if p.confirm {
+		p.confirm = false
+		if key != "y" {
+			return a, nil
+		}
+		deployment := p.deployments[p.cursor]
+		command := fmt.Sprintf("scale %s --replicas=%d", strings.Join(deployment.Args(), " "), p.replicas)
+		a.output += fmt.Sprintf("%s %s\n", styles.PromptStyle.Render(fmt.Sprintf("[%s]$", p.session.cluster.Name)), command)
+		return a.startRollout(actionScale, deployment.ObjectRef, command)
+	}
+
+	switch key {
+	case "esc":
+		if p.selected {
+			p.selected = false
+			return a, nil
+		}
+		a.state = terminalView
+		a.updateTerminalOutput()
+		return a, nil
+	case "ctrl+c":
+		return a, tea.Quit
+	}
+
+	if !p.selected {
+		switch key {
+		case "up", "k":
+			if p.cursor > 0 {
+				p.cursor--
+			}
+		case "down", "j":
+			if p.cursor < len(p.deployments)-1 {
+				p.cursor++
+			}
+		case "r":
+			p.loading = true
+			return a, a.loadScaleList()
+		case "enter":
+			if len(p.deployments) > 0 {
+				p.selected = true
+				p.replicas = p.deployments[p.cursor].Desired
+				p.input = ""
+			}
+		}
+		return a, nil
+	}
+
+	switch key {
+	case "+", "=", "up", "right":
+		p.replicas++
+		p.input = ""
+	case "-", "down", "left":
+		if p.replicas > 0 {
+			p.replicas--
+		}
+		p.input = ""
+	case "backspace":
+		if p.input != "" {
+			p.input = p.input[:len(p.input)-1]
+			p.replicas, _ = strconv.Atoi(p.input)
+		}
+	case "enter":
+		if p.replicas != p.deployments[p.cursor].Desired {
+			p.confirm = true
+		}
+	default:
+		// Typed digits replace the count
+		if len(key) == 1 && key[0] >= '0' && key[0] <= '9' && len(p.input) < 4 {
+			p.input += key
+			p.replicas, _ = strconv.Atoi(p.input)
+		}
+	}
+	return a, nil
+}
+
+// renderScale renders the scale picker
+func (a *Application) renderScale() string {
+	p := &a.scale
+
+	body := ""
+	switch {
+	case p.loading:
+		body = styles.LoadingStyle.Render(a.tr("scale.loading")) + "\n"
+	case p.err != nil:
+		body = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", p.err)) + "\n"
+	case len(p.deployments) == 0:
+		body = styles.InfoStyle.Render(a.tr("scale.empty")) + "\n"
+	case !p.selected:
+		for i, deployment := range p.deployments {
+			line := fmt.Sprintf("%-48s %s", deployment.Namespace+"/"+deployment.Name, a.tr("scale.ready", deployment.Ready, deployment.Desired))
+			if i == p.cursor {
+				line = styles.SelectedStyle.Render(line)
+			}
+			body += line + "\n"
+		}
+	default:
+		deployment := p.deployments[p.cursor]
+		body = fmt.Sprintf("%s\n\n%s\n",
+			styles.HeaderStyle.Render(deployment.Namespace+"/"+deployment.Name),
+			a.tr("scale.replicas", deployment.Desired, deployment.Ready, styles.SelectedStyle.Render(strconv.Itoa(p.replicas))))
+		if p.confirm {
+			body += "\n" + styles.ErrorStyle.Render(a.tr("scale.confirm", deployment.Name, deployment.Desired, p.replicas)) + "\n"
+		}
+	}
+
+	footer := a.tr("scale.footer")
+	if p.selected {
+		footer = a.tr("scale.adjust_footer")
+	}
+	return fmt.Sprintf("\n%s\n\n%s\n%s",
+		styles.TitleStyle.Render(a.tr("scale.title")),
+		body,
+		styles.InfoStyle.Render(footer))
+}
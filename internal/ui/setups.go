@@ -0,0 +1,848 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/setup"
+	"github.com/RaymondAkachi/custom-kub-cli/k8s/clustersetup"
+)
+
+// Actions that can be run against a managed cluster config
+const (
+	actionSetup   = "setup"
+	actionResume  = "resume"
+	actionDestroy = "destroy"
+	actionStatus  = "status"
+	actionBake    = "bake"
+	actionRunbook = "runbook"
+)
+
+// setupLogLines is how many log lines the operation view keeps
+const setupLogLines = 12
+
+// Messages for managed config operations
+type setupEventMsg struct {
+	phase string
+	step  *clustersetup.ProgressUpdate
+	log   string
+}
+type setupDoneMsg struct {
+	output     string
+	kubeconfig string // remote admin kubeconfig written by a successful setup
+	endpoint   string
+	err        error
+}
+
+// configForm edits a managed config one field at a time
+type configForm struct {
+	config setup.ManagedConfig
+	field  int
+	naming bool // asking for the name of a new config
+	err    string
+}
+
+// setupOperation is a setup, destroy, status or bake run against a managed config
+type setupOperation struct {
+	action  string
+	config  string
+	cluster string
+	target  string // node to bake
+	phase   string
+	// kubeconfig is the remote admin kubeconfig a successful setup wrote and
+	// endpoint the controller address it targets
+	kubeconfig string
+	endpoint   string
+	step       *clustersetup.ProgressUpdate
+	nodes      []string
+	steps      map[string]clustersetup.ProgressUpdate
+	logs       []string
+	output     string
+	err        error
+	done       bool
+	started    time.Time
+	events     chan tea.Msg
+	cancel     context.CancelFunc
+}
+
+// running reports whether an operation is still in progress
+func (op *setupOperation) running() bool {
+	return op != nil && !op.done
+}
+
+// tuiProgressReporter forwards clustersetup progress to the operation view
+type tuiProgressReporter struct {
+	events chan<- tea.Msg
+	tr     func(id string, args ...interface{}) string
+}
+
+// send delivers a message without blocking setup when the UI falls behind
+func (p *tuiProgressReporter) send(msg tea.Msg) {
+	select {
+	case p.events <- msg:
+	default:
+	}
+}
+
+func (p *tuiProgressReporter) Start(total int, description string) {
+	p.send(setupEventMsg{phase: description})
+}
+func (p *tuiProgressReporter) Update(current int, status string)   {}
+func (p *tuiProgressReporter) Finish(success bool, message string) {}
+func (p *tuiProgressReporter) ReportProgress(step, totalSteps int, phase string) {
+	p.send(setupEventMsg{phase: p.tr("setups.step", step, totalSteps, phase)})
+}
+func (p *tuiProgressReporter) ReportStep(update clustersetup.ProgressUpdate) {
+	p.send(setupEventMsg{step: &update})
+}
+
+// openClusterConfigs shows the managed cluster config list
+func (a *Application) openClusterConfigs() (tea.Model, tea.Cmd) {
+	a.refreshConfigList()
+	a.state = clusterConfigsView
+	return a, nil
+}
+
+// refreshConfigList reloads the managed configs from disk
+func (a *Application) refreshConfigList() {
+	configs, err := a.setupStore.List()
+	if err != nil {
+		a.configsNotice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+	}
+
+	var items []list.Item
+	for _, managed := range configs {
+		items = append(items, &configItem{catalog: a.catalog, config: managed, status: setup.SetupStatus(managed)})
+	}
+	items = append(items, &newConfigItem{catalog: a.catalog})
+	a.configList.SetItems(items)
+}
+
+// selectedConfig returns the managed config under the cursor, if any
+func (a *Application) selectedConfig() (setup.ManagedConfig, bool) {
+	item, ok := a.configList.SelectedItem().(*configItem)
+	if !ok {
+		return setup.ManagedConfig{}, false
+	}
+	return item.config, true
+}
+
+// updateClusterConfigs handles managed config list updates
+func (a *Application) updateClusterConfigs(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+	switch key {
+	case "esc":
+		a.state = clusterSelectionView
+		a.configsNotice = ""
+		return a, nil
+	case "ctrl+c":
+		return a, tea.Quit
+	case "n":
+		return a.startNewConfig()
+	case "enter", "e":
+		if managed, ok := a.selectedConfig(); ok {
+			return a.startConfigForm(managed)
+		}
+		if _, ok := a.configList.SelectedItem().(*newConfigItem); ok {
+			return a.startNewConfig()
+		}
+		return a, nil
+	case "v":
+		if managed, ok := a.selectedConfig(); ok {
+			if _, err := setup.Validate(managed); err != nil {
+				a.configsNotice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %s: %v", managed.Name, err))
+			} else if err := a.setupStore.CheckUnique(managed); err != nil {
+				a.configsNotice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+			} else {
+				a.configsNotice = styles.SuccessStyle.Render(a.tr("setups.valid", managed.Name))
+			}
+		}
+		return a, nil
+	case "s", "r", "t", "d", "b", "x":
+		managed, ok := a.selectedConfig()
+		if !ok {
+			return a, nil
+		}
+		if a.operation.running() {
+			a.configsNotice = styles.ErrorStyle.Render(a.tr("setups.still_running", a.actionName(a.operation.action), a.operation.config))
+			return a, nil
+		}
+		if key != "t" && key != "x" && a.setupReadOnly(managed) {
+			a.configsNotice = styles.ErrorStyle.Render(a.tr("setups.read_only", managed.Config.ClusterName))
+			return a, nil
+		}
+		if key == "s" || key == "r" {
+			if err := a.setupStore.CheckUnique(managed); err != nil {
+				a.configsNotice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+				return a, nil
+			}
+		}
+		switch key {
+		case "s":
+			return a.startOperation(actionSetup, managed, "")
+		case "r":
+			return a.startOperation(actionResume, managed, "")
+		case "t":
+			return a.startOperation(actionStatus, managed, "")
+		case "x":
+			return a.startOperation(actionRunbook, managed, "")
+		case "b":
+			return a.startBakeNode(managed)
+		default:
+			return a.startDestroyConfirm(managed)
+		}
+	case "h":
+		if managed, ok := a.selectedConfig(); ok {
+			return a.openDashboard(managed)
+		}
+		return a, nil
+	case "o":
+		if a.operation != nil {
+			a.state = setupRunView
+		}
+		return a, nil
+	}
+
+	var cmd tea.Cmd
+	a.configList, cmd = a.configList.Update(msg)
+	return a, cmd
+}
+
+// setupReadOnly reports whether setups and destroys of a managed config are
+// blocked, by --read-only or the settings of its registered cluster
+func (a *Application) setupReadOnly(managed setup.ManagedConfig) bool {
+	cluster, _ := a.config.GetCluster(managed.Config.ClusterName) // nil when not registered
+	return a.clusterReadOnly(cluster)
+}
+
+// startNewConfig asks for the name of a config to create from the default template
+func (a *Application) startNewConfig() (tea.Model, tea.Cmd) {
+	a.configForm = configForm{naming: true}
+	a.textInput.SetValue("")
+	a.textInput.Placeholder = a.tr("setups.name_placeholder")
+	a.state = configFormView
+	return a, nil
+}
+
+// startConfigForm starts editing a managed config
+func (a *Application) startConfigForm(managed setup.ManagedConfig) (tea.Model, tea.Cmd) {
+	if managed.ParseErr != nil {
+		a.configsNotice = styles.ErrorStyle.Render(a.tr("setups.parse_failed", managed.ParseErr, managed.Path))
+		return a, nil
+	}
+	a.configForm = configForm{config: managed}
+	a.loadFormField()
+	a.state = configFormView
+	return a, nil
+}
+
+// loadFormField puts the current field's value in the text input
+func (a *Application) loadFormField() {
+	field := setup.Fields[a.configForm.field]
+	a.textInput.SetValue(field.Get(&a.configForm.config.Config))
+	a.textInput.Placeholder = field.Label
+	a.textInput.CursorEnd()
+}
+
+// updateConfigForm handles config form updates
+func (a *Application) updateConfigForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		a.configsNotice = styles.InfoStyle.Render(a.tr("setups.edit_canceled"))
+		return a.openClusterConfigs()
+	case "ctrl+c":
+		return a, tea.Quit
+	case "shift+tab", "up":
+		if !a.configForm.naming && a.configForm.field > 0 {
+			a.configForm.field--
+			a.configForm.err = ""
+			a.loadFormField()
+		}
+		return a, nil
+	case "enter":
+		return a.submitFormField()
+	}
+
+	var cmd tea.Cmd
+	a.textInput, cmd = a.textInput.Update(msg)
+	return a, cmd
+}
+
+// submitFormField applies the current field and moves on, saving after the last one
+func (a *Application) submitFormField() (tea.Model, tea.Cmd) {
+	value := strings.TrimSpace(a.textInput.Value())
+
+	if a.configForm.naming {
+		managed, err := a.setupStore.Create(value)
+		if err != nil {
+			a.configForm.err = err.Error()
+			return a, nil
+		}
+		return a.startConfigForm(managed)
+	}
+
+	field := setup.Fields[a.configForm.field]
+	if err := field.Set(&a.configForm.config.Config, value); err != nil {
+		a.configForm.err = err.Error()
+		return a, nil
+	}
+	a.configForm.err = ""
+
+	if a.configForm.field < len(setup.Fields)-1 {
+		a.configForm.field++
+		a.loadFormField()
+		return a, nil
+	}
+
+	managed := a.configForm.config
+	if err := a.setupStore.Save(managed); err != nil {
+		a.configsNotice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+	} else if _, err := setup.Validate(managed); err != nil {
+		a.configsNotice = styles.ErrorStyle.Render(a.tr("setups.saved_invalid", managed.Name, err))
+	} else {
+		a.configsNotice = styles.SuccessStyle.Render(a.tr("setups.saved", managed.Name))
+	}
+	return a.openClusterConfigs()
+}
+
+// startDestroyConfirm asks the user to type the cluster name before destroying it
+func (a *Application) startDestroyConfirm(managed setup.ManagedConfig) (tea.Model, tea.Cmd) {
+	if managed.ParseErr != nil {
+		a.configsNotice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", managed.ParseErr))
+		return a, nil
+	}
+	a.destroyTarget = managed
+	a.configForm.err = ""
+	a.textInput.SetValue("")
+	a.textInput.Placeholder = managed.Config.ClusterName
+	a.state = destroyConfirmView
+	return a, nil
+}
+
+// updateDestroyConfirm handles the destroy confirmation dialog
+func (a *Application) updateDestroyConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		a.configsNotice = styles.InfoStyle.Render(a.tr("setups.canceled", a.actionName(actionDestroy)))
+		return a.openClusterConfigs()
+	case "ctrl+c":
+		return a, tea.Quit
+	case "enter":
+		if strings.TrimSpace(a.textInput.Value()) != a.destroyTarget.Config.ClusterName {
+			a.configForm.err = a.tr("setups.name_mismatch")
+			return a, nil
+		}
+		return a.startOperation(actionDestroy, a.destroyTarget, "")
+	}
+
+	var cmd tea.Cmd
+	a.textInput, cmd = a.textInput.Update(msg)
+	return a, cmd
+}
+
+// startBakeNode asks which node to prepare for a machine image
+func (a *Application) startBakeNode(managed setup.ManagedConfig) (tea.Model, tea.Cmd) {
+	if managed.ParseErr != nil {
+		a.configsNotice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", managed.ParseErr))
+		return a, nil
+	}
+	a.bakeTarget = managed
+	a.configForm.err = ""
+	a.textInput.SetValue("")
+	a.textInput.Placeholder = a.tr("setups.bake_placeholder")
+	a.state = bakeNodeView
+	return a, nil
+}
+
+// updateBakeNode handles the bake node prompt
+func (a *Application) updateBakeNode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		a.configsNotice = styles.InfoStyle.Render(a.tr("setups.canceled", a.actionName(actionBake)))
+		return a.openClusterConfigs()
+	case "ctrl+c":
+		return a, tea.Quit
+	case "enter":
+		target := strings.TrimSpace(a.textInput.Value())
+		if _, _, err := clustersetup.ResolveBakeTarget(a.bakeTarget.Config, target); err != nil {
+			a.configForm.err = err.Error()
+			return a, nil
+		}
+		return a.startOperation(actionBake, a.bakeTarget, target)
+	}
+
+	var cmd tea.Cmd
+	a.textInput, cmd = a.textInput.Update(msg)
+	return a, cmd
+}
+
+// passphrasePrompt asks for the passphrase of a config's SSH key before
+// running an operation that connects to the nodes
+type passphrasePrompt struct {
+	action  string
+	config  setup.ManagedConfig
+	target  string
+	keyPath string
+}
+
+// protectedKey returns the SSH key of a config that is protected by a
+// passphrase nothing else supplies, or "" when the operation can run
+func (a *Application) protectedKey(action string, managed setup.ManagedConfig) string {
+	c := managed.Config
+	// Runbooks never connect, and an agent may hold the decrypted key
+	if action == actionRunbook || c.SSHKeyPassphrase != "" || c.Vault.SSHKeyPassphrase.IsSet() ||
+		a.passphrases[managed.Name] != "" || os.Getenv("SSH_AUTH_SOCK") != "" {
+		return ""
+	}
+	for _, keyPath := range []string{c.SSHKey, c.BastionKey} {
+		if keyPath != "" && clustersetup.KeyNeedsPassphrase(keyPath) {
+			return keyPath
+		}
+	}
+	return ""
+}
+
+// updatePassphrase handles the SSH key passphrase prompt
+func (a *Application) updatePassphrase(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := &a.passphrase
+	switch msg.String() {
+	case "esc":
+		a.textInput.EchoMode = textinput.EchoNormal
+		a.configsNotice = styles.InfoStyle.Render(a.tr("setups.canceled", a.actionName(p.action)))
+		return a.openClusterConfigs()
+	case "ctrl+c":
+		return a, tea.Quit
+	case "enter":
+		passphrase := a.textInput.Value()
+		if err := clustersetup.CheckKeyPassphrase(p.keyPath, passphrase); err != nil {
+			a.configForm.err = err.Error()
+			a.textInput.SetValue("")
+			return a, nil
+		}
+		a.textInput.EchoMode = textinput.EchoNormal
+		a.textInput.SetValue("")
+		if a.passphrases == nil {
+			a.passphrases = make(map[string]string)
+		}
+		a.passphrases[p.config.Name] = passphrase
+		return a.startOperation(p.action, p.config, p.target)
+	}
+
+	var cmd tea.Cmd
+	a.textInput, cmd = a.textInput.Update(msg)
+	return a, cmd
+}
+
+// startOperation runs setup, destroy, status or bake for a managed config in
+// the background. target is the node to bake. A protected SSH key is
+// unlocked first.
+func (a *Application) startOperation(action string, managed setup.ManagedConfig, target string) (tea.Model, tea.Cmd) {
+	if keyPath := a.protectedKey(action, managed); keyPath != "" {
+		a.passphrase = passphrasePrompt{action: action, config: managed, target: target, keyPath: keyPath}
+		a.configForm.err = ""
+		a.textInput.SetValue("")
+		a.textInput.Placeholder = a.tr("setups.passphrase_placeholder")
+		a.textInput.EchoMode = textinput.EchoPassword
+		a.state = passphraseView
+		return a, nil
+	}
+	managed.Passphrase = a.passphrases[managed.Name]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	op := &setupOperation{
+		action:  action,
+		config:  managed.Name,
+		cluster: managed.Config.ClusterName,
+		target:  target,
+		phase:   a.tr("setups.connecting"),
+		started: time.Now(),
+		steps:   make(map[string]clustersetup.ProgressUpdate),
+		events:  make(chan tea.Msg, 64),
+		cancel:  cancel,
+	}
+	a.operation = op
+	a.configsNotice = ""
+	a.state = setupRunView
+
+	progress := &tuiProgressReporter{events: op.events, tr: a.tr}
+	logger := clustersetup.NewMultiLogger(
+		a.setupLogger(managed.Config.ClusterName),
+		clustersetup.NewFuncLogger(func(level clustersetup.LogLevel, msg string) {
+			progress.send(setupEventMsg{log: fmt.Sprintf("%s: %s", level, msg)})
+		}),
+	)
+
+	go func() {
+		defer recoverWorker(op.events)
+		defer cancel()
+		output, err := a.runOperation(ctx, action, managed, target, logger, progress)
+		done := setupDoneMsg{output: output, err: err}
+		if (action == actionSetup || action == actionResume) && err == nil {
+			if cfg, err := setup.Validate(managed); err == nil {
+				done.kubeconfig = clustersetup.RemoteAdminKubeconfigPath(cfg)
+				done.endpoint = cfg.Controller.PublicAddress
+				if done.endpoint == "" {
+					done.endpoint = cfg.ControlPlaneEndpoint
+				}
+				if done.endpoint == "" {
+					done.endpoint = cfg.Controller.IPAddress
+				}
+			}
+		}
+		op.events <- done
+	}()
+
+	cmds := []tea.Cmd{a.waitForSetupEvent(op)}
+	if !a.loading && a.inFlight == 0 && !a.dashboard.loading {
+		cmds = append(cmds, a.spinner.Tick)
+	}
+	return a, tea.Batch(cmds...)
+}
+
+// actionName returns the display name of an operation
+func (a *Application) actionName(action string) string {
+	return a.tr("setups.action_" + action)
+}
+
+// runOperation creates a cluster manager for the config and runs the action
+func (a *Application) runOperation(ctx context.Context, action string, managed setup.ManagedConfig, target string, logger clustersetup.Logger, progress clustersetup.ProgressReporter) (string, error) {
+	if action == actionRunbook {
+		path, err := setup.ExportRunbook(ctx, managed, logger, progress)
+		if err != nil {
+			return "", err
+		}
+		return a.tr("setups.runbook_written", path), nil
+	}
+
+	cm, err := setup.NewClusterManager(managed, logger, progress)
+	if err != nil {
+		return "", err
+	}
+	defer cm.Close()
+	cm.Events().Subscribe(a.auditLog.ClusterSetupHandler())
+	cm.Events().Subscribe(a.stats.ClusterSetupHandler())
+
+	switch action {
+	case actionSetup:
+		return "", cm.SetupCluster(ctx)
+	case actionResume:
+		return "", cm.ResumeSetup(ctx)
+	case actionDestroy:
+		return "", cm.DestroyCluster(ctx)
+	case actionStatus:
+		status, err := cm.GetClusterStatus(ctx)
+		output := a.tr("setups.status", status.Nodes, status.PodStatus, status.TestStatus)
+		return output, err
+	case actionBake:
+		node, role, err := clustersetup.ResolveBakeTarget(managed.Config, target)
+		if err != nil {
+			return "", err
+		}
+		if err := cm.BakeNode(ctx, node, role); err != nil {
+			return "", err
+		}
+		return a.tr("setups.baked", node.Name, node.IPAddress, role, role), nil
+	}
+	return "", fmt.Errorf("unknown action %s", action)
+}
+
+// waitForSetupEvent waits for the next progress, log or completion message of an operation
+func (a *Application) waitForSetupEvent(op *setupOperation) tea.Cmd {
+	return func() tea.Msg {
+		return <-op.events
+	}
+}
+
+// handleSetupEvent applies a progress or log message to the running operation
+func (a *Application) handleSetupEvent(msg setupEventMsg) (tea.Model, tea.Cmd) {
+	op := a.operation
+	if op == nil {
+		return a, nil
+	}
+	if msg.phase != "" {
+		op.phase = msg.phase
+	}
+	if msg.step != nil {
+		op.step = msg.step
+		if node := msg.step.Node; node != "" {
+			if _, ok := op.steps[node]; !ok {
+				op.nodes = append(op.nodes, node)
+			}
+			op.steps[node] = *msg.step
+		}
+	}
+	if msg.log != "" {
+		op.logs = append(op.logs, msg.log)
+		if len(op.logs) > setupLogLines {
+			op.logs = op.logs[len(op.logs)-setupLogLines:]
+		}
+	}
+	return a, a.waitForSetupEvent(op)
+}
+
+// handleSetupDone records the result of the operation
+func (a *Application) handleSetupDone(msg setupDoneMsg) (tea.Model, tea.Cmd) {
+	op := a.operation
+	if op == nil {
+		return a, nil
+	}
+	op.done = true
+	op.output = msg.output
+	op.err = msg.err
+	op.kubeconfig = msg.kubeconfig
+	op.endpoint = msg.endpoint
+
+	if op.action == actionDestroy && op.err == nil {
+		a.forgetCluster(op.cluster)
+	}
+	if a.shuttingDown {
+		return a, tea.Quit
+	}
+	return a, nil
+}
+
+// forgetCluster removes a destroyed cluster from the registry along with its
+// managed kubeconfig, if it was registered
+func (a *Application) forgetCluster(name string) {
+	if _, err := a.config.GetCluster(name); err != nil {
+		return
+	}
+	if err := a.config.RemoveCluster(name); err != nil {
+		a.operation.output += styles.ErrorStyle.Render(a.tr("setups.forget_failed", name, err)) + "\n"
+		return
+	}
+	if a.selectedCluster != nil && a.selectedCluster.Name == name {
+		a.selectedCluster = nil
+		a.kubectlExecutor = nil
+		a.closeGitManager()
+	}
+	delete(a.terminals, name)
+	if a.lastCluster == name {
+		a.lastCluster = ""
+	}
+	a.refreshClusterList()
+	a.operation.output += styles.SuccessStyle.Render(a.tr("setups.forgotten", name)) + "\n"
+}
+
+// updateSetupRun handles operation view updates
+func (a *Application) updateSetupRun(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		// The operation keeps running; 'o' in the config list reopens it
+		return a.openClusterConfigs()
+	case "c":
+		if a.operation.running() {
+			a.operation.cancel()
+			a.operation.phase = a.tr("setups.canceling")
+		}
+	case "i":
+		if a.operation.done && a.operation.kubeconfig != "" {
+			return a.importSetupKubeconfig()
+		}
+	case "ctrl+c":
+		return a, tea.Quit
+	}
+	return a, nil
+}
+
+// importSetupKubeconfig adds the cluster a setup created to the registry
+// using the remote admin kubeconfig, so it can be selected without copying
+// the kubeconfig by hand
+func (a *Application) importSetupKubeconfig() (tea.Model, tea.Cmd) {
+	op := a.operation
+	a.newCluster = config.ClusterInfo{Name: op.cluster, CreatedAt: time.Now()}
+	if net.ParseIP(op.endpoint) != nil {
+		a.newCluster.PublicIP = op.endpoint
+	} else {
+		a.newCluster.DNS = op.endpoint
+	}
+
+	a.loading = true
+	a.loadingMsg = a.tr("add.loading")
+	a.state = loadingView
+	path := op.kubeconfig
+	return a, func() tea.Msg {
+		if err := a.addCluster(path); err != nil {
+			return errorMsg{err: err}
+		}
+		return clusterAddedMsg{cluster: &a.newCluster}
+	}
+}
+
+// renderClusterConfigs renders the managed config list
+func (a *Application) renderClusterConfigs() string {
+	footer := a.tr("setups.footer")
+	if a.operation != nil {
+		footer = a.tr("setups.operation_footer")
+	}
+	return fmt.Sprintf("\n%s\n\n%s\n%s\n\n%s",
+		styles.TitleStyle.Render(a.tr("configs.title")),
+		a.configList.View(),
+		a.configsNotice,
+		styles.InfoStyle.Render(footer))
+}
+
+// renderConfigForm renders the config form
+func (a *Application) renderConfigForm() string {
+	title := a.tr("setups.new_title")
+	instructions := a.tr("setups.new_name", a.setupStore.Dir())
+	footer := a.tr("setups.new_footer")
+	if !a.configForm.naming {
+		title = a.tr("setups.form_title", a.configForm.config.Name, a.configForm.field+1, len(setup.Fields))
+		instructions = setup.Fields[a.configForm.field].Label + ":"
+		footer = a.tr("setups.form_footer")
+		if a.configForm.field == len(setup.Fields)-1 {
+			footer = a.tr("setups.form_save_footer")
+		}
+	}
+
+	errLine := ""
+	if a.configForm.err != "" {
+		errLine = styles.ErrorStyle.Render("❌ " + a.configForm.err)
+	}
+
+	return fmt.Sprintf("\n%s\n\n%s\n\n%s\n%s\n\n%s",
+		styles.TitleStyle.Render(title),
+		instructions,
+		a.textInput.View(),
+		errLine,
+		styles.InfoStyle.Render(footer))
+}
+
+// renderDestroyConfirm renders the destroy confirmation dialog
+func (a *Application) renderDestroyConfirm() string {
+	c := a.destroyTarget.Config
+	var nodes []string
+	for _, node := range c.Nodes() {
+		nodes = append(nodes, node.Name)
+	}
+
+	errLine := ""
+	if a.configForm.err != "" {
+		errLine = styles.ErrorStyle.Render("❌ " + a.configForm.err)
+	}
+
+	return fmt.Sprintf("\n%s\n\n%s\n\n%s\n\n%s\n%s\n\n%s",
+		styles.TitleStyle.Render(a.tr("setups.destroy_title", c.ClusterName)),
+		styles.ErrorStyle.Render(a.tr("setups.destroy_warning", strings.Join(nodes, ", "), c.WorkDir)),
+		a.tr("setups.destroy_confirm"),
+		a.textInput.View(),
+		errLine,
+		styles.InfoStyle.Render(a.tr("setups.destroy_footer")))
+}
+
+// renderBakeNode renders the bake node prompt
+func (a *Application) renderBakeNode() string {
+	c := a.bakeTarget.Config
+	errLine := ""
+	if a.configForm.err != "" {
+		errLine = styles.ErrorStyle.Render("❌ " + a.configForm.err)
+	}
+
+	return fmt.Sprintf("\n%s\n\n%s\n\n%s\n\n%s\n%s\n\n%s",
+		styles.TitleStyle.Render(a.tr("setups.bake_title", c.ClusterName)),
+		a.tr("setups.bake_info"),
+		a.tr("setups.bake_prompt"),
+		a.textInput.View(),
+		errLine,
+		styles.InfoStyle.Render(a.tr("setups.bake_footer")))
+}
+
+// renderPassphrase renders the SSH key passphrase prompt
+func (a *Application) renderPassphrase() string {
+	p := &a.passphrase
+	errLine := ""
+	if a.configForm.err != "" {
+		errLine = styles.ErrorStyle.Render("❌ " + a.configForm.err)
+	}
+
+	return fmt.Sprintf("\n%s\n\n%s\n\n%s\n%s\n\n%s",
+		styles.TitleStyle.Render(a.tr("setups.unlock_title", p.config.Config.ClusterName)),
+		a.tr("setups.unlock_info", p.keyPath),
+		a.textInput.View(),
+		errLine,
+		styles.InfoStyle.Render(a.tr("setups.unlock_footer", strings.ToLower(a.actionName(p.action)))))
+}
+
+// renderSetupRun renders the progress and result of a managed config operation
+func (a *Application) renderSetupRun() string {
+	op := a.operation
+	title := a.tr("setups.operation_title", a.actionName(op.action), op.config)
+
+	body := ""
+	if op.done {
+		elapsed := time.Since(op.started).Round(time.Second)
+		if op.err != nil {
+			body += styles.ErrorStyle.Render(a.tr("setups.operation_failed", a.actionName(op.action), elapsed, op.err)) + "\n"
+		} else {
+			body += styles.SuccessStyle.Render(a.tr("setups.operation_finished", a.actionName(op.action), elapsed)) + "\n"
+		}
+		if op.output != "" {
+			body += "\n" + op.output + "\n"
+		}
+		if op.kubeconfig != "" {
+			body += "\n" + styles.InfoStyle.Render(a.tr("setups.remote_kubeconfig", op.kubeconfig)) + "\n"
+		}
+	} else {
+		body += fmt.Sprintf("%s %s\n", a.spinner.View(), styles.LoadingStyle.Render(op.phase))
+		if step := op.step; step != nil {
+			node := step.Node
+			if node == "" {
+				node = a.tr("setups.local")
+			}
+			eta := a.tr("setups.estimating")
+			if step.ETA > 0 {
+				eta = step.ETA.Round(time.Second).String()
+			}
+			body += fmt.Sprintf("  [%s] %s (%d/%d)\n  %s\n",
+				node, step.Step, step.NodeStep, step.NodeSteps,
+				a.tr("setups.progress", step.Percent(), step.Elapsed.Round(time.Second), eta))
+		}
+	}
+
+	if len(op.nodes) > 0 {
+		body += "\n" + styles.HeaderStyle.Render(a.tr("setups.nodes")) + "\n"
+		for _, node := range op.nodes {
+			step := op.steps[node]
+			finished := step.NodeStep == step.NodeSteps && (op.done || op.step == nil || op.step.Node != node)
+			switch {
+			case finished && op.err == nil:
+				body += styles.SuccessStyle.Render(fmt.Sprintf("  ✅ %-16s %s", node, a.tr("setups.node_done"))) + "\n"
+			case op.done && op.err != nil && op.step != nil && op.step.Node == node:
+				body += styles.ErrorStyle.Render(fmt.Sprintf("  ❌ %-16s %s (%d/%d)", node, step.Step, step.NodeStep, step.NodeSteps)) + "\n"
+			default:
+				body += fmt.Sprintf("  ⏳ %-16s %s (%d/%d)\n", node, step.Step, step.NodeStep, step.NodeSteps)
+			}
+		}
+	}
+
+	if len(op.logs) > 0 {
+		body += "\n" + styles.HeaderStyle.Render(a.tr("setups.recent_logs")) + "\n"
+		for _, line := range op.logs {
+			body += styles.InfoStyle.Render("  "+line) + "\n"
+		}
+	}
+
+	footer := a.tr("setups.running_footer")
+	if a.shuttingDown {
+		footer = a.tr("setups.quitting_footer")
+	} else if op.done {
+		footer = a.tr("setups.done_footer")
+		if op.kubeconfig != "" {
+			footer = a.tr("setups.import_footer")
+		}
+	}
+	return fmt.Sprintf("\n%s\n\n%s\n%s",
+		styles.TitleStyle.Render(title),
+		body,
+		styles.InfoStyle.Render(footer))
+}
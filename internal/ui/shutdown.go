@@ -0,0 +1,22 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ShutdownMsg asks the application to exit, as on SIGINT or SIGTERM
+type ShutdownMsg struct{}
+
+// handleShutdown exits once nothing is left running. A running setup or
+// destroy is canceled and the application waits for it to stop its remote
+// command and log where it got to. Asking again exits right away.
+func (a *Application) handleShutdown() (tea.Model, tea.Cmd) {
+	if !a.operation.running() || a.shuttingDown {
+		return a, tea.Quit
+	}
+	a.shuttingDown = true
+	a.operation.cancel()
+	a.operation.phase = a.tr("setups.shutting_down")
+	a.state = setupRunView
+	return a, nil
+}
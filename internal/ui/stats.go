@@ -0,0 +1,156 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/stats"
+)
+
+// statsWindows are the time ranges the stats view can summarize
+var statsWindows = []struct {
+	label string        // message ID
+	span  time.Duration // zero for everything recorded
+}{
+	{"stats.day", 24 * time.Hour},
+	{"stats.week", 7 * 24 * time.Hour},
+	{"stats.month", 30 * 24 * time.Hour},
+	{"stats.all_time", 0},
+}
+
+// statsRows is how many rows each stats table shows
+const statsRows = 10
+
+// statsReport summarizes the local usage statistics
+type statsReport struct {
+	window  int
+	samples []stats.Sample
+	notice  string
+}
+
+// openStats shows the usage statistics of the last day
+func (a *Application) openStats() (tea.Model, tea.Cmd) {
+	a.statsReport = statsReport{}
+	a.loadStats()
+	a.state = statsView
+	return a, nil
+}
+
+// loadStats reads the samples of the selected time range
+func (a *Application) loadStats() {
+	r := &a.statsReport
+	var since time.Time
+	if span := statsWindows[r.window].span; span > 0 {
+		since = time.Now().Add(-span)
+	}
+	samples, err := a.stats.Load(since)
+	r.samples = samples
+	if err != nil {
+		r.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+	}
+}
+
+// toggleStats turns recording on or off and remembers the choice
+func (a *Application) toggleStats() {
+	r := &a.statsReport
+	preferences, err := a.config.LoadPreferences()
+	if err != nil {
+		r.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+		return
+	}
+	preferences.Stats = !a.stats.Enabled()
+	if err := a.config.SavePreferences(preferences); err != nil {
+		r.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+		return
+	}
+	a.stats.SetEnabled(preferences.Stats)
+	if preferences.Stats {
+		r.notice = styles.SuccessStyle.Render(a.tr("stats.recording_started"))
+	} else {
+		r.notice = styles.SuccessStyle.Render(a.tr("stats.recording_stopped"))
+	}
+}
+
+// updateStats handles stats view updates
+func (a *Application) updateStats(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	r := &a.statsReport
+	switch msg.String() {
+	case "esc":
+		a.state = terminalView
+		a.updateTerminalOutput()
+	case "ctrl+c":
+		return a, tea.Quit
+	case "tab":
+		r.window = (r.window + 1) % len(statsWindows)
+		r.notice = ""
+		a.loadStats()
+	case "r":
+		r.notice = ""
+		a.loadStats()
+	case "e":
+		a.toggleStats()
+	}
+	return a, nil
+}
+
+// renderStats renders the stats view
+func (a *Application) renderStats() string {
+	r := &a.statsReport
+
+	recording := styles.InfoStyle.Render(a.tr("stats.off", a.stats.Path()))
+	if a.stats.Enabled() {
+		recording = styles.SuccessStyle.Render(a.tr("stats.on", a.stats.Path()))
+	}
+
+	var b strings.Builder
+	byName := func(s stats.Sample) string { return s.Name }
+	byCluster := func(s stats.Sample) string { return s.Cluster }
+	for _, table := range []struct {
+		title     string
+		summaries []stats.Summary
+	}{
+		{a.tr("stats.commands"), stats.Summarize(r.samples, stats.KindCommand, byName)},
+		{a.tr("stats.clusters"), stats.Summarize(r.samples, stats.KindCommand, byCluster)},
+		{a.tr("stats.setup_phases"), stats.Summarize(r.samples, stats.KindSetupPhase, byName)},
+	} {
+		b.WriteString(styles.HeaderStyle.Render(table.title) + "\n")
+		if len(table.summaries) == 0 {
+			b.WriteString(styles.InfoStyle.Render("  "+a.tr("stats.empty")) + "\n\n")
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  %-32s %6s %7s %9s %9s %9s\n", "", a.tr("stats.count"), a.tr("stats.failed"), "p50", "p95", "max"))
+		for i, summary := range table.summaries {
+			if i == statsRows {
+				break
+			}
+			line := fmt.Sprintf("  %-32s %6d %6.0f%% %9s %9s %9s", summary.Key, summary.Count,
+				summary.FailureRate()*100, formatLatency(summary.P50), formatLatency(summary.P95), formatLatency(summary.Max))
+			if summary.Failures > 0 && summary.FailureRate() >= 0.2 {
+				line = styles.ErrorStyle.Render(line)
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	return fmt.Sprintf("\n%s\n%s\n\n%s%s\n%s",
+		styles.TitleStyle.Render(a.tr("stats.title", a.tr(statsWindows[r.window].label))),
+		recording,
+		b.String(),
+		r.notice,
+		styles.InfoStyle.Render(a.tr("stats.footer")))
+}
+
+// formatLatency formats a duration with precision suited to its size
+func formatLatency(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
@@ -15,6 +15,10 @@ var styles = struct {
 	OutputStyle    lipgloss.Style
 	LoadingStyle   lipgloss.Style
 	HelpStyle      lipgloss.Style
+	KeyStyle       lipgloss.Style
+	DocStringStyle lipgloss.Style
+	DocNumberStyle lipgloss.Style
+	DocBoolStyle   lipgloss.Style
 }{
 	TitleStyle: lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#FAFAFA")).
@@ -67,4 +71,16 @@ var styles = struct {
 	HelpStyle: lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#CCCCCC")).
 		MarginLeft(2),
+
+	KeyStyle: lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#5FAFFF")),
+
+	DocStringStyle: lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#A8CC8C")),
+
+	DocNumberStyle: lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#F0C674")),
+
+	DocBoolStyle: lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#D787FF")),
 }
\ No newline at end of file
@@ -0,0 +1,227 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/templates"
+)
+
+// templateLibrary lists manifest templates and fills in the selected one
+type templateLibrary struct {
+	templates []templates.Template
+	cursor    int
+	notice    string
+	// Set while filling in the selected template
+	filling bool
+	field   int
+	values  map[string]string
+}
+
+// selected returns the template under the cursor
+func (l *templateLibrary) selected() templates.Template {
+	return l.templates[l.cursor]
+}
+
+// openTemplates lists the built-in and custom templates
+func (a *Application) openTemplates() (tea.Model, tea.Cmd) {
+	a.templates = templateLibrary{}
+	a.loadTemplates()
+	a.state = templatesView
+	return a, nil
+}
+
+// loadTemplates reads the templates, reporting the custom ones that don't parse
+func (a *Application) loadTemplates() {
+	l := &a.templates
+	var errs []error
+	l.templates, errs = templates.Load(a.config.TemplateDir)
+	if l.cursor >= len(l.templates) {
+		l.cursor = 0
+	}
+	l.notice = ""
+	if len(errs) > 0 {
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		l.notice = styles.ErrorStyle.Render("❌ " + strings.Join(messages, "\n❌ "))
+	}
+}
+
+// loadTemplateField puts the current parameter's value in the text input
+func (a *Application) loadTemplateField() {
+	l := &a.templates
+	param := l.selected().Params[l.field]
+	a.textInput.SetValue(l.values[param.Name])
+	a.textInput.Placeholder = param.Name
+	a.textInput.CursorEnd()
+}
+
+// submitTemplateField stores the current parameter and moves on, rendering after the last one
+func (a *Application) submitTemplateField() (tea.Model, tea.Cmd) {
+	l := &a.templates
+	t := l.selected()
+	l.values[t.Params[l.field].Name] = strings.TrimSpace(a.textInput.Value())
+	if l.field < len(t.Params)-1 {
+		l.field++
+		a.loadTemplateField()
+		return a, nil
+	}
+	return a, a.renderTemplate()
+}
+
+// renderTemplate fills in the selected template and reviews it in the apply flow
+func (a *Application) renderTemplate() tea.Cmd {
+	l := &a.templates
+	t := l.selected()
+	manifest, err := t.Render(l.values)
+	if err != nil {
+		l.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+		return nil
+	}
+
+	cmd, err := a.reviewManifest(t.Name, manifest, templatesView)
+	if err != nil {
+		l.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+		return nil
+	}
+	l.filling = false
+	return cmd
+}
+
+// reviewManifest writes a generated manifest to a temporary file and checks
+// it in the apply flow, which returns to source on esc
+func (a *Application) reviewManifest(name, manifest string, source sessionState) (tea.Cmd, error) {
+	file, err := os.CreateTemp("", "kub-cli-"+strings.ReplaceAll(name, " ", "-")+"-*.yaml")
+	if err == nil {
+		_, err = file.WriteString(manifest)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write the manifest: %v", err)
+	}
+
+	a.apply = applyFlow{
+		session:  a.currentSession(),
+		template: name,
+		manifest: manifest,
+		source:   source,
+		path:     file.Name(),
+		viewport: viewport.New(a.width-4, a.height-10),
+	}
+	a.state = applyView
+	return a.checkManifest(file.Name()), nil
+}
+
+// updateTemplates handles template library updates
+func (a *Application) updateTemplates(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	l := &a.templates
+	key := msg.String()
+	if key == "ctrl+c" {
+		return a, tea.Quit
+	}
+
+	if l.filling {
+		switch key {
+		case "esc":
+			l.filling = false
+			return a, nil
+		case "shift+tab", "up":
+			if l.field > 0 {
+				l.values[l.selected().Params[l.field].Name] = strings.TrimSpace(a.textInput.Value())
+				l.field--
+				a.loadTemplateField()
+			}
+			return a, nil
+		case "enter":
+			return a.submitTemplateField()
+		}
+		var cmd tea.Cmd
+		a.textInput, cmd = a.textInput.Update(msg)
+		return a, cmd
+	}
+
+	switch key {
+	case "esc":
+		a.state = terminalView
+		a.updateTerminalOutput()
+	case "up", "k":
+		if l.cursor > 0 {
+			l.cursor--
+		}
+	case "down", "j":
+		if l.cursor < len(l.templates)-1 {
+			l.cursor++
+		}
+	case "r":
+		a.loadTemplates()
+	case "enter":
+		if l.cursor >= len(l.templates) {
+			return a, nil
+		}
+		t := l.selected()
+		l.values = make(map[string]string)
+		for _, param := range t.Params {
+			l.values[param.Name] = param.Default
+		}
+		if len(t.Params) == 0 {
+			return a, a.renderTemplate()
+		}
+		l.filling = true
+		l.field = 0
+		l.notice = ""
+		a.loadTemplateField()
+	}
+	return a, nil
+}
+
+// renderTemplates renders the template library
+func (a *Application) renderTemplates() string {
+	l := &a.templates
+
+	if l.filling {
+		t := l.selected()
+		param := t.Params[l.field]
+		label := param.Name
+		if param.Description != "" {
+			label += " - " + param.Description
+		}
+		footer := a.tr("templates.field_footer")
+		if l.field == len(t.Params)-1 {
+			footer = a.tr("templates.review_footer")
+		}
+		return fmt.Sprintf("\n%s\n\n%s:\n\n%s\n%s\n\n%s",
+			styles.TitleStyle.Render(a.tr("templates.field_title", t.Name, l.field+1, len(t.Params))),
+			label,
+			a.textInput.View(),
+			l.notice,
+			styles.InfoStyle.Render(footer))
+	}
+
+	var b strings.Builder
+	for i, t := range l.templates {
+		source := a.tr("templates.built_in")
+		if t.Path != "" {
+			source = a.tr("templates.custom")
+		}
+		line := fmt.Sprintf("  %-20s %-9s %s", t.Name, source, t.Description)
+		if i == l.cursor {
+			line = styles.SelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	return fmt.Sprintf("\n%s\n%s\n\n%s\n%s\n%s",
+		styles.TitleStyle.Render(a.tr("templates.title")),
+		styles.InfoStyle.Render(a.tr("templates.dir", a.config.TemplateDir)),
+		b.String(),
+		l.notice,
+		styles.InfoStyle.Render(a.tr("templates.footer")))
+}
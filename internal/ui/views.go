@@ -1,16 +1,28 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/audit"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/git"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/kubectl"
+	"github.com/RaymondAkachi/custom-kub-cli/internal/setup"
+	"github.com/RaymondAkachi/custom-kub-cli/k8s/clustersetup"
 )
 
 // renderClusterSelection renders the cluster selection view
 func (a *Application) renderClusterSelection() string {
 	return fmt.Sprintf("\n%s\n\n%s\n\n%s",
-		styles.TitleStyle.Render("🚀 Kubernetes Orchestrator"),
+		styles.TitleStyle.Render(a.tr("app.title")),
 		a.list.View(),
-		styles.InfoStyle.Render("↑/↓: navigate • enter: select • q: quit"))
+		styles.InfoStyle.Render(a.tr("clusters.footer")))
 }
 // renderAddCluster renders the add cluster form
 func (a *Application) renderAddCluster() string {
@@ -19,33 +31,55 @@ func (a *Application) renderAddCluster() string {
 
 	switch a.addClusterStep {
 	case 0:
-		title = "📝 Add New Cluster - Step 1/3"
-		instructions = "Enter the cluster name:"
+		title = a.tr("add.title", 1)
+		instructions = a.tr("add.name")
 	case 1:
-		title = "📝 Add New Cluster - Step 2/3"
-		instructions = fmt.Sprintf("Cluster: %s\nEnter public IP or DNS:", a.newCluster.Name)
+		title = a.tr("add.title", 2)
+		instructions = a.tr("add.endpoint", a.newCluster.Name)
 	case 2:
-		title = "📝 Add New Cluster - Step 3/3"
+		title = a.tr("add.title", 3)
 		endpoint := a.newCluster.DNS
 		if endpoint == "" {
 			endpoint = a.newCluster.PublicIP
 		}
-		instructions = fmt.Sprintf("Cluster: %s\nEndpoint: %s\nEnter path to kubeconfig file:",
-			a.newCluster.Name, endpoint)
+		instructions = a.tr("add.kubeconfig", a.newCluster.Name, endpoint)
 	}
 
 	return fmt.Sprintf("\n%s\n\n%s\n\n%s\n\n%s",
 		styles.TitleStyle.Render(title),
 		instructions,
 		a.textInput.View(),
-		styles.InfoStyle.Render("enter: next • esc: cancel"))
+		styles.InfoStyle.Render(a.tr("add.footer")))
 }
 
 // renderTerminal renders the terminal view
 func (a *Application) renderTerminal() string {
-	return fmt.Sprintf("%s\n\n%s",
-		a.viewport.View(),
-		styles.InfoStyle.Render("esc: switch clusters • ctrl+l: clear • ctrl+c: quit"))
+	footer := styles.InfoStyle.Render(a.tr("terminal.footer", a.secretsHint()))
+	if a.gitManager != nil {
+		if pending := a.gitManager.PendingPushes(); pending > 0 {
+			footer = styles.ErrorStyle.Render(a.tr("git.pending_push", pending)) + "  " + footer
+		}
+	}
+	return fmt.Sprintf("%s\n\n%s", a.viewport.View(), footer)
+}
+
+// renderGitStatus renders the git sync status view
+func (a *Application) renderGitStatus() string {
+	body := ""
+	if a.gitStatus.status != nil {
+		body = a.formatGitStatus(a.gitStatus.status)
+	}
+	if a.gitStatus.err != nil {
+		body += "\n" + styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", a.gitStatus.err))
+	}
+	if a.gitStatus.notice != "" {
+		body += "\n" + styles.InfoStyle.Render(a.gitStatus.notice)
+	}
+
+	return fmt.Sprintf("\n%s\n\n%s\n\n%s",
+		styles.TitleStyle.Render(a.tr("git.title", a.selectedCluster.Name)),
+		body,
+		styles.InfoStyle.Render(a.tr("git.footer")))
 }
 
 // renderLoading renders the loading view
@@ -53,15 +87,15 @@ func (a *Application) renderLoading() string {
 	return fmt.Sprintf("\n%s %s\n\n%s",
 		a.spinner.View(),
 		styles.LoadingStyle.Render(a.loadingMsg),
-		styles.InfoStyle.Render("esc: cancel"))
+		styles.InfoStyle.Render(a.tr("loading.footer")))
 }
 
 // setupTerminalViewport initializes the terminal viewport
 func (a *Application) setupTerminalViewport() {
 	a.output = fmt.Sprintf("%s\n%s\n%s\n",
-		styles.TitleStyle.Render("🎯 Connected to cluster: "+a.selectedCluster.Name),
+		styles.TitleStyle.Render(a.tr("terminal.connected", a.selectedCluster.Name)),
 		a.getClusterStatusLine(),
-		styles.HeaderStyle.Render("Terminal Ready - Type 'help' for commands, 'esc' to switch clusters"))
+		styles.HeaderStyle.Render(a.tr("terminal.ready")))
 	a.updateTerminalOutput()
 }
 
@@ -69,144 +103,608 @@ func (a *Application) setupTerminalViewport() {
 func (a *Application) getClusterStatusLine() string {
 	status := []string{}
 	if a.selectedCluster.HasPrometheus {
-		status = append(status, "🔍 Prometheus")
+		status = append(status, a.tr("status.prometheus"))
 	}
 	if a.selectedCluster.HasArgoCD {
-		status = append(status, "🚀 ArgoCD")
+		status = append(status, a.tr("status.argocd"))
+	}
+	if a.clusterReadOnly(a.selectedCluster) {
+		status = append(status, a.tr("status.read_only"))
 	}
 
 	if len(status) > 0 {
-		return styles.InfoStyle.Render("Status: " + strings.Join(status, " | "))
+		return styles.InfoStyle.Render(a.tr("status.line", strings.Join(status, " | ")))
 	}
 	return ""
 }
 
+// secretsHint returns the key hint for toggling secret masking
+func (a *Application) secretsHint() string {
+	if a.revealSecrets {
+		return a.tr("secrets.mask")
+	}
+	return a.tr("secrets.reveal")
+}
+
+// visibleOutput returns the terminal output with secrets masked unless revealed
+func (a *Application) visibleOutput() string {
+	if a.revealSecrets {
+		return a.output
+	}
+	return kubectl.MaskSecrets(a.output)
+}
+
 // updateTerminalOutput updates the terminal viewport content
 func (a *Application) updateTerminalOutput() {
-	content := a.output + "\n" + a.getCurrentPrompt()
+	content := a.visibleOutput() + "\n" + a.getCurrentPrompt()
 	a.viewport.SetContent(content)
 	a.viewport.GotoBottom()
 }
 
 // updateTerminalPrompt updates just the prompt line
 func (a *Application) updateTerminalPrompt() {
-	content := a.output + "\n" + a.getCurrentPrompt()
+	content := a.visibleOutput() + "\n" + a.getCurrentPrompt()
 	a.viewport.SetContent(content)
 	a.viewport.GotoBottom()
 }
 
 // getCurrentPrompt returns the current command prompt
 func (a *Application) getCurrentPrompt() string {
-	return fmt.Sprintf("%s %s",
-		styles.PromptStyle.Render(fmt.Sprintf("[%s]$", a.selectedCluster.Name)),
-		a.currentCommand)
+	a.resizePrompt()
+	prompt := a.prompt.View()
+	if running := a.running[a.selectedCluster.Name]; running > 0 {
+		prompt = styles.InfoStyle.Render(a.tr("terminal.in_flight", a.spinner.View(), running)) + "\n" + prompt
+	}
+	return prompt
 }
 
 // getHelpText returns the help text
 func (a *Application) getHelpText() string {
-	return `🎯 Kubernetes Orchestrator Terminal
-
-Built-in Commands:
-  help              - Show this help
-  clear             - Clear terminal
-  cluster-info      - Show cluster information
-  deps              - Show dependency information
-  esc               - Switch clusters
-
-Kubectl Commands:
-  get pods          - List pods
-  get nodes         - List nodes  
-  get namespaces    - List namespaces
-  describe <resource> <n>  - Describe resource
-  logs <pod-name>   - Get pod logs
-  apply -f <file>   - Apply resource from file
-  create <resource> - Create resource
-  delete <resource> <n> - Delete resource
-
-💡 Any kubectl command will work and be executed on the selected cluster.
-📤 Resource modifications are automatically synced to Git (if ArgoCD is configured).
-
-Keyboard Shortcuts:
-  Ctrl+L  - Clear terminal
-  Esc     - Switch clusters
-  Ctrl+C  - Quit application
-
-🔧 System Requirements:
-  • kubectl - Kubernetes command-line tool
-  • git - Version control system
-
-All dependencies are verified on startup.`
+	return a.tr("help.text")
 }
 
 // getClusterInfo returns detailed cluster information
 func (a *Application) getClusterInfo() string {
-	info := fmt.Sprintf(`🔍 Cluster Information:
-  Name: %s
-  Server: %s`, a.selectedCluster.Name, a.selectedCluster.Server)
+	info := a.tr("info.header", a.selectedCluster.Name, a.selectedCluster.Server)
 
 	if a.selectedCluster.PublicIP != "" {
-		info += fmt.Sprintf("\n  Public IP: %s", a.selectedCluster.PublicIP)
+		info += "\n  " + a.tr("info.public_ip", a.selectedCluster.PublicIP)
 	}
 	if a.selectedCluster.DNS != "" {
-		info += fmt.Sprintf("\n  DNS: %s", a.selectedCluster.DNS)
+		info += "\n  " + a.tr("info.dns", a.selectedCluster.DNS)
 	}
 
-	info += fmt.Sprintf(`
-  Config: %s
-  Added: %s
-  Prometheus: %v
-  ArgoCD: %v`,
+	info += "\n" + a.tr("info.details",
 		a.selectedCluster.ConfigPath,
 		a.selectedCluster.CreatedAt.Format("2006-01-02 15:04:05"),
 		a.selectedCluster.HasPrometheus,
 		a.selectedCluster.HasArgoCD)
 
 	if a.selectedCluster.GitRepo != "" {
-		info += fmt.Sprintf("\n  Git Repo: %s", a.selectedCluster.GitRepo)
+		info += "\n  " + a.tr("info.git_repo", a.selectedCluster.GitRepo)
+	}
+
+	if kubeConfig, err := config.LoadKubeConfig(a.selectedCluster.ConfigPath); err == nil {
+		if auth, err := kubeConfig.CurrentAuth(); err == nil {
+			info += "\n  " + a.tr("info.auth", auth.Description())
+		}
 	}
 
 	// Add connection status
 	if a.kubectlExecutor != nil {
 		if err := a.kubectlExecutor.TestConnection(); err != nil {
-			info += fmt.Sprintf("\n  %s", styles.ErrorStyle.Render("❌ Connection: Failed"))
+			info += fmt.Sprintf("\n  %s", styles.ErrorStyle.Render(a.tr("info.connection_failed")))
 		} else {
-			info += fmt.Sprintf("\n  %s", styles.SuccessStyle.Render("✅ Connection: Active"))
+			info += fmt.Sprintf("\n  %s", styles.SuccessStyle.Render(a.tr("info.connection_active")))
 		}
 	}
 
 	return info
 }
 
-// getDependencyInfo returns information about system dependencies
+// getDependencyInfo returns the cached dependency information from the background refresher
 func (a *Application) getDependencyInfo() string {
-	info := "🔧 System Dependencies:\n\n"
+	statuses, checkedAt, refreshing := a.depsRefresher.Snapshot()
+	if checkedAt.IsZero() {
+		return styles.LoadingStyle.Render(a.tr("deps.checking"))
+	}
 
-	// Check kubectl
-	if kubectlVersion, err := a.dependencyChecker.GetVersion("kubectl"); err != nil {
-		info += styles.ErrorStyle.Render("❌ kubectl: Not available\n")
-	} else {
-		info += styles.SuccessStyle.Render("✅ kubectl: Available\n")
-		info += styles.InfoStyle.Render(fmt.Sprintf("    Version: %s\n", strings.Split(kubectlVersion, "\n")[0]))
+	info := a.tr("deps.title") + "\n\n"
+	for _, optional := range []bool{false, true} {
+		if optional {
+			info += "\n" + a.tr("deps.optional") + "\n\n"
+		}
+		for _, status := range statuses {
+			if status.Optional != optional {
+				continue
+			}
+			if !status.Available {
+				if optional {
+					info += styles.InfoStyle.Render(a.tr("deps.not_installed", status.Name, status.InstallURL) + "\n")
+				} else {
+					info += styles.ErrorStyle.Render(a.tr("deps.not_available", status.Name, status.Error) + "\n")
+				}
+				continue
+			}
+			info += styles.SuccessStyle.Render(a.tr("deps.available", status.Name) + "\n")
+			info += styles.InfoStyle.Render("    " + a.tr("deps.version", status.Version) + "\n")
+		}
 	}
 
-	// Check git
-	if gitVersion, err := a.dependencyChecker.GetVersion("git"); err != nil {
-		info += styles.ErrorStyle.Render("❌ git: Not available\n")
-	} else {
-		info += styles.SuccessStyle.Render("✅ git: Available\n")
-		info += styles.InfoStyle.Render(fmt.Sprintf("    Version: %s\n", gitVersion))
+	checked := "\n" + a.tr("deps.checked", checkedAt.Format("15:04:05"))
+	if refreshing {
+		checked += " • " + a.tr("deps.refreshing")
 	}
+	info += styles.InfoStyle.Render(checked) + "\n"
 
 	// Git repository status if available
 	if a.gitManager != nil {
-		info += "\n📁 Git Repository Status:\n"
+		info += "\n" + a.tr("deps.git_title") + "\n"
 		if lastCommit, err := a.gitManager.GetLastCommit(); err != nil {
-			info += styles.ErrorStyle.Render("❌ Unable to get repository status\n")
+			info += styles.ErrorStyle.Render(a.tr("deps.git_unavailable") + "\n")
 		} else {
-			info += styles.SuccessStyle.Render("✅ Repository: Connected\n")
-			info += styles.InfoStyle.Render(fmt.Sprintf("    Last commit: %s\n", lastCommit))
+			info += styles.SuccessStyle.Render(a.tr("deps.git_connected") + "\n")
+			info += styles.InfoStyle.Render("    " + a.tr("git.last_commit", lastCommit) + "\n")
+		}
+	}
+
+	return info
+}
+// getPluginInfo returns information about discovered terminal plugins
+func (a *Application) getPluginInfo() string {
+	if err := a.pluginManager.Discover(); err != nil {
+		return styles.ErrorStyle.Render(a.tr("plugins.discover_failed", err))
+	}
+
+	found := a.pluginManager.List()
+	if len(found) == 0 {
+		return styles.InfoStyle.Render(a.tr("plugins.none", a.config.PluginDir))
+	}
+
+	info := a.tr("plugins.title") + "\n\n"
+	for _, plugin := range found {
+		info += fmt.Sprintf("  %-16s %s\n", plugin.Name, styles.InfoStyle.Render(plugin.Path))
+	}
+	return info
+}
+
+// getAuditInfo renders recent audit log entries or exports the audit log
+func (a *Application) getAuditInfo(args []string) string {
+	if len(args) > 0 && args[0] == "export" {
+		if len(args) < 2 {
+			return styles.ErrorStyle.Render(a.tr("audit.export_usage"))
+		}
+		if err := a.auditLog.Export(args[1]); err != nil {
+			return styles.ErrorStyle.Render(a.tr("audit.export_failed", err))
+		}
+		return styles.SuccessStyle.Render(a.tr("audit.exported", args[1]))
+	}
+
+	limit := 20
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return styles.ErrorStyle.Render(a.tr("audit.usage"))
+		}
+		limit = n
+	}
+
+	entries, err := a.auditLog.Read(limit)
+	if err != nil {
+		return styles.ErrorStyle.Render(a.tr("audit.read_failed", err))
+	}
+	if len(entries) == 0 {
+		return styles.InfoStyle.Render(a.tr("audit.empty"))
+	}
+
+	info := a.tr("audit.title", a.auditLog.Path()) + "\n\n"
+	for _, entry := range entries {
+		status := styles.SuccessStyle.Render("✅")
+		if entry.Status == audit.StatusFailure {
+			status = styles.ErrorStyle.Render("❌")
+		}
+		info += fmt.Sprintf("%s %s %-8s %-12s %-12s %s\n",
+			status,
+			entry.Time.Format("2006-01-02 15:04:05"),
+			entry.User,
+			entry.Cluster,
+			entry.Source,
+			entry.Command)
+		if entry.Error != "" {
+			info += styles.InfoStyle.Render("    "+strings.Split(entry.Error, "\n")[0]) + "\n"
+		}
+	}
+	return info
+}
+
+// getPolicyInfo returns the effective command policy for the selected cluster
+func (a *Application) getPolicyInfo() string {
+	policy, err := a.config.PolicyForCluster(a.selectedCluster)
+	if err != nil {
+		return styles.ErrorStyle.Render(a.tr("policy.load_failed", err))
+	}
+
+	info := a.tr("policy.title") + "\n\n"
+	tags := a.tr("policy.no_tags")
+	if len(a.selectedCluster.Tags) > 0 {
+		tags = strings.Join(a.selectedCluster.Tags, ", ")
+	}
+	info += "  " + a.tr("policy.tags", tags) + "\n"
+	readOnly := fmt.Sprintf("%v", policy.ReadOnly)
+	if a.readOnly {
+		readOnly = a.tr("policy.read_only_flag")
+	} else if a.selectedCluster.ReadOnly {
+		readOnly = a.tr("policy.read_only_setting")
+	}
+	info += "  " + a.tr("policy.read_only", readOnly) + "\n"
+	if len(policy.Deny) > 0 {
+		info += "  " + a.tr("policy.denied", strings.Join(policy.Deny, ", ")) + "\n"
+	}
+	if len(policy.Confirm) > 0 {
+		info += "  " + a.tr("policy.confirm", strings.Join(policy.Confirm, ", ")) + "\n"
+	}
+	info += styles.InfoStyle.Render("\n  " + a.tr("policy.profiles", a.config.PolicyPath))
+	return info
+}
+
+// settingsChangedMsg carries a cluster whose settings were changed, to be
+// saved and applied in Update
+type settingsChangedMsg struct {
+	cluster config.ClusterInfo
+	setting string
+}
+
+// clusterSettings shows the request timeout, rate limits and modes of the
+// session's cluster, or checks a change to them that handleSettingsChanged
+// applies
+func (a *Application) clusterSettings(session clusterSession, args []string) tea.Msg {
+	result := func(output string) tea.Msg {
+		return commandExecutedMsg{output: output}
+	}
+	usage := result(styles.ErrorStyle.Render(a.tr("settings.usage")))
+	if len(args) == 0 {
+		timeout := a.tr("settings.kubectl_default")
+		if t := session.executor.RequestTimeout(); t > 0 {
+			timeout = t.String()
+		}
+		qps, burst := session.executor.RateLimits()
+		info := a.tr("settings.title") + "\n\n"
+		info += a.tr("settings.values", timeout, qps, burst, session.cluster.ReadOnly, session.cluster.GitKeepStale) + "\n"
+		info += styles.InfoStyle.Render("\n  " + a.tr("settings.rate_limit_note"))
+		return result(info)
+	}
+	if len(args) != 2 {
+		return usage
+	}
+
+	cluster := *session.cluster
+	value := args[1]
+	switch args[0] {
+	case "timeout":
+		if value == "default" {
+			value = ""
+		}
+		cluster.RequestTimeout = value
+		if _, err := cluster.Timeout(); err != nil {
+			return result(styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err)))
+		}
+	case "qps":
+		qps := 0.0
+		if value != "default" {
+			var err error
+			if qps, err = strconv.ParseFloat(value, 32); err != nil || qps <= 0 {
+				return result(styles.ErrorStyle.Render(a.tr("settings.invalid_qps")))
+			}
+		}
+		cluster.QPS = float32(qps)
+	case "burst":
+		burst := 0
+		if value != "default" {
+			var err error
+			if burst, err = strconv.Atoi(value); err != nil || burst <= 0 {
+				return result(styles.ErrorStyle.Render(a.tr("settings.invalid_burst")))
+			}
+		}
+		cluster.Burst = burst
+	case "read-only":
+		if value != "on" && value != "off" {
+			return usage
 		}
+		if a.readOnly && value == "off" {
+			return result(styles.ErrorStyle.Render(a.tr("settings.started_read_only")))
+		}
+		cluster.ReadOnly = value == "on"
+	case "keep-stale":
+		if value != "on" && value != "off" {
+			return usage
+		}
+		cluster.GitKeepStale = value == "on"
+	default:
+		return usage
 	}
 
+	return settingsChangedMsg{cluster: cluster, setting: args[0]}
+}
+
+// handleSettingsChanged saves a cluster's changed settings and, if it's the
+// selected cluster, applies them to its executor
+func (a *Application) handleSettingsChanged(msg settingsChangedMsg) (tea.Model, tea.Cmd) {
+	if err := a.config.UpdateCluster(msg.cluster); err != nil {
+		return a.update(commandExecutedMsg{output: styles.ErrorStyle.Render(a.tr("settings.save_failed", err))})
+	}
+	if a.selectedCluster != nil && a.selectedCluster.Name == msg.cluster.Name {
+		*a.selectedCluster = msg.cluster
+		timeout, _ := msg.cluster.Timeout()
+		a.kubectlExecutor.SetRequestTimeout(timeout)
+		a.kubectlExecutor.SetRateLimits(msg.cluster.QPS, msg.cluster.Burst)
+		if policy, err := a.clusterPolicy(a.selectedCluster); err == nil {
+			a.kubectlExecutor.SetPolicy(policy)
+		}
+	}
+	return a.update(commandExecutedMsg{output: styles.SuccessStyle.Render(a.tr("settings.saved", msg.setting, msg.cluster.Name))})
+}
+
+// relogin refreshes the credentials of clusters using exec or OIDC auth
+func (a *Application) relogin() string {
+	output, err := a.kubectlExecutor.Relogin()
+	if err != nil {
+		return styles.ErrorStyle.Render(a.tr("relogin.failed", err))
+	}
+	return styles.SuccessStyle.Render(output)
+}
+
+// getQueueInfo lists the running and queued commands for the selected cluster
+func (a *Application) getQueueInfo() string {
+	pending := a.commandQueue.Pending(a.selectedCluster.Name)
+	if len(pending) == 0 {
+		return styles.InfoStyle.Render(a.tr("queue.empty"))
+	}
+
+	info := a.tr("queue.title") + "\n\n"
+	for _, cmd := range pending {
+		state := a.tr("queue.queued")
+		if cmd.Running {
+			state = a.tr("queue.running")
+		}
+		info += fmt.Sprintf("  %3d  %s  %s  %s\n",
+			cmd.ID,
+			state,
+			cmd.QueuedAt.Format("15:04:05"),
+			cmd.Command)
+	}
+	info += styles.InfoStyle.Render("\n  " + a.tr("queue.cancel_hint"))
 	return info
-}
\ No newline at end of file
+}
+
+// cancelQueuedCommand removes a command from the selected cluster's queue
+func (a *Application) cancelQueuedCommand(args []string) string {
+	if len(args) != 1 {
+		return styles.ErrorStyle.Render(a.tr("queue.cancel_usage"))
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return styles.ErrorStyle.Render(a.tr("queue.cancel_usage"))
+	}
+	if err := a.commandQueue.Cancel(a.selectedCluster.Name, id); err != nil {
+		return styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+	}
+	return styles.SuccessStyle.Render(a.tr("queue.canceled", id))
+}
+
+// getGitStatusInfo returns the Git sync status of a session's cluster
+func (a *Application) getGitStatusInfo(session clusterSession) string {
+	if session.gitManager == nil {
+		return styles.InfoStyle.Render(a.tr("git.not_configured"))
+	}
+	status, err := session.gitManager.Status()
+	info := a.formatGitStatus(status)
+	if err != nil {
+		info += "\n" + styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+	}
+	return info + "\n" + styles.InfoStyle.Render(a.tr("git.hint"))
+}
+
+// bootstrapGit lays out the repository of a session's cluster and commits
+// its first export, with an ArgoCD Application when asked
+func (a *Application) bootstrapGit(session clusterSession, args []string) string {
+	if session.gitManager == nil {
+		return styles.InfoStyle.Render(a.tr("git.not_configured"))
+	}
+	if len(args) > 1 || (len(args) == 1 && args[0] != "argocd") {
+		return styles.ErrorStyle.Render(a.tr("git.bootstrap_usage"))
+	}
+	if a.clusterReadOnly(session.cluster) {
+		return styles.ErrorStyle.Render(a.tr("git.read_only"))
+	}
+
+	// Bootstrap through the command queue so it doesn't race kubectl commands
+	var bootstrapErr error
+	err := a.commandQueue.Run(session.cluster.Name, "git bootstrap", func() {
+		started := time.Now()
+		bootstrapErr = session.gitManager.Bootstrap(git.BootstrapOptions{ArgoCD: len(args) == 1})
+		a.recordResult(session.cluster.Name, audit.SourceGit, "bootstrap", started, bootstrapErr)
+	})
+	var exportErr *git.ExportError
+	switch {
+	case errors.Is(err, kubectl.ErrCanceled):
+		return styles.InfoStyle.Render(a.tr("git.bootstrap_canceled"))
+	case errors.As(bootstrapErr, &exportErr):
+		return styles.ErrorStyle.Render(a.tr("git.bootstrap_partial", session.cluster.Name, exportErr))
+	case bootstrapErr != nil:
+		return styles.ErrorStyle.Render(a.tr("git.bootstrap_failed", bootstrapErr))
+	}
+	return styles.SuccessStyle.Render(a.tr("git.bootstrapped", session.cluster.Name))
+}
+
+// syncGit exports a session's cluster and commits it to Git, with the
+// message given by --message or -m instead of the default one
+func (a *Application) syncGit(session clusterSession, args string) string {
+	if session.gitManager == nil {
+		return styles.InfoStyle.Render(a.tr("git.not_configured"))
+	}
+	message, ok := parseSyncMessage(args)
+	if !ok {
+		return styles.ErrorStyle.Render(a.tr("git.sync_usage"))
+	}
+	if a.clusterReadOnly(session.cluster) {
+		return styles.ErrorStyle.Render(a.tr("git.read_only"))
+	}
+
+	// Sync through the command queue so it doesn't race kubectl commands
+	var syncErr error
+	var warnings string
+	err := a.commandQueue.Run(session.cluster.Name, "git sync", func() {
+		started := time.Now()
+		head := session.gitManager.Head()
+		syncErr = session.gitManager.SyncChanges(message)
+		a.recordResult(session.cluster.Name, audit.SourceGit, "sync", started, syncErr)
+		warnings = a.rbacSyncWarnings(session.gitManager, head)
+	})
+	var pushErr *git.PushError
+	var exportErr *git.ExportError
+	switch {
+	case errors.Is(err, kubectl.ErrCanceled):
+		return styles.InfoStyle.Render(a.tr("git.sync_canceled"))
+	case errors.As(syncErr, &pushErr):
+		return styles.ErrorStyle.Render(a.tr("git.push_queued", pushErr.Pending)) + warnings
+	case errors.As(syncErr, &exportErr):
+		return styles.ErrorStyle.Render(a.tr("git.synced_partial", exportErr)) + warnings
+	case syncErr != nil:
+		return styles.ErrorStyle.Render(a.tr("git.sync_failed", syncErr))
+	}
+	return styles.SuccessStyle.Render(a.tr("git.synced")) + warnings
+}
+
+// parseSyncMessage parses the arguments of sync: nothing, or --message or -m
+// followed by the commit message, which may be quoted
+func parseSyncMessage(args string) (string, bool) {
+	if args == "" {
+		return "", true
+	}
+	var message string
+	switch {
+	case strings.HasPrefix(args, "--message="):
+		message = strings.TrimPrefix(args, "--message=")
+	case strings.HasPrefix(args, "--message "), strings.HasPrefix(args, "-m "):
+		_, message, _ = strings.Cut(args, " ")
+	default:
+		return "", false
+	}
+	message = strings.TrimSpace(message)
+	if len(message) >= 2 && (message[0] == '"' || message[0] == '\'') && message[len(message)-1] == message[0] {
+		message = message[1 : len(message)-1]
+	}
+	return message, strings.TrimSpace(message) != ""
+}
+
+// formatGitStatus renders a repository sync status
+func (a *Application) formatGitStatus(status *git.SyncStatus) string {
+	info := a.tr("git.repository", status.RemoteURL, status.RepoPath, status.SyncPath, status.Branch) + "\n"
+	if status.NeedsBootstrap {
+		info += "  " + styles.InfoStyle.Render(a.tr("git.bootstrap_hint")) + "\n"
+	}
+
+	if status.LastCommit != "" {
+		info += "  " + a.tr("git.last_commit", status.LastCommit) + "\n"
+	}
+
+	switch {
+	case status.LastSync.IsZero():
+		info += "  " + a.tr("git.last_sync", a.tr("git.never_synced")) + "\n"
+	case status.LastSyncErr != nil:
+		info += "  " + a.tr("git.last_sync", styles.ErrorStyle.Render(a.tr("git.sync_failed_ago",
+			time.Since(status.LastSync).Round(time.Second), strings.Split(status.LastSyncErr.Error(), "\n")[0]))) + "\n"
+	default:
+		info += "  " + a.tr("git.last_sync", styles.SuccessStyle.Render(a.tr("git.synced_ago",
+			status.LastSync.Format("15:04:05"), time.Since(status.LastSync).Round(time.Second)))) + "\n"
+	}
+	if status.PendingPushes > 0 {
+		pending := a.tr("git.pending_push", status.PendingPushes)
+		if status.LastPushErr != nil {
+			pending += " - " + strings.Split(status.LastPushErr.Error(), "\n")[0]
+		}
+		info += "  " + styles.ErrorStyle.Render(pending) + "\n"
+	}
+
+	if len(status.Changes) == 0 {
+		info += "\n  " + styles.SuccessStyle.Render(a.tr("git.no_drift")) + "\n"
+		return info
+	}
+	info += "\n  " + styles.ErrorStyle.Render(a.tr("git.drift", len(status.Changes))) + "\n"
+	for _, change := range status.Changes {
+		info += fmt.Sprintf("    %-10s %s\n", change.Status, change.Path)
+	}
+	return info
+}
+
+// getSetupLogInfo renders the most recent cluster setup log lines
+func (a *Application) getSetupLogInfo(args []string) string {
+	limit := 50
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return styles.ErrorStyle.Render(a.tr("setup_logs.usage"))
+		}
+		limit = n
+	}
+
+	entries := a.logs.Recent(limit)
+	if len(entries) == 0 {
+		return styles.InfoStyle.Render(a.tr("setup_logs.empty", a.logs.Path()))
+	}
+
+	info := a.tr("setup_logs.title", a.logs.Path()) + "\n\n"
+	for _, entry := range entries {
+		line := fmt.Sprintf("%s %-5s %-12s %s",
+			entry.Time.Format("15:04:05"),
+			entry.Level,
+			entry.Cluster,
+			entry.Message)
+		switch entry.Level {
+		case string(clustersetup.LevelError):
+			line = styles.ErrorStyle.Render(line)
+		case string(clustersetup.LevelDebug):
+			line = styles.InfoStyle.Render(line)
+		}
+		info += line + "\n"
+	}
+	return info
+}
+
+// getSetupsInfo lists the managed cluster configs with the setup status of
+// their clusters, or shows the config of one cluster
+func (a *Application) getSetupsInfo(args []string) string {
+	if len(args) > 1 {
+		return styles.ErrorStyle.Render(a.tr("setups.usage"))
+	}
+	if len(args) == 1 {
+		managed, err := a.setupStore.Find(args[0])
+		if err != nil {
+			return styles.ErrorStyle.Render(a.tr("setups.error", err))
+		}
+		if managed.ParseErr != nil {
+			return styles.ErrorStyle.Render(a.tr("setups.error", managed.ParseErr))
+		}
+		c := managed.Config
+		return a.tr("setups.config", managed.Path, c.ClusterName, c.KubernetesVersion, c.WorkDir, setup.SetupStatus(managed))
+	}
+
+	configs, err := a.setupStore.List()
+	if err != nil {
+		return styles.ErrorStyle.Render(a.tr("setups.error", err))
+	}
+	if len(configs) == 0 {
+		return styles.InfoStyle.Render(a.tr("setups.empty", a.setupStore.Dir()))
+	}
+
+	info := a.tr("setups.title", a.setupStore.Dir()) + "\n\n"
+	for _, managed := range configs {
+		if managed.ParseErr != nil {
+			info += styles.ErrorStyle.Render(fmt.Sprintf("%-16s %v", managed.Name, managed.ParseErr)) + "\n"
+			continue
+		}
+		c := managed.Config
+		info += fmt.Sprintf("%-16s %-16s %-10s %s  %s\n", managed.Name, c.ClusterName, c.KubernetesVersion, setup.SetupStatus(managed), c.WorkDir)
+	}
+	return info
+}
@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+)
+
+// kubeconfigCheckInterval is how often kubeconfigs are checked for external changes
+const kubeconfigCheckInterval = 3 * time.Second
+
+// kubeconfigTickMsg triggers a kubeconfig check
+type kubeconfigTickMsg struct{}
+
+// watchKubeconfigs schedules the next kubeconfig check
+func watchKubeconfigs() tea.Cmd {
+	return tea.Tick(kubeconfigCheckInterval, func(time.Time) tea.Msg {
+		return kubeconfigTickMsg{}
+	})
+}
+
+// kubeconfigChangesMsg carries the kubeconfigs a check found changed
+type kubeconfigChangesMsg struct {
+	changes []config.KubeConfigChange
+}
+
+// checkKubeconfigs checks the kubeconfigs of the registered clusters in the
+// background, so copying and parsing them doesn't hold up the UI
+func (a *Application) checkKubeconfigs() tea.Cmd {
+	watcher := a.kubeconfigWatcher
+	clusters := append([]config.ClusterInfo(nil), a.config.GetAllClusters()...)
+	return func() tea.Msg {
+		return kubeconfigChangesMsg{changes: watcher.Check(clusters)}
+	}
+}
+
+// handleKubeconfigChanges saves kubeconfigs rotated outside the CLI and tells
+// the user, so the next command doesn't fail with stale connection details
+func (a *Application) handleKubeconfigChanges(msg kubeconfigChangesMsg) (tea.Model, tea.Cmd) {
+	if len(msg.changes) == 0 {
+		return a, watchKubeconfigs()
+	}
+
+	for _, change := range msg.changes {
+		if change.Err == nil {
+			change.Err = a.kubeconfigWatcher.Save(change)
+		}
+		if change.Err != nil {
+			a.output += styles.ErrorStyle.Render(a.tr("kubeconfig.unusable", change.Cluster, change.Err)) + "\n"
+			continue
+		}
+
+		notice := a.tr("kubeconfig.reloaded", change.Cluster)
+		if change.FromSource {
+			notice = a.tr("kubeconfig.copied", change.Cluster)
+		}
+		if change.Server != change.OldServer {
+			notice += "; " + a.tr("kubeconfig.server", change.Server)
+		}
+		a.output += styles.InfoStyle.Render(notice) + "\n"
+
+		// Tables from before the change may describe a different cluster
+		delete(a.tables, change.Cluster)
+		if a.selectedCluster != nil && a.selectedCluster.Name == change.Cluster {
+			if cluster, err := a.config.GetCluster(change.Cluster); err == nil {
+				a.selectedCluster.Server = cluster.Server
+				a.selectedCluster.AuthType = cluster.AuthType
+			}
+		}
+	}
+
+	a.refreshClusterList()
+	if a.state == terminalView {
+		a.updateTerminalOutput()
+	}
+	return a, watchKubeconfigs()
+}
@@ -0,0 +1,198 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/wizard"
+)
+
+// resourceWizard creates common objects from guided forms
+type resourceWizard struct {
+	cursor int
+	notice string
+	// Set while filling in the form of the selected kind
+	filling bool
+	field   int
+	values  map[string]string
+}
+
+// selected returns the wizard under the cursor
+func (w *resourceWizard) selected() wizard.Wizard {
+	return wizard.Wizards[w.cursor]
+}
+
+// openWizard lists the kinds that can be created, or starts the form of the
+// kind given as in "create deployment"
+func (a *Application) openWizard(args []string) (tea.Model, tea.Cmd) {
+	a.wizard = resourceWizard{}
+	a.state = wizardView
+	if len(args) == 0 {
+		return a, nil
+	}
+	i, ok := wizard.Find(args[0])
+	if !ok {
+		a.wizard.notice = styles.ErrorStyle.Render(a.tr("wizard.unknown", args[0]))
+		return a, nil
+	}
+	a.wizard.cursor = i
+	a.startWizardForm()
+	return a, nil
+}
+
+// startWizardForm starts filling in the selected kind with its defaults
+func (a *Application) startWizardForm() {
+	w := &a.wizard
+	w.values = make(map[string]string)
+	for _, field := range w.selected().Fields {
+		w.values[field.Name] = field.Default
+	}
+	w.filling = true
+	w.field = 0
+	w.notice = ""
+	a.loadWizardField()
+}
+
+// loadWizardField puts the current field's value in the text input
+func (a *Application) loadWizardField() {
+	w := &a.wizard
+	field := w.selected().Fields[w.field]
+	a.textInput.SetValue(w.values[field.Name])
+	a.textInput.Placeholder = field.Hint
+	a.textInput.CursorEnd()
+}
+
+// submitWizardField checks the current field and moves on, reviewing the
+// manifest after the last one. Invalid values keep the form on the field.
+func (a *Application) submitWizardField() (tea.Model, tea.Cmd) {
+	w := &a.wizard
+	kind := w.selected()
+	field := kind.Fields[w.field]
+	value := strings.TrimSpace(a.textInput.Value())
+	if err := field.Check(value); err != nil {
+		w.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+		return a, nil
+	}
+	w.values[field.Name] = value
+	w.notice = ""
+	if w.field < len(kind.Fields)-1 {
+		w.field++
+		a.loadWizardField()
+		return a, nil
+	}
+
+	manifest, err := kind.Manifest(w.values)
+	if err != nil {
+		w.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+		return a, nil
+	}
+	cmd, err := a.reviewManifest(kind.Kind+" "+w.values["name"], manifest, wizardView)
+	if err != nil {
+		w.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+		return a, nil
+	}
+	return a, cmd
+}
+
+// updateWizard handles resource wizard updates
+func (a *Application) updateWizard(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	w := &a.wizard
+	key := msg.String()
+	if key == "ctrl+c" {
+		return a, tea.Quit
+	}
+
+	if w.filling {
+		switch key {
+		case "esc":
+			w.filling = false
+			w.notice = ""
+			return a, nil
+		case "shift+tab", "up":
+			if w.field > 0 {
+				w.values[w.selected().Fields[w.field].Name] = strings.TrimSpace(a.textInput.Value())
+				w.field--
+				w.notice = ""
+				a.loadWizardField()
+			}
+			return a, nil
+		case "enter", "tab":
+			return a.submitWizardField()
+		}
+		var cmd tea.Cmd
+		a.textInput, cmd = a.textInput.Update(msg)
+		return a, cmd
+	}
+
+	switch key {
+	case "esc":
+		a.state = terminalView
+		a.updateTerminalOutput()
+	case "up", "k":
+		if w.cursor > 0 {
+			w.cursor--
+		}
+	case "down", "j":
+		if w.cursor < len(wizard.Wizards)-1 {
+			w.cursor++
+		}
+	case "enter":
+		a.startWizardForm()
+	}
+	return a, nil
+}
+
+// renderWizard renders the resource wizard
+func (a *Application) renderWizard() string {
+	w := &a.wizard
+
+	if w.filling {
+		kind := w.selected()
+		var b strings.Builder
+		for i, field := range kind.Fields {
+			label := fmt.Sprintf("  %-26s", field.Label)
+			switch {
+			case i == w.field:
+				b.WriteString(styles.SelectedStyle.Render(label) + " " + a.textInput.View() + "\n")
+			case i < w.field:
+				b.WriteString(label + " " + w.values[field.Name] + "\n")
+			default:
+				b.WriteString(styles.InfoStyle.Render(label+" "+w.values[field.Name]) + "\n")
+			}
+		}
+		field := kind.Fields[w.field]
+		hint := ""
+		if field.Hint != "" {
+			hint = a.tr("wizard.example", field.Hint)
+		}
+		if field.Optional {
+			hint = strings.TrimPrefix(hint+" • "+a.tr("wizard.optional"), " • ")
+		}
+		footer := a.tr("wizard.next_help")
+		if w.field == len(kind.Fields)-1 {
+			footer = a.tr("wizard.review_help")
+		}
+		return fmt.Sprintf("\n%s\n\n%s\n%s\n%s\n\n%s",
+			styles.TitleStyle.Render(a.tr("wizard.field_title", kind.Kind, w.field+1, len(kind.Fields))),
+			b.String(),
+			styles.InfoStyle.Render(hint),
+			w.notice,
+			styles.InfoStyle.Render(footer))
+	}
+
+	var b strings.Builder
+	for i, kind := range wizard.Wizards {
+		line := fmt.Sprintf("  %-12s %s", kind.Kind, kind.Description)
+		if i == w.cursor {
+			line = styles.SelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	return fmt.Sprintf("\n%s\n\n%s\n%s\n%s",
+		styles.TitleStyle.Render(a.tr("wizard.title")),
+		b.String(),
+		w.notice,
+		styles.InfoStyle.Render(a.tr("wizard.help")))
+}
@@ -0,0 +1,152 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
+)
+
+// workspaceSwitcher lists workspaces and switches between them
+type workspaceSwitcher struct {
+	names    []string
+	cursor   int
+	creating bool
+	notice   string
+}
+
+// openWorkspaces lists the workspaces with the current one selected
+func (a *Application) openWorkspaces() (tea.Model, tea.Cmd) {
+	names, err := config.ListWorkspaces()
+	a.workspaces = workspaceSwitcher{names: names}
+	if err != nil {
+		a.workspaces.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+	}
+	for i, name := range names {
+		if name == a.config.Workspace {
+			a.workspaces.cursor = i
+		}
+	}
+	a.state = workspacesView
+	return a, nil
+}
+
+// switchWorkspace replaces the application with one for another workspace.
+// Everything loaded from the old workspace is dropped, so running commands
+// must finish first.
+func (a *Application) switchWorkspace(name string) (tea.Model, tea.Cmd) {
+	w := &a.workspaces
+	if a.inFlight > 0 || a.operation.running() {
+		w.notice = styles.ErrorStyle.Render(a.tr("workspaces.busy"))
+		return a, nil
+	}
+
+	cfg, err := config.Initialize(name)
+	if err != nil {
+		w.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+		return a, nil
+	}
+	next, err := NewApplication(cfg)
+	if err != nil {
+		w.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+		return a, nil
+	}
+	// Port-forwards and pushes belong to the clusters of the old workspace
+	a.Close()
+	next.SetReadOnly(a.readOnly)
+	next.Update(tea.WindowSizeMsg{Width: a.width, Height: a.height})
+	// The kubeconfig watch loop keeps running and now reaches the new application
+	return next, next.refreshDependencies
+}
+
+// updateWorkspaces handles workspace switcher updates
+func (a *Application) updateWorkspaces(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	w := &a.workspaces
+	key := msg.String()
+
+	if w.creating {
+		switch key {
+		case "esc":
+			w.creating = false
+			return a, nil
+		case "ctrl+c":
+			return a, tea.Quit
+		case "enter":
+			name := strings.TrimSpace(a.textInput.Value())
+			if err := config.ValidateWorkspaceName(name); err != nil {
+				w.notice = styles.ErrorStyle.Render(fmt.Sprintf("❌ %v", err))
+				return a, nil
+			}
+			w.creating = false
+			return a.switchWorkspace(name)
+		}
+		var cmd tea.Cmd
+		a.textInput, cmd = a.textInput.Update(msg)
+		return a, cmd
+	}
+
+	switch key {
+	case "esc":
+		a.state = clusterSelectionView
+	case "ctrl+c":
+		return a, tea.Quit
+	case "up", "k":
+		if w.cursor > 0 {
+			w.cursor--
+		}
+	case "down", "j":
+		if w.cursor < len(w.names)-1 {
+			w.cursor++
+		}
+	case "enter":
+		if w.cursor >= len(w.names) {
+			return a, nil
+		}
+		if w.names[w.cursor] == a.config.Workspace {
+			a.state = clusterSelectionView
+			return a, nil
+		}
+		return a.switchWorkspace(w.names[w.cursor])
+	case "n":
+		w.creating = true
+		w.notice = ""
+		a.textInput.SetValue("")
+		a.textInput.Placeholder = a.tr("workspaces.name_placeholder")
+	}
+	return a, nil
+}
+
+// renderWorkspaces renders the workspace switcher
+func (a *Application) renderWorkspaces() string {
+	w := &a.workspaces
+
+	if w.creating {
+		return fmt.Sprintf("\n%s\n\n%s\n\n%s\n%s\n\n%s",
+			styles.TitleStyle.Render(a.tr("workspaces.new_title")),
+			a.tr("workspaces.name"),
+			a.textInput.View(),
+			w.notice,
+			styles.InfoStyle.Render(a.tr("workspaces.new_footer")))
+	}
+
+	var b strings.Builder
+	for i, name := range w.names {
+		marker := "  "
+		if name == a.config.Workspace {
+			marker = "★ "
+		}
+		line := marker + name
+		if i == w.cursor {
+			line = styles.SelectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	return fmt.Sprintf("\n%s\n\n%s\n%s\n%s",
+		styles.TitleStyle.Render(a.tr("workspaces.title")),
+		b.String(),
+		w.notice,
+		styles.InfoStyle.Render(a.tr("workspaces.footer")))
+}
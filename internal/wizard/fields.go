@@ -0,0 +1,283 @@
+package wizard
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	dnsLabelPattern     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	dnsSubdomainPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	envNamePattern      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	dataKeyPattern      = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	quantityPattern     = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(m|k|M|G|T|P|E|Ki|Mi|Gi|Ti|Pi|Ei)?$`)
+	portNamePattern     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// dnsLabel checks names that must be a DNS label, such as namespaces and services
+func dnsLabel(value string) error {
+	if len(value) > 63 || !dnsLabelPattern.MatchString(value) {
+		return fmt.Errorf("%q must be at most 63 lowercase letters, digits and '-', starting and ending with a letter or digit", value)
+	}
+	return nil
+}
+
+// dnsSubdomain checks names that may contain dots, such as deployments and configmaps
+func dnsSubdomain(value string) error {
+	if len(value) > 253 || !dnsSubdomainPattern.MatchString(value) {
+		return fmt.Errorf("%q must be lowercase letters, digits, '-' and '.', starting and ending with a letter or digit", value)
+	}
+	return nil
+}
+
+// image checks a container image reference
+func image(value string) error {
+	if strings.ContainsAny(value, " \t") {
+		return fmt.Errorf("image %q can't contain spaces", value)
+	}
+	return nil
+}
+
+// count checks a number of replicas
+func count(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%q must be a whole number of at least 0", value)
+	}
+	return nil
+}
+
+// portNumber checks a port number
+func portNumber(value string) error {
+	_, err := parsePort(value)
+	return err
+}
+
+// parsePort reads a port number between 1 and 65535
+func parsePort(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > 65535 {
+		return 0, fmt.Errorf("port %q must be a number from 1 to 65535", value)
+	}
+	return n, nil
+}
+
+// portRef checks a port given by number or by name
+func portRef(value string) error {
+	if _, err := strconv.Atoi(value); err == nil {
+		return portNumber(value)
+	}
+	if len(value) > 15 || !portNamePattern.MatchString(value) {
+		return fmt.Errorf("port %q must be a number or a port name", value)
+	}
+	return nil
+}
+
+// quantity checks a resource quantity such as 100m or 256Mi
+func quantity(value string) error {
+	if !quantityPattern.MatchString(value) {
+		return fmt.Errorf("%q isn't a quantity, e.g. 250m of CPU or 256Mi of memory", value)
+	}
+	return nil
+}
+
+// oneOf returns a check accepting only the given values
+func oneOf(values ...string) func(string) error {
+	return func(value string) error {
+		for _, allowed := range values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q must be one of %s", value, strings.Join(values, ", "))
+	}
+}
+
+// path checks an HTTP path
+func path(value string) error {
+	if !strings.HasPrefix(value, "/") || strings.ContainsAny(value, " \t") {
+		return fmt.Errorf("path %q must start with / and can't contain spaces", value)
+	}
+	return nil
+}
+
+// host checks an ingress host name, which may start with a wildcard
+func host(value string) error {
+	if err := dnsSubdomain(strings.TrimPrefix(value, "*.")); err != nil {
+		return fmt.Errorf("host %q must be a DNS name such as app.example.com", value)
+	}
+	return nil
+}
+
+// list splits a comma-separated list, dropping empty items
+func list(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseEnv reads NAME=value items. Values written as secret:<name>/<key> or
+// configmap:<name>/<key> are read from that Secret or ConfigMap.
+func parseEnv(value string) ([]envVar, error) {
+	var env []envVar
+	for _, item := range list(value) {
+		name, val, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%q must be NAME=value with a name of letters, digits and '_'", item)
+		}
+		v := envVar{Name: name, Value: strings.TrimSpace(val)}
+		var err error
+		if source, ok := strings.CutPrefix(v.Value, "secret:"); ok {
+			v.Value = ""
+			v.secretRef, err = parseKeyRef(source)
+		} else if source, ok := strings.CutPrefix(v.Value, "configmap:"); ok {
+			v.Value = ""
+			v.configMapRef, err = parseKeyRef(source)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", item, err)
+		}
+		env = append(env, v)
+	}
+	return env, nil
+}
+
+// parseKeyRef reads a <name>/<key> reference to a key of a Secret or ConfigMap
+func parseKeyRef(source string) (*keyRef, error) {
+	name, key, ok := strings.Cut(source, "/")
+	if !ok || dnsSubdomain(name) != nil || !dataKeyPattern.MatchString(key) {
+		return nil, fmt.Errorf("references must be written as <name>/<key>")
+	}
+	return &keyRef{Name: name, Key: key}, nil
+}
+
+// envList checks a list of environment variables
+func envList(value string) error {
+	_, err := parseEnv(value)
+	return err
+}
+
+// parseProbe reads a probe written as http:<port><path>, tcp:<port> or
+// exec:<command>, e.g. http:8080/healthz
+func parseProbe(value string) (*probe, error) {
+	kind, target, _ := strings.Cut(value, ":")
+	target = strings.TrimSpace(target)
+	switch kind {
+	case "http":
+		port, urlPath := target, "/"
+		if i := strings.Index(target, "/"); i >= 0 {
+			port, urlPath = target[:i], target[i:]
+		}
+		if err := portRef(port); err != nil {
+			return nil, err
+		}
+		return &probe{HTTPGet: &httpGet{Path: urlPath, Port: portValue(port)}}, nil
+	case "tcp":
+		if err := portRef(target); err != nil {
+			return nil, err
+		}
+		return &probe{TCPSocket: &tcpSocket{Port: portValue(target)}}, nil
+	case "exec":
+		if target == "" {
+			return nil, fmt.Errorf("exec probes need a command, e.g. exec:cat /tmp/ready")
+		}
+		return &probe{Exec: &execAction{Command: strings.Fields(target)}}, nil
+	}
+	return nil, fmt.Errorf("%q must be http:<port>/<path>, tcp:<port> or exec:<command>", value)
+}
+
+// probeSpec checks a probe
+func probeSpec(value string) error {
+	_, err := parseProbe(value)
+	return err
+}
+
+// portValue returns a port as a number, or as its name
+func portValue(value string) any {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return value
+}
+
+// parseServicePorts reads port[:targetPort][/protocol] items, e.g. 80:8080 or 53/UDP
+func parseServicePorts(value string) ([]servicePort, error) {
+	var ports []servicePort
+	items := list(value)
+	if len(items) == 0 {
+		return nil, fmt.Errorf("at least one port is needed")
+	}
+	for _, item := range items {
+		spec, protocol, hasProtocol := strings.Cut(item, "/")
+		if hasProtocol {
+			protocol = strings.ToUpper(protocol)
+			if err := oneOf("TCP", "UDP", "SCTP")(protocol); err != nil {
+				return nil, err
+			}
+		}
+		portText, target, hasTarget := strings.Cut(spec, ":")
+		port, err := parsePort(portText)
+		if err != nil {
+			return nil, err
+		}
+		if !hasTarget {
+			target = portText
+		}
+		if err := portRef(target); err != nil {
+			return nil, err
+		}
+		// Ports need names once there are several
+		p := servicePort{Port: port, TargetPort: portValue(target), Protocol: protocol}
+		if len(items) > 1 {
+			p.Name = fmt.Sprintf("port-%d", port)
+			if protocol != "" {
+				p.Name += "-" + strings.ToLower(protocol)
+			}
+		}
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+// servicePorts checks a list of service ports
+func servicePorts(value string) error {
+	_, err := parseServicePorts(value)
+	return err
+}
+
+// parseData reads key=value items of a ConfigMap. Values written as @<file>
+// are read from the file.
+func parseData(value string) (map[string]string, error) {
+	data := make(map[string]string)
+	for _, item := range list(value) {
+		key, val, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !dataKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("%q must be key=value or key=@file with a key of letters, digits, '-', '_' and '.'", item)
+		}
+		val = strings.TrimSpace(val)
+		if file, fromFile := strings.CutPrefix(val, "@"); fromFile {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %v", file, err)
+			}
+			val = string(content)
+		}
+		data[key] = val
+	}
+	return data, nil
+}
+
+// dataList checks the data of a ConfigMap
+func dataList(value string) error {
+	_, err := parseData(value)
+	return err
+}
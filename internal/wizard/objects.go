@@ -0,0 +1,155 @@
+package wizard
+
+// The subset of Kubernetes object fields the wizards fill in, in the order
+// kubectl prints them
+
+type object struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   metadata          `yaml:"metadata"`
+	Spec       any               `yaml:"spec,omitempty"`
+	Data       map[string]string `yaml:"data,omitempty"`
+}
+
+type metadata struct {
+	Name      string            `yaml:"name,omitempty"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+type selector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+type deploymentSpec struct {
+	Replicas int         `yaml:"replicas"`
+	Selector selector    `yaml:"selector"`
+	Template podTemplate `yaml:"template"`
+}
+
+type podTemplate struct {
+	Metadata metadata `yaml:"metadata"`
+	Spec     podSpec  `yaml:"spec"`
+}
+
+type podSpec struct {
+	Containers []container `yaml:"containers"`
+}
+
+type container struct {
+	Name           string          `yaml:"name"`
+	Image          string          `yaml:"image"`
+	Ports          []containerPort `yaml:"ports,omitempty"`
+	Env            []envSpec       `yaml:"env,omitempty"`
+	ReadinessProbe *probe          `yaml:"readinessProbe,omitempty"`
+	LivenessProbe  *probe          `yaml:"livenessProbe,omitempty"`
+	Resources      *resources      `yaml:"resources,omitempty"`
+}
+
+type containerPort struct {
+	ContainerPort int `yaml:"containerPort"`
+}
+
+type resources struct {
+	Requests map[string]string `yaml:"requests,omitempty"`
+	Limits   map[string]string `yaml:"limits,omitempty"`
+}
+
+// envVar is an environment variable with a literal value or a reference
+type envVar struct {
+	Name         string
+	Value        string
+	secretRef    *keyRef
+	configMapRef *keyRef
+}
+
+type keyRef struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+type envSpec struct {
+	Name      string        `yaml:"name"`
+	Value     string        `yaml:"value,omitempty"`
+	ValueFrom *valueFromRef `yaml:"valueFrom,omitempty"`
+}
+
+type valueFromRef struct {
+	SecretKeyRef    *keyRef `yaml:"secretKeyRef,omitempty"`
+	ConfigMapKeyRef *keyRef `yaml:"configMapKeyRef,omitempty"`
+}
+
+// spec returns the variable as it's written in a container
+func (v envVar) spec() envSpec {
+	if v.secretRef == nil && v.configMapRef == nil {
+		return envSpec{Name: v.Name, Value: v.Value}
+	}
+	return envSpec{Name: v.Name, ValueFrom: &valueFromRef{SecretKeyRef: v.secretRef, ConfigMapKeyRef: v.configMapRef}}
+}
+
+type probe struct {
+	HTTPGet   *httpGet    `yaml:"httpGet,omitempty"`
+	TCPSocket *tcpSocket  `yaml:"tcpSocket,omitempty"`
+	Exec      *execAction `yaml:"exec,omitempty"`
+}
+
+type httpGet struct {
+	Path string `yaml:"path"`
+	Port any    `yaml:"port"`
+}
+
+type tcpSocket struct {
+	Port any `yaml:"port"`
+}
+
+type execAction struct {
+	Command []string `yaml:"command"`
+}
+
+type serviceSpec struct {
+	Type     string            `yaml:"type"`
+	Selector map[string]string `yaml:"selector"`
+	Ports    []servicePort     `yaml:"ports"`
+}
+
+type servicePort struct {
+	Name       string `yaml:"name,omitempty"`
+	Port       int    `yaml:"port"`
+	TargetPort any    `yaml:"targetPort"`
+	Protocol   string `yaml:"protocol,omitempty"`
+}
+
+type ingressSpec struct {
+	IngressClassName string        `yaml:"ingressClassName,omitempty"`
+	TLS              []ingressTLS  `yaml:"tls,omitempty"`
+	Rules            []ingressRule `yaml:"rules"`
+}
+
+type ingressTLS struct {
+	Hosts      []string `yaml:"hosts"`
+	SecretName string   `yaml:"secretName"`
+}
+
+type ingressRule struct {
+	Host string      `yaml:"host"`
+	HTTP ingressHTTP `yaml:"http"`
+}
+
+type ingressHTTP struct {
+	Paths []ingressPath `yaml:"paths"`
+}
+
+type ingressPath struct {
+	Path     string         `yaml:"path"`
+	PathType string         `yaml:"pathType"`
+	Backend  ingressBackend `yaml:"backend"`
+}
+
+type ingressBackend struct {
+	Service ingressService `yaml:"service"`
+}
+
+type ingressService struct {
+	Name string         `yaml:"name"`
+	Port map[string]any `yaml:"port"`
+}
@@ -0,0 +1,264 @@
+package wizard
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Field is a value the user is asked for
+type Field struct {
+	Name     string
+	Label    string
+	Hint     string // example of the expected format
+	Default  string
+	Optional bool
+	check    func(value string) error
+}
+
+// Check validates a value for the field. Empty values are accepted only for
+// optional fields.
+func (f Field) Check(value string) error {
+	if value == "" {
+		if f.Optional {
+			return nil
+		}
+		return fmt.Errorf("%s is required", strings.ToLower(f.Label))
+	}
+	if f.check == nil {
+		return nil
+	}
+	return f.check(value)
+}
+
+// Wizard asks for the fields of one kind of object and builds its manifest
+type Wizard struct {
+	Kind        string
+	Aliases     []string // short names kubectl accepts for the kind
+	Description string
+	Fields      []Field
+	build       func(values map[string]string) (any, error)
+}
+
+// Wizards are the kinds of object that can be created with a guided form
+var Wizards = []Wizard{
+	{
+		Kind:        "Deployment",
+		Aliases:     []string{"deploy"},
+		Description: "Pods running a container image, with environment variables and probes",
+		Fields: []Field{
+			// The name is also the container's, which can't contain dots
+			{Name: "name", Label: "Name", check: dnsLabel},
+			namespaceField,
+			{Name: "image", Label: "Container image", Hint: "nginx:1.27", check: image},
+			{Name: "replicas", Label: "Replicas", Default: "1", check: count},
+			{Name: "port", Label: "Container port", Hint: "empty for none", Default: "80", Optional: true, check: portNumber},
+			{Name: "env", Label: "Environment variables", Hint: "MODE=prod, DB_PASSWORD=secret:db/password, LEVEL=configmap:app/level", Optional: true, check: envList},
+			{Name: "readiness", Label: "Readiness probe", Hint: "http:8080/healthz, tcp:5432 or exec:cat /tmp/ready", Optional: true, check: probeSpec},
+			{Name: "liveness", Label: "Liveness probe", Hint: "http:8080/healthz, tcp:5432 or exec:cat /tmp/ready", Optional: true, check: probeSpec},
+			{Name: "cpu", Label: "CPU request", Hint: "250m", Optional: true, check: quantity},
+			{Name: "memory", Label: "Memory request and limit", Hint: "256Mi", Optional: true, check: quantity},
+		},
+		build: buildDeployment,
+	},
+	{
+		Kind:        "Service",
+		Aliases:     []string{"svc"},
+		Description: "Stable address for the pods of an app",
+		Fields: []Field{
+			{Name: "name", Label: "Name", check: dnsLabel},
+			namespaceField,
+			{Name: "selector", Label: "App label of the pods", Hint: "the name of their deployment", check: dnsSubdomain},
+			{Name: "type", Label: "Type", Hint: "ClusterIP, NodePort or LoadBalancer", Default: "ClusterIP", check: oneOf("ClusterIP", "NodePort", "LoadBalancer")},
+			{Name: "ports", Label: "Ports", Hint: "80:8080, 53/UDP - port:targetPort/protocol", Default: "80", check: servicePorts},
+		},
+		build: buildService,
+	},
+	{
+		Kind:        "ConfigMap",
+		Aliases:     []string{"cm"},
+		Description: "Configuration values and files for pods",
+		Fields: []Field{
+			nameField,
+			namespaceField,
+			{Name: "data", Label: "Data", Hint: "MODE=prod, app.conf=@./app.conf", check: dataList},
+		},
+		build: buildConfigMap,
+	},
+	{
+		Kind:        "Ingress",
+		Aliases:     []string{"ing"},
+		Description: "HTTP route from a host name to a service",
+		Fields: []Field{
+			nameField,
+			namespaceField,
+			{Name: "host", Label: "Host", Hint: "app.example.com", check: host},
+			{Name: "path", Label: "Path", Default: "/", check: path},
+			{Name: "service", Label: "Backend service", check: dnsLabel},
+			{Name: "port", Label: "Backend service port", Hint: "number or name", Default: "80", check: portRef},
+			{Name: "className", Label: "Ingress class", Hint: "empty for the cluster default", Optional: true, check: dnsSubdomain},
+			{Name: "tls", Label: "TLS secret", Hint: "empty for plain HTTP", Optional: true, check: dnsSubdomain},
+		},
+		build: buildIngress,
+	},
+}
+
+var (
+	nameField      = Field{Name: "name", Label: "Name", check: dnsSubdomain}
+	namespaceField = Field{Name: "namespace", Label: "Namespace", Hint: "empty for the terminal default", Optional: true, check: dnsLabel}
+)
+
+// Find returns the wizard of a kind, given like kubectl accepts it: in any
+// case, plural or by a short name
+func Find(kind string) (int, bool) {
+	kind = strings.ToLower(kind)
+	for i, w := range Wizards {
+		name := strings.ToLower(w.Kind)
+		if kind == name || kind == name+"s" || kind == name+"es" {
+			return i, true
+		}
+		for _, alias := range w.Aliases {
+			if kind == alias {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Manifest checks the values of every field and builds the YAML manifest
+func (w Wizard) Manifest(values map[string]string) (string, error) {
+	for _, field := range w.Fields {
+		if err := field.Check(values[field.Name]); err != nil {
+			return "", fmt.Errorf("%s: %v", field.Label, err)
+		}
+	}
+	object, err := w.build(values)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(object); err != nil {
+		return "", fmt.Errorf("failed to write the %s manifest: %v", w.Kind, err)
+	}
+	encoder.Close()
+	return out.String(), nil
+}
+
+func buildDeployment(values map[string]string) (any, error) {
+	name := values["name"]
+	replicas, _ := strconv.Atoi(values["replicas"])
+	c := container{Name: name, Image: values["image"]}
+	if values["port"] != "" {
+		port, _ := parsePort(values["port"])
+		c.Ports = []containerPort{{ContainerPort: port}}
+	}
+	env, err := parseEnv(values["env"])
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range env {
+		c.Env = append(c.Env, v.spec())
+	}
+	if values["readiness"] != "" {
+		c.ReadinessProbe, _ = parseProbe(values["readiness"])
+	}
+	if values["liveness"] != "" {
+		c.LivenessProbe, _ = parseProbe(values["liveness"])
+	}
+	if values["cpu"] != "" || values["memory"] != "" {
+		c.Resources = &resources{Requests: map[string]string{}}
+		if values["cpu"] != "" {
+			c.Resources.Requests["cpu"] = values["cpu"]
+		}
+		if values["memory"] != "" {
+			c.Resources.Requests["memory"] = values["memory"]
+			c.Resources.Limits = map[string]string{"memory": values["memory"]}
+		}
+	}
+
+	labels := map[string]string{"app": name}
+	return object{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Metadata:   metadata{Name: name, Namespace: values["namespace"], Labels: labels},
+		Spec: deploymentSpec{
+			Replicas: replicas,
+			Selector: selector{MatchLabels: labels},
+			Template: podTemplate{
+				Metadata: metadata{Labels: labels},
+				Spec:     podSpec{Containers: []container{c}},
+			},
+		},
+	}, nil
+}
+
+func buildService(values map[string]string) (any, error) {
+	ports, err := parseServicePorts(values["ports"])
+	if err != nil {
+		return nil, err
+	}
+	return object{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Metadata:   metadata{Name: values["name"], Namespace: values["namespace"]},
+		Spec: serviceSpec{
+			Type:     values["type"],
+			Selector: map[string]string{"app": values["selector"]},
+			Ports:    ports,
+		},
+	}, nil
+}
+
+func buildConfigMap(values map[string]string) (any, error) {
+	data, err := parseData(values["data"])
+	if err != nil {
+		return nil, err
+	}
+	return object{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata:   metadata{Name: values["name"], Namespace: values["namespace"]},
+		Data:       data,
+	}, nil
+}
+
+func buildIngress(values map[string]string) (any, error) {
+	spec := ingressSpec{
+		IngressClassName: values["className"],
+		Rules: []ingressRule{{
+			Host: values["host"],
+			HTTP: ingressHTTP{Paths: []ingressPath{{
+				Path:     values["path"],
+				PathType: "Prefix",
+				Backend: ingressBackend{Service: ingressService{
+					Name: values["service"],
+					Port: ingressPort(values["port"]),
+				}},
+			}}},
+		}},
+	}
+	if values["tls"] != "" {
+		spec.TLS = []ingressTLS{{Hosts: []string{values["host"]}, SecretName: values["tls"]}}
+	}
+	return object{
+		APIVersion: "networking.k8s.io/v1",
+		Kind:       "Ingress",
+		Metadata:   metadata{Name: values["name"], Namespace: values["namespace"]},
+		Spec:       spec,
+	}, nil
+}
+
+// ingressPort refers to a service port by number or by name
+func ingressPort(value string) map[string]any {
+	if n, err := strconv.Atoi(value); err == nil {
+		return map[string]any{"number": n}
+	}
+	return map[string]any{"name": value}
+}
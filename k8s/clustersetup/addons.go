@@ -0,0 +1,246 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// addons.go installs, checks and removes the addons listed in the config once the cluster is up.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Built-in addons.
+const (
+	AddonMetricsServer = "metrics-server"
+	AddonIngressNginx  = "ingress-nginx"
+	AddonCertManager   = "cert-manager"
+)
+
+// addonReadyTimeout bounds how long the deployments of an addon get to
+// become available after it is applied.
+const addonReadyTimeout = 5 * time.Minute
+
+// Addon is a component installed into the cluster after it is validated,
+// from upstream manifests applied on the controller.
+type Addon interface {
+	// Name is the name the addons list of the config refers to it by.
+	Name() string
+	// Manifests returns the URLs of the manifests that install it, in the
+	// order they are applied.
+	Manifests() []string
+	// Deployments returns the deployments, as namespace/name, that are
+	// available while it is healthy.
+	Deployments() []string
+}
+
+// manifestAddon is an addon installed from released manifests.
+type manifestAddon struct {
+	name        string
+	manifests   []string
+	deployments []string
+}
+
+func (a manifestAddon) Name() string { return a.name }
+
+func (a manifestAddon) Manifests() []string { return a.manifests }
+
+func (a manifestAddon) Deployments() []string { return a.deployments }
+
+// addonCatalog holds the built-in addons by name.
+var addonCatalog = map[string]Addon{
+	AddonMetricsServer: manifestAddon{
+		name:        AddonMetricsServer,
+		manifests:   []string{"https://github.com/kubernetes-sigs/metrics-server/releases/download/v0.6.4/components.yaml"},
+		deployments: []string{"kube-system/metrics-server"},
+	},
+	// The bare-metal manifest exposes the controller on a NodePort of every worker
+	AddonIngressNginx: manifestAddon{
+		name:        AddonIngressNginx,
+		manifests:   []string{"https://raw.githubusercontent.com/kubernetes/ingress-nginx/controller-v1.8.2/deploy/static/provider/baremetal/deploy.yaml"},
+		deployments: []string{"ingress-nginx/ingress-nginx-controller"},
+	},
+	AddonCertManager: manifestAddon{
+		name:        AddonCertManager,
+		manifests:   []string{"https://github.com/cert-manager/cert-manager/releases/download/v1.13.1/cert-manager.yaml"},
+		deployments: []string{"cert-manager/cert-manager", "cert-manager/cert-manager-cainjector", "cert-manager/cert-manager-webhook"},
+	},
+}
+
+// AddonCatalog returns the names of the built-in addons, sorted.
+func AddonCatalog() []string {
+	names := make([]string, 0, len(addonCatalog))
+	for name := range addonCatalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupAddon returns the built-in addon of the given name.
+func LookupAddon(name string) (Addon, bool) {
+	addon, ok := addonCatalog[name]
+	return addon, ok
+}
+
+// validateAddons checks that the listed addons are built in and listed
+// once. Each addon serves an aggregated API or admission webhook the API
+// servers call, so, like the webhook smoke test, addons need the bridge
+// provider to route pod IPs to the controllers.
+func validateAddons(config ClusterConfig) error {
+	listed := map[string]bool{}
+	for _, name := range config.Addons {
+		if _, ok := addonCatalog[name]; !ok {
+			return fmt.Errorf("unknown addon %q; use %s", name, strings.Join(AddonCatalog(), ", "))
+		}
+		if listed[name] {
+			return fmt.Errorf("addon %s is listed twice", name)
+		}
+		listed[name] = true
+	}
+	if len(config.Addons) > 0 && config.CNIProvider != "" && config.CNIProvider != CNIBridge {
+		return fmt.Errorf("addons need the %s provider, which routes pod IPs to the controllers", CNIBridge)
+	}
+	return nil
+}
+
+// addons returns the addons listed in the config, in order.
+func (cm *ClusterManager) addons() []Addon {
+	var addons []Addon
+	for _, name := range cm.config.Addons {
+		if addon, ok := addonCatalog[name]; ok {
+			addons = append(addons, addon)
+		}
+	}
+	return addons
+}
+
+// addonChanges returns the built-in addons listed in changed but not in
+// applied, and those listed in applied but not in changed.
+func addonChanges(applied, changed []string) (added, dropped []Addon) {
+	for _, name := range changed {
+		if addon, ok := addonCatalog[name]; ok && !contains(applied, name) {
+			added = append(added, addon)
+		}
+	}
+	for _, name := range applied {
+		if addon, ok := addonCatalog[name]; ok && !contains(changed, name) {
+			dropped = append(dropped, addon)
+		}
+	}
+	return added, dropped
+}
+
+// addonDownloads returns the URLs of the manifests of the listed addons.
+func (cm *ClusterManager) addonDownloads() []string {
+	var urls []string
+	for _, addon := range cm.addons() {
+		urls = append(urls, addon.Manifests()...)
+	}
+	return urls
+}
+
+// addonManifestPath is where a manifest of an addon is put on the controller.
+func addonManifestPath(addon Addon, index int) string {
+	return fmt.Sprintf("/tmp/addon-%s-%d.yaml", addon.Name(), index)
+}
+
+// installAddons installs the listed addons from the controller and waits
+// for each to become healthy.
+func (cm *ClusterManager) installAddons(ctx context.Context) error {
+	addons := cm.addons()
+	if len(addons) == 0 {
+		return nil
+	}
+	var steps []string
+	for _, addon := range addons {
+		steps = append(steps, "Installing "+addon.Name())
+	}
+	progress := cm.nodeProgress(cm.config.Controller.Name, steps)
+	for _, addon := range addons {
+		progress.advance()
+		if err := cm.InstallAddon(ctx, addon); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InstallAddon applies the manifests of an addon from the controller and
+// waits for its deployments to become available.
+func (cm *ClusterManager) InstallAddon(ctx context.Context, addon Addon) error {
+	controller := cm.config.Controller
+	if err := cm.stageDownloads(ctx, controller, addon.Manifests()); err != nil {
+		return fmt.Errorf("failed to install %s: %w", addon.Name(), err)
+	}
+	var script []string
+	for i, url := range addon.Manifests() {
+		path := addonManifestPath(addon, i)
+		script = append(script, cm.fetchCommand(url, path),
+			fmt.Sprintf("kubectl apply -f %s --kubeconfig /var/lib/kubernetes/admin.kubeconfig", path))
+	}
+	if _, err := cm.executeScriptStreamed(ctx, controller, strings.Join(script, "\n")); err != nil {
+		return fmt.Errorf("failed to install %s: %w", addon.Name(), err)
+	}
+	if err := cm.waitForAddon(ctx, addon, addonReadyTimeout); err != nil {
+		return fmt.Errorf("%s didn't become healthy: %w", addon.Name(), err)
+	}
+	cm.logger.Info(fmt.Sprintf("Installed the %s addon", addon.Name()))
+	return nil
+}
+
+// RemoveAddon deletes the objects of an addon's manifests, in reverse order,
+// from the controller. Objects already gone are skipped.
+func (cm *ClusterManager) RemoveAddon(ctx context.Context, addon Addon) error {
+	controller := cm.config.Controller
+	if err := cm.stageDownloads(ctx, controller, addon.Manifests()); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", addon.Name(), err)
+	}
+	var script []string
+	manifests := addon.Manifests()
+	for i := len(manifests) - 1; i >= 0; i-- {
+		path := addonManifestPath(addon, i)
+		script = append(script, cm.fetchCommand(manifests[i], path),
+			fmt.Sprintf("kubectl delete -f %s --ignore-not-found --kubeconfig /var/lib/kubernetes/admin.kubeconfig", path))
+	}
+	if _, err := cm.executeScriptStreamed(ctx, controller, strings.Join(script, "\n")); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", addon.Name(), err)
+	}
+	cm.logger.Info(fmt.Sprintf("Removed the %s addon", addon.Name()))
+	return nil
+}
+
+// waitForAddon waits until the deployments of an addon are available. A
+// zero timeout checks them once.
+func (cm *ClusterManager) waitForAddon(ctx context.Context, addon Addon, timeout time.Duration) error {
+	for _, deployment := range addon.Deployments() {
+		namespace, name, _ := strings.Cut(deployment, "/")
+		cmd := fmt.Sprintf("kubectl wait --for=condition=Available deployment/%s -n %s --timeout=%s --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+			name, namespace, timeout)
+		if _, err := cm.sshClient.ExecuteCommand(ctx, cm.config.Controller.IPAddress, cmd); err != nil {
+			return fmt.Errorf("deployment %s is not available: %w", deployment, err)
+		}
+	}
+	return nil
+}
+
+// AddonHealth is the state of an addon listed in the config. Err is set
+// while any of its deployments isn't available.
+type AddonHealth struct {
+	Name string
+	Err  error
+}
+
+// Healthy reports whether every deployment of the addon is available.
+func (h AddonHealth) Healthy() bool {
+	return h.Err == nil
+}
+
+// GetAddonHealth checks the addons listed in the config.
+func (cm *ClusterManager) GetAddonHealth(ctx context.Context) []AddonHealth {
+	var health []AddonHealth
+	for _, addon := range cm.addons() {
+		health = append(health, AddonHealth{Name: addon.Name(), Err: cm.waitForAddon(ctx, addon, 0)})
+	}
+	return health
+}
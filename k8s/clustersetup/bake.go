@@ -0,0 +1,279 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// bake.go prepares single nodes for machine images and detects nodes built from them.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Node roles whose binaries can be baked into a machine image.
+const (
+	RoleController = "controller"
+	RoleEtcd       = "etcd"
+	RoleWorker     = "worker"
+)
+
+// bakeMarkerPath records the role and versions baked into a node image.
+const bakeMarkerPath = "/etc/kube-orchestrator/baked"
+
+// installStep is a download or install step that can be baked into an image.
+// The files at the downloads URLs are fetched into the working directory
+// before the commands run. check, when set, succeeds on nodes that already
+// have what the step installs, so a resumed setup can skip it.
+type installStep struct {
+	name      string
+	downloads []string
+	commands  []string
+	check     string
+}
+
+// etcdInstall downloads and installs the etcd binaries.
+func (cm *ClusterManager) etcdInstall() installStep {
+	return installStep{"Installing etcd", []string{
+		fmt.Sprintf("https://github.com/etcd-io/etcd/releases/download/%s/etcd-%s-linux-amd64.tar.gz", cm.config.EtcdVersion, cm.config.EtcdVersion),
+	}, []string{
+		fmt.Sprintf("tar -xzf etcd-%s-linux-amd64.tar.gz", cm.config.EtcdVersion),
+		fmt.Sprintf("sudo mv etcd-%s-linux-amd64/etcd* /usr/local/bin/", cm.config.EtcdVersion),
+		fmt.Sprintf("rm -f etcd-%s-linux-amd64.tar.gz", cm.config.EtcdVersion),
+	}, fmt.Sprintf("/usr/local/bin/etcd --version | grep -qF 'etcd Version: %s'", strings.TrimPrefix(cm.config.EtcdVersion, "v"))}
+}
+
+// controlPlaneInstall downloads and installs the control plane binaries.
+func (cm *ClusterManager) controlPlaneInstall() installStep {
+	return installStep{"Installing control plane binaries",
+		kubernetesDownloads(cm.config.KubernetesVersion, "kube-apiserver", "kube-controller-manager", "kube-scheduler", "kubectl"),
+		[]string{
+			"chmod +x kube-apiserver kube-controller-manager kube-scheduler kubectl",
+			"sudo mv kube-apiserver kube-controller-manager kube-scheduler kubectl /usr/local/bin/",
+		}, kubernetesBinariesCheck(cm.config.KubernetesVersion, "kube-apiserver", "kube-controller-manager", "kube-scheduler", "kubectl")}
+}
+
+// workerInstalls lists the install steps of a worker of the OS family,
+// matching the first entries of workerSteps.
+func (cm *ClusterManager) workerInstalls(family string) []installStep {
+	return []installStep{
+		dependenciesInstall(family),
+		{"Installing CNI plugins", []string{
+			fmt.Sprintf("https://github.com/containernetworking/plugins/releases/download/%s/cni-plugins-linux-amd64-%s.tgz", cm.config.CNIVersion, cm.config.CNIVersion),
+		}, []string{
+			"sudo mkdir -p /opt/cni/bin",
+			fmt.Sprintf("sudo tar -xzf cni-plugins-linux-amd64-%s.tgz -C /opt/cni/bin/", cm.config.CNIVersion),
+			fmt.Sprintf("rm -f cni-plugins-linux-amd64-%s.tgz", cm.config.CNIVersion),
+		}, fmt.Sprintf("/opt/cni/bin/bridge --version | grep -qF 'CNI bridge plugin %s'", cm.config.CNIVersion)},
+		{"Installing containerd", []string{
+			fmt.Sprintf("https://github.com/containerd/containerd/releases/download/%s/containerd-%s-linux-amd64.tar.gz", cm.config.ContainerdVersion, cm.config.ContainerdVersion),
+			"https://github.com/opencontainers/runc/releases/download/v1.1.7/runc.amd64",
+		}, []string{
+			fmt.Sprintf("sudo tar -xzf containerd-%s-linux-amd64.tar.gz -C /", cm.config.ContainerdVersion),
+			"sudo mv runc.amd64 runc",
+			"chmod +x runc",
+			"sudo mv runc /usr/local/bin/",
+			fmt.Sprintf("rm -f containerd-%s-linux-amd64.tar.gz", cm.config.ContainerdVersion),
+		}, fmt.Sprintf("/bin/containerd --version | grep -qF ' v%s ' && test -x /usr/local/bin/runc", strings.TrimPrefix(cm.config.ContainerdVersion, "v"))},
+		cm.workerKubernetesInstall(),
+	}
+}
+
+// workerKubernetesInstall downloads and installs the Kubernetes binaries of
+// a worker.
+func (cm *ClusterManager) workerKubernetesInstall() installStep {
+	return installStep{"Installing Kubernetes binaries",
+		kubernetesDownloads(cm.config.KubernetesVersion, "kubectl", "kube-proxy", "kubelet"),
+		[]string{
+			"chmod +x kubectl kube-proxy kubelet",
+			"sudo mv kubectl kube-proxy kubelet /usr/local/bin/",
+		}, kubernetesBinariesCheck(cm.config.KubernetesVersion, "kubelet", "kube-proxy", "kubectl")}
+}
+
+// kubernetesDownloads returns the URLs of Kubernetes release binaries.
+func kubernetesDownloads(version string, binaries ...string) []string {
+	var urls []string
+	for _, binary := range binaries {
+		urls = append(urls, fmt.Sprintf("https://storage.googleapis.com/kubernetes-release/release/%s/bin/linux/amd64/%s", version, binary))
+	}
+	return urls
+}
+
+// kubernetesBinariesCheck succeeds when the first binary reports the
+// Kubernetes version and the others are installed.
+func kubernetesBinariesCheck(version string, binaries ...string) string {
+	check := fmt.Sprintf("/usr/local/bin/%s --version | grep -qxF 'Kubernetes %s'", binaries[0], version)
+	for _, binary := range binaries[1:] {
+		check += " && test -x /usr/local/bin/" + binary
+	}
+	return check
+}
+
+// installSteps lists the install steps baked for a role on a node of the OS
+// family. A controller includes etcd unless etcd runs on dedicated nodes.
+func (cm *ClusterManager) installSteps(role, family string) []installStep {
+	switch role {
+	case RoleController:
+		steps := []installStep{cm.controlPlaneInstall()}
+		if !cm.config.Etcd.External() {
+			steps = append([]installStep{cm.etcdInstall()}, steps...)
+		}
+		if cm.config.KubeVIP.Enabled {
+			steps = append(steps, cm.kubeVIPInstall())
+		}
+		return steps
+	case RoleEtcd:
+		return []installStep{cm.etcdInstall()}
+	case RoleWorker:
+		return cm.workerInstalls(family)
+	}
+	return nil
+}
+
+// bakeMarker describes what a baked image of the role contains. Setup only
+// skips installs when the marker on a node matches the config exactly.
+func (cm *ClusterManager) bakeMarker(role string) string {
+	marker := "role=" + role
+	switch role {
+	case RoleController:
+		marker += " kubernetes=" + cm.config.KubernetesVersion
+		if !cm.config.Etcd.External() {
+			marker += " etcd=" + cm.config.EtcdVersion
+		}
+		if cm.config.KubeVIP.Enabled {
+			marker += " kube-vip=" + cm.config.KubeVIP.version()
+		}
+	case RoleEtcd:
+		marker += " etcd=" + cm.config.EtcdVersion
+	case RoleWorker:
+		marker += fmt.Sprintf(" kubernetes=%s containerd=%s cni=%s",
+			cm.config.KubernetesVersion, cm.config.ContainerdVersion, cm.config.CNIVersion)
+	}
+	return marker
+}
+
+// isBaked reports whether the node was built from an image baked for the role
+// with the configured versions.
+func (cm *ClusterManager) isBaked(ctx context.Context, node Node, role string) bool {
+	output, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, "cat "+bakeMarkerPath+" 2>/dev/null || true")
+	if err != nil || strings.TrimSpace(output) != cm.bakeMarker(role) {
+		return false
+	}
+	cm.logger.Info(fmt.Sprintf("%s was built from a baked %s image; skipping installs", node.Name, role))
+	return true
+}
+
+// runInstall fetches the downloads of an install step and runs its commands
+// on a node as one script. A resumed setup skips steps whose result is
+// already on the node. On an air-gapped cluster the downloads are pushed from
+// the bundle, and steps installing packages are skipped once the packages
+// are there, as the node may have no package repositories to reach.
+func (cm *ClusterManager) runInstall(ctx context.Context, node Node, step installStep) error {
+	skippable := cm.resuming || cm.config.AirGapped && len(step.downloads) == 0
+	if skippable && step.check != "" {
+		output, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, "("+step.check+") 2>/dev/null && echo installed || true")
+		if err == nil && strings.TrimSpace(output) == "installed" {
+			cm.logger.Info(fmt.Sprintf("Skipping %s on %s, which is already done", strings.ToLower(step.name), node.Name))
+			return nil
+		}
+	}
+	// The bytes the node receives meanwhile are mostly the downloads
+	var before int64
+	measured := false
+	if cm.metrics != nil {
+		before, measured = cm.receivedBytes(ctx, node)
+	}
+	script := step.commands
+	if len(step.downloads) > 0 {
+		fetch := []string{cm.wget() + " -q --show-progress --https-only --timestamping '" + strings.Join(step.downloads, "' '") + "'"}
+		if cm.config.AirGapped {
+			if err := cm.stageDownloads(ctx, node, step.downloads); err != nil {
+				return fmt.Errorf("failed on %s while %s: %w", node.Name, strings.ToLower(step.name), err)
+			}
+			fetch = nil
+			for _, download := range step.downloads {
+				fetch = append(fetch, cm.fetchCommand(download, path.Base(download)))
+			}
+		}
+		script = append(fetch, script...)
+	}
+	if _, err := cm.executeScriptStreamed(ctx, node, strings.Join(script, "\n")); err != nil {
+		return fmt.Errorf("failed on %s while %s: %w", node.Name, strings.ToLower(step.name), err)
+	}
+	if measured {
+		if after, ok := cm.receivedBytes(ctx, node); ok && after >= before {
+			cm.metrics.download(node.Name, after-before)
+		}
+	}
+	return nil
+}
+
+// ResolveBakeTarget finds the node to bake. The target is the name of a
+// configured node, whose role follows from the config, or "role=ip" for a
+// machine that isn't part of the cluster, such as a template VM.
+func ResolveBakeTarget(config ClusterConfig, target string) (Node, string, error) {
+	target = strings.TrimSpace(target)
+	if role, ip, ok := strings.Cut(target, "="); ok {
+		role, ip = strings.TrimSpace(role), strings.TrimSpace(ip)
+		if role != RoleController && role != RoleEtcd && role != RoleWorker {
+			return Node{}, "", fmt.Errorf("unknown role %q, expected %s, %s or %s", role, RoleController, RoleEtcd, RoleWorker)
+		}
+		if ip == "" {
+			return Node{}, "", fmt.Errorf("no address given for the %s node", role)
+		}
+		return Node{Name: role + "-image", IPAddress: ip}, role, nil
+	}
+	for _, controller := range config.ControlPlane() {
+		if target == controller.Name {
+			return controller, RoleController, nil
+		}
+	}
+	for _, member := range config.Etcd.Members {
+		if target == member.Name {
+			return member, RoleEtcd, nil
+		}
+	}
+	for _, worker := range config.Workers {
+		if target == worker.Name {
+			return worker, RoleWorker, nil
+		}
+	}
+	return Node{}, "", fmt.Errorf("unknown node %q", target)
+}
+
+// BakeNode runs only the download and install steps of a role on a single
+// node and records what was installed, so that setup skips those steps on
+// machines created from a snapshot of the node.
+func (cm *ClusterManager) BakeNode(ctx context.Context, node Node, role string) error {
+	if role != RoleController && role != RoleEtcd && role != RoleWorker {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	steps := cm.installSteps(role, cm.osFamily(ctx, node))
+	names := []string{"Checking node"}
+	for _, step := range steps {
+		names = append(names, step.name)
+	}
+	names = append(names, "Recording image contents")
+
+	cm.tracker = newProgressTracker(len(names))
+	cm.startPhase(1, 1, fmt.Sprintf("Baking %s image on %s", role, node.Name))
+	progress := cm.nodeProgress(node.Name, names)
+
+	progress.advance()
+	if err := cm.checkNode(node); err != nil {
+		return err
+	}
+	for _, step := range steps {
+		progress.advance()
+		if err := cm.runInstall(ctx, node, step); err != nil {
+			return err
+		}
+	}
+
+	progress.advance()
+	markCmd := fmt.Sprintf("sudo mkdir -p /etc/kube-orchestrator && echo '%s' | sudo tee %s > /dev/null", cm.bakeMarker(role), bakeMarkerPath)
+	if _, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, markCmd); err != nil {
+		return fmt.Errorf("failed to record image contents on %s: %w", node.Name, err)
+	}
+	cm.publish(Event{Type: EventNodeCompleted, Phase: "bake", Node: node.Name})
+	cm.logger.Info(fmt.Sprintf("%s is ready to be snapshotted into a %s image", node.Name, role))
+	return nil
+}
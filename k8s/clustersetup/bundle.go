@@ -0,0 +1,320 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// bundle.go downloads the binaries and images of an air-gapped setup locally and pushes them to the nodes.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// BundleDir is the directory in the work directory holding the binaries and
+// images of an air-gapped setup. Binaries are stored under the host and path
+// of their URL, images as archives under images/.
+const BundleDir = "bundle"
+
+// bundleRemoteDir is where bundled files are staged on a node before they
+// are moved into place.
+const bundleRemoteDir = "/var/tmp/k8s-bundle"
+
+// defaultSandboxImage is the pause image air-gapped workers are configured
+// with when no sandbox_image is set, so it matches the one in the bundle.
+const defaultSandboxImage = "registry.k8s.io/pause:3.9"
+
+// testImage runs the deployments and pods validation and upgrades schedule.
+const testImage = "nginx:1.14.2"
+
+// manifestImagePattern matches the images a manifest's containers run.
+var manifestImagePattern = regexp.MustCompile(`(?m)^\s*(?:-\s+)?image:\s*["']?([^\s"']+)`)
+
+// validateAirGap checks that an air-gapped setup can bundle everything it
+// deploys. The cilium CLI pulls the images of its chart on the cluster.
+func validateAirGap(config ClusterConfig) error {
+	if config.AirGapped && config.CNIProvider == CNICilium {
+		return fmt.Errorf("air_gapped doesn't support the %s provider; use %q, %q or %q", CNICilium, CNIBridge, CNICalico, CNIFlannel)
+	}
+	return nil
+}
+
+// sandboxImage returns the pause image workers are configured with, or an
+// empty string to keep containerd's default.
+func (cm *ClusterManager) sandboxImage() string {
+	if cm.config.SandboxImage == "" && cm.config.AirGapped {
+		return defaultSandboxImage
+	}
+	return cm.config.SandboxImage
+}
+
+// bundleDownloads lists the URLs of every binary and manifest the nodes of
+// the cluster download.
+func (cm *ClusterManager) bundleDownloads() []string {
+	steps := append(cm.installSteps(RoleController, ""), cm.installSteps(RoleEtcd, "")...)
+	steps = append(steps, cm.workerInstalls("")...)
+	var urls []string
+	for _, step := range steps {
+		for _, download := range step.downloads {
+			if !contains(urls, download) {
+				urls = append(urls, download)
+			}
+		}
+	}
+	for _, download := range append(cm.cni().downloads(), cm.addonDownloads()...) {
+		if !contains(urls, download) {
+			urls = append(urls, download)
+		}
+	}
+	return urls
+}
+
+// bundleImages lists the images the pods setup deploys run, including those
+// in the manifests of the pod network provider and the addons, which are
+// downloaded to read them.
+func (cm *ClusterManager) bundleImages(ctx context.Context) ([]string, error) {
+	images := []string{cm.sandboxImage(), "coredns/coredns:" + cm.config.CoreDNSVersion, testImage}
+	if a := cm.config.CoreDNS.Autoscaler; a != nil {
+		version := a.Version
+		if version == "" {
+			version = defaultDNSAutoscalerVersion
+		}
+		images = append(images, "registry.k8s.io/cpa/cluster-proportional-autoscaler:"+version)
+	}
+	if cm.config.WebhookSmokeTest {
+		images = append(images, webhookImage)
+	}
+	for _, download := range append(cm.cni().downloads(), cm.addonDownloads()...) {
+		if !strings.HasSuffix(download, ".yaml") && !strings.HasSuffix(download, ".yml") {
+			continue
+		}
+		file, err := cm.bundleFile(ctx, download)
+		if err != nil {
+			return nil, err
+		}
+		// A runbook doesn't download the manifest it lists
+		manifest, err := os.ReadFile(file)
+		if err != nil && cm.runbook != nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		for _, match := range manifestImagePattern.FindAllStringSubmatch(string(manifest), -1) {
+			if !contains(images, match[1]) {
+				images = append(images, match[1])
+			}
+		}
+	}
+	return images, nil
+}
+
+// PrepareBundle downloads every binary, manifest and image an air-gapped
+// setup pushes to the nodes into BundleDir in the work directory. Files
+// already in the bundle are kept, so an interrupted download resumes with
+// the files still missing. Images are pulled and saved with the local docker
+// or podman.
+func (cm *ClusterManager) PrepareBundle(ctx context.Context) error {
+	for _, download := range cm.bundleDownloads() {
+		if _, err := cm.bundleFile(ctx, download); err != nil {
+			return err
+		}
+	}
+	images, err := cm.bundleImages(ctx)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		if _, err := cm.bundleImage(ctx, image); err != nil {
+			return err
+		}
+	}
+	cm.logger.Info(fmt.Sprintf("Bundle in %s holds %d files and %d images",
+		filepath.Join(cm.config.WorkDir, BundleDir), len(cm.bundleDownloads()), len(images)))
+	return nil
+}
+
+// bundlePath returns the path of the file at the URL in the bundle.
+func (cm *ClusterManager) bundlePath(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid download URL %s: %w", rawURL, err)
+	}
+	return filepath.Join(cm.config.WorkDir, BundleDir, u.Host, filepath.FromSlash(u.Path)), nil
+}
+
+// bundleImagePath returns the path of the image's archive in the bundle.
+func (cm *ClusterManager) bundleImagePath(image string) string {
+	name := strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(image) + ".tar"
+	return filepath.Join(cm.config.WorkDir, BundleDir, "images", name)
+}
+
+// bundleFile returns the path of the file at the URL in the bundle,
+// downloading it first unless it's there already.
+func (cm *ClusterManager) bundleFile(ctx context.Context, rawURL string) (string, error) {
+	file, err := cm.bundlePath(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(file); cm.runbook != nil || err == nil && info.Size() > 0 {
+		return file, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+
+	cm.logger.Info(fmt.Sprintf("Downloading %s into the bundle", filepath.Base(file)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", rawURL, resp.Status)
+	}
+
+	// A partial download must not pass for a bundled file
+	partial := file + ".part"
+	out, err := os.Create(partial)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", partial, err)
+	}
+	_, err = io.Copy(out, resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(partial, file)
+	}
+	if err != nil {
+		os.Remove(partial)
+		return "", fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	return file, nil
+}
+
+// bundleImage returns the path of the image's archive in the bundle, pulling
+// and saving it first unless it's there already.
+func (cm *ClusterManager) bundleImage(ctx context.Context, image string) (string, error) {
+	file := cm.bundleImagePath(image)
+	if info, err := os.Stat(file); cm.runbook != nil || err == nil && info.Size() > 0 {
+		return file, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+
+	tool, err := imageTool()
+	if err != nil {
+		return "", err
+	}
+	cm.logger.Info(fmt.Sprintf("Saving image %s into the bundle", image))
+	if output, err := exec.CommandContext(ctx, tool, "pull", image).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to pull %s: %w, output: %s", image, err, string(output))
+	}
+	partial := file + ".part"
+	if output, err := exec.CommandContext(ctx, tool, "save", "-o", partial, image).CombinedOutput(); err != nil {
+		os.Remove(partial)
+		return "", fmt.Errorf("failed to save %s: %w, output: %s", image, err, string(output))
+	}
+	if err := os.Rename(partial, file); err != nil {
+		return "", fmt.Errorf("failed to save %s: %w", image, err)
+	}
+	return file, nil
+}
+
+// imageTool returns the local tool that pulls and saves images.
+func imageTool() (string, error) {
+	for _, tool := range []string{"docker", "podman"} {
+		if found, err := exec.LookPath(tool); err == nil {
+			return found, nil
+		}
+	}
+	return "", fmt.Errorf("bundling images needs docker or podman on this machine")
+}
+
+// stage uploads files from the bundle to bundleRemoteDir on a node.
+func (cm *ClusterManager) stage(ctx context.Context, node Node, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	if _, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, "sudo mkdir -p "+bundleRemoteDir); err != nil {
+		return fmt.Errorf("failed to create %s on %s: %w", bundleRemoteDir, node.Name, err)
+	}
+	for _, file := range files {
+		if err := cm.sshClient.CopyFile(ctx, node.IPAddress, file, bundleRemoteDir+"/"+filepath.Base(file)); err != nil {
+			return fmt.Errorf("failed to copy %s to %s: %w", filepath.Base(file), node.Name, err)
+		}
+	}
+	return nil
+}
+
+// stageDownloads uploads the files at the URLs from the bundle to a node of
+// an air-gapped cluster, where fetchCommand picks them up.
+func (cm *ClusterManager) stageDownloads(ctx context.Context, node Node, urls []string) error {
+	if !cm.config.AirGapped {
+		return nil
+	}
+	var files []string
+	for _, download := range urls {
+		file, err := cm.bundleFile(ctx, download)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+	return cm.stage(ctx, node, files)
+}
+
+// fetchCommand returns the command that puts the file at the URL at dest on a
+// node: a download through any proxy, or on an air-gapped cluster a copy of
+// the staged file.
+func (cm *ClusterManager) fetchCommand(rawURL, dest string) string {
+	if !cm.config.AirGapped {
+		return fmt.Sprintf("%s -q -O %s '%s'", cm.wget(), dest, rawURL)
+	}
+	staged := bundleRemoteDir + "/" + path.Base(rawURL)
+	return fmt.Sprintf("cp %s %s && sudo rm -f %s", staged, dest, staged)
+}
+
+// importImages loads the bundled images into containerd on a worker of an
+// air-gapped cluster, waiting for containerd to accept requests first.
+func (cm *ClusterManager) importImages(ctx context.Context, worker Node) error {
+	if !cm.config.AirGapped {
+		return nil
+	}
+	images, err := cm.bundleImages(ctx)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, image := range images {
+		file, err := cm.bundleImage(ctx, image)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+	if err := cm.stage(ctx, worker, files); err != nil {
+		return err
+	}
+
+	script := []string{"for i in $(seq 1 30); do sudo ctr version >/dev/null 2>&1 && break; sleep 1; done"}
+	for _, file := range files {
+		staged := bundleRemoteDir + "/" + filepath.Base(file)
+		script = append(script, fmt.Sprintf("sudo ctr -n k8s.io images import %s && sudo rm -f %s", staged, staged))
+	}
+	if _, err := cm.executeScriptStreamed(ctx, worker, strings.Join(script, "\n")); err != nil {
+		return fmt.Errorf("failed to import images on %s: %w", worker.Name, err)
+	}
+	return nil
+}
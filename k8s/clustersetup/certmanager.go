@@ -6,8 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-
-	"encoding/pem"
+	"time"
 
 	cfssl_config "github.com/cloudflare/cfssl/config"
 	"github.com/cloudflare/cfssl/csr"
@@ -89,23 +88,12 @@ func (cm *RealCertificateManager) GenerateClientCert(workDir, name string, confi
 		KeyRequest: &csr.KeyRequest{A: "ecdsa", S: 256},
 	}
 	
-	// Generate CSR and private key
-	generator := &csr.Generator{Validator: nil}
-	csrBytes, key, err := generator.ProcessRequest(req)
+	// Generate PEM encoded CSR and private key
+	pemCSRBytes, key, err := csr.ParseRequest(req)
 	if err != nil {
 		return fmt.Errorf("failed to generate CSR for %s: %w", name, err)
 	}
 
-	// Encode CSR to PEM
-	pemCSR := &pem.Block{
-		Type:  "CERTIFICATE REQUEST",
-		Bytes: csrBytes,
-	}
-	pemCSRBytes := pem.EncodeToMemory(pemCSR)
-	if pemCSRBytes == nil {
-		return fmt.Errorf("failed to encode CSR to PEM for %s", name)
-	}
-
 	// Load CA config
 	caConfigBytes, err := os.ReadFile(filepath.Join(workDir, "ca-config.json"))
 	if err != nil {
@@ -148,21 +136,12 @@ func (cm *RealCertificateManager) GenerateClientCert(workDir, name string, confi
 		Profile: "kubernetes",
 	}
 	
-	certBytes, err := s.Sign(signReq)
+	// The signer returns a PEM encoded certificate
+	pemCertBytes, err := s.Sign(signReq)
 	if err != nil {
 		return fmt.Errorf("failed to sign certificate for %s: %w", name, err)
 	}
 
-	// Encode certificate to PEM format
-	pemCert := &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: certBytes,
-	}
-	pemCertBytes := pem.EncodeToMemory(pemCert)
-	if pemCSRBytes == nil {
-		return fmt.Errorf("failed to encode certificate to PEM for %s", name)
-	}
-
 	// Write certificate and key files
 	if err := os.WriteFile(filepath.Join(workDir, name+".pem"), pemCertBytes, 0644); err != nil {
 		return fmt.Errorf("failed to write certificate for %s: %w", name, err)
@@ -188,23 +167,12 @@ func (cm *RealCertificateManager) GenerateServerCert(workDir, name string, hosts
 		Hosts:      hosts,
 	}
 	
-	// Generate CSR and private key
-	generator := &csr.Generator{Validator: nil}
-	csrBytes, key, err := generator.ProcessRequest(req)
+	// Generate PEM encoded CSR and private key
+	pemCSRBytes, key, err := csr.ParseRequest(req)
 	if err != nil {
 		return fmt.Errorf("failed to generate CSR for %s: %w", name, err)
 	}
 
-	// Encode CSR to PEM
-	pemCSR := &pem.Block{
-		Type:  "CERTIFICATE REQUEST",
-		Bytes: csrBytes,
-	}
-	pemCSRBytes := pem.EncodeToMemory(pemCSR)
-	if pemCSRBytes == nil {
-		return fmt.Errorf("failed to encode CSR to PEM for %s", name)
-	}
-
 	// Load CA config
 	caConfigBytes, err := os.ReadFile(filepath.Join(workDir, "ca-config.json"))
 	if err != nil {
@@ -247,21 +215,12 @@ func (cm *RealCertificateManager) GenerateServerCert(workDir, name string, hosts
 		Profile: "kubernetes",
 	}
 	
-	certBytes, err := s.Sign(signReq)
+	// The signer returns a PEM encoded certificate
+	pemCertBytes, err := s.Sign(signReq)
 	if err != nil {
 		return fmt.Errorf("failed to sign certificate for %s: %w", name, err)
 	}
 
-	// Encode certificate to PEM format
-	pemCert := &pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: certBytes,
-	}
-	pemCertBytes := pem.EncodeToMemory(pemCert)
-	if pemCertBytes == nil {
-		return fmt.Errorf("failed to encode certificate to PEM for %s", name)
-	}
-
 	// Write certificate and key files
 	if err := os.WriteFile(filepath.Join(workDir, name+".pem"), pemCertBytes, 0644); err != nil {
 		return fmt.Errorf("failed to write certificate for %s: %w", name, err)
@@ -270,4 +229,16 @@ func (cm *RealCertificateManager) GenerateServerCert(workDir, name string, hosts
 		return fmt.Errorf("failed to write key for %s: %w", name, err)
 	}
 	return nil
-}
\ No newline at end of file
+}
+// readCertificateExpiry returns the NotAfter time of a PEM certificate file.
+func readCertificateExpiry(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read certificate %s: %w", path, err)
+	}
+	cert, err := helpers.ParseCertificatePEM(data)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse certificate %s: %w", path, err)
+	}
+	return cert.NotAfter, nil
+}
@@ -0,0 +1,330 @@
+// Package clustersetuptest provides test doubles for the clustersetup interfaces.
+// fakes.go contains an SSH client, logger and progress reporter that record
+// what they receive and are safe for concurrent use.
+package clustersetuptest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/RaymondAkachi/custom-kub-cli/k8s/clustersetup"
+)
+
+var (
+	_ clustersetup.SSHClient                  = (*SSHClient)(nil)
+	_ clustersetup.Logger                     = (*Logger)(nil)
+	_ clustersetup.StructuredProgressReporter = (*ProgressReporter)(nil)
+)
+
+// DefaultResponse is returned for commands without a configured response.
+const DefaultResponse = "success"
+
+// Command is a command run on a host.
+type Command struct {
+	Host    string
+	Command string
+}
+
+// String formats the command as "host: command".
+func (c Command) String() string {
+	return c.Host + ": " + c.Command
+}
+
+// SSHClient is a fake clustersetup.SSHClient. Commands succeed with
+// DefaultResponse unless a response or error is configured for them, and
+// every command and upload is recorded. "uname -s" answers "Linux" so
+// prerequisite checks pass.
+type SSHClient struct {
+	mu        sync.Mutex
+	commands  []Command
+	responses map[string]string
+	errors    map[string]error
+	handler   func(host, command string) (string, bool, error)
+	uploads   map[string]string
+	owners    map[string]string
+}
+
+// NewSSHClient creates a fake SSH client.
+func NewSSHClient() *SSHClient {
+	return &SSHClient{
+		responses: map[string]string{"uname -s": "Linux\n"},
+		errors:    make(map[string]error),
+		uploads:   make(map[string]string),
+		owners:    make(map[string]string),
+	}
+}
+
+// SetResponse makes an exact command return output.
+func (c *SSHClient) SetResponse(command, output string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses[command] = output
+}
+
+// SetError makes an exact command fail with err.
+func (c *SSHClient) SetError(command string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors[command] = err
+}
+
+// SetHandler answers commands that have no exact response or error. The
+// handler returns false to fall back to DefaultResponse.
+func (c *SSHClient) SetHandler(handler func(host, command string) (output string, ok bool, err error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = handler
+}
+
+// ExecuteCommand records the command and returns its configured result.
+func (c *SSHClient) ExecuteCommand(ctx context.Context, host, command string) (string, error) {
+	c.mu.Lock()
+	c.commands = append(c.commands, Command{Host: host, Command: command})
+	err, failed := c.errors[command]
+	output, answered := c.responses[command]
+	handler := c.handler
+	c.mu.Unlock()
+
+	if failed {
+		return "", err
+	}
+	if answered {
+		return output, nil
+	}
+	if handler != nil {
+		if output, ok, err := handler(host, command); ok {
+			return output, err
+		}
+	}
+	return DefaultResponse, nil
+}
+
+// ExecuteScript runs each non-empty line of the script through
+// ExecuteCommand, stopping at the first that fails, so script commands are
+// recorded and answered like single ones.
+func (c *SSHClient) ExecuteScript(ctx context.Context, host, script string) (string, error) {
+	var output strings.Builder
+	for i, line := range strings.Split(script, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		out, err := c.ExecuteCommand(ctx, host, line)
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", i+1, err)
+		}
+		output.WriteString(out)
+	}
+	return output.String(), nil
+}
+
+// CopyFile records the content of the local file under the remote path.
+func (c *SSHClient) CopyFile(ctx context.Context, host, localPath, remotePath string) error {
+	content, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	return c.CopyContent(ctx, host, string(content), remotePath)
+}
+
+// CopyFileOwned records the content like CopyFile and the owner under the
+// remote path.
+func (c *SSHClient) CopyFileOwned(ctx context.Context, host, localPath, remotePath, owner string) error {
+	if err := c.CopyFile(ctx, host, localPath, remotePath); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.owners[remotePath] = owner
+	return nil
+}
+
+// CopyContent records the content under the remote path.
+func (c *SSHClient) CopyContent(ctx context.Context, host, content, remotePath string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.uploads[remotePath] = content
+	return nil
+}
+
+// Commands returns the commands run so far, in order.
+func (c *SSHClient) Commands() []Command {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Command(nil), c.commands...)
+}
+
+// CommandsOn returns the commands run on a host, in order.
+func (c *SSHClient) CommandsOn(host string) []string {
+	var commands []string
+	for _, cmd := range c.Commands() {
+		if cmd.Host == host {
+			commands = append(commands, cmd.Command)
+		}
+	}
+	return commands
+}
+
+// Ran reports whether any command containing substr was run.
+func (c *SSHClient) Ran(substr string) bool {
+	for _, cmd := range c.Commands() {
+		if strings.Contains(cmd.Command, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Upload returns the last content uploaded to a remote path.
+func (c *SSHClient) Upload(remotePath string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	content, ok := c.uploads[remotePath]
+	return content, ok
+}
+
+// Owner returns the owner an upload was handed to, or "" for root.
+func (c *SSHClient) Owner(remotePath string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.owners[remotePath]
+}
+
+// Uploads returns a copy of every upload, keyed by remote path.
+func (c *SSHClient) Uploads() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	uploads := make(map[string]string, len(c.uploads))
+	for path, content := range c.uploads {
+		uploads[path] = content
+	}
+	return uploads
+}
+
+// Logger is a fake clustersetup.Logger that records formatted messages
+// prefixed with their level, such as "INFO: Setting up etcd...".
+type Logger struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+// NewLogger creates a fake logger.
+func NewLogger() *Logger {
+	return &Logger{}
+}
+
+func (l *Logger) record(level, msg string, args ...interface{}) {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, level+": "+msg)
+}
+
+// Info records an info message.
+func (l *Logger) Info(msg string, args ...interface{}) { l.record("INFO", msg, args...) }
+
+// Error records an error message.
+func (l *Logger) Error(msg string, args ...interface{}) { l.record("ERROR", msg, args...) }
+
+// Debug records a debug message.
+func (l *Logger) Debug(msg string, args ...interface{}) { l.record("DEBUG", msg, args...) }
+
+// Warn records a warning.
+func (l *Logger) Warn(msg string, args ...interface{}) { l.record("WARN", msg, args...) }
+
+// Logs returns the messages recorded so far, in order.
+func (l *Logger) Logs() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.logs...)
+}
+
+// Contains reports whether any recorded message contains substr.
+func (l *Logger) Contains(substr string) bool {
+	for _, line := range l.Logs() {
+		if strings.Contains(line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProgressReporter is a fake clustersetup.StructuredProgressReporter that
+// records phases, status lines and step updates.
+type ProgressReporter struct {
+	mu       sync.Mutex
+	events   []string
+	updates  []clustersetup.ProgressUpdate
+	finished bool
+	success  bool
+}
+
+// NewProgressReporter creates a fake progress reporter.
+func NewProgressReporter() *ProgressReporter {
+	return &ProgressReporter{}
+}
+
+func (p *ProgressReporter) record(event string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+}
+
+// ReportProgress records a phase.
+func (p *ProgressReporter) ReportProgress(step, totalSteps int, phase string) {
+	p.record(fmt.Sprintf("PROGRESS: Step %d/%d: %s", step, totalSteps, phase))
+}
+
+// Start records the start of an operation.
+func (p *ProgressReporter) Start(total int, description string) {
+	p.record(fmt.Sprintf("START: %s (%d)", description, total))
+}
+
+// Update records a status line.
+func (p *ProgressReporter) Update(current int, status string) {
+	p.record(fmt.Sprintf("UPDATE: %s (%d)", status, current))
+}
+
+// Finish records the outcome of the operation.
+func (p *ProgressReporter) Finish(success bool, message string) {
+	status := "SUCCESS"
+	if !success {
+		status = "FAILED"
+	}
+	p.mu.Lock()
+	p.finished, p.success = true, success
+	p.mu.Unlock()
+	p.record(fmt.Sprintf("%s: %s", status, message))
+}
+
+// ReportStep records a step update.
+func (p *ProgressReporter) ReportStep(update clustersetup.ProgressUpdate) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.updates = append(p.updates, update)
+}
+
+// Events returns the phases, status lines and outcomes recorded so far.
+func (p *ProgressReporter) Events() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.events...)
+}
+
+// Updates returns the step updates recorded so far.
+func (p *ProgressReporter) Updates() []clustersetup.ProgressUpdate {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]clustersetup.ProgressUpdate(nil), p.updates...)
+}
+
+// Finished reports whether Finish was called and whether it reported success.
+func (p *ProgressReporter) Finished() (finished, success bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.finished, p.success
+}
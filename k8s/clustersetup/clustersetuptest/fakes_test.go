@@ -0,0 +1,94 @@
+package clustersetuptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFakesConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	client := NewSSHClient()
+	logger := NewLogger()
+	progress := NewProgressReporter()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			host := fmt.Sprintf("10.240.0.%d", i)
+			client.ExecuteCommand(ctx, host, "hostname")
+			client.CopyContent(ctx, host, "content", fmt.Sprintf("/tmp/file-%d", i))
+			logger.Info("configured %s", host)
+			progress.Update(i, host)
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(client.Commands()); got != 20 {
+		t.Errorf("Expected 20 commands, got %d", got)
+	}
+	if got := len(client.Uploads()); got != 20 {
+		t.Errorf("Expected 20 uploads, got %d", got)
+	}
+	if got := len(logger.Logs()); got != 20 || !logger.Contains("INFO: configured 10.240.0.") {
+		t.Errorf("Unexpected logs: %v", logger.Logs())
+	}
+	if got := len(progress.Events()); got != 20 {
+		t.Errorf("Expected 20 progress events, got %d", got)
+	}
+}
+
+func TestSSHClientResponses(t *testing.T) {
+	ctx := context.Background()
+	client := NewSSHClient()
+	client.SetResponse("hostname", "controller-0\n")
+	client.SetError("false", errors.New("exit status 1"))
+	client.SetHandler(func(host, command string) (string, bool, error) {
+		if strings.HasPrefix(command, "kubectl get node ") {
+			return "Ready", true, nil
+		}
+		return "", false, nil
+	})
+
+	if out, _ := client.ExecuteCommand(ctx, "10.240.0.10", "hostname"); out != "controller-0\n" {
+		t.Errorf("Unexpected configured response %q", out)
+	}
+	if _, err := client.ExecuteCommand(ctx, "10.240.0.10", "false"); err == nil {
+		t.Error("Expected the configured error")
+	}
+	if out, _ := client.ExecuteCommand(ctx, "10.240.0.10", "kubectl get node worker-0"); out != "Ready" {
+		t.Errorf("Expected the handler to answer, got %q", out)
+	}
+	if out, _ := client.ExecuteCommand(ctx, "10.240.0.20", "true"); out != DefaultResponse {
+		t.Errorf("Expected the default response, got %q", out)
+	}
+	if got := client.CommandsOn("10.240.0.20"); len(got) != 1 || got[0] != "true" {
+		t.Errorf("Unexpected commands on worker: %v", got)
+	}
+}
+
+func TestSSHClientScripts(t *testing.T) {
+	ctx := context.Background()
+	client := NewSSHClient()
+	client.SetError("false", errors.New("exit status 1"))
+
+	if _, err := client.ExecuteScript(ctx, "10.240.0.20", "hostname\n\ntrue\n"); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+	if got := client.CommandsOn("10.240.0.20"); len(got) != 2 || got[0] != "hostname" || got[1] != "true" {
+		t.Errorf("Expected each script line to run, got %v", got)
+	}
+
+	_, err := client.ExecuteScript(ctx, "10.240.0.21", "true\nfalse\nhostname")
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected the failing line in the error, got %v", err)
+	}
+	if got := client.CommandsOn("10.240.0.21"); len(got) != 2 {
+		t.Errorf("Expected the script to stop at the failing line, got %v", got)
+	}
+}
@@ -0,0 +1,197 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// cni.go sets up the pod network with the bridge plugin or a CNI provider deployed into the cluster.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Pod network providers.
+const (
+	// CNIBridge gives each worker a bridge on its pod CIDR and routes the pod
+	// CIDRs between nodes.
+	CNIBridge  = "bridge"
+	CNICalico  = "calico"
+	CNIFlannel = "flannel"
+	CNICilium  = "cilium"
+)
+
+// defaultCNIProviderVersions are deployed when no cni_provider_version is set.
+var defaultCNIProviderVersions = map[string]string{
+	CNICalico:  "v3.26.1",
+	CNIFlannel: "v0.22.0",
+	CNICilium:  "1.14.2",
+}
+
+// ciliumCLIVersion is the cilium CLI release that installs Cilium.
+const ciliumCLIVersion = "v0.15.8"
+
+// cniInstaller sets up a pod network.
+type cniInstaller interface {
+	// workerConfigs returns the CNI configuration files written to a worker,
+	// by path.
+	workerConfigs(worker Node) map[string]string
+	// podRoutes reports whether each worker's pod CIDR is routed through it
+	// on the other nodes.
+	podRoutes() bool
+	// deployScript returns the commands the controller runs to deploy the
+	// network once the API server is up, if any.
+	deployScript() []string
+	// downloads returns the URLs of the files deployScript fetches.
+	downloads() []string
+}
+
+// validateCNI checks the pod network provider.
+func validateCNI(config ClusterConfig) error {
+	switch config.CNIProvider {
+	case "", CNIBridge:
+		return nil
+	case CNICalico, CNIFlannel, CNICilium:
+	default:
+		return fmt.Errorf("cni_provider must be %q, %q, %q or %q", CNIBridge, CNICalico, CNIFlannel, CNICilium)
+	}
+	if strings.ContainsAny(config.CNIProviderVersion, " \t\"'/") {
+		return fmt.Errorf("cni_provider_version %q is not a valid release", config.CNIProviderVersion)
+	}
+	// The controllers run no kubelet, so only the bridge setup routes pod IPs to them
+	if config.WebhookSmokeTest {
+		return fmt.Errorf("webhook_smoke_test needs the %s provider, which routes pod IPs to the controllers", CNIBridge)
+	}
+	return nil
+}
+
+// cniProvider returns the configured pod network provider.
+func (cm *ClusterManager) cniProvider() string {
+	if cm.config.CNIProvider == "" {
+		return CNIBridge
+	}
+	return cm.config.CNIProvider
+}
+
+// cni returns the installer of the configured pod network.
+func (cm *ClusterManager) cni() cniInstaller {
+	provider := cm.cniProvider()
+	version := cm.config.CNIProviderVersion
+	if version == "" {
+		version = defaultCNIProviderVersions[provider]
+	}
+	switch provider {
+	case CNICalico:
+		return manifestCNI{
+			cm:  cm,
+			url: fmt.Sprintf("https://raw.githubusercontent.com/projectcalico/calico/%s/manifests/calico.yaml", version),
+			// The pool is commented out in the manifest, defaulting to 192.168.0.0/16
+			edits: []string{
+				"s|# - name: CALICO_IPV4POOL_CIDR|- name: CALICO_IPV4POOL_CIDR|",
+				fmt.Sprintf(`s|#   value: "192.168.0.0/16"|  value: "%s"|`, cm.config.PodCIDR),
+			},
+		}
+	case CNIFlannel:
+		return manifestCNI{
+			cm:    cm,
+			url:   fmt.Sprintf("https://github.com/flannel-io/flannel/releases/download/%s/kube-flannel.yml", version),
+			edits: []string{fmt.Sprintf("s|10.244.0.0/16|%s|g", cm.config.PodCIDR)},
+		}
+	case CNICilium:
+		return ciliumCNI{cm: cm, version: version}
+	}
+	return bridgeCNI{cm: cm}
+}
+
+// bridgeCNI connects each worker's pods to a bridge on its pod CIDR.
+type bridgeCNI struct {
+	cm *ClusterManager
+}
+
+func (b bridgeCNI) workerConfigs(worker Node) map[string]string {
+	return map[string]string{
+		"/etc/cni/net.d/10-bridge.conf":   b.cm.generateBridgeNetworkConfig(worker.PodCIDR),
+		"/etc/cni/net.d/99-loopback.conf": b.cm.generateLoopbackNetworkConfig(),
+	}
+}
+
+func (bridgeCNI) podRoutes() bool { return true }
+
+func (bridgeCNI) deployScript() []string { return nil }
+
+func (bridgeCNI) downloads() []string { return nil }
+
+// manifestCNI deploys a provider from its upstream manifest, edited with sed
+// to use the cluster's pod CIDR. The provider's DaemonSet writes the CNI
+// configuration of each worker as it joins.
+type manifestCNI struct {
+	cm    *ClusterManager
+	url   string
+	edits []string
+}
+
+func (m manifestCNI) workerConfigs(worker Node) map[string]string {
+	return map[string]string{"/etc/cni/net.d/99-loopback.conf": m.cm.generateLoopbackNetworkConfig()}
+}
+
+func (manifestCNI) podRoutes() bool { return false }
+
+func (m manifestCNI) deployScript() []string {
+	path := "/tmp/" + m.cm.cniProvider() + ".yaml"
+	script := []string{m.cm.fetchCommand(m.url, path)}
+	for _, edit := range m.edits {
+		script = append(script, fmt.Sprintf("sed -i '%s' %s", edit, path))
+	}
+	return append(script, fmt.Sprintf("kubectl apply -f %s --kubeconfig /var/lib/kubernetes/admin.kubeconfig", path))
+}
+
+func (m manifestCNI) downloads() []string { return []string{m.url} }
+
+// ciliumCNI installs Cilium with the cilium CLI, allocating pod addresses
+// from the cluster's pod CIDR.
+type ciliumCNI struct {
+	cm      *ClusterManager
+	version string
+}
+
+func (c ciliumCNI) workerConfigs(worker Node) map[string]string {
+	return map[string]string{"/etc/cni/net.d/99-loopback.conf": c.cm.generateLoopbackNetworkConfig()}
+}
+
+func (ciliumCNI) podRoutes() bool { return false }
+
+func (c ciliumCNI) deployScript() []string {
+	archive := "cilium-linux-amd64.tar.gz"
+	return []string{
+		c.cm.fetchCommand(c.downloads()[0], "/tmp/"+archive),
+		fmt.Sprintf("sudo tar -xzf /tmp/%s -C /usr/local/bin", archive),
+		fmt.Sprintf("rm -f /tmp/%s", archive),
+		fmt.Sprintf("KUBECONFIG=/var/lib/kubernetes/admin.kubeconfig cilium install --version %s --set ipam.mode=cluster-pool "+
+			"--set ipam.operator.clusterPoolIPv4PodCIDRList=%s --set k8sServiceHost=%s --set k8sServicePort=6443",
+			c.version, c.cm.config.PodCIDR, c.cm.apiServerEndpoint()),
+	}
+}
+
+func (ciliumCNI) downloads() []string {
+	return []string{fmt.Sprintf("https://github.com/cilium/cilium-cli/releases/download/%s/cilium-linux-amd64.tar.gz", ciliumCLIVersion)}
+}
+
+// deployPodNetwork deploys the configured provider from the controller. The
+// workers join afterwards and only become Ready once the provider's agent
+// runs on them, so it's deployed with the control plane.
+func (cm *ClusterManager) deployPodNetwork(ctx context.Context) error {
+	script := cm.cni().deployScript()
+	if len(script) == 0 {
+		return nil
+	}
+	controller := cm.config.Controller
+	cm.nodeProgress(controller.Name, []string{"Deploying " + cm.cniProvider()}).advance()
+	if err := cm.waitForAPIServer(ctx, controller, apiServerReadyTimeout); err != nil {
+		return err
+	}
+	if err := cm.stageDownloads(ctx, controller, cm.cni().downloads()); err != nil {
+		return fmt.Errorf("failed to deploy %s: %w", cm.cniProvider(), err)
+	}
+	if _, err := cm.executeScriptStreamed(ctx, controller, strings.Join(script, "\n")); err != nil {
+		return fmt.Errorf("failed to deploy %s: %w", cm.cniProvider(), err)
+	}
+	cm.logger.Info(fmt.Sprintf("Deployed the %s pod network", cm.cniProvider()))
+	return nil
+}
@@ -4,7 +4,10 @@ package clustersetup
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -51,12 +54,17 @@ func LoadClusterConfig(configPath string) (ClusterConfig, error) {
 	if config.WorkDir == "" {
 		return config, fmt.Errorf("work_dir is required")
 	}
-	if config.SSHKey == "" {
-		return config, fmt.Errorf("ssh_key is required")
+	// An SSH agent can authenticate without a key file
+	if config.SSHKey == "" && !config.Vault.SSHKey.IsSet() && os.Getenv("SSH_AUTH_SOCK") == "" {
+		return config, fmt.Errorf("ssh_key is required unless an SSH agent runs")
 	}
 	if config.SSHUser == "" {
 		return config, fmt.Errorf("ssh_user is required")
 	}
+	if config.BastionHost == "" && (config.BastionUser != "" || config.BastionKey != "") {
+		return config, fmt.Errorf("bastion_user and bastion_key need a bastion_host")
+	}
+	resolveControlPlane(&config)
 	if config.Controller.IPAddress == "" || config.Controller.Name == "" {
 		return config, fmt.Errorf("controller configuration is incomplete")
 	}
@@ -64,22 +72,177 @@ func LoadClusterConfig(configPath string) (ClusterConfig, error) {
 		return config, fmt.Errorf("at least one worker node is required")
 	}
 	for _, worker := range config.Workers {
-		if worker.IPAddress == "" || worker.Name == "" || worker.PodCIDR == "" {
+		if worker.IPAddress == "" || worker.Name == "" {
 			return config, fmt.Errorf("worker %s configuration is incomplete", worker.Name)
 		}
 	}
+	if err := validateNetwork(config); err != nil {
+		return config, fmt.Errorf("invalid network configuration: %w", err)
+	}
+	if err := validateNodeLabels(config); err != nil {
+		return config, fmt.Errorf("invalid node labels: %w", err)
+	}
 	if config.Certificates.Country == "" || config.Certificates.ValidityDays <= 0 {
 		return config, fmt.Errorf("certificate configuration is incomplete")
 	}
+	if strings.ContainsAny(config.SandboxImage, " \t\"'") {
+		return config, fmt.Errorf("sandbox_image %q is not a valid image reference", config.SandboxImage)
+	}
+	switch config.KubeletServingCerts {
+	case "", KubeletServingStatic, KubeletServingBootstrap:
+	default:
+		return config, fmt.Errorf("kubelet_serving_certs must be %q or %q", KubeletServingStatic, KubeletServingBootstrap)
+	}
+	if err := validateOSFamily(config); err != nil {
+		return config, err
+	}
+	if err := validateAirGap(config); err != nil {
+		return config, err
+	}
+	if err := validateProxy(config.Proxy); err != nil {
+		return config, fmt.Errorf("invalid proxy configuration: %w", err)
+	}
+	if err := validateCNI(config); err != nil {
+		return config, fmt.Errorf("invalid pod network configuration: %w", err)
+	}
+	if err := validateControlPlane(config); err != nil {
+		return config, fmt.Errorf("invalid control plane configuration: %w", err)
+	}
+	if err := validateEtcd(config); err != nil {
+		return config, fmt.Errorf("invalid etcd configuration: %w", err)
+	}
+	if err := validateCoreDNS(config.CoreDNS); err != nil {
+		return config, fmt.Errorf("invalid coredns configuration: %w", err)
+	}
+	if err := validateSystemd(config.Systemd); err != nil {
+		return config, fmt.Errorf("invalid systemd configuration: %w", err)
+	}
+	if err := validatePriorityClasses(config); err != nil {
+		return config, fmt.Errorf("invalid priority_classes configuration: %w", err)
+	}
+	if err := validateResourceDefaults(config.ResourceDefaults); err != nil {
+		return config, fmt.Errorf("invalid resource_defaults configuration: %w", err)
+	}
+	if err := validateAddons(config); err != nil {
+		return config, fmt.Errorf("invalid addons configuration: %w", err)
+	}
+	if err := validateLogging(config); err != nil {
+		return config, fmt.Errorf("invalid logging configuration: %w", err)
+	}
+	if err := validateHooks(config); err != nil {
+		return config, fmt.Errorf("invalid hooks configuration: %w", err)
+	}
+	if err := validateNotifications(config.Notifications); err != nil {
+		return config, fmt.Errorf("invalid notifications configuration: %w", err)
+	}
+	if err := validateVault(config.Vault); err != nil {
+		return config, fmt.Errorf("invalid vault configuration: %w", err)
+	}
+
+	// Resolve ~ so configs written on one OS work on Windows and macOS
+	if config.SSHKey, err = expandHome(config.SSHKey); err != nil {
+		return config, fmt.Errorf("failed to resolve ssh_key: %w", err)
+	}
+	if config.BastionKey, err = expandHome(config.BastionKey); err != nil {
+		return config, fmt.Errorf("failed to resolve bastion_key: %w", err)
+	}
+	if config.WorkDir, err = expandHome(config.WorkDir); err != nil {
+		return config, fmt.Errorf("failed to resolve work_dir: %w", err)
+	}
 
 	// Ensure WorkDir exists
 	if err := os.MkdirAll(config.WorkDir, 0755); err != nil {
 		return config, fmt.Errorf("failed to create work directory %s: %w", config.WorkDir, err)
 	}
+	// Allocations are recorded in the work directory to stay stable
+	if err := allocatePodCIDRs(&config); err != nil {
+		return config, fmt.Errorf("failed to allocate pod CIDRs: %w", err)
+	}
 
 	return config, nil
 }
 
+// validateNetwork checks that the pod and service CIDRs are valid and
+// apart, that the pod CIDR of every worker lies within the cluster's without
+// overlapping another's, and that cluster_dns is a service address. Workers
+// without a pod CIDR are left to allocatePodCIDRs.
+func validateNetwork(config ClusterConfig) error {
+	podNet, serviceNet, err := parseNetworks(config)
+	if err != nil {
+		return err
+	}
+	if err := validateNodeCIDRMaskSize(config, podNet); err != nil {
+		return err
+	}
+	dns := net.ParseIP(config.ClusterDNS)
+	if dns == nil {
+		return fmt.Errorf("cluster_dns %q is not an IP address", config.ClusterDNS)
+	}
+	if !serviceNet.Contains(dns) {
+		return fmt.Errorf("cluster_dns %s is outside service_cidr %s", config.ClusterDNS, config.ServiceCIDR)
+	}
+	for i, worker := range config.Workers {
+		if worker.PodCIDR == "" {
+			continue
+		}
+		if err := validateNodeCIDR(worker, podNet, serviceNet, config.Workers[:i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseNetworks parses the pod and service CIDRs, which must not overlap.
+func parseNetworks(config ClusterConfig) (*net.IPNet, *net.IPNet, error) {
+	_, podNet, err := net.ParseCIDR(config.PodCIDR)
+	if err != nil {
+		return nil, nil, fmt.Errorf("pod_cidr %q is not a valid CIDR", config.PodCIDR)
+	}
+	_, serviceNet, err := net.ParseCIDR(config.ServiceCIDR)
+	if err != nil {
+		return nil, nil, fmt.Errorf("service_cidr %q is not a valid CIDR", config.ServiceCIDR)
+	}
+	if overlaps(podNet, serviceNet) {
+		return nil, nil, fmt.Errorf("pod_cidr %s overlaps service_cidr %s", config.PodCIDR, config.ServiceCIDR)
+	}
+	return podNet, serviceNet, nil
+}
+
+// validateNodeCIDR checks that a worker's pod CIDR is a subnet of the
+// cluster's pod CIDR that overlaps neither the service CIDR nor the pod CIDR
+// of another worker.
+func validateNodeCIDR(node Node, podNet, serviceNet *net.IPNet, others []Node) error {
+	_, nodeNet, err := net.ParseCIDR(node.PodCIDR)
+	if err != nil {
+		return fmt.Errorf("pod_cidr %q of %s is not a valid CIDR", node.PodCIDR, node.Name)
+	}
+	if !subnetOf(nodeNet, podNet) {
+		return fmt.Errorf("pod_cidr %s of %s is outside the cluster pod_cidr %s", node.PodCIDR, node.Name, podNet)
+	}
+	if overlaps(nodeNet, serviceNet) {
+		return fmt.Errorf("pod_cidr %s of %s overlaps service_cidr %s", node.PodCIDR, node.Name, serviceNet)
+	}
+	for _, other := range others {
+		_, otherNet, err := net.ParseCIDR(other.PodCIDR)
+		if err == nil && overlaps(nodeNet, otherNet) {
+			return fmt.Errorf("pod_cidr %s of %s overlaps %s of %s", node.PodCIDR, node.Name, other.PodCIDR, other.Name)
+		}
+	}
+	return nil
+}
+
+// overlaps reports whether two networks share any address.
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// subnetOf reports whether every address of inner lies in outer.
+func subnetOf(inner, outer *net.IPNet) bool {
+	innerOnes, innerBits := inner.Mask.Size()
+	outerOnes, outerBits := outer.Mask.Size()
+	return innerBits == outerBits && innerOnes >= outerOnes && outer.Contains(inner.IP)
+}
+
 // GenerateDefaultConfig generates a default cluster configuration.
 func GenerateDefaultConfig() ClusterConfig {
 	return ClusterConfig{
@@ -92,7 +255,7 @@ func GenerateDefaultConfig() ClusterConfig {
 		PodCIDR:           "10.200.0.0/16",
 		ServiceCIDR:       "10.32.0.0/24",
 		ClusterDNS:        "10.32.0.10",
-		WorkDir:           "/tmp/k8s-hard-way",
+		WorkDir:           filepath.Join(os.TempDir(), "k8s-hard-way"),
 		SSHKey:            "~/.ssh/id_rsa",
 		SSHUser:           "ubuntu",
 		Controller: Node{
@@ -141,4 +304,21 @@ func SaveConfig(config ClusterConfig, outputPath string) error {
 		return fmt.Errorf("failed to write config to %s: %w", outputPath, err)
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// expandHome replaces a leading ~ in a local path with the user's home
+// directory. Both / and \ are accepted after the ~ so paths written on any
+// OS resolve correctly.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	if path == "~" {
+		return home, nil
+	}
+	return filepath.Join(home, filepath.FromSlash(strings.ReplaceAll(path[2:], `\`, "/"))), nil
+}
@@ -0,0 +1,69 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// controlplane.go resolves the control plane nodes and the API server endpoint of HA clusters.
+package clustersetup
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ControlPlane returns the nodes running the control plane: every entry of
+// Controllers for an HA cluster, otherwise the single controller. The first
+// node is the primary controller that setup runs kubectl on.
+func (c ClusterConfig) ControlPlane() []Node {
+	if len(c.Controllers) > 0 {
+		return c.Controllers
+	}
+	return []Node{c.Controller}
+}
+
+// HA reports whether the control plane runs on more than one node.
+func (c ClusterConfig) HA() bool {
+	return len(c.ControlPlane()) > 1
+}
+
+// apiServerEndpoint returns the address kubelets, kube-proxy and the admin
+// kubeconfig reach the API servers on: the control plane endpoint in front
+// of an HA control plane, otherwise the primary controller.
+func (cm *ClusterManager) apiServerEndpoint() string {
+	if cm.config.ControlPlaneEndpoint != "" {
+		return cm.config.ControlPlaneEndpoint
+	}
+	return cm.config.Controller.IPAddress
+}
+
+// resolveControlPlane fills in the primary controller from Controllers when
+// only the list is configured.
+func resolveControlPlane(config *ClusterConfig) {
+	if len(config.Controllers) > 0 && config.Controller.Name == "" && config.Controller.IPAddress == "" {
+		config.Controller = config.Controllers[0]
+	}
+}
+
+// validateControlPlane checks that every controller is complete and unique,
+// and that the primary controller heads the list of controllers.
+func validateControlPlane(config ClusterConfig) error {
+	seen := map[string]bool{}
+	for _, worker := range config.Workers {
+		seen[worker.Name], seen[worker.IPAddress] = true, true
+	}
+	for i, controller := range config.Controllers {
+		if controller.Name == "" || controller.IPAddress == "" {
+			return fmt.Errorf("controller %d configuration is incomplete", i)
+		}
+		if seen[controller.Name] || seen[controller.IPAddress] {
+			return fmt.Errorf("controller %s shares its name or address with another node", controller.Name)
+		}
+		seen[controller.Name], seen[controller.IPAddress] = true, true
+	}
+	if len(config.Controllers) > 0 {
+		first := config.Controllers[0]
+		if config.Controller.Name != first.Name || config.Controller.IPAddress != first.IPAddress {
+			return fmt.Errorf("controller must match the first entry of controllers")
+		}
+	}
+	if strings.ContainsAny(config.ControlPlaneEndpoint, " /:") {
+		return fmt.Errorf("control_plane_endpoint %q must be a host name or IP address without a port", config.ControlPlaneEndpoint)
+	}
+	return validateKubeVIP(config)
+}
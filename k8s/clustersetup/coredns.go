@@ -0,0 +1,281 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// coredns.go builds the tunable parts of the CoreDNS deployment.
+package clustersetup
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CoreDNSConfig tunes the CoreDNS deployment. StubDomains maps a domain to
+// the DNS servers that answer for it; Upstreams replaces the nodes'
+// /etc/resolv.conf as the forwarders for every other name. PriorityClassName
+// defaults to system-cluster-critical and also applies to the autoscaler.
+type CoreDNSConfig struct {
+	Replicas          int                  `yaml:"replicas,omitempty"`
+	Resources         ResourceConfig       `yaml:"resources,omitempty"`
+	StubDomains       map[string][]string  `yaml:"stub_domains,omitempty"`
+	Upstreams         []string             `yaml:"upstreams,omitempty"`
+	Autoscaler        *DNSAutoscalerConfig `yaml:"autoscaler,omitempty"`
+	PriorityClassName string               `yaml:"priority_class_name,omitempty"`
+}
+
+// ResourceConfig holds container resource requests and limits.
+type ResourceConfig struct {
+	Requests ResourceList `yaml:"requests,omitempty"`
+	Limits   ResourceList `yaml:"limits,omitempty"`
+}
+
+// ResourceList holds CPU and memory quantities such as "100m" or "170Mi".
+type ResourceList struct {
+	CPU    string `yaml:"cpu,omitempty"`
+	Memory string `yaml:"memory,omitempty"`
+}
+
+// DNSAutoscalerConfig enables the cluster-proportional-autoscaler, which
+// scales CoreDNS linearly with the number of nodes and cores in the cluster.
+type DNSAutoscalerConfig struct {
+	Version         string `yaml:"version,omitempty"`
+	CoresPerReplica int    `yaml:"cores_per_replica,omitempty"`
+	NodesPerReplica int    `yaml:"nodes_per_replica,omitempty"`
+	Min             int    `yaml:"min,omitempty"`
+	Max             int    `yaml:"max,omitempty"`
+}
+
+const (
+	defaultCoreDNSReplicas      = 2
+	defaultDNSAutoscalerVersion = "1.8.9"
+	defaultDNSCoresPerReplica   = 256
+	defaultDNSNodesPerReplica   = 16
+)
+
+// quantityPattern matches the Kubernetes resource quantities accepted here.
+var quantityPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(m|k|M|G|T|Ki|Mi|Gi|Ti)?$`)
+
+// validateCoreDNS checks replica counts, resource quantities and forwarders.
+func validateCoreDNS(config CoreDNSConfig) error {
+	if config.Replicas < 0 {
+		return fmt.Errorf("replicas must not be negative")
+	}
+	for name, quantity := range map[string]string{
+		"requests.cpu":    config.Resources.Requests.CPU,
+		"requests.memory": config.Resources.Requests.Memory,
+		"limits.cpu":      config.Resources.Limits.CPU,
+		"limits.memory":   config.Resources.Limits.Memory,
+	} {
+		if quantity != "" && !quantityPattern.MatchString(quantity) {
+			return fmt.Errorf("resources.%s has invalid quantity %q", name, quantity)
+		}
+	}
+	for domain, servers := range config.StubDomains {
+		if strings.TrimSpace(domain) == "" || strings.ContainsAny(domain, " {}") {
+			return fmt.Errorf("stub domain %q is invalid", domain)
+		}
+		if len(servers) == 0 {
+			return fmt.Errorf("stub domain %s has no servers", domain)
+		}
+		if err := validateForwarders(servers); err != nil {
+			return fmt.Errorf("stub domain %s: %w", domain, err)
+		}
+	}
+	if err := validateForwarders(config.Upstreams); err != nil {
+		return fmt.Errorf("upstreams: %w", err)
+	}
+	if a := config.Autoscaler; a != nil {
+		if a.CoresPerReplica < 0 || a.NodesPerReplica < 0 || a.Min < 0 || a.Max < 0 {
+			return fmt.Errorf("autoscaler settings must not be negative")
+		}
+		if a.Max > 0 && a.Min > a.Max {
+			return fmt.Errorf("autoscaler min %d is greater than max %d", a.Min, a.Max)
+		}
+	}
+	return nil
+}
+
+// validateForwarders rejects empty entries and entries that would break the
+// Corefile.
+func validateForwarders(servers []string) error {
+	for _, server := range servers {
+		if strings.TrimSpace(server) == "" || strings.ContainsAny(server, " {}") {
+			return fmt.Errorf("invalid server %q", server)
+		}
+	}
+	return nil
+}
+
+// coreDNSReplicas returns the configured replica count or the default.
+func (cm *ClusterManager) coreDNSReplicas() int {
+	if cm.config.CoreDNS.Replicas > 0 {
+		return cm.config.CoreDNS.Replicas
+	}
+	return defaultCoreDNSReplicas
+}
+
+// coreDNSResources renders the container resources block, indented for the
+// CoreDNS container, or an empty string when none are configured.
+func (cm *ClusterManager) coreDNSResources() string {
+	var b strings.Builder
+	section := func(name string, list ResourceList) {
+		if list.CPU == "" && list.Memory == "" {
+			return
+		}
+		fmt.Fprintf(&b, "          %s:\n", name)
+		if list.CPU != "" {
+			fmt.Fprintf(&b, "            cpu: %s\n", list.CPU)
+		}
+		if list.Memory != "" {
+			fmt.Fprintf(&b, "            memory: %s\n", list.Memory)
+		}
+	}
+	section("requests", cm.config.CoreDNS.Resources.Requests)
+	section("limits", cm.config.CoreDNS.Resources.Limits)
+	if b.Len() == 0 {
+		return ""
+	}
+	return "        resources:\n" + b.String()
+}
+
+// generateCorefile renders the Corefile, indented for the ConfigMap, with a
+// server block for each stub domain after the cluster zone.
+func (cm *ClusterManager) generateCorefile() string {
+	upstreams := "/etc/resolv.conf"
+	if len(cm.config.CoreDNS.Upstreams) > 0 {
+		upstreams = strings.Join(cm.config.CoreDNS.Upstreams, " ")
+	}
+	corefile := fmt.Sprintf(`    .:53 {
+        errors
+        health
+        kubernetes cluster.local in-addr.arpa ip6.arpa {
+          pods insecure
+          fallthrough in-addr.arpa ip6.arpa
+        }
+        prometheus :9153
+        forward . %s
+        cache 30
+        loop
+        reload
+        loadbalance
+    }
+`, upstreams)
+
+	domains := make([]string, 0, len(cm.config.CoreDNS.StubDomains))
+	for domain := range cm.config.CoreDNS.StubDomains {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		corefile += fmt.Sprintf(`    %s:53 {
+        errors
+        cache 30
+        forward . %s
+    }
+`, domain, strings.Join(cm.config.CoreDNS.StubDomains[domain], " "))
+	}
+	return corefile
+}
+
+// generateDNSAutoscalerManifest generates the cluster-proportional-autoscaler
+// that resizes the CoreDNS deployment, or an empty string when disabled.
+func (cm *ClusterManager) generateDNSAutoscalerManifest() string {
+	a := cm.config.CoreDNS.Autoscaler
+	if a == nil {
+		return ""
+	}
+	version := a.Version
+	if version == "" {
+		version = defaultDNSAutoscalerVersion
+	}
+	cores, nodes := a.CoresPerReplica, a.NodesPerReplica
+	if cores == 0 {
+		cores = defaultDNSCoresPerReplica
+	}
+	if nodes == 0 {
+		nodes = defaultDNSNodesPerReplica
+	}
+	min := a.Min
+	if min == 0 {
+		min = cm.coreDNSReplicas()
+	}
+	params := fmt.Sprintf(`{"coresPerReplica":%d,"nodesPerReplica":%d,"min":%d`, cores, nodes, min)
+	if a.Max > 0 {
+		params += fmt.Sprintf(`,"max":%d`, a.Max)
+	}
+	params += `,"preventSinglePointFailure":true}`
+
+	return fmt.Sprintf(`apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: dns-autoscaler
+  namespace: kube-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: dns-autoscaler
+rules:
+- apiGroups: [""]
+  resources: ["nodes"]
+  verbs: ["list", "watch"]
+- apiGroups: [""]
+  resources: ["replicationcontrollers/scale"]
+  verbs: ["get", "update"]
+- apiGroups: ["apps"]
+  resources: ["deployments/scale", "replicasets/scale"]
+  verbs: ["get", "update"]
+- apiGroups: [""]
+  resources: ["configmaps"]
+  verbs: ["get", "create"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: dns-autoscaler
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: dns-autoscaler
+subjects:
+- kind: ServiceAccount
+  name: dns-autoscaler
+  namespace: kube-system
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: dns-autoscaler
+  namespace: kube-system
+data:
+  linear: '%s'
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dns-autoscaler
+  namespace: kube-system
+  labels:
+    k8s-app: dns-autoscaler
+spec:
+  selector:
+    matchLabels:
+      k8s-app: dns-autoscaler
+  template:
+    metadata:
+      labels:
+        k8s-app: dns-autoscaler
+    spec:
+      serviceAccountName: dns-autoscaler
+      priorityClassName: %s
+      containers:
+      - name: autoscaler
+        image: registry.k8s.io/cpa/cluster-proportional-autoscaler:%s
+        command:
+        - /cluster-proportional-autoscaler
+        - --namespace=kube-system
+        - --configmap=dns-autoscaler
+        - --target=Deployment/coredns
+        - --logtostderr=true
+        - --v=2
+`, params, cm.coreDNSPriorityClassName(), version)
+}
@@ -0,0 +1,128 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// drift.go finds node files changed outside setup and raises drift and certificate expiry alerts.
+package clustersetup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CertificateWarningPeriod is how long before they expire certificates are
+// reported as expiring.
+const CertificateWarningPeriod = 30 * 24 * time.Hour
+
+// NodeDrift is a unit file or config setup generated on a node that no
+// longer holds what the applied config generates, or is missing.
+type NodeDrift struct {
+	Node string
+	Path string
+}
+
+// alerts remembers the drift and expiring certificates already published,
+// so checks that run on a schedule publish each once until it is resolved.
+type alerts struct {
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+// update records the alerts a check found among those of the given kind and
+// returns the ones that are new.
+func (a *alerts) update(kind string, found []string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.active == nil {
+		a.active = map[string]bool{}
+	}
+	current := map[string]bool{}
+	var raised []string
+	for _, name := range found {
+		key := kind + "/" + name
+		current[key] = true
+		if !a.active[key] {
+			raised = append(raised, name)
+		}
+	}
+	for key := range a.active {
+		if strings.HasPrefix(key, kind+"/") && !current[key] {
+			delete(a.active, key)
+		}
+	}
+	for key := range current {
+		a.active[key] = true
+	}
+	return raised
+}
+
+// DetectDrift compares the unit files and configs on every node with those
+// generated from the config the cluster was last set up or changed with,
+// and publishes a drift event for each newly drifted file.
+func (cm *ClusterManager) DetectDrift(ctx context.Context) ([]NodeDrift, error) {
+	applied := cm.config
+	if state, err := LoadSetupState(cm.config.WorkDir); err == nil && state.Config != nil {
+		applied = *state.Config
+	}
+	expected := &ClusterManager{config: applied}
+
+	var drift []NodeDrift
+	for _, node := range applied.Nodes() {
+		files := expected.nodeFiles(node)
+		var paths []string
+		for _, file := range files {
+			paths = append(paths, file.path)
+		}
+		// Missing files print nothing, so they show up as drift too
+		output, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress,
+			fmt.Sprintf("sudo sha256sum %s 2>/dev/null || true", strings.Join(paths, " ")))
+		if err != nil {
+			return drift, fmt.Errorf("failed to check files on %s: %w", node.Name, err)
+		}
+		sums := map[string]string{}
+		for _, line := range strings.Split(output, "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 {
+				sums[fields[1]] = fields[0]
+			}
+		}
+		for _, file := range files {
+			sum := sha256.Sum256([]byte(file.content))
+			if sums[file.path] != hex.EncodeToString(sum[:]) {
+				drift = append(drift, NodeDrift{Node: node.Name, Path: file.path})
+			}
+		}
+	}
+
+	var found []string
+	for _, d := range drift {
+		found = append(found, d.Node+":"+d.Path)
+	}
+	for _, name := range cm.alerts.update("drift", found) {
+		cm.logger.Warn(fmt.Sprintf("Drift detected: %s differs from the applied config", name))
+		cm.publish(Event{Type: EventDriftDetected, Name: name})
+	}
+	return drift, nil
+}
+
+// alertExpiring publishes an expiry event for each certificate newly found
+// to expire within threshold, and returns every such certificate.
+func (cm *ClusterManager) alertExpiring(certificates []CertificateExpiry, threshold time.Duration) []CertificateExpiry {
+	var expiring []CertificateExpiry
+	var found []string
+	byName := map[string]CertificateExpiry{}
+	deadline := time.Now().Add(threshold)
+	for _, cert := range certificates {
+		if cert.NotAfter.Before(deadline) {
+			expiring = append(expiring, cert)
+			found = append(found, cert.Name)
+			byName[cert.Name] = cert
+		}
+	}
+	for _, name := range cm.alerts.update("certificate", found) {
+		cm.logger.Warn(fmt.Sprintf("Certificate %s expires on %s", name, byName[name].NotAfter.Format("2006-01-02")))
+		cm.publish(Event{Type: EventCertificateExpiring, Name: name})
+	}
+	return expiring
+}
@@ -0,0 +1,172 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// etcd.go installs etcd on the controller or on dedicated etcd nodes.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EtcdConfig selects the etcd topology. Without members etcd runs on the
+// controllers (stacked); with members it runs only on those dedicated nodes
+// (external). DataDir and WALDir place the data and the write-ahead log, for
+// example on separate disks.
+type EtcdConfig struct {
+	Members []Node `yaml:"members,omitempty"`
+	DataDir string `yaml:"data_dir,omitempty"`
+	WALDir  string `yaml:"wal_dir,omitempty"`
+}
+
+const defaultEtcdDataDir = "/var/lib/etcd"
+
+// etcdCertificateFiles are the certificates and keys in the work directory
+// that etcd serves and verifies peers with.
+var etcdCertificateFiles = []string{"ca.pem", "kubernetes-key.pem", "kubernetes.pem"}
+
+// External reports whether etcd runs on dedicated nodes.
+func (e EtcdConfig) External() bool {
+	return len(e.Members) > 0
+}
+
+// dataDir returns the configured data directory or the default.
+func (e EtcdConfig) dataDir() string {
+	if e.DataDir != "" {
+		return e.DataDir
+	}
+	return defaultEtcdDataDir
+}
+
+// EtcdNodes returns the nodes running etcd.
+func (c ClusterConfig) EtcdNodes() []Node {
+	if c.Etcd.External() {
+		return c.Etcd.Members
+	}
+	return c.ControlPlane()
+}
+
+// validateEtcd checks that dedicated etcd members are complete and don't
+// reuse the name or address of another node.
+func validateEtcd(config ClusterConfig) error {
+	seen := map[string]bool{}
+	for _, controller := range config.ControlPlane() {
+		seen[controller.Name], seen[controller.IPAddress] = true, true
+	}
+	for _, worker := range config.Workers {
+		seen[worker.Name], seen[worker.IPAddress] = true, true
+	}
+	for i, member := range config.Etcd.Members {
+		if member.Name == "" || member.IPAddress == "" {
+			return fmt.Errorf("member %d configuration is incomplete", i)
+		}
+		if seen[member.Name] || seen[member.IPAddress] {
+			return fmt.Errorf("member %s shares its name or address with another node", member.Name)
+		}
+		seen[member.Name], seen[member.IPAddress] = true, true
+	}
+	for _, dir := range []string{config.Etcd.DataDir, config.Etcd.WALDir} {
+		if dir != "" && !strings.HasPrefix(dir, "/") {
+			return fmt.Errorf("directory %q must be an absolute path", dir)
+		}
+	}
+	if config.Etcd.WALDir != "" && config.Etcd.WALDir == config.Etcd.dataDir() {
+		return fmt.Errorf("wal_dir must differ from data_dir")
+	}
+	return nil
+}
+
+// etcdServers returns the client URLs of every etcd member, comma separated.
+func (cm *ClusterManager) etcdServers() string {
+	var urls []string
+	for _, node := range cm.config.EtcdNodes() {
+		urls = append(urls, fmt.Sprintf("https://%s:2379", node.IPAddress))
+	}
+	return strings.Join(urls, ",")
+}
+
+// etcdInitialCluster returns the --initial-cluster value listing every member.
+func (cm *ClusterManager) etcdInitialCluster() string {
+	var peers []string
+	for _, node := range cm.config.EtcdNodes() {
+		peers = append(peers, fmt.Sprintf("%s=https://%s:2380", node.Name, node.IPAddress))
+	}
+	return strings.Join(peers, ",")
+}
+
+// setupEtcd installs etcd on every member and waits until all of them are
+// active. Members are started without waiting on each other, since a new
+// member only reports ready once the cluster has quorum.
+func (cm *ClusterManager) setupEtcd(ctx context.Context, workDir string) error {
+	cm.logger.Info("Setting up etcd...")
+	members := cm.config.EtcdNodes()
+	role := RoleEtcd
+	if !cm.config.Etcd.External() {
+		role = RoleController
+	}
+	dirs := []string{cm.config.Etcd.dataDir()}
+	if cm.config.Etcd.WALDir != "" {
+		dirs = append(dirs, cm.config.Etcd.WALDir)
+	}
+
+	started := map[string]bool{}
+	for _, member := range members {
+		if cm.nodeDone(ctx, "etcd/"+member.Name, member, len(etcdSteps), etcdServices...) {
+			continue
+		}
+		started[member.Name] = true
+		progress := cm.nodeProgress(member.Name, etcdSteps)
+
+		progress.advance()
+		etcdCommands := []string{
+			"sudo mkdir -p /etc/etcd " + strings.Join(dirs, " "),
+			"sudo groupadd -f etcd",
+			fmt.Sprintf("sudo useradd -g etcd -d %s -s /sbin/nologin -c 'etcd user' etcd || true", cm.config.Etcd.dataDir()),
+			"sudo chown -R etcd:etcd " + strings.Join(dirs, " "),
+			"sudo chmod 700 " + strings.Join(dirs, " "),
+		}
+		if _, err := cm.sshClient.ExecuteScript(ctx, member.IPAddress, strings.Join(etcdCommands, "\n")); err != nil {
+			return fmt.Errorf("failed to prepare etcd directories on %s: %w", member.Name, err)
+		}
+		if !cm.isBaked(ctx, member, role) {
+			if err := cm.runInstall(ctx, member, cm.etcdInstall()); err != nil {
+				return err
+			}
+		}
+
+		// Copy etcd certificates
+		progress.advance()
+		for _, file := range etcdCertificateFiles {
+			localPath := filepath.Join(workDir, file)
+			remotePath := "/etc/etcd/" + file
+			if err := cm.sshClient.CopyFileOwned(ctx, member.IPAddress, localPath, remotePath, "etcd:etcd"); err != nil {
+				return fmt.Errorf("failed to copy %s to %s: %w", file, member.Name, err)
+			}
+		}
+		etcdService := cm.generateEtcdService(member)
+		if err := cm.sshClient.CopyContent(ctx, member.IPAddress, etcdService, "/etc/systemd/system/etcd.service"); err != nil {
+			return fmt.Errorf("failed to upload etcd service to %s: %w", member.Name, err)
+		}
+
+		progress.advance()
+		if _, err := cm.sshClient.ExecuteCommand(ctx, member.IPAddress,
+			"sudo systemctl daemon-reload && sudo systemctl enable etcd && sudo systemctl start etcd --no-block"); err != nil {
+			return fmt.Errorf("failed to start etcd on %s: %w", member.Name, err)
+		}
+	}
+
+	for _, member := range members {
+		if !started[member.Name] {
+			continue
+		}
+		if err := cm.waitForService(ctx, member.IPAddress, "etcd", 30*time.Second); err != nil {
+			return fmt.Errorf("etcd on %s failed to become healthy: %w", member.Name, err)
+		}
+		cm.publish(Event{Type: EventNodeCompleted, Phase: "etcd", Node: member.Name})
+		cm.completeStep("etcd/" + member.Name)
+	}
+
+	cm.logger.Info("etcd setup completed")
+	return nil
+}
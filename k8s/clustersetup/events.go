@@ -0,0 +1,230 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// events.go implements an in-process event bus for setup lifecycle events.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of lifecycle event.
+type EventType string
+
+const (
+	EventPhaseStarted        EventType = "phase_started"
+	EventStepStarted         EventType = "step_started"
+	EventNodeCompleted       EventType = "node_completed"
+	EventCommandFailed       EventType = "command_failed"
+	EventCertificateIssued   EventType = "certificate_issued"
+	EventSetupCompleted      EventType = "setup_completed"
+	EventSetupFailed         EventType = "setup_failed"
+	EventDriftDetected       EventType = "drift_detected"
+	EventCertificateExpiring EventType = "certificate_expiring"
+	EventCommandOutput       EventType = "command_output"
+)
+
+// Event is a single lifecycle event published on the EventBus. Fields that
+// don't apply to an event type are left empty.
+type Event struct {
+	Type       EventType
+	Time       time.Time
+	Cluster    string
+	Phase      string
+	Step       int
+	TotalSteps int
+	Node       string
+	Command    string
+	Name       string
+	Err        error
+	Progress   *ProgressUpdate
+	Output     string
+}
+
+// EventHandler receives published events.
+type EventHandler func(Event)
+
+// EventBus fans out events to subscribed handlers. Handlers are called
+// synchronously in subscription order and should return quickly.
+type EventBus struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers map[int]EventHandler
+	order    []int
+}
+
+// NewEventBus creates an empty event bus.
+func NewEventBus() *EventBus {
+	return &EventBus{handlers: make(map[int]EventHandler)}
+}
+
+// Subscribe registers a handler and returns a function that removes it.
+func (b *EventBus) Subscribe(handler EventHandler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	b.order = append(b.order, id)
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+		for i, existing := range b.order {
+			if existing == id {
+				b.order = append(b.order[:i], b.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Publish delivers an event to every subscribed handler.
+func (b *EventBus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := make([]EventHandler, 0, len(b.order))
+	for _, id := range b.order {
+		handlers = append(handlers, b.handlers[id])
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// Events returns the cluster manager's event bus.
+func (cm *ClusterManager) Events() *EventBus {
+	return cm.events
+}
+
+// publish stamps an event with the cluster name and publishes it.
+func (cm *ClusterManager) publish(event Event) {
+	if cm.events == nil {
+		return
+	}
+	event.Cluster = cm.config.ClusterName
+	cm.events.Publish(event)
+}
+
+// startPhase announces the start of a setup phase.
+func (cm *ClusterManager) startPhase(step, totalSteps int, phase string) {
+	if cm.tracker != nil {
+		cm.tracker.startPhase(step, totalSteps, phase)
+	}
+	cm.publish(Event{Type: EventPhaseStarted, Phase: phase, Step: step, TotalSteps: totalSteps})
+}
+
+// progressSubscriber forwards phase events to a ProgressReporter, and step
+// events too when it implements StructuredProgressReporter.
+func progressSubscriber(progress ProgressReporter) EventHandler {
+	structured, _ := progress.(StructuredProgressReporter)
+	return func(event Event) {
+		switch event.Type {
+		case EventPhaseStarted:
+			progress.ReportProgress(event.Step, event.TotalSteps, event.Phase)
+		case EventStepStarted:
+			if structured != nil && event.Progress != nil {
+				structured.ReportStep(*event.Progress)
+			}
+		}
+	}
+}
+
+// loggerSubscriber logs events that are not already logged at the call site.
+func loggerSubscriber(logger Logger) EventHandler {
+	return func(event Event) {
+		switch event.Type {
+		case EventCommandFailed:
+			logger.Debug(fmt.Sprintf("Command failed on %s: %s: %v", event.Node, event.Command, event.Err))
+		case EventCertificateIssued:
+			logger.Debug(fmt.Sprintf("Certificate issued: %s", event.Name))
+		case EventCommandOutput:
+			logger.Info(fmt.Sprintf("%s | %s", event.Node, event.Output))
+		case EventSetupFailed:
+			logger.Error(fmt.Sprintf("Cluster setup failed: %v", event.Err))
+		}
+	}
+}
+
+// eventSSHClient decorates an SSHClient so failed commands are published
+// and every command's latency and outcome is recorded.
+type eventSSHClient struct {
+	SSHClient
+	publish func(Event)
+	record  func(host string, latency time.Duration, err error)
+}
+
+// done publishes a failed command and records it. Commands skipped or
+// stopped after a cancellation are left out: they didn't fail.
+func (c *eventSSHClient) done(ctx context.Context, host, command string, started time.Time, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		c.publish(Event{Type: EventCommandFailed, Node: host, Command: command, Err: err})
+	}
+	if c.record != nil {
+		c.record(host, time.Since(started), err)
+	}
+}
+
+func (c *eventSSHClient) ExecuteCommand(ctx context.Context, host, command string) (string, error) {
+	started := time.Now()
+	output, err := c.SSHClient.ExecuteCommand(ctx, host, command)
+	c.done(ctx, host, command, started, err)
+	return output, err
+}
+
+func (c *eventSSHClient) ExecuteScript(ctx context.Context, host, script string) (string, error) {
+	started := time.Now()
+	output, err := c.SSHClient.ExecuteScript(ctx, host, script)
+	c.done(ctx, host, script, started, err)
+	return output, err
+}
+
+// ExecuteCommandStream streams the output of the command when the decorated
+// client can, and otherwise runs it like ExecuteCommand.
+func (c *eventSSHClient) ExecuteCommandStream(ctx context.Context, host, command string, output func(line string)) (string, error) {
+	streaming, ok := c.SSHClient.(StreamingSSHClient)
+	if !ok {
+		return c.ExecuteCommand(ctx, host, command)
+	}
+	started := time.Now()
+	result, err := streaming.ExecuteCommandStream(ctx, host, command, output)
+	c.done(ctx, host, command, started, err)
+	return result, err
+}
+
+// ExecuteScriptStream streams the output of the script when the decorated
+// client can, and otherwise runs it like ExecuteScript.
+func (c *eventSSHClient) ExecuteScriptStream(ctx context.Context, host, script string, output func(line string)) (string, error) {
+	streaming, ok := c.SSHClient.(StreamingSSHClient)
+	if !ok {
+		return c.ExecuteScript(ctx, host, script)
+	}
+	started := time.Now()
+	result, err := streaming.ExecuteScriptStream(ctx, host, script, output)
+	c.done(ctx, host, script, started, err)
+	return result, err
+}
+
+// executeScriptStreamed runs a long script on a node, such as downloads and
+// installs, publishing its output lines as they arrive so the setup log
+// shows them in real time.
+func (cm *ClusterManager) executeScriptStreamed(ctx context.Context, node Node, script string) (string, error) {
+	streaming, ok := cm.sshClient.(StreamingSSHClient)
+	if !ok {
+		return cm.sshClient.ExecuteScript(ctx, node.IPAddress, script)
+	}
+	return streaming.ExecuteScriptStream(ctx, node.IPAddress, script, func(line string) {
+		cm.publish(Event{Type: EventCommandOutput, Node: node.Name, Output: line})
+	})
+}
@@ -0,0 +1,171 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// facts.go gathers the hardware and software facts of nodes and caches them in the work directory.
+package clustersetup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// factsDir is the directory in the work directory holding a facts file per node.
+const factsDir = "facts"
+
+// factsCommand prints one key=value line per fact. Facts a node can't
+// report are left out.
+const factsCommand = `echo "cpus=$(nproc 2>/dev/null)"; ` +
+	`echo "memory_kb=$(awk '/^MemTotal:/ {print $2}' /proc/meminfo 2>/dev/null)"; ` +
+	`echo "disk_free_kb=$(df -Pk /var/lib 2>/dev/null | awk 'NR==2 {print $4}')"; ` +
+	`echo "kernel=$(uname -r)"; ` +
+	`echo "arch=$(uname -m)"; ` +
+	`(. /etc/os-release 2>/dev/null && echo "os=$PRETTY_NAME" && echo "os_id=$ID" && echo "os_like=$ID_LIKE" && echo "os_version=$VERSION_ID"); ` +
+	`for runtime in containerd dockerd crio podman; do command -v $runtime >/dev/null 2>&1 && echo "runtime=$runtime"; done; true`
+
+// NodeFacts describes a node's hardware and software. Zero values are facts
+// the node didn't report.
+type NodeFacts struct {
+	Node       string    `json:"node"`
+	CPUs       int       `json:"cpus"`
+	MemoryMB   int       `json:"memory_mb"`
+	DiskFreeMB int       `json:"disk_free_mb"`
+	Kernel     string    `json:"kernel"`
+	Arch       string    `json:"arch"`
+	OS         string    `json:"os"`
+	OSID       string    `json:"os_id"`
+	OSLike     string    `json:"os_like,omitempty"`
+	OSVersion  string    `json:"os_version"`
+	Runtimes   []string  `json:"runtimes,omitempty"`
+	GatheredAt time.Time `json:"gathered_at"`
+}
+
+// nodeRequirements are the resources a node of a role should have. Nodes
+// below them are set up anyway, with a warning.
+type nodeRequirements struct {
+	cpus       int
+	memoryMB   int
+	diskFreeMB int
+}
+
+var (
+	controllerRequirements = nodeRequirements{cpus: 2, memoryMB: 2048, diskFreeMB: 10240}
+	etcdRequirements       = nodeRequirements{cpus: 1, memoryMB: 1024, diskFreeMB: 10240}
+	workerRequirements     = nodeRequirements{cpus: 1, memoryMB: 1024, diskFreeMB: 20480}
+)
+
+// parseFacts parses the output of factsCommand.
+func parseFacts(node, output string) NodeFacts {
+	facts := NodeFacts{Node: node, GatheredAt: time.Now()}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || value == "" {
+			continue
+		}
+		number, _ := strconv.Atoi(value)
+		switch key {
+		case "cpus":
+			facts.CPUs = number
+		case "memory_kb":
+			facts.MemoryMB = number / 1024
+		case "disk_free_kb":
+			facts.DiskFreeMB = number / 1024
+		case "kernel":
+			facts.Kernel = value
+		case "arch":
+			facts.Arch = value
+		case "os":
+			facts.OS = value
+		case "os_id":
+			facts.OSID = value
+		case "os_like":
+			facts.OSLike = strings.Trim(value, `"`)
+		case "os_version":
+			facts.OSVersion = value
+		case "runtime":
+			facts.Runtimes = append(facts.Runtimes, value)
+		}
+	}
+	return facts
+}
+
+// GatherNodeFacts collects the facts of a node and caches them in the work
+// directory.
+func (cm *ClusterManager) GatherNodeFacts(ctx context.Context, node Node) (NodeFacts, error) {
+	output, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, factsCommand)
+	if err != nil {
+		return NodeFacts{}, fmt.Errorf("failed to gather facts of %s: %w", node.Name, err)
+	}
+	facts := parseFacts(node.Name, output)
+
+	data, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		return facts, fmt.Errorf("failed to encode facts of %s: %w", node.Name, err)
+	}
+	dir := filepath.Join(cm.config.WorkDir, factsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return facts, fmt.Errorf("failed to create facts directory: %w", err)
+	}
+	if err := cm.writeFile(filepath.Join(dir, node.Name+".json"), string(data)); err != nil {
+		return facts, fmt.Errorf("failed to cache facts of %s: %w", node.Name, err)
+	}
+	return facts, nil
+}
+
+// LoadNodeFacts returns the facts last gathered for a node from the work
+// directory.
+func LoadNodeFacts(workDir, node string) (NodeFacts, error) {
+	var facts NodeFacts
+	data, err := os.ReadFile(filepath.Join(workDir, factsDir, node+".json"))
+	if err != nil {
+		return facts, fmt.Errorf("no facts gathered for %s: %w", node, err)
+	}
+	if err := json.Unmarshal(data, &facts); err != nil {
+		return facts, fmt.Errorf("failed to parse facts of %s: %w", node, err)
+	}
+	return facts, nil
+}
+
+// checkNodeFacts gathers a node's facts and checks them against its role.
+// The binaries setup downloads are built for x86_64, so other architectures
+// are rejected; a node short of resources or running another container
+// runtime only gets a warning.
+func (cm *ClusterManager) checkNodeFacts(ctx context.Context, node Node, role string, required nodeRequirements) error {
+	facts, err := cm.GatherNodeFacts(ctx, node)
+	if err != nil {
+		cm.logger.Warn(fmt.Sprintf("Skipping resource checks of %s: %v", node.Name, err))
+		return nil
+	}
+	if facts.Arch != "" && facts.Arch != "x86_64" {
+		return fmt.Errorf("node %s is %s; cluster setup only installs x86_64 binaries", node.Name, facts.Arch)
+	}
+
+	var short []string
+	if facts.CPUs > 0 && facts.CPUs < required.cpus {
+		short = append(short, fmt.Sprintf("%d CPUs (recommended %d)", facts.CPUs, required.cpus))
+	}
+	if facts.MemoryMB > 0 && facts.MemoryMB < required.memoryMB {
+		short = append(short, fmt.Sprintf("%d MB of memory (recommended %d)", facts.MemoryMB, required.memoryMB))
+	}
+	if facts.DiskFreeMB > 0 && facts.DiskFreeMB < required.diskFreeMB {
+		short = append(short, fmt.Sprintf("%d MB free in /var/lib (recommended %d)", facts.DiskFreeMB, required.diskFreeMB))
+	}
+	if len(short) > 0 {
+		cm.logger.Warn(fmt.Sprintf("%s %s has only %s", role, node.Name, strings.Join(short, ", ")))
+	}
+
+	if role == RoleWorker {
+		for _, runtime := range facts.Runtimes {
+			if runtime != "containerd" {
+				cm.logger.Warn(fmt.Sprintf("%s already runs %s, which may conflict with the containerd setup installs", node.Name, runtime))
+			}
+		}
+	}
+	if facts.OS != "" {
+		cm.logger.Info(fmt.Sprintf("%s: %s, kernel %s, %d CPUs, %d MB memory", node.Name, facts.OS, facts.Kernel, facts.CPUs, facts.MemoryMB))
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// health.go collects service, etcd and certificate health for status dashboards.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Services run on each node type, as installed by SetupCluster.
+var (
+	etcdServices       = []string{"etcd"}
+	controllerServices = []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler"}
+	workerServices     = []string{"containerd", "kubelet", "kube-proxy"}
+)
+
+// etcdctlCommand lists etcd members using the certificates installed on an etcd node.
+const etcdctlCommand = "sudo ETCDCTL_API=3 etcdctl member list --write-out=simple " +
+	"--endpoints=https://127.0.0.1:2379 --cacert=/etc/etcd/ca.pem " +
+	"--cert=/etc/etcd/kubernetes.pem --key=/etc/etcd/kubernetes-key.pem"
+
+// ServiceHealth is the systemd state of a service on a node.
+type ServiceHealth struct {
+	Node    string
+	Service string
+	State   string
+}
+
+// Healthy reports whether the service is active.
+func (s ServiceHealth) Healthy() bool {
+	return s.State == "active"
+}
+
+// EtcdMember is a member of the etcd cluster as reported by etcdctl.
+type EtcdMember struct {
+	ID        string
+	Status    string
+	Name      string
+	PeerURL   string
+	ClientURL string
+}
+
+// ClusterHealth combines cluster status, service health, etcd membership,
+// certificate expiry, node file drift and the health of the addons. Errors of
+// individual checks are kept so the remaining checks are still shown.
+type ClusterHealth struct {
+	CheckedAt    time.Time
+	Status       ClusterStatus
+	StatusErr    error
+	Services     []ServiceHealth
+	ServicesErr  error
+	EtcdMembers  []EtcdMember
+	EtcdErr      error
+	Certificates []CertificateExpiry
+	CertsErr     error
+	Drift        []NodeDrift
+	DriftErr     error
+	Addons       []AddonHealth
+}
+
+// GetClusterHealth runs every health check against the cluster. Drift and
+// certificates expiring within CertificateWarningPeriod are published as
+// events the first time a check finds them, so webhooks hear about them
+// from dashboards refreshing on a schedule.
+func (cm *ClusterManager) GetClusterHealth(ctx context.Context) ClusterHealth {
+	health := ClusterHealth{CheckedAt: time.Now()}
+	health.Status, health.StatusErr = cm.GetClusterStatus(ctx)
+	health.Services, health.ServicesErr = cm.GetServiceHealth(ctx)
+	health.EtcdMembers, health.EtcdErr = cm.GetEtcdMembers(ctx)
+	health.Certificates, health.CertsErr = cm.certificateExpiries()
+	if health.CertsErr == nil {
+		cm.alertExpiring(health.Certificates, CertificateWarningPeriod)
+	}
+	health.Drift, health.DriftErr = cm.DetectDrift(ctx)
+	health.Addons = cm.GetAddonHealth(ctx)
+	return health
+}
+
+// GetServiceHealth returns the systemd state of the Kubernetes services on every node.
+func (cm *ClusterManager) GetServiceHealth(ctx context.Context) ([]ServiceHealth, error) {
+	var health []ServiceHealth
+	check := func(node Node, services []string) error {
+		// is-active exits non-zero when a service is down, but still prints every state
+		output, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress,
+			fmt.Sprintf("systemctl is-active %s || true", strings.Join(services, " ")))
+		if err != nil {
+			return fmt.Errorf("failed to check services on %s: %w", node.Name, err)
+		}
+		states := strings.Fields(output)
+		for i, service := range services {
+			state := "unknown"
+			if i < len(states) {
+				state = states[i]
+			}
+			health = append(health, ServiceHealth{Node: node.Name, Service: service, State: state})
+		}
+		return nil
+	}
+
+	services := cm.controlPlaneServices()
+	if !cm.config.Etcd.External() {
+		services = append(etcdServices, services...)
+	}
+	for _, controller := range cm.config.ControlPlane() {
+		if err := check(controller, services); err != nil {
+			return health, err
+		}
+	}
+	for _, member := range cm.config.Etcd.Members {
+		if err := check(member, etcdServices); err != nil {
+			return health, err
+		}
+	}
+	for _, worker := range cm.config.Workers {
+		if err := check(worker, workerServices); err != nil {
+			return health, err
+		}
+	}
+	return health, nil
+}
+
+// GetEtcdMembers lists the etcd cluster members from the first etcd node.
+func (cm *ClusterManager) GetEtcdMembers(ctx context.Context) ([]EtcdMember, error) {
+	output, err := cm.sshClient.ExecuteCommand(ctx, cm.config.EtcdNodes()[0].IPAddress, etcdctlCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list etcd members: %w", err)
+	}
+
+	var members []EtcdMember
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		// Format: ID, STATUS, NAME, PEER ADDRS, CLIENT ADDRS, IS LEARNER
+		fields := strings.Split(line, ",")
+		if len(fields) < 5 {
+			continue
+		}
+		members = append(members, EtcdMember{
+			ID:        strings.TrimSpace(fields[0]),
+			Status:    strings.TrimSpace(fields[1]),
+			Name:      strings.TrimSpace(fields[2]),
+			PeerURL:   strings.TrimSpace(fields[3]),
+			ClientURL: strings.TrimSpace(fields[4]),
+		})
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("etcdctl returned no members")
+	}
+	return members, nil
+}
+
+// certificateExpiries returns the expiry of every certificate in WorkDir,
+// soonest first.
+func (cm *ClusterManager) certificateExpiries() ([]CertificateExpiry, error) {
+	paths, err := filepath.Glob(filepath.Join(cm.config.WorkDir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificates: %w", err)
+	}
+
+	var expiries []CertificateExpiry
+	for _, path := range paths {
+		if strings.HasSuffix(path, "-key.pem") {
+			continue
+		}
+		notAfter, err := readCertificateExpiry(path)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".pem")
+		expiries = append(expiries, CertificateExpiry{Name: name, NotAfter: notAfter})
+	}
+
+	sort.Slice(expiries, func(i, j int) bool {
+		return expiries[i].NotAfter.Before(expiries[j].NotAfter)
+	})
+	return expiries, nil
+}
@@ -36,7 +36,7 @@ resources:
 
 // generateKubeconfig creates a kubeconfig file for the specified user or component.
 func (cm *ClusterManager) generateKubeconfig(workDir, name, ip string) error {
-	clusterIP := cm.config.Controller.IPAddress
+	clusterIP := cm.apiServerEndpoint()
 	if ip != "" {
 		clusterIP = ip
 	}
@@ -65,41 +65,120 @@ users:
 	return cm.writeFile(filepath.Join(workDir, name+".kubeconfig"), config)
 }
 
-// generateEtcdService generates the etcd systemd service file.
-func (cm *ClusterManager) generateEtcdService(controller Node) string {
+// RemoteAdminKubeconfigPath returns where setup writes the admin kubeconfig
+// for use outside the node network.
+func RemoteAdminKubeconfigPath(config ClusterConfig) string {
+	return filepath.Join(config.WorkDir, "remote-admin.kubeconfig")
+}
+
+// writeRemoteAdminKubeconfig writes an admin kubeconfig with embedded
+// certificates that targets the controller's public address, falling back to
+// the API server endpoint.
+func (cm *ClusterManager) writeRemoteAdminKubeconfig(workDir string) error {
+	server := cm.config.Controller.PublicAddress
+	if server == "" {
+		server = cm.apiServerEndpoint()
+	}
+	config, err := embeddedAdminKubeconfig(workDir, server, cm.config.ClusterName)
+	if err != nil {
+		return fmt.Errorf("failed to create remote admin kubeconfig: %w", err)
+	}
+
+	path := filepath.Join(workDir, "remote-admin.kubeconfig")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		return fmt.Errorf("failed to write remote admin kubeconfig: %w", err)
+	}
+	cm.logger.Info(fmt.Sprintf("Remote admin kubeconfig written to %s", path))
+	return nil
+}
+
+// embeddedAdminKubeconfig returns an admin kubeconfig for the API server at
+// server that embeds the certificates, so it works away from the work
+// directory.
+func embeddedAdminKubeconfig(workDir, server, clusterName string) (string, error) {
+	var data [3]string
+	for i, file := range []string{"ca.pem", "admin.pem", "admin-key.pem"} {
+		content, err := os.ReadFile(filepath.Join(workDir, file))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		data[i] = base64.StdEncoding.EncodeToString(content)
+	}
+
+	return fmt.Sprintf(`apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: %s
+    server: https://%s:6443
+  name: %s
+contexts:
+- context:
+    cluster: %s
+    user: admin
+  name: %s
+current-context: %s
+kind: Config
+preferences: {}
+users:
+- name: admin
+  user:
+    client-certificate-data: %s
+    client-key-data: %s
+`, data[0], server, clusterName, clusterName, clusterName, clusterName, data[1], data[2]), nil
+}
+
+// generateEtcdService generates the etcd systemd service file for a member.
+func (cm *ClusterManager) generateEtcdService(member Node) string {
+	walDir := ""
+	if cm.config.Etcd.WALDir != "" {
+		walDir = " \\\n  --wal-dir=" + cm.config.Etcd.WALDir
+	}
 	return fmt.Sprintf(`[Unit]
 Description=etcd
 Documentation=https://github.com/etcd-io/etcd
-After=network.target
+After=network.target%s
 
 [Service]
 User=etcd
 Group=etcd
 Type=notify
-ExecStart=/usr/local/bin/etcd \
-  --name %s \
-  --cert-file=/etc/etcd/kubernetes.pem \
-  --key-file=/etc/etcd/kubernetes-key.pem \
-  --peer-cert-file=/etc/etcd/kubernetes.pem \
-  --peer-key-file=/etc/etcd/kubernetes-key.pem \
-  --trusted-ca-file=/etc/etcd/ca.pem \
-  --peer-trusted-ca-file=/etc/etcd/ca.pem \
-  --client-cert-auth \
-  --data-dir=/var/lib/etcd
-Restart=on-failure
-RestartSec=5
+ExecStart=/usr/local/bin/etcd \\
+  --name %s \\
+  --cert-file=/etc/etcd/kubernetes.pem \\
+  --key-file=/etc/etcd/kubernetes-key.pem \\
+  --peer-cert-file=/etc/etcd/kubernetes.pem \\
+  --peer-key-file=/etc/etcd/kubernetes-key.pem \\
+  --trusted-ca-file=/etc/etcd/ca.pem \\
+  --peer-trusted-ca-file=/etc/etcd/ca.pem \\
+  --client-cert-auth \\
+  --peer-client-cert-auth \\
+  --initial-advertise-peer-urls https://%s:2380 \\
+  --listen-peer-urls https://%s:2380 \\
+  --listen-client-urls https://%s:2379,https://127.0.0.1:2379 \\
+  --advertise-client-urls https://%s:2379 \\
+  --initial-cluster-token etcd-%s \\
+  --initial-cluster %s \\
+  --initial-cluster-state new \\
+  --data-dir=%s%s
+%s
 
 [Install]
 WantedBy=multi-user.target
-`, controller.Name)
+`, cm.unitDirectives("etcd"), member.Name, member.IPAddress, member.IPAddress, member.IPAddress, member.IPAddress,
+		cm.config.ClusterName, cm.etcdInitialCluster(), cm.config.Etcd.dataDir(), walDir,
+		cm.serviceDirectives("etcd"))
 }
 
 // generateContainerdConfig generates the containerd configuration.
 func (cm *ClusterManager) generateContainerdConfig() string {
+	sandboxImage := ""
+	if image := cm.sandboxImage(); image != "" {
+		sandboxImage = fmt.Sprintf("    sandbox_image = %q\n", image)
+	}
 	return `version = 2
 [plugins]
   [plugins."io.containerd.grpc.v1.cri"]
-    [plugins."io.containerd.grpc.v1.cri".containerd]
+` + sandboxImage + `    [plugins."io.containerd.grpc.v1.cri".containerd]
       [plugins."io.containerd.grpc.v1.cri".containerd.runtimes]
         [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc]
           runtime_type = "io.containerd.runc.v2"
@@ -108,74 +187,93 @@ func (cm *ClusterManager) generateContainerdConfig() string {
 `
 }
 
-// generateAPIServerService generates the kube-apiserver systemd service file.
-func (cm *ClusterManager) generateAPIServerService() string {
+// generateAPIServerService generates the kube-apiserver systemd service file
+// for a controller.
+func (cm *ClusterManager) generateAPIServerService(controller Node) string {
 	return fmt.Sprintf(`[Unit]
 Description=Kubernetes API Server
 Documentation=https://kubernetes.io/docs/reference/command-line-tools-reference/kube-apiserver/
-After=network.target
+After=network.target%s
 
 [Service]
 ExecStart=/usr/local/bin/kube-apiserver \
   --advertise-address=%s \
   --allow-privileged=true \
-  --apiserver-count=1 \
+  --apiserver-count=%d \
   --authorization-mode=Node,RBAC \
   --bind-address=0.0.0.0 \
   --client-ca-file=/var/lib/kubernetes/ca.pem \
-  --enable-admission-plugins=NodeRestriction \
+  --enable-admission-plugins=NodeRestriction,MutatingAdmissionWebhook,ValidatingAdmissionWebhook \
+  --enable-aggregator-routing=true \
   --etcd-cafile=/var/lib/kubernetes/ca.pem \
   --etcd-certfile=/var/lib/kubernetes/kubernetes.pem \
   --etcd-keyfile=/var/lib/kubernetes/kubernetes-key.pem \
-  --etcd-servers=https://%s:2379 \
+  --etcd-servers=%s \
   --encryption-provider-config=/var/lib/kubernetes/encryption-config.yaml \
   --kubelet-certificate-authority=/var/lib/kubernetes/ca.pem \
   --kubelet-client-certificate=/var/lib/kubernetes/kubernetes.pem \
   --kubelet-client-key=/var/lib/kubernetes/kubernetes-key.pem \
+  --proxy-client-cert-file=/var/lib/kubernetes/front-proxy-client.pem \
+  --proxy-client-key-file=/var/lib/kubernetes/front-proxy-client-key.pem \
+  --requestheader-allowed-names=front-proxy-client \
+  --requestheader-client-ca-file=/var/lib/kubernetes/ca.pem \
+  --requestheader-extra-headers-prefix=X-Remote-Extra- \
+  --requestheader-group-headers=X-Remote-Group \
+  --requestheader-username-headers=X-Remote-User \
   --service-account-key-file=/var/lib/kubernetes/service-account.pem \
   --service-cluster-ip-range=%s \
   --service-node-port-range=30000-32767 \
   --tls-cert-file=/var/lib/kubernetes/kubernetes.pem \
   --tls-private-key-file=/var/lib/kubernetes/kubernetes-key.pem
-Restart=on-failure
-RestartSec=5
+%s
 
 [Install]
 WantedBy=multi-user.target
-`, cm.config.Controller.IPAddress, cm.config.Controller.IPAddress, cm.config.ServiceCIDR)
+`, cm.unitDirectives("kube-apiserver"), controller.IPAddress, len(cm.config.ControlPlane()), cm.etcdServers(), cm.config.ServiceCIDR,
+		cm.serviceDirectives("kube-apiserver"))
 }
 
 // generateControllerManagerService generates the kube-controller-manager systemd service file.
 func (cm *ClusterManager) generateControllerManagerService() string {
+	allocateNodeCIDRs := ""
+	if cm.cniProvider() == CNIFlannel {
+		// flannel gives each node the subnet in its spec.podCIDR
+		allocateNodeCIDRs = "\n  --allocate-node-cidrs=true \\"
+		if cm.config.NodeCIDRMaskSize > 0 {
+			allocateNodeCIDRs += fmt.Sprintf("\n  --node-cidr-mask-size=%d \\", cm.config.NodeCIDRMaskSize)
+		}
+	}
 	return fmt.Sprintf(`[Unit]
 Description=Kubernetes Controller Manager
 Documentation=https://kubernetes.io/docs/reference/command-line-tools-reference/kube-controller-manager/
-After=network.target
+After=network.target%s
 
 [Service]
 ExecStart=/usr/local/bin/kube-controller-manager \
   --bind-address=0.0.0.0 \
-  --cluster-cidr=%s \
+  --cluster-cidr=%s \%s
+  --cluster-signing-cert-file=/var/lib/kubernetes/ca.pem \
+  --cluster-signing-key-file=/var/lib/kubernetes/ca-key.pem \
   --leader-elect=true \
   --service-account-private-key-file=/var/lib/kubernetes/service-account-key.pem \
   --service-cluster-ip-range=%s \
   --use-service-account-credentials=true \
   --v=2 \
   --kubeconfig=/var/lib/kubernetes/kube-controller-manager.kubeconfig
-Restart=on-failure
-RestartSec=5
+%s
 
 [Install]
 WantedBy=multi-user.target
-`, cm.config.PodCIDR, cm.config.ServiceCIDR)
+`, cm.unitDirectives("kube-controller-manager"), cm.config.PodCIDR, allocateNodeCIDRs, cm.config.ServiceCIDR,
+		cm.serviceDirectives("kube-controller-manager"))
 }
 
 // generateSchedulerService generates the kube-scheduler systemd service file.
 func (cm *ClusterManager) generateSchedulerService() string {
-	return `[Unit]
+	return fmt.Sprintf(`[Unit]
 Description=Kubernetes Scheduler
 Documentation=https://kubernetes.io/docs/reference/command-line-tools-reference/kube-scheduler/
-After=network.target
+After=network.target%s
 
 [Service]
 ExecStart=/usr/local/bin/kube-scheduler \
@@ -183,44 +281,46 @@ ExecStart=/usr/local/bin/kube-scheduler \
   --leader-elect=true \
   --v=2 \
   --kubeconfig=/var/lib/kubernetes/kube-scheduler.kubeconfig
-Restart=on-failure
-RestartSec=5
+%s
 
 [Install]
 WantedBy=multi-user.target
-`
+`, cm.unitDirectives("kube-scheduler"), cm.serviceDirectives("kube-scheduler"))
 }
 
 // generateContainerdService generates the containerd systemd service file.
 func (cm *ClusterManager) generateContainerdService() string {
-	return `[Unit]
+	return fmt.Sprintf(`[Unit]
 Description=containerd container runtime
 Documentation=https://containerd.io
-After=network.target
+After=network.target%s
 
 [Service]
 ExecStart=/bin/containerd
-Restart=on-failure
-RestartSec=5
+%s
 Delegate=yes
 KillMode=process
 OOMScoreAdjust=-999
-LimitNOFILE=1048576
-LimitNPROC=infinity
 LimitCORE=infinity
 
 [Install]
 WantedBy=multi-user.target
-`
+`, cm.unitDirectives("containerd"), cm.serviceDirectives("containerd"))
 }
 
-// generateKubeletService generates the kubelet systemd service file.
+// generateKubeletService generates the kubelet systemd service file. A custom
+// sandbox image is passed to the kubelet as well so image garbage collection
+// never removes it, and the worker registers with its labels and taints.
 func (cm *ClusterManager) generateKubeletService(worker Node) string {
+	podInfraImage := ""
+	if image := cm.sandboxImage(); image != "" {
+		podInfraImage = " \\\n  --pod-infra-container-image=" + image
+	}
 	return fmt.Sprintf(`[Unit]
 Description=Kubernetes Kubelet
 Documentation=https://kubernetes.io/docs/reference/command-line-tools-reference/kubelet/
 After=containerd.service
-Requires=containerd.service
+Requires=containerd.service%s
 
 [Service]
 ExecStart=/usr/local/bin/kubelet \
@@ -228,33 +328,31 @@ ExecStart=/usr/local/bin/kubelet \
   --container-runtime-endpoint=unix:///var/run/containerd/containerd.sock \
   --image-pull-progress-deadline=2m \
   --kubeconfig=/var/lib/kubelet/%s.kubeconfig \
-  --network-plugin=cni \
+  --network-plugin=cni%s \
   --register-node=true \
   --v=2
-Restart=on-failure
-RestartSec=5
+%s
 
 [Install]
 WantedBy=multi-user.target
-`, worker.Name)
+`, cm.unitDirectives("kubelet"), worker.Name, podInfraImage+kubeletRegistrationFlags(worker), cm.serviceDirectives("kubelet"))
 }
 
 // generateKubeProxyService generates the kube-proxy systemd service file.
 func (cm *ClusterManager) generateKubeProxyService() string {
-	return `[Unit]
+	return fmt.Sprintf(`[Unit]
 Description=Kubernetes Kube Proxy
 Documentation=https://kubernetes.io/docs/reference/command-line-tools-reference/kube-proxy/
-After=network.target
+After=network.target%s
 
 [Service]
 ExecStart=/usr/local/bin/kube-proxy \
   --config=/var/lib/kube-proxy/kube-proxy-config.yaml
-Restart=on-failure
-RestartSec=5
+%s
 
 [Install]
 WantedBy=multi-user.target
-`
+`, cm.unitDirectives("kube-proxy"), cm.serviceDirectives("kube-proxy"))
 }
 
 // generateBridgeNetworkConfig generates the CNI bridge configuration.
@@ -289,6 +387,11 @@ func (cm *ClusterManager) generateLoopbackNetworkConfig() string {
 
 // generateKubeletConfig generates the kubelet configuration.
 func (cm *ClusterManager) generateKubeletConfig(worker Node) string {
+	serving := "serverTLSBootstrap: true\n"
+	if !cm.kubeletServingBootstrap() {
+		name := kubeletServingCertificateName(worker)
+		serving = fmt.Sprintf("tlsCertFile: /var/lib/kubelet/%s.pem\ntlsPrivateKeyFile: /var/lib/kubelet/%s-key.pem\n", name, name)
+	}
 	return fmt.Sprintf(`apiVersion: kubelet.config.k8s.io/v1beta1
 kind: KubeletConfiguration
 address: %s
@@ -304,7 +407,7 @@ clusterDNS:
 clusterDomain: cluster.local
 podCIDR: %s
 resolvConf: /etc/resolv.conf
-`, worker.IPAddress, cm.config.ClusterDNS, worker.PodCIDR)
+%s`, worker.IPAddress, cm.config.ClusterDNS, worker.PodCIDR, serving)
 }
 
 // generateKubeProxyConfig generates the kube-proxy configuration.
@@ -318,9 +421,10 @@ clusterCIDR: %s
 `, cm.config.PodCIDR)
 }
 
-// generateCoreDNSManifest generates the CoreDNS manifest.
+// generateCoreDNSManifest generates the CoreDNS manifest, followed by the DNS
+// autoscaler when one is configured.
 func (cm *ClusterManager) generateCoreDNSManifest() string {
-	return fmt.Sprintf(`apiVersion: v1
+	manifest := fmt.Sprintf(`apiVersion: v1
 kind: ServiceAccount
 metadata:
   name: coredns
@@ -356,7 +460,7 @@ metadata:
   labels:
     k8s-app: kube-dns
 spec:
-  replicas: 2
+  replicas: %d
   selector:
     matchLabels:
       k8s-app: kube-dns
@@ -366,13 +470,14 @@ spec:
         k8s-app: kube-dns
     spec:
       serviceAccountName: coredns
+      priorityClassName: %s
       containers:
       - name: coredns
         image: coredns/coredns:%s
         args:
         - -conf
         - /etc/coredns/Corefile
-        volumeMounts:
+%s        volumeMounts:
         - name: config-volume
           mountPath: /etc/coredns
       volumes:
@@ -387,21 +492,7 @@ metadata:
   namespace: kube-system
 data:
   Corefile: |
-    .:53 {
-        errors
-        health
-        kubernetes cluster.local in-addr.arpa ip6.arpa {
-          pods insecure
-          fallthrough in-addr.arpa ip6.arpa
-        }
-        prometheus :9153
-        forward . /etc/resolv.conf
-        cache 30
-        loop
-        reload
-        loadbalance
-    }
----
+%s---
 apiVersion: v1
 kind: Service
 metadata:
@@ -426,7 +517,11 @@ spec:
     protocol: TCP
   selector:
     k8s-app: kube-dns
-`, cm.config.CoreDNSVersion, cm.config.ClusterDNS)
+`, cm.coreDNSReplicas(), cm.coreDNSPriorityClassName(), cm.config.CoreDNSVersion, cm.coreDNSResources(), cm.generateCorefile(), cm.config.ClusterDNS)
+	if autoscaler := cm.generateDNSAutoscalerManifest(); autoscaler != "" {
+		manifest += "---\n" + autoscaler
+	}
+	return manifest
 }
 
 // generateTestApplicationManifest generates a test application manifest.
@@ -0,0 +1,203 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// hooks.go runs user-defined commands before and after setup phases.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// HookPoint identifies a point in the setup flow where hooks are run.
+type HookPoint string
+
+const (
+	HookPreSetup         HookPoint = "pre_setup"
+	HookPreControlPlane  HookPoint = "pre_control_plane"
+	HookPostControlPlane HookPoint = "post_control_plane"
+	HookPreWorkers       HookPoint = "pre_workers"
+	HookPostWorkers      HookPoint = "post_workers"
+	HookPreNetworking    HookPoint = "pre_networking"
+	HookPostSetup        HookPoint = "post_setup"
+)
+
+// HooksConfig lists the hooks to run at each hook point.
+type HooksConfig struct {
+	PreSetup         []Hook `yaml:"pre_setup,omitempty"`
+	PreControlPlane  []Hook `yaml:"pre_control_plane,omitempty"`
+	PostControlPlane []Hook `yaml:"post_control_plane,omitempty"`
+	PreWorkers       []Hook `yaml:"pre_workers,omitempty"`
+	PostWorkers      []Hook `yaml:"post_workers,omitempty"`
+	PreNetworking    []Hook `yaml:"pre_networking,omitempty"`
+	PostSetup        []Hook `yaml:"post_setup,omitempty"`
+}
+
+// Hook is a user-supplied command. Local hooks run on the machine running
+// the tool; remote hooks run over SSH on the nodes named in Targets, where
+// "controller" (the primary), "controllers", "etcd", "workers" and "all"
+// select groups of nodes.
+type Hook struct {
+	Name            string   `yaml:"name,omitempty"`
+	Command         string   `yaml:"command"`
+	Local           bool     `yaml:"local,omitempty"`
+	Targets         []string `yaml:"targets,omitempty"`
+	ContinueOnError bool     `yaml:"continue_on_error,omitempty"`
+}
+
+// forPoint returns the hooks registered for the given hook point.
+func (h HooksConfig) forPoint(point HookPoint) []Hook {
+	switch point {
+	case HookPreSetup:
+		return h.PreSetup
+	case HookPreControlPlane:
+		return h.PreControlPlane
+	case HookPostControlPlane:
+		return h.PostControlPlane
+	case HookPreWorkers:
+		return h.PreWorkers
+	case HookPostWorkers:
+		return h.PostWorkers
+	case HookPreNetworking:
+		return h.PreNetworking
+	case HookPostSetup:
+		return h.PostSetup
+	}
+	return nil
+}
+
+// allHookPoints lists every hook point in the order they run.
+var allHookPoints = []HookPoint{
+	HookPreSetup,
+	HookPreControlPlane,
+	HookPostControlPlane,
+	HookPreWorkers,
+	HookPostWorkers,
+	HookPreNetworking,
+	HookPostSetup,
+}
+
+// validateHooks checks that every hook has a command and valid targets.
+func validateHooks(config ClusterConfig) error {
+	for _, point := range allHookPoints {
+		for i, hook := range config.Hooks.forPoint(point) {
+			if hook.Command == "" {
+				return fmt.Errorf("hook %d at %s has no command", i, point)
+			}
+			if hook.Local {
+				continue
+			}
+			if _, err := resolveHookTargets(config, hook.Targets); err != nil {
+				return fmt.Errorf("hook %d at %s: %w", i, point, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveHookTargets maps hook target names to cluster nodes. An empty
+// target list defaults to the controller.
+func resolveHookTargets(config ClusterConfig, targets []string) ([]Node, error) {
+	if len(targets) == 0 {
+		return []Node{config.Controller}, nil
+	}
+
+	var nodes []Node
+	for _, target := range targets {
+		switch target {
+		case "controller":
+			nodes = append(nodes, config.Controller)
+		case "controllers":
+			nodes = append(nodes, config.ControlPlane()...)
+		case "etcd":
+			nodes = append(nodes, config.EtcdNodes()...)
+		case "workers":
+			nodes = append(nodes, config.Workers...)
+		case "all":
+			nodes = append(nodes, config.Nodes()...)
+		default:
+			found := false
+			for _, node := range config.Nodes() {
+				if node.Name == target {
+					nodes = append(nodes, node)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unknown hook target %q", target)
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// runHooks runs all hooks registered for the given hook point.
+func (cm *ClusterManager) runHooks(ctx context.Context, point HookPoint) error {
+	for i, hook := range cm.config.Hooks.forPoint(point) {
+		name := hook.Name
+		if name == "" {
+			name = fmt.Sprintf("%s[%d]", point, i)
+		}
+		cm.logger.Info(fmt.Sprintf("Running hook %s", name))
+
+		if err := cm.runHook(ctx, point, hook); err != nil {
+			if hook.ContinueOnError {
+				cm.logger.Warn(fmt.Sprintf("Hook %s failed, continuing: %v", name, err))
+				continue
+			}
+			return fmt.Errorf("hook %s failed: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// runHook runs a single hook locally or on its target nodes.
+func (cm *ClusterManager) runHook(ctx context.Context, point HookPoint, hook Hook) error {
+	if hook.Local && cm.runbook != nil {
+		cm.runbook.record(runbookEntry{command: hook.Command})
+		return nil
+	}
+	if hook.Local {
+		return cm.runLocalHook(ctx, point, hook)
+	}
+
+	nodes, err := resolveHookTargets(cm.config, hook.Targets)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if _, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, hook.Command); err != nil {
+			return fmt.Errorf("failed on %s: %w", node.Name, err)
+		}
+	}
+	return nil
+}
+
+// runLocalHook runs a hook through the local shell. The cluster name, work
+// directory and hook point are exported to the command's environment.
+func (cm *ClusterManager) runLocalHook(ctx context.Context, point HookPoint, hook Hook) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hook.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	}
+	cmd.Env = append(os.Environ(),
+		"CLUSTER_NAME="+cm.config.ClusterName,
+		"CLUSTER_WORK_DIR="+cm.config.WorkDir,
+		"CLUSTER_HOOK_POINT="+string(point),
+		"CLUSTER_CONTROLLER_IP="+cm.config.Controller.IPAddress,
+	)
+	cmd.Dir = cm.config.WorkDir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("local command failed: %w, output: %s", err, string(output))
+	}
+	if len(output) > 0 {
+		cm.logger.Debug(fmt.Sprintf("Hook output: %s", string(output)))
+	}
+	return nil
+}
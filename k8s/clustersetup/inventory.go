@@ -0,0 +1,136 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// inventory.go writes an ssh_config snippet and an Ansible inventory for the cluster's nodes.
+package clustersetup
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SSHConfigFile is the ssh_config snippet in the work directory. With
+	// "Include <work_dir>/ssh_config" in ~/.ssh/config, "ssh controller-0"
+	// reaches the node.
+	SSHConfigFile = "ssh_config"
+	// InventoryFile is the Ansible inventory of the nodes in the work directory.
+	InventoryFile = "inventory.ini"
+)
+
+// bastionLogin returns the address, port, user and key file of the bastion,
+// the latter two defaulting to those of the nodes. The port is empty unless
+// bastion_host sets one.
+func (cm *ClusterManager) bastionLogin() (host, port, user, key string) {
+	host = cm.config.BastionHost
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host, port = h, p
+	}
+	user, key = cm.config.BastionUser, cm.config.BastionKey
+	if user == "" {
+		user = cm.config.SSHUser
+	}
+	if key == "" {
+		key = cm.config.SSHKey
+	}
+	return host, port, user, key
+}
+
+// generateSSHConfig generates a Host entry per node. Hostnames that differ
+// from the node name are aliases of the entry. With a bastion, an entry for
+// it comes first and the nodes jump through it.
+func (cm *ClusterManager) generateSSHConfig() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Nodes of cluster %s, generated by cluster setup\n", cm.config.ClusterName)
+	bastion := ""
+	if cm.config.BastionHost != "" {
+		bastion = cm.config.ClusterName + "-bastion"
+		host, port, user, key := cm.bastionLogin()
+		fmt.Fprintf(&b, "\nHost %s\n  HostName %s\n  User %s\n", bastion, host, user)
+		if port != "" {
+			fmt.Fprintf(&b, "  Port %s\n", port)
+		}
+		if key != "" {
+			fmt.Fprintf(&b, "  IdentityFile %s\n  IdentitiesOnly yes\n", key)
+		}
+		b.WriteString("  StrictHostKeyChecking accept-new\n")
+	}
+	for _, node := range cm.config.Nodes() {
+		hosts := node.Name
+		if node.Hostname != "" && node.Hostname != node.Name {
+			hosts += " " + node.Hostname
+		}
+		fmt.Fprintf(&b, "\nHost %s\n  HostName %s\n  User %s\n", hosts, node.IPAddress, cm.config.SSHUser)
+		// A key read from Vault has no file to point to
+		if cm.config.SSHKey != "" {
+			fmt.Fprintf(&b, "  IdentityFile %s\n  IdentitiesOnly yes\n", cm.config.SSHKey)
+		}
+		if bastion != "" {
+			fmt.Fprintf(&b, "  ProxyJump %s\n", bastion)
+		}
+		b.WriteString("  StrictHostKeyChecking accept-new\n")
+	}
+	return b.String()
+}
+
+// generateInventory generates an Ansible inventory with a group per role and
+// a k8s_cluster group holding all of them.
+func (cm *ClusterManager) generateInventory() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Nodes of cluster %s, generated by cluster setup\n", cm.config.ClusterName)
+	groups := []struct {
+		name  string
+		nodes []Node
+	}{
+		{"controllers", cm.config.ControlPlane()},
+		{"etcd", cm.config.EtcdNodes()},
+		{"workers", cm.config.Workers},
+	}
+	for _, group := range groups {
+		fmt.Fprintf(&b, "\n[%s]\n", group.name)
+		for _, node := range group.nodes {
+			fmt.Fprintf(&b, "%s ansible_host=%s", node.Name, node.IPAddress)
+			if node.PodCIDR != "" {
+				fmt.Fprintf(&b, " pod_cidr=%s", node.PodCIDR)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n[k8s_cluster:children]\n")
+	for _, group := range groups {
+		b.WriteString(group.name + "\n")
+	}
+	fmt.Fprintf(&b, "\n[k8s_cluster:vars]\nansible_user=%s\n", cm.config.SSHUser)
+	if cm.config.SSHKey != "" {
+		fmt.Fprintf(&b, "ansible_ssh_private_key_file=%s\n", cm.config.SSHKey)
+	}
+	if cm.config.BastionHost != "" {
+		host, port, user, key := cm.bastionLogin()
+		proxy := "ssh -W %h:%p -q"
+		if port != "" {
+			proxy += " -p " + port
+		}
+		if key != "" {
+			proxy += " -i " + key
+		}
+		fmt.Fprintf(&b, "ansible_ssh_common_args='-o ProxyCommand=\"%s %s@%s\"'\n", proxy, user, host)
+	}
+	fmt.Fprintf(&b, "cluster_name=%s\nkubernetes_version=%s\n", cm.config.ClusterName, cm.config.KubernetesVersion)
+	return b.String()
+}
+
+// writeNodeAccessFiles writes SSHConfigFile and InventoryFile to the work
+// directory. Operators only lose a convenience if they can't be written, so
+// failures are logged.
+func (cm *ClusterManager) writeNodeAccessFiles() {
+	files := map[string]string{
+		SSHConfigFile: cm.generateSSHConfig(),
+		InventoryFile: cm.generateInventory(),
+	}
+	for name, content := range files {
+		if err := cm.writeFile(filepath.Join(cm.config.WorkDir, name), content); err != nil {
+			cm.logger.Warn(fmt.Sprintf("Failed to write %s: %v", name, err))
+		}
+	}
+}
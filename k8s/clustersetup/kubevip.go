@@ -0,0 +1,182 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// kubevip.go runs kube-vip on the controllers to hold the control plane endpoint as a virtual IP.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// KubeVIPConfig runs kube-vip on every controller to announce the control
+// plane endpoint as a virtual IP over ARP. The controllers elect the one
+// holding the address, so it moves to another controller when its holder
+// fails. Interface is the network interface the address is announced on;
+// empty uses the interface holding each controller's own address.
+type KubeVIPConfig struct {
+	Enabled   bool   `yaml:"enabled,omitempty"`
+	Version   string `yaml:"version,omitempty"`
+	Interface string `yaml:"interface,omitempty"`
+}
+
+// defaultKubeVIPVersion is installed when no version is set.
+const defaultKubeVIPVersion = "v0.8.9"
+
+// kubeVIPService is the unit running kube-vip on the controllers.
+const kubeVIPService = "kube-vip"
+
+// kubeVIPKubeconfig is the kubeconfig kube-vip elects its leader with,
+// through the API server on its own controller.
+const kubeVIPKubeconfig = "kube-vip.kubeconfig"
+
+// version returns the kube-vip release to install.
+func (c KubeVIPConfig) version() string {
+	if c.Version == "" {
+		return defaultKubeVIPVersion
+	}
+	return c.Version
+}
+
+// validateKubeVIP checks that kube-vip has an unused IPv4 address to announce.
+func validateKubeVIP(config ClusterConfig) error {
+	vip := config.KubeVIP
+	if !vip.Enabled {
+		if vip.Version != "" || vip.Interface != "" {
+			return fmt.Errorf("kube_vip settings need kube_vip.enabled")
+		}
+		return nil
+	}
+	endpoint := config.ControlPlaneEndpoint
+	if endpoint == "" {
+		return fmt.Errorf("kube_vip needs control_plane_endpoint set to the virtual IP")
+	}
+	if ip := net.ParseIP(endpoint); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("control_plane_endpoint %q must be an IPv4 address for kube_vip to announce", endpoint)
+	}
+	for _, node := range config.Nodes() {
+		if node.IPAddress == endpoint {
+			return fmt.Errorf("control_plane_endpoint %s is the address of %s; kube_vip needs an unused address", endpoint, node.Name)
+		}
+	}
+	if strings.ContainsAny(vip.Version+vip.Interface, " \t\"'/\\$") {
+		return fmt.Errorf("kube_vip version and interface must be plain names")
+	}
+	return nil
+}
+
+// controlPlaneServices returns the services of a controller, including
+// kube-vip when it holds the control plane endpoint.
+func (cm *ClusterManager) controlPlaneServices() []string {
+	services := append([]string{}, controllerServices...)
+	if cm.config.KubeVIP.Enabled {
+		services = append(services, kubeVIPService)
+	}
+	return services
+}
+
+// kubeVIPInstall downloads and installs the kube-vip binary.
+func (cm *ClusterManager) kubeVIPInstall() installStep {
+	version := cm.config.KubeVIP.version()
+	return installStep{"Installing kube-vip", []string{
+		fmt.Sprintf("https://github.com/kube-vip/kube-vip/releases/download/%s/kube-vip-linux-amd64", version),
+	}, []string{
+		"chmod +x kube-vip-linux-amd64",
+		"sudo mv kube-vip-linux-amd64 /usr/local/bin/kube-vip",
+	}, fmt.Sprintf("/usr/local/bin/kube-vip version | grep -qF '%s'", version)}
+}
+
+// writeKubeVIPKubeconfig writes the admin kubeconfig kube-vip uses, with
+// embedded certificates, targeting the API server on the same controller so
+// the election doesn't depend on the address it elects a holder for.
+func (cm *ClusterManager) writeKubeVIPKubeconfig(workDir string) error {
+	config, err := embeddedAdminKubeconfig(workDir, "127.0.0.1", cm.config.ClusterName)
+	if err != nil {
+		return fmt.Errorf("failed to create the kube-vip kubeconfig: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, kubeVIPKubeconfig), []byte(config), 0600); err != nil {
+		return fmt.Errorf("failed to write the kube-vip kubeconfig: %w", err)
+	}
+	return nil
+}
+
+// generateKubeVIPService generates the kube-vip systemd service file that
+// announces the control plane endpoint on iface over ARP while its
+// controller holds the leader lease.
+func (cm *ClusterManager) generateKubeVIPService(iface string) string {
+	return fmt.Sprintf(`[Unit]
+Description=kube-vip control plane virtual IP
+Documentation=https://kube-vip.io
+After=network-online.target kube-apiserver.service
+Wants=network-online.target%s
+
+[Service]
+Environment=address=%s
+Environment=port=6443
+Environment=vip_interface=%s
+Environment=vip_cidr=32
+Environment=vip_arp=true
+Environment=cp_enable=true
+Environment=cp_namespace=kube-system
+Environment=vip_leaderelection=true
+Environment=vip_leasename=plndr-cp-lock
+Environment=vip_leaseduration=5
+Environment=vip_renewdeadline=3
+Environment=vip_retryperiod=1
+ExecStart=/usr/local/bin/kube-vip manager --k8sConfigPath=/var/lib/kubernetes/%s
+%s
+
+[Install]
+WantedBy=multi-user.target
+`, cm.unitDirectives(kubeVIPService), cm.config.ControlPlaneEndpoint, iface, kubeVIPKubeconfig, cm.serviceDirectives(kubeVIPService))
+}
+
+// kubeVIPInterface returns the interface the controller announces the
+// virtual IP on: the configured one, or the one holding its own address.
+func (cm *ClusterManager) kubeVIPInterface(ctx context.Context, controller Node) (string, error) {
+	if cm.config.KubeVIP.Interface != "" {
+		return cm.config.KubeVIP.Interface, nil
+	}
+	pattern := strings.ReplaceAll(controller.IPAddress, ".", `\.`)
+	output, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress,
+		fmt.Sprintf(`ip -o -4 addr show | awk '$4 ~ /^%s\// {print $2; exit}'`, pattern))
+	if err != nil {
+		return "", fmt.Errorf("failed to find the network interface of %s: %w", controller.Name, err)
+	}
+	iface := strings.TrimSpace(output)
+	if iface == "" {
+		return "", fmt.Errorf("no network interface of %s holds %s; set kube_vip.interface", controller.Name, controller.IPAddress)
+	}
+	return iface, nil
+}
+
+// startKubeVIP installs and starts the kube-vip service on a controller
+// whose API server is up.
+func (cm *ClusterManager) startKubeVIP(ctx context.Context, controller Node) error {
+	iface, err := cm.kubeVIPInterface(ctx, controller)
+	if err != nil {
+		return err
+	}
+	servicePath := "/etc/systemd/system/" + kubeVIPService + ".service"
+	if err := cm.sshClient.CopyContent(ctx, controller.IPAddress, cm.generateKubeVIPService(iface), servicePath); err != nil {
+		return fmt.Errorf("failed to upload %s service: %w", kubeVIPService, err)
+	}
+	if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress,
+		"sudo systemctl daemon-reload && sudo systemctl enable kube-vip && sudo systemctl restart kube-vip"); err != nil {
+		return fmt.Errorf("failed to start kube-vip: %w", err)
+	}
+	if err := cm.waitForService(ctx, controller.IPAddress, kubeVIPService, 30*time.Second); err != nil {
+		return fmt.Errorf("kube-vip failed to become healthy: %w", err)
+	}
+	return nil
+}
+
+// waitForKubeVIP waits until an API server answers on the virtual IP, which
+// workers and kubeconfigs rely on from here on.
+func (cm *ClusterManager) waitForKubeVIP(ctx context.Context) error {
+	endpoint := Node{Name: "control plane endpoint " + cm.config.ControlPlaneEndpoint, IPAddress: cm.config.ControlPlaneEndpoint}
+	return cm.waitForAPIServer(ctx, endpoint, 2*time.Minute)
+}
@@ -1,18 +1,268 @@
 // Package k8shard provides automation for setting up Kubernetes clusters.
-// logger.go implements the Logger interface for console-based logging.
+// logger.go implements the Logger interface on log/slog, for the console and
+// the setup log file, and adapters that let host applications route logs
+// elsewhere.
 package clustersetup
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
 
-// NewLogger creates a new logger
+// LogLevel is the severity of a log message.
+type LogLevel string
+
+const (
+	LevelDebug LogLevel = "DEBUG"
+	LevelInfo  LogLevel = "INFO"
+	LevelWarn  LogLevel = "WARN"
+	LevelError LogLevel = "ERROR"
+)
+
+// Log formats.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// SetupLogFile is the log file setups write in the work directory unless
+// the config names another.
+const SetupLogFile = "setup.log"
+
+// ParseLogLevel parses a level name such as "debug", in any case.
+func ParseLogLevel(name string) (LogLevel, error) {
+	level := LogLevel(strings.ToUpper(name))
+	switch level {
+	case LevelDebug, LevelInfo, LevelWarn, LevelError:
+		return level, nil
+	}
+	return "", fmt.Errorf("unknown log level %q; use debug, info, warn or error", name)
+}
+
+// slogLevel returns the slog level of l, treating unknown levels as info.
+func (l LogLevel) slogLevel() slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// NewLogger creates a logger that prints every message to the console.
 func NewLogger() Logger {
-	return &consoleLogger{}
+	return NewSlogLogger(os.Stdout, LevelDebug, LogFormatText)
+}
+
+// NewSlogLogger creates a logger that writes messages of the given level
+// and above to w as slog text or JSON records.
+func NewSlogLogger(w io.Writer, level LogLevel, format string) Logger {
+	return slogLogger{newSlog(w, level, format)}
+}
+
+// newSlog creates the slog.Logger of NewSlogLogger.
+func newSlog(w io.Writer, level LogLevel, format string) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level.slogLevel()}
+	if format == LogFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// slogLogger adapts a slog.Logger to the Logger interface.
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Info(msg string, args ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelInfo, formatLogMessage(msg, args))
+}
+func (l slogLogger) Error(msg string, args ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelError, formatLogMessage(msg, args))
+}
+func (l slogLogger) Debug(msg string, args ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelDebug, formatLogMessage(msg, args))
+}
+func (l slogLogger) Warn(msg string, args ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelWarn, formatLogMessage(msg, args))
+}
+
+// LoggingConfig configures the setup log file. Level is debug, info (the
+// default), warn or error; Format is text (the default) or json.
+type LoggingConfig struct {
+	Level  string `yaml:"level,omitempty"`
+	Format string `yaml:"format,omitempty"`
+	File   string `yaml:"file,omitempty"`
+}
+
+// SetupLogPath returns the path of the setup log file of a config: the file
+// named by its logging settings, relative to the work directory unless
+// absolute, or setup.log in the work directory.
+func SetupLogPath(config ClusterConfig) string {
+	path := config.Logging.File
+	if path == "" {
+		path = SetupLogFile
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(config.WorkDir, path)
+}
+
+// LogToFile appends the manager's logs and command output to the setup log
+// file as well, at the level and in the format of the config's logging
+// settings, so failed setups can be debugged after the fact. The file is
+// closed by Close.
+func (cm *ClusterManager) LogToFile() error {
+	path := SetupLogPath(cm.config)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create the setup log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the setup log: %w", err)
+	}
+	level := LevelInfo
+	if cm.config.Logging.Level != "" {
+		level, _ = ParseLogLevel(cm.config.Logging.Level)
+	}
+	logFile := &setupLog{file: file, path: path}
+	logger := slogLogger{newSlog(logFile, level, cm.config.Logging.Format).With("cluster", cm.config.ClusterName)}
+	cm.logger = NewMultiLogger(cm.logger, logger)
+	cm.events.Subscribe(loggerSubscriber(logger))
+	cm.logFile = logFile
+	return nil
 }
 
-// consoleLogger is a simple console-based logger
-type consoleLogger struct{}
+// setupLog is the file LogToFile writes to. Writes after Close are dropped,
+// so the loggers holding it can outlive the file.
+type setupLog struct {
+	mu   sync.Mutex
+	file *os.File
+	path string
+}
+
+func (l *setupLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return len(p), nil
+	}
+	return l.file.Write(p)
+}
+
+func (l *setupLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// closeLogIn closes the setup log file if it is in dir, which is about to
+// be removed. A log file elsewhere keeps recording.
+func (cm *ClusterManager) closeLogIn(dir string) error {
+	if cm.logFile == nil {
+		return nil
+	}
+	rel, err := filepath.Rel(dir, cm.logFile.path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	cm.logger.Info(fmt.Sprintf("Closing the setup log %s before removing %s", cm.logFile.path, dir))
+	return cm.logFile.Close()
+}
+
+// validateLogging checks the level and format of the logging settings.
+func validateLogging(config ClusterConfig) error {
+	if config.Logging.Level != "" {
+		if _, err := ParseLogLevel(config.Logging.Level); err != nil {
+			return err
+		}
+	}
+	switch config.Logging.Format {
+	case "", LogFormatText, LogFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown log format %q; use %s or %s", config.Logging.Format, LogFormatText, LogFormatJSON)
+}
+
+// LogFunc receives a formatted log message.
+type LogFunc func(level LogLevel, msg string)
+
+// NewFuncLogger adapts a function to the Logger interface, so a host
+// application such as a TUI can route setup logs into its own views.
+func NewFuncLogger(fn LogFunc) Logger {
+	return funcLogger(fn)
+}
+
+// funcLogger formats messages and passes them to a LogFunc.
+type funcLogger LogFunc
 
-func (l *consoleLogger) Info(msg string, args ...interface{})  { fmt.Printf("INFO: "+msg+"\n", args...) }
-func (l *consoleLogger) Error(msg string, args ...interface{}) { fmt.Printf("ERROR: "+msg+"\n", args...) }
-func (l *consoleLogger) Debug(msg string, args ...interface{}) { fmt.Printf("DEBUG: "+msg+"\n", args...) }
-func (l *consoleLogger) Warn(msg string, args ...interface{})  { fmt.Printf("WARN: "+msg+"\n", args...) }
\ No newline at end of file
+func (l funcLogger) Info(msg string, args ...interface{}) {
+	l(LevelInfo, formatLogMessage(msg, args))
+}
+func (l funcLogger) Error(msg string, args ...interface{}) {
+	l(LevelError, formatLogMessage(msg, args))
+}
+func (l funcLogger) Debug(msg string, args ...interface{}) {
+	l(LevelDebug, formatLogMessage(msg, args))
+}
+func (l funcLogger) Warn(msg string, args ...interface{}) {
+	l(LevelWarn, formatLogMessage(msg, args))
+}
+
+// formatLogMessage applies args to msg. Messages are usually preformatted, so
+// msg is only treated as a format string when args are given.
+func formatLogMessage(msg string, args []interface{}) string {
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// NewWriterLogger creates a logger that writes "LEVEL: message" lines to w.
+func NewWriterLogger(w io.Writer) Logger {
+	var mu sync.Mutex
+	return NewFuncLogger(func(level LogLevel, msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "%s: %s\n", level, msg)
+	})
+}
+
+// NewMultiLogger creates a logger that forwards every message to each logger.
+func NewMultiLogger(loggers ...Logger) Logger {
+	return NewFuncLogger(func(level LogLevel, msg string) {
+		for _, logger := range loggers {
+			switch level {
+			case LevelDebug:
+				logger.Debug("%s", msg)
+			case LevelWarn:
+				logger.Warn("%s", msg)
+			case LevelError:
+				logger.Error("%s", msg)
+			default:
+				logger.Info("%s", msg)
+			}
+		}
+	})
+}
+
+// NewNopLogger creates a logger that discards every message.
+func NewNopLogger() Logger {
+	return NewFuncLogger(func(LogLevel, string) {})
+}
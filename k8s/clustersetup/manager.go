@@ -6,66 +6,203 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 )
 
-// SetupCluster sets up the Kubernetes cluster.
+// SetupCluster sets up the Kubernetes cluster from scratch, recording the
+// steps that complete in SetupStateFile in the work directory.
 func (cm *ClusterManager) SetupCluster(ctx context.Context) error {
-	totalSteps := 6
-	cm.progress.ReportProgress(1, totalSteps, "Checking Prerequisites")
+	cm.state = newSetupState(cm.config)
+	cm.resuming = false
+	return cm.runSetup(ctx)
+}
+
+// ResumeSetup continues a setup that failed or was interrupted, skipping the
+// phases and nodes the state in the work directory records as completed.
+// Certificates, the encryption config and binaries already in place are
+// kept, and nodes whose services stopped since are set up again.
+func (cm *ClusterManager) ResumeSetup(ctx context.Context) error {
+	state, err := LoadSetupState(cm.config.WorkDir)
+	if err != nil {
+		return fmt.Errorf("no setup to resume: %w", err)
+	}
+	if state.ClusterName != cm.config.ClusterName || state.KubernetesVersion != cm.config.KubernetesVersion {
+		return fmt.Errorf("setup state is for %s %s, not %s %s; run a full setup instead",
+			state.ClusterName, state.KubernetesVersion, cm.config.ClusterName, cm.config.KubernetesVersion)
+	}
+	cm.state = state
+	cm.resuming = true
+	cm.logger.Info(fmt.Sprintf("Resuming setup with %d completed steps", len(state.Completed)))
+	return cm.runSetup(ctx)
+}
+
+// runSetup runs the setup and publishes its outcome. Setups that run against
+// the nodes end with a report of their timings.
+func (cm *ClusterManager) runSetup(ctx context.Context) error {
+	if cm.runbook == nil {
+		cm.metrics = newSetupMetrics(cm.config)
+		unsubscribe := cm.events.Subscribe(cm.metrics.handle)
+		defer func() {
+			unsubscribe()
+			cm.writeSetupReport()
+			cm.metrics = nil
+		}()
+	}
+	if err := cm.setupCluster(ctx); err != nil {
+		if ctx.Err() != nil && cm.tracker != nil {
+			phase, started, total := cm.tracker.position()
+			cm.logger.Warn(fmt.Sprintf("Setup interrupted during %s after %d of about %d steps; its running command was stopped", phase, started, total))
+		}
+		cm.publish(Event{Type: EventSetupFailed, Err: err})
+		return err
+	}
+	cm.publish(Event{Type: EventSetupCompleted})
+	return nil
+}
+
+// setupCluster runs every setup phase in order, skipping those a resumed
+// setup already completed.
+func (cm *ClusterManager) setupCluster(ctx context.Context) error {
+	totalSteps := 7
+	cm.tracker = newProgressTracker(cm.estimateSetupSteps())
+	cm.startPhase(1, totalSteps, "Checking Prerequisites")
 	if err := cm.ValidateK8sPrerequisites(); err != nil {
 		return fmt.Errorf("prerequisites check failed: %w", err)
 	}
+	if cm.config.AirGapped && cm.runbook == nil {
+		if err := cm.PrepareBundle(ctx); err != nil {
+			return fmt.Errorf("failed to prepare the air-gapped bundle: %w", err)
+		}
+	}
+	// A new setup replaces the state an earlier one left behind
+	if !cm.resuming {
+		if err := cm.state.save(); err != nil {
+			return err
+		}
+	}
+
+	if err := cm.runHooksOnce(ctx, HookPreSetup); err != nil {
+		return err
+	}
 
 	workDir := cm.config.WorkDir
-	cm.progress.ReportProgress(2, totalSteps, "Generating Certificates")
-	if err := cm.generateCertificates(ctx, workDir); err != nil {
+	cm.startPhase(2, totalSteps, "Generating Certificates")
+	if err := cm.once("certificates", func() error { return cm.generateCertificates(ctx, workDir) }); err != nil {
 		return fmt.Errorf("failed to generate certificates: %w", err)
 	}
 
-	cm.progress.ReportProgress(3, totalSteps, "Creating Configurations")
-	if err := cm.createConfigurations(ctx, workDir); err != nil {
+	cm.startPhase(3, totalSteps, "Creating Configurations")
+	if err := cm.once("configurations", func() error { return cm.createConfigurations(ctx, workDir) }); err != nil {
 		return fmt.Errorf("failed to create configurations: %w", err)
 	}
 
-	cm.progress.ReportProgress(4, totalSteps, "Setting Up Control Plane")
-	if err := cm.setupControlPlane(ctx, workDir); err != nil {
+	cm.startPhase(4, totalSteps, "Setting Up Control Plane")
+	if err := cm.runHooksOnce(ctx, HookPreControlPlane); err != nil {
+		return err
+	}
+	if err := cm.once("control-plane", func() error { return cm.setupControlPlane(ctx, workDir) }); err != nil {
 		return fmt.Errorf("failed to setup control plane: %w", err)
 	}
+	if err := cm.runHooksOnce(ctx, HookPostControlPlane); err != nil {
+		return err
+	}
 
-	cm.progress.ReportProgress(5, totalSteps, "Setting Up Worker Nodes")
-	if err := cm.setupWorkerNodes(ctx, workDir); err != nil {
+	cm.startPhase(5, totalSteps, "Setting Up Worker Nodes")
+	if err := cm.runHooksOnce(ctx, HookPreWorkers); err != nil {
+		return err
+	}
+	if err := cm.once("workers", func() error { return cm.setupWorkerNodes(ctx, workDir) }); err != nil {
 		return fmt.Errorf("failed to setup worker nodes: %w", err)
 	}
+	if err := cm.runHooksOnce(ctx, HookPostWorkers); err != nil {
+		return err
+	}
 
-	cm.progress.ReportProgress(6, totalSteps, "Setting Up Networking")
-	if err := cm.setupNetworking(ctx); err != nil {
+	cm.startPhase(6, totalSteps, "Setting Up Networking")
+	if err := cm.runHooksOnce(ctx, HookPreNetworking); err != nil {
+		return err
+	}
+	if err := cm.once("networking", func() error { return cm.setupNetworking(ctx) }); err != nil {
 		return fmt.Errorf("failed to setup networking: %w", err)
 	}
 
-	cm.progress.ReportProgress(6, totalSteps, "Validating Cluster")
-	if err := cm.validateCluster(ctx); err != nil {
-		return fmt.Errorf("failed to validate cluster: %w", err)
+	cm.startPhase(7, totalSteps, "Validating Cluster")
+	err := cm.once("validation", func() error {
+		if err := cm.validateCluster(ctx); err != nil {
+			return fmt.Errorf("failed to validate cluster: %w", err)
+		}
+		return cm.applyResourceDefaults(ctx)
+	})
+	if err != nil {
+		return err
+	}
+	if err := cm.once("addons", func() error { return cm.installAddons(ctx) }); err != nil {
+		return fmt.Errorf("failed to install addons: %w", err)
+	}
+	if err := cm.writeRemoteAdminKubeconfig(workDir); err != nil {
+		return err
+	}
+
+	if err := cm.runHooksOnce(ctx, HookPostSetup); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-// ValidateK8sPrerequisites checks SSH connectivity and working directory.
+// ValidateK8sPrerequisites checks SSH connectivity and working directory,
+// writes the nodes' ssh_config and inventory to it, and gathers the facts of
+// every node to check it suits its role.
 func (cm *ClusterManager) ValidateK8sPrerequisites() error {
 	cm.logger.Info("Checking prerequisites...")
 
-	nodes := append([]Node{cm.config.Controller}, cm.config.Workers...)
-	for _, node := range nodes {
-		if _, err := cm.sshClient.ExecuteCommand(context.Background(), node.IPAddress, "echo 'SSH test'"); err != nil {
-			return fmt.Errorf("SSH connection to %s failed: %w", node.Name, err)
+	for _, node := range cm.config.Nodes() {
+		if err := cm.checkNode(node); err != nil {
+			return err
 		}
-		cm.logger.Info(fmt.Sprintf("SSH connection verified: %s", node.Name))
 	}
 
 	if err := os.MkdirAll(cm.config.WorkDir, 0755); err != nil {
 		return fmt.Errorf("failed to create work directory %s: %w", cm.config.WorkDir, err)
 	}
+	cm.writeNodeAccessFiles()
+
+	ctx := context.Background()
+	roles := []struct {
+		role     string
+		nodes    []Node
+		required nodeRequirements
+	}{
+		{RoleController, cm.config.ControlPlane(), controllerRequirements},
+		{RoleEtcd, cm.config.Etcd.Members, etcdRequirements},
+		{RoleWorker, cm.config.Workers, workerRequirements},
+	}
+	for _, r := range roles {
+		for _, node := range r.nodes {
+			if err := cm.checkNodeFacts(ctx, node, r.role, r.required); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
 
+// checkNode verifies SSH access to a node and that it runs Linux.
+func (cm *ClusterManager) checkNode(node Node) error {
+	if _, err := cm.sshClient.ExecuteCommand(context.Background(), node.IPAddress, "echo 'SSH test'"); err != nil {
+		return fmt.Errorf("SSH connection to %s failed: %w", node.Name, err)
+	}
+	// The setup installs Linux binaries and systemd units on every node
+	nodeOS, err := cm.sshClient.ExecuteCommand(context.Background(), node.IPAddress, "uname -s")
+	if err != nil {
+		return fmt.Errorf("failed to detect operating system of %s: %w", node.Name, err)
+	}
+	if nodeOS = strings.TrimSpace(nodeOS); nodeOS != "Linux" {
+		return fmt.Errorf("node %s runs %s; cluster setup only supports Linux nodes", node.Name, nodeOS)
+	}
+	cm.logger.Info(fmt.Sprintf("SSH connection verified: %s", node.Name))
 	return nil
 }
 
@@ -98,29 +235,41 @@ func (cm *ClusterManager) GetClusterStatus(ctx context.Context) (ClusterStatus,
 	return status, nil
 }
 
+// CheckCertificateExpiry returns the certificates in WorkDir that expire
+// within threshold and publishes a warning event for each of them that
+// wasn't expiring at the previous check.
+func (cm *ClusterManager) CheckCertificateExpiry(threshold time.Duration) ([]CertificateExpiry, error) {
+	certificates, err := cm.certificateExpiries()
+	if err != nil {
+		return nil, err
+	}
+	return cm.alertExpiring(certificates, threshold), nil
+}
+
 // DestroyCluster cleans up the cluster.
 func (cm *ClusterManager) DestroyCluster(ctx context.Context) error {
 	cm.logger.Info("Destroying cluster...")
 
-	nodes := append([]Node{cm.config.Controller}, cm.config.Workers...)
+	nodes := cm.config.Nodes()
+	cm.tracker = newProgressTracker(len(nodes) * len(destroyCommands))
+	cm.startPhase(1, 2, "Cleaning up nodes")
 	for _, node := range nodes {
 		cm.logger.Info(fmt.Sprintf("Cleaning up node: %s", node.Name))
-		commands := []string{
-			"sudo systemctl stop etcd kube-apiserver kube-controller-manager kube-scheduler containerd kubelet kube-proxy || true",
-			"sudo systemctl disable etcd kube-apiserver kube-controller-manager kube-scheduler containerd kubelet kube-proxy || true",
-			"sudo rm -rf /etc/etcd /var/lib/etcd /etc/kubernetes /var/lib/kubernetes /var/lib/kubelet /var/lib/kube-proxy /etc/cni /opt/cni /var/run/kubernetes",
-			"sudo rm -f /usr/local/bin/etcd* /usr/local/bin/kube* /usr/local/bin/runc /bin/containerd*",
-			"sudo rm -f /etc/systemd/system/etcd.service /etc/systemd/system/kube*.service /etc/systemd/system/containerd.service",
-			"sudo systemctl daemon-reload",
-			"sudo systemctl reset-failed",
-		}
-		for _, cmd := range commands {
-			if _, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, cmd); err != nil {
-				return fmt.Errorf("failed to execute cleanup command '%s' on %s: %w", cmd, node.Name, err)
+		progress := cm.nodeProgress(node.Name, destroySteps())
+		for _, cmd := range destroyCommands {
+			progress.advance()
+			if _, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, cmd.command); err != nil {
+				return fmt.Errorf("failed to execute cleanup command '%s' on %s: %w", cmd.command, node.Name, err)
 			}
 		}
+		cm.publish(Event{Type: EventNodeCompleted, Phase: "destroy", Node: node.Name})
 	}
 
+	cm.startPhase(2, 2, "Removing work directory")
+	// An open file in it would keep Windows from removing the directory
+	if err := cm.closeLogIn(cm.config.WorkDir); err != nil {
+		return fmt.Errorf("failed to close the setup log: %w", err)
+	}
 	if err := os.RemoveAll(cm.config.WorkDir); err != nil {
 		return fmt.Errorf("failed to remove work directory %s: %w", cm.config.WorkDir, err)
 	}
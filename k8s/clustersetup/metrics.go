@@ -0,0 +1,386 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// metrics.go times the phases and nodes of a setup and reports the slowest of them.
+package clustersetup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SetupReportFile is the file in the work directory the report of the last
+// setup is written to.
+const SetupReportFile = "setup-report.json"
+
+// receivedBytesCommand prints the bytes each network interface of a node
+// received since boot.
+const receivedBytesCommand = "cat /sys/class/net/*/statistics/rx_bytes"
+
+// PhaseTiming is the wall-clock duration of a setup phase.
+type PhaseTiming struct {
+	Phase    string        `json:"phase"`
+	Duration time.Duration `json:"duration"`
+}
+
+const (
+	// sshSlowMinCommands is how many commands a node runs before it can be
+	// flagged as slow, so that a few long installs don't flag it
+	sshSlowMinCommands = 10
+	// sshSlowLatency is the median command latency at which a node is slow
+	sshSlowLatency = time.Second
+	// sshSlowFactor is how many times the median latency of its peers a
+	// node's may be before it is slow
+	sshSlowFactor = 3
+	// sshSlowFloor is the median latency below which a node is never slow
+	sshSlowFloor = 200 * time.Millisecond
+	// sshFlakyErrors is how many commands may fail to reach a node before
+	// it is flaky
+	sshFlakyErrors = 3
+)
+
+// SSHStats are the SSH commands setup ran on a node: how many, how long they
+// took, and how many failed. Connection errors are the failures where the
+// command didn't run to an exit status, such as dropped connections and
+// failed handshakes. Slow and Flaky are set once the node is flagged.
+type SSHStats struct {
+	Commands         int           `json:"commands"`
+	Failures         int           `json:"failures"`
+	ConnectionErrors int           `json:"connection_errors"`
+	MedianLatency    time.Duration `json:"median_latency"`
+	MaxLatency       time.Duration `json:"max_latency"`
+	Slow             bool          `json:"slow,omitempty"`
+	Flaky            bool          `json:"flaky,omitempty"`
+}
+
+// NodeTiming is the time setup spent on a node, in total and per phase, the
+// bytes the node received while installing, how often setup polled it again
+// while waiting for a service, the API server or the node to be ready, and
+// the SSH commands it ran there.
+type NodeTiming struct {
+	Node          string                   `json:"node"`
+	Duration      time.Duration            `json:"duration"`
+	Phases        map[string]time.Duration `json:"phases"`
+	DownloadBytes int64                    `json:"download_bytes"`
+	Retries       int                      `json:"retries"`
+	SSH           SSHStats                 `json:"ssh"`
+}
+
+// SetupReport summarizes a setup run. Nodes are sorted slowest first.
+type SetupReport struct {
+	ClusterName   string        `json:"cluster_name"`
+	Started       time.Time     `json:"started"`
+	Duration      time.Duration `json:"duration"`
+	Succeeded     bool          `json:"succeeded"`
+	Phases        []PhaseTiming `json:"phases"`
+	Nodes         []NodeTiming  `json:"nodes"`
+	DownloadBytes int64         `json:"download_bytes"`
+	Retries       int           `json:"retries"`
+	SlowNodes     []string      `json:"slow_nodes,omitempty"`
+	FlakyNodes    []string      `json:"flaky_nodes,omitempty"`
+}
+
+// setupMetrics times a setup from its events. Setup works on one node at a
+// time, so the time until the next step is attributed to the node of the
+// current step.
+type setupMetrics struct {
+	mu           sync.Mutex
+	names        map[string]string
+	started      time.Time
+	phase        string
+	phaseStarted time.Time
+	node         string
+	nodeStarted  time.Time
+	phases       []PhaseTiming
+	nodes        map[string]*NodeTiming
+	latencies    map[string][]time.Duration
+	succeeded    bool
+	finished     time.Time
+}
+
+// newSetupMetrics starts timing a setup of the config.
+func newSetupMetrics(config ClusterConfig) *setupMetrics {
+	names := map[string]string{}
+	for _, node := range config.Nodes() {
+		names[node.IPAddress] = node.Name
+	}
+	now := time.Now()
+	return &setupMetrics{names: names, started: now, nodeStarted: now, nodes: map[string]*NodeTiming{}, latencies: map[string][]time.Duration{}}
+}
+
+// handle records the phase and step boundaries of the setup.
+func (m *setupMetrics) handle(event Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch event.Type {
+	case EventPhaseStarted:
+		m.endPhase(event.Time)
+		m.phase, m.phaseStarted = event.Phase, event.Time
+		m.node = ""
+	case EventStepStarted:
+		m.endNode(event.Time)
+		m.node = event.Node
+	case EventSetupCompleted, EventSetupFailed:
+		m.endPhase(event.Time)
+		m.succeeded = event.Type == EventSetupCompleted
+		m.finished = event.Time
+	}
+}
+
+// endPhase closes the current phase and the node interval within it.
+func (m *setupMetrics) endPhase(at time.Time) {
+	m.endNode(at)
+	if m.phase != "" {
+		m.phases = append(m.phases, PhaseTiming{Phase: m.phase, Duration: at.Sub(m.phaseStarted)})
+		m.phase = ""
+	}
+}
+
+// endNode attributes the time since the last step to its node.
+func (m *setupMetrics) endNode(at time.Time) {
+	if m.node != "" {
+		timing := m.timing(m.node)
+		elapsed := at.Sub(m.nodeStarted)
+		timing.Duration += elapsed
+		timing.Phases[m.phase] += elapsed
+	}
+	m.nodeStarted = at
+}
+
+// timing returns the timing of a node, given by name or address.
+func (m *setupMetrics) timing(node string) *NodeTiming {
+	if name, ok := m.names[node]; ok {
+		node = name
+	}
+	timing, ok := m.nodes[node]
+	if !ok {
+		timing = &NodeTiming{Node: node, Phases: map[string]time.Duration{}}
+		m.nodes[node] = timing
+	}
+	return timing
+}
+
+// retry counts another poll of a node.
+func (m *setupMetrics) retry(node string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timing(node).Retries++
+}
+
+// download adds to the bytes a node received.
+func (m *setupMetrics) download(node string, bytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timing(node).DownloadBytes += bytes
+}
+
+// command records an SSH command run on a node. It returns the node's name
+// and stats, and whether the command made it slow or flaky.
+func (m *setupMetrics) command(host string, latency time.Duration, err error) (string, SSHStats, bool, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	timing := m.timing(host)
+	stats := &timing.SSH
+	stats.Commands++
+	if latency > stats.MaxLatency {
+		stats.MaxLatency = latency
+	}
+	m.latencies[timing.Node] = append(m.latencies[timing.Node], latency)
+	if err != nil {
+		stats.Failures++
+		var exit *ssh.ExitError
+		if !errors.As(err, &exit) {
+			stats.ConnectionErrors++
+		}
+	}
+
+	wasSlow, wasFlaky := stats.Slow, stats.Flaky
+	stats.Flaky = stats.ConnectionErrors >= sshFlakyErrors
+	if !stats.Slow && stats.Commands%sshSlowMinCommands == 0 {
+		stats.Slow = m.slow(timing.Node)
+	}
+	stats.MedianLatency = median(m.latencies[timing.Node])
+	return timing.Node, *stats, stats.Slow && !wasSlow, stats.Flaky && !wasFlaky
+}
+
+// slow reports whether the median command latency of a node is over
+// sshSlowLatency, or sshSlowFactor times that of the other nodes, once it
+// ran sshSlowMinCommands commands.
+func (m *setupMetrics) slow(node string) bool {
+	latencies := m.latencies[node]
+	if len(latencies) < sshSlowMinCommands {
+		return false
+	}
+	own := median(latencies)
+	if own >= sshSlowLatency {
+		return true
+	}
+	var peers []time.Duration
+	for other, latencies := range m.latencies {
+		if other != node && len(latencies) >= sshSlowMinCommands {
+			peers = append(peers, median(latencies))
+		}
+	}
+	// A single peer could be the slow one
+	if len(peers) < 2 {
+		return false
+	}
+	return own >= sshSlowFloor && own >= sshSlowFactor*median(peers)
+}
+
+// median returns the middle of the durations.
+func median(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// report returns the summary of the setup.
+func (m *setupMetrics) report(clusterName string) SetupReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	report := SetupReport{
+		ClusterName: clusterName,
+		Started:     m.started,
+		Duration:    m.finished.Sub(m.started),
+		Succeeded:   m.succeeded,
+		Phases:      m.phases,
+	}
+	for _, timing := range m.nodes {
+		report.Nodes = append(report.Nodes, *timing)
+		report.DownloadBytes += timing.DownloadBytes
+		report.Retries += timing.Retries
+	}
+	sort.Slice(report.Nodes, func(i, j int) bool {
+		if report.Nodes[i].Duration != report.Nodes[j].Duration {
+			return report.Nodes[i].Duration > report.Nodes[j].Duration
+		}
+		return report.Nodes[i].Node < report.Nodes[j].Node
+	})
+	for _, node := range report.Nodes {
+		if node.SSH.Slow {
+			report.SlowNodes = append(report.SlowNodes, node.Node)
+		}
+		if node.SSH.Flaky {
+			report.FlakyNodes = append(report.FlakyNodes, node.Node)
+		}
+	}
+	return report
+}
+
+// String formats the report as a table of the phases and one of the nodes,
+// slowest first.
+func (r SetupReport) String() string {
+	var b strings.Builder
+	outcome := "completed"
+	if !r.Succeeded {
+		outcome = "failed"
+	}
+	fmt.Fprintf(&b, "Setup of %s %s in %s, downloading %s with %d retries\n\n",
+		r.ClusterName, outcome, r.Duration.Round(time.Second), formatBytes(r.DownloadBytes), r.Retries)
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PHASE\tDURATION")
+	for _, phase := range r.Phases {
+		fmt.Fprintf(w, "%s\t%s\n", phase.Phase, phase.Duration.Round(time.Second))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "NODE\tDURATION\tDOWNLOADED\tRETRIES\tCOMMANDS\tMEDIAN\tMAX\tFAILED\tCONN ERRORS")
+	for _, node := range r.Nodes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%d\t%d\n", node.Node, node.Duration.Round(time.Second), formatBytes(node.DownloadBytes), node.Retries,
+			node.SSH.Commands, node.SSH.MedianLatency.Round(time.Millisecond), node.SSH.MaxLatency.Round(time.Millisecond), node.SSH.Failures, node.SSH.ConnectionErrors)
+	}
+	w.Flush()
+	if len(r.SlowNodes) > 0 {
+		fmt.Fprintf(&b, "\nSlow SSH on %s; check their network and load\n", strings.Join(r.SlowNodes, ", "))
+	}
+	if len(r.FlakyNodes) > 0 {
+		fmt.Fprintf(&b, "\nSSH connections to %s kept failing; check their network and sshd\n", strings.Join(r.FlakyNodes, ", "))
+	}
+	return b.String()
+}
+
+// formatBytes formats a byte count with a binary unit.
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// writeSetupReport logs the report of the setup that just ended and writes
+// it to the work directory.
+func (cm *ClusterManager) writeSetupReport() {
+	report := cm.metrics.report(cm.config.ClusterName)
+	cm.logger.Info(report.String())
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = cm.writeFile(filepath.Join(cm.config.WorkDir, SetupReportFile), string(data))
+	}
+	if err != nil {
+		cm.logger.Warn(fmt.Sprintf("Failed to write the setup report: %v", err))
+	}
+}
+
+// countRetry counts another poll of a node during setup.
+func (cm *ClusterManager) countRetry(host string) {
+	if cm.metrics != nil {
+		cm.metrics.retry(host)
+	}
+}
+
+// recordCommand records the latency and outcome of an SSH command during
+// setup, and warns about nodes that turn out slow or flaky while it runs.
+func (cm *ClusterManager) recordCommand(host string, latency time.Duration, err error) {
+	if cm.metrics == nil {
+		return
+	}
+	node, stats, slow, flaky := cm.metrics.command(host, latency, err)
+	if slow {
+		cm.logger.Warn(fmt.Sprintf("%s is slow: its SSH commands take %s at the median over %d commands",
+			node, stats.MedianLatency.Round(time.Millisecond), stats.Commands))
+	}
+	if flaky {
+		cm.logger.Warn(fmt.Sprintf("%s is flaky: %d SSH commands failed to reach it", node, stats.ConnectionErrors))
+	}
+}
+
+// receivedBytes returns the bytes a node's network interfaces received since
+// boot, or false if the node doesn't report them.
+func (cm *ClusterManager) receivedBytes(ctx context.Context, node Node) (int64, bool) {
+	output, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, receivedBytesCommand)
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	var total int64
+	for _, field := range fields {
+		bytes, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		total += bytes
+	}
+	return total, true
+}
@@ -0,0 +1,183 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// nodelabels.go applies the labels and taints of workers set in the config.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Taint effects a worker's taints may have.
+var taintEffects = []string{"NoSchedule", "PreferNoSchedule", "NoExecute"}
+
+var (
+	// labelNamePattern matches the name of a label key and a label value.
+	labelNamePattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	// labelPrefixPattern matches the DNS subdomain prefixing a label key.
+	labelPrefixPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+)
+
+// kubeletLabels are the labels in the kubernetes.io and k8s.io namespaces
+// the NodeRestriction admission plugin lets kubelets set on their node.
+// Other labels in those namespaces, such as node-role.kubernetes.io/gpu,
+// are applied with kubectl only.
+var kubeletLabels = map[string]bool{
+	"kubernetes.io/hostname":                   true,
+	"kubernetes.io/arch":                       true,
+	"kubernetes.io/os":                         true,
+	"beta.kubernetes.io/arch":                  true,
+	"beta.kubernetes.io/os":                    true,
+	"beta.kubernetes.io/instance-type":         true,
+	"node.kubernetes.io/instance-type":         true,
+	"failure-domain.beta.kubernetes.io/region": true,
+	"failure-domain.beta.kubernetes.io/zone":   true,
+	"topology.kubernetes.io/region":            true,
+	"topology.kubernetes.io/zone":              true,
+}
+
+// validateNodeLabels checks the labels and taints of the nodes. Only
+// workers run a kubelet, so other nodes can't have any.
+func validateNodeLabels(config ClusterConfig) error {
+	workers := map[string]bool{}
+	for _, worker := range config.Workers {
+		workers[worker.Name] = true
+		if err := validateNodeTopology(worker); err != nil {
+			return err
+		}
+	}
+	for _, node := range config.Nodes() {
+		if !workers[node.Name] && (len(node.Labels) > 0 || len(node.Taints) > 0) {
+			return fmt.Errorf("%s runs no kubelet; only workers can have labels and taints", node.Name)
+		}
+	}
+	return nil
+}
+
+// validateNodeTopology checks the label keys and values and the taints of
+// a worker. A taint maps its key to "value:Effect", or to just the effect.
+func validateNodeTopology(node Node) error {
+	for key, value := range node.Labels {
+		if err := validateLabelKey(key); err != nil {
+			return fmt.Errorf("label %q of %s: %w", key, node.Name, err)
+		}
+		if value != "" && (len(value) > 63 || !labelNamePattern.MatchString(value)) {
+			return fmt.Errorf("label %q of %s has an invalid value %q", key, node.Name, value)
+		}
+	}
+	for key, taint := range node.Taints {
+		if err := validateLabelKey(key); err != nil {
+			return fmt.Errorf("taint %q of %s: %w", key, node.Name, err)
+		}
+		value, effect := splitTaint(taint)
+		if value != "" && (len(value) > 63 || !labelNamePattern.MatchString(value)) {
+			return fmt.Errorf("taint %q of %s has an invalid value %q", key, node.Name, value)
+		}
+		if !contains(taintEffects, effect) {
+			return fmt.Errorf("taint %q of %s has effect %q; use one of %s", key, node.Name, effect, strings.Join(taintEffects, ", "))
+		}
+	}
+	return nil
+}
+
+// validateLabelKey checks a label or taint key: a name of at most 63
+// characters, optionally prefixed by a DNS subdomain and a slash.
+func validateLabelKey(key string) error {
+	name := key
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		prefix := key[:i]
+		name = key[i+1:]
+		if len(prefix) > 253 || !labelPrefixPattern.MatchString(prefix) {
+			return fmt.Errorf("prefix %q is not a DNS subdomain", prefix)
+		}
+	}
+	if len(name) > 63 || !labelNamePattern.MatchString(name) {
+		return fmt.Errorf("name %q must be at most 63 letters, digits, '-', '_' or '.'", name)
+	}
+	return nil
+}
+
+// splitTaint splits "value:Effect" into its value and effect; a taint
+// without a colon is just the effect.
+func splitTaint(taint string) (string, string) {
+	if i := strings.LastIndex(taint, ":"); i >= 0 {
+		return taint[:i], taint[i+1:]
+	}
+	return "", taint
+}
+
+// kubeletSelfLabel reports whether the kubelet may set a label on its node.
+func kubeletSelfLabel(key string) bool {
+	prefix := ""
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		prefix = key[:i]
+	}
+	restricted := prefix == "kubernetes.io" || strings.HasSuffix(prefix, ".kubernetes.io") ||
+		prefix == "k8s.io" || strings.HasSuffix(prefix, ".k8s.io")
+	return !restricted || kubeletLabels[key] ||
+		strings.HasPrefix(key, "kubelet.kubernetes.io/") || strings.HasPrefix(key, "node.kubernetes.io/")
+}
+
+// nodeLabelArgs returns the labels of a node as sorted key=value arguments,
+// only those the kubelet may set itself if kubeletOnly is set.
+func nodeLabelArgs(node Node, kubeletOnly bool) []string {
+	var args []string
+	for key, value := range node.Labels {
+		if !kubeletOnly || kubeletSelfLabel(key) {
+			args = append(args, key+"="+value)
+		}
+	}
+	sort.Strings(args)
+	return args
+}
+
+// nodeTaintArgs returns the taints of a node as sorted key=value:Effect
+// arguments, or key:Effect for taints without a value.
+func nodeTaintArgs(node Node) []string {
+	var args []string
+	for key, taint := range node.Taints {
+		value, effect := splitTaint(taint)
+		if value != "" {
+			key += "=" + value
+		}
+		args = append(args, key+":"+effect)
+	}
+	sort.Strings(args)
+	return args
+}
+
+// kubeletRegistrationFlags returns the kubelet flags that register a worker
+// with its labels and taints, so nothing is scheduled on it before they are
+// applied.
+func kubeletRegistrationFlags(worker Node) string {
+	flags := ""
+	if labels := nodeLabelArgs(worker, true); len(labels) > 0 {
+		flags += " \\\n  --node-labels=" + strings.Join(labels, ",")
+	}
+	if taints := nodeTaintArgs(worker); len(taints) > 0 {
+		flags += " \\\n  --register-with-taints=" + strings.Join(taints, ",")
+	}
+	return flags
+}
+
+// applyNodeLabels labels and taints a registered worker from the
+// controller. The kubelet only applies its flags when the node first
+// registers, and can't set every label, so they are applied again here.
+func (cm *ClusterManager) applyNodeLabels(ctx context.Context, worker Node) error {
+	kubeconfig := "--kubeconfig /var/lib/kubernetes/admin.kubeconfig"
+	if labels := nodeLabelArgs(worker, false); len(labels) > 0 {
+		cmd := fmt.Sprintf("kubectl label node %s %s --overwrite %s", worker.Name, strings.Join(labels, " "), kubeconfig)
+		if _, err := cm.sshClient.ExecuteCommand(ctx, cm.config.Controller.IPAddress, cmd); err != nil {
+			return fmt.Errorf("failed to label node %s: %w", worker.Name, err)
+		}
+	}
+	if taints := nodeTaintArgs(worker); len(taints) > 0 {
+		cmd := fmt.Sprintf("kubectl taint node %s %s --overwrite %s", worker.Name, strings.Join(taints, " "), kubeconfig)
+		if _, err := cm.sshClient.ExecuteCommand(ctx, cm.config.Controller.IPAddress, cmd); err != nil {
+			return fmt.Errorf("failed to taint node %s: %w", worker.Name, err)
+		}
+	}
+	return nil
+}
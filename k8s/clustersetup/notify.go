@@ -0,0 +1,176 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// notify.go pushes lifecycle events to webhook and Slack endpoints.
+package clustersetup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// NotificationConfig configures where lifecycle notifications are sent.
+type NotificationConfig struct {
+	Webhooks []WebhookConfig `yaml:"webhooks,omitempty"`
+}
+
+// WebhookConfig describes a single notification endpoint. Format is "json"
+// (the default) or "slack". Events filters which event types are sent; when
+// empty, setup completion and failure, drift and certificate expiry events
+// are sent.
+type WebhookConfig struct {
+	URL     string            `yaml:"url"`
+	Format  string            `yaml:"format,omitempty"`
+	Events  []string          `yaml:"events,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// defaultNotificationEvents are sent when a webhook doesn't list events.
+var defaultNotificationEvents = []EventType{
+	EventSetupCompleted,
+	EventSetupFailed,
+	EventDriftDetected,
+	EventCertificateExpiring,
+}
+
+// webhookPayload is the JSON body sent to generic webhooks.
+type webhookPayload struct {
+	Event   string    `json:"event"`
+	Cluster string    `json:"cluster"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// WebhookNotifier sends events to the configured webhooks.
+type WebhookNotifier struct {
+	webhooks []WebhookConfig
+	client   *http.Client
+	logger   Logger
+}
+
+// NewWebhookNotifier creates a notifier for the given configuration.
+func NewWebhookNotifier(config NotificationConfig, logger Logger) *WebhookNotifier {
+	return &WebhookNotifier{
+		webhooks: config.Webhooks,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+	}
+}
+
+// validateNotifications checks that every webhook has a URL and a known format.
+func validateNotifications(config NotificationConfig) error {
+	for i, webhook := range config.Webhooks {
+		if webhook.URL == "" {
+			return fmt.Errorf("webhook %d has no url", i)
+		}
+		if webhook.Format != "" && webhook.Format != "json" && webhook.Format != "slack" {
+			return fmt.Errorf("webhook %d has unknown format %q", i, webhook.Format)
+		}
+	}
+	return nil
+}
+
+// Handle is an EventHandler that forwards matching events to each webhook.
+// Delivery failures are logged and never interrupt the operation.
+func (n *WebhookNotifier) Handle(event Event) {
+	for _, webhook := range n.webhooks {
+		if !webhookWants(webhook, event.Type) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := n.send(ctx, webhook, event); err != nil {
+			n.logger.Warn(fmt.Sprintf("Failed to send %s notification to %s: %v", event.Type, webhook.URL, err))
+		}
+		cancel()
+	}
+}
+
+// webhookWants reports whether a webhook is subscribed to an event type.
+func webhookWants(webhook WebhookConfig, eventType EventType) bool {
+	if len(webhook.Events) == 0 {
+		for _, t := range defaultNotificationEvents {
+			if t == eventType {
+				return true
+			}
+		}
+		return false
+	}
+	for _, t := range webhook.Events {
+		if EventType(t) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// send posts a single event to a webhook.
+func (n *WebhookNotifier) send(ctx context.Context, webhook WebhookConfig, event Event) error {
+	message := describeEvent(event)
+
+	var body interface{}
+	if webhook.Format == "slack" {
+		body = map[string]string{"text": message}
+	} else {
+		payload := webhookPayload{
+			Event:   string(event.Type),
+			Cluster: event.Cluster,
+			Message: message,
+			Time:    event.Time,
+		}
+		if event.Err != nil {
+			payload.Error = event.Err.Error()
+		}
+		body = payload
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range webhook.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// describeEvent renders a one-line human readable summary of an event.
+func describeEvent(event Event) string {
+	switch event.Type {
+	case EventSetupCompleted:
+		return fmt.Sprintf("✅ Cluster %s setup completed", event.Cluster)
+	case EventSetupFailed:
+		return fmt.Sprintf("❌ Cluster %s setup failed: %v", event.Cluster, event.Err)
+	case EventDriftDetected:
+		return fmt.Sprintf("⚠️ Drift detected in cluster %s: %s", event.Cluster, event.Name)
+	case EventCertificateExpiring:
+		return fmt.Sprintf("⚠️ Certificate %s in cluster %s expires soon", event.Name, event.Cluster)
+	case EventPhaseStarted:
+		return fmt.Sprintf("Cluster %s: step %d/%d %s", event.Cluster, event.Step, event.TotalSteps, event.Phase)
+	case EventNodeCompleted:
+		return fmt.Sprintf("Cluster %s: node %s completed", event.Cluster, event.Node)
+	case EventCommandFailed:
+		return fmt.Sprintf("Cluster %s: command failed on %s: %v", event.Cluster, event.Node, event.Err)
+	case EventCertificateIssued:
+		return fmt.Sprintf("Cluster %s: certificate %s issued", event.Cluster, event.Name)
+	}
+	return fmt.Sprintf("Cluster %s: %s", event.Cluster, event.Type)
+}
@@ -0,0 +1,109 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// osfamily.go detects the OS family of nodes and prepares RHEL-like workers.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// OS families of the nodes, which decide how worker dependencies are
+// installed.
+const (
+	// OSFamilyDebian covers Debian and Ubuntu, installing with apt-get.
+	OSFamilyDebian = "debian"
+	// OSFamilyRHEL covers RHEL, Rocky, AlmaLinux, CentOS, Fedora and Amazon
+	// Linux, installing with dnf or yum.
+	OSFamilyRHEL = "rhel"
+)
+
+// osReleaseCommand prints the ID and ID_LIKE of a node's OS.
+const osReleaseCommand = `(. /etc/os-release 2>/dev/null && echo "$ID $ID_LIKE") || true`
+
+// workerFirewallPorts are opened on workers running firewalld: the kubelet,
+// NodePorts and the overlay and health ports of the CNI providers.
+var workerFirewallPorts = []string{"10250/tcp", "30000-32767/tcp", "30000-32767/udp", "179/tcp", "4240/tcp", "4789/udp", "8472/udp"}
+
+// osFamilyOf returns the family of an OS from its os-release ID and ID_LIKE,
+// or an empty string for an OS outside the known families.
+func osFamilyOf(ids string) string {
+	for _, id := range strings.Fields(ids) {
+		switch strings.Trim(id, `"`) {
+		case "debian", "ubuntu":
+			return OSFamilyDebian
+		case "rhel", "centos", "fedora", "rocky", "almalinux", "amzn":
+			return OSFamilyRHEL
+		}
+	}
+	return ""
+}
+
+// validateOSFamily checks the configured OS family.
+func validateOSFamily(config ClusterConfig) error {
+	switch config.OSFamily {
+	case "", OSFamilyDebian, OSFamilyRHEL:
+		return nil
+	}
+	return fmt.Errorf("os_family must be %q or %q", OSFamilyDebian, OSFamilyRHEL)
+}
+
+// osFamily returns the OS family of a node: the configured os_family, else
+// the one its cached facts report, else the one it reports now. Nodes of an
+// unknown OS are treated as Debian.
+func (cm *ClusterManager) osFamily(ctx context.Context, node Node) string {
+	if cm.config.OSFamily != "" {
+		return cm.config.OSFamily
+	}
+	var family string
+	if facts, err := LoadNodeFacts(cm.config.WorkDir, node.Name); err == nil {
+		family = osFamilyOf(facts.OSID + " " + facts.OSLike)
+	} else if output, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, osReleaseCommand); err == nil {
+		family = osFamilyOf(output)
+	}
+	if family == "" {
+		return OSFamilyDebian
+	}
+	return family
+}
+
+// dependenciesInstall installs the tools kube-proxy and the kubelet need
+// with the package manager of the family. Minimal RHEL-like images also lack
+// the tar and wget the other install steps use.
+func dependenciesInstall(family string) installStep {
+	commands := []string{
+		"sudo apt-get update",
+		"sudo apt-get -y install socat conntrack ipset",
+	}
+	if family == OSFamilyRHEL {
+		// Amazon Linux 2 and CentOS 7 predate dnf
+		commands = []string{
+			"if command -v dnf >/dev/null 2>&1; then sudo dnf -y install socat conntrack-tools ipset tar wget; " +
+				"else sudo yum -y install socat conntrack-tools ipset tar wget; fi",
+		}
+	}
+	return installStep{"Installing dependencies", nil, commands, "which socat conntrack ipset"}
+}
+
+// prepareWorkerOS adjusts RHEL-like workers for Kubernetes: SELinux becomes
+// permissive, since the binaries setup installs carry no SELinux policy, and
+// a running firewalld lets in the kubelet, NodePort and pod network traffic.
+func (cm *ClusterManager) prepareWorkerOS(ctx context.Context, worker Node, family string) error {
+	if family != OSFamilyRHEL {
+		return nil
+	}
+	firewall := "sudo firewall-cmd --permanent"
+	for _, port := range workerFirewallPorts {
+		firewall += " --add-port=" + port
+	}
+	script := []string{
+		"if command -v getenforce >/dev/null 2>&1 && [ \"$(getenforce)\" = Enforcing ]; then sudo setenforce 0; fi",
+		"if [ -f /etc/selinux/config ]; then sudo sed -i 's/^SELINUX=enforcing$/SELINUX=permissive/' /etc/selinux/config; fi",
+		fmt.Sprintf("if systemctl is-active --quiet firewalld; then %s && sudo firewall-cmd --permanent --zone=trusted --add-source=%s && sudo firewall-cmd --reload; fi",
+			firewall, cm.config.PodCIDR),
+	}
+	if _, err := cm.sshClient.ExecuteScript(ctx, worker.IPAddress, strings.Join(script, "\n")); err != nil {
+		return fmt.Errorf("failed to prepare SELinux and firewalld on %s: %w", worker.Name, err)
+	}
+	return nil
+}
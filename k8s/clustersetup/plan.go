@@ -0,0 +1,158 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// plan.go computes the commands, uploads and certificates of a setup without running it.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PlanStep is a command a setup runs or a file it uploads. Local steps, such
+// as local hooks, have no node.
+type PlanStep struct {
+	Phase   string
+	Node    string
+	Host    string
+	Command string
+	// Upload is the remote path of an uploaded file. Source names the file
+	// in the work directory it's copied from, such as a certificate, and
+	// Content holds what's written for files generated on the fly.
+	Upload  string
+	Source  string
+	Content string
+}
+
+// PlannedCertificate is a certificate a setup would issue: its name, the
+// hosts of a server certificate, and where it comes from, the local CA or
+// the Vault PKI engine.
+type PlannedCertificate struct {
+	Name   string
+	Hosts  []string
+	Source string
+}
+
+// SetupPlan is everything a setup would do, in order.
+type SetupPlan struct {
+	ClusterName       string
+	KubernetesVersion string
+	Certificates      []PlannedCertificate
+	Steps             []PlanStep
+}
+
+// PlanSetup walks every phase of a setup without connecting to the nodes and
+// returns the exact commands it would run, the files it would upload and the
+// certificates it would issue. Nothing is written to the work directory and
+// Vault is never contacted: the certificates and kubeconfigs are generated
+// from a throwaway local CA in a temporary directory and discarded, and
+// those a Vault PKI would issue are only listed. Local hooks are listed, not
+// run.
+func (cm *ClusterManager) PlanSetup(ctx context.Context) (SetupPlan, error) {
+	dir, err := os.MkdirTemp("", "k8s-setup-plan-*")
+	if err != nil {
+		return SetupPlan{}, fmt.Errorf("failed to create plan directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certificates := &planCertificateManager{CertificateManager: NewCertificateManager(), pki: cm.config.Vault.PKI}
+	book, err := cm.recordSetup(ctx, dir, certificates)
+	if err != nil {
+		return SetupPlan{}, fmt.Errorf("failed to plan setup: %w", err)
+	}
+	plan := SetupPlan{
+		ClusterName:       cm.config.ClusterName,
+		KubernetesVersion: cm.config.KubernetesVersion,
+		Certificates:      certificates.planned,
+	}
+	for _, entry := range book.entries {
+		step := PlanStep{Phase: entry.phase, Host: entry.host, Command: entry.command, Upload: entry.upload, Content: entry.content}
+		if entry.host != "" {
+			step.Node = book.nodeName(entry.host)
+		}
+		// Generated content only lived in the discarded directory
+		if entry.upload != "" && entry.content == "" {
+			step.Source = entry.source
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
+// String formats the plan for review: the certificates, then every step
+// under its phase, prefixed with the node it runs on.
+func (p SetupPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Setup plan for %s (Kubernetes %s)\n", p.ClusterName, p.KubernetesVersion)
+	fmt.Fprintf(&b, "\nCertificates to issue (%d):\n", len(p.Certificates))
+	for _, cert := range p.Certificates {
+		fmt.Fprintf(&b, "  %s from %s", cert.Name, cert.Source)
+		if len(cert.Hosts) > 0 {
+			fmt.Fprintf(&b, " for %s", strings.Join(cert.Hosts, ", "))
+		}
+		b.WriteString("\n")
+	}
+
+	phase := ""
+	for _, step := range p.Steps {
+		if step.Phase != phase {
+			phase = step.Phase
+			fmt.Fprintf(&b, "\n[%s]\n", phase)
+		}
+		target := "local"
+		if step.Host != "" {
+			target = fmt.Sprintf("%s (%s)", step.Node, step.Host)
+		}
+		if step.Upload != "" {
+			source := step.Source
+			if source == "" {
+				source = fmt.Sprintf("%d bytes", len(step.Content))
+			}
+			fmt.Fprintf(&b, "  %s: upload %s -> %s\n", target, source, step.Upload)
+			continue
+		}
+		lines := strings.Split(strings.TrimRight(step.Command, "\n"), "\n")
+		fmt.Fprintf(&b, "  %s: %s\n", target, lines[0])
+		for _, line := range lines[1:] {
+			fmt.Fprintf(&b, "      %s\n", line)
+		}
+	}
+	return b.String()
+}
+
+// planCertificateManager lists the certificates a planned setup issues. The
+// files setup needs are generated from a throwaway local CA, so a Vault PKI
+// is described but never asked for a certificate.
+type planCertificateManager struct {
+	CertificateManager
+	pki     *VaultPKIConfig
+	planned []PlannedCertificate
+}
+
+// source describes where a certificate would come from.
+func (m *planCertificateManager) source(ca bool) string {
+	switch {
+	case m.pki == nil && ca:
+		return "a new local CA"
+	case m.pki == nil:
+		return "the local CA"
+	case ca:
+		return fmt.Sprintf("Vault PKI %s/cert/ca", m.pki.Mount)
+	}
+	return fmt.Sprintf("Vault PKI %s/issue/%s", m.pki.Mount, m.pki.Role)
+}
+
+func (m *planCertificateManager) GenerateCA(workDir string, config CertificateConfig) error {
+	m.planned = append(m.planned, PlannedCertificate{Name: "ca", Source: m.source(true)})
+	return m.CertificateManager.GenerateCA(workDir, config)
+}
+
+func (m *planCertificateManager) GenerateClientCert(workDir, name string, config CertificateConfig) error {
+	m.planned = append(m.planned, PlannedCertificate{Name: name, Source: m.source(false)})
+	return m.CertificateManager.GenerateClientCert(workDir, name, config)
+}
+
+func (m *planCertificateManager) GenerateServerCert(workDir, name string, hosts []string, config CertificateConfig) error {
+	m.planned = append(m.planned, PlannedCertificate{Name: name, Hosts: hosts, Source: m.source(false)})
+	return m.CertificateManager.GenerateServerCert(workDir, name, hosts, config)
+}
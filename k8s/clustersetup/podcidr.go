@@ -0,0 +1,187 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// podcidr.go carves the pod CIDRs of workers out of the cluster's pod CIDR.
+package clustersetup
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// PodCIDRAllocationFile is the file in the work directory that records the
+// pod CIDRs allocated to workers configured without one, by worker name.
+const PodCIDRAllocationFile = ".pod-cidrs.json"
+
+// defaultNodeCIDRMaskSize is the prefix length of allocated pod CIDRs unless
+// node_cidr_mask_size sets it.
+const defaultNodeCIDRMaskSize = 24
+
+// nodeCIDRMaskSize returns the prefix length of allocated pod CIDRs.
+func nodeCIDRMaskSize(config ClusterConfig) int {
+	if config.NodeCIDRMaskSize > 0 {
+		return config.NodeCIDRMaskSize
+	}
+	return defaultNodeCIDRMaskSize
+}
+
+// validateNodeCIDRMaskSize checks that node_cidr_mask_size carves at least
+// one subnet with room for pods out of the cluster's pod CIDR.
+func validateNodeCIDRMaskSize(config ClusterConfig, podNet *net.IPNet) error {
+	if config.NodeCIDRMaskSize == 0 {
+		return nil
+	}
+	ones, bits := podNet.Mask.Size()
+	if config.NodeCIDRMaskSize < ones || config.NodeCIDRMaskSize > bits-2 {
+		return fmt.Errorf("node_cidr_mask_size %d must be between %d and %d for pod_cidr %s", config.NodeCIDRMaskSize, ones, bits-2, podNet)
+	}
+	return nil
+}
+
+// allocatePodCIDRs gives every worker without a pod CIDR one carved from the
+// cluster's pod CIDR and records the allocations in the work directory. A
+// worker keeps the pod CIDR recorded for it before; others take the lowest
+// subnet used by no worker and by no recorded allocation, so the result only
+// depends on the order of the workers and on earlier allocations.
+func allocatePodCIDRs(config *ClusterConfig) error {
+	needed := false
+	for _, worker := range config.Workers {
+		needed = needed || worker.PodCIDR == ""
+	}
+	if !needed {
+		return nil
+	}
+	podNet, _, err := parseNetworks(*config)
+	if err != nil {
+		return err
+	}
+	allocations, err := loadPodCIDRAllocations(config.WorkDir)
+	if err != nil {
+		return err
+	}
+	if err := assignPodCIDRs(config.Workers, allocations, podNet, nodeCIDRMaskSize(*config)); err != nil {
+		return err
+	}
+	if err := savePodCIDRAllocations(config.WorkDir, allocations); err != nil {
+		return err
+	}
+	// Recorded allocations may predate a change of pod_cidr
+	return validateNetwork(*config)
+}
+
+// assignPodCIDRs sets the pod CIDR of the workers without one and records
+// it in allocations. Allocations of workers that now set a different one are
+// dropped; those of workers no longer configured stay reserved.
+func assignPodCIDRs(workers []Node, allocations map[string]string, podNet *net.IPNet, size int) error {
+	configured := map[string]bool{}
+	for i, worker := range workers {
+		configured[worker.Name] = true
+		if worker.PodCIDR != "" && worker.PodCIDR != allocations[worker.Name] {
+			delete(allocations, worker.Name)
+		} else if cidr, ok := allocations[worker.Name]; ok {
+			workers[i].PodCIDR = cidr
+		}
+	}
+
+	var taken []*net.IPNet
+	reserve := func(cidr string) {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			taken = append(taken, network)
+		}
+	}
+	for _, worker := range workers {
+		reserve(worker.PodCIDR)
+	}
+	for name, cidr := range allocations {
+		if !configured[name] {
+			reserve(cidr)
+		}
+	}
+
+	for i, worker := range workers {
+		if worker.PodCIDR != "" {
+			continue
+		}
+		subnet := freeSubnet(podNet, size, taken)
+		if subnet == nil {
+			return fmt.Errorf("pod_cidr %s has no free /%d left for %s", podNet, size, worker.Name)
+		}
+		taken = append(taken, subnet)
+		workers[i].PodCIDR = subnet.String()
+		allocations[worker.Name] = subnet.String()
+	}
+	return nil
+}
+
+// freeSubnet returns the lowest subnet of network with the given prefix
+// length that overlaps none of taken, or nil if there is none.
+func freeSubnet(network *net.IPNet, size int, taken []*net.IPNet) *net.IPNet {
+	ones, bits := network.Mask.Size()
+	if size < ones || size > bits {
+		return nil
+	}
+	base := new(big.Int).SetBytes(network.IP)
+	step := new(big.Int).Lsh(big.NewInt(1), uint(bits-size))
+	count := new(big.Int).Lsh(big.NewInt(1), uint(size-ones))
+	for i := new(big.Int); i.Cmp(count) < 0; i.Add(i, big.NewInt(1)) {
+		start := new(big.Int).Add(base, new(big.Int).Mul(i, step))
+		ip := make(net.IP, len(network.IP))
+		start.FillBytes(ip)
+		subnet := &net.IPNet{IP: ip, Mask: net.CIDRMask(size, bits)}
+		free := true
+		for _, other := range taken {
+			if overlaps(subnet, other) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return subnet
+		}
+	}
+	return nil
+}
+
+// loadPodCIDRAllocations reads the allocations recorded in workDir; a work
+// directory without any has none.
+func loadPodCIDRAllocations(workDir string) (map[string]string, error) {
+	path := filepath.Join(workDir, PodCIDRAllocationFile)
+	allocations := map[string]string{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return allocations, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pod CIDR allocations: %w", err)
+	}
+	if err := json.Unmarshal(data, &allocations); err != nil {
+		return nil, fmt.Errorf("failed to parse pod CIDR allocations %s: %w", path, err)
+	}
+	return allocations, nil
+}
+
+// savePodCIDRAllocations writes the allocations to workDir.
+func savePodCIDRAllocations(workDir string, allocations map[string]string) error {
+	data, err := json.MarshalIndent(allocations, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pod CIDR allocations: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, PodCIDRAllocationFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write pod CIDR allocations: %w", err)
+	}
+	return nil
+}
+
+// releasePodCIDR frees the pod CIDR allocated to a removed worker for reuse.
+func (cm *ClusterManager) releasePodCIDR(name string) {
+	allocations, err := loadPodCIDRAllocations(cm.config.WorkDir)
+	if err != nil || allocations[name] == "" {
+		return
+	}
+	delete(allocations, name)
+	if err := savePodCIDRAllocations(cm.config.WorkDir, allocations); err != nil {
+		cm.logger.Warn(fmt.Sprintf("Failed to release the pod CIDR of %s: %v", name, err))
+	}
+}
@@ -0,0 +1,124 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// priorityclasses.go creates custom PriorityClasses and picks the priority of the cluster addons.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PriorityClassConfig describes a PriorityClass created during setup.
+// PreemptionPolicy is "PreemptLowerPriority" (the default) or "Never".
+type PriorityClassConfig struct {
+	Name             string `yaml:"name"`
+	Value            int    `yaml:"value"`
+	GlobalDefault    bool   `yaml:"global_default,omitempty"`
+	PreemptionPolicy string `yaml:"preemption_policy,omitempty"`
+	Description      string `yaml:"description,omitempty"`
+}
+
+// The built-in priority classes. system-node-critical is for pods a node
+// can't run without; system-cluster-critical for pods the cluster needs, such
+// as DNS. Both are reserved for the kube-system namespace.
+const (
+	SystemNodeCritical    = "system-node-critical"
+	SystemClusterCritical = "system-cluster-critical"
+)
+
+// maxPriorityClassValue is the highest value of a user-defined PriorityClass;
+// higher values are reserved for the system classes.
+const maxPriorityClassValue = 1000000000
+
+// subdomainPattern matches a DNS subdomain name such as "batch.low".
+var subdomainPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// validatePriorityClasses checks the custom priority classes and that the
+// CoreDNS priority class exists.
+func validatePriorityClasses(config ClusterConfig) error {
+	defined := map[string]bool{SystemNodeCritical: true, SystemClusterCritical: true}
+	globalDefault := ""
+	for _, class := range config.PriorityClasses {
+		if len(class.Name) > 253 || !subdomainPattern.MatchString(class.Name) {
+			return fmt.Errorf("priority class %q is not a valid name", class.Name)
+		}
+		if strings.HasPrefix(class.Name, "system-") {
+			return fmt.Errorf("priority class %s: the system- prefix is reserved", class.Name)
+		}
+		if defined[class.Name] {
+			return fmt.Errorf("priority class %s is defined twice", class.Name)
+		}
+		defined[class.Name] = true
+		if class.Value < -maxPriorityClassValue || class.Value > maxPriorityClassValue {
+			return fmt.Errorf("priority class %s: value must be between %d and %d", class.Name, -maxPriorityClassValue, maxPriorityClassValue)
+		}
+		switch class.PreemptionPolicy {
+		case "", "PreemptLowerPriority", "Never":
+		default:
+			return fmt.Errorf("priority class %s: preemption_policy must be PreemptLowerPriority or Never", class.Name)
+		}
+		if class.GlobalDefault {
+			if globalDefault != "" {
+				return fmt.Errorf("priority classes %s and %s are both the global default", globalDefault, class.Name)
+			}
+			globalDefault = class.Name
+		}
+	}
+	if name := config.CoreDNS.PriorityClassName; name != "" && !defined[name] {
+		return fmt.Errorf("coredns priority class %q is neither a system class nor in priority_classes", name)
+	}
+	return nil
+}
+
+// coreDNSPriorityClassName returns the priority class of CoreDNS and its
+// autoscaler, system-cluster-critical unless configured.
+func (cm *ClusterManager) coreDNSPriorityClassName() string {
+	if cm.config.CoreDNS.PriorityClassName != "" {
+		return cm.config.CoreDNS.PriorityClassName
+	}
+	return SystemClusterCritical
+}
+
+// generatePriorityClassesManifest generates the configured PriorityClasses.
+func (cm *ClusterManager) generatePriorityClassesManifest() string {
+	var docs []string
+	for _, class := range cm.config.PriorityClasses {
+		policy := class.PreemptionPolicy
+		if policy == "" {
+			policy = "PreemptLowerPriority"
+		}
+		doc := fmt.Sprintf(`apiVersion: scheduling.k8s.io/v1
+kind: PriorityClass
+metadata:
+  name: %s
+value: %d
+globalDefault: %t
+preemptionPolicy: %s
+`, class.Name, class.Value, class.GlobalDefault, policy)
+		if class.Description != "" {
+			doc += fmt.Sprintf("description: %q\n", class.Description)
+		}
+		docs = append(docs, doc)
+	}
+	return strings.Join(docs, "---\n")
+}
+
+// applyPriorityClasses creates the configured PriorityClasses from the
+// controller so that the addons deployed after them can use them.
+func (cm *ClusterManager) applyPriorityClasses(ctx context.Context) error {
+	if len(cm.config.PriorityClasses) == 0 {
+		return nil
+	}
+	controller := cm.config.Controller
+	manifestPath := "/tmp/priority-classes.yaml"
+	if err := cm.sshClient.CopyContent(ctx, controller.IPAddress, cm.generatePriorityClassesManifest(), manifestPath); err != nil {
+		return fmt.Errorf("failed to upload priority classes: %w", err)
+	}
+	applyCmd := fmt.Sprintf("kubectl apply -f %s --kubeconfig /var/lib/kubernetes/admin.kubeconfig", manifestPath)
+	if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, applyCmd); err != nil {
+		return fmt.Errorf("failed to apply priority classes: %w", err)
+	}
+	cm.logger.Info(fmt.Sprintf("Created %d priority classes", len(cm.config.PriorityClasses)))
+	return nil
+}
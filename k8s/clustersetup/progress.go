@@ -2,7 +2,11 @@
 // progress.go implements the ProgressReporter interface for progress updates.
 package clustersetup
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+	"time"
+)
 
 // NewProgressReporter creates a new progress reporter
 func NewProgressReporter() ProgressReporter {
@@ -15,6 +19,20 @@ type consoleProgressReporter struct {
 	current int
 }
 
+// NewNopProgressReporter creates a progress reporter that discards all progress,
+// for checks that run without a progress display.
+func NewNopProgressReporter() ProgressReporter {
+	return nopProgressReporter{}
+}
+
+// nopProgressReporter discards all progress.
+type nopProgressReporter struct{}
+
+func (nopProgressReporter) Start(total int, description string)                {}
+func (nopProgressReporter) Update(current int, status string)                  {}
+func (nopProgressReporter) Finish(success bool, message string)                {}
+func (nopProgressReporter) ReportProgress(step, totalSteps int, phase string) {}
+
 func (p *consoleProgressReporter) Start(total int, description string) {
 	p.total = total
 	p.current = 0
@@ -36,4 +54,292 @@ func (p *consoleProgressReporter) Finish(success bool, message string) {
 
 func (p *consoleProgressReporter) ReportProgress(step, totalSteps int, phase string) {
 	fmt.Printf("Step %d/%d: %s\n", step, totalSteps, phase)
-}
\ No newline at end of file
+}
+func (p *consoleProgressReporter) ReportStep(update ProgressUpdate) {
+	node := update.Node
+	if node == "" {
+		node = "local"
+	}
+	eta := "estimating"
+	if update.ETA > 0 {
+		eta = update.ETA.Round(time.Second).String()
+	}
+	fmt.Printf("  [%s] %d/%d %s (%d/%d overall, elapsed %s, ETA %s)\n",
+		node, update.NodeStep, update.NodeSteps, update.Step,
+		update.Completed+1, update.Total, update.Elapsed.Round(time.Second), eta)
+}
+
+// ProgressUpdate is a snapshot of hierarchical progress: a phase, the node
+// being worked on within it, and the step running on that node. Node is
+// empty for steps that run locally or span several nodes.
+type ProgressUpdate struct {
+	Phase      string
+	PhaseIndex int
+	PhaseTotal int
+	Node       string
+	Step       string
+	NodeStep   int
+	NodeSteps  int
+	Completed  int
+	Total      int
+	Elapsed    time.Duration
+	ETA        time.Duration
+}
+
+// Percent returns the overall completion as a value between 0 and 100.
+func (u ProgressUpdate) Percent() float64 {
+	if u.Total == 0 {
+		return 0
+	}
+	return float64(u.Completed) * 100 / float64(u.Total)
+}
+
+// progressTracker counts steps across a run and estimates the time left.
+type progressTracker struct {
+	mu         sync.Mutex
+	started    time.Time
+	total      int
+	completed  int
+	phase      string
+	phaseIndex int
+	phaseTotal int
+}
+
+// newProgressTracker creates a tracker for a run of an estimated number of steps.
+func newProgressTracker(total int) *progressTracker {
+	return &progressTracker{started: time.Now(), total: total}
+}
+
+// startPhase records the phase that subsequent steps belong to.
+func (t *progressTracker) startPhase(index, total int, phase string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phase = phase
+	t.phaseIndex = index
+	t.phaseTotal = total
+}
+
+// position returns the current phase and how many steps have started.
+func (t *progressTracker) position() (string, int, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.phase, t.completed, t.total
+}
+
+// skip removes steps that won't run, such as those a resumed setup already
+// completed, from the estimate.
+func (t *progressTracker) skip(steps int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.total -= steps
+	if t.total < t.completed {
+		t.total = t.completed
+	}
+}
+
+// step records that a step has started and returns the resulting snapshot.
+// The ETA is extrapolated from the average duration of the finished steps.
+func (t *progressTracker) step(node, step string, nodeStep, nodeSteps int) ProgressUpdate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.completed >= t.total {
+		// The estimate was too low; keep the totals consistent
+		t.total = t.completed + 1
+	}
+	elapsed := time.Since(t.started)
+	var eta time.Duration
+	if t.completed > 0 {
+		eta = elapsed / time.Duration(t.completed) * time.Duration(t.total-t.completed)
+	}
+	update := ProgressUpdate{
+		Phase:      t.phase,
+		PhaseIndex: t.phaseIndex,
+		PhaseTotal: t.phaseTotal,
+		Node:       node,
+		Step:       step,
+		NodeStep:   nodeStep,
+		NodeSteps:  nodeSteps,
+		Completed:  t.completed,
+		Total:      t.total,
+		Elapsed:    elapsed,
+		ETA:        eta,
+	}
+	t.completed++
+	return update
+}
+
+// nodeProgress reports the fixed sequence of steps run on one node.
+type nodeProgress struct {
+	cm    *ClusterManager
+	node  string
+	steps []string
+	next  int
+}
+
+// nodeProgress starts reporting the given steps for a node.
+func (cm *ClusterManager) nodeProgress(node string, steps []string) *nodeProgress {
+	return &nodeProgress{cm: cm, node: node, steps: steps}
+}
+
+// advance announces the next step of the node.
+func (p *nodeProgress) advance() {
+	if p.next >= len(p.steps) {
+		return
+	}
+	step := p.steps[p.next]
+	p.next++
+	if p.cm.tracker == nil {
+		p.cm.tracker = newProgressTracker(p.cm.estimateSetupSteps())
+	}
+	update := p.cm.tracker.step(p.node, step, p.next, len(p.steps))
+	p.cm.publish(Event{Type: EventStepStarted, Phase: update.Phase, Node: p.node, Name: step, Progress: &update})
+}
+
+// Steps run on each node during setup, in order.
+var (
+	etcdSteps = []string{
+		"Installing etcd",
+		"Copying etcd certificates",
+		"Starting etcd",
+	}
+	controlPlaneSteps = []string{
+		"Installing control plane binaries",
+		"Copying control plane files",
+		"Installing services",
+		"Starting kube-apiserver",
+		"Starting kube-controller-manager and kube-scheduler",
+	}
+	workerSteps = []string{
+		"Installing dependencies",
+		"Installing CNI plugins",
+		"Installing containerd",
+		"Installing Kubernetes binaries",
+		"Copying certificates",
+		"Uploading configuration",
+		"Installing services",
+		"Starting services",
+		"Waiting for node Ready",
+	}
+	validationSteps = []string{
+		"Checking nodes",
+		"Checking system pods",
+		"Deploying test application",
+	}
+)
+
+// destroyCommands are run on each node when the cluster is destroyed, in order.
+var destroyCommands = []struct {
+	step    string
+	command string
+}{
+	{"Stopping services", "sudo systemctl stop etcd kube-apiserver kube-controller-manager kube-scheduler containerd kubelet kube-proxy || true"},
+	{"Disabling services", "sudo systemctl disable etcd kube-apiserver kube-controller-manager kube-scheduler containerd kubelet kube-proxy || true"},
+	{"Removing data directories", "sudo rm -rf /etc/etcd /var/lib/etcd /etc/kubernetes /var/lib/kubernetes /var/lib/kubelet /var/lib/kube-proxy /etc/cni /opt/cni /var/run/kubernetes"},
+	{"Removing binaries", "sudo rm -f /usr/local/bin/etcd* /usr/local/bin/kube* /usr/local/bin/runc /bin/containerd* " + bakeMarkerPath},
+	{"Removing unit files", "sudo rm -f /etc/systemd/system/etcd.service /etc/systemd/system/kube*.service /etc/systemd/system/containerd.service"},
+	{"Reloading systemd", "sudo systemctl daemon-reload"},
+	{"Resetting failed units", "sudo systemctl reset-failed"},
+}
+
+// destroySteps lists the step names of destroyCommands.
+func destroySteps() []string {
+	steps := make([]string, len(destroyCommands))
+	for i, cmd := range destroyCommands {
+		steps[i] = cmd.step
+	}
+	return steps
+}
+
+// clientCertificateNames lists the client certificates generated for the cluster.
+func (cm *ClusterManager) clientCertificateNames() []string {
+	names := []string{
+		"admin",
+		"front-proxy-client",
+		"kube-controller-manager",
+		"kube-proxy",
+		"kube-scheduler",
+		"service-account",
+	}
+	for _, worker := range cm.config.Workers {
+		names = append(names, worker.Name)
+	}
+	return names
+}
+
+// kubeletServingBootstrap reports whether kubelets request their own serving
+// certificates instead of receiving one issued during setup.
+func (cm *ClusterManager) kubeletServingBootstrap() bool {
+	return cm.config.KubeletServingCerts == KubeletServingBootstrap
+}
+
+// kubeletServingCertificateName returns the name of the serving certificate
+// issued to a worker's kubelet.
+func kubeletServingCertificateName(worker Node) string {
+	return worker.Name + "-serving"
+}
+
+// kubeletServingCertificateNames lists the kubelet serving certificates
+// generated for the cluster.
+func (cm *ClusterManager) kubeletServingCertificateNames() []string {
+	if cm.kubeletServingBootstrap() {
+		return nil
+	}
+	var names []string
+	for _, worker := range cm.config.Workers {
+		names = append(names, kubeletServingCertificateName(worker))
+	}
+	return names
+}
+
+// estimateSetupSteps estimates the number of steps in a full setup.
+func (cm *ClusterManager) estimateSetupSteps() int {
+	total := 0
+	for _, steps := range cm.setupPhaseSteps() {
+		total += steps
+	}
+	return total
+}
+
+// setupPhaseSteps estimates the steps of each setup phase, keyed by the
+// phase's name in the setup state.
+func (cm *ClusterManager) setupPhaseSteps() map[string]int {
+	workers := len(cm.config.Workers)
+	certificates := 2 + len(cm.clientCertificateNames()) + len(cm.kubeletServingCertificateNames())
+	configurations := 1 + workers + 4
+	if cm.config.KubeVIP.Enabled {
+		configurations++
+	}
+	workerSetup := workers * len(workerSteps)
+	if cm.kubeletServingBootstrap() {
+		workerSetup++
+	}
+	networking := 1
+	if cm.cni().podRoutes() {
+		networking += workers + len(cm.config.ControlPlane())
+	}
+	if len(cm.config.PriorityClasses) > 0 {
+		networking++
+	}
+	etcd := len(cm.config.EtcdNodes()) * len(etcdSteps)
+	validation := len(validationSteps)
+	if cm.config.WebhookSmokeTest {
+		validation++
+	}
+	if cm.config.ResourceDefaults.Enabled() {
+		validation++
+	}
+	controlPlane := len(cm.config.ControlPlane()) * len(controlPlaneSteps)
+	if len(cm.cni().deployScript()) > 0 {
+		controlPlane++
+	}
+	return map[string]int{
+		"certificates":   certificates,
+		"configurations": configurations,
+		"control-plane":  etcd + controlPlane,
+		"workers":        workerSetup,
+		"networking":     networking,
+		"validation":     validation,
+		"addons":         len(cm.config.Addons),
+	}
+}
@@ -0,0 +1,138 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// proxy.go routes node downloads and image pulls through an HTTP proxy.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ProxyConfig holds the proxy nodes reach the internet through. HTTPProxy
+// and HTTPSProxy are URLs such as http://proxy.corp:3128; NoProxy lists
+// further hosts, domains and CIDRs to reach directly, on top of the cluster's
+// own addresses which always bypass the proxy.
+type ProxyConfig struct {
+	HTTPProxy  string `yaml:"http_proxy,omitempty"`
+	HTTPSProxy string `yaml:"https_proxy,omitempty"`
+	NoProxy    string `yaml:"no_proxy,omitempty"`
+}
+
+// proxyServices are the units that get the proxy environment: containerd
+// pulls images and the kubelet runs image credential providers.
+var proxyServices = []string{"containerd", "kubelet"}
+
+// enabled reports whether a proxy is configured.
+func (p ProxyConfig) enabled() bool {
+	return p.HTTPProxy != "" || p.HTTPSProxy != ""
+}
+
+// validateProxy checks that the proxies are http or https URLs.
+func validateProxy(config ProxyConfig) error {
+	for name, proxy := range map[string]string{"http_proxy": config.HTTPProxy, "https_proxy": config.HTTPSProxy} {
+		if proxy == "" {
+			continue
+		}
+		u, err := url.Parse(proxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s %q must be an http:// or https:// URL", name, proxy)
+		}
+	}
+	// The settings are quoted in wget commands and systemd drop-ins
+	if strings.ContainsAny(config.HTTPProxy+config.HTTPSProxy+config.NoProxy, "\"'\\") {
+		return fmt.Errorf("proxy settings must not contain quotes or backslashes")
+	}
+	if config.NoProxy != "" && !config.enabled() {
+		return fmt.Errorf("no_proxy needs http_proxy or https_proxy")
+	}
+	return nil
+}
+
+// noProxy returns the hosts that bypass the proxy: loopback, the nodes, the
+// pod and service networks and cluster DNS names, then the configured ones.
+func (cm *ClusterManager) noProxy() string {
+	hosts := []string{"localhost", "127.0.0.1"}
+	for _, node := range cm.config.Nodes() {
+		if !contains(hosts, node.IPAddress) {
+			hosts = append(hosts, node.IPAddress)
+		}
+	}
+	if cm.config.ControlPlaneEndpoint != "" {
+		hosts = append(hosts, cm.config.ControlPlaneEndpoint)
+	}
+	hosts = append(hosts, cm.config.PodCIDR, cm.config.ServiceCIDR, ".svc", ".cluster.local")
+	for _, host := range strings.Split(cm.config.Proxy.NoProxy, ",") {
+		if host = strings.TrimSpace(host); host != "" && !contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return strings.Join(hosts, ",")
+}
+
+// proxyEnvironment returns the proxy variables, or nil without a proxy.
+func (cm *ClusterManager) proxyEnvironment() []string {
+	proxy := cm.config.Proxy
+	if !proxy.enabled() {
+		return nil
+	}
+	var env []string
+	if proxy.HTTPProxy != "" {
+		env = append(env, "HTTP_PROXY="+proxy.HTTPProxy)
+	}
+	if proxy.HTTPSProxy != "" {
+		env = append(env, "HTTPS_PROXY="+proxy.HTTPSProxy)
+	}
+	return append(env, "NO_PROXY="+cm.noProxy())
+}
+
+// wget returns the wget invocation of node downloads, passing it the proxy,
+// which wget only reads from lowercase variables.
+func (cm *ClusterManager) wget() string {
+	env := cm.proxyEnvironment()
+	if env == nil {
+		return "wget"
+	}
+	command := "env"
+	for _, variable := range env {
+		name, value, _ := strings.Cut(variable, "=")
+		command += fmt.Sprintf(" %s='%s'", strings.ToLower(name), value)
+	}
+	return command + " wget"
+}
+
+// proxyDropInPath returns the path of a service's systemd drop-in setting
+// the proxy environment.
+func proxyDropInPath(service string) string {
+	return "/etc/systemd/system/" + service + ".service.d/http-proxy.conf"
+}
+
+// generateProxyDropIn generates the systemd drop-in passing the proxy to a
+// service. Without a proxy it sets nothing, so that a removed proxy clears
+// the one a running cluster was set up with.
+func (cm *ClusterManager) generateProxyDropIn() string {
+	dropIn := "[Service]\n"
+	for _, variable := range cm.proxyEnvironment() {
+		dropIn += fmt.Sprintf("Environment=\"%s\"\n", variable)
+	}
+	return dropIn
+}
+
+// installProxyDropIns passes the proxy to containerd and the kubelet of a
+// worker. Workers without a proxy get no drop-ins.
+func (cm *ClusterManager) installProxyDropIns(ctx context.Context, worker Node) error {
+	if !cm.config.Proxy.enabled() {
+		return nil
+	}
+	for _, service := range proxyServices {
+		dropIn := proxyDropInPath(service)
+		if _, err := cm.sshClient.ExecuteCommand(ctx, worker.IPAddress, "sudo mkdir -p "+path.Dir(dropIn)); err != nil {
+			return fmt.Errorf("failed to create the %s drop-in directory on %s: %w", service, worker.Name, err)
+		}
+		if err := cm.sshClient.CopyContent(ctx, worker.IPAddress, cm.generateProxyDropIn(), dropIn); err != nil {
+			return fmt.Errorf("failed to upload the %s proxy drop-in to %s: %w", service, worker.Name, err)
+		}
+	}
+	return nil
+}
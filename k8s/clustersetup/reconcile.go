@@ -0,0 +1,374 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// reconcile.go applies changes to the configuration of a running cluster.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// nodeFile is a file setup generates on a node and the service that reads it.
+type nodeFile struct {
+	path    string
+	content string
+	service string
+}
+
+// nodeUpdate holds the changed files of a node and the services to restart.
+type nodeUpdate struct {
+	node     Node
+	files    []nodeFile
+	reload   bool
+	services []string
+}
+
+// reconcileManifests are the cluster addons, in the order they're applied.
+var reconcileManifests = []struct {
+	name     string
+	generate func(cm *ClusterManager) string
+}{
+	{"priority-classes", func(cm *ClusterManager) string {
+		if len(cm.config.PriorityClasses) == 0 {
+			return ""
+		}
+		return cm.generatePriorityClassesManifest()
+	}},
+	{"resource-defaults", func(cm *ClusterManager) string {
+		if !cm.config.ResourceDefaults.Enabled() {
+			return ""
+		}
+		return cm.generateResourceDefaultsManifest()
+	}},
+	{"coredns", func(cm *ClusterManager) string { return cm.generateCoreDNSManifest() }},
+}
+
+// ApplyConfigChanges applies a changed config to the running cluster without
+// rebuilding it. The config is diffed against the one recorded in the setup
+// state, or the manager's config for setups recorded before configs were:
+//
+//   - Changed unit files and node configs, such as systemd limits, hardening
+//     or the sandbox image, are uploaded and only the services reading them
+//     are restarted, one node at a time.
+//   - New names for the API server, such as a controller's public address,
+//     reissue its certificate and restart the API servers.
+//   - Changed CoreDNS, priority class and resource default settings are
+//     applied again. Objects removed from the config are left in the cluster.
+//   - Addons added to the list are installed, and those dropped from it
+//     removed.
+//
+// Settings such as the hooks and notifications take effect with the next
+// operation. Changes to the nodes, the CIDRs, the pod network or component
+// versions are rejected, since they need AddWorkerNode, RemoveWorkerNode,
+// UpgradeCluster or a new setup. The config is recorded once applied.
+func (cm *ClusterManager) ApplyConfigChanges(ctx context.Context, newConfig ClusterConfig) (err error) {
+	state, err := LoadSetupState(cm.config.WorkDir)
+	if err != nil {
+		return fmt.Errorf("no setup to apply changes to: %w", err)
+	}
+	if !state.Done("validation") {
+		return fmt.Errorf("the setup of %s is incomplete; resume it before applying changes", state.ClusterName)
+	}
+	applied := cm.config
+	if state.Config != nil {
+		applied = *state.Config
+	}
+	if err := checkConfigChanges(applied, newConfig); err != nil {
+		return err
+	}
+
+	previous := &ClusterManager{config: applied}
+	original := cm.config
+	cm.config = newConfig
+	defer func() {
+		if err != nil {
+			cm.config = original
+		}
+	}()
+
+	reissue := !reflect.DeepEqual(previous.kubernetesCertificateHosts(), cm.kubernetesCertificateHosts())
+	updates := cm.nodeUpdates(previous, reissue)
+	var manifests []string
+	for _, manifest := range reconcileManifests {
+		content := manifest.generate(cm)
+		if content != "" && content != manifest.generate(previous) {
+			manifests = append(manifests, manifest.name)
+		}
+	}
+	added, dropped := addonChanges(applied.Addons, newConfig.Addons)
+	if !reissue && len(updates) == 0 && len(manifests) == 0 && len(added)+len(dropped) == 0 {
+		cm.recordConfig()
+		cm.logger.Info("The cluster already runs with this config")
+		return nil
+	}
+
+	steps := 2*len(updates) + len(manifests) + len(added) + len(dropped)
+	if reissue {
+		steps++
+	}
+	cm.tracker = newProgressTracker(steps)
+	cm.startPhase(1, 1, "Applying Config Changes")
+
+	if reissue {
+		cm.nodeProgress("", []string{"Reissuing kubernetes certificate"}).advance()
+		if err := cm.certManager.GenerateServerCert(cm.config.WorkDir, "kubernetes", cm.kubernetesCertificateHosts(), cm.config.Certificates); err != nil {
+			return fmt.Errorf("failed to reissue server certificate: %w", err)
+		}
+		cm.publish(Event{Type: EventCertificateIssued, Name: "kubernetes"})
+		if err := cm.writeRemoteAdminKubeconfig(cm.config.WorkDir); err != nil {
+			return err
+		}
+	}
+
+	for _, update := range updates {
+		if err := cm.updateNode(ctx, update, reissue); err != nil {
+			return err
+		}
+	}
+
+	if len(manifests) > 0 {
+		var progressSteps []string
+		for _, name := range manifests {
+			progressSteps = append(progressSteps, "Applying "+name)
+		}
+		progress := cm.nodeProgress(cm.config.Controller.Name, progressSteps)
+		for _, manifest := range reconcileManifests {
+			if !contains(manifests, manifest.name) {
+				continue
+			}
+			progress.advance()
+			if err := cm.applyManifest(ctx, manifest.name, manifest.generate(cm)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(added)+len(dropped) > 0 {
+		var progressSteps []string
+		for _, addon := range dropped {
+			progressSteps = append(progressSteps, "Removing "+addon.Name())
+		}
+		for _, addon := range added {
+			progressSteps = append(progressSteps, "Installing "+addon.Name())
+		}
+		progress := cm.nodeProgress(cm.config.Controller.Name, progressSteps)
+		for _, addon := range dropped {
+			progress.advance()
+			if err := cm.RemoveAddon(ctx, addon); err != nil {
+				return err
+			}
+		}
+		for _, addon := range added {
+			progress.advance()
+			if err := cm.InstallAddon(ctx, addon); err != nil {
+				return err
+			}
+		}
+	}
+
+	cm.recordConfig()
+	cm.logger.Info(fmt.Sprintf("Applied config changes to %d nodes and %d addons", len(updates), len(manifests)+len(added)+len(dropped)))
+	return nil
+}
+
+// checkConfigChanges rejects changes that can't be applied to a running
+// cluster.
+func checkConfigChanges(applied, changed ClusterConfig) error {
+	// The controllers' public addresses only add names to the API server
+	// certificate
+	controlPlane := func(config ClusterConfig) []Node {
+		nodes := append([]Node{}, config.ControlPlane()...)
+		for i := range nodes {
+			nodes[i].PublicAddress = ""
+		}
+		return nodes
+	}
+	fixed := []struct {
+		name          string
+		before, after interface{}
+	}{
+		{"cluster_name", applied.ClusterName, changed.ClusterName},
+		{"work_dir", applied.WorkDir, changed.WorkDir},
+		{"etcd_version", applied.EtcdVersion, changed.EtcdVersion},
+		{"containerd_version", applied.ContainerdVersion, changed.ContainerdVersion},
+		{"cni_version", applied.CNIVersion, changed.CNIVersion},
+		{"cni_provider", applied.CNIProvider, changed.CNIProvider},
+		{"cni_provider_version", applied.CNIProviderVersion, changed.CNIProviderVersion},
+		{"pod_cidr", applied.PodCIDR, changed.PodCIDR},
+		{"service_cidr", applied.ServiceCIDR, changed.ServiceCIDR},
+		{"cluster_dns", applied.ClusterDNS, changed.ClusterDNS},
+		{"kubelet_serving_certs", applied.KubeletServingCerts, changed.KubeletServingCerts},
+		{"control_plane_endpoint", applied.ControlPlaneEndpoint, changed.ControlPlaneEndpoint},
+		{"kube_vip", applied.KubeVIP, changed.KubeVIP},
+		{"controllers", controlPlane(applied), controlPlane(changed)},
+		{"etcd", applied.Etcd, changed.Etcd},
+	}
+	var reasons []string
+	if applied.KubernetesVersion != changed.KubernetesVersion {
+		reasons = append(reasons, fmt.Sprintf("kubernetes_version changed from %s to %s; upgrade the cluster instead",
+			applied.KubernetesVersion, changed.KubernetesVersion))
+	}
+	if !reflect.DeepEqual(applied.Workers, changed.Workers) {
+		reasons = append(reasons, "workers changed; add or remove them one at a time instead")
+	}
+	// The images of an air-gapped cluster are imported when workers are set up
+	if added, _ := addonChanges(applied.Addons, changed.Addons); changed.AirGapped && len(added) > 0 {
+		reasons = append(reasons, "addons can't be added to an air-gapped cluster, whose workers lack their images")
+	}
+	for _, field := range fixed {
+		if !reflect.DeepEqual(field.before, field.after) {
+			reasons = append(reasons, field.name+" can't change without a new setup")
+		}
+	}
+	if len(reasons) > 0 {
+		return fmt.Errorf("config changes can't be applied: %s", strings.Join(reasons, "; "))
+	}
+	return nil
+}
+
+// nodeFiles returns the unit files and configs setup generates on a node,
+// by the roles it has.
+func (cm *ClusterManager) nodeFiles(node Node) []nodeFile {
+	var files []nodeFile
+	unit := func(service, content string) {
+		files = append(files, nodeFile{path: "/etc/systemd/system/" + service + ".service", content: content, service: service})
+	}
+	if hasNode(cm.config.EtcdNodes(), node) {
+		unit("etcd", cm.generateEtcdService(node))
+	}
+	if hasNode(cm.config.ControlPlane(), node) {
+		unit("kube-apiserver", cm.generateAPIServerService(node))
+		unit("kube-controller-manager", cm.generateControllerManagerService())
+		unit("kube-scheduler", cm.generateSchedulerService())
+	}
+	if hasNode(cm.config.Workers, node) {
+		files = append(files,
+			nodeFile{path: "/etc/containerd/config.toml", content: cm.generateContainerdConfig(), service: "containerd"},
+			nodeFile{path: "/var/lib/kubelet/kubelet-config.yaml", content: cm.generateKubeletConfig(node), service: "kubelet"},
+			nodeFile{path: "/var/lib/kube-proxy/kube-proxy-config.yaml", content: cm.generateKubeProxyConfig(), service: "kube-proxy"})
+		unit("containerd", cm.generateContainerdService())
+		unit("kubelet", cm.generateKubeletService(node))
+		unit("kube-proxy", cm.generateKubeProxyService())
+		for _, service := range proxyServices {
+			files = append(files, nodeFile{path: proxyDropInPath(service), content: cm.generateProxyDropIn(), service: service})
+		}
+	}
+	return files
+}
+
+// nodeUpdates compares the files of every node with those generated from the
+// previous config. With a reissued API server certificate the controllers'
+// API servers restart too.
+func (cm *ClusterManager) nodeUpdates(previous *ClusterManager, reissue bool) []nodeUpdate {
+	var updates []nodeUpdate
+	for _, node := range cm.config.Nodes() {
+		update := nodeUpdate{node: node}
+		before := previous.nodeFiles(node)
+		for i, file := range cm.nodeFiles(node) {
+			if file.content == before[i].content {
+				continue
+			}
+			update.files = append(update.files, file)
+			update.reload = update.reload || strings.HasPrefix(file.path, "/etc/systemd/system/")
+			if !contains(update.services, file.service) {
+				update.services = append(update.services, file.service)
+			}
+		}
+		if reissue && hasNode(cm.config.ControlPlane(), node) && !contains(update.services, "kube-apiserver") {
+			update.services = append(update.services, "kube-apiserver")
+		}
+		if len(update.services) > 0 {
+			updates = append(updates, update)
+		}
+	}
+	return updates
+}
+
+// updateNode uploads the changed files of a node and restarts the services
+// reading them, waiting for each to come back before moving on.
+func (cm *ClusterManager) updateNode(ctx context.Context, update nodeUpdate, reissue bool) error {
+	node := update.node
+	controller := hasNode(cm.config.ControlPlane(), node)
+	progress := cm.nodeProgress(node.Name, []string{"Uploading changed files", "Restarting " + strings.Join(update.services, ", ")})
+
+	progress.advance()
+	// Drop-in directories of a proxy added later don't exist yet
+	var dirs []string
+	for _, file := range update.files {
+		if dir := path.Dir(file.path); !contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	if _, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, "sudo mkdir -p "+strings.Join(dirs, " ")); err != nil {
+		return fmt.Errorf("failed to create directories on %s: %w", node.Name, err)
+	}
+	for _, file := range update.files {
+		if err := cm.sshClient.CopyContent(ctx, node.IPAddress, file.content, file.path); err != nil {
+			return fmt.Errorf("failed to upload %s to %s: %w", file.path, node.Name, err)
+		}
+	}
+	if reissue && controller {
+		for _, file := range []string{"kubernetes.pem", "kubernetes-key.pem"} {
+			if err := cm.sshClient.CopyFile(ctx, node.IPAddress, filepath.Join(cm.config.WorkDir, file), "/var/lib/kubernetes/"+file); err != nil {
+				return fmt.Errorf("failed to copy %s to %s: %w", file, node.Name, err)
+			}
+		}
+	}
+
+	progress.advance()
+	if update.reload {
+		if _, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, "sudo systemctl daemon-reload"); err != nil {
+			return fmt.Errorf("failed to reload systemd on %s: %w", node.Name, err)
+		}
+	}
+	if err := cm.restartServices(ctx, node, update.services...); err != nil {
+		return err
+	}
+	for _, service := range update.services {
+		if err := cm.waitForService(ctx, node.IPAddress, service, 60*time.Second); err != nil {
+			return fmt.Errorf("%s on %s failed to become healthy: %w", service, node.Name, err)
+		}
+	}
+	if controller && contains(update.services, "kube-apiserver") {
+		if err := cm.waitForAPIServer(ctx, node, apiServerReadyTimeout); err != nil {
+			return err
+		}
+	}
+	if hasNode(cm.config.Workers, node) {
+		if err := cm.waitForNodeReady(ctx, node, nodeReadyTimeout); err != nil {
+			return err
+		}
+	}
+
+	cm.publish(Event{Type: EventNodeCompleted, Phase: "reconcile", Node: node.Name})
+	cm.logger.Info(fmt.Sprintf("Restarted %s on %s", strings.Join(update.services, ", "), node.Name))
+	return nil
+}
+
+// applyManifest uploads a manifest to the controller and applies it.
+func (cm *ClusterManager) applyManifest(ctx context.Context, name, manifest string) error {
+	controller := cm.config.Controller
+	manifestPath := "/tmp/" + name + ".yaml"
+	if err := cm.sshClient.CopyContent(ctx, controller.IPAddress, manifest, manifestPath); err != nil {
+		return fmt.Errorf("failed to upload %s manifest: %w", name, err)
+	}
+	applyCmd := fmt.Sprintf("kubectl apply -f %s --kubeconfig /var/lib/kubernetes/admin.kubeconfig", manifestPath)
+	if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, applyCmd); err != nil {
+		return fmt.Errorf("failed to apply %s manifest: %w", name, err)
+	}
+	return nil
+}
+
+// hasNode reports whether a node of the same name is among nodes.
+func hasNode(nodes []Node, node Node) bool {
+	for _, n := range nodes {
+		if n.Name == node.Name {
+			return true
+		}
+	}
+	return false
+}
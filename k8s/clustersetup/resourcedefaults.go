@@ -0,0 +1,161 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// resourcedefaults.go applies default LimitRanges and ResourceQuotas after setup.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ResourceDefaultsConfig gives namespaces a LimitRange and a ResourceQuota
+// once the cluster is up. Namespaces that don't exist yet are created; without
+// a list only the default namespace is covered.
+type ResourceDefaultsConfig struct {
+	Namespaces    []string          `yaml:"namespaces,omitempty"`
+	LimitRange    *LimitRangeConfig `yaml:"limit_range,omitempty"`
+	ResourceQuota map[string]string `yaml:"resource_quota,omitempty"`
+}
+
+// LimitRangeConfig holds the per-container defaults and bounds of a
+// LimitRange. Default sets the limits and DefaultRequest the requests of
+// containers that don't declare their own.
+type LimitRangeConfig struct {
+	Default        ResourceList `yaml:"default,omitempty"`
+	DefaultRequest ResourceList `yaml:"default_request,omitempty"`
+	Min            ResourceList `yaml:"min,omitempty"`
+	Max            ResourceList `yaml:"max,omitempty"`
+}
+
+// Enabled reports whether any resource defaults are configured.
+func (r ResourceDefaultsConfig) Enabled() bool {
+	return r.LimitRange != nil || len(r.ResourceQuota) > 0
+}
+
+// namespaces returns the namespaces to apply the defaults to.
+func (r ResourceDefaultsConfig) namespaces() []string {
+	if len(r.Namespaces) > 0 {
+		return r.Namespaces
+	}
+	return []string{"default"}
+}
+
+// namespacePattern matches a valid namespace name.
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// quotaResourcePattern matches a ResourceQuota resource name such as "pods",
+// "requests.cpu" or "count/deployments.apps".
+var quotaResourcePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9./]*[a-z0-9])?$`)
+
+// validateResourceDefaults checks namespace names and resource quantities.
+func validateResourceDefaults(config ResourceDefaultsConfig) error {
+	for _, namespace := range config.Namespaces {
+		if len(namespace) > 63 || !namespacePattern.MatchString(namespace) {
+			return fmt.Errorf("namespace %q is not a valid name", namespace)
+		}
+	}
+	if lr := config.LimitRange; lr != nil {
+		for name, list := range map[string]ResourceList{
+			"default": lr.Default, "default_request": lr.DefaultRequest, "min": lr.Min, "max": lr.Max,
+		} {
+			for resource, quantity := range map[string]string{"cpu": list.CPU, "memory": list.Memory} {
+				if quantity != "" && !quantityPattern.MatchString(quantity) {
+					return fmt.Errorf("limit_range.%s.%s has invalid quantity %q", name, resource, quantity)
+				}
+			}
+		}
+	}
+	for resource, quantity := range config.ResourceQuota {
+		if !quotaResourcePattern.MatchString(resource) {
+			return fmt.Errorf("resource_quota has invalid resource %q", resource)
+		}
+		if !quantityPattern.MatchString(quantity) {
+			return fmt.Errorf("resource_quota.%s has invalid quantity %q", resource, quantity)
+		}
+	}
+	return nil
+}
+
+// generateResourceDefaultsManifest generates the namespaces, LimitRanges and
+// ResourceQuotas to apply.
+func (cm *ClusterManager) generateResourceDefaultsManifest() string {
+	defaults := cm.config.ResourceDefaults
+	var docs []string
+	for _, namespace := range defaults.namespaces() {
+		docs = append(docs, fmt.Sprintf(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: %s
+`, namespace))
+
+		if lr := defaults.LimitRange; lr != nil {
+			limits := "  - type: Container\n"
+			for _, section := range []struct {
+				name string
+				list ResourceList
+			}{{"default", lr.Default}, {"defaultRequest", lr.DefaultRequest}, {"min", lr.Min}, {"max", lr.Max}} {
+				if section.list.CPU == "" && section.list.Memory == "" {
+					continue
+				}
+				limits += fmt.Sprintf("    %s:\n", section.name)
+				if section.list.CPU != "" {
+					limits += fmt.Sprintf("      cpu: %s\n", section.list.CPU)
+				}
+				if section.list.Memory != "" {
+					limits += fmt.Sprintf("      memory: %s\n", section.list.Memory)
+				}
+			}
+			docs = append(docs, fmt.Sprintf(`apiVersion: v1
+kind: LimitRange
+metadata:
+  name: default-limits
+  namespace: %s
+spec:
+  limits:
+%s`, namespace, limits))
+		}
+
+		if len(defaults.ResourceQuota) > 0 {
+			resources := make([]string, 0, len(defaults.ResourceQuota))
+			for resource := range defaults.ResourceQuota {
+				resources = append(resources, resource)
+			}
+			sort.Strings(resources)
+			hard := ""
+			for _, resource := range resources {
+				hard += fmt.Sprintf("    %s: %q\n", resource, defaults.ResourceQuota[resource])
+			}
+			docs = append(docs, fmt.Sprintf(`apiVersion: v1
+kind: ResourceQuota
+metadata:
+  name: default-quota
+  namespace: %s
+spec:
+  hard:
+%s`, namespace, hard))
+		}
+	}
+	return strings.Join(docs, "---\n")
+}
+
+// applyResourceDefaults applies the configured LimitRanges and ResourceQuotas
+// from the controller.
+func (cm *ClusterManager) applyResourceDefaults(ctx context.Context) error {
+	if !cm.config.ResourceDefaults.Enabled() {
+		return nil
+	}
+	cm.nodeProgress("", []string{"Applying resource defaults"}).advance()
+	controller := cm.config.Controller
+	manifestPath := "/tmp/resource-defaults.yaml"
+	if err := cm.sshClient.CopyContent(ctx, controller.IPAddress, cm.generateResourceDefaultsManifest(), manifestPath); err != nil {
+		return fmt.Errorf("failed to upload resource defaults: %w", err)
+	}
+	applyCmd := fmt.Sprintf("kubectl apply -f %s --kubeconfig /var/lib/kubernetes/admin.kubeconfig", manifestPath)
+	if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, applyCmd); err != nil {
+		return fmt.Errorf("failed to apply resource defaults: %w", err)
+	}
+	cm.logger.Info(fmt.Sprintf("Applied resource defaults to %s", strings.Join(cm.config.ResourceDefaults.namespaces(), ", ")))
+	return nil
+}
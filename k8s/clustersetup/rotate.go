@@ -0,0 +1,166 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// rotate.go renews the cluster's certificates under its existing CA.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RotateCertificates renews every leaf certificate under the CA in the work
+// directory, copies them to the nodes and restarts the services using them
+// one node at a time, checking each before moving on. The CA and the service
+// account key pair are kept, so kubeconfigs and service account tokens stay
+// valid. Kubelets that bootstrap their serving certificates rotate those
+// themselves.
+func (cm *ClusterManager) RotateCertificates(ctx context.Context) error {
+	workDir := cm.config.WorkDir
+	for _, file := range []string{"ca.pem", "ca-key.pem", "ca-config.json"} {
+		if _, err := os.Stat(filepath.Join(workDir, file)); err != nil {
+			return fmt.Errorf("work directory %s doesn't hold the cluster's %s: %w", workDir, file, err)
+		}
+	}
+
+	var clientCerts []string
+	for _, name := range cm.clientCertificateNames() {
+		if name != "service-account" {
+			clientCerts = append(clientCerts, name)
+		}
+	}
+	etcdMembers, controllers, workers := cm.config.EtcdNodes(), cm.config.ControlPlane(), cm.config.Workers
+	renewSteps := len(clientCerts) + 1 + len(cm.kubeletServingCertificateNames())
+	cm.tracker = newProgressTracker(renewSteps + 2*(len(etcdMembers)+len(controllers)+len(workers)))
+
+	cm.startPhase(1, 2, "Renewing Certificates")
+	if err := cm.renewCertificates(workDir, clientCerts); err != nil {
+		return err
+	}
+	if err := cm.writeRemoteAdminKubeconfig(workDir); err != nil {
+		return err
+	}
+	controllerFiles := controllerCertificateFiles
+	if cm.config.KubeVIP.Enabled {
+		if err := cm.writeKubeVIPKubeconfig(workDir); err != nil {
+			return err
+		}
+		controllerFiles = append(append([]string{}, controllerFiles...), kubeVIPKubeconfig)
+	}
+
+	cm.startPhase(2, 2, "Distributing Certificates")
+	for _, member := range etcdMembers {
+		progress := cm.nodeProgress(member.Name, []string{"Copying certificates", "Restarting etcd"})
+		progress.advance()
+		for _, file := range etcdCertificateFiles {
+			remotePath := "/etc/etcd/" + file
+			if err := cm.sshClient.CopyFileOwned(ctx, member.IPAddress, filepath.Join(workDir, file), remotePath, "etcd:etcd"); err != nil {
+				return fmt.Errorf("failed to copy %s to %s: %w", file, member.Name, err)
+			}
+		}
+		progress.advance()
+		if err := cm.restartServices(ctx, member, "etcd"); err != nil {
+			return err
+		}
+		if err := cm.waitForService(ctx, member.IPAddress, "etcd", 30*time.Second); err != nil {
+			return fmt.Errorf("etcd on %s failed to become healthy: %w", member.Name, err)
+		}
+		cm.publish(Event{Type: EventNodeCompleted, Phase: "rotate", Node: member.Name})
+	}
+
+	for _, controller := range controllers {
+		progress := cm.nodeProgress(controller.Name, []string{"Copying certificates", "Restarting control plane services"})
+		progress.advance()
+		for _, file := range controllerFiles {
+			if err := cm.sshClient.CopyFile(ctx, controller.IPAddress, filepath.Join(workDir, file), "/var/lib/kubernetes/"+file); err != nil {
+				return fmt.Errorf("failed to copy %s to %s: %w", file, controller.Name, err)
+			}
+		}
+		progress.advance()
+		if err := cm.restartServices(ctx, controller, cm.controlPlaneServices()...); err != nil {
+			return err
+		}
+		for _, service := range cm.controlPlaneServices() {
+			if err := cm.waitForService(ctx, controller.IPAddress, service, 60*time.Second); err != nil {
+				return err
+			}
+		}
+		if err := cm.waitForAPIServer(ctx, controller, apiServerReadyTimeout); err != nil {
+			return err
+		}
+		cm.publish(Event{Type: EventNodeCompleted, Phase: "rotate", Node: controller.Name})
+	}
+
+	for _, worker := range workers {
+		progress := cm.nodeProgress(worker.Name, []string{"Copying certificates", "Restarting kubelet and kube-proxy"})
+		progress.advance()
+		for _, file := range cm.workerCertificateFiles(worker) {
+			if err := cm.sshClient.CopyFile(ctx, worker.IPAddress, filepath.Join(workDir, file), "/var/lib/kubelet/"+file); err != nil {
+				return fmt.Errorf("failed to copy %s to %s: %w", file, worker.Name, err)
+			}
+		}
+		progress.advance()
+		if err := cm.restartServices(ctx, worker, "kubelet", "kube-proxy"); err != nil {
+			return err
+		}
+		if err := cm.waitForNodeReady(ctx, worker, nodeReadyTimeout); err != nil {
+			return err
+		}
+		cm.publish(Event{Type: EventNodeCompleted, Phase: "rotate", Node: worker.Name})
+	}
+
+	cm.logger.Info("Certificates rotated")
+	return nil
+}
+
+// renewCertificates issues new client, API server and kubelet serving
+// certificates from the CA in workDir, replacing the old ones.
+func (cm *ClusterManager) renewCertificates(workDir string, clientCerts []string) error {
+	steps := []string{}
+	for _, name := range clientCerts {
+		steps = append(steps, "Renewing "+name+" certificate")
+	}
+	steps = append(steps, "Renewing kubernetes certificate")
+	for _, name := range cm.kubeletServingCertificateNames() {
+		steps = append(steps, "Renewing "+name+" certificate")
+	}
+	progress := cm.nodeProgress("", steps)
+
+	for _, name := range clientCerts {
+		progress.advance()
+		if err := cm.certManager.GenerateClientCert(workDir, name, cm.config.Certificates); err != nil {
+			return fmt.Errorf("failed to renew client certificate for %s: %w", name, err)
+		}
+		cm.publish(Event{Type: EventCertificateIssued, Name: name})
+	}
+	progress.advance()
+	if err := cm.certManager.GenerateServerCert(workDir, "kubernetes", cm.kubernetesCertificateHosts(), cm.config.Certificates); err != nil {
+		return fmt.Errorf("failed to renew server certificate: %w", err)
+	}
+	cm.publish(Event{Type: EventCertificateIssued, Name: "kubernetes"})
+	if cm.kubeletServingBootstrap() {
+		return nil
+	}
+	for _, worker := range cm.config.Workers {
+		name := kubeletServingCertificateName(worker)
+		progress.advance()
+		if err := cm.certManager.GenerateServerCert(workDir, name, kubeletServingHosts(worker), cm.config.Certificates); err != nil {
+			return fmt.Errorf("failed to renew kubelet serving certificate for %s: %w", worker.Name, err)
+		}
+		cm.publish(Event{Type: EventCertificateIssued, Name: name})
+	}
+	return nil
+}
+
+// restartServices restarts systemd units on a node.
+func (cm *ClusterManager) restartServices(ctx context.Context, node Node, services ...string) error {
+	cmd := "sudo systemctl restart"
+	for _, service := range services {
+		cmd += " " + service
+	}
+	if _, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, cmd); err != nil {
+		return fmt.Errorf("failed to restart services on %s: %w", node.Name, err)
+	}
+	return nil
+}
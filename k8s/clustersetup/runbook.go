@@ -0,0 +1,257 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// runbook.go exports the commands and files of a setup as a runbook to review or follow by hand.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// RunbookDir is the directory in the work directory the runbook, and the
+	// certificates and files it uploads, are exported to.
+	RunbookDir = "runbook"
+	// RunbookFile is the runbook's file name in RunbookDir.
+	RunbookFile = "RUNBOOK.md"
+)
+
+// runbookEntry is a command run on a node, or a file uploaded to it. Local
+// entries have no host.
+type runbookEntry struct {
+	phase   string
+	host    string
+	command string
+	// upload is the remote path of an uploaded file; source is its local
+	// path relative to the runbook directory
+	upload string
+	source string
+	// content is the uploaded content of files setup didn't write locally
+	content string
+	// denied is set for commands the cluster is expected to reject
+	denied bool
+}
+
+// runbook records what a setup does instead of doing it. It stands in for the
+// SSH client and answers the commands setup waits on as a healthy cluster
+// would, so the setup runs through every step.
+type runbook struct {
+	config  ClusterConfig
+	dir     string
+	names   map[string]string
+	phase   string
+	entries []runbookEntry
+}
+
+// newRunbook creates a runbook for the config, exported to its work directory.
+func newRunbook(config ClusterConfig) *runbook {
+	names := map[string]string{}
+	for _, node := range config.Nodes() {
+		if _, ok := names[node.IPAddress]; !ok {
+			names[node.IPAddress] = node.Name
+		}
+	}
+	return &runbook{config: config, dir: config.WorkDir, names: names}
+}
+
+// ExportRunbook renders every command, file and manifest a setup would run
+// on the nodes into RunbookFile without connecting to any of them, so the
+// setup can be reviewed, audited or carried out by hand. The certificates and
+// kubeconfigs it uploads are generated next to it in RunbookDir, leaving
+// those in the work directory alone. Local hooks are listed, not run. It
+// returns the runbook's path.
+func (cm *ClusterManager) ExportRunbook(ctx context.Context) (string, error) {
+	dir := filepath.Join(cm.config.WorkDir, RunbookDir)
+	// Files uploaded by an earlier export would otherwise linger
+	if err := os.RemoveAll(filepath.Join(dir, "files")); err != nil {
+		return "", fmt.Errorf("failed to clear the previous runbook: %w", err)
+	}
+
+	book, err := cm.recordSetup(ctx, dir, cm.certManager)
+	if err != nil {
+		return "", fmt.Errorf("failed to render runbook: %w", err)
+	}
+	// None of these were part of the setup being described
+	for _, name := range []string{SetupStateFile, factsDir, SSHConfigFile, InventoryFile} {
+		os.RemoveAll(filepath.Join(dir, name))
+	}
+
+	path := filepath.Join(dir, RunbookFile)
+	if err := cm.writeFile(path, book.render()); err != nil {
+		return "", fmt.Errorf("failed to write runbook: %w", err)
+	}
+	cm.logger.Info(fmt.Sprintf("Runbook with %d steps written to %s", len(book.entries), path))
+	return path, nil
+}
+
+// recordSetup runs a setup of the cluster against a runbook instead of the
+// nodes, with workDir as the work directory and certificates issued by
+// certManager.
+func (cm *ClusterManager) recordSetup(ctx context.Context, workDir string, certManager CertificateManager) (*runbook, error) {
+	config := cm.config
+	config.WorkDir = workDir
+	config.Notifications = NotificationConfig{}
+
+	book := newRunbook(config)
+	recorder := NewClusterManager(config, NewNopLogger(), book, certManager, NewNopProgressReporter())
+	recorder.runbook = book
+	recorder.events.Subscribe(func(event Event) {
+		if event.Type == EventPhaseStarted {
+			book.phase = fmt.Sprintf("%d/%d %s", event.Step, event.TotalSteps, event.Phase)
+		}
+	})
+	if err := recorder.SetupCluster(ctx); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// pause waits before the next setup step, or records the wait in the runbook
+// being exported.
+func (cm *ClusterManager) pause(host string, d time.Duration) {
+	if cm.runbook != nil {
+		cm.runbook.record(runbookEntry{host: host, command: fmt.Sprintf("sleep %d", int(d.Seconds()))})
+		return
+	}
+	time.Sleep(d)
+}
+
+// record appends an entry to the current phase.
+func (r *runbook) record(entry runbookEntry) {
+	entry.phase = r.phase
+	r.entries = append(r.entries, entry)
+}
+
+// ExecuteCommand records the command and answers it as a healthy node would.
+func (r *runbook) ExecuteCommand(ctx context.Context, host, command string) (string, error) {
+	entry := runbookEntry{host: host, command: command}
+	// The admission webhook smoke test expects this object to be rejected
+	if strings.Contains(command, "create configmap "+webhookDeniedName+" ") {
+		entry.denied = true
+		r.record(entry)
+		return "", fmt.Errorf("denied by %s", webhookName)
+	}
+	r.record(entry)
+
+	switch {
+	case command == "uname -s":
+		return "Linux\n", nil
+	case strings.Contains(command, "systemctl is-active "):
+		return "active\n", nil
+	case strings.HasPrefix(command, "kubectl get --raw=/readyz"):
+		return "ok\n", nil
+	case strings.HasPrefix(command, "kubectl get node ") && strings.Contains(command, " -o json "):
+		return fmt.Sprintf(`{"status":{"conditions":[{"type":"Ready","status":"True"}],"nodeInfo":{"kubeletVersion":%q}}}`, r.config.KubernetesVersion), nil
+	case command == listKubeletCSRsCommand:
+		var requests strings.Builder
+		for _, worker := range r.config.Workers {
+			fmt.Fprintf(&requests, "<csr-of-%s> kubernetes.io/kubelet-serving system:node:%s\n", worker.Name, worker.Name)
+		}
+		return requests.String(), nil
+	}
+	return "", nil
+}
+
+// ExecuteScript records the script as one step.
+func (r *runbook) ExecuteScript(ctx context.Context, host, script string) (string, error) {
+	r.record(runbookEntry{host: host, command: script})
+	return "", nil
+}
+
+// CopyFile records the upload of a file the setup generated.
+func (r *runbook) CopyFile(ctx context.Context, host, localPath, remotePath string) error {
+	source := localPath
+	if rel, err := filepath.Rel(r.dir, localPath); err == nil && !strings.HasPrefix(rel, "..") {
+		source = rel
+	}
+	r.record(runbookEntry{host: host, upload: remotePath, source: source})
+	return nil
+}
+
+// CopyFileOwned records the upload like CopyFile, followed by the chown
+// that hands it to owner.
+func (r *runbook) CopyFileOwned(ctx context.Context, host, localPath, remotePath, owner string) error {
+	if err := r.CopyFile(ctx, host, localPath, remotePath); err != nil {
+		return err
+	}
+	r.record(runbookEntry{host: host, command: fmt.Sprintf("sudo chown -R %s %s", owner, remotePath)})
+	return nil
+}
+
+// CopyContent saves the content under files/<node>/ in the runbook directory
+// and records its upload. Files are numbered in upload order, as setup
+// uploads some to the same path more than once.
+func (r *runbook) CopyContent(ctx context.Context, host, content, remotePath string) error {
+	source := filepath.Join("files", r.nodeName(host), fmt.Sprintf("%03d-%s", len(r.entries)+1, filepath.Base(remotePath)))
+	path := filepath.Join(r.dir, source)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create runbook directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", source, err)
+	}
+	r.record(runbookEntry{host: host, upload: remotePath, source: source, content: content})
+	return nil
+}
+
+// nodeName returns the name of the node with the address.
+func (r *runbook) nodeName(host string) string {
+	if name, ok := r.names[host]; ok {
+		return name
+	}
+	return host
+}
+
+// render formats the runbook as markdown: a section per phase, a heading
+// whenever the node changes, and shell blocks for the commands.
+func (r *runbook) render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Setup Runbook: %s\n\n", r.config.ClusterName)
+	fmt.Fprintf(&b, "Kubernetes %s, exported %s.\n\n", r.config.KubernetesVersion, time.Now().Format(time.RFC1123))
+	b.WriteString("Every step the automated setup takes, in order. Run each block on the node in the heading above it, " +
+		"after uploading the files listed before it. Paths of uploaded files are relative to this directory. " +
+		"Commands that check a service or node can be repeated until they succeed.\n")
+
+	phase, host := "", "-"
+	inBlock := false
+	closeBlock := func() {
+		if inBlock {
+			b.WriteString("```\n")
+			inBlock = false
+		}
+	}
+	for _, entry := range r.entries {
+		if entry.phase != phase {
+			closeBlock()
+			phase, host = entry.phase, "-"
+			fmt.Fprintf(&b, "\n## %s\n", phase)
+		}
+		if entry.host != host {
+			closeBlock()
+			host = entry.host
+			if host == "" {
+				b.WriteString("\n### Locally\n\n")
+			} else {
+				fmt.Fprintf(&b, "\n### %s (%s)\n\n", r.nodeName(host), host)
+			}
+		}
+		if entry.upload != "" {
+			closeBlock()
+			fmt.Fprintf(&b, "- Upload `%s` to `%s`\n", entry.source, entry.upload)
+			continue
+		}
+		if !inBlock {
+			b.WriteString("\n```sh\n")
+			inBlock = true
+		}
+		if entry.denied {
+			b.WriteString("# Expected to be denied by the admission webhook\n")
+		}
+		b.WriteString(strings.TrimRight(entry.command, "\n") + "\n")
+	}
+	closeBlock()
+	return b.String()
+}
@@ -0,0 +1,213 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// scale.go adds workers to and removes workers from an existing cluster.
+package clustersetup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// drainTimeout bounds how long a removed worker's pods get to be evicted.
+// The drain command itself is killed a minute later if it hangs.
+const drainTimeout = 5 * time.Minute
+
+// AddWorkerNode joins a new worker to the running cluster. It issues the
+// worker's certificates from the CA in the work directory, sets the node up
+// like setup does, waits for it to become Ready and adds pod routes between
+// it and the existing workers, and to it from the controllers. A node without
+// a pod CIDR is allocated one from the cluster's. The node is appended to the
+// manager's workers.
+func (cm *ClusterManager) AddWorkerNode(ctx context.Context, node Node) error {
+	workDir := cm.config.WorkDir
+	if node.Name == "" || node.IPAddress == "" {
+		return fmt.Errorf("worker %s configuration is incomplete", node.Name)
+	}
+	if node.Hostname == "" {
+		node.Hostname = node.Name
+	}
+	for _, existing := range cm.config.Nodes() {
+		if existing.Name == node.Name || existing.IPAddress == node.IPAddress {
+			return fmt.Errorf("%s shares its name or address with %s", node.Name, existing.Name)
+		}
+	}
+	if node.PodCIDR == "" {
+		config := cm.config
+		config.Workers = append(append([]Node{}, cm.config.Workers...), node)
+		if err := allocatePodCIDRs(&config); err != nil {
+			return fmt.Errorf("failed to allocate a pod CIDR for %s: %w", node.Name, err)
+		}
+		node.PodCIDR = config.Workers[len(config.Workers)-1].PodCIDR
+	}
+	podNet, serviceNet, err := parseNetworks(cm.config)
+	if err != nil {
+		return err
+	}
+	if err := validateNodeCIDR(node, podNet, serviceNet, cm.config.Workers); err != nil {
+		return err
+	}
+	if err := validateNodeTopology(node); err != nil {
+		return err
+	}
+	for _, file := range []string{"ca.pem", "ca-key.pem", "kube-proxy.kubeconfig"} {
+		if _, err := os.Stat(filepath.Join(workDir, file)); err != nil {
+			return fmt.Errorf("work directory %s doesn't hold the cluster's %s: %w", workDir, file, err)
+		}
+	}
+
+	others := cm.config.Workers
+	steps := []string{"Checking node", "Generating certificates", "Generating kubeconfig"}
+	cm.tracker = newProgressTracker(len(steps) + len(workerSteps) + 1)
+	cm.startPhase(1, 1, "Adding worker "+node.Name)
+	progress := cm.nodeProgress("", append(steps, "Adding pod routes"))
+
+	progress.advance()
+	if err := cm.checkNode(node); err != nil {
+		return err
+	}
+	if err := cm.checkNodeFacts(ctx, node, RoleWorker, workerRequirements); err != nil {
+		return err
+	}
+
+	progress.advance()
+	if err := cm.certManager.GenerateClientCert(workDir, node.Name, cm.config.Certificates); err != nil {
+		return fmt.Errorf("failed to generate client certificate for %s: %w", node.Name, err)
+	}
+	cm.publish(Event{Type: EventCertificateIssued, Name: node.Name})
+	if !cm.kubeletServingBootstrap() {
+		name := kubeletServingCertificateName(node)
+		if err := cm.certManager.GenerateServerCert(workDir, name, kubeletServingHosts(node), cm.config.Certificates); err != nil {
+			return fmt.Errorf("failed to generate kubelet serving certificate for %s: %w", node.Name, err)
+		}
+		cm.publish(Event{Type: EventCertificateIssued, Name: name})
+	}
+
+	progress.advance()
+	if err := cm.generateKubeconfig(workDir, node.Name, ""); err != nil {
+		return fmt.Errorf("failed to generate kubeconfig for %s: %w", node.Name, err)
+	}
+
+	if err := cm.setupSingleWorkerNode(ctx, workDir, node); err != nil {
+		return fmt.Errorf("failed to setup worker %s: %w", node.Name, err)
+	}
+	if cm.kubeletServingBootstrap() {
+		if err := cm.approveKubeletServingCSRs(ctx, []Node{node}, 2*time.Minute); err != nil {
+			return err
+		}
+	}
+
+	progress.advance()
+	if cm.cni().podRoutes() {
+		for _, other := range others {
+			if err := cm.addPodRoute(ctx, node, other); err != nil {
+				return err
+			}
+			if err := cm.addPodRoute(ctx, other, node); err != nil {
+				return err
+			}
+		}
+		for _, controller := range cm.config.ControlPlane() {
+			if err := cm.addPodRoute(ctx, controller, node); err != nil {
+				return err
+			}
+		}
+	}
+	cm.config.Workers = append(cm.config.Workers, node)
+	cm.writeNodeAccessFiles()
+	cm.recordConfig()
+
+	cm.publish(Event{Type: EventNodeCompleted, Phase: "scale", Node: node.Name})
+	cm.logger.Info(fmt.Sprintf("Worker %s joined the cluster", node.Name))
+	return nil
+}
+
+// RemoveWorkerNode drains a worker, deletes it from the cluster, removes the
+// routes to its pods from the controllers and the remaining workers and cleans
+// up the node. An unreachable node is still removed from the cluster; its
+// cleanup failure is logged. The node is removed from the manager's workers.
+func (cm *ClusterManager) RemoveWorkerNode(ctx context.Context, name string) error {
+	index := -1
+	for i, worker := range cm.config.Workers {
+		if worker.Name == name {
+			index = i
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("unknown worker %q", name)
+	}
+	if len(cm.config.Workers) == 1 {
+		return fmt.Errorf("%s is the only worker; destroy the cluster instead", name)
+	}
+	node := cm.config.Workers[index]
+	remaining := append(append([]Node{}, cm.config.Workers[:index]...), cm.config.Workers[index+1:]...)
+
+	steps := []string{"Draining node", "Deleting node", "Removing pod routes", "Cleaning up node"}
+	cm.tracker = newProgressTracker(len(steps))
+	cm.startPhase(1, 1, "Removing worker "+name)
+	progress := cm.nodeProgress(name, steps)
+	controller := cm.config.Controller
+
+	progress.advance()
+	if err := cm.drainNode(ctx, name); err != nil {
+		return err
+	}
+
+	progress.advance()
+	deleteCmd := fmt.Sprintf("kubectl delete node %s --ignore-not-found --kubeconfig /var/lib/kubernetes/admin.kubeconfig", name)
+	if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, deleteCmd); err != nil {
+		return fmt.Errorf("failed to delete node %s: %w", name, err)
+	}
+
+	progress.advance()
+	if cm.cni().podRoutes() {
+		for _, other := range append(cm.config.ControlPlane(), remaining...) {
+			routeCmd := fmt.Sprintf("sudo ip route del %s via %s || true", node.PodCIDR, node.IPAddress)
+			if _, err := cm.sshClient.ExecuteCommand(ctx, other.IPAddress, routeCmd); err != nil {
+				return fmt.Errorf("failed to remove route to %s on %s: %w", name, other.Name, err)
+			}
+		}
+	}
+	cm.config.Workers = remaining
+	cm.releasePodCIDR(name)
+	cm.writeNodeAccessFiles()
+	cm.recordConfig()
+
+	progress.advance()
+	for _, cmd := range destroyCommands {
+		if _, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, cmd.command); err != nil {
+			cm.logger.Warn(fmt.Sprintf("Failed to clean up %s, which was already removed from the cluster: %v", name, err))
+			break
+		}
+	}
+	serving := kubeletServingCertificateName(node)
+	for _, file := range []string{name + ".pem", name + "-key.pem", name + ".kubeconfig", serving + ".pem", serving + "-key.pem"} {
+		os.Remove(filepath.Join(cm.config.WorkDir, file))
+	}
+
+	cm.publish(Event{Type: EventNodeCompleted, Phase: "scale", Node: name})
+	cm.logger.Info(fmt.Sprintf("Worker %s was removed from the cluster", name))
+	return nil
+}
+
+// drainNode cordons a node and evicts its pods from the controller.
+func (cm *ClusterManager) drainNode(ctx context.Context, name string) error {
+	drainCmd := fmt.Sprintf("kubectl drain %s --ignore-daemonsets --delete-emptydir-data --force --timeout=%s --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		name, drainTimeout)
+	ctx, cancel := context.WithTimeout(ctx, drainTimeout+time.Minute)
+	defer cancel()
+	if _, err := cm.sshClient.ExecuteCommand(ctx, cm.config.Controller.IPAddress, drainCmd); err != nil {
+		return fmt.Errorf("failed to drain %s: %w", name, err)
+	}
+	return nil
+}
+
+// addPodRoute routes the pod CIDR of target through its node on worker.
+func (cm *ClusterManager) addPodRoute(ctx context.Context, worker, target Node) error {
+	routeCmd := fmt.Sprintf("sudo ip route add %s via %s || true", target.PodCIDR, target.IPAddress)
+	if _, err := cm.sshClient.ExecuteCommand(ctx, worker.IPAddress, routeCmd); err != nil {
+		return fmt.Errorf("failed to add route on %s: %w", worker.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// scp.go copies files and directories to nodes over the SCP protocol.
+package clustersetup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// scpEntry is a file or directory to copy. Files are read from localPath,
+// or hold their content when there is none.
+type scpEntry struct {
+	name      string
+	mode      os.FileMode
+	dir       bool
+	localPath string
+	content   string
+	size      int64
+	children  []scpEntry
+}
+
+// localEntry describes a local file, or a directory with everything in it,
+// keeping their permissions.
+func localEntry(localPath string) (scpEntry, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return scpEntry{}, fmt.Errorf("failed to read local file %s: %w", localPath, err)
+	}
+	entry := scpEntry{name: info.Name(), mode: info.Mode().Perm(), localPath: localPath, size: info.Size()}
+	if !info.IsDir() {
+		if !info.Mode().IsRegular() {
+			return scpEntry{}, fmt.Errorf("%s is not a regular file", localPath)
+		}
+		return entry, nil
+	}
+
+	entry.dir, entry.size = true, 0
+	files, err := os.ReadDir(localPath)
+	if err != nil {
+		return scpEntry{}, fmt.Errorf("failed to read local directory %s: %w", localPath, err)
+	}
+	for _, file := range files {
+		child, err := localEntry(filepath.Join(localPath, file.Name()))
+		if err != nil {
+			return scpEntry{}, err
+		}
+		entry.children = append(entry.children, child)
+	}
+	return entry, nil
+}
+
+// scpTransfer is the source side of an SCP transfer to a remote scp -t. It
+// keeps the SHA-256 checksum of every file sent, by remote path.
+type scpTransfer struct {
+	in        io.Writer
+	out       *bufio.Reader
+	checksums map[string]string
+}
+
+// ack reads the remote's reply to the last message.
+func (t *scpTransfer) ack() error {
+	code, err := t.out.ReadByte()
+	if err != nil {
+		return fmt.Errorf("scp ended early: %w", err)
+	}
+	if code == 0 {
+		return nil
+	}
+	message, _ := t.out.ReadString('\n')
+	return fmt.Errorf("scp: %s", strings.TrimSpace(message))
+}
+
+// send copies an entry to remotePath, which names the entry inside the
+// directory scp was started in.
+func (t *scpTransfer) send(entry scpEntry, remotePath string) error {
+	if entry.dir {
+		fmt.Fprintf(t.in, "D%04o 0 %s\n", entry.mode, path.Base(remotePath))
+		if err := t.ack(); err != nil {
+			return err
+		}
+		for _, child := range entry.children {
+			if err := t.send(child, path.Join(remotePath, child.name)); err != nil {
+				return err
+			}
+		}
+		fmt.Fprint(t.in, "E\n")
+		return t.ack()
+	}
+
+	var source io.Reader = strings.NewReader(entry.content)
+	if entry.localPath != "" {
+		file, err := os.Open(entry.localPath)
+		if err != nil {
+			return fmt.Errorf("failed to read local file %s: %w", entry.localPath, err)
+		}
+		defer file.Close()
+		source = file
+	}
+
+	fmt.Fprintf(t.in, "C%04o %d %s\n", entry.mode, entry.size, path.Base(remotePath))
+	if err := t.ack(); err != nil {
+		return err
+	}
+	checksum := sha256.New()
+	written, err := io.Copy(t.in, io.TeeReader(io.LimitReader(source, entry.size), checksum))
+	if err != nil {
+		return fmt.Errorf("failed to send %s: %w", remotePath, err)
+	}
+	if written != entry.size {
+		return fmt.Errorf("%s shrank while it was copied", entry.localPath)
+	}
+	if _, err := t.in.Write([]byte{0}); err != nil {
+		return fmt.Errorf("failed to send %s: %w", remotePath, err)
+	}
+	t.checksums[remotePath] = hex.EncodeToString(checksum.Sum(nil))
+	return t.ack()
+}
+
+// CopyFile copies a local file, or a directory with everything in it, to
+// the remote host over SCP. Copies keep the local permissions, are owned by
+// root and are checked against the local checksums.
+func (c *RealSSHClient) CopyFile(ctx context.Context, host, localPath, remotePath string) error {
+	return c.CopyFileOwned(ctx, host, localPath, remotePath, "")
+}
+
+// CopyFileOwned copies like CopyFile and then hands the copies to owner,
+// given as "user" or "user:group".
+func (c *RealSSHClient) CopyFileOwned(ctx context.Context, host, localPath, remotePath, owner string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("copy of %s to %s not started: %w", localPath, host, err)
+	}
+	entry, err := localEntry(localPath)
+	if err != nil {
+		return err
+	}
+	return c.upload(ctx, host, entry, remotePath, owner)
+}
+
+// CopyContent copies content directly to a remote file, readable by everyone.
+func (c *RealSSHClient) CopyContent(ctx context.Context, host, content, remotePath string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("upload of %s to %s not started: %w", remotePath, host, err)
+	}
+	entry := scpEntry{mode: 0644, content: content, size: int64(len(content))}
+	return c.upload(ctx, host, entry, remotePath, "")
+}
+
+// upload runs scp -t as root in the parent directory of remotePath, sends
+// the entry, and then verifies and hands over the copies. The transfer is
+// stopped when ctx is done.
+func (c *RealSSHClient) upload(ctx context.Context, host string, entry scpEntry, remotePath, owner string) error {
+	client, session, release, err := c.newSession(ctx, host)
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe for %s: %w", host, err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe for %s: %w", host, err)
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+
+	// -p applies the modes sent to files that already exist
+	flags := "-p -t"
+	if entry.dir {
+		flags = "-p -r -t"
+	}
+	scpCommand, input := c.sudoCommand(fmt.Sprintf("sudo scp %s %s", flags, shellQuote(path.Dir(remotePath))))
+	if err := session.Start(scpCommand); err != nil {
+		return fmt.Errorf("failed to start scp on %s: %w", host, err)
+	}
+
+	stop := c.stopOnDone(ctx, host, client, session)
+	transfer := &scpTransfer{in: stdin, out: bufio.NewReader(stdout), checksums: make(map[string]string)}
+	_, err = io.WriteString(stdin, input)
+	if err == nil {
+		err = transfer.ack()
+	}
+	if err == nil {
+		err = transfer.send(entry, remotePath)
+	}
+	stdin.Close()
+	if waitErr := session.Wait(); err == nil {
+		err = waitErr
+	}
+	if !stop() {
+		return fmt.Errorf("copy to %s on %s interrupted: %w", remotePath, host, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy to %s on %s: %w, stderr: %s", remotePath, host, err, stderr.String())
+	}
+
+	return c.verifyUpload(ctx, client, host, remotePath, owner, transfer.checksums)
+}
+
+// verifyUpload hands the copies under remotePath to owner, if any, and
+// compares their checksums with those of the files sent.
+func (c *RealSSHClient) verifyUpload(ctx context.Context, client *ssh.Client, host, remotePath, owner string, checksums map[string]string) error {
+	var paths []string
+	for file := range checksums {
+		paths = append(paths, shellQuote(file))
+	}
+	sort.Strings(paths)
+	var commands []string
+	if owner != "" {
+		commands = append(commands, fmt.Sprintf("sudo chown -R %s %s", shellQuote(owner), shellQuote(remotePath)))
+	}
+	if len(paths) > 0 {
+		commands = append(commands, "sudo sha256sum "+strings.Join(paths, " "))
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create verification session for %s: %w", host, err)
+	}
+	defer session.Close()
+
+	verifyCommand, input := c.sudoCommand(strings.Join(commands, " && "))
+	if input != "" {
+		session.Stdin = strings.NewReader(input)
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	stop := c.stopOnDone(ctx, host, client, session)
+	output, err := session.Output(verifyCommand)
+	if !stop() {
+		return fmt.Errorf("verification of %s on %s interrupted: %w", remotePath, host, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to verify %s on %s: %w, stderr: %s", remotePath, host, err, stderr.String())
+	}
+
+	remote := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if fields := strings.SplitN(line, "  ", 2); len(fields) == 2 {
+			remote[fields[1]] = fields[0]
+		}
+	}
+	for file, checksum := range checksums {
+		if remote[file] != checksum {
+			return fmt.Errorf("copy of %s on %s doesn't match the local file", file, host)
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes a word for the remote shell.
+func shellQuote(word string) string {
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
@@ -4,7 +4,9 @@ package clustersetup
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math/rand"
 	"path/filepath"
 	"strings"
 	"time"
@@ -13,149 +15,242 @@ import (
 // generateCertificates generates all required certificates for the Kubernetes cluster.
 func (cm *ClusterManager) generateCertificates(ctx context.Context, workDir string) error {
 	cm.logger.Info("Generating certificates...")
-	if err := cm.certManager.GenerateCA(workDir, cm.config.Certificates); err != nil {
-		return fmt.Errorf("failed to generate CA: %w", err)
+	clientCerts := cm.clientCertificateNames()
+	steps := []string{"Generating CA"}
+	for _, name := range clientCerts {
+		steps = append(steps, "Generating "+name+" certificate")
+	}
+	steps = append(steps, "Generating kubernetes certificate")
+	for _, name := range cm.kubeletServingCertificateNames() {
+		steps = append(steps, "Generating "+name+" certificate")
 	}
+	progress := cm.nodeProgress("", steps)
 
-	clientCerts := []string{
-		"admin",
-		"kube-controller-manager",
-		"kube-proxy",
-		"kube-scheduler",
-		"service-account",
+	// A resumed setup keeps the certificates it already issued, unless it
+	// has to issue a new CA
+	keepCA := cm.keepExisting("ca.pem", "ca-key.pem")
+	issued := func(name string) bool {
+		return keepCA && cm.keepExisting(name+".pem", name+"-key.pem")
 	}
-	for _, worker := range cm.config.Workers {
-		clientCerts = append(clientCerts, worker.Name)
+
+	progress.advance()
+	if !keepCA {
+		if err := cm.certManager.GenerateCA(workDir, cm.config.Certificates); err != nil {
+			return fmt.Errorf("failed to generate CA: %w", err)
+		}
+		cm.publish(Event{Type: EventCertificateIssued, Name: "ca"})
 	}
+
 	for _, name := range clientCerts {
+		progress.advance()
+		if issued(name) {
+			continue
+		}
 		if err := cm.certManager.GenerateClientCert(workDir, name, cm.config.Certificates); err != nil {
 			return fmt.Errorf("failed to generate client certificate for %s: %w", name, err)
 		}
+		cm.publish(Event{Type: EventCertificateIssued, Name: name})
+	}
+
+	progress.advance()
+	if !issued("kubernetes") {
+		if err := cm.certManager.GenerateServerCert(workDir, "kubernetes", cm.kubernetesCertificateHosts(), cm.config.Certificates); err != nil {
+			return fmt.Errorf("failed to generate server certificate: %w", err)
+		}
+		cm.publish(Event{Type: EventCertificateIssued, Name: "kubernetes"})
 	}
 
-	serverHosts := []string{
+	if !cm.kubeletServingBootstrap() {
+		for _, worker := range cm.config.Workers {
+			name := kubeletServingCertificateName(worker)
+			progress.advance()
+			if issued(name) {
+				continue
+			}
+			if err := cm.certManager.GenerateServerCert(workDir, name, kubeletServingHosts(worker), cm.config.Certificates); err != nil {
+				return fmt.Errorf("failed to generate kubelet serving certificate for %s: %w", worker.Name, err)
+			}
+			cm.publish(Event{Type: EventCertificateIssued, Name: name})
+		}
+	}
+
+	cm.logger.Info("All certificates generated successfully")
+	return nil
+}
+
+// kubernetesCertificateHosts returns the names and addresses the API server
+// and etcd certificate is valid for.
+func (cm *ClusterManager) kubernetesCertificateHosts() []string {
+	hosts := []string{
 		"127.0.0.1",
 		"10.32.0.1",
-		cm.config.Controller.IPAddress,
-		cm.config.Controller.Hostname,
 		"kubernetes",
 		"kubernetes.default",
 		"kubernetes.default.svc",
 		"kubernetes.default.svc.cluster",
 		"kubernetes.default.svc.cluster.local",
 	}
-	if err := cm.certManager.GenerateServerCert(workDir, "kubernetes", serverHosts, cm.config.Certificates); err != nil {
-		return fmt.Errorf("failed to generate server certificate: %w", err)
+	for _, controller := range cm.config.ControlPlane() {
+		hosts = append(hosts, controller.IPAddress, controller.Hostname)
+		if controller.PublicAddress != "" {
+			hosts = append(hosts, controller.PublicAddress)
+		}
+	}
+	if cm.config.ControlPlaneEndpoint != "" {
+		hosts = append(hosts, cm.config.ControlPlaneEndpoint)
 	}
+	for _, member := range cm.config.Etcd.Members {
+		hosts = append(hosts, member.IPAddress)
+		if member.Hostname != "" {
+			hosts = append(hosts, member.Hostname)
+		}
+	}
+	return hosts
+}
 
-	cm.logger.Info("All certificates generated successfully")
-	return nil
+// kubeletServingHosts returns the names and address a worker's kubelet
+// serving certificate is valid for.
+func kubeletServingHosts(worker Node) []string {
+	hosts := []string{worker.Name, worker.IPAddress}
+	if worker.Hostname != "" && worker.Hostname != worker.Name {
+		hosts = append(hosts, worker.Hostname)
+	}
+	return hosts
 }
 
 // createConfigurations generates all required configuration files for the cluster.
 func (cm *ClusterManager) createConfigurations(ctx context.Context, workDir string) error {
 	cm.logger.Info("Creating configurations...")
-	if err := cm.generateEncryptionConfig(workDir); err != nil {
-		return fmt.Errorf("failed to create encryption config: %w", err)
+	components := []string{"kube-proxy", "kube-controller-manager", "kube-scheduler", "admin"}
+	steps := []string{"Generating encryption config"}
+	for _, worker := range cm.config.Workers {
+		steps = append(steps, "Generating "+worker.Name+" kubeconfig")
+	}
+	for _, name := range components {
+		steps = append(steps, "Generating "+name+" kubeconfig")
+	}
+	if cm.config.KubeVIP.Enabled {
+		steps = append(steps, "Generating kube-vip kubeconfig")
+	}
+	progress := cm.nodeProgress("", steps)
+
+	// Replacing the encryption key of a resumed setup would leave secrets
+	// already stored in etcd unreadable
+	progress.advance()
+	if !cm.keepExisting("encryption-config.yaml") {
+		if err := cm.generateEncryptionConfig(workDir); err != nil {
+			return fmt.Errorf("failed to create encryption config: %w", err)
+		}
 	}
 
 	for _, worker := range cm.config.Workers {
-		if err := cm.generateKubeconfig(workDir, worker.Name, worker.IPAddress); err != nil {
+		progress.advance()
+		if err := cm.generateKubeconfig(workDir, worker.Name, ""); err != nil {
 			return fmt.Errorf("failed to generate kubeconfig for %s: %w", worker.Name, err)
 		}
 	}
 
-	for _, name := range []string{"kube-proxy", "kube-controller-manager", "kube-scheduler", "admin"} {
+	// The controller manager and scheduler talk to the API server on their
+	// own controller; workers and the admin go through the API server
+	// endpoint, so they keep working while any controller is down
+	for _, name := range components {
+		progress.advance()
 		var ip string
-		if name == "kube-controller-manager" || name == "kube-scheduler" || name == "admin" {
-			ip = cm.config.Controller.IPAddress
-		} else {
-			ip = ""
+		switch name {
+		case "kube-controller-manager", "kube-scheduler":
+			ip = "127.0.0.1"
 		}
 		if err := cm.generateKubeconfig(workDir, name, ip); err != nil {
 			return fmt.Errorf("failed to generate kubeconfig for %s: %w", name, err)
 		}
 	}
+	if cm.config.KubeVIP.Enabled {
+		progress.advance()
+		if err := cm.writeKubeVIPKubeconfig(workDir); err != nil {
+			return err
+		}
+	}
 
 	cm.logger.Info("All configurations created successfully")
 	return nil
 }
 
-// setupControlPlane sets up the Kubernetes control plane on the controller node.
+// controllerCertificateFiles are the certificates and keys in the work
+// directory that the control plane services use.
+var controllerCertificateFiles = []string{
+	"ca.pem", "ca-key.pem", "kubernetes.pem", "kubernetes-key.pem", "service-account-key.pem", "service-account.pem",
+	"front-proxy-client.pem", "front-proxy-client-key.pem",
+}
+
+// setupControlPlane sets up etcd and then the Kubernetes control plane on
+// every controller node.
 func (cm *ClusterManager) setupControlPlane(ctx context.Context, workDir string) error {
 	cm.logger.Info("Setting up control plane...")
-	controller := cm.config.Controller
-
-	// Setup etcd
-	etcdCommands := []string{
-		"sudo mkdir -p /etc/etcd /var/lib/etcd",
-		"sudo groupadd -f etcd",
-		"sudo useradd -g etcd -d /var/lib/etcd -s /sbin/nologin -c 'etcd user' etcd || true",
-		"sudo chown -R etcd:etcd /var/lib/etcd",
-		fmt.Sprintf("wget -q --show-progress --https-only --timestamping 'https://github.com/etcd-io/etcd/releases/download/%s/etcd-%s-linux-amd64.tar.gz'", cm.config.EtcdVersion, cm.config.EtcdVersion),
-		fmt.Sprintf("tar -xzf etcd-%s-linux-amd64.tar.gz", cm.config.EtcdVersion),
-		fmt.Sprintf("sudo mv etcd-%s-linux-amd64/etcd* /usr/local/bin/", cm.config.EtcdVersion),
-		fmt.Sprintf("rm -f etcd-%s-linux-amd64.tar.gz", cm.config.EtcdVersion),
+	if err := cm.setupEtcd(ctx, workDir); err != nil {
+		return err
 	}
-	for _, cmd := range etcdCommands {
-		if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, cmd); err != nil {
-			return fmt.Errorf("failed to execute etcd setup command '%s' on controller: %w", cmd, err)
+	for _, controller := range cm.config.ControlPlane() {
+		step := "control-plane/" + controller.Name
+		if cm.nodeDone(ctx, step, controller, len(controlPlaneSteps), cm.controlPlaneServices()...) {
+			continue
 		}
-	}
-
-	// Copy etcd certificates
-	certFiles := []string{"ca.pem", "kubernetes-key.pem", "kubernetes.pem"}
-	for _, file := range certFiles {
-		localPath := filepath.Join(workDir, file)
-		remotePath := "/etc/etcd/" + file
-		if err := cm.sshClient.CopyFile(ctx, controller.IPAddress, localPath, remotePath); err != nil {
-			return fmt.Errorf("failed to copy %s to controller: %w", file, err)
+		if err := cm.setupController(ctx, workDir, controller); err != nil {
+			return err
 		}
-		if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, fmt.Sprintf("sudo chown etcd:etcd %s", remotePath)); err != nil {
-			return fmt.Errorf("failed to set ownership for %s: %w", remotePath, err)
+		cm.completeStep(step)
+	}
+	if cm.config.KubeVIP.Enabled {
+		if err := cm.waitForKubeVIP(ctx); err != nil {
+			return err
 		}
 	}
-
-	// Setup etcd service
-	etcdService := cm.generateEtcdService(controller)
-	if err := cm.sshClient.CopyContent(ctx, controller.IPAddress, etcdService, "/etc/systemd/system/etcd.service"); err != nil {
-		return fmt.Errorf("failed to upload etcd service: %w", err)
+	if err := cm.deployPodNetwork(ctx); err != nil {
+		return err
 	}
+	cm.logger.Info("Control plane setup completed")
+	return nil
+}
+
+// setupController installs and starts the control plane components on a
+// controller node.
+func (cm *ClusterManager) setupController(ctx context.Context, workDir string, controller Node) error {
+	progress := cm.nodeProgress(controller.Name, controlPlaneSteps)
 
 	// Setup Kubernetes control plane components
-	k8sCommands := []string{
-		"sudo mkdir -p /etc/kubernetes/config /var/lib/kubernetes",
-		fmt.Sprintf("wget -q --show-progress --https-only --timestamping "+
-			"'https://storage.googleapis.com/kubernetes-release/release/%s/bin/linux/amd64/kube-apiserver' "+
-			"'https://storage.googleapis.com/kubernetes-release/release/%s/bin/linux/amd64/kube-controller-manager' "+
-			"'https://storage.googleapis.com/kubernetes-release/release/%s/bin/linux/amd64/kube-scheduler' "+
-			"'https://storage.googleapis.com/kubernetes-release/release/%s/bin/linux/amd64/kubectl'",
-			cm.config.KubernetesVersion, cm.config.KubernetesVersion, cm.config.KubernetesVersion, cm.config.KubernetesVersion),
-		"chmod +x kube-apiserver kube-controller-manager kube-scheduler kubectl",
-		"sudo mv kube-apiserver kube-controller-manager kube-scheduler kubectl /usr/local/bin/",
+	progress.advance()
+	if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, "sudo mkdir -p /etc/kubernetes/config /var/lib/kubernetes"); err != nil {
+		return fmt.Errorf("failed to create kubernetes directories on controller: %w", err)
 	}
-	for _, cmd := range k8sCommands {
-		if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, cmd); err != nil {
-			return fmt.Errorf("failed to execute kubernetes setup command '%s' on controller: %w", cmd, err)
+	if !cm.isBaked(ctx, controller, RoleController) {
+		if err := cm.runInstall(ctx, controller, cm.controlPlaneInstall()); err != nil {
+			return err
+		}
+		if cm.config.KubeVIP.Enabled {
+			if err := cm.runInstall(ctx, controller, cm.kubeVIPInstall()); err != nil {
+				return err
+			}
 		}
 	}
 
 	// Copy additional Kubernetes files
-	additionalFiles := []string{
-		"ca-key.pem", "service-account-key.pem", "service-account.pem",
-		"encryption-config.yaml", "kube-controller-manager.kubeconfig", "kube-scheduler.kubeconfig",
+	progress.advance()
+	additionalFiles := append(append([]string{}, controllerCertificateFiles...),
+		"encryption-config.yaml", "kube-controller-manager.kubeconfig", "kube-scheduler.kubeconfig")
+	if cm.config.KubeVIP.Enabled {
+		additionalFiles = append(additionalFiles, kubeVIPKubeconfig)
 	}
 	for _, file := range additionalFiles {
 		localPath := filepath.Join(workDir, file)
 		remotePath := "/var/lib/kubernetes/" + file
 		if err := cm.sshClient.CopyFile(ctx, controller.IPAddress, localPath, remotePath); err != nil {
-			return fmt.Errorf("failed to copy %s to controller: %w", file, err)
+			return fmt.Errorf("failed to copy %s to %s: %w", file, controller.Name, err)
 		}
 	}
 
 	// Setup Kubernetes services
+	progress.advance()
 	services := map[string]string{
-		"kube-apiserver":         cm.generateAPIServerService(),
+		"kube-apiserver":         cm.generateAPIServerService(controller),
 		"kube-controller-manager": cm.generateControllerManagerService(),
 		"kube-scheduler":         cm.generateSchedulerService(),
 	}
@@ -166,21 +261,11 @@ func (cm *ClusterManager) setupControlPlane(ctx context.Context, workDir string)
 		}
 	}
 
-	// Start services in proper order with health checks
-	// Start etcd first
-	if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, 
-		"sudo systemctl daemon-reload && sudo systemctl enable etcd && sudo systemctl start etcd"); err != nil {
-		return fmt.Errorf("failed to start etcd: %w", err)
-	}
-
-	// Wait for etcd to be healthy
-	if err := cm.waitForService(ctx, controller.IPAddress, "etcd", 30*time.Second); err != nil {
-		return fmt.Errorf("etcd failed to become healthy: %w", err)
-	}
-
+	// Start services in proper order with health checks, now that etcd is up
 	// Start API server
+	progress.advance()
 	if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress,
-		"sudo systemctl enable kube-apiserver && sudo systemctl start kube-apiserver"); err != nil {
+		"sudo systemctl daemon-reload && sudo systemctl enable kube-apiserver && sudo systemctl start kube-apiserver"); err != nil {
 		return fmt.Errorf("failed to start kube-apiserver: %w", err)
 	}
 
@@ -190,6 +275,7 @@ func (cm *ClusterManager) setupControlPlane(ctx context.Context, workDir string)
 	}
 
 	// Start controller manager and scheduler
+	progress.advance()
 	last_services := []string{"kube-controller-manager", "kube-scheduler"}
 	for _, service := range last_services {
 		if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress,
@@ -200,79 +286,45 @@ func (cm *ClusterManager) setupControlPlane(ctx context.Context, workDir string)
 			return fmt.Errorf("%s failed to become healthy: %w", service, err)
 		}
 	}
+	// kube-vip elects its leader through the API server it runs next to
+	if cm.config.KubeVIP.Enabled {
+		if err := cm.startKubeVIP(ctx, controller); err != nil {
+			return err
+		}
+	}
 
-	cm.logger.Info("Control plane setup completed")
+	cm.publish(Event{Type: EventNodeCompleted, Phase: "control-plane", Node: controller.Name})
 	return nil
 }
 
 // setupSingleWorkerNode sets up a single worker node.
 func (cm *ClusterManager) setupSingleWorkerNode(ctx context.Context, workDir string, worker Node) error {
 	cm.logger.Info(fmt.Sprintf("Setting up worker node %s...", worker.Name))
-
-	// Install dependencies
-	depCommands := []string{
-		"sudo apt-get update",
-		"sudo apt-get -y install socat conntrack ipset",
-		"sudo mkdir -p /etc/cni/net.d /opt/cni/bin /var/lib/kubelet /var/lib/kube-proxy /var/lib/kubernetes /var/run/kubernetes",
-	}
-	for _, cmd := range depCommands {
-		if _, err := cm.sshClient.ExecuteCommand(ctx, worker.IPAddress, cmd); err != nil {
-			return fmt.Errorf("failed to execute dependency command '%s' on %s: %w", cmd, worker.Name, err)
+	progress := cm.nodeProgress(worker.Name, workerSteps)
+
+	// Install dependencies, CNI plugins, containerd and Kubernetes binaries
+	family := cm.osFamily(ctx, worker)
+	baked := cm.isBaked(ctx, worker, RoleWorker)
+	for _, step := range cm.workerInstalls(family) {
+		progress.advance()
+		if baked {
+			continue
 		}
-	}
-
-	// Install CNI plugins
-	cniCommands := []string{
-		fmt.Sprintf("wget -q --show-progress --https-only --timestamping 'https://github.com/containernetworking/plugins/releases/download/%s/cni-plugins-linux-amd64-%s.tgz'", cm.config.CNIVersion, cm.config.CNIVersion),
-		fmt.Sprintf("sudo tar -xzf cni-plugins-linux-amd64-%s.tgz -C /opt/cni/bin/", cm.config.CNIVersion),
-		fmt.Sprintf("rm -f cni-plugins-linux-amd64-%s.tgz", cm.config.CNIVersion),
-	}
-	for _, cmd := range cniCommands {
-		if _, err := cm.sshClient.ExecuteCommand(ctx, worker.IPAddress, cmd); err != nil {
-			return fmt.Errorf("failed to execute CNI command '%s' on %s: %w", cmd, worker.Name, err)
-		}
-	}
-
-	// Install containerd
-	containerdCommands := []string{
-		fmt.Sprintf("wget -q --show-progress --https-only --timestamping 'https://github.com/containerd/containerd/releases/download/%s/containerd-%s-linux-amd64.tar.gz'", cm.config.ContainerdVersion, cm.config.ContainerdVersion),
-		"wget -q --show-progress --https-only --timestamping 'https://github.com/opencontainers/runc/releases/download/v1.1.7/runc.amd64'",
-		fmt.Sprintf("sudo tar -xzf containerd-%s-linux-amd64.tar.gz -C /", cm.config.ContainerdVersion),
-		"sudo mv runc.amd64 runc",
-		"chmod +x runc",
-		"sudo mv runc /usr/local/bin/",
-		fmt.Sprintf("rm -f containerd-%s-linux-amd64.tar.gz", cm.config.ContainerdVersion),
-	}
-	for _, cmd := range containerdCommands {
-		if _, err := cm.sshClient.ExecuteCommand(ctx, worker.IPAddress, cmd); err != nil {
-			return fmt.Errorf("failed to execute containerd command '%s' on %s: %w", cmd, worker.Name, err)
+		if err := cm.runInstall(ctx, worker, step); err != nil {
+			return err
 		}
 	}
-
-	// Install Kubernetes binaries
-	k8sWorkerCommands := []string{
-		fmt.Sprintf("wget -q --show-progress --https-only --timestamping "+
-			"'https://storage.googleapis.com/kubernetes-release/release/%s/bin/linux/amd64/kubectl' "+
-			"'https://storage.googleapis.com/kubernetes-release/release/%s/bin/linux/amd64/kube-proxy' "+
-			"'https://storage.googleapis.com/kubernetes-release/release/%s/bin/linux/amd64/kubelet'",
-			cm.config.KubernetesVersion, cm.config.KubernetesVersion, cm.config.KubernetesVersion),
-		"chmod +x kubectl kube-proxy kubelet",
-		"sudo mv kubectl kube-proxy kubelet /usr/local/bin/",
+	if err := cm.prepareWorkerOS(ctx, worker, family); err != nil {
+		return err
 	}
-	for _, cmd := range k8sWorkerCommands {
-		if _, err := cm.sshClient.ExecuteCommand(ctx, worker.IPAddress, cmd); err != nil {
-			return fmt.Errorf("failed to execute kubernetes command '%s' on %s: %w", cmd, worker.Name, err)
-		}
+	dirCmd := "sudo mkdir -p /etc/cni/net.d /opt/cni/bin /var/lib/kubelet /var/lib/kube-proxy /var/lib/kubernetes /var/run/kubernetes"
+	if _, err := cm.sshClient.ExecuteCommand(ctx, worker.IPAddress, dirCmd); err != nil {
+		return fmt.Errorf("failed to create directories on %s: %w", worker.Name, err)
 	}
 
 	// Copy certificates and kubeconfigs
-	workerFiles := []string{
-		"ca.pem",
-		worker.Name + "-key.pem",
-		worker.Name + ".pem",
-		worker.Name + ".kubeconfig",
-		"kube-proxy.kubeconfig",
-	}
+	progress.advance()
+	workerFiles := append(cm.workerCertificateFiles(worker), worker.Name+".kubeconfig", "kube-proxy.kubeconfig")
 	for _, file := range workerFiles {
 		localPath := filepath.Join(workDir, file)
 		remotePath := "/var/lib/kubelet/" + file
@@ -285,13 +337,15 @@ func (cm *ClusterManager) setupSingleWorkerNode(ctx context.Context, workDir str
 	}
 
 	// Copy configuration files
+	progress.advance()
 	configs := map[string]string{
 		"/etc/containerd/config.toml":            cm.generateContainerdConfig(),
-		"/etc/cni/net.d/10-bridge.conf":          cm.generateBridgeNetworkConfig(worker.PodCIDR),
-		"/etc/cni/net.d/99-loopback.conf":        cm.generateLoopbackNetworkConfig(),
 		"/var/lib/kubelet/kubelet-config.yaml":   cm.generateKubeletConfig(worker),
 		"/var/lib/kube-proxy/kube-proxy-config.yaml": cm.generateKubeProxyConfig(),
 	}
+	for path, content := range cm.cni().workerConfigs(worker) {
+		configs[path] = content
+	}
 	for path, content := range configs {
 		if err := cm.sshClient.CopyContent(ctx, worker.IPAddress, content, path); err != nil {
 			return fmt.Errorf("failed to upload config %s to %s: %w", path, worker.Name, err)
@@ -299,6 +353,7 @@ func (cm *ClusterManager) setupSingleWorkerNode(ctx context.Context, workDir str
 	}
 
 	// Setup services
+	progress.advance()
 	services := map[string]string{
 		"containerd": cm.generateContainerdService(),
 		"kubelet":    cm.generateKubeletService(worker),
@@ -310,53 +365,258 @@ func (cm *ClusterManager) setupSingleWorkerNode(ctx context.Context, workDir str
 			return fmt.Errorf("failed to upload %s service to %s: %w", name, worker.Name, err)
 		}
 	}
+	if err := cm.installProxyDropIns(ctx, worker); err != nil {
+		return err
+	}
 
 	// Start services
+	progress.advance()
 	workerStartCommands := []string{
 		"sudo systemctl daemon-reload",
 		"sudo systemctl enable containerd kubelet kube-proxy",
 		"sudo systemctl start containerd kubelet kube-proxy",
 	}
-	for _, cmd := range workerStartCommands {
-		if _, err := cm.sshClient.ExecuteCommand(ctx, worker.IPAddress, cmd); err != nil {
-			return fmt.Errorf("failed to execute start command '%s' on %s: %w", cmd, worker.Name, err)
-		}
+	if _, err := cm.sshClient.ExecuteScript(ctx, worker.IPAddress, strings.Join(workerStartCommands, "\n")); err != nil {
+		return fmt.Errorf("failed to start services on %s: %w", worker.Name, err)
+	}
+	if err := cm.importImages(ctx, worker); err != nil {
+		return err
+	}
+
+	progress.advance()
+	if err := cm.waitForNodeReady(ctx, worker, nodeReadyTimeout); err != nil {
+		return err
+	}
+	if err := cm.applyNodeLabels(ctx, worker); err != nil {
+		return err
 	}
 
 	cm.logger.Info(fmt.Sprintf("Worker node %s setup completed", worker.Name))
 	return nil
 }
 
+// nodeReadyTimeout is how long a new worker has to register and report Ready.
+const nodeReadyTimeout = 3 * time.Minute
+
+// nodeStatus is the part of `kubectl get node -o json` that reports readiness.
+type nodeStatus struct {
+	Status struct {
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+		NodeInfo struct {
+			KubeletVersion string `json:"kubeletVersion"`
+		} `json:"nodeInfo"`
+	} `json:"status"`
+}
+
+// waitForNodeReady polls the API server from the controller until the worker
+// has registered and reports Ready. On timeout the kubelet and containerd
+// journals of the worker are logged and the last known condition is returned.
+func (cm *ClusterManager) waitForNodeReady(ctx context.Context, worker Node, timeout time.Duration) error {
+	cmd := fmt.Sprintf("kubectl get node %s -o json --kubeconfig /var/lib/kubernetes/admin.kubeconfig", worker.Name)
+	last := "not registered"
+	deadline := time.Now().Add(timeout)
+	for {
+		// A probe that hangs is killed at the deadline
+		probeCtx, cancel := context.WithDeadline(ctx, deadline)
+		output, err := cm.sshClient.ExecuteCommand(probeCtx, cm.config.Controller.IPAddress, cmd)
+		cancel()
+		if err == nil {
+			var node nodeStatus
+			if err := json.Unmarshal([]byte(output), &node); err != nil {
+				last = fmt.Sprintf("unreadable node status: %v", err)
+			}
+			for _, condition := range node.Status.Conditions {
+				if condition.Type != "Ready" {
+					continue
+				}
+				if condition.Status == "True" {
+					cm.logger.Info(fmt.Sprintf("Node %s is Ready", worker.Name))
+					return nil
+				}
+				last = fmt.Sprintf("Ready=%s %s: %s", condition.Status, condition.Reason, condition.Message)
+			}
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		cm.countRetry(worker.Name)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	journal := cm.journalExcerpt(ctx, worker.IPAddress, 50, "kubelet", "containerd")
+	cm.logger.Error(fmt.Sprintf("Node %s did not become Ready; recent kubelet and containerd logs:\n%s", worker.Name, journal))
+	return fmt.Errorf("node %s did not become Ready within %v (%s)", worker.Name, timeout, last)
+}
+
+// workerCertificateFiles lists the certificates and keys in the work
+// directory that a worker's kubelet uses.
+func (cm *ClusterManager) workerCertificateFiles(worker Node) []string {
+	files := []string{"ca.pem", worker.Name + "-key.pem", worker.Name + ".pem"}
+	if !cm.kubeletServingBootstrap() {
+		name := kubeletServingCertificateName(worker)
+		files = append(files, name+"-key.pem", name+".pem")
+	}
+	return files
+}
+
 // setupWorkerNodes sets up all worker nodes.
 func (cm *ClusterManager) setupWorkerNodes(ctx context.Context, workDir string) error {
 	cm.logger.Info("Setting up worker nodes...")
+	var setUp []Node
 	for _, worker := range cm.config.Workers {
+		if cm.nodeDone(ctx, "workers/"+worker.Name, worker, len(workerSteps), workerServices...) {
+			continue
+		}
 		if err := cm.setupSingleWorkerNode(ctx, workDir, worker); err != nil {
 			return fmt.Errorf("failed to setup worker %s: %w", worker.Name, err)
 		}
+		cm.publish(Event{Type: EventNodeCompleted, Phase: "workers", Node: worker.Name})
+		setUp = append(setUp, worker)
+	}
+	if cm.kubeletServingBootstrap() && len(setUp) > 0 {
+		cm.nodeProgress("", []string{"Approving kubelet serving certificates"}).advance()
+		if err := cm.approveKubeletServingCSRs(ctx, setUp, 2*time.Minute); err != nil {
+			return err
+		}
+	}
+	// Workers count as set up once their serving certificates are approved
+	for _, worker := range setUp {
+		cm.completeStep("workers/" + worker.Name)
 	}
 	cm.logger.Info("All worker nodes setup completed")
 	return nil
 }
 
+// listKubeletCSRsCommand prints one line per certificate signing request:
+// name, signer, requesting user and any condition types.
+const listKubeletCSRsCommand = `kubectl get csr --kubeconfig /var/lib/kubernetes/admin.kubeconfig -o jsonpath='{range .items[*]}{.metadata.name} {.spec.signerName} {.spec.username} {.status.conditions[*].type}{"\n"}{end}'`
+
+// approveKubeletServingCSRs approves the serving certificate requests of the
+// given workers once their kubelets have submitted them. Requests from any
+// other node are left pending for an administrator to review.
+func (cm *ClusterManager) approveKubeletServingCSRs(ctx context.Context, workers []Node, timeout time.Duration) error {
+	controller := cm.config.Controller
+	requesters := map[string]string{}
+	pending := map[string]bool{}
+	for _, worker := range workers {
+		requesters[worker.Name] = worker.Name
+		requesters["system:node:"+worker.Name] = worker.Name
+		pending[worker.Name] = true
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		output, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, listKubeletCSRsCommand)
+		if err != nil {
+			return fmt.Errorf("failed to list certificate signing requests: %w", err)
+		}
+		for _, line := range strings.Split(output, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 3 || fields[1] != "kubernetes.io/kubelet-serving" {
+				continue
+			}
+			worker, ok := requesters[fields[2]]
+			if !ok {
+				continue
+			}
+			if len(fields) > 3 {
+				if fields[3] == "Denied" {
+					cm.logger.Warn(fmt.Sprintf("Serving certificate request %s from %s was denied", fields[0], worker))
+				}
+				delete(pending, worker)
+				continue
+			}
+			approveCmd := fmt.Sprintf("kubectl certificate approve %s --kubeconfig /var/lib/kubernetes/admin.kubeconfig", fields[0])
+			if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, approveCmd); err != nil {
+				return fmt.Errorf("failed to approve serving certificate request %s from %s: %w", fields[0], worker, err)
+			}
+			cm.logger.Info(fmt.Sprintf("Approved serving certificate request %s from %s", fields[0], worker))
+			delete(pending, worker)
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			var waiting []string
+			for _, worker := range workers {
+				if pending[worker.Name] {
+					waiting = append(waiting, worker.Name)
+				}
+			}
+			return fmt.Errorf("timed out waiting for kubelet serving certificate requests from %s", strings.Join(waiting, ", "))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
 // setupNetworking configures pod networking and deploys CoreDNS.
 func (cm *ClusterManager) setupNetworking(ctx context.Context) error {
 	cm.logger.Info("Setting up networking...")
 	controller := cm.config.Controller
 
-	// Setup pod routing
-	for _, worker := range cm.config.Workers {
-		for _, otherWorker := range cm.config.Workers {
-			if worker.Name != otherWorker.Name {
-				routeCmd := fmt.Sprintf("sudo ip route add %s via %s || true", otherWorker.PodCIDR, otherWorker.IPAddress)
-				if _, err := cm.sshClient.ExecuteCommand(ctx, worker.IPAddress, routeCmd); err != nil {
-					return fmt.Errorf("failed to add route on %s: %w", worker.Name, err)
+	// Other providers route pod traffic themselves
+	routes := cm.cni().podRoutes()
+	steps := []string{}
+	if routes {
+		for _, worker := range cm.config.Workers {
+			steps = append(steps, "Adding pod routes on "+worker.Name)
+		}
+		for _, node := range cm.config.ControlPlane() {
+			steps = append(steps, "Adding pod routes on "+node.Name)
+		}
+	}
+	if len(cm.config.PriorityClasses) > 0 {
+		steps = append(steps, "Creating priority classes")
+	}
+	progress := cm.nodeProgress("", append(steps, "Deploying CoreDNS"))
+
+	if routes {
+		// Setup pod routing
+		for _, worker := range cm.config.Workers {
+			progress.advance()
+			for _, otherWorker := range cm.config.Workers {
+				if worker.Name != otherWorker.Name {
+					if err := cm.addPodRoute(ctx, worker, otherWorker); err != nil {
+						return err
+					}
 				}
 			}
 		}
+
+		// The API servers reach webhooks and aggregated APIs on their pod IPs
+		for _, controlPlaneNode := range cm.config.ControlPlane() {
+			progress.advance()
+			for _, worker := range cm.config.Workers {
+				if err := cm.addPodRoute(ctx, controlPlaneNode, worker); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Create the priority classes the addons may use
+	if len(cm.config.PriorityClasses) > 0 {
+		progress.advance()
+		if err := cm.applyPriorityClasses(ctx); err != nil {
+			return err
+		}
 	}
 
 	// Deploy CoreDNS
+	progress.advance()
 	coreDNSManifest := cm.generateCoreDNSManifest()
 	manifestPath := "/tmp/coredns.yaml"
 	if err := cm.sshClient.CopyContent(ctx, controller.IPAddress, coreDNSManifest, manifestPath); err != nil {
@@ -375,7 +635,13 @@ func (cm *ClusterManager) setupNetworking(ctx context.Context) error {
 func (cm *ClusterManager) validateCluster(ctx context.Context) error {
 	cm.logger.Info("Validating cluster...")
 	controller := cm.config.Controller
+	steps := validationSteps
+	if cm.config.WebhookSmokeTest {
+		steps = append(steps[:len(steps):len(steps)], "Testing admission webhooks")
+	}
+	progress := cm.nodeProgress(controller.Name, steps)
 
+	progress.advance()
 	nodeStatus, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress,
 		"kubectl get nodes --kubeconfig /var/lib/kubernetes/admin.kubeconfig")
 	if err != nil {
@@ -383,6 +649,7 @@ func (cm *ClusterManager) validateCluster(ctx context.Context) error {
 	}
 	cm.logger.Info(fmt.Sprintf("Node status: %s", nodeStatus))
 
+	progress.advance()
 	podStatus, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress,
 		"kubectl get pods -n kube-system --kubeconfig /var/lib/kubernetes/admin.kubeconfig")
 	if err != nil {
@@ -391,6 +658,7 @@ func (cm *ClusterManager) validateCluster(ctx context.Context) error {
 	cm.logger.Info(fmt.Sprintf("System pods: %s", podStatus))
 
 	// Deploy test application
+	progress.advance()
 	testApp := cm.generateTestApplicationManifest()
 	testPath := "/tmp/test-app.yaml"
 	if err := cm.sshClient.CopyContent(ctx, controller.IPAddress, testApp, testPath); err != nil {
@@ -401,7 +669,7 @@ func (cm *ClusterManager) validateCluster(ctx context.Context) error {
 		return fmt.Errorf("failed to apply test app: %w", err)
 	}
 
-	time.Sleep(30 * time.Second)
+	cm.pause(controller.IPAddress, 30*time.Second)
 	testStatus, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress,
 		"kubectl get deployment test-deployment --kubeconfig /var/lib/kubernetes/admin.kubeconfig")
 	if err != nil {
@@ -409,24 +677,82 @@ func (cm *ClusterManager) validateCluster(ctx context.Context) error {
 	}
 	cm.logger.Info(fmt.Sprintf("Test application status: %s", testStatus))
 
+	if cm.config.WebhookSmokeTest {
+		progress.advance()
+		if err := cm.testAdmissionWebhook(ctx); err != nil {
+			return err
+		}
+	}
+
 	cm.logger.Info("Cluster validation completed")
 	return nil
 }
 
 
-// 4. Add service health check helper
+// Polling of services waiting to become active backs off exponentially
+// between these delays.
+const (
+	serviceInitialPollDelay = 500 * time.Millisecond
+	serviceMaxPollDelay     = 10 * time.Second
+)
+
+// servicePollDelay returns the delay before the next check of a service
+// after attempt checks, with up to 20% jitter so that checks of many nodes
+// don't stay in lockstep.
+func servicePollDelay(attempt int) time.Duration {
+	delay := serviceMaxPollDelay
+	if attempt < 10 {
+		delay = min(serviceInitialPollDelay<<attempt, serviceMaxPollDelay)
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+// waitForService polls a service until it's active, for the timeout given or
+// the active_timeout_sec configured for the service.
 func (cm *ClusterManager) waitForService(ctx context.Context, host, serviceName string, timeout time.Duration) error {
+	if configured := cm.unitConfig(serviceName).ActiveTimeoutSec; configured > 0 {
+		timeout = time.Duration(configured) * time.Second
+	}
 	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		output, err := cm.sshClient.ExecuteCommand(ctx, host, 
+	for attempt := 0; ; attempt++ {
+		output, err := cm.sshClient.ExecuteCommand(ctx, host,
 			fmt.Sprintf("sudo systemctl is-active %s", serviceName))
 		if err == nil && strings.TrimSpace(output) == "active" {
 			cm.logger.Info(fmt.Sprintf("Service %s is healthy", serviceName))
 			return nil
 		}
-		time.Sleep(5 * time.Second)
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		cm.countRetry(host)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(servicePollDelay(attempt), remaining)):
+		}
+	}
+	journal := cm.journalExcerpt(ctx, host, serviceJournalLines, serviceName)
+	cm.logger.Error(fmt.Sprintf("Service %s on %s did not become healthy; recent logs:\n%s", serviceName, host, journal))
+	return fmt.Errorf("service %s did not become healthy within %v; last journal entries:\n%s", serviceName, timeout, journal)
+}
+
+// serviceJournalLines is how much of a unit's journal is reported when it
+// doesn't become healthy.
+const serviceJournalLines = 100
+
+// journalExcerpt returns the last lines of the journal of the given units on
+// a host, or why it couldn't be read.
+func (cm *ClusterManager) journalExcerpt(ctx context.Context, host string, lines int, units ...string) string {
+	cmd := "sudo journalctl"
+	for _, unit := range units {
+		cmd += " -u " + unit
+	}
+	output, err := cm.sshClient.ExecuteCommand(ctx, host, fmt.Sprintf("%s -n %d --no-pager", cmd, lines))
+	if err != nil {
+		return fmt.Sprintf("failed to read journal: %v", err)
 	}
-	return fmt.Errorf("service %s did not become healthy within %v", serviceName, timeout)
+	return strings.TrimRight(output, "\n")
 }
 
 // 5. Add checksum verification for downloads
@@ -5,172 +5,480 @@ package clustersetup
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 // RealSSHClient implements the SSHClient interface using golang.org/x/crypto/ssh.
 type RealSSHClient struct {
 	user   string
 	keyPath string
+	keyData []byte
+	passphrase string
+	sudoPassword string
+	bastion *sshBastion
+	// pool holds the connection to every node, reused by all their sessions
+	pool sshPool
 }
 
-// NewSSHClient creates a new RealSSHClient.
+// sshBastion is the jump host connections to the nodes are tunneled through.
+type sshBastion struct {
+	address string
+	user    string
+	keyPath string
+}
+
+// NewSSHClient creates a new RealSSHClient. Without the key file, the keys
+// of the SSH agent at $SSH_AUTH_SOCK are used alone.
 func NewSSHClient(user, keyPath string) (*RealSSHClient, error) {
+	keyPath, err := expandHome(keyPath)
+	if err != nil {
+		return nil, err
+	}
 	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("SSH key file %s does not exist", keyPath)
+		if os.Getenv("SSH_AUTH_SOCK") == "" {
+			if keyPath == "" {
+				return nil, fmt.Errorf("no SSH key file given and no SSH agent running")
+			}
+			return nil, fmt.Errorf("SSH key file %s does not exist", keyPath)
+		}
+		keyPath = ""
 	}
 	return &RealSSHClient{user: user, keyPath: keyPath}, nil
 }
 
-// ExecuteCommand executes a command on the remote host via SSH.
+// SetKeyPassphrase sets the passphrase that decrypts protected SSH keys.
+func (c *RealSSHClient) SetKeyPassphrase(passphrase string) {
+	c.passphrase = passphrase
+}
+
+// SetSudoPassword makes sudo read the given password from stdin for hosts
+// that don't allow passwordless sudo.
+func (c *RealSSHClient) SetSudoPassword(password string) {
+	c.sudoPassword = password
+}
+
+// SetBastion makes the client reach every node through a jump host, given
+// as "host" or "host:port". An empty user or key path uses the nodes' user
+// or key.
+func (c *RealSSHClient) SetBastion(host, user, keyPath string) error {
+	if user == "" {
+		user = c.user
+	}
+	if keyPath != "" {
+		var err error
+		if keyPath, err = expandHome(keyPath); err != nil {
+			return err
+		}
+		if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+			return fmt.Errorf("bastion SSH key file %s does not exist", keyPath)
+		}
+	}
+	c.bastion = &sshBastion{address: withSSHPort(host), user: user, keyPath: keyPath}
+	return nil
+}
+
+// Close closes the connections kept open to the nodes. Later commands
+// connect again.
+func (c *RealSSHClient) Close() error {
+	c.pool.closeAll()
+	return nil
+}
+
+// sudoCommand rewrites sudo invocations to read the password from stdin and
+// returns the input that must precede the command's own stdin.
+func (c *RealSSHClient) sudoCommand(command string) (string, string) {
+	if c.sudoPassword == "" {
+		return command, ""
+	}
+	count := strings.Count(command, "sudo ")
+	if count == 0 {
+		return command, ""
+	}
+	return strings.ReplaceAll(command, "sudo ", "sudo -S -p '' "), strings.Repeat(c.sudoPassword+"\n", count)
+}
+
+// ExecuteCommand executes a command on the remote host via SSH, over the
+// connection kept open to it. When ctx is canceled or its deadline passes,
+// the running command is killed, and the error wraps ctx.Err().
 func (c *RealSSHClient) ExecuteCommand(ctx context.Context, host, command string) (string, error) {
-	client, err := c.createSSHClient(host)
+	return c.ExecuteCommandStream(ctx, host, command, nil)
+}
+
+// ExecuteCommandStream executes a command like ExecuteCommand, passing every
+// line of its stdout and stderr to output as it arrives, if given.
+func (c *RealSSHClient) ExecuteCommandStream(ctx context.Context, host, command string, output func(line string)) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("command '%s' on %s not started: %w", command, host, err)
+	}
+	remoteCommand, input := c.sudoCommand(command)
+	stdout, stderr, err := c.run(ctx, host, remoteCommand, input, output)
 	if err != nil {
-		return "", fmt.Errorf("failed to create SSH client for %s: %w", host, err)
+		return "", fmt.Errorf("failed to execute command '%s' on %s: %w, stderr: %s", command, host, err, stderr)
 	}
-	defer client.Close()
+	return stdout, nil
+}
+
+// scriptPrelude stops a script at its first failing command and reports the
+// command's line, counted from the first line of the script itself.
+const scriptPrelude = `set -euo pipefail
+trap 'echo "line $((LINENO - 2)): $BASH_COMMAND exited with status $?" >&2' ERR
+`
 
-	session, err := client.NewSession()
+// scriptCommand returns the bash invocation that runs script and the input
+// it needs on stdin.
+func (c *RealSSHClient) scriptCommand(script string) (string, string) {
+	remoteScript, input := c.sudoCommand(scriptPrelude + script)
+	return "bash -c '" + strings.ReplaceAll(remoteScript, "'", `'\''`) + "'", input
+}
+
+// ExecuteScript runs a script of newline-separated commands on the remote
+// host in a single SSH session. The script stops at the first failing
+// command, and the error names its line.
+func (c *RealSSHClient) ExecuteScript(ctx context.Context, host, script string) (string, error) {
+	return c.ExecuteScriptStream(ctx, host, script, nil)
+}
+
+// ExecuteScriptStream runs a script like ExecuteScript, passing every line of
+// its stdout and stderr to output as it arrives, if given.
+func (c *RealSSHClient) ExecuteScriptStream(ctx context.Context, host, script string, output func(line string)) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("script on %s not started: %w", host, err)
+	}
+	command, input := c.scriptCommand(script)
+	stdout, stderr, err := c.run(ctx, host, command, input, output)
+	if err != nil {
+		return "", fmt.Errorf("script failed on %s: %w, stderr: %s", host, err, stderr)
+	}
+	return stdout, nil
+}
+
+// run runs a command in a new SSH session, feeding it input on stdin. Lines
+// of its stdout and stderr are passed to output as they arrive, if given.
+func (c *RealSSHClient) run(ctx context.Context, host, command, input string, output func(line string)) (string, string, error) {
+	client, session, release, err := c.newSession(ctx, host)
 	if err != nil {
-		return "", fmt.Errorf("failed to create SSH session for %s: %w", host, err)
+		return "", "", err
 	}
+	defer release()
 	defer session.Close()
 
 	var stdout, stderr bytes.Buffer
 	session.Stdout = &stdout
 	session.Stderr = &stderr
+	if input != "" {
+		session.Stdin = strings.NewReader(input)
+	}
+	if output != nil {
+		// stdout and stderr are copied concurrently
+		var mu sync.Mutex
+		locked := func(line string) {
+			mu.Lock()
+			defer mu.Unlock()
+			output(line)
+		}
+		stdoutLines, stderrLines := &lineWriter{output: locked}, &lineWriter{output: locked}
+		defer stdoutLines.Flush()
+		defer stderrLines.Flush()
+		session.Stdout = io.MultiWriter(&stdout, stdoutLines)
+		session.Stderr = io.MultiWriter(&stderr, stderrLines)
+	}
 
-	if err := session.Run(command); err != nil {
-		return "", fmt.Errorf("failed to execute command '%s' on %s: %w, stderr: %s", command, host, err, stderr.String())
+	stop := c.stopOnDone(ctx, host, client, session)
+	err = session.Run(command)
+	if !stop() && err != nil {
+		err = fmt.Errorf("interrupted: %w", ctx.Err())
 	}
+	return stdout.String(), stderr.String(), err
+}
 
-	return stdout.String(), nil
+// newSession opens a session on the pooled connection to host. A connection
+// lost since its last session, e.g. when the node rebooted, is replaced
+// once. release hands the connection back after the session.
+func (c *RealSSHClient) newSession(ctx context.Context, host string) (*ssh.Client, *ssh.Session, func(), error) {
+	for retried := false; ; retried = true {
+		client, release, err := c.pool.get(ctx, host, c.createSSHClient)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to create SSH client for %s: %w", host, err)
+		}
+		session, err := client.NewSession()
+		if err == nil {
+			return client, session, release, nil
+		}
+		release()
+		// The host refusing the session doesn't mean the connection is lost
+		var refused *ssh.OpenChannelError
+		if retried || errors.As(err, &refused) {
+			return nil, nil, nil, fmt.Errorf("failed to create SSH session for %s: %w", host, err)
+		}
+		c.pool.drop(host, client)
+	}
 }
 
-// CopyFile copies a local file to the remote host via SSH.
-func (c *RealSSHClient) CopyFile(ctx context.Context, host, localPath, remotePath string) error {
-	client, err := c.createSSHClient(host)
-	if err != nil {
-		return fmt.Errorf("failed to create SSH client for %s: %w", host, err)
+// sshStopGrace is how long a killed session may take to end before its
+// connection is closed.
+const sshStopGrace = 5 * time.Second
+
+// stopOnDone kills the session's command and closes the session once ctx is
+// done, which ends the session's Wait and stops commands that ignore the
+// signal from writing output. If the host doesn't confirm within
+// sshStopGrace, the connection is dropped from the pool and closed. The
+// returned function stops watching ctx and reports false if the session was
+// already stopped.
+func (c *RealSSHClient) stopOnDone(ctx context.Context, host string, client *ssh.Client, session *ssh.Session) func() bool {
+	finished := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		select {
+		case <-finished:
+		case <-time.After(sshStopGrace):
+			c.pool.drop(host, client)
+		}
+	})
+	return func() bool {
+		close(finished)
+		return stop()
 	}
-	defer client.Close()
+}
 
-	session, err := client.NewSession()
-	if err != nil {
-		return fmt.Errorf("failed to create SSH session for %s: %w", host, err)
+// lineWriter passes every line written to it to output, splitting at
+// carriage returns too so progress updates come through. Empty lines are
+// dropped.
+type lineWriter struct {
+	output  func(line string)
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		end := bytes.IndexAny(w.partial, "\r\n")
+		if end < 0 {
+			return len(p), nil
+		}
+		w.emit(w.partial[:end])
+		w.partial = w.partial[end+1:]
 	}
-	defer session.Close()
+}
 
-	fileContent, err := os.ReadFile(localPath)
-	if err != nil {
-		return fmt.Errorf("failed to read local file %s: %w", localPath, err)
+// Flush passes on the last line if it didn't end in a newline.
+func (w *lineWriter) Flush() {
+	w.emit(w.partial)
+	w.partial = nil
+}
+
+func (w *lineWriter) emit(line []byte) {
+	if text := strings.TrimRight(string(line), " \t"); text != "" {
+		w.output(text)
 	}
+}
 
-	pipe, err := session.StdinPipe()
+// createSSHClient creates an SSH client for the specified host, tunneled
+// through the bastion when one is set. Connecting gives up when ctx is done.
+func (c *RealSSHClient) createSSHClient(ctx context.Context, host string) (*ssh.Client, error) {
+	host = withSSHPort(host)
+	config, release, err := c.clientConfig(c.user, c.keyPath, c.keyData)
 	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe for %s: %w", host, err)
+		return nil, err
+	}
+	defer release()
+	if c.bastion == nil {
+		return dialSSH(ctx, host, config)
 	}
 
-	go func() {
-		defer pipe.Close()
-		if _, err := pipe.Write(fileContent); err != nil {
-			return
+	bastionConfig := config
+	if c.bastion.user != c.user || c.bastion.keyPath != "" {
+		keyPath, keyData := c.keyPath, c.keyData
+		if c.bastion.keyPath != "" {
+			keyPath, keyData = c.bastion.keyPath, nil
 		}
-	}()
-
-	if err := session.Run(fmt.Sprintf("sudo tee %s > /dev/null", remotePath)); err != nil {
-		return fmt.Errorf("failed to copy file to %s on %s: %w", remotePath, host, err)
+		var releaseBastion func()
+		if bastionConfig, releaseBastion, err = c.clientConfig(c.bastion.user, keyPath, keyData); err != nil {
+			return nil, err
+		}
+		defer releaseBastion()
 	}
-
-	// Create new session for permission setting
-	permSession, err := client.NewSession()
+	bastion, err := dialSSH(ctx, c.bastion.address, bastionConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create permission session for %s: %w", host, err)
+		return nil, fmt.Errorf("failed to connect to bastion %s: %w", c.bastion.address, err)
 	}
-	defer permSession.Close()
-
-	if err := permSession.Run(fmt.Sprintf("sudo chmod 644 %s", remotePath)); err != nil {
-		return fmt.Errorf("failed to set permissions for %s on %s: %w", remotePath, host, err)
+	conn, err := bastion.DialContext(ctx, "tcp", host)
+	if err != nil {
+		bastion.Close()
+		return nil, fmt.Errorf("failed to reach %s through bastion %s: %w", host, c.bastion.address, err)
 	}
-
-	return nil
+	client, err := handshake(ctx, conn, host, config)
+	if err != nil {
+		bastion.Close()
+		return nil, err
+	}
+	// Closing the node's client closes the tunnel, then the bastion's
+	go func() {
+		client.Wait()
+		bastion.Close()
+	}()
+	return client, nil
 }
 
-// CopyContent copies content directly to a remote file via SSH.
-func (c *RealSSHClient) CopyContent(ctx context.Context, host, content, remotePath string) error {
-	client, err := c.createSSHClient(host)
+// dialSSH connects to an SSH server, giving up when ctx is done.
+func dialSSH(ctx context.Context, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialer := net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
 	if err != nil {
-		return fmt.Errorf("failed to create SSH client for %s: %w", host, err)
+		return nil, err
 	}
-	defer client.Close()
+	return handshake(ctx, conn, address, config)
+}
 
-	session, err := client.NewSession()
+// handshake starts an SSH connection over conn, closing it if ctx is done
+// before the handshake completes.
+func handshake(ctx context.Context, conn net.Conn, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if !stop() {
+		if err == nil {
+			clientConn.Close()
+		}
+		return nil, fmt.Errorf("connection to %s interrupted: %w", address, ctx.Err())
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create SSH session for %s: %w", host, err)
+		conn.Close()
+		return nil, err
 	}
-	defer session.Close()
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
 
-	pipe, err := session.StdinPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe for %s: %w", host, err)
+// clientConfig returns the config of a connection as user. It offers the key
+// read from keyPath, unless its content is given, and then the keys of the
+// SSH agent, so that either can authenticate. release closes the connection
+// to the agent once the SSH handshake is done.
+func (c *RealSSHClient) clientConfig(user, keyPath string, key []byte) (*ssh.ClientConfig, func(), error) {
+	var signers []ssh.Signer
+	var keyErr error
+	if keyPath != "" || key != nil {
+		signer, err := c.signer(keyPath, key)
+		var missing *ssh.PassphraseMissingError
+		switch {
+		case errors.As(err, &missing):
+			// The agent may hold the decrypted key
+			keyErr = fmt.Errorf("SSH key %s is protected by a passphrase: set ssh_key_passphrase or add the key to an SSH agent", keyPath)
+		case err != nil:
+			return nil, nil, err
+		default:
+			signers = append(signers, signer)
+		}
 	}
 
-	go func() {
-		defer pipe.Close()
-		if _, err := io.WriteString(pipe, content); err != nil {
-			return
+	agentKeys, release, agentErr := agentSigners()
+	signers = append(signers, agentKeys...)
+	if len(signers) == 0 {
+		release()
+		switch {
+		case keyErr != nil:
+			return nil, nil, keyErr
+		case agentErr != nil:
+			return nil, nil, agentErr
 		}
-	}()
+		return nil, nil, fmt.Errorf("the SSH agent holds no keys")
+	}
 
-	if err := session.Run(fmt.Sprintf("sudo tee %s > /dev/null", remotePath)); err != nil {
-		return fmt.Errorf("failed to copy content to %s on %s: %w", remotePath, host, err)
+	return &ssh.ClientConfig{
+		User: user,
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(signers...),
+		},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         30 * time.Second,
+	}, release, nil
+}
+
+// signer parses a private key read from keyPath unless its content is given,
+// decrypting it with the passphrase if it's protected.
+func (c *RealSSHClient) signer(keyPath string, key []byte) (ssh.Signer, error) {
+	if key == nil {
+		var err error
+		key, err = os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SSH key %s: %w", keyPath, err)
+		}
 	}
 
-	// Create new session for permission setting
-	permSession, err := client.NewSession()
+	signer, err := ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && c.passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(c.passphrase))
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create permission session for %s: %w", host, err)
+		return nil, fmt.Errorf("failed to parse SSH key %s: %w", keyPath, err)
 	}
-	defer permSession.Close()
+	return signer, nil
+}
 
-	if err := permSession.Run(fmt.Sprintf("sudo chmod 644 %s", remotePath)); err != nil {
-		return fmt.Errorf("failed to set permissions for %s on %s: %w", remotePath, host, err)
+// agentSigners returns the keys of the SSH agent at $SSH_AUTH_SOCK, if one
+// runs, and a function closing the connection to it.
+func agentSigners() ([]ssh.Signer, func(), error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, func() {}, nil
 	}
-
-	return nil
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("failed to connect to the SSH agent at %s: %w", socket, err)
+	}
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil, func() {}, fmt.Errorf("failed to list the keys of the SSH agent: %w", err)
+	}
+	return signers, func() { conn.Close() }, nil
 }
 
-// createSSHClient creates an SSH client for the specified host.
-func (c *RealSSHClient) createSSHClient(host string) (*ssh.Client, error) {
-	// Ensure host has port
-	if !strings.Contains(host, ":") {
-		host = host + ":22"
+// KeyNeedsPassphrase reports whether a private key file is protected by a
+// passphrase. Keys that can't be read report false and fail on connecting.
+func KeyNeedsPassphrase(keyPath string) bool {
+	keyPath, err := expandHome(keyPath)
+	if err != nil {
+		return false
 	}
-	
-	key, err := os.ReadFile(c.keyPath)
+	key, err := os.ReadFile(keyPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read SSH key %s: %w", c.keyPath, err)
+		return false
 	}
+	_, err = ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	return errors.As(err, &missing)
+}
 
-	signer, err := ssh.ParsePrivateKey(key)
+// CheckKeyPassphrase checks that a passphrase decrypts a private key file.
+func CheckKeyPassphrase(keyPath, passphrase string) error {
+	keyPath, err := expandHome(keyPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse SSH key %s: %w", c.keyPath, err)
+		return err
 	}
-
-	config := &ssh.ClientConfig{
-		User: c.user,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:        30 * time.Second, // Add timeout
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read SSH key %s: %w", keyPath, err)
+	}
+	if _, err := ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase)); err != nil {
+		return fmt.Errorf("failed to decrypt SSH key %s: %w", keyPath, err)
 	}
+	return nil
+}
 
-	return ssh.Dial("tcp", host, config)
-}
\ No newline at end of file
+// withSSHPort adds the default SSH port to a host without one.
+func withSSHPort(host string) string {
+	if !strings.Contains(host, ":") {
+		return host + ":22"
+	}
+	return host
+}
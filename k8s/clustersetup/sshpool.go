@@ -0,0 +1,171 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// sshpool.go keeps one SSH connection open per host and shares it between sessions.
+package clustersetup
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// sshKeepaliveInterval is how often idle connections are checked
+	sshKeepaliveInterval = 15 * time.Second
+	// sshKeepaliveTimeout is how long a host may take to answer a keepalive
+	sshKeepaliveTimeout = 10 * time.Second
+	// sshIdleTimeout is how long a connection without sessions stays open
+	sshIdleTimeout = 5 * time.Minute
+	// sshMaxSessions is how many sessions share a connection; OpenSSH's
+	// MaxSessions defaults to 10
+	sshMaxSessions = 8
+)
+
+// sshPool holds the open connection to every host.
+type sshPool struct {
+	mu    sync.Mutex
+	conns map[string]*pooledConn
+}
+
+// pooledConn is the connection to a host. mu is held while connecting so
+// that concurrent sessions wait for a single handshake.
+type pooledConn struct {
+	mu       sync.Mutex
+	client   *ssh.Client
+	sessions int
+	lastUsed time.Time
+}
+
+// get returns the open connection to host, connecting with dial if there is
+// none or it was lost. release must be called once the session on it ends.
+// Hosts already running sshMaxSessions sessions get a connection of their
+// own, closed on release.
+func (p *sshPool) get(ctx context.Context, host string, dial func(context.Context, string) (*ssh.Client, error)) (*ssh.Client, func(), error) {
+	p.mu.Lock()
+	if p.conns == nil {
+		p.conns = make(map[string]*pooledConn)
+	}
+	conn := p.conns[host]
+	if conn == nil {
+		conn = &pooledConn{}
+		p.conns[host] = conn
+	}
+	p.mu.Unlock()
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.client != nil && conn.sessions >= sshMaxSessions {
+		client, err := dial(ctx, host)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, func() { client.Close() }, nil
+	}
+	if conn.client == nil {
+		client, err := dial(ctx, host)
+		if err != nil {
+			return nil, nil, err
+		}
+		conn.client = client
+		go p.keepalive(host, conn, client)
+	}
+
+	conn.sessions++
+	client := conn.client
+	var once sync.Once
+	return client, func() {
+		once.Do(func() {
+			conn.mu.Lock()
+			defer conn.mu.Unlock()
+			conn.sessions--
+			conn.lastUsed = time.Now()
+		})
+	}, nil
+}
+
+// drop closes a connection to host, so that the next session reconnects.
+func (p *sshPool) drop(host string, client *ssh.Client) {
+	p.mu.Lock()
+	conn := p.conns[host]
+	p.mu.Unlock()
+	if conn != nil {
+		conn.mu.Lock()
+		if conn.client == client {
+			conn.client = nil
+		}
+		conn.mu.Unlock()
+	}
+	client.Close()
+}
+
+// keepalive checks a connection until it is lost, replaced or idle for
+// sshIdleTimeout, and then drops it. Connections to hosts that stop
+// answering are dropped rather than left to hang the next session.
+func (p *sshPool) keepalive(host string, conn *pooledConn, client *ssh.Client) {
+	lost := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(lost)
+	}()
+	ticker := time.NewTicker(sshKeepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lost:
+			p.drop(host, client)
+			return
+		case <-ticker.C:
+		}
+
+		conn.mu.Lock()
+		if conn.client != client {
+			conn.mu.Unlock()
+			return
+		}
+		// An idle connection is dropped under the lock, so a session that
+		// starts meanwhile waits and reconnects instead of losing it
+		if conn.sessions == 0 && time.Since(conn.lastUsed) > sshIdleTimeout {
+			conn.client = nil
+			conn.mu.Unlock()
+			client.Close()
+			return
+		}
+		conn.mu.Unlock()
+		if !answers(client) {
+			p.drop(host, client)
+			return
+		}
+	}
+}
+
+// answers sends a keepalive request and reports whether the host replied in time.
+func answers(client *ssh.Client) bool {
+	reply := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		reply <- err
+	}()
+	select {
+	case err := <-reply:
+		return err == nil
+	case <-time.After(sshKeepaliveTimeout):
+		return false
+	}
+}
+
+// closeAll closes every connection.
+func (p *sshPool) closeAll() {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = nil
+	p.mu.Unlock()
+	for _, conn := range conns {
+		conn.mu.Lock()
+		if conn.client != nil {
+			conn.client.Close()
+			conn.client = nil
+		}
+		conn.mu.Unlock()
+	}
+}
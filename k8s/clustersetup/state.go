@@ -0,0 +1,188 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// state.go records which setup steps completed so an interrupted setup can resume.
+package clustersetup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SetupStateFile is the file in the work directory that records the setup
+// steps that completed.
+const SetupStateFile = ".cluster-state.json"
+
+// SetupState records the setup steps that completed. Phases are recorded by
+// name, such as "certificates", node steps by phase and node, such as
+// "workers/worker-0", and hook points as "hooks/pre_setup". Config is the
+// configuration last applied to the cluster, which ApplyConfigChanges diffs
+// against; states written before it was recorded have none.
+type SetupState struct {
+	ClusterName       string               `json:"cluster_name"`
+	KubernetesVersion string               `json:"kubernetes_version"`
+	Completed         map[string]time.Time `json:"completed"`
+	Config            *ClusterConfig       `json:"config,omitempty"`
+
+	path string
+}
+
+// newSetupState creates an empty state for a setup of the config.
+func newSetupState(config ClusterConfig) *SetupState {
+	return &SetupState{
+		ClusterName:       config.ClusterName,
+		KubernetesVersion: config.KubernetesVersion,
+		Completed:         map[string]time.Time{},
+		Config:            appliedConfig(config),
+		path:              filepath.Join(config.WorkDir, SetupStateFile),
+	}
+}
+
+// appliedConfig returns the config to record in the setup state, without
+// the Vault token.
+func appliedConfig(config ClusterConfig) *ClusterConfig {
+	config.Vault.Token = ""
+	return &config
+}
+
+// recordConfig records the manager's config as the one applied to the
+// cluster after an operation changed it. Work directories without a setup
+// state are left alone.
+func (cm *ClusterManager) recordConfig() {
+	state, err := LoadSetupState(cm.config.WorkDir)
+	if err != nil {
+		return
+	}
+	state.KubernetesVersion = cm.config.KubernetesVersion
+	state.Config = appliedConfig(cm.config)
+	if err := state.save(); err != nil {
+		cm.logger.Warn(fmt.Sprintf("Failed to record the applied config: %v", err))
+	}
+}
+
+// LoadSetupState reads the state a setup left in workDir.
+func LoadSetupState(workDir string) (*SetupState, error) {
+	path := filepath.Join(workDir, SetupStateFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read setup state: %w", err)
+	}
+	state := &SetupState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse setup state %s: %w", path, err)
+	}
+	if state.Completed == nil {
+		state.Completed = map[string]time.Time{}
+	}
+	state.path = path
+	return state, nil
+}
+
+// Done reports whether the step completed. A nil state has no completed steps.
+func (s *SetupState) Done(step string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.Completed[step]
+	return ok
+}
+
+// complete records that the step completed and saves the state.
+func (s *SetupState) complete(step string) error {
+	if s == nil {
+		return nil
+	}
+	s.Completed[step] = time.Now()
+	return s.save()
+}
+
+// save writes the state to its file, replacing it atomically so an
+// interrupted write doesn't lose the steps recorded before.
+func (s *SetupState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode setup state: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write setup state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write setup state: %w", err)
+	}
+	return nil
+}
+
+// completeStep records a completed setup step. The setup carries on if the
+// state can't be saved; a later resume just repeats the step.
+func (cm *ClusterManager) completeStep(step string) {
+	if err := cm.state.complete(step); err != nil {
+		cm.logger.Warn(fmt.Sprintf("Failed to record setup step %s: %v", step, err))
+	}
+}
+
+// once runs a setup phase unless a resumed setup already completed it, and
+// records it once it succeeds.
+func (cm *ClusterManager) once(step string, run func() error) error {
+	if cm.state.Done(step) {
+		cm.logger.Info(fmt.Sprintf("Skipping %s, which completed before", step))
+		if cm.tracker != nil {
+			cm.tracker.skip(cm.setupPhaseSteps()[step])
+		}
+		return nil
+	}
+	if err := run(); err != nil {
+		return err
+	}
+	cm.completeStep(step)
+	return nil
+}
+
+// runHooksOnce runs the hooks of a hook point unless a resumed setup already
+// ran them.
+func (cm *ClusterManager) runHooksOnce(ctx context.Context, point HookPoint) error {
+	return cm.once("hooks/"+string(point), func() error {
+		return cm.runHooks(ctx, point)
+	})
+}
+
+// nodeDone reports whether a resumed setup already completed the node step
+// and the node's services are still active, in which case the node is
+// skipped. A node whose services stopped is set up again.
+func (cm *ClusterManager) nodeDone(ctx context.Context, step string, node Node, steps int, services ...string) bool {
+	if !cm.state.Done(step) {
+		return false
+	}
+	output, err := cm.sshClient.ExecuteCommand(ctx, node.IPAddress, "systemctl is-active "+strings.Join(services, " ")+" || true")
+	states := strings.Fields(output)
+	active := err == nil && len(states) == len(services)
+	for _, state := range states {
+		active = active && state == "active"
+	}
+	if !active {
+		cm.logger.Warn(fmt.Sprintf("%s was set up before but its services aren't all active; setting it up again", node.Name))
+		return false
+	}
+	cm.logger.Info(fmt.Sprintf("Skipping %s, which was set up before", node.Name))
+	if cm.tracker != nil {
+		cm.tracker.skip(steps)
+	}
+	return true
+}
+
+// keepExisting reports whether a resumed setup already wrote every one of
+// the files to the work directory, so they're kept rather than replaced.
+func (cm *ClusterManager) keepExisting(files ...string) bool {
+	if !cm.resuming {
+		return false
+	}
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(cm.config.WorkDir, file)); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,180 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// systemd.go builds the restart, limit and hardening directives of the generated units.
+package clustersetup
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SystemdConfig tunes the generated systemd units. The inline settings apply
+// to every unit and Services overrides them per unit, keyed by service name
+// such as "kube-apiserver". Hardening adds sandboxing directives to the units
+// that tolerate them; kubelet and containerd are never sandboxed because the
+// containers they start inherit the restrictions.
+type SystemdConfig struct {
+	UnitConfig `yaml:",inline"`
+	Hardening  bool                  `yaml:"hardening,omitempty"`
+	Services   map[string]UnitConfig `yaml:"services,omitempty"`
+}
+
+// UnitConfig holds the restart policy and resource limits of a unit. Zero
+// values keep the default or the value inherited from SystemdConfig.
+// ActiveTimeoutSec isn't part of the unit; it replaces how long setup waits
+// for the unit to become active.
+type UnitConfig struct {
+	Restart               string `yaml:"restart,omitempty"`
+	RestartSec            int    `yaml:"restart_sec,omitempty"`
+	StartLimitBurst       int    `yaml:"start_limit_burst,omitempty"`
+	StartLimitIntervalSec int    `yaml:"start_limit_interval_sec,omitempty"`
+	LimitNOFILE           string `yaml:"limit_nofile,omitempty"`
+	LimitNPROC            string `yaml:"limit_nproc,omitempty"`
+	ActiveTimeoutSec      int    `yaml:"active_timeout_sec,omitempty"`
+}
+
+// restartPolicies are the Restart= values systemd accepts.
+var restartPolicies = []string{"no", "always", "on-success", "on-failure", "on-abnormal", "on-abort", "on-watchdog"}
+
+// limitPattern matches a resource limit: a number or "infinity".
+var limitPattern = regexp.MustCompile(`^([0-9]+|infinity)$`)
+
+// defaultUnit is the restart policy of every generated unit.
+var defaultUnit = UnitConfig{Restart: "on-failure", RestartSec: 5}
+
+// serviceDefaults are the limits of units that need more than the default.
+var serviceDefaults = map[string]UnitConfig{
+	"containerd": {LimitNOFILE: "1048576", LimitNPROC: "infinity"},
+}
+
+// hardening lists the sandboxing directives of each unit that tolerates them.
+// etcd also gets ReadWritePaths for its data directories.
+var hardening = map[string][]string{
+	"etcd":                    {"NoNewPrivileges=yes", "ProtectSystem=strict", "ProtectHome=yes", "PrivateTmp=yes", "ProtectKernelTunables=yes", "ProtectControlGroups=yes"},
+	"kube-apiserver":          {"NoNewPrivileges=yes", "ProtectSystem=full", "ProtectHome=yes", "PrivateTmp=yes", "ProtectKernelTunables=yes", "ProtectControlGroups=yes"},
+	"kube-controller-manager": {"NoNewPrivileges=yes", "ProtectSystem=full", "ProtectHome=yes", "PrivateTmp=yes", "ProtectKernelTunables=yes", "ProtectControlGroups=yes"},
+	"kube-scheduler":          {"NoNewPrivileges=yes", "ProtectSystem=full", "ProtectHome=yes", "PrivateTmp=yes", "ProtectKernelTunables=yes", "ProtectControlGroups=yes"},
+	// kube-proxy writes sysctls and loads kernel modules
+	"kube-proxy": {"NoNewPrivileges=yes", "ProtectHome=yes", "PrivateTmp=yes"},
+}
+
+// validateSystemd checks restart policies, intervals and limits, and that
+// per-service overrides name a generated unit.
+func validateSystemd(config SystemdConfig) error {
+	if err := validateUnit(config.UnitConfig); err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, services := range [][]string{etcdServices, controllerServices, {kubeVIPService}, workerServices} {
+		for _, service := range services {
+			known[service] = true
+		}
+	}
+	for service, unit := range config.Services {
+		if !known[service] {
+			return fmt.Errorf("unknown service %q", service)
+		}
+		if err := validateUnit(unit); err != nil {
+			return fmt.Errorf("service %s: %w", service, err)
+		}
+	}
+	return nil
+}
+
+// validateUnit checks the settings of a single unit.
+func validateUnit(unit UnitConfig) error {
+	if unit.Restart != "" && !contains(restartPolicies, unit.Restart) {
+		return fmt.Errorf("restart must be one of %s", strings.Join(restartPolicies, ", "))
+	}
+	if unit.RestartSec < 0 || unit.StartLimitBurst < 0 || unit.StartLimitIntervalSec < 0 || unit.ActiveTimeoutSec < 0 {
+		return fmt.Errorf("restart_sec, start limits and active_timeout_sec must not be negative")
+	}
+	for name, limit := range map[string]string{"limit_nofile": unit.LimitNOFILE, "limit_nproc": unit.LimitNPROC} {
+		if limit != "" && !limitPattern.MatchString(limit) {
+			return fmt.Errorf("%s must be a number or \"infinity\", got %q", name, limit)
+		}
+	}
+	return nil
+}
+
+// contains reports whether values includes value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// merge overrides the settings of u with the non-zero settings of o.
+func (u UnitConfig) merge(o UnitConfig) UnitConfig {
+	if o.Restart != "" {
+		u.Restart = o.Restart
+	}
+	if o.RestartSec != 0 {
+		u.RestartSec = o.RestartSec
+	}
+	if o.StartLimitBurst != 0 {
+		u.StartLimitBurst = o.StartLimitBurst
+	}
+	if o.StartLimitIntervalSec != 0 {
+		u.StartLimitIntervalSec = o.StartLimitIntervalSec
+	}
+	if o.LimitNOFILE != "" {
+		u.LimitNOFILE = o.LimitNOFILE
+	}
+	if o.LimitNPROC != "" {
+		u.LimitNPROC = o.LimitNPROC
+	}
+	if o.ActiveTimeoutSec != 0 {
+		u.ActiveTimeoutSec = o.ActiveTimeoutSec
+	}
+	return u
+}
+
+// unitConfig resolves the settings of a service from the defaults, the
+// cluster-wide settings and the service's overrides.
+func (cm *ClusterManager) unitConfig(service string) UnitConfig {
+	return defaultUnit.merge(serviceDefaults[service]).
+		merge(cm.config.Systemd.UnitConfig).
+		merge(cm.config.Systemd.Services[service])
+}
+
+// unitDirectives renders the [Unit] section directives of a service, each
+// preceded by a newline so they can follow the last fixed directive.
+func (cm *ClusterManager) unitDirectives(service string) string {
+	unit := cm.unitConfig(service)
+	directives := ""
+	if unit.StartLimitIntervalSec > 0 {
+		directives += fmt.Sprintf("\nStartLimitIntervalSec=%d", unit.StartLimitIntervalSec)
+	}
+	if unit.StartLimitBurst > 0 {
+		directives += fmt.Sprintf("\nStartLimitBurst=%d", unit.StartLimitBurst)
+	}
+	return directives
+}
+
+// serviceDirectives renders the restart, limit and hardening directives of
+// the [Service] section of a service.
+func (cm *ClusterManager) serviceDirectives(service string) string {
+	unit := cm.unitConfig(service)
+	lines := []string{"Restart=" + unit.Restart, fmt.Sprintf("RestartSec=%d", unit.RestartSec)}
+	if unit.LimitNOFILE != "" {
+		lines = append(lines, "LimitNOFILE="+unit.LimitNOFILE)
+	}
+	if unit.LimitNPROC != "" {
+		lines = append(lines, "LimitNPROC="+unit.LimitNPROC)
+	}
+	if cm.config.Systemd.Hardening {
+		lines = append(lines, hardening[service]...)
+		if service == "etcd" {
+			paths := cm.config.Etcd.dataDir()
+			if cm.config.Etcd.WALDir != "" {
+				paths += " " + cm.config.Etcd.WALDir
+			}
+			lines = append(lines, "ReadWritePaths="+paths)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
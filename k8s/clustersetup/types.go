@@ -4,33 +4,115 @@ package clustersetup
 
 import (
 	"context"
+	"io"
+	"time"
 )
 
 // ClusterConfig defines the configuration for the Kubernetes cluster.
 type ClusterConfig struct {
-	ClusterName       string            `yaml:"cluster_name"`
-	KubernetesVersion string            `yaml:"kubernetes_version"`
-	EtcdVersion       string            `yaml:"etcd_version"`
-	ContainerdVersion string            `yaml:"containerd_version"`
-	CNIVersion        string            `yaml:"cni_version"`
-	CoreDNSVersion    string            `yaml:"coredns_version"`
-	PodCIDR           string            `yaml:"pod_cidr"`
-	ServiceCIDR       string            `yaml:"service_cidr"`
-	ClusterDNS        string            `yaml:"cluster_dns"`
-	WorkDir           string            `yaml:"work_dir"`
-	SSHKey            string            `yaml:"ssh_key"`
-	SSHUser           string            `yaml:"ssh_user"`
-	Controller        Node              `yaml:"controller"`
-	Workers           []Node            `yaml:"workers"`
-	Certificates      CertificateConfig `yaml:"certificates"`
+	ClusterName       string `yaml:"cluster_name"`
+	KubernetesVersion string `yaml:"kubernetes_version"`
+	EtcdVersion       string `yaml:"etcd_version"`
+	ContainerdVersion string `yaml:"containerd_version"`
+	CNIVersion        string `yaml:"cni_version"`
+	// CNIProvider selects the pod network: "bridge" (the default) or one of
+	// "calico", "flannel" and "cilium", deployed from upstream at
+	// CNIProviderVersion.
+	CNIProvider        string `yaml:"cni_provider,omitempty"`
+	CNIProviderVersion string `yaml:"cni_provider_version,omitempty"`
+	CoreDNSVersion     string `yaml:"coredns_version"`
+	// SandboxImage replaces containerd's default pause image, for example
+	// with a copy in a mirrored or air-gapped registry.
+	SandboxImage string `yaml:"sandbox_image,omitempty"`
+	// AirGapped downloads every binary and image into the work directory
+	// first and pushes them to the nodes, which then need no internet access.
+	AirGapped bool `yaml:"air_gapped,omitempty"`
+	// Proxy is the HTTP proxy nodes download binaries and pull images through.
+	Proxy   ProxyConfig `yaml:"proxy,omitempty"`
+	PodCIDR string      `yaml:"pod_cidr"`
+	// NodeCIDRMaskSize is the prefix length of the pod CIDRs allocated from
+	// PodCIDR to workers that don't set one; it defaults to 24.
+	NodeCIDRMaskSize int    `yaml:"node_cidr_mask_size,omitempty"`
+	ServiceCIDR      string `yaml:"service_cidr"`
+	ClusterDNS       string `yaml:"cluster_dns"`
+	WorkDir          string `yaml:"work_dir"`
+	SSHKey           string `yaml:"ssh_key"`
+	SSHUser          string `yaml:"ssh_user"`
+	// SSHKeyPassphrase decrypts a passphrase-protected ssh_key and
+	// bastion_key. Without it, such keys must be loaded into the SSH agent.
+	SSHKeyPassphrase string `yaml:"ssh_key_passphrase,omitempty"`
+	// BastionHost is the jump host ("host" or "host:port") nodes with only
+	// private addresses are reached through. BastionUser and BastionKey
+	// default to SSHUser and SSHKey.
+	BastionHost string `yaml:"bastion_host,omitempty"`
+	BastionUser string `yaml:"bastion_user,omitempty"`
+	BastionKey  string `yaml:"bastion_key,omitempty"`
+	// OSFamily is "debian" or "rhel" for every node; empty detects the
+	// family of each node from its /etc/os-release.
+	OSFamily   string `yaml:"os_family,omitempty"`
+	Controller Node   `yaml:"controller"`
+	// Controllers lists every control plane node of an HA cluster, starting
+	// with the primary controller; etcd is stacked on them unless it runs on
+	// dedicated nodes. ControlPlaneEndpoint is the load balancer in front of
+	// their API servers.
+	Controllers          []Node `yaml:"controllers,omitempty"`
+	ControlPlaneEndpoint string `yaml:"control_plane_endpoint,omitempty"`
+	// KubeVIP holds ControlPlaneEndpoint as a virtual IP on the controllers
+	// in place of an external load balancer.
+	KubeVIP      KubeVIPConfig     `yaml:"kube_vip,omitempty"`
+	Workers      []Node            `yaml:"workers"`
+	Etcd         EtcdConfig        `yaml:"etcd,omitempty"`
+	Certificates CertificateConfig `yaml:"certificates"`
+	// KubeletServingCerts selects how kubelets obtain their serving
+	// certificates: "static" (the default) or "bootstrap".
+	KubeletServingCerts string                 `yaml:"kubelet_serving_certs,omitempty"`
+	CoreDNS             CoreDNSConfig          `yaml:"coredns,omitempty"`
+	Systemd             SystemdConfig          `yaml:"systemd,omitempty"`
+	ResourceDefaults    ResourceDefaultsConfig `yaml:"resource_defaults,omitempty"`
+	PriorityClasses     []PriorityClassConfig  `yaml:"priority_classes,omitempty"`
+	// WebhookSmokeTest deploys a throwaway validating webhook during
+	// validation to prove the API servers can reach webhooks in the cluster.
+	WebhookSmokeTest bool `yaml:"webhook_smoke_test,omitempty"`
+	// Addons lists the built-in addons, such as metrics-server, installed
+	// once the cluster is validated.
+	Addons        []string           `yaml:"addons,omitempty"`
+	Hooks         HooksConfig        `yaml:"hooks,omitempty"`
+	Notifications NotificationConfig `yaml:"notifications,omitempty"`
+	// Logging sets the level and format of the setup log file, setup.log in
+	// the work directory unless File names another.
+	Logging LoggingConfig `yaml:"logging,omitempty"`
+	Vault   VaultConfig   `yaml:"vault,omitempty"`
 }
 
-// Node represents a node in the cluster.
+// Kubelet serving certificate modes.
+const (
+	// KubeletServingStatic issues each kubelet a serving certificate from the
+	// cluster CA during setup.
+	KubeletServingStatic = "static"
+	// KubeletServingBootstrap has kubelets request serving certificates from
+	// the API server and rotate them before they expire.
+	KubeletServingBootstrap = "bootstrap"
+)
+
+// Node represents a node in the cluster. PublicAddress is the IP or DNS
+// name clients outside the node network reach the controller on.
 type Node struct {
-	Name      string `yaml:"name"`
-	IPAddress string `yaml:"ip_address"`
-	Hostname  string `yaml:"hostname"`
-	PodCIDR   string `yaml:"pod_cidr,omitempty"`
+	Name          string `yaml:"name"`
+	IPAddress     string `yaml:"ip_address"`
+	Hostname      string `yaml:"hostname"`
+	PodCIDR       string `yaml:"pod_cidr,omitempty"`
+	PublicAddress string `yaml:"public_address,omitempty"`
+	// Labels and Taints are applied to a worker once it registers. A taint
+	// maps its key to "value:Effect", or to just the effect.
+	Labels map[string]string `yaml:"labels,omitempty"`
+	Taints map[string]string `yaml:"taints,omitempty"`
+}
+
+// Nodes returns every node of the cluster: the controllers, any dedicated
+// etcd members, then the workers.
+func (c ClusterConfig) Nodes() []Node {
+	nodes := append(append([]Node{}, c.ControlPlane()...), c.Etcd.Members...)
+	return append(nodes, c.Workers...)
 }
 
 // CertificateConfig defines certificate generation parameters.
@@ -50,13 +132,38 @@ type ClusterStatus struct {
 	TestStatus string
 }
 
+// CertificateExpiry describes when a generated certificate expires.
+type CertificateExpiry struct {
+	Name     string
+	NotAfter time.Time
+}
+
 // SSHClient defines the interface for SSH operations.
 type SSHClient interface {
 	ExecuteCommand(ctx context.Context, host, command string) (string, error)
+	// ExecuteScript runs newline-separated commands in one session, stopping
+	// at the first that fails.
+	ExecuteScript(ctx context.Context, host, script string) (string, error)
+	// CopyFile copies a local file or directory, keeping its permissions.
 	CopyFile(ctx context.Context, host, localPath, remotePath string) error
+	// CopyFileOwned copies like CopyFile and hands the copies to owner,
+	// given as "user" or "user:group".
+	CopyFileOwned(ctx context.Context, host, localPath, remotePath, owner string) error
 	CopyContent(ctx context.Context, host, content, remotePath string) error
 }
 
+// StreamingSSHClient is implemented by SSH clients that can hand over the
+// output of long-running commands line by line while they run.
+type StreamingSSHClient interface {
+	SSHClient
+	// ExecuteCommandStream runs a command like ExecuteCommand, calling output
+	// with every line of its stdout and stderr as it arrives.
+	ExecuteCommandStream(ctx context.Context, host, command string, output func(line string)) (string, error)
+	// ExecuteScriptStream runs a script like ExecuteScript, streaming its
+	// output the same way.
+	ExecuteScriptStream(ctx context.Context, host, script string, output func(line string)) (string, error)
+}
+
 // CertificateManager defines the interface for certificate operations.
 type CertificateManager interface {
 	GenerateCA(workDir string, config CertificateConfig) error
@@ -80,6 +187,13 @@ type ProgressReporter interface {
 	Finish(success bool, message string)
 }
 
+// StructuredProgressReporter is implemented by progress reporters that can
+// render nested phase, node and step progress with elapsed time and ETA.
+type StructuredProgressReporter interface {
+	ProgressReporter
+	ReportStep(update ProgressUpdate)
+}
+
 // ClusterManager manages the Kubernetes cluster setup.
 type ClusterManager struct {
 	config      ClusterConfig
@@ -87,15 +201,55 @@ type ClusterManager struct {
 	sshClient   SSHClient
 	certManager CertificateManager
 	progress    ProgressReporter
+	events      *EventBus
+	tracker     *progressTracker
+	// state records the steps of the running setup; resuming is set when it
+	// continues an earlier one
+	state    *SetupState
+	resuming bool
+	// runbook records the commands and files of the setup instead of running
+	// them while a runbook is exported
+	runbook *runbook
+	// metrics times the running setup
+	metrics *setupMetrics
+	// alerts holds the drift and expiring certificates already published
+	alerts alerts
+	// logFile is the setup log file LogToFile opened
+	logFile *setupLog
 }
 
 // NewClusterManager creates a new ClusterManager.
 func NewClusterManager(config ClusterConfig, logger Logger, sshClient SSHClient, certManager CertificateManager, progress ProgressReporter) *ClusterManager {
-	return &ClusterManager{
+	cm := &ClusterManager{
 		config:      config,
 		logger:      logger,
-		sshClient:   sshClient,
 		certManager: certManager,
 		progress:    progress,
+		events:      NewEventBus(),
+	}
+	cm.sshClient = &eventSSHClient{SSHClient: sshClient, publish: cm.publish, record: cm.recordCommand}
+	cm.events.Subscribe(progressSubscriber(progress))
+	cm.events.Subscribe(loggerSubscriber(logger))
+	if len(config.Notifications.Webhooks) > 0 {
+		cm.events.Subscribe(NewWebhookNotifier(config.Notifications, logger).Handle)
+	}
+	return cm
+}
+
+// Close closes the connections the SSH client keeps open to the nodes, if it
+// keeps any, and the setup log file.
+func (cm *ClusterManager) Close() error {
+	var err error
+	if client, ok := cm.sshClient.(*eventSSHClient); ok {
+		if closer, ok := client.SSHClient.(io.Closer); ok {
+			err = closer.Close()
+		}
 	}
-}
\ No newline at end of file
+	if cm.logFile != nil {
+		if closeErr := cm.logFile.Close(); err == nil {
+			err = closeErr
+		}
+		cm.logFile = nil
+	}
+	return err
+}
@@ -2,34 +2,59 @@ package clustersetup
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 // Mock implementations for testing
 type MockSSHClient struct {
+	mu           sync.Mutex
 	commands     []string
 	responses    map[string]string
 	errors       map[string]error
 	filesUploaded map[string]string
+	fileOwners    map[string]string
 }
 
 func NewMockSSHClient() *MockSSHClient {
 	return &MockSSHClient{
 		commands:     []string{},
-		responses:    make(map[string]string),
+		responses:    map[string]string{"uname -s": "Linux\n"},
 		errors:       make(map[string]error),
 		filesUploaded: make(map[string]string),
+		fileOwners:    make(map[string]string),
 	}
 }
 
 func (m *MockSSHClient) ExecuteCommand(ctx context.Context, host, command string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.commands = append(m.commands, fmt.Sprintf("%s: %s", host, command))
 	if err, exists := m.errors[command]; exists {
 		return "", err
@@ -37,36 +62,77 @@ func (m *MockSSHClient) ExecuteCommand(ctx context.Context, host, command string
 	if response, exists := m.responses[command]; exists {
 		return response, nil
 	}
+	if strings.HasPrefix(command, "kubectl get node ") && strings.Contains(command, " -o json") {
+		return readyNodeJSON, nil
+	}
 	return "success", nil
 }
 
+// ExecuteScript runs each line of the script as a command, so tests can
+// match script commands like single ones.
+func (m *MockSSHClient) ExecuteScript(ctx context.Context, host, script string) (string, error) {
+	var output strings.Builder
+	for i, line := range strings.Split(script, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		out, err := m.ExecuteCommand(ctx, host, line)
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", i+1, err)
+		}
+		output.WriteString(out)
+	}
+	return output.String(), nil
+}
+
+// readyNodeJSON is the default response to node status queries
+const readyNodeJSON = `{"status":{"conditions":[{"type":"Ready","status":"True","reason":"KubeletReady"}]}}`
+
 func (m *MockSSHClient) CopyFile(ctx context.Context, host, localPath, remotePath string) error {
 	content, err := os.ReadFile(localPath)
 	if err != nil {
 		return err
 	}
-	m.filesUploaded[remotePath] = string(content)
+	return m.CopyContent(ctx, host, string(content), remotePath)
+}
+
+func (m *MockSSHClient) CopyFileOwned(ctx context.Context, host, localPath, remotePath, owner string) error {
+	if err := m.CopyFile(ctx, host, localPath, remotePath); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fileOwners[remotePath] = owner
 	return nil
 }
 
 func (m *MockSSHClient) CopyContent(ctx context.Context, host, content, remotePath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.filesUploaded[remotePath] = content
 	return nil
 }
 
 func (m *MockSSHClient) GetExecutedCommands() []string {
-	return m.commands
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.commands...)
 }
 
 func (m *MockSSHClient) SetCommandResponse(command, response string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.responses[command] = response
 }
 
 func (m *MockSSHClient) SetCommandError(command string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.errors[command] = err
 }
 
 type MockLogger struct {
+	mu   sync.Mutex
 	logs []string
 }
 
@@ -74,13 +140,21 @@ func NewMockLogger() *MockLogger {
 	return &MockLogger{logs: []string{}}
 }
 
-func (l *MockLogger) Info(msg string, args ...interface{})  { l.logs = append(l.logs, fmt.Sprintf("INFO: "+msg, args...)) }
-func (l *MockLogger) Error(msg string, args ...interface{}) { l.logs = append(l.logs, fmt.Sprintf("ERROR: "+msg, args...)) }
-func (l *MockLogger) Debug(msg string, args ...interface{}) { l.logs = append(l.logs, fmt.Sprintf("DEBUG: "+msg, args...)) }
-func (l *MockLogger) Warn(msg string, args ...interface{})  { l.logs = append(l.logs, fmt.Sprintf("WARN: "+msg, args...)) }
+func (l *MockLogger) Info(msg string, args ...interface{})  { l.record(fmt.Sprintf("INFO: "+msg, args...)) }
+func (l *MockLogger) Error(msg string, args ...interface{}) { l.record(fmt.Sprintf("ERROR: "+msg, args...)) }
+func (l *MockLogger) Debug(msg string, args ...interface{}) { l.record(fmt.Sprintf("DEBUG: "+msg, args...)) }
+func (l *MockLogger) Warn(msg string, args ...interface{})  { l.record(fmt.Sprintf("WARN: "+msg, args...)) }
+
+func (l *MockLogger) record(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, line)
+}
 
 func (l *MockLogger) GetLogs() []string {
-	return l.logs
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.logs...)
 }
 
 type MockProgressReporter struct {
@@ -549,7 +623,7 @@ func TestConfigurationFileGeneration(t *testing.T) {
 		}
 
 		// Test API server service generation
-		apiService := cm.generateAPIServerService()
+		apiService := cm.generateAPIServerService(config.Controller)
 		if apiService == "" {
 			t.Error("API server service generation returned empty string")
 		}
@@ -714,6 +788,7 @@ func TestClusterSetupFlow(t *testing.T) {
 		requiredCerts := []string{
 			"ca.pem", "ca-key.pem",
 			"admin.pem", "admin-key.pem",
+			"front-proxy-client.pem", "front-proxy-client-key.pem",
 			"kube-controller-manager.pem", "kube-controller-manager-key.pem",
 			"kube-proxy.pem", "kube-proxy-key.pem",
 			"kube-scheduler.pem", "kube-scheduler-key.pem",
@@ -1350,119 +1425,3740 @@ func TestServiceHealthCheck(t *testing.T) {
 
 	t.Run("Service Health Check Timeout", func(t *testing.T) {
 		sshClient.SetCommandResponse("sudo systemctl is-active failing-service", "failed")
+		sshClient.SetCommandResponse("sudo journalctl -u failing-service -n 100 --no-pager",
+			"failing-service[42]: open /etc/failing-service.conf: no such file or directory\n")
 
 		ctx := context.Background()
 		err := cm.waitForService(ctx, "10.0.0.1", "failing-service", 1*time.Second)
 		if err == nil {
-			t.Error("Expected timeout error")
+			t.Fatal("Expected timeout error")
 		}
 		if !strings.Contains(err.Error(), "did not become healthy") {
 			t.Errorf("Expected timeout error, got: %v", err)
 		}
+		if !strings.Contains(err.Error(), "open /etc/failing-service.conf: no such file or directory") {
+			t.Errorf("Expected the journal excerpt in the error, got: %v", err)
+		}
+		found := false
+		for _, log := range logger.GetLogs() {
+			found = found || strings.Contains(log, "failing-service[42]")
+		}
+		if !found {
+			t.Error("Expected the journal excerpt to be logged")
+		}
 	})
 }
 
-// Comprehensive end-to-end test
-func TestEndToEndClusterSetup(t *testing.T) {
-	// This test simulates a complete cluster setup from configuration to validation
+func TestServicePolling(t *testing.T) {
+	previous := time.Duration(0)
+	for attempt := 0; attempt < 20; attempt++ {
+		delay := servicePollDelay(attempt)
+		if delay <= 0 || delay > serviceMaxPollDelay {
+			t.Errorf("Attempt %d has delay %v outside (0, %v]", attempt, delay, serviceMaxPollDelay)
+		}
+		if attempt < 4 && delay < previous {
+			t.Errorf("Attempt %d backs off less than the one before: %v < %v", attempt, delay, previous)
+		}
+		previous = delay
+	}
+	if delay := servicePollDelay(0); delay > serviceInitialPollDelay || delay < serviceInitialPollDelay*4/5 {
+		t.Errorf("First delay %v isn't within 20%% below %v", delay, serviceInitialPollDelay)
+	}
+
 	config := createTestConfig()
-	
-	// Create temporary work directory
-	workDir, err := os.MkdirTemp("", "e2e-test-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp work dir: %v", err)
+	config.Systemd.Services = map[string]UnitConfig{"kube-scheduler": {ActiveTimeoutSec: 1}}
+	sshClient := NewMockSSHClient()
+	sshClient.SetCommandResponse("sudo systemctl is-active kube-scheduler", "activating")
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	start := time.Now()
+	if err := cm.waitForService(context.Background(), "10.240.0.10", "kube-scheduler", time.Hour); err == nil {
+		t.Error("Expected the configured timeout to expire")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the configured 1s timeout to replace the default, waited %v", elapsed)
 	}
-	defer os.RemoveAll(workDir)
-	config.WorkDir = workDir
 
-	// Save configuration
-	configPath := filepath.Join(workDir, "cluster-config.yaml")
-	if err := SaveConfig(config, configPath); err != nil {
-		t.Fatalf("Failed to save config: %v", err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cm.waitForService(ctx, "10.240.0.10", "kube-apiserver", time.Hour); err != context.Canceled {
+		t.Errorf("Expected a cancelled wait to stop with the context error, got %v", err)
 	}
+}
 
-	// Load configuration back
-	loadedConfig, err := LoadClusterConfig(configPath)
-	if err != nil {
-		t.Fatalf("Failed to load config: %v", err)
+func TestBakeNode(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	ctx := context.Background()
+
+	node, role, err := ResolveBakeTarget(config, "worker-1")
+	if err != nil || role != RoleWorker || node.IPAddress != "10.240.0.21" {
+		t.Fatalf("Unexpected target for worker-1: %+v %s %v", node, role, err)
+	}
+	if _, _, err := ResolveBakeTarget(config, "gpu=10.0.0.5"); err == nil {
+		t.Error("Expected an unknown role to be rejected")
+	}
+	if _, _, err := ResolveBakeTarget(config, "worker-9"); err == nil {
+		t.Error("Expected an unknown node to be rejected")
 	}
 
-	// Setup mocks with comprehensive responses
-	logger := NewMockLogger()
-	progress := NewMockProgressReporter()
 	sshClient := NewMockSSHClient()
-	certManager := NewCertificateManager()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	node, role, _ = ResolveBakeTarget(config, "worker=10.0.0.5")
+	if err := cm.BakeNode(ctx, node, role); err != nil {
+		t.Fatalf("Bake failed: %v", err)
+	}
+	commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+	for _, want := range []string{"kubelet", "containerd", "cni-plugins", cm.bakeMarker(RoleWorker)} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Bake didn't run a command containing %q", want)
+		}
+	}
+	if strings.Contains(commands, "systemctl start") || len(sshClient.filesUploaded) != 0 {
+		t.Error("Bake should only download and install binaries")
+	}
 
-	// Setup SSH responses for all expected commands
-	sshClient.SetCommandResponse("echo 'SSH test'", "SSH test")
+	// A node built from the image skips the installs during setup
+	sshClient = NewMockSSHClient()
+	sshClient.SetCommandResponse("cat "+bakeMarkerPath+" 2>/dev/null || true", cm.bakeMarker(RoleWorker)+"\n")
+	cm = NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Certificate generation failed: %v", err)
+	}
+	if err := cm.createConfigurations(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Configuration creation failed: %v", err)
+	}
+	if err := cm.setupSingleWorkerNode(ctx, config.WorkDir, config.Workers[0]); err != nil {
+		t.Fatalf("Worker setup failed: %v", err)
+	}
+	commands = strings.Join(sshClient.GetExecutedCommands(), "\n")
+	if strings.Contains(commands, "wget") || strings.Contains(commands, "apt-get") {
+		t.Error("Setup reinstalled binaries on a baked node")
+	}
+	if !strings.Contains(commands, "systemctl start containerd kubelet kube-proxy") {
+		t.Error("Setup didn't start the services on a baked node")
+	}
+
+	// An image baked for other versions is ignored
+	config.KubernetesVersion = "v1.27.0"
+	cm = NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	if cm.isBaked(ctx, config.Workers[0], RoleWorker) {
+		t.Error("Expected a marker for other versions to be ignored")
+	}
+}
+
+func TestWaitForNodeReady(t *testing.T) {
+	config := createTestConfig()
+	worker := config.Workers[0]
+	ctx := context.Background()
+	query := "kubectl get node worker-0 -o json --kubeconfig /var/lib/kubernetes/admin.kubeconfig"
+
+	sshClient := NewMockSSHClient()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	if err := cm.waitForNodeReady(ctx, worker, time.Second); err != nil {
+		t.Fatalf("Expected a Ready node to pass: %v", err)
+	}
+
+	sshClient.SetCommandResponse(query, `{"status":{"conditions":[{"type":"Ready","status":"False","reason":"KubeletNotReady","message":"container runtime network not ready"}]}}`)
+	sshClient.SetCommandResponse("sudo journalctl -u kubelet -u containerd -n 50 --no-pager", "kubelet: cni config uninitialized")
+	logger := NewMockLogger()
+	cm = NewClusterManager(config, logger, sshClient, NewCertificateManager(), NewMockProgressReporter())
+	err := cm.waitForNodeReady(ctx, worker, 0)
+	if err == nil || !strings.Contains(err.Error(), "KubeletNotReady") {
+		t.Fatalf("Expected the NotReady condition in the error, got %v", err)
+	}
+	if !strings.Contains(strings.Join(logger.GetLogs(), "\n"), "cni config uninitialized") {
+		t.Error("Expected the worker journal to be logged when the node isn't Ready")
+	}
+
+	sshClient.SetCommandError(query, fmt.Errorf("NotFound"))
+	err = cm.waitForNodeReady(ctx, worker, 0)
+	if err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Errorf("Expected an unregistered node to fail, got %v", err)
+	}
+}
+
+func TestRemoteAdminKubeconfig(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	config.Controller.PublicAddress = "k8s.example.com"
+	ctx := context.Background()
+
+	cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Certificate generation failed: %v", err)
+	}
+
+	certData, err := os.ReadFile(filepath.Join(config.WorkDir, "kubernetes.pem"))
+	if err != nil {
+		t.Fatalf("Failed to read API server certificate: %v", err)
+	}
+	block, _ := pem.Decode(certData)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse API server certificate: %v", err)
+	}
+	if err := cert.VerifyHostname("k8s.example.com"); err != nil {
+		t.Errorf("API server certificate doesn't cover the public address: %v", err)
+	}
+
+	if err := cm.writeRemoteAdminKubeconfig(config.WorkDir); err != nil {
+		t.Fatalf("Failed to write remote admin kubeconfig: %v", err)
+	}
+	path := RemoteAdminKubeconfigPath(config)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read remote admin kubeconfig: %v", err)
+	}
+	kubeconfig := string(data)
+	caData, _ := os.ReadFile(filepath.Join(config.WorkDir, "ca.pem"))
+	for _, want := range []string{
+		"server: https://k8s.example.com:6443",
+		"certificate-authority-data: " + base64.StdEncoding.EncodeToString(caData),
+		"client-certificate-data: ",
+		"client-key-data: ",
+	} {
+		if !strings.Contains(kubeconfig, want) {
+			t.Errorf("Remote admin kubeconfig is missing %q", want)
+		}
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected remote admin kubeconfig mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// Without a public address the controller's IP is used
+	cm.config.Controller.PublicAddress = ""
+	if err := cm.writeRemoteAdminKubeconfig(config.WorkDir); err != nil {
+		t.Fatalf("Failed to write remote admin kubeconfig: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.Contains(string(data), "server: https://10.240.0.10:6443") {
+		t.Error("Expected the remote admin kubeconfig to fall back to the controller IP")
+	}
+}
+
+func TestExternalEtcd(t *testing.T) {
+	config := createTestConfig()
+	config.Etcd = EtcdConfig{
+		Members: []Node{
+			{Name: "etcd-0", IPAddress: "10.240.0.30", Hostname: "etcd-0"},
+			{Name: "etcd-1", IPAddress: "10.240.0.31", Hostname: "etcd-1"},
+			{Name: "etcd-2", IPAddress: "10.240.0.32", Hostname: "etcd-2"},
+		},
+		DataDir: "/mnt/etcd-data",
+		WALDir:  "/mnt/etcd-wal",
+	}
+	if err := validateEtcd(config); err != nil {
+		t.Fatalf("Valid etcd config rejected: %v", err)
+	}
+	config.WorkDir = t.TempDir()
+	sshClient := NewMockSSHClient()
 	sshClient.SetCommandResponse("sudo systemctl is-active etcd", "active")
 	sshClient.SetCommandResponse("sudo systemctl is-active kube-apiserver", "active")
 	sshClient.SetCommandResponse("sudo systemctl is-active kube-controller-manager", "active")
 	sshClient.SetCommandResponse("sudo systemctl is-active kube-scheduler", "active")
-	sshClient.SetCommandResponse("kubectl get nodes --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
-		"NAME         STATUS   ROLES    AGE   VERSION\nworker-0     Ready    <none>   1m    v1.26.0\nworker-1     Ready    <none>   1m    v1.26.0")
-	sshClient.SetCommandResponse("kubectl get pods -n kube-system --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
-		"NAME                      READY   STATUS    RESTARTS   AGE\ncoredns-xxx               2/2     Running   0          1m")
-	sshClient.SetCommandResponse("kubectl get deployment test-deployment --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
-		"NAME              READY   UP-TO-DATE   AVAILABLE   AGE\ntest-deployment   2/2     2            2           1m")
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	ctx := context.Background()
 
-	cm := NewClusterManager(loadedConfig, logger, sshClient, certManager, progress)
+	apiServer := cm.generateAPIServerService(config.Controller)
+	if !strings.Contains(apiServer, "--etcd-servers=https://10.240.0.30:2379,https://10.240.0.31:2379,https://10.240.0.32:2379 ") {
+		t.Errorf("API server doesn't list every etcd member:\n%s", apiServer)
+	}
+	etcdService := cm.generateEtcdService(config.Etcd.Members[1])
+	for _, want := range []string{
+		"--name etcd-1",
+		"--listen-peer-urls https://10.240.0.31:2380",
+		"--initial-cluster etcd-0=https://10.240.0.30:2380,etcd-1=https://10.240.0.31:2380,etcd-2=https://10.240.0.32:2380",
+		"--data-dir=/mnt/etcd-data",
+		"--wal-dir=/mnt/etcd-wal",
+	} {
+		if !strings.Contains(etcdService, want) {
+			t.Errorf("etcd service doesn't contain %q", want)
+		}
+	}
 
-	t.Run("Complete Cluster Lifecycle", func(t *testing.T) {
-		ctx := context.Background()
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Certificate generation failed: %v", err)
+	}
+	if err := cm.createConfigurations(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Configuration creation failed: %v", err)
+	}
+	if err := cm.setupControlPlane(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Control plane setup failed: %v", err)
+	}
+	for _, cmd := range sshClient.GetExecutedCommands() {
+		if strings.HasPrefix(cmd, config.Controller.IPAddress+": ") && strings.Contains(cmd, "etcd-") {
+			t.Errorf("etcd was installed on the controller: %s", cmd)
+		}
+	}
+	for _, member := range config.Etcd.Members {
+		if !strings.Contains(strings.Join(sshClient.GetExecutedCommands(), "\n"), member.IPAddress+": sudo mkdir -p /etc/etcd /mnt/etcd-data /mnt/etcd-wal") {
+			t.Errorf("etcd was not installed on %s", member.Name)
+		}
+	}
+	if nodes := config.Nodes(); len(nodes) != 1+len(config.Etcd.Members)+len(config.Workers) {
+		t.Errorf("Expected etcd members among the cluster nodes, got %d nodes", len(nodes))
+	}
 
-		// Setup cluster
-		if err := cm.SetupCluster(ctx); err != nil {
-			t.Fatalf("Cluster setup failed: %v", err)
+	config.Etcd.Members = append(config.Etcd.Members, Node{Name: "worker-0", IPAddress: "10.240.0.40"})
+	if err := validateEtcd(config); err == nil {
+		t.Error("Expected an etcd member reusing a worker name to be rejected")
+	}
+	if err := validateEtcd(ClusterConfig{Etcd: EtcdConfig{DataDir: "relative"}}); err == nil {
+		t.Error("Expected a relative data_dir to be rejected")
+	}
+}
+
+func TestHAControlPlane(t *testing.T) {
+	config := createTestConfig()
+	config.Controller = Node{}
+	config.Controllers = []Node{
+		{Name: "controller-0", IPAddress: "10.240.0.10", Hostname: "controller-0"},
+		{Name: "controller-1", IPAddress: "10.240.0.11", Hostname: "controller-1"},
+		{Name: "controller-2", IPAddress: "10.240.0.12", Hostname: "controller-2"},
+	}
+	config.ControlPlaneEndpoint = "k8s-api.internal"
+	resolveControlPlane(&config)
+	if config.Controller.Name != "controller-0" {
+		t.Fatalf("Expected the first controller to be the primary, got %q", config.Controller.Name)
+	}
+	if err := validateControlPlane(config); err != nil {
+		t.Fatalf("Valid control plane rejected: %v", err)
+	}
+	config.WorkDir = t.TempDir()
+
+	sshClient := NewMockSSHClient()
+	for _, service := range []string{"etcd", "kube-apiserver", "kube-controller-manager", "kube-scheduler"} {
+		sshClient.SetCommandResponse("sudo systemctl is-active "+service, "active")
+	}
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	ctx := context.Background()
+
+	apiServer := cm.generateAPIServerService(config.Controllers[1])
+	for _, want := range []string{
+		"--advertise-address=10.240.0.11 ",
+		"--apiserver-count=3 ",
+		"--etcd-servers=https://10.240.0.10:2379,https://10.240.0.11:2379,https://10.240.0.12:2379 ",
+	} {
+		if !strings.Contains(apiServer, want) {
+			t.Errorf("API server doesn't contain %q", want)
 		}
+	}
+	if etcd := cm.generateEtcdService(config.Controllers[2]); !strings.Contains(etcd,
+		"--initial-cluster controller-0=https://10.240.0.10:2380,controller-1=https://10.240.0.11:2380,controller-2=https://10.240.0.12:2380") {
+		t.Errorf("etcd isn't stacked on every controller:\n%s", etcd)
+	}
 
-		// Verify cluster status
-		status, err := cm.GetClusterStatus(ctx)
-		if err != nil {
-			t.Fatalf("Failed to get cluster status: %v", err)
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Certificate generation failed: %v", err)
+	}
+	certData, _ := os.ReadFile(filepath.Join(config.WorkDir, "kubernetes.pem"))
+	block, _ := pem.Decode(certData)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse API server certificate: %v", err)
+	}
+	for _, host := range []string{"10.240.0.12", "controller-1", "k8s-api.internal"} {
+		if err := cert.VerifyHostname(host); err != nil {
+			t.Errorf("API server certificate doesn't cover %s: %v", host, err)
 		}
+	}
 
-		if !strings.Contains(status.Nodes, "Ready") {
-			t.Error("Cluster nodes are not ready")
+	if err := cm.createConfigurations(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Configuration creation failed: %v", err)
+	}
+	for file, server := range map[string]string{
+		"worker-0.kubeconfig":                "https://k8s-api.internal:6443",
+		"kube-proxy.kubeconfig":              "https://k8s-api.internal:6443",
+		"kube-scheduler.kubeconfig":          "https://127.0.0.1:6443",
+		"kube-controller-manager.kubeconfig": "https://127.0.0.1:6443",
+	} {
+		data, _ := os.ReadFile(filepath.Join(config.WorkDir, file))
+		if !strings.Contains(string(data), "server: "+server) {
+			t.Errorf("%s doesn't point at %s", file, server)
 		}
+	}
 
-		// Destroy cluster
-		if err := cm.DestroyCluster(ctx); err != nil {
-			t.Fatalf("Cluster destruction failed: %v", err)
+	if err := cm.setupControlPlane(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Control plane setup failed: %v", err)
+	}
+	commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+	for _, controller := range config.Controllers {
+		if !strings.Contains(commands, controller.IPAddress+": sudo systemctl daemon-reload && sudo systemctl enable kube-apiserver") {
+			t.Errorf("kube-apiserver wasn't started on %s", controller.Name)
 		}
+	}
+	if nodes := config.Nodes(); len(nodes) != len(config.Controllers)+len(config.Workers) {
+		t.Errorf("Expected every controller among the cluster nodes, got %d nodes", len(nodes))
+	}
 
-		// Verify logs contain all phases
-		logs := logger.GetLogs()
-		logStr := strings.Join(logs, " ")
-		
-		expectedLogMessages := []string{
-			"Generating certificates",
-			"Creating configurations", 
-			"Setting up control plane",
-			"Setting up worker nodes",
-			"Setting up networking",
-			"Validating cluster",
-			"Cluster destroyed successfully",
+	config.Controller = config.Controllers[1]
+	if err := validateControlPlane(config); err == nil {
+		t.Error("Expected a primary controller other than the first to be rejected")
+	}
+}
+
+func TestScaleWorkers(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	ctx := context.Background()
+	sshClient := NewMockSSHClient()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	node := Node{Name: "worker-2", IPAddress: "10.240.0.22", PodCIDR: "10.200.2.0/24"}
+	if err := cm.AddWorkerNode(ctx, node); err == nil {
+		t.Fatal("Expected adding a worker without the cluster CA to fail")
+	}
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Certificate generation failed: %v", err)
+	}
+	if err := cm.createConfigurations(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Configuration creation failed: %v", err)
+	}
+	if err := cm.AddWorkerNode(ctx, Node{Name: "worker-3", IPAddress: "10.240.0.23", PodCIDR: "10.200.0.0/24"}); err == nil {
+		t.Error("Expected a worker reusing a pod CIDR to be rejected")
+	}
+
+	if err := cm.AddWorkerNode(ctx, node); err != nil {
+		t.Fatalf("Adding worker failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.WorkDir, "worker-2.kubeconfig")); err != nil {
+		t.Errorf("No kubeconfig was generated for the new worker: %v", err)
+	}
+	commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+	for _, want := range []string{
+		"10.240.0.22: sudo systemctl start containerd kubelet kube-proxy",
+		"10.240.0.22: sudo ip route add 10.200.1.0/24 via 10.240.0.21 || true",
+		"10.240.0.20: sudo ip route add 10.200.2.0/24 via 10.240.0.22 || true",
+		"10.240.0.21: sudo ip route add 10.200.2.0/24 via 10.240.0.22 || true",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Adding a worker didn't run %q", want)
 		}
+	}
+	if len(cm.config.Workers) != 3 {
+		t.Fatalf("Expected 3 workers after adding one, got %d", len(cm.config.Workers))
+	}
 
-		for _, msg := range expectedLogMessages {
-			if !strings.Contains(logStr, msg) {
-				t.Errorf("Expected log message '%s' not found", msg)
-			}
+	sshClient = NewMockSSHClient()
+	cm.sshClient = sshClient
+	if err := cm.RemoveWorkerNode(ctx, "worker-9"); err == nil {
+		t.Error("Expected removing an unknown worker to fail")
+	}
+	if err := cm.RemoveWorkerNode(ctx, "worker-0"); err != nil {
+		t.Fatalf("Removing worker failed: %v", err)
+	}
+	commands = strings.Join(sshClient.GetExecutedCommands(), "\n")
+	for _, want := range []string{
+		"10.240.0.10: kubectl drain worker-0 --ignore-daemonsets",
+		"10.240.0.10: kubectl delete node worker-0",
+		"10.240.0.21: sudo ip route del 10.200.0.0/24 via 10.240.0.20 || true",
+		"10.240.0.22: sudo ip route del 10.200.0.0/24 via 10.240.0.20 || true",
+		"10.240.0.20: sudo systemctl stop",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Removing a worker didn't run %q", want)
 		}
+	}
+	if len(cm.config.Workers) != 2 || cm.config.Workers[0].Name != "worker-1" {
+		t.Errorf("Unexpected workers after removal: %+v", cm.config.Workers)
+	}
+	if _, err := os.Stat(filepath.Join(config.WorkDir, "worker-0.kubeconfig")); !os.IsNotExist(err) {
+		t.Error("The removed worker's kubeconfig was kept")
+	}
+}
 
-		// Verify progress reporting
-		steps := progress.steps
-		if len(steps) < 6 { // At least 6 main phases
-			t.Errorf("Expected at least 6 progress steps, got %d", len(steps))
+func TestResourceDefaults(t *testing.T) {
+	config := createTestConfig()
+	ctx := context.Background()
+	sshClient := NewMockSSHClient()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	if err := cm.applyResourceDefaults(ctx); err != nil || len(sshClient.GetExecutedCommands()) != 0 {
+		t.Fatalf("Expected nothing to be applied without resource defaults: %v", err)
+	}
+
+	config.ResourceDefaults = ResourceDefaultsConfig{
+		Namespaces: []string{"default", "team-a"},
+		LimitRange: &LimitRangeConfig{
+			Default:        ResourceList{CPU: "500m", Memory: "512Mi"},
+			DefaultRequest: ResourceList{CPU: "100m", Memory: "128Mi"},
+		},
+		ResourceQuota: map[string]string{"requests.cpu": "4", "pods": "20"},
+	}
+	if err := validateResourceDefaults(config.ResourceDefaults); err != nil {
+		t.Fatalf("Valid resource defaults rejected: %v", err)
+	}
+	cm = NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	if err := cm.applyResourceDefaults(ctx); err != nil {
+		t.Fatalf("Applying resource defaults failed: %v", err)
+	}
+	manifest := sshClient.filesUploaded["/tmp/resource-defaults.yaml"]
+	for _, want := range []string{
+		"kind: Namespace\nmetadata:\n  name: team-a\n",
+		"  name: default-limits\n  namespace: team-a\n",
+		"    default:\n      cpu: 500m\n      memory: 512Mi\n    defaultRequest:\n      cpu: 100m\n      memory: 128Mi\n",
+		"  hard:\n    pods: \"20\"\n    requests.cpu: \"4\"\n",
+	} {
+		if !strings.Contains(manifest, want) {
+			t.Errorf("Resource defaults manifest doesn't contain %q:\n%s", want, manifest)
 		}
+	}
+	if strings.Count(manifest, "kind: ResourceQuota") != 2 {
+		t.Error("Expected a ResourceQuota in each namespace")
+	}
+	if !strings.Contains(strings.Join(sshClient.GetExecutedCommands(), "\n"), "kubectl apply -f /tmp/resource-defaults.yaml") {
+		t.Error("Resource defaults weren't applied")
+	}
 
-		// Verify comprehensive command execution
-		commands := sshClient.GetExecutedCommands()
-		if len(commands) < 50 { // Expect many commands for full setup
-			t.Errorf("Expected many commands to be executed, got %d", len(commands))
+	for _, invalid := range []ResourceDefaultsConfig{
+		{Namespaces: []string{"Team_A"}},
+		{LimitRange: &LimitRangeConfig{Max: ResourceList{CPU: "lots"}}},
+		{ResourceQuota: map[string]string{"pods": "twenty"}},
+	} {
+		if err := validateResourceDefaults(invalid); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
 		}
+	}
+}
 
-		t.Logf("End-to-end test completed successfully with %d commands executed", len(commands))
-	})
-}
\ No newline at end of file
+func TestPriorityClasses(t *testing.T) {
+	config := createTestConfig()
+	config.CoreDNS.Autoscaler = &DNSAutoscalerConfig{}
+	ctx := context.Background()
+	sshClient := NewMockSSHClient()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	if n := strings.Count(cm.generateCoreDNSManifest(), "priorityClassName: system-cluster-critical"); n != 2 {
+		t.Errorf("Expected CoreDNS and its autoscaler to be cluster critical, found %d", n)
+	}
+	if err := cm.applyPriorityClasses(ctx); err != nil || len(sshClient.GetExecutedCommands()) != 0 {
+		t.Fatalf("Expected nothing to be applied without priority classes: %v", err)
+	}
+
+	config.PriorityClasses = []PriorityClassConfig{
+		{Name: "platform-critical", Value: 1000000, Description: "Shared platform services"},
+		{Name: "batch", Value: -10, GlobalDefault: true, PreemptionPolicy: "Never"},
+	}
+	config.CoreDNS.PriorityClassName = "platform-critical"
+	if err := validatePriorityClasses(config); err != nil {
+		t.Fatalf("Valid priority classes rejected: %v", err)
+	}
+	cm = NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	if err := cm.applyPriorityClasses(ctx); err != nil {
+		t.Fatalf("Applying priority classes failed: %v", err)
+	}
+	manifest := sshClient.filesUploaded["/tmp/priority-classes.yaml"]
+	for _, want := range []string{
+		"  name: platform-critical\nvalue: 1000000\nglobalDefault: false\npreemptionPolicy: PreemptLowerPriority\ndescription: \"Shared platform services\"\n",
+		"  name: batch\nvalue: -10\nglobalDefault: true\npreemptionPolicy: Never\n",
+	} {
+		if !strings.Contains(manifest, want) {
+			t.Errorf("Priority classes manifest doesn't contain %q:\n%s", want, manifest)
+		}
+	}
+	if !strings.Contains(strings.Join(sshClient.GetExecutedCommands(), "\n"), "kubectl apply -f /tmp/priority-classes.yaml") {
+		t.Error("Priority classes weren't applied")
+	}
+	if n := strings.Count(cm.generateCoreDNSManifest(), "priorityClassName: platform-critical"); n != 2 {
+		t.Errorf("Expected the configured priority class on CoreDNS and its autoscaler, found %d", n)
+	}
+
+	for _, invalid := range [][]PriorityClassConfig{
+		{{Name: "Batch", Value: 1}},
+		{{Name: "system-custom", Value: 1}},
+		{{Name: "huge", Value: 2000000000}},
+		{{Name: "batch", PreemptionPolicy: "Sometimes"}},
+		{{Name: "a", GlobalDefault: true}, {Name: "b", GlobalDefault: true}},
+		{{Name: "batch"}, {Name: "batch"}},
+	} {
+		if err := validatePriorityClasses(ClusterConfig{PriorityClasses: invalid}); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+	if err := validatePriorityClasses(ClusterConfig{CoreDNS: CoreDNSConfig{PriorityClassName: "missing"}}); err == nil {
+		t.Error("Expected an undefined CoreDNS priority class to be rejected")
+	}
+}
+
+func TestUpgradeCluster(t *testing.T) {
+	for _, c := range []struct {
+		target string
+		valid  bool
+	}{
+		{"v1.26.5", true},
+		{"v1.27.0", true},
+		{"v1.27.0-rc.1", true},
+		{"v1.26.0", false},
+		{"v1.25.9", false},
+		{"v1.28.0", false},
+		{"v2.0.0", false},
+		{"1.27.0", false},
+	} {
+		if err := checkUpgradeVersion("v1.26.0", c.target); (err == nil) != c.valid {
+			t.Errorf("Upgrading v1.26.0 to %s: expected valid=%v, got %v", c.target, c.valid, err)
+		}
+	}
+
+	config := createTestConfig()
+	ctx := context.Background()
+	sshClient := NewMockSSHClient()
+	for _, service := range controllerServices {
+		sshClient.SetCommandResponse("sudo systemctl is-active "+service, "active\n")
+	}
+	sshClient.SetCommandResponse("kubectl get --raw=/readyz --server=https://10.240.0.10:6443 --kubeconfig /var/lib/kubernetes/admin.kubeconfig", "ok\n")
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	if err := cm.UpgradeCluster(ctx, "v1.27.2"); err != nil {
+		t.Fatalf("Upgrade failed: %v", err)
+	}
+	if cm.config.KubernetesVersion != "v1.27.2" {
+		t.Errorf("Expected the config to record v1.27.2, got %s", cm.config.KubernetesVersion)
+	}
+	order := []string{
+		"10.240.0.10: wget -q --show-progress --https-only --timestamping 'https://storage.googleapis.com/kubernetes-release/release/v1.27.2/bin/linux/amd64/kube-apiserver'",
+		"10.240.0.10: sudo systemctl restart kube-apiserver kube-controller-manager kube-scheduler",
+		"10.240.0.10: kubectl get --raw=/readyz",
+		"10.240.0.10: kubectl drain worker-0 ",
+		"10.240.0.20: wget -q --show-progress --https-only --timestamping 'https://storage.googleapis.com/kubernetes-release/release/v1.27.2/bin/linux/amd64/kubectl'",
+		"10.240.0.20: sudo systemctl restart kubelet kube-proxy",
+		"10.240.0.10: kubectl get node worker-0 -o json",
+		"10.240.0.10: kubectl uncordon worker-0 ",
+		"10.240.0.10: kubectl drain worker-1 ",
+		"10.240.0.21: sudo systemctl restart kubelet kube-proxy",
+		"10.240.0.10: kubectl uncordon worker-1 ",
+	}
+	executed := sshClient.GetExecutedCommands()
+	next := 0
+	for _, command := range executed {
+		if next < len(order) && strings.HasPrefix(command, order[next]) {
+			next++
+		}
+	}
+	if next != len(order) {
+		t.Errorf("Expected %q after the earlier upgrade steps, got %v", order[next], executed)
+	}
+
+	sshClient.SetCommandError("kubectl uncordon worker-1 --kubeconfig /var/lib/kubernetes/admin.kubeconfig", fmt.Errorf("connection refused"))
+	if err := cm.UpgradeCluster(ctx, "v1.27.3"); err == nil || !strings.Contains(err.Error(), "worker-1") {
+		t.Errorf("Expected the upgrade to fail on worker-1, got %v", err)
+	}
+	if cm.config.KubernetesVersion != "v1.27.2" {
+		t.Errorf("Expected a failed upgrade to keep v1.27.2, got %s", cm.config.KubernetesVersion)
+	}
+}
+
+func TestUpgradeClusterCanary(t *testing.T) {
+	ctx := context.Background()
+	newManager := func() (*ClusterManager, *MockSSHClient) {
+		sshClient := NewMockSSHClient()
+		for _, service := range controllerServices {
+			sshClient.SetCommandResponse("sudo systemctl is-active "+service, "active\n")
+		}
+		sshClient.SetCommandResponse("kubectl get --raw=/readyz --server=https://10.240.0.10:6443 --kubeconfig /var/lib/kubernetes/admin.kubeconfig", "ok\n")
+		sshClient.SetCommandResponse("kubectl get node worker-0 -o json --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+			`{"status":{"conditions":[{"type":"Ready","status":"True"}],"nodeInfo":{"kubeletVersion":"v1.27.2"}}}`)
+		return NewClusterManager(createTestConfig(), NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter()), sshClient
+	}
+	drained := func(sshClient *MockSSHClient, worker string) bool {
+		for _, command := range sshClient.GetExecutedCommands() {
+			if strings.HasPrefix(command, "10.240.0.10: kubectl drain "+worker+" ") {
+				return true
+			}
+		}
+		return false
+	}
+
+	cm, sshClient := newManager()
+	var asked []string
+	err := cm.UpgradeClusterCanary(ctx, "v1.27.2", func(ctx context.Context, canary Node) (bool, error) {
+		asked = append(asked, canary.Name)
+		return false, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "stopped after canary worker-0") {
+		t.Errorf("Expected a declined canary to stop the upgrade, got %v", err)
+	}
+	if len(asked) != 1 || asked[0] != "worker-0" {
+		t.Errorf("Expected confirmation to be asked for worker-0, got %v", asked)
+	}
+	if !drained(sshClient, "worker-0") || drained(sshClient, "worker-1") {
+		t.Errorf("Expected only the canary to be upgraded, got %v", sshClient.GetExecutedCommands())
+	}
+	if cm.config.KubernetesVersion != "v1.26.0" {
+		t.Errorf("Expected a stopped upgrade to keep v1.26.0, got %s", cm.config.KubernetesVersion)
+	}
+	manifest := sshClient.filesUploaded["/tmp/upgrade-canary.yaml"]
+	if !strings.Contains(manifest, "key: metadata.name") || !strings.Contains(manifest, "- worker-0") {
+		t.Errorf("Expected the canary pod to be pinned to worker-0, got:\n%s", manifest)
+	}
+	order := []string{
+		"10.240.0.10: kubectl apply -f /tmp/upgrade-canary.yaml",
+		"10.240.0.10: kubectl wait --for=condition=Ready pod/upgrade-canary",
+		"10.240.0.10: kubectl logs upgrade-canary",
+		"10.240.0.10: kubectl delete pod upgrade-canary --ignore-not-found",
+	}
+	executed := sshClient.GetExecutedCommands()
+	next := 0
+	for _, command := range executed {
+		if next < len(order) && strings.HasPrefix(command, order[next]) {
+			next++
+		}
+	}
+	if next != len(order) {
+		t.Errorf("Expected %q after the earlier canary steps, got %v", order[next], executed)
+	}
+
+	cm, sshClient = newManager()
+	if err := cm.UpgradeClusterCanary(ctx, "v1.27.2", func(ctx context.Context, canary Node) (bool, error) {
+		return !drained(sshClient, "worker-1"), nil
+	}); err != nil {
+		t.Fatalf("Canary upgrade failed: %v", err)
+	}
+	if !drained(sshClient, "worker-1") || cm.config.KubernetesVersion != "v1.27.2" {
+		t.Errorf("Expected a confirmed canary to upgrade the other workers, got %v", sshClient.GetExecutedCommands())
+	}
+
+	cm, sshClient = newManager()
+	delete(sshClient.responses, "kubectl get node worker-0 -o json --kubeconfig /var/lib/kubernetes/admin.kubeconfig")
+	err = cm.UpgradeClusterCanary(ctx, "v1.27.2", func(ctx context.Context, canary Node) (bool, error) {
+		t.Error("Expected no confirmation after a failed smoke test")
+		return true, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "reports kubelet") {
+		t.Errorf("Expected the canary's kubelet version to be checked, got %v", err)
+	}
+
+	sshClient.SetCommandError("kubectl logs upgrade-canary --kubeconfig /var/lib/kubernetes/admin.kubeconfig", fmt.Errorf("connection refused"))
+	if err := cm.UpgradeClusterCanary(ctx, "v1.27.2", func(context.Context, Node) (bool, error) { return true, nil }); err == nil || !strings.Contains(err.Error(), "smoke test") {
+		t.Errorf("Expected the canary pod's logs to be checked, got %v", err)
+	}
+}
+
+func TestRotateCertificates(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	ctx := context.Background()
+	sshClient := NewMockSSHClient()
+	for _, service := range append([]string{"etcd"}, controllerServices...) {
+		sshClient.SetCommandResponse("sudo systemctl is-active "+service, "active\n")
+	}
+	sshClient.SetCommandResponse("kubectl get --raw=/readyz --server=https://10.240.0.10:6443 --kubeconfig /var/lib/kubernetes/admin.kubeconfig", "ok\n")
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	if err := cm.RotateCertificates(ctx); err == nil {
+		t.Fatal("Expected rotation without a CA to fail")
+	}
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Certificate generation failed: %v", err)
+	}
+	read := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(config.WorkDir, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		return string(content)
+	}
+	before := map[string]string{}
+	for _, name := range []string{"ca.pem", "service-account.pem", "kubernetes.pem", "worker-0.pem", "worker-0-serving.pem", "admin.pem"} {
+		before[name] = read(name)
+	}
+
+	if err := cm.RotateCertificates(ctx); err != nil {
+		t.Fatalf("Rotation failed: %v", err)
+	}
+	for _, name := range []string{"ca.pem", "service-account.pem"} {
+		if read(name) != before[name] {
+			t.Errorf("%s shouldn't change when rotating", name)
+		}
+	}
+	for _, name := range []string{"kubernetes.pem", "worker-0.pem", "worker-0-serving.pem", "admin.pem"} {
+		if read(name) == before[name] {
+			t.Errorf("%s wasn't renewed", name)
+		}
+	}
+	if sshClient.filesUploaded["/var/lib/kubelet/worker-0.pem"] != read("worker-0.pem") {
+		t.Error("The renewed worker certificate wasn't copied to the worker")
+	}
+	if sshClient.filesUploaded["/etc/etcd/kubernetes.pem"] != read("kubernetes.pem") {
+		t.Error("The renewed kubernetes certificate wasn't copied to etcd")
+	}
+	commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+	for _, want := range []string{
+		"10.240.0.10: sudo systemctl restart etcd",
+		"10.240.0.10: sudo systemctl restart kube-apiserver kube-controller-manager kube-scheduler",
+		"10.240.0.20: sudo systemctl restart kubelet kube-proxy",
+		"10.240.0.21: sudo systemctl restart kubelet kube-proxy",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Rotation didn't run %q", want)
+		}
+	}
+	if _, err := os.Stat(RemoteAdminKubeconfigPath(config)); err != nil {
+		t.Errorf("Remote admin kubeconfig wasn't rewritten: %v", err)
+	}
+}
+
+func TestAdmissionWebhooks(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	ctx := context.Background()
+	sshClient := NewMockSSHClient()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	apiserver := cm.generateAPIServerService(config.Controller)
+	for _, want := range []string{
+		"--enable-admission-plugins=NodeRestriction,MutatingAdmissionWebhook,ValidatingAdmissionWebhook ",
+		"--enable-aggregator-routing=true ",
+		"--proxy-client-cert-file=/var/lib/kubernetes/front-proxy-client.pem ",
+		"--requestheader-client-ca-file=/var/lib/kubernetes/ca.pem ",
+		"--requestheader-allowed-names=front-proxy-client ",
+	} {
+		if !strings.Contains(apiserver, want) {
+			t.Errorf("kube-apiserver unit doesn't contain %q", want)
+		}
+	}
+
+	if err := cm.setupNetworking(ctx); err != nil {
+		t.Fatalf("Networking setup failed: %v", err)
+	}
+	commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+	for _, worker := range config.Workers {
+		want := fmt.Sprintf("10.240.0.10: sudo ip route add %s via %s || true", worker.PodCIDR, worker.IPAddress)
+		if !strings.Contains(commands, want) {
+			t.Errorf("The controller has no route to the pods of %s", worker.Name)
+		}
+	}
+
+	if err := cm.certManager.GenerateCA(config.WorkDir, config.Certificates); err != nil {
+		t.Fatalf("CA generation failed: %v", err)
+	}
+	sshClient = NewMockSSHClient()
+	cm.sshClient = sshClient
+	if err := cm.testAdmissionWebhook(ctx); err == nil {
+		t.Error("Expected the smoke test to fail when the webhook doesn't reject anything")
+	}
+	sshClient = NewMockSSHClient()
+	cm.sshClient = sshClient
+	sshClient.SetCommandError("kubectl create configmap webhook-smoke-denied -n webhook-smoke-test --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		fmt.Errorf(`stderr: admission webhook "webhook-smoke.webhook-smoke-test.svc" denied the request: denied by webhook-smoke`))
+	if err := cm.testAdmissionWebhook(ctx); err != nil {
+		t.Fatalf("Webhook smoke test failed: %v", err)
+	}
+
+	ca, _ := os.ReadFile(filepath.Join(config.WorkDir, "ca.pem"))
+	webhookConfig := sshClient.filesUploaded["/tmp/webhook-smoke-config.yaml"]
+	if !strings.Contains(webhookConfig, "caBundle: "+base64.StdEncoding.EncodeToString(ca)) {
+		t.Errorf("Webhook configuration doesn't trust the cluster CA:\n%s", webhookConfig)
+	}
+	server := sshClient.filesUploaded["/tmp/webhook-smoke-server.yaml"]
+	for _, want := range []string{"type: kubernetes.io/tls", "  server.py: |\n    import json\n", "image: " + webhookImage} {
+		if !strings.Contains(server, want) {
+			t.Errorf("Webhook server manifest doesn't contain %q", want)
+		}
+	}
+	executed := sshClient.GetExecutedCommands()
+	order := []string{
+		"kubectl apply -f /tmp/webhook-smoke-server.yaml",
+		"kubectl rollout status deployment/webhook-smoke -n webhook-smoke-test",
+		"kubectl apply -f /tmp/webhook-smoke-config.yaml",
+		"kubectl create configmap webhook-smoke-allowed",
+		"kubectl create configmap webhook-smoke-denied",
+		"kubectl delete validatingwebhookconfiguration webhook-smoke",
+		"kubectl delete namespace webhook-smoke-test",
+	}
+	next := 0
+	for _, command := range executed {
+		if next < len(order) && strings.Contains(command, order[next]) {
+			next++
+		}
+	}
+	if next != len(order) {
+		t.Errorf("Expected %q after the earlier smoke test steps, got %v", order[next], executed)
+	}
+}
+
+func TestSandboxImage(t *testing.T) {
+	config := createTestConfig()
+	cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+	if strings.Contains(cm.generateContainerdConfig(), "sandbox_image") || strings.Contains(cm.generateKubeletService(config.Workers[0]), "pod-infra-container-image") {
+		t.Error("Expected containerd's default sandbox image without a configured one")
+	}
+
+	config.SandboxImage = "registry.internal:5000/pause:3.9"
+	cm = NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+	want := "  [plugins.\"io.containerd.grpc.v1.cri\"]\n    sandbox_image = \"registry.internal:5000/pause:3.9\"\n"
+	if containerd := cm.generateContainerdConfig(); !strings.Contains(containerd, want) {
+		t.Errorf("containerd config doesn't set the sandbox image:\n%s", containerd)
+	}
+	if kubelet := cm.generateKubeletService(config.Workers[0]); !strings.Contains(kubelet, "--network-plugin=cni \\\n  --pod-infra-container-image=registry.internal:5000/pause:3.9 \\\n") {
+		t.Errorf("kubelet doesn't pin the sandbox image:\n%s", kubelet)
+	}
+}
+
+func TestSystemdUnits(t *testing.T) {
+	config := createTestConfig()
+	cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+
+	apiserver := cm.generateAPIServerService(config.Controller)
+	if !strings.Contains(apiserver, "Restart=on-failure\nRestartSec=5\n") || strings.Contains(apiserver, "NoNewPrivileges") {
+		t.Errorf("Unexpected default kube-apiserver unit:\n%s", apiserver)
+	}
+	if !strings.Contains(cm.generateContainerdService(), "LimitNOFILE=1048576\nLimitNPROC=infinity") {
+		t.Error("containerd unit lost its default limits")
+	}
+
+	config.Systemd = SystemdConfig{
+		UnitConfig: UnitConfig{RestartSec: 10, StartLimitIntervalSec: 300, StartLimitBurst: 5},
+		Hardening:  true,
+		Services: map[string]UnitConfig{
+			"kube-apiserver": {Restart: "always", LimitNOFILE: "65536"},
+		},
+	}
+	config.Etcd.WALDir = "/var/lib/etcd-wal"
+	if err := validateSystemd(config.Systemd); err != nil {
+		t.Fatalf("Valid systemd config rejected: %v", err)
+	}
+	cm = NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+
+	apiserver = cm.generateAPIServerService(config.Controller)
+	for _, want := range []string{
+		"After=network.target\nStartLimitIntervalSec=300\nStartLimitBurst=5\n",
+		"Restart=always\nRestartSec=10\nLimitNOFILE=65536\n",
+		"NoNewPrivileges=yes",
+		"ProtectSystem=full",
+	} {
+		if !strings.Contains(apiserver, want) {
+			t.Errorf("kube-apiserver unit doesn't contain %q", want)
+		}
+	}
+	if etcd := cm.generateEtcdService(config.Controller); !strings.Contains(etcd, "ProtectSystem=strict") ||
+		!strings.Contains(etcd, "ReadWritePaths=/var/lib/etcd /var/lib/etcd-wal") {
+		t.Errorf("etcd unit isn't hardened for its data directories:\n%s", etcd)
+	}
+	for name, unit := range map[string]string{
+		"kubelet":    cm.generateKubeletService(config.Workers[0]),
+		"containerd": cm.generateContainerdService(),
+	} {
+		if strings.Contains(unit, "NoNewPrivileges") || !strings.Contains(unit, "Restart=on-failure\nRestartSec=10") {
+			t.Errorf("Unexpected %s unit:\n%s", name, unit)
+		}
+	}
+
+	for _, invalid := range []SystemdConfig{
+		{UnitConfig: UnitConfig{Restart: "sometimes"}},
+		{UnitConfig: UnitConfig{RestartSec: -1}},
+		{UnitConfig: UnitConfig{LimitNOFILE: "lots"}},
+		{Services: map[string]UnitConfig{"kube-dns": {}}},
+	} {
+		if err := validateSystemd(invalid); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestCoreDNSConfiguration(t *testing.T) {
+	config := createTestConfig()
+	cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+
+	manifest := cm.generateCoreDNSManifest()
+	for _, want := range []string{"replicas: 2", "forward . /etc/resolv.conf"} {
+		if !strings.Contains(manifest, want) {
+			t.Errorf("Default manifest doesn't contain %q", want)
+		}
+	}
+	if strings.Contains(manifest, "requests:") || strings.Contains(manifest, "limits:") || strings.Contains(manifest, "dns-autoscaler") {
+		t.Error("Default manifest shouldn't set resources or deploy the autoscaler")
+	}
+
+	config.CoreDNS = CoreDNSConfig{
+		Replicas: 3,
+		Resources: ResourceConfig{
+			Requests: ResourceList{CPU: "100m", Memory: "70Mi"},
+			Limits:   ResourceList{Memory: "170Mi"},
+		},
+		StubDomains: map[string][]string{"corp.example": {"10.0.0.53", "10.0.1.53"}},
+		Upstreams:   []string{"1.1.1.1", "8.8.8.8"},
+		Autoscaler:  &DNSAutoscalerConfig{Max: 10},
+	}
+	if err := validateCoreDNS(config.CoreDNS); err != nil {
+		t.Fatalf("Valid CoreDNS config rejected: %v", err)
+	}
+	cm = NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+	manifest = cm.generateCoreDNSManifest()
+	for _, want := range []string{
+		"replicas: 3",
+		"        resources:\n          requests:\n            cpu: 100m\n            memory: 70Mi\n          limits:\n            memory: 170Mi\n",
+		"forward . 1.1.1.1 8.8.8.8",
+		"    corp.example:53 {",
+		"forward . 10.0.0.53 10.0.1.53",
+		"--target=Deployment/coredns",
+		`"min":3,"max":10`,
+	} {
+		if !strings.Contains(manifest, want) {
+			t.Errorf("Manifest doesn't contain %q", want)
+		}
+	}
+
+	invalid := []CoreDNSConfig{
+		{Replicas: -1},
+		{Resources: ResourceConfig{Limits: ResourceList{Memory: "lots"}}},
+		{StubDomains: map[string][]string{"corp.example": nil}},
+		{Upstreams: []string{"1.1.1.1 }"}},
+		{Autoscaler: &DNSAutoscalerConfig{Min: 5, Max: 2}},
+	}
+	for i, c := range invalid {
+		if err := validateCoreDNS(c); err == nil {
+			t.Errorf("Expected invalid config %d to be rejected", i)
+		}
+	}
+}
+
+func TestKubeletServingCertificates(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Static", func(t *testing.T) {
+		config := createTestConfig()
+		config.WorkDir = t.TempDir()
+		sshClient := NewMockSSHClient()
+		cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+		worker := config.Workers[0]
+
+		if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+			t.Fatalf("Certificate generation failed: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(config.WorkDir, worker.Name+"-serving.pem"))
+		if err != nil {
+			t.Fatalf("Serving certificate was not generated: %v", err)
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			t.Fatal("Serving certificate is not PEM encoded")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("Failed to parse serving certificate: %v", err)
+		}
+		if err := cert.VerifyHostname(worker.IPAddress); err != nil {
+			t.Errorf("Serving certificate is not valid for the worker IP: %v", err)
+		}
+
+		kubeletConfig := cm.generateKubeletConfig(worker)
+		if !strings.Contains(kubeletConfig, "tlsCertFile: /var/lib/kubelet/"+worker.Name+"-serving.pem") {
+			t.Error("Kubelet config doesn't reference the serving certificate")
+		}
+		if strings.Contains(kubeletConfig, "serverTLSBootstrap") {
+			t.Error("Kubelet config shouldn't bootstrap serving certificates in static mode")
+		}
+
+		if err := cm.createConfigurations(ctx, config.WorkDir); err != nil {
+			t.Fatalf("Configuration creation failed: %v", err)
+		}
+		if err := cm.setupSingleWorkerNode(ctx, config.WorkDir, worker); err != nil {
+			t.Fatalf("Worker setup failed: %v", err)
+		}
+		if _, ok := sshClient.filesUploaded["/var/lib/kubelet/"+worker.Name+"-serving-key.pem"]; !ok {
+			t.Error("Serving key was not copied to the worker")
+		}
+	})
+
+	t.Run("Bootstrap", func(t *testing.T) {
+		config := createTestConfig()
+		config.KubeletServingCerts = KubeletServingBootstrap
+		sshClient := NewMockSSHClient()
+		logger := NewMockLogger()
+		cm := NewClusterManager(config, logger, sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+		if names := cm.kubeletServingCertificateNames(); len(names) != 0 {
+			t.Errorf("Expected no serving certificates in bootstrap mode, got %v", names)
+		}
+		kubeletConfig := cm.generateKubeletConfig(config.Workers[0])
+		if !strings.Contains(kubeletConfig, "serverTLSBootstrap: true") || strings.Contains(kubeletConfig, "tlsCertFile") {
+			t.Errorf("Unexpected kubelet config for bootstrap mode:\n%s", kubeletConfig)
+		}
+
+		var lines []string
+		for i, worker := range config.Workers {
+			lines = append(lines, fmt.Sprintf("csr-%d kubernetes.io/kubelet-serving system:node:%s", i, worker.Name))
+		}
+		lines = append(lines,
+			"csr-rogue kubernetes.io/kubelet-serving system:node:intruder",
+			"csr-client kubernetes.io/kube-apiserver-client-kubelet system:node:worker-0",
+			"csr-done kubernetes.io/kubelet-serving system:node:worker-0 Approved",
+		)
+		sshClient.SetCommandResponse(listKubeletCSRsCommand, strings.Join(lines, "\n"))
+
+		if err := cm.approveKubeletServingCSRs(ctx, config.Workers, time.Second); err != nil {
+			t.Fatalf("Approval failed: %v", err)
+		}
+		approved := map[string]bool{}
+		for _, cmd := range sshClient.GetExecutedCommands() {
+			if i := strings.Index(cmd, "kubectl certificate approve "); i >= 0 {
+				approved[strings.Fields(cmd[i+len("kubectl certificate approve "):])[0]] = true
+			}
+		}
+		for i := range config.Workers {
+			if !approved[fmt.Sprintf("csr-%d", i)] {
+				t.Errorf("Expected csr-%d to be approved", i)
+			}
+		}
+		for _, name := range []string{"csr-rogue", "csr-client", "csr-done"} {
+			if approved[name] {
+				t.Errorf("Did not expect %s to be approved", name)
+			}
+		}
+	})
+
+	t.Run("Bootstrap Timeout", func(t *testing.T) {
+		config := createTestConfig()
+		config.KubeletServingCerts = KubeletServingBootstrap
+		sshClient := NewMockSSHClient()
+		sshClient.SetCommandResponse(listKubeletCSRsCommand, "")
+		cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+		err := cm.approveKubeletServingCSRs(ctx, config.Workers, 0)
+		if err == nil || !strings.Contains(err.Error(), config.Workers[0].Name) {
+			t.Errorf("Expected a timeout naming the waiting workers, got %v", err)
+		}
+	})
+}
+
+// Comprehensive end-to-end test
+func TestEndToEndClusterSetup(t *testing.T) {
+	// This test simulates a complete cluster setup from configuration to validation
+	config := createTestConfig()
+	
+	// Create temporary work directory
+	workDir, err := os.MkdirTemp("", "e2e-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp work dir: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+	config.WorkDir = workDir
+
+	// Save configuration
+	configPath := filepath.Join(workDir, "cluster-config.yaml")
+	if err := SaveConfig(config, configPath); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	// Load configuration back
+	loadedConfig, err := LoadClusterConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Setup mocks with comprehensive responses
+	logger := NewMockLogger()
+	progress := NewMockProgressReporter()
+	sshClient := NewMockSSHClient()
+	certManager := NewCertificateManager()
+
+	// Setup SSH responses for all expected commands
+	sshClient.SetCommandResponse("echo 'SSH test'", "SSH test")
+	sshClient.SetCommandResponse("sudo systemctl is-active etcd", "active")
+	sshClient.SetCommandResponse("sudo systemctl is-active kube-apiserver", "active")
+	sshClient.SetCommandResponse("sudo systemctl is-active kube-controller-manager", "active")
+	sshClient.SetCommandResponse("sudo systemctl is-active kube-scheduler", "active")
+	sshClient.SetCommandResponse("kubectl get nodes --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		"NAME         STATUS   ROLES    AGE   VERSION\nworker-0     Ready    <none>   1m    v1.26.0\nworker-1     Ready    <none>   1m    v1.26.0")
+	sshClient.SetCommandResponse("kubectl get pods -n kube-system --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		"NAME                      READY   STATUS    RESTARTS   AGE\ncoredns-xxx               2/2     Running   0          1m")
+	sshClient.SetCommandResponse("kubectl get deployment test-deployment --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		"NAME              READY   UP-TO-DATE   AVAILABLE   AGE\ntest-deployment   2/2     2            2           1m")
+
+	cm := NewClusterManager(loadedConfig, logger, sshClient, certManager, progress)
+
+	t.Run("Complete Cluster Lifecycle", func(t *testing.T) {
+		ctx := context.Background()
+
+		// Setup cluster
+		if err := cm.SetupCluster(ctx); err != nil {
+			t.Fatalf("Cluster setup failed: %v", err)
+		}
+
+		// Verify cluster status
+		status, err := cm.GetClusterStatus(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get cluster status: %v", err)
+		}
+
+		if !strings.Contains(status.Nodes, "Ready") {
+			t.Error("Cluster nodes are not ready")
+		}
+
+		// Destroy cluster
+		if err := cm.DestroyCluster(ctx); err != nil {
+			t.Fatalf("Cluster destruction failed: %v", err)
+		}
+
+		// Verify logs contain all phases
+		logs := logger.GetLogs()
+		logStr := strings.Join(logs, " ")
+		
+		expectedLogMessages := []string{
+			"Generating certificates",
+			"Creating configurations", 
+			"Setting up control plane",
+			"Setting up worker nodes",
+			"Setting up networking",
+			"Validating cluster",
+			"Cluster destroyed successfully",
+		}
+
+		for _, msg := range expectedLogMessages {
+			if !strings.Contains(logStr, msg) {
+				t.Errorf("Expected log message '%s' not found", msg)
+			}
+		}
+
+		// Verify progress reporting
+		steps := progress.steps
+		if len(steps) < 6 { // At least 6 main phases
+			t.Errorf("Expected at least 6 progress steps, got %d", len(steps))
+		}
+
+		// Verify comprehensive command execution
+		commands := sshClient.GetExecutedCommands()
+		if len(commands) < 50 { // Expect many commands for full setup
+			t.Errorf("Expected many commands to be executed, got %d", len(commands))
+		}
+
+		t.Logf("End-to-end test completed successfully with %d commands executed", len(commands))
+	})
+}
+// Hook tests
+func TestHooks(t *testing.T) {
+	config := createTestConfig()
+	logger := NewMockLogger()
+	progress := NewMockProgressReporter()
+	sshClient := NewMockSSHClient()
+	certManager := NewCertificateManager()
+
+	workDir, err := os.MkdirTemp("", "hooks-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp work dir: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+	config.WorkDir = workDir
+
+	config.Hooks = HooksConfig{
+		PreControlPlane: []Hook{
+			{Name: "remote", Command: "echo pre-control-plane", Targets: []string{"controller", "worker-1"}},
+		},
+		PostSetup: []Hook{
+			{Name: "local", Command: "echo $CLUSTER_NAME > hook-output.txt", Local: true},
+		},
+	}
+
+	cm := NewClusterManager(config, logger, sshClient, certManager, progress)
+
+	t.Run("Remote Hook Targets", func(t *testing.T) {
+		if err := cm.runHooks(context.Background(), HookPreControlPlane); err != nil {
+			t.Fatalf("Remote hook failed: %v", err)
+		}
+
+		commands := sshClient.GetExecutedCommands()
+		expected := []string{
+			config.Controller.IPAddress + ": echo pre-control-plane",
+			config.Workers[1].IPAddress + ": echo pre-control-plane",
+		}
+		if len(commands) != len(expected) {
+			t.Fatalf("Expected %d hook commands, got %d: %v", len(expected), len(commands), commands)
+		}
+		for i, cmd := range expected {
+			if commands[i] != cmd {
+				t.Errorf("Expected command %q, got %q", cmd, commands[i])
+			}
+		}
+	})
+
+	t.Run("Local Hook Environment", func(t *testing.T) {
+		if err := cm.runHooks(context.Background(), HookPostSetup); err != nil {
+			t.Fatalf("Local hook failed: %v", err)
+		}
+
+		content, err := os.ReadFile(filepath.Join(workDir, "hook-output.txt"))
+		if err != nil {
+			t.Fatalf("Local hook did not write output: %v", err)
+		}
+		if strings.TrimSpace(string(content)) != config.ClusterName {
+			t.Errorf("Expected CLUSTER_NAME %s, got %s", config.ClusterName, string(content))
+		}
+	})
+
+	t.Run("Failing Hook", func(t *testing.T) {
+		sshClient.SetCommandError("exit 1", fmt.Errorf("exit status 1"))
+		cm.config.Hooks.PreWorkers = []Hook{{Name: "fails", Command: "exit 1"}}
+
+		if err := cm.runHooks(context.Background(), HookPreWorkers); err == nil {
+			t.Error("Expected error from failing hook")
+		}
+
+		cm.config.Hooks.PreWorkers[0].ContinueOnError = true
+		if err := cm.runHooks(context.Background(), HookPreWorkers); err != nil {
+			t.Errorf("Expected failing hook to be ignored, got: %v", err)
+		}
+	})
+
+	t.Run("Hook Validation", func(t *testing.T) {
+		invalid := createTestConfig()
+		invalid.Hooks.PostSetup = []Hook{{Command: "true", Targets: []string{"worker-9"}}}
+		if err := validateHooks(invalid); err == nil {
+			t.Error("Expected error for unknown hook target")
+		}
+
+		invalid.Hooks.PostSetup = []Hook{{Local: true}}
+		if err := validateHooks(invalid); err == nil {
+			t.Error("Expected error for hook without command")
+		}
+	})
+}
+
+// Event bus tests
+func TestEventBus(t *testing.T) {
+	t.Run("Subscribe And Unsubscribe", func(t *testing.T) {
+		bus := NewEventBus()
+		var received []EventType
+		unsubscribe := bus.Subscribe(func(e Event) { received = append(received, e.Type) })
+
+		bus.Publish(Event{Type: EventPhaseStarted})
+		unsubscribe()
+		bus.Publish(Event{Type: EventSetupCompleted})
+
+		if len(received) != 1 || received[0] != EventPhaseStarted {
+			t.Errorf("Expected only phase_started event, got %v", received)
+		}
+	})
+
+	t.Run("Setup Lifecycle Events", func(t *testing.T) {
+		config := createTestConfig()
+		sshClient := NewMockSSHClient()
+		sshClient.SetCommandError("echo 'SSH test'", fmt.Errorf("connection refused"))
+		cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+		var events []Event
+		cm.Events().Subscribe(func(e Event) { events = append(events, e) })
+
+		if err := cm.SetupCluster(context.Background()); err == nil {
+			t.Fatal("Expected setup to fail")
+		}
+
+		types := map[EventType]bool{}
+		for _, e := range events {
+			types[e.Type] = true
+			if e.Cluster != config.ClusterName {
+				t.Errorf("Event %s missing cluster name", e.Type)
+			}
+		}
+		for _, expected := range []EventType{EventPhaseStarted, EventCommandFailed, EventSetupFailed} {
+			if !types[expected] {
+				t.Errorf("Expected %s event to be published", expected)
+			}
+		}
+	})
+}
+
+// Notification tests
+func TestWebhookNotifications(t *testing.T) {
+	var received []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode webhook body: %v", err)
+		}
+		received = append(received, body)
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.Notifications = NotificationConfig{
+		Webhooks: []WebhookConfig{
+			{URL: server.URL},
+			{URL: server.URL, Format: "slack", Events: []string{string(EventSetupFailed)}},
+		},
+	}
+	sshClient := NewMockSSHClient()
+	sshClient.SetCommandError("echo 'SSH test'", fmt.Errorf("connection refused"))
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	if err := cm.SetupCluster(context.Background()); err == nil {
+		t.Fatal("Expected setup to fail")
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 notifications, got %d: %v", len(received), received)
+	}
+	if received[0]["event"] != string(EventSetupFailed) || received[0]["cluster"] != config.ClusterName {
+		t.Errorf("Unexpected JSON payload: %v", received[0])
+	}
+	if !strings.Contains(received[1]["text"], "setup failed") {
+		t.Errorf("Unexpected Slack payload: %v", received[1])
+	}
+
+	if err := validateNotifications(NotificationConfig{Webhooks: []WebhookConfig{{URL: server.URL, Format: "xml"}}}); err == nil {
+		t.Error("Expected error for unknown webhook format")
+	}
+}
+
+func TestVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors":["permission denied"]}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/k8s":
+			fmt.Fprint(w, `{"data":{"data":{"ssh_key":"KEY","sudo":"pw"},"metadata":{"version":1}}}`)
+		case "/v1/pki/cert/ca":
+			fmt.Fprint(w, `{"data":{"certificate":"CA CERT"}}`)
+		case "/v1/pki/issue/kubernetes":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["common_name"] == "kubernetes" && body["ip_sans"] != "127.0.0.1" {
+				t.Errorf("Unexpected ip_sans: %q", body["ip_sans"])
+			}
+			fmt.Fprintf(w, `{"data":{"certificate":"CERT %s","private_key":"KEY %s"}}`, body["common_name"], body["common_name"])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[]}`)
+		}
+	}))
+	defer server.Close()
+
+	vault, err := NewVaultClient(VaultConfig{Address: server.URL, Token: "test-token"})
+	if err != nil {
+		t.Fatalf("Failed to create vault client: %v", err)
+	}
+
+	t.Run("ReadField", func(t *testing.T) {
+		value, err := vault.ReadField(context.Background(), VaultSecretRef{Path: "secret/data/k8s", Field: "ssh_key"})
+		if err != nil || value != "KEY" {
+			t.Errorf("Expected KEY, got %q (%v)", value, err)
+		}
+		if _, err := vault.ReadField(context.Background(), VaultSecretRef{Path: "secret/data/k8s", Field: "missing"}); err == nil {
+			t.Error("Expected error for missing field")
+		}
+		if _, err := vault.ReadField(context.Background(), VaultSecretRef{Path: "secret/data/other", Field: "x"}); err == nil {
+			t.Error("Expected error for missing secret")
+		}
+	})
+
+	t.Run("SSHClient", func(t *testing.T) {
+		client, err := NewSSHClientFromVault(context.Background(), vault, "ubuntu", VaultConfig{
+			SSHKey:       VaultSecretRef{Path: "secret/data/k8s", Field: "ssh_key"},
+			SudoPassword: VaultSecretRef{Path: "secret/data/k8s", Field: "sudo"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create SSH client: %v", err)
+		}
+		command, input := client.sudoCommand("sudo mv a b && sudo systemctl restart x")
+		if command != "sudo -S -p '' mv a b && sudo -S -p '' systemctl restart x" || input != "pw\npw\n" {
+			t.Errorf("Unexpected sudo rewrite: %q %q", command, input)
+		}
+	})
+
+	t.Run("Certificates", func(t *testing.T) {
+		workDir := t.TempDir()
+		certManager := NewVaultCertificateManager(vault, VaultPKIConfig{
+			Mount: "pki",
+			Role:  "kubernetes",
+			CAKey: VaultSecretRef{Path: "secret/data/k8s", Field: "ssh_key"},
+		})
+		if err := certManager.GenerateCA(workDir, CertificateConfig{}); err != nil {
+			t.Fatalf("GenerateCA failed: %v", err)
+		}
+		if err := certManager.GenerateServerCert(workDir, "kubernetes", []string{"127.0.0.1", "kubernetes.default"}, CertificateConfig{}); err != nil {
+			t.Fatalf("GenerateServerCert failed: %v", err)
+		}
+		for file, want := range map[string]string{"ca.pem": "CA CERT\n", "kubernetes.pem": "CERT kubernetes\n", "kubernetes-key.pem": "KEY kubernetes\n"} {
+			data, err := os.ReadFile(filepath.Join(workDir, file))
+			if err != nil || string(data) != want {
+				t.Errorf("Unexpected %s: %q (%v)", file, data, err)
+			}
+		}
+	})
+
+	if err := validateVault(VaultConfig{Address: server.URL, PKI: &VaultPKIConfig{Mount: "pki", Role: "k8s"}}); err == nil {
+		t.Error("Expected error for PKI without ca_key")
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("No home directory: %v", err)
+	}
+
+	tests := map[string]string{
+		"~":                home,
+		"~/.ssh/id_rsa":    filepath.Join(home, ".ssh", "id_rsa"),
+		`~\.ssh\id_rsa`:    filepath.Join(home, ".ssh", "id_rsa"),
+		"/etc/ssh/key.pem": "/etc/ssh/key.pem",
+		"relative/key":     "relative/key",
+	}
+	for input, want := range tests {
+		got, err := expandHome(input)
+		if err != nil || got != want {
+			t.Errorf("expandHome(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+}
+
+func TestNonLinuxNodesRejected(t *testing.T) {
+	sshClient := NewMockSSHClient()
+	sshClient.SetCommandResponse("uname -s", "Darwin\n")
+	cm := NewClusterManager(createTestConfig(), NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	err := cm.ValidateK8sPrerequisites()
+	if err == nil || !strings.Contains(err.Error(), "only supports Linux") {
+		t.Errorf("Expected Linux-only error, got %v", err)
+	}
+}
+
+type structuredProgressReporter struct {
+	*MockProgressReporter
+	updates []ProgressUpdate
+}
+
+func (p *structuredProgressReporter) ReportStep(update ProgressUpdate) {
+	p.updates = append(p.updates, update)
+}
+
+func TestStructuredProgress(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	progress := &structuredProgressReporter{MockProgressReporter: NewMockProgressReporter()}
+	cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), progress)
+	ctx := context.Background()
+
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Certificate generation failed: %v", err)
+	}
+	if err := cm.createConfigurations(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Configuration creation failed: %v", err)
+	}
+	if err := cm.setupSingleWorkerNode(ctx, config.WorkDir, config.Workers[0]); err != nil {
+		t.Fatalf("Worker setup failed: %v", err)
+	}
+
+	certSteps := len(cm.clientCertificateNames()) + len(cm.kubeletServingCertificateNames()) + 2
+	configSteps := len(config.Workers) + 5
+	if want := certSteps + configSteps + len(workerSteps); len(progress.updates) != want {
+		t.Fatalf("Expected %d step updates, got %d", want, len(progress.updates))
+	}
+
+	first := progress.updates[0]
+	if first.Step != "Generating CA" || first.NodeStep != 1 || first.NodeSteps != certSteps || first.Node != "" {
+		t.Errorf("Unexpected first update: %+v", first)
+	}
+	last := progress.updates[len(progress.updates)-1]
+	if last.Node != config.Workers[0].Name || last.Step != "Waiting for node Ready" || last.NodeStep != len(workerSteps) {
+		t.Errorf("Unexpected last update: %+v", last)
+	}
+	for i, update := range progress.updates {
+		if update.Completed != i || update.Total != cm.estimateSetupSteps() {
+			t.Errorf("Update %d has completed %d of %d", i, update.Completed, update.Total)
+		}
+	}
+	if last.ETA <= 0 && last.Elapsed > 0 {
+		t.Errorf("Expected an ETA once steps completed, got %v", last.ETA)
+	}
+}
+
+func TestLoggerAdapters(t *testing.T) {
+	var lines []string
+	logger := NewFuncLogger(func(level LogLevel, msg string) {
+		lines = append(lines, string(level)+" "+msg)
+	})
+	logger.Info("plain 100% message")
+	logger.Warn("node %s is %d%% done", "worker-1", 50)
+
+	want := []string{"INFO plain 100% message", "WARN node worker-1 is 50% done"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected messages: %q", lines)
+	}
+
+	var buf strings.Builder
+	NewMultiLogger(NewWriterLogger(&buf), NewNopLogger()).Error("setup failed: %v", fmt.Errorf("boom"))
+	if buf.String() != "ERROR: setup failed: boom\n" {
+		t.Errorf("Unexpected writer output: %q", buf.String())
+	}
+}
+
+func TestDestroyProgress(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	progress := &structuredProgressReporter{MockProgressReporter: NewMockProgressReporter()}
+	cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), progress)
+
+	if err := cm.DestroyCluster(context.Background()); err != nil {
+		t.Fatalf("Cluster destruction failed: %v", err)
+	}
+
+	nodes := 1 + len(config.Workers)
+	if want := nodes * len(destroyCommands); len(progress.updates) != want {
+		t.Fatalf("Expected %d step updates, got %d", want, len(progress.updates))
+	}
+	first := progress.updates[0]
+	if first.Node != config.Controller.Name || first.Step != "Stopping services" || first.NodeSteps != len(destroyCommands) {
+		t.Errorf("Unexpected first update: %+v", first)
+	}
+	last := progress.updates[len(progress.updates)-1]
+	if last.Node != config.Workers[len(config.Workers)-1].Name || last.NodeStep != len(destroyCommands) {
+		t.Errorf("Unexpected last update: %+v", last)
+	}
+}
+
+func TestClusterHealth(t *testing.T) {
+	config := createTestConfig()
+	sshClient := NewMockSSHClient()
+	sshClient.SetCommandResponse("systemctl is-active etcd kube-apiserver kube-controller-manager kube-scheduler || true",
+		"active\nactive\nactive\nactive\n")
+	sshClient.SetCommandResponse("systemctl is-active containerd kubelet kube-proxy || true", "active\nfailed\n")
+	sshClient.SetCommandResponse(etcdctlCommand,
+		"8e9e05c52164694d, started, controller-0, https://10.240.0.10:2380, https://10.240.0.10:2379, false\n")
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	services, err := cm.GetServiceHealth(context.Background())
+	if err != nil {
+		t.Fatalf("Service health check failed: %v", err)
+	}
+	if want := len(etcdServices) + len(controllerServices) + len(config.Workers)*len(workerServices); len(services) != want {
+		t.Fatalf("Expected %d services, got %d", want, len(services))
+	}
+	for _, service := range services {
+		healthy := service.Service != "kubelet" && service.Service != "kube-proxy"
+		if service.Healthy() != healthy {
+			t.Errorf("Unexpected state for %s on %s: %s", service.Service, service.Node, service.State)
+		}
+	}
+	if last := services[len(services)-1]; last.State != "unknown" {
+		t.Errorf("Expected missing state to be unknown, got %s", last.State)
+	}
+
+	members, err := cm.GetEtcdMembers(context.Background())
+	if err != nil {
+		t.Fatalf("etcd member check failed: %v", err)
+	}
+	if len(members) != 1 || members[0].Name != "controller-0" || members[0].Status != "started" {
+		t.Errorf("Unexpected etcd members: %+v", members)
+	}
+}
+
+func TestExecuteScript(t *testing.T) {
+	t.Run("Script Command", func(t *testing.T) {
+		client := &RealSSHClient{user: "ubuntu", sudoPassword: "pw"}
+		command, input := client.scriptCommand("sudo systemctl daemon-reload\necho 'done'")
+		if !strings.HasPrefix(command, "bash -c 'set -euo pipefail\n") {
+			t.Errorf("Expected the script to run under set -euo pipefail, got %q", command)
+		}
+		if !strings.Contains(command, "sudo -S -p '\\'''\\'' systemctl daemon-reload\necho '\\''done'\\'''") {
+			t.Errorf("Expected the script to be quoted with sudo rewritten, got %q", command)
+		}
+		if input != "pw\n" {
+			t.Errorf("Expected one sudo password on stdin, got %q", input)
+		}
+	})
+
+	t.Run("Install Steps", func(t *testing.T) {
+		sshClient := NewMockSSHClient()
+		cm := NewClusterManager(createTestConfig(), NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+		step := installStep{name: "Installing tools", commands: []string{"sudo apt-get update", "sudo apt-get -y install socat"}}
+		sshClient.SetCommandError("sudo apt-get -y install socat", fmt.Errorf("exit status 100"))
+
+		err := cm.runInstall(context.Background(), cm.config.Workers[0], step)
+		if err == nil || !strings.Contains(err.Error(), "line 2") || !strings.Contains(err.Error(), "installing tools") {
+			t.Errorf("Expected the failing line in the error, got %v", err)
+		}
+	})
+}
+
+func TestResumeSetup(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	sshClient := NewMockSSHClient()
+	sshClient.SetCommandResponse("echo 'SSH test'", "SSH test")
+	for _, service := range append(append([]string{}, etcdServices...), controllerServices...) {
+		sshClient.SetCommandResponse("sudo systemctl is-active "+service, "active")
+	}
+	sshClient.SetCommandResponse("kubectl get nodes --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		"NAME         STATUS   ROLES    AGE   VERSION\nworker-0     Ready    <none>   1m    v1.26.0\nworker-1     Ready    <none>   1m    v1.26.0")
+	sshClient.SetCommandResponse("kubectl get pods -n kube-system --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		"NAME                      READY   STATUS    RESTARTS   AGE\ncoredns-xxx               2/2     Running   0          1m")
+	sshClient.SetCommandResponse("kubectl get deployment test-deployment --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		"NAME              READY   UP-TO-DATE   AVAILABLE   AGE\ntest-deployment   2/2     2            2           1m")
+	applyCoreDNS := "kubectl apply -f /tmp/coredns.yaml --kubeconfig /var/lib/kubernetes/admin.kubeconfig"
+	sshClient.SetCommandError(applyCoreDNS, fmt.Errorf("connection refused"))
+
+	logger := NewMockLogger()
+	cm := NewClusterManager(config, logger, sshClient, NewCertificateManager(), NewMockProgressReporter())
+	ctx := context.Background()
+
+	if err := cm.ResumeSetup(ctx); err == nil {
+		t.Error("Expected resuming without a setup state to fail")
+	}
+	if err := cm.SetupCluster(ctx); err == nil {
+		t.Fatal("Expected setup to fail deploying CoreDNS")
+	}
+
+	state, err := LoadSetupState(config.WorkDir)
+	if err != nil {
+		t.Fatalf("Failed to load setup state: %v", err)
+	}
+	for _, step := range []string{"hooks/pre_setup", "certificates", "configurations", "etcd/controller-0", "control-plane/controller-0", "control-plane", "workers/worker-1", "workers"} {
+		if !state.Done(step) {
+			t.Errorf("Expected %s to be recorded as completed", step)
+		}
+	}
+	if state.Done("networking") {
+		t.Error("Networking failed but was recorded as completed")
+	}
+
+	// Pretend setup stopped while worker-1 was being set up
+	delete(state.Completed, "workers")
+	delete(state.Completed, "workers/worker-1")
+	if err := state.save(); err != nil {
+		t.Fatalf("Failed to save setup state: %v", err)
+	}
+	caBefore, _ := os.ReadFile(filepath.Join(config.WorkDir, "ca.pem"))
+	encryptionBefore, _ := os.ReadFile(filepath.Join(config.WorkDir, "encryption-config.yaml"))
+
+	delete(sshClient.errors, applyCoreDNS)
+	sshClient.SetCommandResponse("systemctl is-active containerd kubelet kube-proxy || true", "active\nactive\nactive\n")
+	sshClient.SetCommandResponse("(which socat conntrack ipset) 2>/dev/null && echo installed || true", "installed\n")
+	executed := len(sshClient.GetExecutedCommands())
+
+	if err := cm.ResumeSetup(ctx); err != nil {
+		t.Fatalf("Resumed setup failed: %v", err)
+	}
+
+	commands := strings.Join(sshClient.GetExecutedCommands()[executed:], "\n")
+	if strings.Contains(commands, "10.240.0.20: sudo systemctl start") {
+		t.Error("Expected worker-0, which was set up, to be skipped")
+	}
+	if !strings.Contains(commands, "10.240.0.21: sudo systemctl start containerd kubelet kube-proxy") {
+		t.Error("Expected worker-1 to be set up again")
+	}
+	if strings.Contains(commands, "apt-get") {
+		t.Error("Expected installed dependencies not to be installed again")
+	}
+	if strings.Contains(commands, "systemctl enable kube-apiserver") {
+		t.Error("Expected the completed control plane to be skipped")
+	}
+	if !strings.Contains(commands, applyCoreDNS) {
+		t.Error("Expected CoreDNS to be deployed")
+	}
+	if caAfter, _ := os.ReadFile(filepath.Join(config.WorkDir, "ca.pem")); string(caAfter) != string(caBefore) {
+		t.Error("Expected the CA to be kept")
+	}
+	if encryptionAfter, _ := os.ReadFile(filepath.Join(config.WorkDir, "encryption-config.yaml")); string(encryptionAfter) != string(encryptionBefore) {
+		t.Error("Expected the encryption config to be kept")
+	}
+	if state, err := LoadSetupState(config.WorkDir); err != nil || !state.Done("workers/worker-1") || !state.Done("validation") {
+		t.Errorf("Expected the resumed steps to be recorded, got %v (%v)", state, err)
+	}
+
+	cm.config.KubernetesVersion = "v1.27.0"
+	if err := cm.ResumeSetup(ctx); err == nil || !strings.Contains(err.Error(), "run a full setup") {
+		t.Errorf("Expected a state for another version to be rejected, got %v", err)
+	}
+}
+
+func TestNodeFacts(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	sshClient := NewMockSSHClient()
+	logger := NewMockLogger()
+	cm := NewClusterManager(config, logger, sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	output := "cpus=1\nmemory_kb=1048576\ndisk_free_kb=52428800\nkernel=5.15.0-89-generic\narch=x86_64\n" +
+		"os=Ubuntu 22.04.3 LTS\nos_id=ubuntu\nos_version=22.04\nruntime=containerd\nruntime=dockerd\n"
+	sshClient.SetCommandResponse(factsCommand, output)
+
+	t.Run("Parsing", func(t *testing.T) {
+		facts := parseFacts("worker-0", output)
+		if facts.CPUs != 1 || facts.MemoryMB != 1024 || facts.DiskFreeMB != 51200 {
+			t.Errorf("Unexpected resources: %+v", facts)
+		}
+		if facts.Kernel != "5.15.0-89-generic" || facts.OSID != "ubuntu" || facts.OSVersion != "22.04" || facts.OS != "Ubuntu 22.04.3 LTS" {
+			t.Errorf("Unexpected system facts: %+v", facts)
+		}
+		if strings.Join(facts.Runtimes, ",") != "containerd,dockerd" {
+			t.Errorf("Unexpected runtimes: %v", facts.Runtimes)
+		}
+		if unknown := parseFacts("worker-0", "cpus=\nsuccess"); unknown.CPUs != 0 || unknown.Arch != "" {
+			t.Errorf("Expected unreported facts to stay empty, got %+v", unknown)
+		}
+	})
+
+	t.Run("Prerequisites", func(t *testing.T) {
+		if err := cm.ValidateK8sPrerequisites(); err != nil {
+			t.Fatalf("Prerequisites failed: %v", err)
+		}
+		logs := strings.Join(logger.GetLogs(), "\n")
+		if !strings.Contains(logs, "controller controller-0 has only 1 CPUs (recommended 2), 1024 MB of memory (recommended 2048)") {
+			t.Errorf("Expected a warning about the small controller, got:\n%s", logs)
+		}
+		if !strings.Contains(logs, "worker-1 already runs dockerd") {
+			t.Errorf("Expected a warning about Docker on the workers, got:\n%s", logs)
+		}
+
+		facts, err := LoadNodeFacts(config.WorkDir, "worker-1")
+		if err != nil {
+			t.Fatalf("Facts were not cached: %v", err)
+		}
+		if facts.Node != "worker-1" || facts.MemoryMB != 1024 || facts.GatheredAt.IsZero() {
+			t.Errorf("Unexpected cached facts: %+v", facts)
+		}
+	})
+
+	t.Run("Architecture", func(t *testing.T) {
+		sshClient.SetCommandResponse(factsCommand, strings.Replace(output, "arch=x86_64", "arch=aarch64", 1))
+		err := cm.ValidateK8sPrerequisites()
+		if err == nil || !strings.Contains(err.Error(), "aarch64") {
+			t.Errorf("Expected non-x86_64 nodes to be rejected, got %v", err)
+		}
+	})
+}
+
+func TestExportRunbook(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	config.WebhookSmokeTest = true
+	config.KubeletServingCerts = KubeletServingBootstrap
+	config.Hooks = HooksConfig{
+		PostSetup: []Hook{{Name: "local", Command: "echo $CLUSTER_NAME > hook-output.txt", Local: true}},
+	}
+	sshClient := NewMockSSHClient()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	path, err := cm.ExportRunbook(context.Background())
+	if err != nil {
+		t.Fatalf("Runbook export failed: %v", err)
+	}
+	if executed := sshClient.GetExecutedCommands(); len(executed) > 0 {
+		t.Errorf("Expected no commands on the nodes, got %v", executed)
+	}
+	runbookDir := filepath.Join(config.WorkDir, RunbookDir)
+	if path != filepath.Join(runbookDir, RunbookFile) {
+		t.Errorf("Expected the runbook in %s, got %s", runbookDir, path)
+	}
+	for _, file := range []string{"hook-output.txt", SetupStateFile, factsDir, SSHConfigFile, InventoryFile} {
+		if _, err := os.Stat(filepath.Join(runbookDir, file)); err == nil {
+			t.Errorf("Expected no %s in the runbook directory", file)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(config.WorkDir, "ca.pem")); err == nil {
+		t.Error("Expected the work directory's certificates to be left alone")
+	}
+	if _, err := os.Stat(filepath.Join(runbookDir, "ca.pem")); err != nil {
+		t.Errorf("Expected the runbook's certificates next to it: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read runbook: %v", err)
+	}
+	runbook := string(content)
+	order := []string{
+		"## 1/7 Checking Prerequisites",
+		"### controller-0 (10.240.0.10)",
+		"## 4/7 Setting Up Control Plane",
+		"- Upload `ca.pem` to `/etc/etcd/ca.pem`",
+		"sudo systemctl is-active etcd",
+		"## 5/7 Setting Up Worker Nodes",
+		"### worker-1 (10.240.0.21)",
+		"kubectl certificate approve <csr-of-worker-0>",
+		"## 7/7 Validating Cluster",
+		"sleep 30",
+		"# Expected to be denied by the admission webhook",
+		"### Locally",
+		"echo $CLUSTER_NAME > hook-output.txt",
+	}
+	rest := runbook
+	for _, want := range order {
+		i := strings.Index(rest, want)
+		if i < 0 {
+			t.Fatalf("Expected %q after the earlier runbook steps in:\n%s", want, runbook)
+		}
+		rest = rest[i+len(want):]
+	}
+
+	uploads, err := filepath.Glob(filepath.Join(runbookDir, "files", "controller-0", "*-etcd.service"))
+	if err != nil || len(uploads) != 1 {
+		t.Fatalf("Expected the etcd unit among the uploaded files, got %v", uploads)
+	}
+	source, _ := filepath.Rel(runbookDir, uploads[0])
+	if !strings.Contains(runbook, "- Upload `"+source+"` to `/etc/systemd/system/etcd.service`") {
+		t.Errorf("Expected the runbook to list the upload of %s", source)
+	}
+}
+
+func TestCNIProviders(t *testing.T) {
+	for _, c := range []struct {
+		provider string
+		smoke    bool
+		valid    bool
+	}{
+		{"", true, true},
+		{CNIBridge, false, true},
+		{CNICalico, false, true},
+		{CNIFlannel, false, true},
+		{CNICilium, false, true},
+		{"weave", false, false},
+		{CNICalico, true, false},
+	} {
+		config := createTestConfig()
+		config.CNIProvider = c.provider
+		config.WebhookSmokeTest = c.smoke
+		if err := validateCNI(config); (err == nil) != c.valid {
+			t.Errorf("cni_provider %q with webhook smoke test %v: expected valid=%v, got %v", c.provider, c.smoke, c.valid, err)
+		}
+	}
+
+	ctx := context.Background()
+	worker := createTestConfig().Workers[0]
+	bridge := NewClusterManager(createTestConfig(), NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+	if _, ok := bridge.cni().workerConfigs(worker)["/etc/cni/net.d/10-bridge.conf"]; !ok || !bridge.cni().podRoutes() {
+		t.Error("Expected the bridge provider by default")
+	}
+	if strings.Contains(bridge.generateControllerManagerService(), "--allocate-node-cidrs") {
+		t.Error("Expected the bridge provider to keep the configured pod CIDRs")
+	}
+
+	for _, c := range []struct {
+		provider string
+		commands []string
+	}{
+		{CNICalico, []string{
+			"wget -q -O /tmp/calico.yaml 'https://raw.githubusercontent.com/projectcalico/calico/v3.26.1/manifests/calico.yaml'",
+			`sed -i 's|#   value: "192.168.0.0/16"|  value: "10.200.0.0/16"|' /tmp/calico.yaml`,
+			"kubectl apply -f /tmp/calico.yaml --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		}},
+		{CNIFlannel, []string{
+			"sed -i 's|10.244.0.0/16|10.200.0.0/16|g' /tmp/flannel.yaml",
+			"kubectl apply -f /tmp/flannel.yaml --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		}},
+		{CNICilium, []string{
+			"sudo tar -xzf /tmp/cilium-linux-amd64.tar.gz -C /usr/local/bin",
+			"KUBECONFIG=/var/lib/kubernetes/admin.kubeconfig cilium install --version 1.14.2 --set ipam.mode=cluster-pool --set ipam.operator.clusterPoolIPv4PodCIDRList=10.200.0.0/16 --set k8sServiceHost=10.240.0.10",
+		}},
+	} {
+		config := createTestConfig()
+		config.CNIProvider = c.provider
+		sshClient := NewMockSSHClient()
+		sshClient.SetCommandResponse("kubectl get --raw=/readyz --server=https://10.240.0.10:6443 --kubeconfig /var/lib/kubernetes/admin.kubeconfig", "ok\n")
+		cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+		if _, ok := cm.cni().workerConfigs(worker)["/etc/cni/net.d/10-bridge.conf"]; ok {
+			t.Errorf("%s: expected no bridge config on the workers", c.provider)
+		}
+		if err := cm.deployPodNetwork(ctx); err != nil {
+			t.Fatalf("%s: deploying the pod network failed: %v", c.provider, err)
+		}
+		if err := cm.setupNetworking(ctx); err != nil {
+			t.Fatalf("%s: networking setup failed: %v", c.provider, err)
+		}
+		commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+		for _, command := range c.commands {
+			if !strings.Contains(commands, "10.240.0.10: "+command) {
+				t.Errorf("%s: expected %q on the controller, got:\n%s", c.provider, command, commands)
+			}
+		}
+		if strings.Contains(commands, "ip route add") {
+			t.Errorf("%s: expected no static pod routes, got:\n%s", c.provider, commands)
+		}
+		if steps := cm.setupPhaseSteps()["networking"]; steps != 1 {
+			t.Errorf("%s: expected only CoreDNS in the networking phase, got %d steps", c.provider, steps)
+		}
+		allocate := strings.Contains(cm.generateControllerManagerService(), "--allocate-node-cidrs=true \\\n")
+		if allocate != (c.provider == CNIFlannel) {
+			t.Errorf("%s: expected node CIDR allocation only for flannel, got %v", c.provider, allocate)
+		}
+	}
+}
+
+func TestNodeAccessFiles(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	config.SSHKey = "/home/ubuntu/.ssh/cluster"
+	config.Workers[1].Hostname = "worker-1.example.com"
+	cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+
+	if err := cm.ValidateK8sPrerequisites(); err != nil {
+		t.Fatalf("Prerequisites validation failed: %v", err)
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(config.WorkDir, name))
+		if err != nil {
+			t.Fatalf("Expected %s in the work directory: %v", name, err)
+		}
+		return string(data)
+	}
+
+	sshConfig := read(SSHConfigFile)
+	for _, want := range []string{
+		"Host controller-0\n  HostName 10.240.0.10\n  User " + config.SSHUser + "\n  IdentityFile /home/ubuntu/.ssh/cluster\n",
+		"Host worker-1 worker-1.example.com\n  HostName 10.240.0.21\n",
+	} {
+		if !strings.Contains(sshConfig, want) {
+			t.Errorf("Expected %q in ssh_config:\n%s", want, sshConfig)
+		}
+	}
+
+	inventory := read(InventoryFile)
+	for _, want := range []string{
+		"[controllers]\ncontroller-0 ansible_host=10.240.0.10\n",
+		"[etcd]\ncontroller-0 ansible_host=10.240.0.10\n",
+		"[workers]\nworker-0 ansible_host=10.240.0.20 pod_cidr=10.200.0.0/24\nworker-1 ansible_host=10.240.0.21 pod_cidr=10.200.1.0/24\n",
+		"[k8s_cluster:children]\ncontrollers\netcd\nworkers\n",
+		"ansible_ssh_private_key_file=/home/ubuntu/.ssh/cluster\n",
+	} {
+		if !strings.Contains(inventory, want) {
+			t.Errorf("Expected %q in the inventory:\n%s", want, inventory)
+		}
+	}
+
+	// Keys read from Vault have no file to point to
+	cm.config.SSHKey = ""
+	cm.writeNodeAccessFiles()
+	if strings.Contains(read(SSHConfigFile), "IdentityFile") || strings.Contains(read(InventoryFile), "ansible_ssh_private_key_file") {
+		t.Error("Expected no key file without an ssh_key")
+	}
+}
+
+func TestPlanSetup(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	sshClient := NewMockSSHClient()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	plan, err := cm.PlanSetup(context.Background())
+	if err != nil {
+		t.Fatalf("Planning failed: %v", err)
+	}
+	if executed := sshClient.GetExecutedCommands(); len(executed) > 0 {
+		t.Errorf("Expected no commands on the nodes, got %v", executed)
+	}
+	if entries, _ := os.ReadDir(config.WorkDir); len(entries) > 0 {
+		t.Errorf("Expected nothing written to the work directory, got %d entries", len(entries))
+	}
+
+	var names []string
+	for _, cert := range plan.Certificates {
+		names = append(names, cert.Name)
+	}
+	certificates := strings.Join(names, ",")
+	for _, name := range []string{"ca", "admin", "worker-0", "kubernetes"} {
+		if !strings.Contains(","+certificates+",", ","+name+",") {
+			t.Errorf("Expected certificate %s in the plan, got %s", name, certificates)
+		}
+	}
+
+	var unit, cert *PlanStep
+	for i, step := range plan.Steps {
+		switch step.Upload {
+		case "/etc/systemd/system/etcd.service":
+			unit = &plan.Steps[i]
+		case "/etc/etcd/ca.pem":
+			cert = &plan.Steps[i]
+		}
+	}
+	if unit == nil || unit.Node != "controller-0" || !strings.Contains(unit.Content, "ExecStart=") || unit.Source != "" {
+		t.Errorf("Expected the etcd unit's content uploaded to controller-0, got %+v", unit)
+	}
+	if cert == nil || cert.Source != "ca.pem" || !strings.HasPrefix(cert.Phase, "4/7") {
+		t.Errorf("Expected ca.pem uploaded during the control plane phase, got %+v", cert)
+	}
+
+	printed := plan.String()
+	for _, want := range []string{
+		"Setup plan for " + config.ClusterName + " (Kubernetes v1.26.0)",
+		"Certificates to issue (",
+		"  ca from a new local CA\n",
+		"  kubernetes from the local CA for ",
+		"[1/7 Checking Prerequisites]",
+		"controller-0 (10.240.0.10): echo 'SSH test'",
+		"controller-0 (10.240.0.10): upload ca.pem -> /etc/etcd/ca.pem",
+	} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("Expected %q in the printed plan:\n%s", want, printed)
+		}
+	}
+}
+
+func TestPlanSetupWithVaultPKI(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "unexpected request", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	config.Vault = VaultConfig{Address: server.URL, Token: "token", PKI: &VaultPKIConfig{Mount: "pki", Role: "kubernetes"}}
+	vault, err := NewVaultClient(config.Vault)
+	if err != nil {
+		t.Fatalf("Failed to create Vault client: %v", err)
+	}
+	cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewVaultCertificateManager(vault, *config.Vault.PKI), NewMockProgressReporter())
+
+	plan, err := cm.PlanSetup(context.Background())
+	if err != nil {
+		t.Fatalf("Planning failed: %v", err)
+	}
+	if requests > 0 {
+		t.Errorf("Expected no Vault requests, got %d", requests)
+	}
+	if entries, _ := os.ReadDir(config.WorkDir); len(entries) > 0 {
+		t.Errorf("Expected nothing written to the work directory, got %d entries", len(entries))
+	}
+
+	sources := map[string]string{}
+	for _, cert := range plan.Certificates {
+		sources[cert.Name] = cert.Source
+	}
+	if sources["ca"] != "Vault PKI pki/cert/ca" || sources["admin"] != "Vault PKI pki/issue/kubernetes" {
+		t.Errorf("Expected the certificates listed from the Vault PKI, got %v", sources)
+	}
+}
+
+func TestApplyConfigChanges(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	ctx := context.Background()
+	sshClient := NewMockSSHClient()
+	for _, service := range append(append([]string{}, controllerServices...), workerServices...) {
+		sshClient.SetCommandResponse("sudo systemctl is-active "+service, "active\n")
+	}
+	sshClient.SetCommandResponse("kubectl get --raw=/readyz --server=https://10.240.0.10:6443 --kubeconfig /var/lib/kubernetes/admin.kubeconfig", "ok\n")
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	if err := cm.ApplyConfigChanges(ctx, config); err == nil {
+		t.Fatal("Expected applying changes without a setup to fail")
+	}
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Certificate generation failed: %v", err)
+	}
+	state := newSetupState(config)
+	state.Completed["validation"] = time.Now()
+	if err := state.save(); err != nil {
+		t.Fatalf("Failed to save setup state: %v", err)
+	}
+
+	t.Run("Rejected changes", func(t *testing.T) {
+		changed := config
+		changed.PodCIDR = "10.100.0.0/16"
+		changed.KubernetesVersion = "v1.27.0"
+		err := cm.ApplyConfigChanges(ctx, changed)
+		if err == nil || !strings.Contains(err.Error(), "pod_cidr") || !strings.Contains(err.Error(), "upgrade the cluster") {
+			t.Errorf("Expected the pod CIDR and version changes to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("No changes", func(t *testing.T) {
+		if err := cm.ApplyConfigChanges(ctx, config); err != nil {
+			t.Fatalf("Applying an unchanged config failed: %v", err)
+		}
+		if executed := sshClient.GetExecutedCommands(); len(executed) > 0 {
+			t.Errorf("Expected no commands for an unchanged config, got %v", executed)
+		}
+	})
+
+	t.Run("Unit changes", func(t *testing.T) {
+		changed := config
+		changed.Systemd.Services = map[string]UnitConfig{"kubelet": {LimitNOFILE: "65536"}}
+		if err := cm.ApplyConfigChanges(ctx, changed); err != nil {
+			t.Fatalf("Applying the kubelet limit failed: %v", err)
+		}
+		executed := strings.Join(sshClient.GetExecutedCommands(), "\n")
+		for _, want := range []string{"10.240.0.20: sudo systemctl restart kubelet", "10.240.0.21: sudo systemctl restart kubelet"} {
+			if !strings.Contains(executed, want) {
+				t.Errorf("Expected %q in:\n%s", want, executed)
+			}
+		}
+		if strings.Contains(executed, "10.240.0.10: sudo systemctl restart") || strings.Contains(executed, "restart kubelet kube-proxy") {
+			t.Errorf("Expected only the kubelets to restart, got:\n%s", executed)
+		}
+		if !strings.Contains(sshClient.filesUploaded["/etc/systemd/system/kubelet.service"], "LimitNOFILE=65536") {
+			t.Error("Expected the changed kubelet unit to be uploaded")
+		}
+		recorded, err := LoadSetupState(config.WorkDir)
+		if err != nil || recorded.Config == nil || recorded.Config.Systemd.Services["kubelet"].LimitNOFILE != "65536" {
+			t.Errorf("Expected the applied config to be recorded, got %v", err)
+		}
+	})
+
+	t.Run("API server names and addons", func(t *testing.T) {
+		before, err := os.ReadFile(filepath.Join(config.WorkDir, "kubernetes.pem"))
+		if err != nil {
+			t.Fatalf("Failed to read kubernetes.pem: %v", err)
+		}
+		sshClient.commands = nil
+		changed := cm.config
+		changed.Controller.PublicAddress = "k8s.example.com"
+		changed.CoreDNS.Replicas = 3
+		if err := cm.ApplyConfigChanges(ctx, changed); err != nil {
+			t.Fatalf("Applying the public address failed: %v", err)
+		}
+		after, _ := os.ReadFile(filepath.Join(config.WorkDir, "kubernetes.pem"))
+		if string(after) == string(before) || sshClient.filesUploaded["/var/lib/kubernetes/kubernetes.pem"] != string(after) {
+			t.Error("Expected the API server certificate to be reissued and copied to the controller")
+		}
+		executed := strings.Join(sshClient.GetExecutedCommands(), "\n")
+		for _, want := range []string{
+			"10.240.0.10: sudo systemctl restart kube-apiserver",
+			"10.240.0.10: kubectl apply -f /tmp/coredns.yaml --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		} {
+			if !strings.Contains(executed, want) {
+				t.Errorf("Expected %q in:\n%s", want, executed)
+			}
+		}
+		if strings.Contains(executed, "restart kubelet") {
+			t.Errorf("Expected the workers to be left alone, got:\n%s", executed)
+		}
+	})
+}
+
+func TestSetupReport(t *testing.T) {
+	config := createTestConfig()
+	metrics := newSetupMetrics(config)
+	start := metrics.started
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	for _, event := range []Event{
+		{Type: EventPhaseStarted, Phase: "Setting Up Worker Nodes", Time: at(0)},
+		{Type: EventStepStarted, Node: "worker-0", Time: at(1)},
+		{Type: EventStepStarted, Node: "worker-0", Time: at(5)},
+		{Type: EventStepStarted, Node: "worker-1", Time: at(11)},
+		{Type: EventStepStarted, Node: "", Time: at(41)},
+		{Type: EventPhaseStarted, Phase: "Validating Cluster", Time: at(45)},
+		{Type: EventStepStarted, Node: "controller-0", Time: at(45)},
+		{Type: EventSetupCompleted, Time: at(50)},
+	} {
+		metrics.handle(event)
+	}
+	metrics.retry("10.240.0.21")
+	metrics.retry("worker-1")
+	metrics.download("worker-0", 2048)
+
+	report := metrics.report(config.ClusterName)
+	if !report.Succeeded || report.Duration != 50*time.Second {
+		t.Errorf("Expected a successful setup of 50s, got %v in %s", report.Succeeded, report.Duration)
+	}
+	if len(report.Phases) != 2 || report.Phases[0].Duration != 45*time.Second || report.Phases[1].Duration != 5*time.Second {
+		t.Errorf("Unexpected phase timings: %+v", report.Phases)
+	}
+	if len(report.Nodes) != 3 || report.Nodes[0].Node != "worker-1" || report.Nodes[0].Duration != 30*time.Second {
+		t.Fatalf("Expected worker-1 to be the slowest node, got %+v", report.Nodes)
+	}
+	if report.Nodes[0].Retries != 2 || report.Retries != 2 || report.DownloadBytes != 2048 {
+		t.Errorf("Unexpected retries or downloads: %+v", report)
+	}
+	if worker0 := report.Nodes[1]; worker0.Node != "worker-0" || worker0.Phases["Setting Up Worker Nodes"] != 10*time.Second {
+		t.Errorf("Expected worker-0 to take 10s setting up workers, got %+v", worker0)
+	}
+
+	table := report.String()
+	for _, want := range []string{"completed in 50s, downloading 2.0 KiB with 2 retries", "Setting Up Worker Nodes  45s", "worker-1      30s       0 B         2"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("Expected %q in the report:\n%s", want, table)
+		}
+	}
+}
+
+func TestOSFamily(t *testing.T) {
+	for ids, want := range map[string]string{
+		"ubuntu debian":                OSFamilyDebian,
+		"rocky rhel centos fedora":     OSFamilyRHEL,
+		`amzn "centos rhel fedora"`:    OSFamilyRHEL,
+		"almalinux rhel centos fedora": OSFamilyRHEL,
+		"opensuse-leap suse opensuse":  "",
+	} {
+		if got := osFamilyOf(ids); got != want {
+			t.Errorf("osFamilyOf(%q) = %q, want %q", ids, got, want)
+		}
+	}
+
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	ctx := context.Background()
+	sshClient := NewMockSSHClient()
+	sshClient.SetCommandResponse(factsCommand, "arch=x86_64\nos=Rocky Linux 9.2 (Blue Onyx)\nos_id=rocky\nos_like=\"rhel centos fedora\"\nos_version=9.2\n")
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Failed to generate certificates: %v", err)
+	}
+	if err := cm.createConfigurations(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Failed to create configurations: %v", err)
+	}
+	worker := config.Workers[0]
+	if err := cm.checkNodeFacts(ctx, worker, RoleWorker, workerRequirements); err != nil {
+		t.Fatalf("Facts check failed: %v", err)
+	}
+	if family := cm.osFamily(ctx, worker); family != OSFamilyRHEL {
+		t.Fatalf("Expected a Rocky Linux worker to be detected as %s, got %s", OSFamilyRHEL, family)
+	}
+
+	if err := cm.setupSingleWorkerNode(ctx, config.WorkDir, worker); err != nil {
+		t.Fatalf("Worker setup failed: %v", err)
+	}
+	commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+	for _, want := range []string{
+		"sudo dnf -y install socat conntrack-tools ipset",
+		"sudo setenforce 0",
+		"--add-port=10250/tcp",
+		"--zone=trusted --add-source=10.200.0.0/16",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Expected %q among the commands of a RHEL worker", want)
+		}
+	}
+	if strings.Contains(commands, "apt-get") {
+		t.Error("Expected no apt-get on a RHEL worker")
+	}
+
+	cm.config.OSFamily = OSFamilyDebian
+	if family := cm.osFamily(ctx, worker); family != OSFamilyDebian {
+		t.Errorf("Expected the configured os_family to win over the facts, got %s", family)
+	}
+	config.OSFamily = "suse"
+	if err := validateOSFamily(config); err == nil {
+		t.Error("Expected an unknown os_family to be rejected")
+	}
+}
+
+func TestAirGappedBundle(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	config.AirGapped = true
+	ctx := context.Background()
+	sshClient := NewMockSSHClient()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+
+	// Fill the bundle so nothing is downloaded or pulled
+	bundle := func(file, content string) {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, download := range cm.bundleDownloads() {
+		file, err := cm.bundlePath(download)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle(file, "binary "+path.Base(download))
+	}
+	images, err := cm.bundleImages(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list images: %v", err)
+	}
+	for _, image := range images {
+		bundle(cm.bundleImagePath(image), "image "+image)
+	}
+	if !contains(images, defaultSandboxImage) || !contains(images, testImage) {
+		t.Errorf("Expected the pause and test images in the bundle, got %v", images)
+	}
+	if err := cm.PrepareBundle(ctx); err != nil {
+		t.Fatalf("Failed to prepare a complete bundle: %v", err)
+	}
+
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Failed to generate certificates: %v", err)
+	}
+	if err := cm.createConfigurations(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Failed to create configurations: %v", err)
+	}
+	sshClient.SetCommandResponse("(which socat conntrack ipset) 2>/dev/null && echo installed || true", "installed\n")
+	if err := cm.setupSingleWorkerNode(ctx, config.WorkDir, config.Workers[0]); err != nil {
+		t.Fatalf("Worker setup failed: %v", err)
+	}
+
+	commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+	if strings.Contains(commands, "wget") || strings.Contains(commands, "apt-get") {
+		t.Error("Expected an air-gapped worker to download nothing")
+	}
+	for _, want := range []string{
+		"cp /var/tmp/k8s-bundle/kubelet kubelet && sudo rm -f /var/tmp/k8s-bundle/kubelet",
+		"cp /var/tmp/k8s-bundle/runc.amd64 runc.amd64",
+		"sudo ctr -n k8s.io images import /var/tmp/k8s-bundle/registry.k8s.io_pause_3.9.tar",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Expected %q among the commands", want)
+		}
+	}
+	if content := sshClient.filesUploaded["/var/tmp/k8s-bundle/kubelet"]; content != "binary kubelet" {
+		t.Errorf("Expected the bundled kubelet to be pushed, got %q", content)
+	}
+	if !strings.Contains(cm.generateContainerdConfig(), `sandbox_image = "registry.k8s.io/pause:3.9"`) {
+		t.Error("Expected containerd to use the bundled pause image")
+	}
+
+	t.Run("Manifest images", func(t *testing.T) {
+		cm.config.CNIProvider = CNICalico
+		manifest, err := cm.bundlePath(cm.cni().downloads()[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle(manifest, "containers:\n  - name: calico-node\n    image: docker.io/calico/node:v3.26.1\n")
+		images, err := cm.bundleImages(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list images: %v", err)
+		}
+		if !contains(images, "docker.io/calico/node:v3.26.1") {
+			t.Errorf("Expected the images of the calico manifest, got %v", images)
+		}
+		if script := strings.Join(cm.cni().deployScript(), "\n"); !strings.Contains(script, "cp /var/tmp/k8s-bundle/calico.yaml /tmp/calico.yaml") {
+			t.Errorf("Expected the manifest to come from the bundle:\n%s", script)
+		}
+	})
+
+	config.CNIProvider = CNICilium
+	if err := validateAirGap(config); err == nil {
+		t.Error("Expected cilium to be rejected in air-gapped mode")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	config.Proxy = ProxyConfig{HTTPProxy: "http://proxy.corp:3128", HTTPSProxy: "http://proxy.corp:3128", NoProxy: "registry.corp, .corp"}
+	if err := validateProxy(config.Proxy); err != nil {
+		t.Fatalf("Expected a valid proxy: %v", err)
+	}
+	for _, proxy := range []ProxyConfig{{HTTPProxy: "proxy.corp:3128"}, {HTTPSProxy: "ftp://proxy.corp"}, {NoProxy: ".corp"}} {
+		if err := validateProxy(proxy); err == nil {
+			t.Errorf("Expected %+v to be rejected", proxy)
+		}
+	}
+
+	ctx := context.Background()
+	sshClient := NewMockSSHClient()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	noProxy := cm.noProxy()
+	for _, want := range []string{"127.0.0.1", config.Workers[0].IPAddress, config.ServiceCIDR, ".cluster.local", "registry.corp"} {
+		if !strings.Contains(noProxy, want) {
+			t.Errorf("Expected %s to bypass the proxy, got %s", want, noProxy)
+		}
+	}
+
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Failed to generate certificates: %v", err)
+	}
+	if err := cm.createConfigurations(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Failed to create configurations: %v", err)
+	}
+	if err := cm.setupSingleWorkerNode(ctx, config.WorkDir, config.Workers[0]); err != nil {
+		t.Fatalf("Worker setup failed: %v", err)
+	}
+	commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+	if !strings.Contains(commands, "env http_proxy='http://proxy.corp:3128' https_proxy='http://proxy.corp:3128' no_proxy='localhost,127.0.0.1,") {
+		t.Errorf("Expected downloads to go through the proxy:\n%s", commands)
+	}
+	for _, service := range proxyServices {
+		dropIn := sshClient.filesUploaded[proxyDropInPath(service)]
+		if !strings.Contains(dropIn, `Environment="HTTPS_PROXY=http://proxy.corp:3128"`) || !strings.Contains(dropIn, `Environment="NO_PROXY=`) {
+			t.Errorf("Expected the %s drop-in to set the proxy, got:\n%s", service, dropIn)
+		}
+	}
+
+	t.Run("Without a proxy", func(t *testing.T) {
+		cm.config.Proxy = ProxyConfig{}
+		if cm.wget() != "wget" || cm.generateProxyDropIn() != "[Service]\n" {
+			t.Errorf("Expected no proxy environment, got %q and %q", cm.wget(), cm.generateProxyDropIn())
+		}
+	})
+}
+
+// serveSSH runs an SSH server on a local port that accepts any key and hands
+// its channels to handle, returning its address.
+func serveSSH(t *testing.T, handle func(ssh.NewChannel)) string {
+	hostKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+		return nil, nil
+	}}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(requests)
+				for channel := range channels {
+					go handle(channel)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestSSHBastion(t *testing.T) {
+	// The node runs commands; the bastion only forwards connections to it
+	node := serveSSH(t, func(newChannel ssh.NewChannel) {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		defer channel.Close()
+		for request := range requests {
+			if request.Type != "exec" {
+				request.Reply(false, nil)
+				continue
+			}
+			request.Reply(true, nil)
+			io.WriteString(channel, "ran on the node\n")
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+			return
+		}
+	})
+	forwarded := make(chan string, 1)
+	bastion := serveSSH(t, func(newChannel ssh.NewChannel) {
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if newChannel.ChannelType() != "direct-tcpip" || ssh.Unmarshal(newChannel.ExtraData(), &target) != nil {
+			newChannel.Reject(ssh.UnknownChannelType, "only forwarding")
+			return
+		}
+		conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, fmt.Sprint(target.Port)))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			return
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			conn.Close()
+			return
+		}
+		go ssh.DiscardRequests(requests)
+		forwarded <- net.JoinHostPort(target.Host, fmt.Sprint(target.Port))
+		go func() {
+			io.Copy(channel, conn)
+			channel.Close()
+		}()
+		io.Copy(conn, channel)
+		conn.Close()
+	})
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ecdsa")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewSSHClient("ubuntu", keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetBastion(bastion, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if client.bastion.user != "ubuntu" {
+		t.Errorf("Expected the bastion user to default to the node user, got %q", client.bastion.user)
+	}
+	output, err := client.ExecuteCommand(context.Background(), node, "hostname")
+	if err != nil {
+		t.Fatalf("Command through the bastion failed: %v", err)
+	}
+	if output != "ran on the node\n" {
+		t.Errorf("Expected the node's output, got %q", output)
+	}
+	select {
+	case target := <-forwarded:
+		if target != node {
+			t.Errorf("Expected the bastion to forward to %s, got %s", node, target)
+		}
+	default:
+		t.Error("Expected the connection to go through the bastion")
+	}
+
+	if err := client.SetBastion(bastion, "jump", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected a missing bastion key to be rejected")
+	}
+
+	t.Run("Node access files", func(t *testing.T) {
+		config := createTestConfig()
+		config.BastionHost = "bastion.example.com:2222"
+		config.BastionUser = "jump"
+		cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+		sshConfig := cm.generateSSHConfig()
+		for _, want := range []string{
+			"Host " + config.ClusterName + "-bastion\n  HostName bastion.example.com\n  User jump\n  Port 2222\n",
+			"  ProxyJump " + config.ClusterName + "-bastion\n",
+		} {
+			if !strings.Contains(sshConfig, want) {
+				t.Errorf("Expected %q in ssh_config:\n%s", want, sshConfig)
+			}
+		}
+		if inventory := cm.generateInventory(); !strings.Contains(inventory, `ProxyCommand="ssh -W %h:%p -q -p 2222 -i `+config.SSHKey+` jump@bastion.example.com"`) {
+			t.Errorf("Expected the inventory to jump through the bastion:\n%s", inventory)
+		}
+	})
+}
+
+func TestSSHKeyPassphrase(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	node := serveSSH(t, func(newChannel ssh.NewChannel) {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		defer channel.Close()
+		for request := range requests {
+			if request.Type != "exec" {
+				request.Reply(false, nil)
+				continue
+			}
+			request.Reply(true, nil)
+			io.WriteString(channel, "ok\n")
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+			return
+		}
+	})
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ecdsa")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if !KeyNeedsPassphrase(keyPath) {
+		t.Error("Expected the encrypted key to need a passphrase")
+	}
+	if err := CheckKeyPassphrase(keyPath, "wrong"); err == nil {
+		t.Error("Expected a wrong passphrase to be rejected")
+	}
+	if err := CheckKeyPassphrase(keyPath, "secret"); err != nil {
+		t.Errorf("Expected the passphrase to decrypt the key, got %v", err)
+	}
+
+	client, err := NewSSHClient("ubuntu", keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ExecuteCommand(context.Background(), node, "hostname"); err == nil || !strings.Contains(err.Error(), "ssh_key_passphrase") {
+		t.Errorf("Expected the missing passphrase to be reported, got %v", err)
+	}
+	client.SetKeyPassphrase("secret")
+	if output, err := client.ExecuteCommand(context.Background(), node, "hostname"); err != nil || output != "ok\n" {
+		t.Errorf("Expected the decrypted key to connect, got %q, %v", output, err)
+	}
+
+	if _, err := NewSSHClient("ubuntu", ""); err == nil {
+		t.Error("Expected a client without a key file or an agent to be rejected")
+	}
+
+	t.Run("SSH agent", func(t *testing.T) {
+		keyring := agent.NewKeyring()
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+			t.Fatal(err)
+		}
+		socket := filepath.Join(t.TempDir(), "agent.sock")
+		listener, err := net.Listen("unix", socket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { listener.Close() })
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					agent.ServeAgent(keyring, conn)
+					conn.Close()
+				}()
+			}
+		}()
+		t.Setenv("SSH_AUTH_SOCK", socket)
+
+		client, err := NewSSHClient("ubuntu", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output, err := client.ExecuteCommand(context.Background(), node, "hostname"); err != nil || output != "ok\n" {
+			t.Errorf("Expected the agent's key to connect, got %q, %v", output, err)
+		}
+
+		// The encrypted key is skipped in favour of the agent
+		client, err = NewSSHClient("ubuntu", keyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.ExecuteCommand(context.Background(), node, "hostname"); err != nil {
+			t.Errorf("Expected the agent to stand in for the encrypted key, got %v", err)
+		}
+	})
+}
+
+func TestSCPCopy(t *testing.T) {
+	if _, err := exec.LookPath("scp"); err != nil {
+		t.Skipf("scp isn't installed: %v", err)
+	}
+	t.Setenv("SSH_AUTH_SOCK", "")
+	// The node runs commands with the local shell, so copies go through a
+	// real scp -t and land on this machine
+	node := serveSSH(t, func(newChannel ssh.NewChannel) {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		defer channel.Close()
+		for request := range requests {
+			var payload struct{ Command string }
+			if request.Type != "exec" || ssh.Unmarshal(request.Payload, &payload) != nil {
+				request.Reply(false, nil)
+				continue
+			}
+			request.Reply(true, nil)
+			cmd := exec.Command("sh", "-c", strings.ReplaceAll(payload.Command, "sudo ", ""))
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = channel, channel, channel.Stderr()
+			status := uint32(0)
+			if err := cmd.Run(); err != nil {
+				status = 1
+			}
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		}
+	})
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, remote := t.TempDir(), t.TempDir()
+	keyPath := filepath.Join(local, "id_ecdsa")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewSSHClient("ubuntu", keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(local, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	check := func(name, content string, mode os.FileMode) {
+		t.Helper()
+		path := filepath.Join(remote, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Expected %s to be copied: %v", name, err)
+		}
+		if string(data) != content {
+			t.Errorf("Expected %s to hold %q, got %q", name, content, data)
+		}
+		if info, _ := os.Stat(path); info.Mode().Perm() != mode {
+			t.Errorf("Expected %s to have mode %o, got %o", name, mode, info.Mode().Perm())
+		}
+	}
+	ctx := context.Background()
+
+	binary := write("kubelet", "\x7fELF binary", 0755)
+	if err := client.CopyFile(ctx, node, binary, filepath.Join(remote, "kubelet")); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	check("kubelet", "\x7fELF binary", 0755)
+
+	privateKey := write("ca-key.pem", "private key", 0600)
+	// The existing copy's mode is replaced too
+	if err := os.WriteFile(filepath.Join(remote, "ca-key.pem"), []byte("old key"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CopyFileOwned(ctx, node, privateKey, filepath.Join(remote, "ca-key.pem"), fmt.Sprint(os.Getuid())); err != nil {
+		t.Fatalf("CopyFileOwned failed: %v", err)
+	}
+	check("ca-key.pem", "private key", 0600)
+
+	write("manifests/pod.yaml", "kind: Pod", 0640)
+	write("manifests/nested/empty", "", 0600)
+	if err := os.Chmod(filepath.Join(local, "manifests"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CopyFile(ctx, node, filepath.Join(local, "manifests"), filepath.Join(remote, "static")); err != nil {
+		t.Fatalf("Copying a directory failed: %v", err)
+	}
+	check("static/pod.yaml", "kind: Pod", 0640)
+	check("static/nested/empty", "", 0600)
+	if info, err := os.Stat(filepath.Join(remote, "static")); err != nil || info.Mode().Perm() != 0750 {
+		t.Errorf("Expected the directory to keep mode 0750, got %v, %v", info, err)
+	}
+
+	if err := client.CopyContent(ctx, node, "[Service]\n", filepath.Join(remote, "kubelet.service")); err != nil {
+		t.Fatalf("CopyContent failed: %v", err)
+	}
+	check("kubelet.service", "[Service]\n", 0644)
+
+	if err := client.CopyFile(ctx, node, binary, filepath.Join(remote, "missing", "kubelet")); err == nil {
+		t.Error("Expected a copy into a missing directory to fail")
+	}
+}
+
+// streamingMockSSHClient streams canned output lines for every script.
+type streamingMockSSHClient struct {
+	*MockSSHClient
+	lines []string
+}
+
+func (m *streamingMockSSHClient) ExecuteCommandStream(ctx context.Context, host, command string, output func(line string)) (string, error) {
+	return m.ExecuteScriptStream(ctx, host, command, output)
+}
+
+func (m *streamingMockSSHClient) ExecuteScriptStream(ctx context.Context, host, script string, output func(line string)) (string, error) {
+	for _, line := range m.lines {
+		output(line)
+	}
+	return m.ExecuteScript(ctx, host, script)
+}
+
+func TestStreamedCommandOutput(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	node := serveSSH(t, func(newChannel ssh.NewChannel) {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		defer channel.Close()
+		for request := range requests {
+			if request.Type != "exec" {
+				request.Reply(false, nil)
+				continue
+			}
+			request.Reply(true, nil)
+			io.WriteString(channel, "Downloading kubelet\n")
+			io.WriteString(channel.Stderr(), "kubelet 10 MB\rkubelet 20 MB\r\n")
+			io.WriteString(channel, "done")
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+			return
+		}
+	})
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ecdsa")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewSSHClient("ubuntu", keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	output, err := client.ExecuteCommandStream(context.Background(), node, "wget kubelet", func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		t.Fatalf("Streamed command failed: %v", err)
+	}
+	if output != "Downloading kubelet\ndone" {
+		t.Errorf("Expected the whole stdout to be returned, got %q", output)
+	}
+	// stdout and stderr are read concurrently, so only their own order is kept
+	sort.Strings(lines)
+	expected := []string{"Downloading kubelet", "done", "kubelet 10 MB", "kubelet 20 MB"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected lines %q, got %q", expected, lines)
+	}
+
+	t.Run("Setup log", func(t *testing.T) {
+		config := createTestConfig()
+		logger := NewMockLogger()
+		sshClient := &streamingMockSSHClient{MockSSHClient: NewMockSSHClient(), lines: []string{"Saving to: kubelet"}}
+		cm := NewClusterManager(config, logger, sshClient, NewCertificateManager(), NewMockProgressReporter())
+		worker := config.Workers[0]
+		if _, err := cm.executeScriptStreamed(context.Background(), worker, "wget kubelet"); err != nil {
+			t.Fatal(err)
+		}
+		if logs := strings.Join(logger.GetLogs(), "\n"); !strings.Contains(logs, "INFO: "+worker.Name+" | Saving to: kubelet") {
+			t.Errorf("Expected the output line in the setup log, got:\n%s", logs)
+		}
+
+		// Clients that can't stream run the script as usual
+		cm = NewClusterManager(config, logger, NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+		if _, err := cm.executeScriptStreamed(context.Background(), worker, "wget kubelet"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestSSHCommandCanceled(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	// The node never finishes a command; it reports the signals it receives
+	signals := make(chan string, 1)
+	node := serveSSH(t, func(newChannel ssh.NewChannel) {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		defer channel.Close()
+		for request := range requests {
+			switch request.Type {
+			case "exec":
+				request.Reply(true, nil)
+			case "signal":
+				var payload struct{ Signal string }
+				ssh.Unmarshal(request.Payload, &payload)
+				signals <- payload.Signal
+			default:
+				request.Reply(false, nil)
+			}
+		}
+	})
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ecdsa")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewSSHClient("ubuntu", keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = client.ExecuteCommand(ctx, node, "sleep infinity")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the command to be stopped at its deadline, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Expected the command to stop promptly, took %v", elapsed)
+	}
+	select {
+	case signal := <-signals:
+		if signal != "KILL" {
+			t.Errorf("Expected the command to be killed, got SIG%s", signal)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the node to receive a kill signal")
+	}
+
+	// A canceled context doesn't start scripts or copies
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.ExecuteScript(ctx, node, "sleep infinity"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the script not to start, got %v", err)
+	}
+	if err := client.CopyContent(ctx, node, "data", "/tmp/data"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the copy not to start, got %v", err)
+	}
+}
+
+func TestSSHConnectionReuse(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	node := serveSSH(t, func(newChannel ssh.NewChannel) {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		defer channel.Close()
+		for request := range requests {
+			if request.Type != "exec" {
+				request.Reply(false, nil)
+				continue
+			}
+			request.Reply(true, nil)
+			io.WriteString(channel, "ok")
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+			return
+		}
+	})
+
+	// A proxy in front of the node counts the connections and can cut them
+	var mu sync.Mutex
+	var conns []net.Conn
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { proxy.Close() })
+	go func() {
+		for {
+			conn, err := proxy.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("tcp", node)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+			go func() {
+				io.Copy(upstream, conn)
+				upstream.Close()
+			}()
+			go func() {
+				io.Copy(conn, upstream)
+				conn.Close()
+			}()
+		}
+	}()
+	connections := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(conns)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ecdsa")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewSSHClient("ubuntu", keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	host := proxy.Addr().String()
+	run := func() {
+		t.Helper()
+		if output, err := client.ExecuteCommand(context.Background(), host, "hostname"); err != nil || output != "ok" {
+			t.Fatalf("Expected the command to succeed, got %q, %v", output, err)
+		}
+	}
+
+	t.Run("Commands share one connection", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			run()
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client.ExecuteCommand(context.Background(), host, "hostname")
+			}()
+		}
+		wg.Wait()
+		if connections() != 1 {
+			t.Errorf("Expected 1 connection, got %d", connections())
+		}
+	})
+
+	t.Run("Lost connections are replaced", func(t *testing.T) {
+		mu.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+		run()
+		if connections() != 2 {
+			t.Errorf("Expected a new connection, got %d in total", connections())
+		}
+	})
+
+	t.Run("Closing the client closes its connections", func(t *testing.T) {
+		client.Close()
+		run()
+		if connections() != 3 {
+			t.Errorf("Expected a new connection after closing, got %d in total", connections())
+		}
+	})
+}
+
+func TestKubeVIP(t *testing.T) {
+	config := createTestConfig()
+	config.Controller = Node{}
+	config.Controllers = []Node{
+		{Name: "controller-0", IPAddress: "10.240.0.10", Hostname: "controller-0"},
+		{Name: "controller-1", IPAddress: "10.240.0.11", Hostname: "controller-1"},
+	}
+	config.ControlPlaneEndpoint = "10.240.0.100"
+	config.KubeVIP = KubeVIPConfig{Enabled: true}
+	resolveControlPlane(&config)
+	if err := validateControlPlane(config); err != nil {
+		t.Fatalf("Valid kube-vip config rejected: %v", err)
+	}
+	config.WorkDir = t.TempDir()
+
+	sshClient := NewMockSSHClient()
+	for _, service := range []string{"etcd", "kube-apiserver", "kube-controller-manager", "kube-scheduler", "kube-vip"} {
+		sshClient.SetCommandResponse("sudo systemctl is-active "+service, "active")
+	}
+	sshClient.SetCommandResponse(`ip -o -4 addr show | awk '$4 ~ /^10\.240\.0\.11\// {print $2; exit}'`, "ens5\n")
+	sshClient.SetCommandResponse("kubectl get --raw=/readyz --server=https://10.240.0.100:6443 --kubeconfig /var/lib/kubernetes/admin.kubeconfig", "ok")
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	ctx := context.Background()
+
+	if err := cm.generateCertificates(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Certificate generation failed: %v", err)
+	}
+	certData, _ := os.ReadFile(filepath.Join(config.WorkDir, "kubernetes.pem"))
+	block, _ := pem.Decode(certData)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse API server certificate: %v", err)
+	}
+	if err := cert.VerifyHostname("10.240.0.100"); err != nil {
+		t.Errorf("API server certificate doesn't cover the virtual IP: %v", err)
+	}
+
+	if err := cm.createConfigurations(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Configuration creation failed: %v", err)
+	}
+	for file, server := range map[string]string{
+		"worker-0.kubeconfig":   "https://10.240.0.100:6443",
+		"kube-proxy.kubeconfig": "https://10.240.0.100:6443",
+		"admin.kubeconfig":      "https://10.240.0.100:6443",
+		kubeVIPKubeconfig:       "https://127.0.0.1:6443",
+	} {
+		data, _ := os.ReadFile(filepath.Join(config.WorkDir, file))
+		if !strings.Contains(string(data), "server: "+server) {
+			t.Errorf("%s doesn't point at %s", file, server)
+		}
+	}
+
+	if err := cm.setupControlPlane(ctx, config.WorkDir); err != nil {
+		t.Fatalf("Control plane setup failed: %v", err)
+	}
+	commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+	for _, controller := range config.Controllers {
+		for _, want := range []string{
+			controller.IPAddress + ": sudo mv kube-vip-linux-amd64 /usr/local/bin/kube-vip",
+			controller.IPAddress + ": sudo systemctl daemon-reload && sudo systemctl enable kube-vip",
+		} {
+			if !strings.Contains(commands, want) {
+				t.Errorf("Expected %q", want)
+			}
+		}
+	}
+	if kubeconfig := sshClient.filesUploaded["/var/lib/kubernetes/"+kubeVIPKubeconfig]; !strings.Contains(kubeconfig, "client-certificate-data: ") {
+		t.Errorf("Expected the kube-vip kubeconfig with embedded certificates on the controllers, got:\n%s", kubeconfig)
+	}
+	if !strings.Contains(commands, "--server=https://10.240.0.100:6443") {
+		t.Error("Expected setup to wait for the virtual IP to answer")
+	}
+	service := cm.generateKubeVIPService("ens5")
+	for _, want := range []string{"Environment=address=10.240.0.100", "Environment=vip_interface=ens5", "--k8sConfigPath=/var/lib/kubernetes/kube-vip.kubeconfig"} {
+		if !strings.Contains(service, want) {
+			t.Errorf("kube-vip service doesn't contain %q", want)
+		}
+	}
+	if marker := cm.bakeMarker(RoleController); !strings.Contains(marker, "kube-vip="+defaultKubeVIPVersion) {
+		t.Errorf("Expected the baked controller image to include kube-vip, got %q", marker)
+	}
+
+	for name, mutate := range map[string]func(*ClusterConfig){
+		"Without an endpoint":     func(c *ClusterConfig) { c.ControlPlaneEndpoint = "" },
+		"With a host name":        func(c *ClusterConfig) { c.ControlPlaneEndpoint = "k8s-api.internal" },
+		"With a node's address":   func(c *ClusterConfig) { c.ControlPlaneEndpoint = "10.240.0.11" },
+		"With settings, disabled": func(c *ClusterConfig) { c.KubeVIP = KubeVIPConfig{Interface: "eth0"} },
+	} {
+		invalid := config
+		mutate(&invalid)
+		if err := validateControlPlane(invalid); err == nil {
+			t.Errorf("%s: expected the kube-vip config to be rejected", name)
+		}
+	}
+}
+
+func TestSSHStats(t *testing.T) {
+	config := createTestConfig()
+	metrics := newSetupMetrics(config)
+	run := func(host string, latency time.Duration, times int) (slow bool) {
+		for i := 0; i < times; i++ {
+			_, _, flagged, _ := metrics.command(host, latency, nil)
+			slow = slow || flagged
+		}
+		return slow
+	}
+
+	if run("10.240.0.10", 40*time.Millisecond, 10) || run("10.240.0.20", 60*time.Millisecond, 10) {
+		t.Fatalf("Expected nodes as fast as their peers not to be slow")
+	}
+	if !run("10.240.0.21", 300*time.Millisecond, 10) {
+		t.Errorf("Expected worker-1 to be slow at 5x the latency of its peers")
+	}
+	if run("10.240.0.21", 300*time.Millisecond, 10) {
+		t.Errorf("Expected worker-1 to be flagged only once")
+	}
+
+	exit := fmt.Errorf("command failed: %w", &ssh.ExitError{})
+	for i := 0; i < 5; i++ {
+		if _, _, _, flaky := metrics.command("worker-0", 50*time.Millisecond, exit); flaky {
+			t.Fatalf("Expected commands exiting non-zero not to make worker-0 flaky")
+		}
+	}
+	var flagged []bool
+	for i := 0; i < sshFlakyErrors; i++ {
+		_, stats, _, flaky := metrics.command("worker-0", 5*time.Second, fmt.Errorf("connection reset by peer"))
+		flagged = append(flagged, flaky)
+		if i == sshFlakyErrors-1 && (stats.Failures != 8 || stats.ConnectionErrors != 3 || stats.MaxLatency != 5*time.Second) {
+			t.Errorf("Unexpected stats of worker-0: %+v", stats)
+		}
+	}
+	if fmt.Sprint(flagged) != "[false false true]" {
+		t.Errorf("Expected worker-0 to turn flaky on its third connection error, got %v", flagged)
+	}
+
+	// Slow on its own, without peers to compare to
+	alone := newSetupMetrics(config)
+	for i := 0; i < sshSlowMinCommands; i++ {
+		if _, _, slow, _ := alone.command("controller-0", 1500*time.Millisecond, nil); slow != (i == sshSlowMinCommands-1) {
+			t.Errorf("Command %d: expected controller-0 to be flagged after %d commands", i+1, sshSlowMinCommands)
+		}
+	}
+
+	// Commands run through the cluster manager are recorded, unless canceled
+	sshClient := NewMockSSHClient()
+	logger := NewMockLogger()
+	cm := NewClusterManager(config, logger, sshClient, NewCertificateManager(), NewMockProgressReporter())
+	cm.metrics = metrics
+	cm.sshClient.ExecuteCommand(context.Background(), "10.240.0.10", "hostname")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cm.sshClient.ExecuteCommand(ctx, "10.240.0.10", "hostname")
+	sshClient.SetCommandError("uptime", fmt.Errorf("connection refused"))
+	for i := 0; i < sshFlakyErrors; i++ {
+		cm.sshClient.ExecuteCommand(context.Background(), "10.240.0.10", "uptime")
+	}
+	if warnings := strings.Join(logger.GetLogs(), "\n"); !strings.Contains(warnings, "WARN: controller-0 is flaky: 3 SSH commands failed to reach it") {
+		t.Errorf("Expected controller-0 to be reported flaky, got:\n%s", warnings)
+	}
+
+	report := metrics.report(config.ClusterName)
+	if fmt.Sprint(report.SlowNodes) != "[worker-1]" || fmt.Sprint(report.FlakyNodes) != "[controller-0 worker-0]" {
+		t.Errorf("Unexpected slow %v and flaky %v nodes", report.SlowNodes, report.FlakyNodes)
+	}
+	for _, node := range report.Nodes {
+		if node.Node == "controller-0" && node.SSH.Commands != 14 {
+			t.Errorf("Expected 14 commands on controller-0, got %+v", node.SSH)
+		}
+		if node.Node == "worker-1" && node.SSH.MedianLatency != 300*time.Millisecond {
+			t.Errorf("Expected a median latency of 300ms on worker-1, got %+v", node.SSH)
+		}
+	}
+	table := report.String()
+	for _, want := range []string{"COMMANDS  MEDIAN", "Slow SSH on worker-1;", "SSH connections to controller-0, worker-0 kept failing"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("Expected %q in the report:\n%s", want, table)
+		}
+	}
+}
+
+func TestNetworkValidation(t *testing.T) {
+	if err := validateNetwork(createTestConfig()); err != nil {
+		t.Fatalf("Expected the test config's network to be valid, got %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		edit func(*ClusterConfig)
+		want string
+	}{
+		"invalid pod CIDR":        {func(c *ClusterConfig) { c.PodCIDR = "10.200.0.0" }, `pod_cidr "10.200.0.0" is not a valid CIDR`},
+		"invalid service CIDR":    {func(c *ClusterConfig) { c.ServiceCIDR = "10.32.0.0/33" }, `service_cidr "10.32.0.0/33" is not a valid CIDR`},
+		"service inside pods":     {func(c *ClusterConfig) { c.ServiceCIDR = "10.200.128.0/24" }, "pod_cidr 10.200.0.0/16 overlaps service_cidr"},
+		"DNS not an address":      {func(c *ClusterConfig) { c.ClusterDNS = "kube-dns" }, `cluster_dns "kube-dns" is not an IP address`},
+		"DNS outside services":    {func(c *ClusterConfig) { c.ClusterDNS = "10.33.0.10" }, "cluster_dns 10.33.0.10 is outside service_cidr 10.32.0.0/24"},
+		"invalid worker CIDR":     {func(c *ClusterConfig) { c.Workers[1].PodCIDR = "10.200.1.0/24/8" }, `pod_cidr "10.200.1.0/24/8" of worker-1 is not a valid CIDR`},
+		"worker outside pods":     {func(c *ClusterConfig) { c.Workers[1].PodCIDR = "10.201.1.0/24" }, "pod_cidr 10.201.1.0/24 of worker-1 is outside the cluster pod_cidr 10.200.0.0/16"},
+		"worker larger than pods": {func(c *ClusterConfig) { c.Workers[1].PodCIDR = "10.200.0.0/15" }, "of worker-1 is outside the cluster pod_cidr"},
+		"workers overlap":         {func(c *ClusterConfig) { c.Workers[1].PodCIDR = "10.200.0.128/25" }, "pod_cidr 10.200.0.128/25 of worker-1 overlaps 10.200.0.0/24 of worker-0"},
+	} {
+		config := createTestConfig()
+		config.Workers = append([]Node{}, config.Workers...)
+		tc.edit(&config)
+		err := validateNetwork(config)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.want, err)
+		}
+	}
+
+	// A worker's range overlapping the services is caught even when the
+	// cluster's pod range is checked separately
+	podNet, serviceNet := mustParseCIDR(t, "10.0.0.0/8"), mustParseCIDR(t, "10.32.0.0/24")
+	err := validateNodeCIDR(Node{Name: "worker-2", PodCIDR: "10.32.0.0/25"}, podNet, serviceNet, nil)
+	if err == nil || !strings.Contains(err.Error(), "overlaps service_cidr 10.32.0.0/24") {
+		t.Errorf("Expected the worker to overlap the services, got %v", err)
+	}
+
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+	err = cm.AddWorkerNode(context.Background(), Node{Name: "worker-2", IPAddress: "10.240.0.22", PodCIDR: "10.200.1.0/25"})
+	if err == nil || !strings.Contains(err.Error(), "overlaps 10.200.1.0/24 of worker-1") {
+		t.Errorf("Expected AddWorkerNode to reject an overlapping pod CIDR, got %v", err)
+	}
+}
+
+func mustParseCIDR(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return network
+}
+
+func TestPodCIDRAllocation(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	config.Workers = []Node{
+		{Name: "worker-0", IPAddress: "10.240.0.20"},
+		{Name: "worker-1", IPAddress: "10.240.0.21", PodCIDR: "10.200.1.0/24"},
+		{Name: "worker-2", IPAddress: "10.240.0.22"},
+	}
+	configPath := filepath.Join(config.WorkDir, "cluster-config.yaml")
+	if err := SaveConfig(config, configPath); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	loaded, err := LoadClusterConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	want := []string{"10.200.0.0/24", "10.200.1.0/24", "10.200.2.0/24"}
+	for i, worker := range loaded.Workers {
+		if worker.PodCIDR != want[i] {
+			t.Errorf("Expected %s to get %s, got %q", worker.Name, want[i], worker.PodCIDR)
+		}
+	}
+
+	// A worker inserted before the others doesn't move their allocations
+	config.Workers = append([]Node{{Name: "worker-3", IPAddress: "10.240.0.23"}}, config.Workers...)
+	if err := SaveConfig(config, configPath); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if loaded, err = LoadClusterConfig(configPath); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	want = []string{"10.200.3.0/24", "10.200.0.0/24", "10.200.1.0/24", "10.200.2.0/24"}
+	for i, worker := range loaded.Workers {
+		if worker.PodCIDR != want[i] {
+			t.Errorf("Expected %s to keep %s, got %q", worker.Name, want[i], worker.PodCIDR)
+		}
+	}
+
+	// Added workers are allocated from the same record, and removed ones
+	// release their subnet
+	cm := NewClusterManager(loaded, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+	ctx := context.Background()
+	if err := cm.generateCertificates(ctx, loaded.WorkDir); err != nil {
+		t.Fatalf("Certificate generation failed: %v", err)
+	}
+	if err := cm.createConfigurations(ctx, loaded.WorkDir); err != nil {
+		t.Fatalf("Configuration creation failed: %v", err)
+	}
+	if err := cm.AddWorkerNode(ctx, Node{Name: "worker-4", IPAddress: "10.240.0.24"}); err != nil {
+		t.Fatalf("Adding worker failed: %v", err)
+	}
+	if added := cm.config.Workers[len(cm.config.Workers)-1]; added.PodCIDR != "10.200.4.0/24" {
+		t.Errorf("Expected worker-4 to get 10.200.4.0/24, got %q", added.PodCIDR)
+	}
+	if err := cm.RemoveWorkerNode(ctx, "worker-0"); err != nil {
+		t.Fatalf("Removing worker failed: %v", err)
+	}
+	allocations, err := loadPodCIDRAllocations(loaded.WorkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := allocations["worker-0"]; ok || allocations["worker-4"] != "10.200.4.0/24" || len(allocations) != 3 {
+		t.Errorf("Unexpected allocations after scaling: %v", allocations)
+	}
+
+	// Workers no longer configured keep their subnet reserved
+	workers := []Node{{Name: "worker-5"}}
+	if err := assignPodCIDRs(workers, map[string]string{"gone": "10.200.0.0/25"}, mustParseCIDR(t, "10.200.0.0/24"), 25); err != nil {
+		t.Fatal(err)
+	}
+	if workers[0].PodCIDR != "10.200.0.128/25" {
+		t.Errorf("Expected the reserved subnet to be skipped, got %q", workers[0].PodCIDR)
+	}
+	workers = []Node{{Name: "worker-6"}}
+	err = assignPodCIDRs(workers, map[string]string{"a": "10.200.0.0/25", "b": "10.200.0.128/25"}, mustParseCIDR(t, "10.200.0.0/24"), 25)
+	if err == nil || !strings.Contains(err.Error(), "no free /25 left for worker-6") {
+		t.Errorf("Expected an exhausted pod CIDR to be reported, got %v", err)
+	}
+
+	config = createTestConfig()
+	config.NodeCIDRMaskSize = 8
+	if err := validateNetwork(config); err == nil || !strings.Contains(err.Error(), "node_cidr_mask_size 8 must be between 16 and 30") {
+		t.Errorf("Expected a mask size shorter than pod_cidr's to be rejected, got %v", err)
+	}
+}
+
+func TestNodeLabelsAndTaints(t *testing.T) {
+	config := createTestConfig()
+	config.Workers[0].Labels = map[string]string{"topology.kubernetes.io/zone": "eu-1a", "node-role.kubernetes.io/gpu": "", "disk": "ssd"}
+	config.Workers[0].Taints = map[string]string{"nvidia.com/gpu": "present:NoSchedule", "dedicated": "NoExecute"}
+	if err := validateNodeLabels(config); err != nil {
+		t.Fatalf("Expected valid labels and taints, got %v", err)
+	}
+
+	tests := map[string]struct {
+		edit func(*ClusterConfig)
+		want string
+	}{
+		"bad label key":    {func(c *ClusterConfig) { c.Workers[1].Labels = map[string]string{"Example.com/gpu": "a"} }, `prefix "Example.com" is not a DNS subdomain`},
+		"bad label value":  {func(c *ClusterConfig) { c.Workers[1].Labels = map[string]string{"disk": "fast ssd"} }, `invalid value "fast ssd"`},
+		"bad taint effect": {func(c *ClusterConfig) { c.Workers[1].Taints = map[string]string{"gpu": "yes:Never"} }, `effect "Never"`},
+		"controller":       {func(c *ClusterConfig) { c.Controller.Labels = map[string]string{"disk": "ssd"} }, "controller-0 runs no kubelet"},
+	}
+	for name, tc := range tests {
+		config := createTestConfig()
+		tc.edit(&config)
+		if err := validateNodeLabels(config); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.want, err)
+		}
+	}
+
+	// The kubelet registers with the labels it may set and every taint
+	cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+	service := cm.generateKubeletService(config.Workers[0])
+	for _, want := range []string{
+		"--node-labels=disk=ssd,topology.kubernetes.io/zone=eu-1a \\",
+		"--register-with-taints=dedicated:NoExecute,nvidia.com/gpu=present:NoSchedule \\",
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("Expected the kubelet service to contain %q:\n%s", want, service)
+		}
+	}
+	if strings.Contains(service, "node-role") {
+		t.Errorf("Expected the kubelet not to set a node-role label:\n%s", service)
+	}
+	if service := cm.generateKubeletService(config.Workers[1]); strings.Contains(service, "--node-labels") || strings.Contains(service, "--register-with-taints") {
+		t.Errorf("Expected no registration flags for a worker without labels:\n%s", service)
+	}
+
+	// Every label and taint is applied once the worker is Ready
+	sshClient := NewMockSSHClient()
+	cm.sshClient = sshClient
+	if err := cm.applyNodeLabels(context.Background(), config.Workers[0]); err != nil {
+		t.Fatalf("Applying labels failed: %v", err)
+	}
+	commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+	for _, want := range []string{
+		"10.240.0.10: kubectl label node worker-0 disk=ssd node-role.kubernetes.io/gpu= topology.kubernetes.io/zone=eu-1a --overwrite",
+		"10.240.0.10: kubectl taint node worker-0 dedicated:NoExecute nvidia.com/gpu=present:NoSchedule --overwrite",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Expected %q, got:\n%s", want, commands)
+		}
+	}
+	sshClient = NewMockSSHClient()
+	cm.sshClient = sshClient
+	if err := cm.applyNodeLabels(context.Background(), config.Workers[1]); err != nil || len(sshClient.GetExecutedCommands()) != 0 {
+		t.Errorf("Expected nothing to run for a worker without labels, got %v, %v", sshClient.GetExecutedCommands(), err)
+	}
+}
+
+func TestAddons(t *testing.T) {
+	config := createTestConfig()
+	config.Addons = []string{AddonMetricsServer, AddonCertManager}
+	if err := validateAddons(config); err != nil {
+		t.Fatalf("Expected valid addons, got %v", err)
+	}
+	tests := map[string]struct {
+		edit func(*ClusterConfig)
+		want string
+	}{
+		"unknown":   {func(c *ClusterConfig) { c.Addons = []string{"traefik"} }, `unknown addon "traefik"; use cert-manager, ingress-nginx, metrics-server`},
+		"duplicate": {func(c *ClusterConfig) { c.Addons = []string{AddonIngressNginx, AddonIngressNginx} }, "ingress-nginx is listed twice"},
+		"provider":  {func(c *ClusterConfig) { c.CNIProvider = CNICalico }, "addons need the bridge provider"},
+	}
+	for name, tc := range tests {
+		config := config
+		tc.edit(&config)
+		if err := validateAddons(config); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.want, err)
+		}
+	}
+
+	ctx := context.Background()
+	sshClient := NewMockSSHClient()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	if err := cm.installAddons(ctx); err != nil {
+		t.Fatalf("Installing addons failed: %v", err)
+	}
+	commands := strings.Join(sshClient.GetExecutedCommands(), "\n")
+	for _, want := range []string{
+		"-O /tmp/addon-metrics-server-0.yaml 'https://github.com/kubernetes-sigs/metrics-server/releases/download/v0.6.4/components.yaml'",
+		"10.240.0.10: kubectl apply -f /tmp/addon-metrics-server-0.yaml",
+		"10.240.0.10: kubectl wait --for=condition=Available deployment/metrics-server -n kube-system --timeout=5m0s",
+		"10.240.0.10: kubectl apply -f /tmp/addon-cert-manager-0.yaml",
+		"10.240.0.10: kubectl wait --for=condition=Available deployment/cert-manager-webhook -n cert-manager --timeout=5m0s",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Expected %q, got:\n%s", want, commands)
+		}
+	}
+	if strings.Index(commands, "addon-metrics-server") > strings.Index(commands, "addon-cert-manager") {
+		t.Error("Expected the addons to be installed in the listed order")
+	}
+
+	// Health checks don't wait, and report each addon on its own
+	sshClient.SetCommandError("kubectl wait --for=condition=Available deployment/cert-manager-cainjector -n cert-manager --timeout=0s --kubeconfig /var/lib/kubernetes/admin.kubeconfig",
+		fmt.Errorf("timed out waiting for the condition"))
+	health := cm.GetAddonHealth(ctx)
+	if len(health) != 2 || !health[0].Healthy() || health[1].Healthy() || !strings.Contains(health[1].Err.Error(), "cert-manager/cert-manager-cainjector") {
+		t.Errorf("Expected only cert-manager to be unhealthy, got %+v", health)
+	}
+
+	sshClient.commands = nil
+	addon, _ := LookupAddon(AddonIngressNginx)
+	if err := cm.RemoveAddon(ctx, addon); err != nil {
+		t.Fatalf("Removing ingress-nginx failed: %v", err)
+	}
+	if commands := strings.Join(sshClient.GetExecutedCommands(), "\n"); !strings.Contains(commands, "kubectl delete -f /tmp/addon-ingress-nginx-0.yaml --ignore-not-found") {
+		t.Errorf("Expected the ingress-nginx manifest to be deleted, got:\n%s", commands)
+	}
+
+	added, dropped := addonChanges([]string{AddonMetricsServer, AddonIngressNginx}, []string{AddonMetricsServer, AddonCertManager})
+	if len(added) != 1 || added[0].Name() != AddonCertManager || len(dropped) != 1 || dropped[0].Name() != AddonIngressNginx {
+		t.Errorf("Expected cert-manager added and ingress-nginx dropped, got %v and %v", added, dropped)
+	}
+	if downloads := cm.bundleDownloads(); !contains(downloads, addonCatalog[AddonCertManager].Manifests()[0]) {
+		t.Error("Expected air-gapped bundles to include the addon manifests")
+	}
+}
+
+func TestSetupLogFile(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = t.TempDir()
+	config.Logging = LoggingConfig{Level: "warn", Format: LogFormatJSON}
+	if err := validateLogging(config); err != nil {
+		t.Fatalf("Expected valid logging settings, got %v", err)
+	}
+	for _, logging := range []LoggingConfig{{Level: "verbose"}, {Format: "xml"}} {
+		invalid := config
+		invalid.Logging = logging
+		if err := validateLogging(invalid); err == nil {
+			t.Errorf("Expected %+v to be rejected", logging)
+		}
+	}
+
+	logger := NewMockLogger()
+	cm := NewClusterManager(config, logger, NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+	if err := cm.LogToFile(); err != nil {
+		t.Fatalf("LogToFile failed: %v", err)
+	}
+	cm.logger.Info("Installing etcd")
+	cm.logger.Warn("Disk %s is %d%% full", "/var", 91)
+	cm.publish(Event{Type: EventSetupFailed, Err: fmt.Errorf("etcd didn't start")})
+	if err := cm.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The host's logger still gets every message
+	if logs := strings.Join(logger.GetLogs(), "\n"); !strings.Contains(logs, "Installing etcd") {
+		t.Errorf("Expected the info message in the host's logs, got:\n%s", logs)
+	}
+
+	data, err := os.ReadFile(filepath.Join(config.WorkDir, SetupLogFile))
+	if err != nil {
+		t.Fatalf("Expected the setup log in the work directory: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected the warning and the error only, got:\n%s", data)
+	}
+	var record struct {
+		Level   string `json:"level"`
+		Msg     string `json:"msg"`
+		Cluster string `json:"cluster"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %q: %v", lines[0], err)
+	}
+	if record.Level != "WARN" || record.Msg != "Disk /var is 91% full" || record.Cluster != config.ClusterName {
+		t.Errorf("Unexpected warning record %+v", record)
+	}
+	if !strings.Contains(lines[1], `"level":"ERROR"`) || !strings.Contains(lines[1], "etcd didn't start") {
+		t.Errorf("Expected the setup failure in the log, got %q", lines[1])
+	}
+
+	// Text records leave out messages below the level
+	var buf strings.Builder
+	NewSlogLogger(&buf, LevelInfo, LogFormatText).Debug("hidden")
+	NewSlogLogger(&buf, LevelInfo, LogFormatText).Info("Worker %s joined", "worker-0")
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, `level=INFO msg="Worker worker-0 joined"`) {
+		t.Errorf("Unexpected text output %q", out)
+	}
+
+	config.Logging.File = "logs/run.log"
+	if path := SetupLogPath(config); path != filepath.Join(config.WorkDir, "logs", "run.log") {
+		t.Errorf("Expected the log file relative to the work directory, got %s", path)
+	}
+}
+
+func TestDestroyWithSetupLog(t *testing.T) {
+	config := createTestConfig()
+	config.WorkDir = filepath.Join(t.TempDir(), "work")
+	cm := NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+	if err := cm.LogToFile(); err != nil {
+		t.Fatalf("LogToFile failed: %v", err)
+	}
+	if err := cm.DestroyCluster(context.Background()); err != nil {
+		t.Fatalf("Cluster destruction failed: %v", err)
+	}
+	if _, err := os.Stat(config.WorkDir); !os.IsNotExist(err) {
+		t.Errorf("Expected the work directory to be removed, got %v", err)
+	}
+	if cm.logFile.file != nil {
+		t.Error("Expected the setup log to be closed before the work directory was removed")
+	}
+	if err := cm.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+
+	// A setup log outside the work directory records the whole destroy
+	config.WorkDir = filepath.Join(t.TempDir(), "work")
+	config.Logging.File = filepath.Join(t.TempDir(), "destroy.log")
+	cm = NewClusterManager(config, NewMockLogger(), NewMockSSHClient(), NewCertificateManager(), NewMockProgressReporter())
+	if err := cm.LogToFile(); err != nil {
+		t.Fatalf("LogToFile failed: %v", err)
+	}
+	if err := cm.DestroyCluster(context.Background()); err != nil {
+		t.Fatalf("Cluster destruction failed: %v", err)
+	}
+	cm.Close()
+	data, err := os.ReadFile(config.Logging.File)
+	if err != nil || !strings.Contains(string(data), "Cluster destroyed successfully") {
+		t.Errorf("Expected the end of the destroy in %s, got %q, %v", config.Logging.File, data, err)
+	}
+}
+
+func TestDriftAndCertificateAlerts(t *testing.T) {
+	var received []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode webhook body: %v", err)
+		}
+		received = append(received, body)
+	}))
+	defer server.Close()
+
+	config := createTestConfig()
+	config.Workers = nil
+	config.WorkDir = t.TempDir()
+	config.Notifications = NotificationConfig{Webhooks: []WebhookConfig{{URL: server.URL}}}
+
+	// A certificate expiring in 10 days
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now(), NotAfter: time.Now().Add(10 * 24 * time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(config.WorkDir, "admin.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The controller holds the generated files, except for an edited scheduler unit
+	sshClient := NewMockSSHClient()
+	cm := NewClusterManager(config, NewMockLogger(), sshClient, NewCertificateManager(), NewMockProgressReporter())
+	var paths, sums []string
+	for _, file := range cm.nodeFiles(config.Controller) {
+		content := file.content
+		if file.service == "kube-scheduler" {
+			content += "# edited by hand\n"
+		}
+		sum := sha256.Sum256([]byte(content))
+		paths = append(paths, file.path)
+		sums = append(sums, hex.EncodeToString(sum[:])+"  "+file.path)
+	}
+	sshClient.SetCommandResponse(fmt.Sprintf("sudo sha256sum %s 2>/dev/null || true", strings.Join(paths, " ")), strings.Join(sums, "\n"))
+
+	health := cm.GetClusterHealth(context.Background())
+	if health.DriftErr != nil || len(health.Drift) != 1 || health.Drift[0].Path != "/etc/systemd/system/kube-scheduler.service" {
+		t.Fatalf("Expected the scheduler unit to drift, got %+v (%v)", health.Drift, health.DriftErr)
+	}
+	events := map[string]string{}
+	for _, body := range received {
+		events[body["event"]] = body["message"]
+	}
+	if len(received) != 2 || !strings.Contains(events[string(EventDriftDetected)], "kube-scheduler.service") ||
+		!strings.Contains(events[string(EventCertificateExpiring)], "admin") {
+		t.Errorf("Expected a drift and a certificate expiry notification, got %v", received)
+	}
+
+	// Later checks don't repeat the alerts
+	cm.GetClusterHealth(context.Background())
+	if expiring, err := cm.CheckCertificateExpiry(CertificateWarningPeriod); err != nil || len(expiring) != 1 {
+		t.Errorf("Expected the admin certificate to be expiring, got %v (%v)", expiring, err)
+	}
+	if len(received) != 2 {
+		t.Errorf("Expected no repeated notifications, got %v", received[2:])
+	}
+}
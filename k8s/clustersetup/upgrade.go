@@ -0,0 +1,344 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// upgrade.go upgrades the Kubernetes binaries of a running cluster node by node.
+package clustersetup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiServerReadyTimeout bounds how long an upgraded API server gets to
+// report ready.
+const apiServerReadyTimeout = 2 * time.Minute
+
+// versionPattern matches a Kubernetes release such as "v1.29.3" and captures
+// its major and minor version.
+var versionPattern = regexp.MustCompile(`^v([0-9]+)\.([0-9]+)\.[0-9]+(-[0-9A-Za-z.]+)?$`)
+
+var (
+	// controllerUpgradeSteps are the steps of upgrading each controller.
+	controllerUpgradeSteps = []string{
+		"Installing control plane binaries",
+		"Restarting control plane services",
+		"Waiting for API server",
+	}
+	// workerUpgradeSteps are the steps of upgrading each worker.
+	workerUpgradeSteps = []string{
+		"Draining node",
+		"Installing Kubernetes binaries",
+		"Restarting kubelet and kube-proxy",
+		"Waiting for node Ready",
+		"Uncordoning node",
+	}
+	// canarySteps are the checks of the canary worker after its upgrade.
+	canarySteps = []string{
+		"Running a pod on the canary",
+		"Checking kubelet version",
+		"Waiting for confirmation",
+	}
+)
+
+// canaryPod is the pod the smoke test runs on the canary worker.
+const canaryPod = "upgrade-canary"
+
+// CanaryConfirm is asked whether an upgrade continues with the remaining
+// workers once the canary passed its smoke test. Returning false stops the
+// upgrade.
+type CanaryConfirm func(ctx context.Context, canary Node) (bool, error)
+
+// checkUpgradeVersion checks that target is a release the cluster can move
+// to from current: a later patch of the same minor version or the next minor
+// version, as Kubernetes doesn't support skipping minor versions.
+func checkUpgradeVersion(current, target string) error {
+	t := versionPattern.FindStringSubmatch(target)
+	if t == nil {
+		return fmt.Errorf("invalid Kubernetes version %q, expected a release such as v1.29.3", target)
+	}
+	if target == current {
+		return fmt.Errorf("cluster already runs %s", target)
+	}
+	c := versionPattern.FindStringSubmatch(current)
+	if c == nil {
+		// Nothing to compare against; trust the operator
+		return nil
+	}
+	currentMinor, _ := strconv.Atoi(c[2])
+	targetMinor, _ := strconv.Atoi(t[2])
+	switch {
+	case t[1] != c[1]:
+		return fmt.Errorf("can't upgrade across major versions from %s to %s", current, target)
+	case targetMinor < currentMinor:
+		return fmt.Errorf("can't downgrade from %s to %s", current, target)
+	case targetMinor > currentMinor+1:
+		return fmt.Errorf("can't skip minor versions from %s to %s; upgrade to v%s.%d first", current, target, c[1], currentMinor+1)
+	}
+	return nil
+}
+
+// UpgradeCluster upgrades the cluster to targetVersion in place. Controllers
+// are upgraded first, one at a time, each waiting for its API server to
+// report ready. Workers follow one at a time: each is drained, upgraded,
+// waited on until Ready and uncordoned. etcd and containerd keep their
+// versions. The manager's config records the target version once every node
+// is upgraded; after a failure, calling UpgradeCluster again reinstalls the
+// target binaries on every node, which is harmless on upgraded ones.
+func (cm *ClusterManager) UpgradeCluster(ctx context.Context, targetVersion string) error {
+	return cm.upgradeCluster(ctx, targetVersion, nil)
+}
+
+// UpgradeClusterCanary upgrades the cluster like UpgradeCluster, but after
+// the controllers only the first worker is upgraded. A pod pinned to it must
+// start and serve its logs and its kubelet must report the target version
+// before confirm is asked whether to upgrade the remaining workers. A failed
+// smoke test or a declined confirmation stops the upgrade with the other
+// workers on their previous version.
+func (cm *ClusterManager) UpgradeClusterCanary(ctx context.Context, targetVersion string, confirm CanaryConfirm) error {
+	if confirm == nil {
+		return fmt.Errorf("a canary upgrade needs a confirmation")
+	}
+	return cm.upgradeCluster(ctx, targetVersion, confirm)
+}
+
+// upgradeCluster upgrades the controllers and then the workers, checking the
+// first worker as a canary when confirm is set.
+func (cm *ClusterManager) upgradeCluster(ctx context.Context, targetVersion string, confirm CanaryConfirm) (err error) {
+	previous := cm.config.KubernetesVersion
+	if err := checkUpgradeVersion(previous, targetVersion); err != nil {
+		return err
+	}
+
+	controllers, workers := cm.config.ControlPlane(), cm.config.Workers
+	steps := len(controllers)*len(controllerUpgradeSteps) + len(workers)*len(workerUpgradeSteps)
+	phases := 2
+	if confirm != nil && len(workers) > 0 {
+		steps += len(canarySteps)
+		phases = 3
+	}
+	cm.tracker = newProgressTracker(steps)
+	cm.logger.Info(fmt.Sprintf("Upgrading cluster from %s to %s", previous, targetVersion))
+
+	// The install steps download the configured version
+	cm.config.KubernetesVersion = targetVersion
+	defer func() {
+		if err != nil {
+			cm.config.KubernetesVersion = previous
+		}
+	}()
+
+	cm.startPhase(1, phases, "Upgrading Control Plane")
+	for _, controller := range controllers {
+		if err := cm.upgradeController(ctx, controller); err != nil {
+			return fmt.Errorf("failed to upgrade %s: %w", controller.Name, err)
+		}
+	}
+
+	if phases == 3 {
+		canary := workers[0]
+		cm.startPhase(2, phases, "Upgrading Canary "+canary.Name)
+		if err := cm.upgradeWorker(ctx, canary); err != nil {
+			return fmt.Errorf("failed to upgrade %s: %w", canary.Name, err)
+		}
+		if err := cm.checkCanary(ctx, canary, targetVersion, confirm, len(workers) > 1); err != nil {
+			return err
+		}
+		workers = workers[1:]
+	}
+
+	cm.startPhase(phases, phases, "Upgrading Workers")
+	for _, worker := range workers {
+		if err := cm.upgradeWorker(ctx, worker); err != nil {
+			return fmt.Errorf("failed to upgrade %s: %w", worker.Name, err)
+		}
+	}
+
+	cm.recordConfig()
+	cm.logger.Info(fmt.Sprintf("Cluster upgraded to %s", targetVersion))
+	return nil
+}
+
+// checkCanary runs the smoke test against the upgraded canary worker and,
+// when other workers remain, asks confirm whether to continue with them.
+func (cm *ClusterManager) checkCanary(ctx context.Context, canary Node, targetVersion string, confirm CanaryConfirm, remaining bool) error {
+	progress := cm.nodeProgress(canary.Name, canarySteps)
+
+	progress.advance()
+	if err := cm.runCanaryPod(ctx, canary); err != nil {
+		return fmt.Errorf("canary %s failed its smoke test: %w", canary.Name, err)
+	}
+
+	progress.advance()
+	nodeCmd := fmt.Sprintf("kubectl get node %s -o json --kubeconfig /var/lib/kubernetes/admin.kubeconfig", canary.Name)
+	output, err := cm.sshClient.ExecuteCommand(ctx, cm.config.Controller.IPAddress, nodeCmd)
+	if err != nil {
+		return fmt.Errorf("failed to get the status of canary %s: %w", canary.Name, err)
+	}
+	var node nodeStatus
+	if err := json.Unmarshal([]byte(output), &node); err != nil {
+		return fmt.Errorf("unreadable status of canary %s: %w", canary.Name, err)
+	}
+	if version := node.Status.NodeInfo.KubeletVersion; version != targetVersion {
+		return fmt.Errorf("canary %s reports kubelet %q, expected %s", canary.Name, version, targetVersion)
+	}
+	cm.logger.Info(fmt.Sprintf("Canary %s passed its smoke test", canary.Name))
+
+	progress.advance()
+	if !remaining {
+		return nil
+	}
+	proceed, err := confirm(ctx, canary)
+	if err != nil {
+		return fmt.Errorf("failed to confirm the upgrade after canary %s: %w", canary.Name, err)
+	}
+	if !proceed {
+		return fmt.Errorf("upgrade stopped after canary %s; the other workers still run the previous version", canary.Name)
+	}
+	return nil
+}
+
+// runCanaryPod runs a pod that can only be scheduled on the canary, waits
+// for it to become ready and reads its logs, which goes through the
+// canary's kubelet. The pod is deleted afterwards.
+func (cm *ClusterManager) runCanaryPod(ctx context.Context, canary Node) error {
+	controller := cm.config.Controller
+	manifestPath := "/tmp/upgrade-canary.yaml"
+	if err := cm.sshClient.CopyContent(ctx, controller.IPAddress, cm.generateCanaryPodManifest(canary), manifestPath); err != nil {
+		return fmt.Errorf("failed to upload canary pod manifest: %w", err)
+	}
+	defer func() {
+		deleteCmd := fmt.Sprintf("kubectl delete pod %s --ignore-not-found --kubeconfig /var/lib/kubernetes/admin.kubeconfig", canaryPod)
+		if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, deleteCmd); err != nil {
+			cm.logger.Warn(fmt.Sprintf("Failed to delete canary pod: %v", err))
+		}
+	}()
+
+	for _, cmd := range []string{
+		fmt.Sprintf("kubectl apply -f %s --kubeconfig /var/lib/kubernetes/admin.kubeconfig", manifestPath),
+		fmt.Sprintf("kubectl wait --for=condition=Ready pod/%s --timeout=120s --kubeconfig /var/lib/kubernetes/admin.kubeconfig", canaryPod),
+		fmt.Sprintf("kubectl logs %s --kubeconfig /var/lib/kubernetes/admin.kubeconfig", canaryPod),
+	} {
+		if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateCanaryPodManifest generates a pod manifest whose node affinity only
+// allows the canary, so it goes through the scheduler like any other pod.
+func (cm *ClusterManager) generateCanaryPodManifest(canary Node) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Pod
+metadata:
+  name: %s
+  labels:
+    app: %s
+spec:
+  affinity:
+    nodeAffinity:
+      requiredDuringSchedulingIgnoredDuringExecution:
+        nodeSelectorTerms:
+        - matchFields:
+          - key: metadata.name
+            operator: In
+            values:
+            - %s
+  containers:
+  - name: nginx
+    image: nginx:1.14.2
+    ports:
+    - containerPort: 80
+`, canaryPod, canaryPod, canary.Name)
+}
+
+// upgradeController installs the target control plane binaries on a
+// controller and restarts its services.
+func (cm *ClusterManager) upgradeController(ctx context.Context, controller Node) error {
+	progress := cm.nodeProgress(controller.Name, controllerUpgradeSteps)
+
+	progress.advance()
+	if err := cm.runInstall(ctx, controller, cm.controlPlaneInstall()); err != nil {
+		return err
+	}
+
+	progress.advance()
+	if _, err := cm.sshClient.ExecuteCommand(ctx, controller.IPAddress,
+		"sudo systemctl restart kube-apiserver kube-controller-manager kube-scheduler"); err != nil {
+		return fmt.Errorf("failed to restart control plane services: %w", err)
+	}
+	for _, service := range controllerServices {
+		if err := cm.waitForService(ctx, controller.IPAddress, service, 60*time.Second); err != nil {
+			return err
+		}
+	}
+
+	progress.advance()
+	if err := cm.waitForAPIServer(ctx, controller, apiServerReadyTimeout); err != nil {
+		return err
+	}
+	cm.publish(Event{Type: EventNodeCompleted, Phase: "upgrade", Node: controller.Name})
+	return nil
+}
+
+// waitForAPIServer polls the readyz endpoint of the API server on a
+// controller until it reports ok.
+func (cm *ClusterManager) waitForAPIServer(ctx context.Context, controller Node, timeout time.Duration) error {
+	readyCmd := fmt.Sprintf("kubectl get --raw=/readyz --server=https://%s:6443 --kubeconfig /var/lib/kubernetes/admin.kubeconfig", controller.IPAddress)
+	deadline := time.Now().Add(timeout)
+	for {
+		// A probe that hangs is killed at the deadline
+		probeCtx, cancel := context.WithDeadline(ctx, deadline)
+		output, err := cm.sshClient.ExecuteCommand(probeCtx, cm.config.Controller.IPAddress, readyCmd)
+		cancel()
+		if err == nil && strings.TrimSpace(output) == "ok" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("API server on %s not ready after %v", controller.Name, timeout)
+		}
+		cm.countRetry(controller.Name)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// upgradeWorker drains a worker, installs the target binaries, restarts the
+// node services and returns the node to service once it's Ready.
+func (cm *ClusterManager) upgradeWorker(ctx context.Context, worker Node) error {
+	progress := cm.nodeProgress(worker.Name, workerUpgradeSteps)
+
+	progress.advance()
+	if err := cm.drainNode(ctx, worker.Name); err != nil {
+		return err
+	}
+
+	progress.advance()
+	if err := cm.runInstall(ctx, worker, cm.workerKubernetesInstall()); err != nil {
+		return err
+	}
+
+	progress.advance()
+	if _, err := cm.sshClient.ExecuteCommand(ctx, worker.IPAddress, "sudo systemctl restart kubelet kube-proxy"); err != nil {
+		return fmt.Errorf("failed to restart kubelet and kube-proxy: %w", err)
+	}
+
+	progress.advance()
+	if err := cm.waitForNodeReady(ctx, worker, nodeReadyTimeout); err != nil {
+		return err
+	}
+
+	progress.advance()
+	uncordonCmd := fmt.Sprintf("kubectl uncordon %s --kubeconfig /var/lib/kubernetes/admin.kubeconfig", worker.Name)
+	if _, err := cm.sshClient.ExecuteCommand(ctx, cm.config.Controller.IPAddress, uncordonCmd); err != nil {
+		return fmt.Errorf("failed to uncordon %s: %w", worker.Name, err)
+	}
+	cm.publish(Event{Type: EventNodeCompleted, Phase: "upgrade", Node: worker.Name})
+	return nil
+}
@@ -0,0 +1,322 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// vault.go retrieves credentials and signs certificates through HashiCorp Vault.
+package clustersetup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// VaultConfig configures the optional Vault backend. Address and Token
+// default to the VAULT_ADDR and VAULT_TOKEN environment variables.
+type VaultConfig struct {
+	Address      string          `yaml:"address,omitempty"`
+	Token        string          `yaml:"token,omitempty"`
+	Namespace    string          `yaml:"namespace,omitempty"`
+	SSHKey       VaultSecretRef  `yaml:"ssh_key,omitempty"`
+	SudoPassword VaultSecretRef  `yaml:"sudo_password,omitempty"`
+	PKI          *VaultPKIConfig `yaml:"pki,omitempty"`
+	// SSHKeyPassphrase decrypts a passphrase-protected SSH key, from Vault
+	// or the local disk.
+	SSHKeyPassphrase VaultSecretRef `yaml:"ssh_key_passphrase,omitempty"`
+}
+
+// VaultSecretRef points at a single field of a Vault secret. KV version 2
+// paths must include the "data/" segment, e.g. "secret/data/k8s/ssh".
+type VaultSecretRef struct {
+	Path  string `yaml:"path,omitempty"`
+	Field string `yaml:"field,omitempty"`
+}
+
+// VaultPKIConfig configures certificate signing through a Vault PKI engine.
+// The PKI engine never exports its CA key, so the key the controller manager
+// uses to sign kubelet certificates is read from CAKey.
+type VaultPKIConfig struct {
+	Mount string         `yaml:"mount"`
+	Role  string         `yaml:"role"`
+	TTL   string         `yaml:"ttl,omitempty"`
+	CAKey VaultSecretRef `yaml:"ca_key"`
+}
+
+// IsSet reports whether the reference points at a secret.
+func (r VaultSecretRef) IsSet() bool {
+	return r.Path != ""
+}
+
+// Enabled reports whether any credential or the PKI engine is sourced from Vault.
+func (v VaultConfig) Enabled() bool {
+	return v.SSHKey.IsSet() || v.SudoPassword.IsSet() || v.SSHKeyPassphrase.IsSet() || v.PKI != nil
+}
+
+// validateVault checks that the Vault configuration is complete.
+func validateVault(config VaultConfig) error {
+	if !config.Enabled() {
+		return nil
+	}
+	if config.Address == "" && os.Getenv("VAULT_ADDR") == "" {
+		return fmt.Errorf("address is required when VAULT_ADDR is not set")
+	}
+	for name, ref := range map[string]VaultSecretRef{"ssh_key": config.SSHKey, "sudo_password": config.SudoPassword, "ssh_key_passphrase": config.SSHKeyPassphrase} {
+		if ref.IsSet() && ref.Field == "" {
+			return fmt.Errorf("%s.field is required", name)
+		}
+	}
+	if config.PKI != nil {
+		if config.PKI.Mount == "" || config.PKI.Role == "" {
+			return fmt.Errorf("pki.mount and pki.role are required")
+		}
+		if !config.PKI.CAKey.IsSet() || config.PKI.CAKey.Field == "" {
+			return fmt.Errorf("pki.ca_key.path and pki.ca_key.field are required")
+		}
+	}
+	return nil
+}
+
+// VaultClient is a minimal client for the Vault HTTP API.
+type VaultClient struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// NewVaultClient creates a Vault client, falling back to VAULT_ADDR,
+// VAULT_TOKEN and VAULT_NAMESPACE for unset fields.
+func NewVaultClient(config VaultConfig) (*VaultClient, error) {
+	address := config.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	token := config.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	namespace := config.Namespace
+	if namespace == "" {
+		namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if address == "" {
+		return nil, fmt.Errorf("vault address is not configured")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("vault token is not configured")
+	}
+	return &VaultClient{
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		namespace: namespace,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// vaultResponse is the envelope returned by Vault API calls.
+type vaultResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []string               `json:"errors"`
+}
+
+// request performs an API call and returns the response data.
+func (v *VaultClient) request(ctx context.Context, method, path string, body interface{}) (map[string]interface{}, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	url := v.address + "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	req.Header.Set("Content-Type", "application/json")
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request to %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	var result vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && resp.StatusCode < 300 {
+		return nil, fmt.Errorf("failed to decode vault response for %s: %w", path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if len(result.Errors) > 0 {
+			return nil, fmt.Errorf("vault returned %s for %s: %s", resp.Status, path, strings.Join(result.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault returned %s for %s", resp.Status, path)
+	}
+	return result.Data, nil
+}
+
+// ReadSecret reads a secret and returns its fields. KV version 2 responses
+// are unwrapped so callers see the secret's own fields.
+func (v *VaultClient) ReadSecret(ctx context.Context, path string) (map[string]interface{}, error) {
+	data, err := v.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			return inner, nil
+		}
+	}
+	return data, nil
+}
+
+// ReadField reads a single string field of a secret.
+func (v *VaultClient) ReadField(ctx context.Context, ref VaultSecretRef) (string, error) {
+	data, err := v.ReadSecret(ctx, ref.Path)
+	if err != nil {
+		return "", err
+	}
+	value, ok := data[ref.Field].(string)
+	if !ok {
+		return "", fmt.Errorf("field %s not found in vault secret %s", ref.Field, ref.Path)
+	}
+	return value, nil
+}
+
+// NewSSHClientFromVault creates an SSH client whose private key, and sudo
+// password and key passphrase if configured, are read from Vault instead of
+// the local disk.
+func NewSSHClientFromVault(ctx context.Context, vault *VaultClient, user string, config VaultConfig) (*RealSSHClient, error) {
+	if !config.SSHKey.IsSet() {
+		return nil, fmt.Errorf("vault ssh_key is not configured")
+	}
+	key, err := vault.ReadField(ctx, config.SSHKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key from vault: %w", err)
+	}
+	client := &RealSSHClient{user: user, keyData: []byte(key)}
+
+	if config.SudoPassword.IsSet() {
+		password, err := vault.ReadField(ctx, config.SudoPassword)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sudo password from vault: %w", err)
+		}
+		client.SetSudoPassword(password)
+	}
+	if config.SSHKeyPassphrase.IsSet() {
+		passphrase, err := vault.ReadField(ctx, config.SSHKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SSH key passphrase from vault: %w", err)
+		}
+		client.SetKeyPassphrase(passphrase)
+	}
+	return client, nil
+}
+
+// VaultCertificateManager implements the CertificateManager interface by
+// issuing certificates from a Vault PKI engine.
+type VaultCertificateManager struct {
+	vault  *VaultClient
+	config VaultPKIConfig
+}
+
+// NewVaultCertificateManager creates a certificate manager backed by Vault PKI.
+func NewVaultCertificateManager(vault *VaultClient, config VaultPKIConfig) *VaultCertificateManager {
+	return &VaultCertificateManager{vault: vault, config: config}
+}
+
+// GenerateCA fetches the PKI engine's CA certificate and the signing key
+// stored alongside it.
+func (cm *VaultCertificateManager) GenerateCA(workDir string, config CertificateConfig) error {
+	ctx := context.Background()
+	data, err := cm.vault.request(ctx, http.MethodGet, cm.config.Mount+"/cert/ca", nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch CA from vault: %w", err)
+	}
+	caCert, ok := data["certificate"].(string)
+	if !ok || caCert == "" {
+		return fmt.Errorf("vault returned no CA certificate for %s", cm.config.Mount)
+	}
+	caKey, err := cm.vault.ReadField(ctx, cm.config.CAKey)
+	if err != nil {
+		return fmt.Errorf("failed to read CA key from vault: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(workDir, "ca.pem"), []byte(ensureTrailingNewline(caCert)), 0644); err != nil {
+		return fmt.Errorf("failed to write CA certificate: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "ca-key.pem"), []byte(ensureTrailingNewline(caKey)), 0600); err != nil {
+		return fmt.Errorf("failed to write CA key: %w", err)
+	}
+	return nil
+}
+
+// GenerateClientCert issues a client certificate from Vault.
+func (cm *VaultCertificateManager) GenerateClientCert(workDir, name string, config CertificateConfig) error {
+	return cm.issue(workDir, name, nil)
+}
+
+// GenerateServerCert issues a server certificate from Vault.
+func (cm *VaultCertificateManager) GenerateServerCert(workDir, name string, hosts []string, config CertificateConfig) error {
+	return cm.issue(workDir, name, hosts)
+}
+
+// issue requests a certificate for name from the PKI role and writes the
+// certificate and private key to the work directory.
+func (cm *VaultCertificateManager) issue(workDir, name string, hosts []string) error {
+	body := map[string]interface{}{"common_name": name}
+	if cm.config.TTL != "" {
+		body["ttl"] = cm.config.TTL
+	}
+
+	var altNames, ipSANs []string
+	for _, host := range hosts {
+		if net.ParseIP(host) != nil {
+			ipSANs = append(ipSANs, host)
+		} else if host != "" {
+			altNames = append(altNames, host)
+		}
+	}
+	if len(altNames) > 0 {
+		body["alt_names"] = strings.Join(altNames, ",")
+	}
+	if len(ipSANs) > 0 {
+		body["ip_sans"] = strings.Join(ipSANs, ",")
+	}
+
+	data, err := cm.vault.request(context.Background(), http.MethodPost, cm.config.Mount+"/issue/"+cm.config.Role, body)
+	if err != nil {
+		return fmt.Errorf("failed to issue certificate for %s: %w", name, err)
+	}
+	cert, _ := data["certificate"].(string)
+	key, _ := data["private_key"].(string)
+	if cert == "" || key == "" {
+		return fmt.Errorf("vault returned an incomplete certificate for %s", name)
+	}
+
+	if err := os.WriteFile(filepath.Join(workDir, name+".pem"), []byte(ensureTrailingNewline(cert)), 0644); err != nil {
+		return fmt.Errorf("failed to write certificate for %s: %w", name, err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, name+"-key.pem"), []byte(ensureTrailingNewline(key)), 0600); err != nil {
+		return fmt.Errorf("failed to write key for %s: %w", name, err)
+	}
+	return nil
+}
+
+// ensureTrailingNewline terminates PEM data returned by Vault with a newline.
+func ensureTrailingNewline(s string) string {
+	if strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
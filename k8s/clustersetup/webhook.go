@@ -0,0 +1,246 @@
+// Package k8shard provides automation for setting up Kubernetes clusters.
+// webhook.go proves that the API servers can call admission webhooks running in the cluster.
+package clustersetup
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// webhookNamespace holds the smoke test webhook; only objects in it are
+	// sent to the webhook.
+	webhookNamespace = "webhook-smoke-test"
+	// webhookName names the webhook's deployment, service, certificate and
+	// ValidatingWebhookConfiguration.
+	webhookName = "webhook-smoke"
+	// webhookImage runs the webhook server script.
+	webhookImage = "python:3.12-alpine"
+	// webhookDeniedName is the ConfigMap name the webhook rejects.
+	webhookDeniedName = "webhook-smoke-denied"
+	// webhookRolloutTimeout bounds how long the webhook server gets to start.
+	webhookRolloutTimeout = 2 * time.Minute
+)
+
+// webhookServer is a validating webhook that allows every object except
+// ConfigMaps named webhookDeniedName.
+const webhookServer = `import json
+import ssl
+from http.server import BaseHTTPRequestHandler, HTTPServer
+
+
+class Handler(BaseHTTPRequestHandler):
+    def do_POST(self):
+        review = json.loads(self.rfile.read(int(self.headers["Content-Length"])))
+        request = review["request"]
+        response = {"uid": request["uid"], "allowed": request.get("name") != "` + webhookDeniedName + `"}
+        if not response["allowed"]:
+            response["status"] = {"code": 403, "message": "denied by ` + webhookName + `"}
+        body = json.dumps({"apiVersion": "admission.k8s.io/v1", "kind": "AdmissionReview", "response": response}).encode()
+        self.send_response(200)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(body)))
+        self.end_headers()
+        self.wfile.write(body)
+
+
+server = HTTPServer(("", 8443), Handler)
+context = ssl.SSLContext(ssl.PROTOCOL_TLS_SERVER)
+context.load_cert_chain("/tls/tls.crt", "/tls/tls.key")
+server.socket = context.wrap_socket(server.socket, server_side=True)
+server.serve_forever()
+`
+
+// webhookHosts are the names the API server verifies the webhook's serving
+// certificate against.
+var webhookHosts = []string{
+	webhookName + "." + webhookNamespace + ".svc",
+	webhookName + "." + webhookNamespace + ".svc.cluster.local",
+}
+
+// indent prefixes every non-empty line of text.
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// generateWebhookServerManifest generates the namespace, TLS secret, script
+// and deployment of the smoke test webhook and the service in front of it.
+func (cm *ClusterManager) generateWebhookServerManifest(cert, key []byte) string {
+	return fmt.Sprintf(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: %[1]s
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: %[2]s-tls
+  namespace: %[1]s
+type: kubernetes.io/tls
+data:
+  tls.crt: %[3]s
+  tls.key: %[4]s
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %[2]s
+  namespace: %[1]s
+data:
+  server.py: |
+%[5]s---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: %[2]s
+  namespace: %[1]s
+spec:
+  selector:
+    matchLabels:
+      app: %[2]s
+  template:
+    metadata:
+      labels:
+        app: %[2]s
+    spec:
+      containers:
+      - name: webhook
+        image: %[6]s
+        command: ["python", "/app/server.py"]
+        ports:
+        - containerPort: 8443
+        volumeMounts:
+        - name: app
+          mountPath: /app
+        - name: tls
+          mountPath: /tls
+      volumes:
+      - name: app
+        configMap:
+          name: %[2]s
+      - name: tls
+        secret:
+          secretName: %[2]s-tls
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: %[2]s
+  namespace: %[1]s
+spec:
+  selector:
+    app: %[2]s
+  ports:
+  - port: 443
+    targetPort: 8443
+`, webhookNamespace, webhookName, base64.StdEncoding.EncodeToString(cert), base64.StdEncoding.EncodeToString(key),
+		indent(webhookServer, "    "), webhookImage)
+}
+
+// generateWebhookConfigurationManifest generates the
+// ValidatingWebhookConfiguration that sends ConfigMaps created in the smoke
+// test namespace to the webhook, trusting the cluster CA.
+func (cm *ClusterManager) generateWebhookConfigurationManifest(caCert []byte) string {
+	return fmt.Sprintf(`apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: %[2]s
+webhooks:
+- name: %[2]s.%[1]s.svc
+  admissionReviewVersions: ["v1"]
+  sideEffects: None
+  failurePolicy: Fail
+  timeoutSeconds: 10
+  namespaceSelector:
+    matchLabels:
+      kubernetes.io/metadata.name: %[1]s
+  rules:
+  - apiGroups: [""]
+    apiVersions: ["v1"]
+    operations: ["CREATE"]
+    resources: ["configmaps"]
+  clientConfig:
+    service:
+      name: %[2]s
+      namespace: %[1]s
+      path: /validate
+    caBundle: %[3]s
+`, webhookNamespace, webhookName, base64.StdEncoding.EncodeToString(caCert))
+}
+
+// testAdmissionWebhook deploys a validating webhook and checks that the API
+// server both admits and rejects ConfigMaps through it, which needs the
+// aggregator routing, pod routes on the controllers and CA bundle to work.
+// The webhook is removed afterwards whether the test passes or not.
+func (cm *ClusterManager) testAdmissionWebhook(ctx context.Context) error {
+	workDir := cm.config.WorkDir
+	controller := cm.config.Controller
+	kubectl := func(args string) (string, error) {
+		return cm.sshClient.ExecuteCommand(ctx, controller.IPAddress,
+			fmt.Sprintf("kubectl %s --kubeconfig /var/lib/kubernetes/admin.kubeconfig", args))
+	}
+
+	if err := cm.certManager.GenerateServerCert(workDir, webhookName, webhookHosts, cm.config.Certificates); err != nil {
+		return fmt.Errorf("failed to generate webhook certificate: %w", err)
+	}
+	var pems [3][]byte
+	for i, file := range []string{"ca.pem", webhookName + ".pem", webhookName + "-key.pem"} {
+		content, err := os.ReadFile(filepath.Join(workDir, file))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		pems[i] = content
+	}
+
+	defer func() {
+		kubectl("delete validatingwebhookconfiguration " + webhookName + " --ignore-not-found")
+		kubectl("delete namespace " + webhookNamespace + " --ignore-not-found --wait=false")
+	}()
+
+	apply := func(path, manifest string) error {
+		if err := cm.sshClient.CopyContent(ctx, controller.IPAddress, manifest, path); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", path, err)
+		}
+		if _, err := kubectl("apply -f " + path); err != nil {
+			return fmt.Errorf("failed to apply %s: %w", path, err)
+		}
+		return nil
+	}
+
+	if err := apply("/tmp/webhook-smoke-server.yaml", cm.generateWebhookServerManifest(pems[1], pems[2])); err != nil {
+		return err
+	}
+	if _, err := kubectl(fmt.Sprintf("rollout status deployment/%s -n %s --timeout=%s", webhookName, webhookNamespace, webhookRolloutTimeout)); err != nil {
+		return fmt.Errorf("webhook server didn't start: %w", err)
+	}
+	// The webhook only takes effect once its server is up, so the server's own
+	// objects aren't sent to it
+	if err := apply("/tmp/webhook-smoke-config.yaml", cm.generateWebhookConfigurationManifest(pems[0])); err != nil {
+		return err
+	}
+
+	if _, err := kubectl(fmt.Sprintf("create configmap %s-allowed -n %s", webhookName, webhookNamespace)); err != nil {
+		return fmt.Errorf("API server couldn't call the admission webhook: %w", err)
+	}
+	// kubectl's stderr, which carries the webhook's message, is part of the error
+	_, err := kubectl(fmt.Sprintf("create configmap %s -n %s", webhookDeniedName, webhookNamespace))
+	if err == nil {
+		return fmt.Errorf("admission webhook didn't reject %s", webhookDeniedName)
+	}
+	if !strings.Contains(err.Error(), "denied by "+webhookName) {
+		return fmt.Errorf("admission webhook rejected %s for the wrong reason: %w", webhookDeniedName, err)
+	}
+	cm.logger.Info("Admission webhooks are reachable from the API server")
+	return nil
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/RaymondAkachi/custom-kub-cli/internal/config"
 	"github.com/RaymondAkachi/custom-kub-cli/internal/system"
@@ -11,6 +14,16 @@ import (
 )
 
 func main() {
+	workspace := flag.String("workspace", "", "workspace to open (default $"+config.WorkspaceEnv+" or \""+config.DefaultWorkspace+"\")")
+	readOnly := flag.Bool("read-only", false, "block every command that changes clusters, Git repositories or cluster setups")
+	flag.Parse()
+	if *workspace == "" {
+		*workspace = os.Getenv(config.WorkspaceEnv)
+	}
+	if *workspace == "" {
+		*workspace = config.DefaultWorkspace
+	}
+
 	// Check system dependencies first
 	dc := system.NewDependencyChecker()
 
@@ -29,7 +42,7 @@ func main() {
 
 
 	// Initialize configuration
-	cfg, err := config.Initialize()
+	cfg, err := config.Initialize(*workspace)
 	if err != nil {
 		fmt.Printf("❌ Failed to initialize configuration: %v\n", err)
 		os.Exit(1)
@@ -41,13 +54,40 @@ func main() {
 		fmt.Printf("❌ Failed to create application: %v\n", err)
 		os.Exit(1)
 	}
+	app.SetReadOnly(*readOnly)
 
 	fmt.Println("🚀 Starting Kubernetes Orchestrator...")
 	fmt.Println("✅ All dependencies verified")
 	
-	p := tea.NewProgram(app, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	// Signals are turned into a shutdown the application can delay until a
+	// running cluster setup reaches a safe point; a second one exits at once
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	p := tea.NewProgram(app, tea.WithAltScreen(), tea.WithoutSignalHandler())
+	go func() {
+		for range signals {
+			p.Send(ui.ShutdownMsg{})
+		}
+	}()
+	model, err := p.Run()
+	// Switching workspaces replaces the application, so ask the last one
+	if final, ok := model.(*ui.Application); ok {
+		app = final
+	}
+	app.Close()
+	if err != nil {
 		fmt.Printf("❌ Application error: %v\n", err)
 		os.Exit(1)
 	}
+	if crash := app.Crash(); crash != nil {
+		fmt.Printf("💥 Kubernetes Orchestrator crashed: %v\n", crash.Value)
+		if crash.Err != nil {
+			fmt.Printf("❌ %v\n", crash.Err)
+		} else {
+			fmt.Printf("A crash report was written to %s\n", crash.ReportPath)
+		}
+		os.Exit(2)
+	}
 }
\ No newline at end of file